@@ -20,10 +20,20 @@ func main() {
 	csvPath := os.Args[1]
 
 	// Load config
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	for _, warning := range cfg.Warnings {
+		log.Printf("WARNING: %s", warning)
+	}
 
 	// Connect to database
-	gormDB, err := db.Open(cfg.DBDsn)
+	gormDB, err := db.Open(cfg.DBDsn, db.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -2,25 +2,55 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
 	"time"
 
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/config"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/db"
 	httpapi "github.com/huaodong/emfield-teaching-platform/backend/internal/http"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 )
 
 func main() {
 	logger.Init()
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		var invalid *config.InvalidError
+		if errors.As(err, &invalid) {
+			logger.Log.Error("invalid configuration", slog.Any("problems", invalid.Problems))
+		} else {
+			logger.Log.Error("config load failed", slog.Any("error", err))
+		}
+		os.Exit(1)
+	}
+	for _, warning := range cfg.Warnings {
+		logger.Log.Warn("INSECURE CONFIGURATION", slog.String("app_env", cfg.AppEnv), slog.String("problem", warning))
+	}
+	logger.Log.Info("configuration loaded", slog.Any("config", cfg))
+	if unknown := services.UnknownModules(cfg.DefaultCourseModules); len(unknown) > 0 {
+		logger.Log.Warn("unknown modules in DEFAULT_COURSE_MODULES", slog.Any("modules", unknown), slog.Any("known", services.KnownModules))
+	}
+	if cfg.PasswordHashCost != 0 {
+		if err := auth.SetPasswordCost(cfg.PasswordHashCost); err != nil {
+			logger.Log.Warn("ignoring PASSWORD_HASH_COST", slog.Int("value", cfg.PasswordHashCost), slog.Any("error", err))
+		}
+	}
 
-	gormDB, err := db.Open(cfg.DBDsn)
+	gormDB, err := db.Open(cfg.DBDsn, db.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
 	if err != nil {
 		logger.Log.Error("db open failed", slog.Any("error", err))
 		os.Exit(1)
@@ -29,37 +59,45 @@ func main() {
 		logger.Log.Error("db migrate failed", slog.Any("error", err))
 		os.Exit(1)
 	}
-	seeded, err := db.SeedDemoUsers(gormDB)
-	if err != nil {
-		logger.Log.Error("db seed failed", slog.Any("error", err))
-		os.Exit(1)
-	}
-	if seeded {
-		logger.Log.Warn("bootstrap demo users created", slog.String("note", "admin/admin123, teacher/teacher123, student/student123 (please change in production)"))
+	if cfg.SeedDemoUsers {
+		created, err := db.SeedDemoUsers(gormDB, cfg.DemoPasswords)
+		if err != nil {
+			logger.Log.Error("db seed failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+		if created > 0 {
+			logger.Log.Warn("demo users created",
+				slog.Int("count", created),
+				slog.Any("password_overrides", sortedKeys(cfg.DemoPasswords)),
+				slog.String("note", "roles without an override use the documented default passwords; do not enable in production"),
+			)
+		} else {
+			logger.Log.Info("demo users already present, nothing seeded")
+		}
+	} else {
+		logger.Log.Info("demo user seeding skipped", slog.String("hint", "set SEED_DEMO_USERS=true to enable"))
 	}
 
 	aiClient := clients.NewAIClient(cfg.AIBaseURL)
 	simClient := clients.NewSimClient(cfg.SimBaseURL)
 
 	// Initialize MinIO client
-	signedURLExpiry, err := time.ParseDuration(cfg.MinioSignedURLExpiry)
-	if err != nil {
-		signedURLExpiry = 7 * 24 * time.Hour // default 7 days
-	}
 	minioClient, err := clients.NewMinioClient(clients.MinioConfig{
 		Endpoint:        cfg.MinioEndpoint,
 		AccessKey:       cfg.MinioAccessKey,
 		SecretKey:       cfg.MinioSecretKey,
 		BucketName:      cfg.MinioBucket,
 		UseSSL:          cfg.MinioUseSSL,
-		SignedURLExpiry: signedURLExpiry,
+		SignedURLExpiry: cfg.MinioSignedURLExpiry,
 	})
 	if err != nil {
 		logger.Log.Warn("minio client init failed (file upload disabled)", slog.Any("error", err))
 		minioClient = nil
 	}
 
-	router := httpapi.NewRouter(cfg, gormDB, aiClient, simClient, minioClient)
+	queue := jobs.NewQueue(cfg.JobWorkers, cfg.JobQueueSize)
+
+	router := httpapi.NewRouter(cfg, gormDB, aiClient, simClient, minioClient, queue)
 
 	server := &http.Server{
 		Addr:              cfg.HTTPAddr,
@@ -82,5 +120,22 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_ = server.Shutdown(ctx)
+
+	// Let queued background tasks (AI analysis, notifications) finish.
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer drainCancel()
+	if err := queue.Shutdown(drainCtx); err != nil {
+		logger.Log.Warn("background tasks not drained", slog.Int("queued", queue.Depth()), slog.Any("error", err))
+	}
 	logger.Log.Info("backend stopped")
 }
+
+// sortedKeys returns the keys of m in sorted order, for logging.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
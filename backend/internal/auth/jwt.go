@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -14,27 +15,63 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func SignToken(secret string, userID uint, username string, role string, ttl time.Duration) (string, error) {
+// TokenConfig holds the signing secret and the issuer/audience stamped into
+// every token and required when parsing. An empty Issuer or Audience is
+// neither set nor checked. Sessions, when set, lets AuthRequired reject
+// revoked tokens. Courses, when set, lets teachers and admins preview courses
+// they manage as a student (see middleware.ViewAsHeader).
+type TokenConfig struct {
+	Secret   string
+	Issuer   string
+	Audience string
+	Sessions SessionStore
+	Courses  CourseManagers
+}
+
+// CourseManagers reports whether a user may manage a course.
+type CourseManagers interface {
+	ManagesCourse(ctx context.Context, userID uint, role string, courseID uint) (bool, error)
+}
+
+// SessionStore revokes a user's sessions: every token issued to the user at or
+// before the revocation is rejected.
+type SessionStore interface {
+	Revoke(ctx context.Context, userID uint, reason string) error
+	IsRevoked(ctx context.Context, userID uint, issuedAt time.Time) (bool, error)
+}
+
+func SignToken(cfg TokenConfig, userID uint, username string, role string, ttl time.Duration) (string, error) {
 	now := time.Now()
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.Issuer,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
+	if cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{cfg.Audience}
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return token.SignedString([]byte(cfg.Secret))
 }
 
-func ParseToken(secret string, tokenString string) (*Claims, error) {
-	parser := jwt.NewParser(
+func ParseToken(cfg TokenConfig, tokenString string) (*Claims, error) {
+	opts := []jwt.ParserOption{
 		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
-	)
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	parser := jwt.NewParser(opts...)
 	token, err := parser.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
-		return []byte(secret), nil
+		return []byte(cfg.Secret), nil
 	})
 	if err != nil {
 		return nil, err
@@ -1,9 +1,27 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCost is the bcrypt cost for new hashes. Existing hashes carry their
+// own cost, so changing it never breaks VerifyPassword. It is set once at
+// startup, before any request is served.
+var passwordCost = bcrypt.DefaultCost
+
+// SetPasswordCost changes the bcrypt cost used by HashPassword.
+func SetPasswordCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("password hash cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	passwordCost = cost
+	return nil
+}
 
 func HashPassword(plain string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	b, err := bcrypt.GenerateFromPassword([]byte(plain), passwordCost)
 	if err != nil {
 		return "", err
 	}
@@ -13,3 +31,13 @@ func HashPassword(plain string) (string, error) {
 func VerifyPassword(hash, plain string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
 }
+
+// NeedsRehash reports whether hash was made with a lower cost than the
+// current one and should be replaced on the next successful login.
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return cost < passwordCost
+}
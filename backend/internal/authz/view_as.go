@@ -0,0 +1,18 @@
+package authz
+
+import "context"
+
+type viewAsCourseKey struct{}
+
+// WithViewAsCourse marks ctx as a teacher previewing courseID as a student.
+// Code that lets enrolled students in treats the previewer as enrolled in
+// that course only.
+func WithViewAsCourse(ctx context.Context, courseID uint) context.Context {
+	return context.WithValue(ctx, viewAsCourseKey{}, courseID)
+}
+
+// ViewingAsStudent reports whether ctx previews courseID as a student.
+func ViewingAsStudent(ctx context.Context, courseID uint) bool {
+	id, ok := ctx.Value(viewAsCourseKey{}).(uint)
+	return ok && id != 0 && id == courseID
+}
@@ -49,6 +49,11 @@ func NewAIClient(baseURL string) *AIClient {
 	}
 }
 
+// IsConfigured returns true if an AI service base URL is set
+func (c *AIClient) IsConfigured() bool {
+	return c != nil && c.baseURL != ""
+}
+
 func (c *AIClient) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
 	if c.baseURL == "" {
 		return ChatResponse{}, errors.New("AI base url is empty")
@@ -2,14 +2,20 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// ErrObjectNotFound indicates the requested object is not in the bucket
+var ErrObjectNotFound = errors.New("object not found")
+
 // MinioConfig holds MinIO connection settings
 type MinioConfig struct {
 	Endpoint        string
@@ -87,3 +93,36 @@ func (m *MinioClient) GetSignedURL(ctx context.Context, objectKey string) (strin
 func (m *MinioClient) DeleteFile(ctx context.Context, objectKey string) error {
 	return m.client.RemoveObject(ctx, m.bucketName, objectKey, minio.RemoveObjectOptions{})
 }
+
+// ObjectKeyFromURL returns the object key of a URL signed by this client,
+// such as a submission's FileURL. The signature may have expired; only the
+// /<bucket>/<key> path is used.
+func (m *MinioClient) ObjectKeyFromURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	key, ok := strings.CutPrefix(u.Path, "/"+m.bucketName+"/")
+	if !ok || key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+// OpenObject streams an object from MinIO. It returns ErrObjectNotFound when
+// the object does not exist.
+func (m *MinioClient) OpenObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	obj, err := m.client.GetObject(ctx, m.bucketName, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat surfaces a missing object before any bytes are read.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFeedbackRunes caps the feedback or comment excerpt included in a notification
+const maxFeedbackRunes = 200
+
+// Notifier renders user-facing notifications and delivers them via WeChat Work
+type Notifier struct {
+	wecom *WecomClient
+}
+
+// NewNotifier creates a Notifier backed by the given WeChat Work client
+func NewNotifier(wecom *WecomClient) *Notifier {
+	return &Notifier{wecom: wecom}
+}
+
+// Enabled returns true if notifications can be delivered
+func (n *Notifier) Enabled() bool {
+	return n != nil && n.wecom != nil && n.wecom.IsConfigured()
+}
+
+// NotifyGrade tells a student their assignment has been graded
+func (n *Notifier) NotifyGrade(ctx context.Context, studentWecomID, assignmentTitle string, grade int, feedback string) error {
+	if !n.Enabled() {
+		return errors.New("wecom not configured")
+	}
+	return n.wecom.SendTextMessage(ctx, studentWecomID, renderGradeMessage(assignmentTitle, grade, feedback))
+}
+
+// NotifySubmissionComment tells the other side of a submission discussion
+// that a new comment was posted
+func (n *Notifier) NotifySubmissionComment(ctx context.Context, recipientWecomID, assignmentTitle, authorName, body string) error {
+	if !n.Enabled() {
+		return errors.New("wecom not configured")
+	}
+	return n.wecom.SendTextMessage(ctx, recipientWecomID, renderCommentMessage(assignmentTitle, authorName, body))
+}
+
+func renderCommentMessage(assignmentTitle, authorName, body string) string {
+	var b strings.Builder
+	b.WriteString("作业有新留言\n")
+	fmt.Fprintf(&b, "作业：%s\n", assignmentTitle)
+	fmt.Fprintf(&b, "%s：%s", authorName, truncateRunes(strings.TrimSpace(body), maxFeedbackRunes))
+	return b.String()
+}
+
+func renderGradeMessage(assignmentTitle string, grade int, feedback string) string {
+	var b strings.Builder
+	b.WriteString("作业已批改\n")
+	fmt.Fprintf(&b, "作业：%s\n", assignmentTitle)
+	fmt.Fprintf(&b, "成绩：%d", grade)
+	feedback = strings.TrimSpace(feedback)
+	if feedback != "" {
+		fmt.Fprintf(&b, "\n评语：%s", truncateRunes(feedback, maxFeedbackRunes))
+	}
+	return b.String()
+}
+
+// truncateRunes shortens s to max runes, marking the cut with an ellipsis
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max]) + "…"
+}
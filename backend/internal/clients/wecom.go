@@ -1,12 +1,14 @@
 package clients
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -341,3 +343,73 @@ func (c *WecomClient) GetJSAPITicket(ctx context.Context) (string, error) {
 
 	return jsapiTicket, nil
 }
+
+// messageSendResponse is the response from message/send API
+type messageSendResponse struct {
+	ErrCode      int    `json:"errcode"`
+	ErrMsg       string `json:"errmsg"`
+	InvalidUser  string `json:"invaliduser"`
+	InvalidParty string `json:"invalidparty"`
+}
+
+// SendTextMessage sends an application text message to a single user
+func (c *WecomClient) SendTextMessage(ctx context.Context, toUser, content string) error {
+	if toUser == "" {
+		return errors.New("toUser is required")
+	}
+	agentID, err := strconv.Atoi(c.agentID)
+	if err != nil {
+		return fmt.Errorf("invalid agent id: %w", err)
+	}
+
+	accessToken, err := c.GetAccessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("get access token: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"touser":  toUser,
+		"msgtype": "text",
+		"agentid": agentID,
+		"text":    map[string]string{"content": content},
+	})
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+
+	url := fmt.Sprintf(
+		"https://qyapi.weixin.qq.com/cgi-bin/message/send?access_token=%s",
+		accessToken,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	var result messageSendResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+
+	if result.ErrCode != 0 {
+		return fmt.Errorf("wecom error %d: %s", result.ErrCode, result.ErrMsg)
+	}
+	if result.InvalidUser != "" {
+		return fmt.Errorf("wecom invalid user: %s", result.InvalidUser)
+	}
+
+	return nil
+}
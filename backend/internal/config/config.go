@@ -1,21 +1,84 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	HTTPAddr    string
-	JWTSecret   string
-	SecretsDir  string
-	CorsOrigins []string
+	// AppEnv is "production" (the default) or "development". An insecure JWT
+	// secret stops a production server but only logs a warning in development.
+	AppEnv string
+	// Warnings are problems Load tolerated, to be logged loudly at startup.
+	Warnings []string
+
+	HTTPAddr  string
+	JWTSecret string
+	// JWTIssuer and JWTAudience are stamped into tokens and required on parse,
+	// so tokens minted for another environment are rejected. Empty skips the check.
+	JWTIssuer   string
+	JWTAudience string
+	// ReceiptSecret signs submission receipts; empty reuses JWTSecret.
+	// Set it so rotating the JWT secret does not void receipts students hold.
+	ReceiptSecret string
+	// PasswordHashCost is the bcrypt cost for new password hashes; zero keeps
+	// the library default. Older hashes are upgraded on login.
+	PasswordHashCost int
+	SecretsDir       string
+	CorsOrigins      []string
 
 	DBDsn string
+	// Connection pool; zero values fall back to db.DefaultPool.
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	// RequestTimeout bounds ordinary API requests; AIRequestTimeout bounds
+	// AI, simulation and upload routes. Zero disables the deadline.
+	RequestTimeout   time.Duration
+	AIRequestTimeout time.Duration
+
+	// MaxBodyBytes caps request bodies; writing submissions and uploads get
+	// the larger MaxWritingBodyBytes and MaxUploadBodyBytes. Zero disables a cap.
+	MaxBodyBytes        int64
+	MaxWritingBodyBytes int64
+	MaxUploadBodyBytes  int64
+
+	// StudySessionTimeout is how long without chapter heartbeats ends a study
+	// session; zero keeps the default.
+	StudySessionTimeout time.Duration
 
+	// JobWorkers and JobQueueSize size the background task pool.
+	JobWorkers   int
+	JobQueueSize int
+
+	// SeedDemoUsers creates the demo accounts on startup; off by default.
+	// DemoPasswords maps a role to a password overriding the documented default.
+	SeedDemoUsers bool
+	DemoPasswords map[string]string
+
+	// AIBaseURL and SimBaseURL locate the AI and simulation services; empty
+	// disables the service.
 	AIBaseURL  string
 	SimBaseURL string
 
+	// AIAllowedModes lists, per role, the chat modes that may be forwarded to
+	// the AI service; "*" allows any mode.
+	AIAllowedModes map[string][]string
+
+	// DefaultCourseModules are enabled on new courses that do not specify any.
+	DefaultCourseModules []string
+
+	// MaxPinnedAnnouncements caps how many announcements a course may pin.
+	MaxPinnedAnnouncements int
+
+	// FeatureFlags overrides the defaults of server feature flags: each flag
+	// maps to the roles it is on for, "*" for everyone, or none when off.
+	FeatureFlags map[string][]string
+
 	// WeChat Work (企业微信) configuration
 	WecomCorpID  string
 	WecomAgentID string
@@ -27,56 +90,154 @@ type Config struct {
 	MinioSecretKey       string
 	MinioBucket          string
 	MinioUseSSL          bool
-	MinioSignedURLExpiry string
+	MinioSignedURLExpiry time.Duration
 }
 
-func Load() Config {
+// Values of APP_ENV.
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+)
+
+// DefaultStudentAIModes is the chat modes students may use unless
+// AI_MODES_STUDENT overrides it; heavier grading modes are left out.
+const DefaultStudentAIModes = "tutor,sim_explain,sim_tutor,formula_verify,problem_solver,polish"
+
+// Load reads the configuration from the environment. Every malformed or
+// missing value is collected, and if there are any the returned error is an
+// *InvalidError listing all of them, so startup can fail before serving.
+func Load() (Config, error) {
+	e := &env{}
+	appEnv := strings.ToLower(strings.TrimSpace(getenv("APP_ENV", "")))
+	if appEnv == "" {
+		appEnv = EnvProduction
+	}
 	httpAddr := getenv("HTTP_ADDR", "0.0.0.0:8080")
 	secretsDir := strings.TrimSpace(getenv("SECRETS_DIR", ""))
-	jwtSecret := getenv("JWT_SECRET", "change_me_in_prod")
+	jwtSecret := getenv("JWT_SECRET", "")
 	if secretsDir != "" {
 		if secretFromFile, err := loadJWTSecretFromDir(secretsDir); err == nil && strings.TrimSpace(secretFromFile) != "" {
 			jwtSecret = secretFromFile
 		}
 	}
 
+	jwtIssuer := strings.TrimSpace(getenv("JWT_ISSUER", ""))
+	jwtAudience := strings.TrimSpace(getenv("JWT_AUDIENCE", ""))
+	receiptSecret := strings.TrimSpace(getenv("SUBMISSION_RECEIPT_SECRET", ""))
+	passwordHashCost := e.int("PASSWORD_HASH_COST", 0)
+
 	corsOriginsRaw := strings.TrimSpace(getenv("CORS_ORIGINS", "http://localhost:5173"))
 	corsOrigins := splitComma(corsOriginsRaw)
 	if len(corsOrigins) == 0 {
 		corsOrigins = []string{"http://localhost:5173"}
 	}
 
-	dbDsn := getenv("DB_DSN", "root:root@tcp(127.0.0.1:3306)/emfield?charset=utf8mb4&parseTime=True&loc=Local")
+	dbDsn := strings.TrimSpace(getenv("DB_DSN", ""))
+
+	dbMaxOpenConns := e.int("DB_MAX_OPEN_CONNS", 0)
+	dbMaxIdleConns := e.int("DB_MAX_IDLE_CONNS", 0)
+	dbConnMaxLifetime := e.duration("DB_CONN_MAX_LIFETIME", 0)
+
+	jobWorkers := e.int("JOB_WORKERS", 4)
+	jobQueueSize := e.int("JOB_QUEUE_SIZE", 256)
+
+	seedDemoUsers := e.bool("SEED_DEMO_USERS", false)
+	demoPasswords := map[string]string{}
+	for role, key := range map[string]string{
+		"admin":     "DEMO_ADMIN_PASSWORD",
+		"teacher":   "DEMO_TEACHER_PASSWORD",
+		"assistant": "DEMO_ASSISTANT_PASSWORD",
+		"student":   "DEMO_STUDENT_PASSWORD",
+	} {
+		if v := getenv(key, ""); v != "" {
+			demoPasswords[role] = v
+		}
+	}
 
 	aiBaseURL := strings.TrimRight(getenv("AI_BASE_URL", "http://127.0.0.1:8001"), "/")
 	simBaseURL := strings.TrimRight(getenv("SIM_BASE_URL", "http://127.0.0.1:8002"), "/")
 
+	aiAllowedModes := map[string][]string{}
+	for role, fallback := range map[string]string{
+		"admin":     "*",
+		"teacher":   "*",
+		"assistant": "*",
+		"student":   DefaultStudentAIModes,
+	} {
+		modes := strings.TrimSpace(getenv("AI_MODES_"+strings.ToUpper(role), ""))
+		if modes == "" {
+			modes = fallback
+		}
+		aiAllowedModes[role] = splitComma(modes)
+	}
+
+	requestTimeout := e.duration("REQUEST_TIMEOUT", 15*time.Second)
+	aiRequestTimeout := e.duration("AI_REQUEST_TIMEOUT", 5*time.Minute)
+
+	studySessionTimeout := e.duration("STUDY_SESSION_TIMEOUT", 10*time.Minute)
+
+	maxBodyBytes := int64(e.int("MAX_BODY_BYTES", 1<<20))
+	maxWritingBodyBytes := int64(e.int("MAX_WRITING_BODY_BYTES", 4<<20))
+	maxUploadBodyBytes := int64(e.int("MAX_UPLOAD_BODY_BYTES", 101<<20))
+
+	defaultCourseModules := splitComma(getenv("DEFAULT_COURSE_MODULES", "core.ai,core.analytics"))
+	maxPinnedAnnouncements := e.int("MAX_PINNED_ANNOUNCEMENTS", 3)
+	featureFlags := e.featureFlags("FEATURE_FLAGS")
+
 	// WeChat Work config (optional)
 	wecomCorpID := getenv("WECOM_CORPID", "")
 	wecomAgentID := getenv("WECOM_AGENTID", "")
 	wecomSecret := getenv("WECOM_SECRET", "")
 
 	// MinIO config
-	minioUseSSL := getenv("MINIO_USE_SSL", "false") == "true"
-
-	return Config{
-		HTTPAddr:             httpAddr,
-		JWTSecret:            jwtSecret,
-		SecretsDir:           secretsDir,
-		CorsOrigins:          corsOrigins,
-		DBDsn:                dbDsn,
-		AIBaseURL:            aiBaseURL,
-		SimBaseURL:           simBaseURL,
-		WecomCorpID:          wecomCorpID,
-		WecomAgentID:         wecomAgentID,
-		WecomSecret:          wecomSecret,
-		MinioEndpoint:        getenv("MINIO_ENDPOINT", "localhost:9000"),
-		MinioAccessKey:       getenv("MINIO_ACCESS_KEY", "minioadmin"),
-		MinioSecretKey:       getenv("MINIO_SECRET_KEY", "minioadmin123"),
-		MinioBucket:          getenv("MINIO_BUCKET", "emfield-uploads"),
-		MinioUseSSL:          minioUseSSL,
-		MinioSignedURLExpiry: getenv("MINIO_SIGNED_URL_EXPIRY", "168h"),
+	minioUseSSL := e.bool("MINIO_USE_SSL", false)
+	minioSignedURLExpiry := e.duration("MINIO_SIGNED_URL_EXPIRY", 7*24*time.Hour)
+
+	cfg := Config{
+		AppEnv:                 appEnv,
+		HTTPAddr:               httpAddr,
+		JWTSecret:              jwtSecret,
+		JWTIssuer:              jwtIssuer,
+		JWTAudience:            jwtAudience,
+		ReceiptSecret:          receiptSecret,
+		PasswordHashCost:       passwordHashCost,
+		SecretsDir:             secretsDir,
+		CorsOrigins:            corsOrigins,
+		DBDsn:                  dbDsn,
+		DBMaxOpenConns:         dbMaxOpenConns,
+		DBMaxIdleConns:         dbMaxIdleConns,
+		DBConnMaxLifetime:      dbConnMaxLifetime,
+		RequestTimeout:         requestTimeout,
+		AIRequestTimeout:       aiRequestTimeout,
+		MaxBodyBytes:           maxBodyBytes,
+		StudySessionTimeout:    studySessionTimeout,
+		MaxWritingBodyBytes:    maxWritingBodyBytes,
+		MaxUploadBodyBytes:     maxUploadBodyBytes,
+		JobWorkers:             jobWorkers,
+		JobQueueSize:           jobQueueSize,
+		SeedDemoUsers:          seedDemoUsers,
+		DemoPasswords:          demoPasswords,
+		AIBaseURL:              aiBaseURL,
+		SimBaseURL:             simBaseURL,
+		AIAllowedModes:         aiAllowedModes,
+		DefaultCourseModules:   defaultCourseModules,
+		MaxPinnedAnnouncements: maxPinnedAnnouncements,
+		FeatureFlags:           featureFlags,
+		WecomCorpID:            wecomCorpID,
+		WecomAgentID:           wecomAgentID,
+		WecomSecret:            wecomSecret,
+		MinioEndpoint:          getenv("MINIO_ENDPOINT", "localhost:9000"),
+		MinioAccessKey:         getenv("MINIO_ACCESS_KEY", "minioadmin"),
+		MinioSecretKey:         getenv("MINIO_SECRET_KEY", "minioadmin123"),
+		MinioBucket:            getenv("MINIO_BUCKET", "emfield-uploads"),
+		MinioUseSSL:            minioUseSSL,
+		MinioSignedURLExpiry:   minioSignedURLExpiry,
 	}
+	cfg.validate(e)
+	if len(e.problems) > 0 {
+		return cfg, &InvalidError{Problems: e.problems}
+	}
+	return cfg, nil
 }
 
 func getenv(key, fallback string) string {
@@ -86,6 +247,91 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
+// env parses typed settings, recording a problem for each value that is set
+// but malformed rather than quietly using the fallback.
+type env struct {
+	problems []string
+}
+
+func (e *env) problemf(format string, args ...interface{}) {
+	e.problems = append(e.problems, fmt.Sprintf(format, args...))
+}
+
+// duration parses a Go duration (e.g. "30s"); a missing value uses fallback.
+func (e *env) duration(key string, fallback time.Duration) time.Duration {
+	v := strings.TrimSpace(getenv(key, ""))
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.problemf("%s: %q is not a duration (use e.g. 30s, 5m, 168h)", key, v)
+		return fallback
+	}
+	return d
+}
+
+// int parses an integer; a missing value uses fallback.
+func (e *env) int(key string, fallback int) int {
+	v := strings.TrimSpace(getenv(key, ""))
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.problemf("%s: %q is not an integer", key, v)
+		return fallback
+	}
+	return n
+}
+
+// bool parses true/false (or 1/0); a missing value uses fallback.
+func (e *env) bool(key string, fallback bool) bool {
+	v := strings.TrimSpace(getenv(key, ""))
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.problemf("%s: %q is not a boolean (use true or false)", key, v)
+		return fallback
+	}
+	return b
+}
+
+// featureFlags parses a comma-separated list of "flag" (on for everyone),
+// "flag=off", or "flag=role|role" (on for those roles only).
+func (e *env) featureFlags(key string) map[string][]string {
+	flags := map[string][]string{}
+	for _, entry := range splitComma(getenv(key, "")) {
+		name, roles, scoped := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			e.problemf("%s: %q has no flag name", key, entry)
+			continue
+		}
+		switch roles = strings.TrimSpace(roles); {
+		case !scoped || roles == "on":
+			flags[name] = []string{"*"}
+		case roles == "off":
+			flags[name] = []string{}
+		default:
+			flags[name] = nil
+			for _, role := range strings.Split(roles, "|") {
+				role = strings.TrimSpace(role)
+				if !knownRoles[role] {
+					e.problemf("%s: %q is not a role (use admin, teacher, assistant or student)", key, role)
+					continue
+				}
+				flags[name] = append(flags[name], role)
+			}
+		}
+	}
+	return flags
+}
+
+var knownRoles = map[string]bool{"admin": true, "teacher": true, "assistant": true, "student": true}
+
 func splitComma(raw string) []string {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
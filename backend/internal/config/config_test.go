@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setValidEnv(t *testing.T) {
+	t.Setenv("DB_DSN", "emfield:s3cret-pass@tcp(mysql:3306)/emfield?parseTime=True")
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	t.Setenv("SECRETS_DIR", "")
+	t.Setenv("APP_ENV", "")
+}
+
+func TestLoad_Valid(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("MINIO_SIGNED_URL_EXPIRY", "24h")
+	t.Setenv("SEED_DEMO_USERS", "1")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, cfg.MinioSignedURLExpiry)
+	assert.True(t, cfg.SeedDemoUsers)
+	assert.Equal(t, 15*time.Second, cfg.RequestTimeout)
+	assert.Equal(t, int64(1<<20), cfg.MaxBodyBytes)
+
+	// Empty base URLs disable the AI and simulation services.
+	t.Setenv("AI_BASE_URL", "")
+	t.Setenv("SIM_BASE_URL", "")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.AIBaseURL)
+	assert.Empty(t, cfg.SimBaseURL)
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("DB_DSN", "")
+	t.Setenv("JWT_SECRET", "change_me_in_prod")
+	t.Setenv("REQUEST_TIMEOUT", "15")
+	t.Setenv("MINIO_USE_SSL", "yes")
+	t.Setenv("JOB_WORKERS", "four")
+	t.Setenv("MINIO_SIGNED_URL_EXPIRY", "720h")
+
+	_, err := Load()
+	var invalid *InvalidError
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Len(t, invalid.Problems, 6)
+		for _, key := range []string{"DB_DSN", "JWT_SECRET", "REQUEST_TIMEOUT", "MINIO_USE_SSL", "JOB_WORKERS", "MINIO_SIGNED_URL_EXPIRY"} {
+			assert.Contains(t, err.Error(), key+":")
+		}
+	}
+
+	t.Setenv("DB_DSN", "sqlite:emfield.db")
+	t.Setenv("JWT_SECRET", "short")
+	t.Setenv("REQUEST_TIMEOUT", "")
+	t.Setenv("MINIO_USE_SSL", "")
+	t.Setenv("JOB_WORKERS", "")
+	t.Setenv("MINIO_SIGNED_URL_EXPIRY", "")
+	_, err = Load()
+	assert.ErrorContains(t, err, "JWT_SECRET: must be at least 16 characters")
+}
+
+func TestConfig_LogValueRedactsSecrets(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("MINIO_SECRET_KEY", "minio-private")
+	cfg, err := Load()
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("configuration loaded", slog.Any("config", cfg))
+	out := buf.String()
+	assert.NotContains(t, out, "s3cret-pass")
+	assert.NotContains(t, out, cfg.JWTSecret)
+	assert.NotContains(t, out, "minio-private")
+	assert.Contains(t, out, `"db_dsn":"emfield:***@tcp(mysql:3306)/emfield?parseTime=True"`)
+	assert.Contains(t, out, `"minio_signed_url_expiry"`)
+}
+
+func TestLoad_WeakJWTSecretOnlyWarnsInDevelopment(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("JWT_SECRET", "emfield-jwt-secret-key-2024-graduation-design")
+
+	_, err := Load()
+	assert.ErrorContains(t, err, "JWT_SECRET: is a published demo value")
+
+	t.Setenv("APP_ENV", "development")
+	cfg, err := Load()
+	assert.NoError(t, err)
+	if assert.Len(t, cfg.Warnings, 1) {
+		assert.Contains(t, cfg.Warnings[0], "JWT_SECRET")
+	}
+
+	t.Setenv("APP_ENV", "staging")
+	_, err = Load()
+	assert.ErrorContains(t, err, "APP_ENV")
+}
+
+func TestLoad_FeatureFlags(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("FEATURE_FLAGS", "ai_grading=off, peer_review=teacher|admin,beta_ui")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"ai_grading":  {},
+		"peer_review": {"teacher", "admin"},
+		"beta_ui":     {"*"},
+	}, cfg.FeatureFlags)
+
+	t.Setenv("FEATURE_FLAGS", "peer_review=teachers")
+	_, err = Load()
+	assert.ErrorContains(t, err, `FEATURE_FLAGS: "teachers" is not a role`)
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// minJWTSecretLength is the shortest JWT secret accepted at startup.
+const minJWTSecretLength = 16
+
+// maxSignedURLExpiry is the longest lifetime S3-compatible stores accept for
+// a presigned URL.
+const maxSignedURLExpiry = 7 * 24 * time.Hour
+
+// demoSecrets are JWT secrets published in this repository's env files,
+// scripts and docs. Anyone can forge tokens signed with them.
+var demoSecrets = map[string]bool{
+	"change_me_in_prod":                             true,
+	"dev-secret":                                    true,
+	"dev-jwt-secret-not-for-prod":                   true,
+	"local-dev-jwt-secret-not-for-prod":             true,
+	"emfield-jwt-secret-key-2024-graduation-design": true,
+	"secret":      true,
+	"test-secret": true,
+}
+
+// InvalidError lists every problem found while loading the configuration.
+type InvalidError struct {
+	Problems []string
+}
+
+func (e *InvalidError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// validate records the problems with values that parsed but are unusable,
+// and the tolerated ones in c.Warnings.
+func (c *Config) validate(e *env) {
+	if c.AppEnv != EnvProduction && c.AppEnv != EnvDevelopment {
+		e.problemf("APP_ENV: %q must be %s or %s", c.AppEnv, EnvProduction, EnvDevelopment)
+	}
+	if strings.TrimSpace(c.HTTPAddr) == "" {
+		e.problemf("HTTP_ADDR: must not be empty")
+	}
+	if c.DBDsn == "" {
+		e.problemf("DB_DSN: is required")
+	}
+	if issue := jwtSecretIssue(c.JWTSecret); issue != "" {
+		if c.AppEnv == EnvDevelopment {
+			c.Warnings = append(c.Warnings, "JWT_SECRET: "+issue+"; tokens can be forged, never deploy this")
+		} else {
+			e.problemf("JWT_SECRET: %s (set APP_ENV=development to run anyway)", issue)
+		}
+	}
+	if c.ReceiptSecret != "" && len(c.ReceiptSecret) < minJWTSecretLength {
+		e.problemf("SUBMISSION_RECEIPT_SECRET: must be at least %d characters", minJWTSecretLength)
+	}
+
+	for key, n := range map[string]int{
+		"DB_MAX_OPEN_CONNS":        c.DBMaxOpenConns,
+		"DB_MAX_IDLE_CONNS":        c.DBMaxIdleConns,
+		"JOB_QUEUE_SIZE":           c.JobQueueSize,
+		"MAX_PINNED_ANNOUNCEMENTS": c.MaxPinnedAnnouncements,
+	} {
+		if n < 0 {
+			e.problemf("%s: must not be negative", key)
+		}
+	}
+	for key, n := range map[string]int64{
+		"MAX_BODY_BYTES":         c.MaxBodyBytes,
+		"MAX_WRITING_BODY_BYTES": c.MaxWritingBodyBytes,
+		"MAX_UPLOAD_BODY_BYTES":  c.MaxUploadBodyBytes,
+	} {
+		if n < 0 {
+			e.problemf("%s: must not be negative", key)
+		}
+	}
+	if c.JobWorkers < 1 {
+		e.problemf("JOB_WORKERS: must be at least 1")
+	}
+	for key, d := range map[string]time.Duration{
+		"DB_CONN_MAX_LIFETIME":  c.DBConnMaxLifetime,
+		"REQUEST_TIMEOUT":       c.RequestTimeout,
+		"AI_REQUEST_TIMEOUT":    c.AIRequestTimeout,
+		"STUDY_SESSION_TIMEOUT": c.StudySessionTimeout,
+	} {
+		if d < 0 {
+			e.problemf("%s: must not be negative", key)
+		}
+	}
+	if c.MinioSignedURLExpiry < time.Second || c.MinioSignedURLExpiry > maxSignedURLExpiry {
+		e.problemf("MINIO_SIGNED_URL_EXPIRY: must be between 1s and %s", maxSignedURLExpiry)
+	}
+
+	// An empty base URL turns the service off rather than misconfiguring it.
+	for key, raw := range map[string]string{
+		"AI_BASE_URL":  c.AIBaseURL,
+		"SIM_BASE_URL": c.SimBaseURL,
+	} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			e.problemf("%s: %q is not an http(s) URL", key, raw)
+		}
+	}
+	// Maps are iterated in random order; keep the summary stable.
+	sort.Strings(e.problems)
+}
+
+// jwtSecretIssue describes why secret is unsafe to sign tokens with, or
+// returns "" when it is acceptable.
+func jwtSecretIssue(secret string) string {
+	secret = strings.TrimSpace(secret)
+	switch {
+	case secret == "":
+		return "is not set (set it or put jwt.key in SECRETS_DIR)"
+	case demoSecrets[secret]:
+		return "is a published demo value; generate one with e.g. openssl rand -hex 32"
+	case len(secret) < minJWTSecretLength:
+		return fmt.Sprintf("must be at least %d characters", minJWTSecretLength)
+	}
+	return ""
+}
+
+// LogValue reports the effective configuration with secrets redacted, so the
+// whole Config can be logged at startup.
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("app_env", c.AppEnv),
+		slog.String("http_addr", c.HTTPAddr),
+		slog.String("jwt_secret", redact(c.JWTSecret)),
+		slog.String("jwt_issuer", c.JWTIssuer),
+		slog.String("jwt_audience", c.JWTAudience),
+		slog.String("receipt_secret", redact(c.ReceiptSecret)),
+		slog.Int("password_hash_cost", c.PasswordHashCost),
+		slog.String("secrets_dir", c.SecretsDir),
+		slog.Any("cors_origins", c.CorsOrigins),
+		slog.String("db_dsn", redactDSN(c.DBDsn)),
+		slog.Int("db_max_open_conns", c.DBMaxOpenConns),
+		slog.Int("db_max_idle_conns", c.DBMaxIdleConns),
+		slog.Duration("db_conn_max_lifetime", c.DBConnMaxLifetime),
+		slog.Duration("request_timeout", c.RequestTimeout),
+		slog.Duration("ai_request_timeout", c.AIRequestTimeout),
+		slog.Int64("max_body_bytes", c.MaxBodyBytes),
+		slog.Int64("max_writing_body_bytes", c.MaxWritingBodyBytes),
+		slog.Int64("max_upload_body_bytes", c.MaxUploadBodyBytes),
+		slog.Duration("study_session_timeout", c.StudySessionTimeout),
+		slog.Int("job_workers", c.JobWorkers),
+		slog.Int("job_queue_size", c.JobQueueSize),
+		slog.Bool("seed_demo_users", c.SeedDemoUsers),
+		slog.Any("demo_password_overrides", sortedKeys(c.DemoPasswords)),
+		slog.String("ai_base_url", c.AIBaseURL),
+		slog.String("sim_base_url", c.SimBaseURL),
+		slog.Any("ai_allowed_modes", c.AIAllowedModes),
+		slog.Any("default_course_modules", c.DefaultCourseModules),
+		slog.Int("max_pinned_announcements", c.MaxPinnedAnnouncements),
+		slog.Any("feature_flags", c.FeatureFlags),
+		slog.String("wecom_corp_id", c.WecomCorpID),
+		slog.String("wecom_agent_id", c.WecomAgentID),
+		slog.String("wecom_secret", redact(c.WecomSecret)),
+		slog.String("minio_endpoint", c.MinioEndpoint),
+		slog.String("minio_access_key", c.MinioAccessKey),
+		slog.String("minio_secret_key", redact(c.MinioSecretKey)),
+		slog.String("minio_bucket", c.MinioBucket),
+		slog.Bool("minio_use_ssl", c.MinioUseSSL),
+		slog.Duration("minio_signed_url_expiry", c.MinioSignedURLExpiry),
+	)
+}
+
+// redact hides a secret while still showing whether it is set.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "***"
+}
+
+// redactDSN hides the password of a "user:password@..." DSN.
+func redactDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + "***" + dsn[at:]
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+// Package csvutil reads CSV imports by header name and writes CSV exports
+// that spreadsheet apps open correctly, Chinese text included.
+//
+// Imports map each column to a key through the header texts it may appear
+// under, so a file headed "题目,答案" reads the same as "content,answer".
+// Problems confined to one row come back as RowErrors alongside the rows
+// that parsed, letting an importer report every bad line at once.
+package csvutil
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// utf8BOM is the byte order mark Excel writes at the start of UTF-8 CSV.
+const utf8BOM = "\uFEFF"
+
+var (
+	// ErrNoHeader indicates a file without a header row.
+	ErrNoHeader = errors.New("missing header row")
+	// ErrMissingColumn indicates the header lacks a required column.
+	ErrMissingColumn = errors.New("missing column")
+	// ErrValueRequired indicates a blank value in a required column.
+	ErrValueRequired = errors.New("value required")
+)
+
+// Column is a column an import reads.
+type Column struct {
+	// Key is the name rows are read by.
+	Key string
+	// Headers lists the header texts the column may appear under, compared
+	// ignoring case and surrounding space. The key itself is always accepted.
+	Headers []string
+	// Required columns must be in the header and non-blank in every row.
+	Required bool
+}
+
+// Row is one data row, its values trimmed and keyed by column.
+type Row struct {
+	// Line is the row's line number in the file, counting the header as 1.
+	Line   int
+	values map[string]string
+}
+
+// Get returns the row's value for the column key, or "" when the file has
+// no such column.
+func (r Row) Get(key string) string {
+	return r.values[key]
+}
+
+// Errorf returns a RowError for the row's value in column.
+func (r Row) Errorf(column, format string, args ...any) *RowError {
+	return &RowError{Line: r.Line, Column: column, Err: fmt.Errorf(format, args...)}
+}
+
+// RowError reports a problem with one row, and the column when it concerns
+// one value.
+type RowError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Read parses a CSV file whose first row is a header, skipping a leading
+// byte order mark, blank lines and headers matching no column. Malformed rows
+// and blank required values are returned as RowErrors and their rows left
+// out. The error is non-nil only when the file as a whole is unusable: no
+// header, a required column missing (wrapping ErrMissingColumn) or a failed
+// read.
+func Read(r io.Reader, columns []Column) ([]Row, []*RowError, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, ErrNoHeader
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	index, err := mapHeader(header, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := []Row{}
+	rowErrs := []*RowError{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrs = append(rowErrs, &RowError{Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if blankRecord(record) {
+			continue
+		}
+
+		row := Row{Line: line, values: make(map[string]string, len(columns))}
+		var missing *RowError
+		for _, col := range columns {
+			i, ok := index[col.Key]
+			if !ok {
+				continue
+			}
+			var value string
+			if i < len(record) {
+				value = strings.TrimSpace(record[i])
+			}
+			if value == "" && col.Required && missing == nil {
+				missing = &RowError{Line: line, Column: col.Key, Err: ErrValueRequired}
+			}
+			row.values[col.Key] = value
+		}
+		if missing != nil {
+			rowErrs = append(rowErrs, missing)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrs, nil
+}
+
+// mapHeader returns the position of each column found in header. Where a
+// column appears under several headers the first wins.
+func mapHeader(header []string, columns []Column) (map[string]int, error) {
+	index := make(map[string]int, len(columns))
+	for i, text := range header {
+		text = strings.TrimSpace(text)
+		for _, col := range columns {
+			if _, ok := index[col.Key]; ok || !col.matches(text) {
+				continue
+			}
+			index[col.Key] = i
+			break
+		}
+	}
+	for _, col := range columns {
+		if _, ok := index[col.Key]; col.Required && !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, col.Key)
+		}
+	}
+	return index, nil
+}
+
+func (col Column) matches(header string) bool {
+	if strings.EqualFold(header, col.Key) {
+		return true
+	}
+	for _, h := range col.Headers {
+		if strings.EqualFold(header, strings.TrimSpace(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+func blankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
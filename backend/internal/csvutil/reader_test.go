@@ -0,0 +1,70 @@
+package csvutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var studentColumns = []Column{
+	{Key: "username", Headers: []string{"学号"}, Required: true},
+	{Key: "name", Headers: []string{"姓名"}, Required: true},
+	{Key: "note", Headers: []string{"备注"}},
+}
+
+func TestRead_MapsChineseHeaders(t *testing.T) {
+	input := "\uFEFF 姓名 ,学号,班级\n" +
+		"张三,2023001,电磁一班\n" +
+		"\n" +
+		"\"李四, 小李\",2023002,电磁二班\n"
+	rows, rowErrs, err := Read(strings.NewReader(input), studentColumns)
+	assert.NoError(t, err)
+	assert.Empty(t, rowErrs)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, 2, rows[0].Line)
+		assert.Equal(t, "2023001", rows[0].Get("username"))
+		assert.Equal(t, "张三", rows[0].Get("name"))
+		assert.Equal(t, "", rows[0].Get("note"), "column not in the file")
+		assert.Equal(t, 4, rows[1].Line)
+		assert.Equal(t, "李四, 小李", rows[1].Get("name"))
+	}
+}
+
+func TestRead_ReportsRowErrors(t *testing.T) {
+	input := "username,name,备注\n" +
+		"2023001,张三,\n" +
+		"2023002,,缺少姓名\n" +
+		"2023003\n" +
+		"2023004,王\"五,引号\n" +
+		"2023005,赵六,好\n"
+	rows, rowErrs, err := Read(strings.NewReader(input), studentColumns)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "2023001", rows[0].Get("username"))
+		assert.Equal(t, "好", rows[1].Get("note"))
+		assert.Equal(t, 6, rows[1].Line)
+	}
+	if assert.Len(t, rowErrs, 3) {
+		assert.Equal(t, 3, rowErrs[0].Line)
+		assert.Equal(t, "name", rowErrs[0].Column)
+		assert.ErrorIs(t, rowErrs[0], ErrValueRequired)
+		assert.Equal(t, "line 3: name: value required", rowErrs[0].Error())
+		assert.Equal(t, 4, rowErrs[1].Line, "short row")
+		assert.Equal(t, 5, rowErrs[2].Line, "bare quote")
+		assert.Empty(t, rowErrs[2].Column)
+	}
+
+	row := rows[0]
+	assert.Equal(t, "line 2: note: too short", row.Errorf("note", "too short").Error())
+}
+
+func TestRead_FileErrors(t *testing.T) {
+	_, _, err := Read(strings.NewReader(""), studentColumns)
+	assert.ErrorIs(t, err, ErrNoHeader)
+
+	_, _, err = Read(strings.NewReader("姓名,备注\n张三,\n"), studentColumns)
+	assert.True(t, errors.Is(err, ErrMissingColumn))
+	assert.EqualError(t, err, "missing column: username")
+}
@@ -0,0 +1,53 @@
+package csvutil
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// Writer writes a CSV export. The file starts with a UTF-8 byte order mark so
+// Excel detects the encoding instead of garbling Chinese text, and lines end
+// in CRLF as RFC 4180 has them. Fields are quoted only when they contain a
+// comma, quote, line break or leading space.
+type Writer struct {
+	w       io.Writer
+	csv     *csv.Writer
+	started bool
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return &Writer{w: w, csv: cw}
+}
+
+// Write writes one record, preceded by the byte order mark on the first call.
+// Pass user-supplied text through Safe first. Writes are buffered; call
+// Flush when done.
+func (w *Writer) Write(record []string) error {
+	if !w.started {
+		w.started = true
+		if _, err := io.WriteString(w.w, utf8BOM); err != nil {
+			return err
+		}
+	}
+	return w.csv.Write(record)
+}
+
+// Flush writes any buffered records and returns the first error any write
+// hit.
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// Safe stops spreadsheet apps from evaluating user-supplied text as a formula
+// by prefixing a quote to values that start with a formula character.
+func Safe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,56 @@
+package csvutil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter_BOMAndQuoting(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.NoError(t, w.Write([]string{"学号", "姓名", "备注"}))
+	assert.NoError(t, w.Write([]string{"2023001", "张三", "电磁场, \"期中\""}))
+	assert.NoError(t, w.Write([]string{"2023002", Safe("=SUM(A1)"), Safe("第一行\n第二行")}))
+	assert.NoError(t, w.Flush())
+
+	want := "\uFEFF学号,姓名,备注\r\n" +
+		"2023001,张三,\"电磁场, \"\"期中\"\"\"\r\n" +
+		"2023002,'=SUM(A1),\"第一行\r\n第二行\"\r\n"
+	assert.Equal(t, want, buf.String())
+
+	// What the writer writes, the reader reads back.
+	rows, rowErrs, err := Read(strings.NewReader(buf.String()), []Column{
+		{Key: "username", Headers: []string{"学号"}, Required: true},
+		{Key: "note", Headers: []string{"备注"}},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, rowErrs)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "电磁场, \"期中\"", rows[0].Get("note"))
+		assert.Equal(t, "第一行\n第二行", rows[1].Get("note"))
+	}
+}
+
+func TestWriter_NoRecordsWritesNothing(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, NewWriter(&buf).Flush())
+	assert.Empty(t, buf.String())
+}
+
+func TestSafe(t *testing.T) {
+	for in, want := range map[string]string{
+		"":         "",
+		"张三":       "张三",
+		"=1+1":     "'=1+1",
+		"+86":      "'+86",
+		"-x":       "'-x",
+		"@cmd":     "'@cmd",
+		"a=b":      "a=b",
+		"\tindent": "'\tindent",
+	} {
+		assert.Equal(t, want, Safe(in), in)
+	}
+}
@@ -1,21 +1,38 @@
 package db
 
 import (
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/glebarez/sqlite"
+	applog "github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// PoolConfig sets the MySQL connection pool. Zero fields use DefaultPool.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DefaultPool suits a single backend instance against MySQL's default
+// max_connections (151), leaving headroom for other clients.
+var DefaultPool = PoolConfig{
+	MaxOpenConns:    50,
+	MaxIdleConns:    25,
+	ConnMaxLifetime: 5 * time.Minute,
+}
+
 // Open opens a database connection.
 // DSN format:
 //   - MySQL: "user:pass@tcp(host:port)/dbname?charset=utf8mb4&parseTime=True"
 //   - SQLite: "sqlite:path/to/db.sqlite" or "file:path/to/db.sqlite"
-func Open(dsn string) (*gorm.DB, error) {
+func Open(dsn string, pool PoolConfig) (*gorm.DB, error) {
 	var dialector gorm.Dialector
 
 	// Check if DSN is for SQLite
@@ -43,27 +60,73 @@ func Open(dsn string) (*gorm.DB, error) {
 
 	// Connection pool settings (only applicable for MySQL, SQLite is single-connection)
 	if !strings.HasPrefix(dsn, "sqlite:") && !strings.HasPrefix(dsn, "file:") {
-		sqlDB.SetConnMaxLifetime(5 * time.Minute)
-		sqlDB.SetMaxOpenConns(20)
-		sqlDB.SetMaxIdleConns(10)
+		if pool.MaxOpenConns <= 0 {
+			pool.MaxOpenConns = DefaultPool.MaxOpenConns
+		}
+		if pool.MaxIdleConns <= 0 {
+			pool.MaxIdleConns = DefaultPool.MaxIdleConns
+		}
+		if pool.MaxIdleConns > pool.MaxOpenConns {
+			pool.MaxIdleConns = pool.MaxOpenConns
+		}
+		if pool.ConnMaxLifetime <= 0 {
+			pool.ConnMaxLifetime = DefaultPool.ConnMaxLifetime
+		}
+		sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+		sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+		applog.Log.Info("db connection pool configured",
+			slog.Int("max_open_conns", pool.MaxOpenConns),
+			slog.Int("max_idle_conns", pool.MaxIdleConns),
+			slog.Duration("conn_max_lifetime", pool.ConnMaxLifetime),
+		)
 	}
 
 	return gormDB, nil
 }
 
 func AutoMigrate(gormDB *gorm.DB) error {
+	if err := dropStaleIndexes(gormDB); err != nil {
+		return err
+	}
+	// Assignments predating drafts were all visible to students; keep them
+	// so when the is_published column is first added.
+	m := gormDB.Migrator()
+	publishExisting := m.HasTable(&models.Assignment{}) && !m.HasColumn(&models.Assignment{}, "IsPublished")
+	if err := migrateModels(gormDB); err != nil {
+		return err
+	}
+	if publishExisting {
+		if err := gormDB.Model(&models.Assignment{}).Where("1 = 1").UpdateColumn("is_published", true).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateModels(gormDB *gorm.DB) error {
 	return gormDB.AutoMigrate(
 		&models.User{},
+		&models.SessionRevocation{},
+		&models.AuditLog{},
 		&models.Course{},
 		&models.CourseEnrollment{},
 		&models.Chapter{},
 		&models.ChapterProgress{},
 		&models.Assignment{},
+		&models.AssignmentAttachment{},
+		&models.AssignmentExtension{},
 		&models.Submission{},
+		&models.SubmissionComment{},
+		&models.StudentGroup{},
+		&models.StudentGroupMember{},
+		&models.SimilarityReport{},
 		&models.Resource{},
 		&models.Quiz{},
 		&models.Question{},
 		&models.QuizAttempt{},
+		&models.QuizAttemptGrant{},
+		&models.QuizFeedback{},
 		// New models for announcements and attendance
 		&models.Announcement{},
 		&models.AnnouncementRead{},
@@ -75,5 +138,42 @@ func AutoMigrate(gormDB *gorm.DB) error {
 		&models.StudentGlobalProfile{},
 		&models.LearningEvent{},
 		&models.WritingSubmission{},
+		&models.ChatTranscript{},
+		&models.ChatTranscriptMessage{},
 	)
 }
+
+// dropStaleIndexes drops indexes whose columns have changed since they were
+// created, so AutoMigrate, which only adds missing indexes, recreates them
+// as the models now define them.
+func dropStaleIndexes(gormDB *gorm.DB) error {
+	m := gormDB.Migrator()
+	stale := []struct {
+		model   interface{}
+		name    string
+		columns int
+	}{
+		// idx_session_student once covered student_id alone, allowing one
+		// check-in per student across all sessions.
+		{&models.AttendanceRecord{}, "idx_session_student", 2},
+	}
+	for _, s := range stale {
+		if !m.HasTable(s.model) {
+			continue
+		}
+		indexes, err := m.GetIndexes(s.model)
+		if err != nil {
+			return err
+		}
+		for _, idx := range indexes {
+			if idx.Name() != s.name || len(idx.Columns()) == s.columns {
+				continue
+			}
+			applog.Log.Info("dropping stale index", slog.String("index", s.name))
+			if err := m.DropIndex(s.model, s.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
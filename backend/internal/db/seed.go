@@ -8,15 +8,12 @@ import (
 	"gorm.io/gorm"
 )
 
-func SeedDemoUsers(gormDB *gorm.DB) (bool, error) {
-	var count int64
-	if err := gormDB.Model(&models.User{}).Count(&count).Error; err != nil {
-		return false, err
-	}
-	if count > 0 {
-		return false, nil
-	}
-
+// SeedDemoUsers creates the demo accounts that do not exist yet, looked up by
+// username, so it is safe to run on every start. Existing accounts are never
+// modified. passwords maps a role to a password that replaces the documented
+// default for every demo account of that role. It returns how many accounts
+// were created.
+func SeedDemoUsers(gormDB *gorm.DB, passwords map[string]string) (int, error) {
 	type seedUser struct {
 		Username string
 		Password string
@@ -41,10 +38,24 @@ func SeedDemoUsers(gormDB *gorm.DB) (bool, error) {
 		{Username: "student5", Password: "student5123", Role: "student", Name: "陈同学"},
 	}
 
+	created := 0
 	for _, u := range users {
-		passwordHash, err := auth.HashPassword(u.Password)
+		var existing models.User
+		err := gormDB.Unscoped().Where("username = ?", u.Username).First(&existing).Error
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return created, err
+		}
+
+		password := u.Password
+		if override := passwords[u.Role]; override != "" {
+			password = override
+		}
+		passwordHash, err := auth.HashPassword(password)
 		if err != nil {
-			return false, err
+			return created, err
 		}
 		if err := gormDB.Create(&models.User{
 			Username:     u.Username,
@@ -55,9 +66,10 @@ func SeedDemoUsers(gormDB *gorm.DB) (bool, error) {
 			if errors.Is(err, gorm.ErrDuplicatedKey) {
 				continue
 			}
-			return false, err
+			return created, err
 		}
+		created++
 	}
 
-	return true, nil
+	return created, nil
 }
@@ -0,0 +1,15 @@
+// Package grading scores quiz answers; it is the single implementation shared by quiz submission, review and previews.
+//
+// Structured question types store JSON in Question.Options and Question.Answer:
+//
+//	ordering: Options ["Coulomb", "Gauss", "Maxwell"]             items as shown to the student
+//	          Answer  ["Gauss", "Coulomb", "Maxwell"]             the items in the correct order
+//	          student ["Coulomb", "Gauss", "Maxwell"]             the items in the student's order
+//	matching: Options ["∇·E=ρ/ε₀", "∇×E=-∂B/∂t", "Gauss", "Faraday"] prompts, then the same number of targets
+//	          Answer  {"∇·E=ρ/ε₀": "Gauss", "∇×E=-∂B/∂t": "Faraday"} prompt -> target
+//	          student {"∇·E=ρ/ε₀": "Faraday", ...}                    prompt -> target
+//
+// Both award partial credit: Points scaled by the share of items in the
+// correct position (ordering) or of correctly matched prompts (matching),
+// rounded down. A student answer may also arrive as a JSON-encoded string.
+package grading
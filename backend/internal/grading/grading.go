@@ -0,0 +1,202 @@
+package grading
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Score returns the points earned by studentAnswer on q. Answers are decoded
+// from JSON, so multiple_choice accepts []interface{} or a JSON-encoded string.
+func Score(q models.Question, studentAnswer interface{}) int {
+	switch q.Type {
+	case "single_choice", "true_false":
+		ans, ok := studentAnswer.(string)
+		if !ok {
+			return 0
+		}
+		if ans == q.Answer {
+			return q.Points
+		}
+
+	case "multiple_choice":
+		var studentAns []string
+		switch v := studentAnswer.(type) {
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					studentAns = append(studentAns, s)
+				}
+			}
+		case string:
+			if err := json.Unmarshal([]byte(v), &studentAns); err != nil {
+				return 0
+			}
+		}
+
+		var correctAns []string
+		if err := json.Unmarshal([]byte(q.Answer), &correctAns); err != nil {
+			return 0
+		}
+
+		sort.Strings(studentAns)
+		sort.Strings(correctAns)
+		if equalStringSlices(studentAns, correctAns) {
+			return q.Points
+		}
+
+	case "fill_blank":
+		ans, ok := studentAnswer.(string)
+		if !ok {
+			return 0
+		}
+		if matchFillBlank(q.Answer, ans, q.MatchRule) {
+			return q.Points
+		}
+
+	case "ordering":
+		correct, ok := DecodeOrdering(q.Answer)
+		if !ok || len(correct) == 0 {
+			return 0
+		}
+		studentAns, ok := DecodeOrdering(studentAnswer)
+		if !ok {
+			return 0
+		}
+		hits := 0
+		for i, item := range correct {
+			if i < len(studentAns) && studentAns[i] == item {
+				hits++
+			}
+		}
+		return q.Points * hits / len(correct)
+
+	case "matching":
+		correct, ok := DecodeMatching(q.Answer)
+		if !ok || len(correct) == 0 {
+			return 0
+		}
+		studentAns, ok := DecodeMatching(studentAnswer)
+		if !ok {
+			return 0
+		}
+		hits := 0
+		for prompt, target := range correct {
+			if studentAns[prompt] == target {
+				hits++
+			}
+		}
+		return q.Points * hits / len(correct)
+	}
+
+	return 0
+}
+
+// DecodeOrdering reads an ordering answer given as a decoded JSON array or a
+// JSON-encoded string.
+func DecodeOrdering(v interface{}) ([]string, bool) {
+	switch val := v.(type) {
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			items = append(items, s)
+		}
+		return items, true
+	case string:
+		var items []string
+		if err := json.Unmarshal([]byte(val), &items); err != nil {
+			return nil, false
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// DecodeMatching reads a matching answer given as a decoded JSON object or a
+// JSON-encoded string.
+func DecodeMatching(v interface{}) (map[string]string, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		pairs := make(map[string]string, len(val))
+		for prompt, target := range val {
+			s, ok := target.(string)
+			if !ok {
+				return nil, false
+			}
+			pairs[prompt] = s
+		}
+		return pairs, true
+	case string:
+		var pairs map[string]string
+		if err := json.Unmarshal([]byte(val), &pairs); err != nil {
+			return nil, false
+		}
+		return pairs, true
+	}
+	return nil, false
+}
+
+func equalStringSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeAnswer canonicalizes free-text answers for the exact_trim and
+// contains match rules:
+//   - NFKC folds full-width letters, digits and punctuation to their
+//     half-width forms ("４" -> "4", "ＡＢ" -> "AB") and the ideographic
+//     space U+3000 to a plain space;
+//   - leading/trailing Unicode whitespace is trimmed;
+//   - case is folded with Unicode rules rather than strings.ToLower.
+//
+// The exact and regex rules intentionally see the raw input.
+func NormalizeAnswer(s string) string {
+	s = norm.NFKC.String(s)
+	s = strings.TrimSpace(s)
+	return cases.Fold().String(s)
+}
+
+func matchFillBlank(answer, studentAns, rule string) bool {
+	var answers []string
+	if err := json.Unmarshal([]byte(answer), &answers); err != nil {
+		answers = []string{answer}
+	}
+
+	for _, ans := range answers {
+		switch rule {
+		case "exact":
+			if studentAns == ans {
+				return true
+			}
+		case "exact_trim":
+			if NormalizeAnswer(studentAns) == NormalizeAnswer(ans) {
+				return true
+			}
+		case "contains":
+			if strings.Contains(NormalizeAnswer(studentAns), NormalizeAnswer(ans)) {
+				return true
+			}
+		case "regex":
+			if matched, err := regexp.MatchString(ans, studentAns); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package grading
+
+import (
+	"testing"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		q      models.Question
+		answer interface{}
+		want   int
+	}{
+		{"single_choice right", models.Question{Type: "single_choice", Answer: "B", Points: 5}, "B", 5},
+		{"single_choice wrong", models.Question{Type: "single_choice", Answer: "B", Points: 5}, "A", 0},
+		{"single_choice not a string", models.Question{Type: "single_choice", Answer: "B", Points: 5}, 1.0, 0},
+		{"true_false right", models.Question{Type: "true_false", Answer: "true", Points: 2}, "true", 2},
+		{"true_false wrong", models.Question{Type: "true_false", Answer: "true", Points: 2}, "false", 0},
+		{"multiple_choice any order", models.Question{Type: "multiple_choice", Answer: `["A","C"]`, Points: 4}, []interface{}{"C", "A"}, 4},
+		{"multiple_choice encoded string", models.Question{Type: "multiple_choice", Answer: `["A","C"]`, Points: 4}, `["A","C"]`, 4},
+		{"multiple_choice partial", models.Question{Type: "multiple_choice", Answer: `["A","C"]`, Points: 4}, []interface{}{"A"}, 0},
+		{"multiple_choice bad string", models.Question{Type: "multiple_choice", Answer: `["A","C"]`, Points: 4}, "A,C", 0},
+		{"multiple_choice bad answer key", models.Question{Type: "multiple_choice", Answer: "A,C", Points: 4}, []interface{}{"A", "C"}, 0},
+		{"fill_blank right", models.Question{Type: "fill_blank", Answer: "V/m", MatchRule: "exact_trim", Points: 3}, " v/m ", 3},
+		{"fill_blank not a string", models.Question{Type: "fill_blank", Answer: "V/m", MatchRule: "exact_trim", Points: 3}, []interface{}{"V/m"}, 0},
+		{"ordering all right", models.Question{Type: "ordering", Answer: `["a","b","c","d"]`, Points: 8}, []interface{}{"a", "b", "c", "d"}, 8},
+		{"ordering half right", models.Question{Type: "ordering", Answer: `["a","b","c","d"]`, Points: 8}, []interface{}{"a", "b", "d", "c"}, 4},
+		{"ordering rounds down", models.Question{Type: "ordering", Answer: `["a","b","c"]`, Points: 5}, `["a","c","b"]`, 1},
+		{"ordering short answer", models.Question{Type: "ordering", Answer: `["a","b","c","d"]`, Points: 8}, []interface{}{"a"}, 2},
+		{"ordering empty answer key", models.Question{Type: "ordering", Answer: `[]`, Points: 8}, []interface{}{}, 0},
+		{"matching all right", models.Question{Type: "matching", Answer: `{"x":"1","y":"2"}`, Points: 6}, map[string]interface{}{"x": "1", "y": "2"}, 6},
+		{"matching half right", models.Question{Type: "matching", Answer: `{"x":"1","y":"2"}`, Points: 6}, `{"x":"1","y":"1"}`, 3},
+		{"matching missing prompt", models.Question{Type: "matching", Answer: `{"x":"1","y":"2"}`, Points: 6}, map[string]interface{}{"y": "2"}, 3},
+		{"matching empty answer key", models.Question{Type: "matching", Answer: `{}`, Points: 6}, map[string]interface{}{}, 0},
+		{"unknown type", models.Question{Type: "essay", Answer: "anything", Points: 10}, "anything", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Score(tt.q, tt.answer))
+		})
+	}
+}
+
+func TestScore_FillBlankMatchRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		answer string
+		input  string
+		want   bool
+	}{
+		{"exact match", "exact", "V/m", "V/m", true},
+		{"exact keeps case", "exact", "V/m", "v/m", false},
+		{"exact keeps spaces", "exact", "V/m", " V/m", false},
+		{"exact any accepted answer", "exact", `["V/m","N/C"]`, "N/C", true},
+		{"exact_trim trims and folds case", "exact_trim", "V/m", "  v/M　", true},
+		{"exact_trim folds full-width", "exact_trim", "4", "４", true},
+		{"exact_trim rejects extra text", "exact_trim", "V/m", "V/m2", false},
+		{"contains substring", "contains", "gauss", "By Gauss's law", true},
+		{"contains missing", "contains", "gauss", "By Faraday's law", false},
+		{"regex match", "regex", `^[0-9]+(\.[0-9]+)?\s*V/m$`, "12.5 V/m", true},
+		{"regex sees raw input", "regex", `^V/m$`, " V/m", false},
+		{"regex invalid pattern", "regex", `([0-9`, "([0-9", false},
+		{"regex invalid pattern falls through to next", "regex", `["([0-9","^ok$"]`, "ok", true},
+		{"unknown rule", "fuzzy", "V/m", "V/m", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := models.Question{Type: "fill_blank", Answer: tt.answer, MatchRule: tt.rule, Points: 1}
+			assert.Equal(t, tt.want, Score(q, tt.input) == 1)
+		})
+	}
+}
+
+func TestDecodeOrdering(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  []string
+		ok    bool
+	}{
+		{"decoded array", []interface{}{"b", "a"}, []string{"b", "a"}, true},
+		{"encoded string", `["b","a"]`, []string{"b", "a"}, true},
+		{"non-string item", []interface{}{"a", 1.0}, nil, false},
+		{"nested array", []interface{}{[]interface{}{"a"}}, nil, false},
+		{"invalid json", `["a",`, nil, false},
+		{"json object", `{"a":"b"}`, nil, false},
+		{"json numbers", `[1,2]`, nil, false},
+		{"number", 3.0, nil, false},
+		{"nil", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DecodeOrdering(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeMatching(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  map[string]string
+		ok    bool
+	}{
+		{"decoded object", map[string]interface{}{"x": "1"}, map[string]string{"x": "1"}, true},
+		{"encoded string", `{"x":"1"}`, map[string]string{"x": "1"}, true},
+		{"non-string target", map[string]interface{}{"x": 1.0}, nil, false},
+		{"nested object", map[string]interface{}{"x": map[string]interface{}{"y": "1"}}, nil, false},
+		{"invalid json", `{"x":`, nil, false},
+		{"json array", `["x","1"]`, nil, false},
+		{"json number targets", `{"x":1}`, nil, false},
+		{"decoded array", []interface{}{"x", "1"}, nil, false},
+		{"nil", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DecodeMatching(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
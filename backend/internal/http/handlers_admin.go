@@ -1,21 +1,36 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"gorm.io/gorm"
 )
 
 type adminHandlers struct {
-	db *gorm.DB
+	db        *gorm.DB
+	sessions  auth.SessionStore
+	analytics *services.AnalyticsService
 }
 
-func newAdminHandlers(db *gorm.DB) *adminHandlers {
-	return &adminHandlers{db: db}
+func newAdminHandlers(db *gorm.DB, sessions auth.SessionStore) *adminHandlers {
+	return &adminHandlers{db: db, sessions: sessions, analytics: services.NewAnalyticsService(db)}
+}
+
+// revokeSessions revokes the user's sessions when a session store is configured.
+func (h *adminHandlers) revokeSessions(c *gin.Context, userID uint, reason string) error {
+	if h.sessions == nil {
+		return nil
+	}
+	return h.sessions.Revoke(c.Request.Context(), userID, reason)
 }
 
 // SystemStats represents overall system statistics
@@ -44,30 +59,68 @@ func (h *adminHandlers) GetSystemStats(c *gin.Context) {
 		UsersByRole: make(map[string]int64),
 	}
 
-	h.db.Model(&models.User{}).Count(&stats.TotalUsers)
-	h.db.Model(&models.Course{}).Count(&stats.TotalCourses)
-	h.db.Model(&models.Assignment{}).Count(&stats.TotalAssignments)
-	h.db.Model(&models.Submission{}).Count(&stats.TotalSubmissions)
-	h.db.Model(&models.Quiz{}).Count(&stats.TotalQuizzes)
-	h.db.Model(&models.Resource{}).Count(&stats.TotalResources)
+	h.db.WithContext(c.Request.Context()).Model(&models.User{}).Count(&stats.TotalUsers)
+	h.db.WithContext(c.Request.Context()).Model(&models.Course{}).Count(&stats.TotalCourses)
+	h.db.WithContext(c.Request.Context()).Model(&models.Assignment{}).Count(&stats.TotalAssignments)
+	h.db.WithContext(c.Request.Context()).Model(&models.Submission{}).Count(&stats.TotalSubmissions)
+	h.db.WithContext(c.Request.Context()).Model(&models.Quiz{}).Count(&stats.TotalQuizzes)
+	h.db.WithContext(c.Request.Context()).Model(&models.Resource{}).Count(&stats.TotalResources)
 
 	// Count users by role
 	roles := []string{"admin", "teacher", "assistant", "student"}
 	for _, role := range roles {
 		var count int64
-		h.db.Model(&models.User{}).Where("role = ?", role).Count(&count)
+		h.db.WithContext(c.Request.Context()).Model(&models.User{}).Where("role = ?", role).Count(&count)
 		stats.UsersByRole[role] = count
 	}
 
 	respondOK(c, stats)
 }
 
+// GetAnalytics returns KPIs across all courses for a from/to range, given
+// like the audit log's and defaulting to the last 7 days
+// GET /admin/analytics
+func (h *adminHandlers) GetAnalytics(c *gin.Context) {
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		parsed, dateOnly, err := parseAuditTime(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "to must be an RFC 3339 time or YYYY-MM-DD date", nil)
+			return
+		}
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -7)
+	if raw := c.Query("from"); raw != "" {
+		parsed, _, err := parseAuditTime(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "from must be an RFC 3339 time or YYYY-MM-DD date", nil)
+			return
+		}
+		from = parsed
+	}
+
+	analytics, err := h.analytics.GetAnalytics(c.Request.Context(), from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDateRange) {
+			respondError(c, http.StatusBadRequest, "INVALID_DATE_RANGE", "from must be before to, at most 366 days apart", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to compute analytics", nil)
+		return
+	}
+	respondOK(c, analytics)
+}
+
 // ListUsers returns a list of all users
 func (h *adminHandlers) ListUsers(c *gin.Context) {
 	roleFilter := c.Query("role")
 
 	var users []models.User
-	query := h.db.Model(&models.User{})
+	query := h.db.WithContext(c.Request.Context()).Model(&models.User{})
 	if roleFilter != "" {
 		query = query.Where("role = ?", roleFilter)
 	}
@@ -105,7 +158,7 @@ func (h *adminHandlers) CreateUser(c *gin.Context) {
 
 	// Check if username already exists
 	var existing models.User
-	if h.db.Where("username = ?", req.Username).First(&existing).Error == nil {
+	if h.db.WithContext(c.Request.Context()).Where("username = ?", req.Username).First(&existing).Error == nil {
 		respondError(c, http.StatusConflict, "CONFLICT", "username already exists", nil)
 		return
 	}
@@ -123,7 +176,7 @@ func (h *adminHandlers) CreateUser(c *gin.Context) {
 		Name:         req.Name,
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&user).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create user", nil)
 		return
 	}
@@ -148,7 +201,7 @@ func (h *adminHandlers) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 
 	var user models.User
-	if err := h.db.First(&user, id).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&user, id).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "user not found", nil)
 		return
 	}
@@ -159,6 +212,7 @@ func (h *adminHandlers) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	previousRole := user.Role
 	updates := map[string]interface{}{}
 
 	if req.Password != "" {
@@ -183,14 +237,43 @@ func (h *adminHandlers) UpdateUser(c *gin.Context) {
 	}
 
 	if len(updates) > 0 {
-		if err := h.db.Model(&user).Updates(updates).Error; err != nil {
+		ctx := c.Request.Context()
+		actor, _ := middleware.GetUser(c)
+		err := h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Updates(updates).Error; err != nil {
+				return err
+			}
+			if req.Role == "" || req.Role == previousRole {
+				return nil
+			}
+			return services.RecordAudit(ctx, repositories.NewAuditRepository(tx), services.AuditEntry{
+				Actor:      services.UserInfo{ID: actor.ID, Role: actor.Role},
+				Action:     services.AuditUserRoleChange,
+				TargetType: "user",
+				TargetID:   user.ID,
+				Details: map[string]interface{}{
+					"username":      user.Username,
+					"previous_role": previousRole,
+					"role":          req.Role,
+				},
+			})
+		})
+		if err != nil {
 			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update user", nil)
 			return
 		}
 	}
 
+	// Tokens carry the role and were issued against the old password
+	if req.Password != "" || (req.Role != "" && req.Role != previousRole) {
+		if err := h.revokeSessions(c, user.ID, "user_updated"); err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to revoke sessions", nil)
+			return
+		}
+	}
+
 	// Reload user
-	h.db.First(&user, id)
+	h.db.WithContext(c.Request.Context()).First(&user, id)
 
 	respondOK(c, gin.H{
 		"id":       user.ID,
@@ -206,7 +289,7 @@ func (h *adminHandlers) DeleteUser(c *gin.Context) {
 	currentUser, _ := middleware.GetUser(c)
 
 	var user models.User
-	if err := h.db.First(&user, id).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&user, id).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "user not found", nil)
 		return
 	}
@@ -217,10 +300,108 @@ func (h *adminHandlers) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Delete(&user).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to delete user", nil)
 		return
 	}
+	if err := h.revokeSessions(c, user.ID, "user_deleted"); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to revoke sessions", nil)
+		return
+	}
 
 	respondOK(c, gin.H{"message": "user deleted"})
 }
+
+// RevokeSessions invalidates every token issued to a user so far
+// POST /admin/users/:id/revoke-sessions
+func (h *adminHandlers) RevokeSessions(c *gin.Context) {
+	id := c.Param("id")
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&user, id).Error; err != nil {
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "user not found", nil)
+		return
+	}
+
+	if err := h.revokeSessions(c, user.ID, "admin"); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to revoke sessions", nil)
+		return
+	}
+
+	respondOK(c, gin.H{"message": "sessions revoked", "user_id": user.ID})
+}
+
+// AuditLogListResponse is a page of audit log entries, newest first
+type AuditLogListResponse struct {
+	Items    []models.AuditLog `json:"items"`
+	Total    int64             `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+}
+
+// ListAuditLogs pages through the audit log of privileged actions. It can be
+// filtered by actor_id, action, and a from/to range given as RFC 3339 times
+// or YYYY-MM-DD dates (UTC); a date-only to includes that whole day.
+// GET /audit-logs
+func (h *adminHandlers) ListAuditLogs(c *gin.Context) {
+	var filter repositories.AuditLogFilter
+	if raw := c.Query("actor_id"); raw != "" {
+		actorID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid actor_id", nil)
+			return
+		}
+		filter.ActorID = uint(actorID)
+	}
+	filter.Action = c.Query("action")
+	if raw := c.Query("from"); raw != "" {
+		from, _, err := parseAuditTime(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "from must be an RFC 3339 time or YYYY-MM-DD date", nil)
+			return
+		}
+		filter.From = &from
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, dateOnly, err := parseAuditTime(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "to must be an RFC 3339 time or YYYY-MM-DD date", nil)
+			return
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+
+	logs, total, err := repositories.NewAuditRepository(h.db).List(c.Request.Context(), filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list audit logs", nil)
+		return
+	}
+	respondOK(c, AuditLogListResponse{
+		Items:    logs,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// parseAuditTime parses an RFC 3339 time or a YYYY-MM-DD date at UTC
+// midnight, reporting which form was given.
+func parseAuditTime(raw string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	return t, true, err
+}
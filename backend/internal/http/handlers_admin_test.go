@@ -0,0 +1,108 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestGetAnalytics_AggregatesRange(t *testing.T) {
+	db := setupDashboardTestDB(t)
+	createCourseTestUser(t, db, "admin1", "pass123", "admin")
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+	createCourseTestUser(t, db, "carol", "pass123", "student")
+
+	now := time.Now()
+	old := now.AddDate(0, 0, -10)
+	active := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	quiet := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	db.Create(&active)
+	db.Create(&quiet)
+	db.Create(&models.CourseEnrollment{CourseID: active.ID, UserID: alice.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: active.ID, UserID: bob.ID, Role: "student"})
+
+	hw := models.Assignment{CourseID: active.ID, TeacherID: teacher.ID, Title: "hw", IsPublished: true}
+	oldHW := models.Assignment{CourseID: quiet.ID, TeacherID: teacher.ID, Title: "old", IsPublished: true}
+	db.Create(&hw)
+	db.Create(&oldHW)
+	db.Create(&models.Submission{AssignmentID: hw.ID, StudentID: alice.ID, Content: "mine"})
+	db.Create(&models.Submission{Model: gorm.Model{CreatedAt: old}, AssignmentID: oldHW.ID, StudentID: bob.ID, Content: "old"})
+
+	quiz := models.Quiz{CourseID: active.ID, CreatedByID: teacher.ID, Title: "quiz", IsPublished: true}
+	db.Create(&quiz)
+	for _, a := range []struct {
+		student uint
+		score   int
+		at      time.Time
+	}{{alice.ID, 8, now}, {bob.ID, 6, now}, {bob.ID, 0, old}} {
+		score := a.score
+		at := a.at
+		db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: a.student, StartedAt: at, Deadline: at, SubmittedAt: &at, Score: &score, MaxScore: 10})
+	}
+
+	session := models.AttendanceSession{CourseID: active.ID, StartedByID: teacher.ID, StartAt: now, EndAt: now, Code: "123456"}
+	db.Create(&session)
+	db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: alice.ID, CheckedInAt: now})
+
+	tokens := auth.TokenConfig{Secret: "test-secret"}
+	hAdmin := newAdminHandlers(db, nil)
+	hAuth := newAuthHandlers(db, tokens)
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	r.GET("/admin/analytics", middleware.AuthRequired(tokens), middleware.RequirePermission(authz.PermUserManage), hAdmin.GetAnalytics)
+	get := func(username, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/analytics"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("admin1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.InstitutionAnalytics]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	got := resp.Data
+	assert.EqualValues(t, 2, got.TotalCourses)
+	assert.EqualValues(t, 1, got.ActiveCourses)
+	assert.EqualValues(t, 3, got.TotalStudents)
+	assert.EqualValues(t, 2, got.ActiveStudents)
+	assert.EqualValues(t, 1, got.Submissions)
+	assert.EqualValues(t, 2, got.QuizAttempts)
+	if assert.NotNil(t, got.AverageQuizScore) {
+		assert.InDelta(t, 70, *got.AverageQuizScore, 1e-9)
+	}
+	if assert.NotNil(t, got.AttendanceRate) {
+		assert.InDelta(t, 0.5, *got.AttendanceRate, 1e-9)
+	}
+
+	// A range covering only the old activity.
+	from, to := old.AddDate(0, 0, -1).Format("2006-01-02"), old.Format("2006-01-02")
+	w = get("admin1", "?from="+from+"&to="+to)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	got = resp.Data
+	assert.EqualValues(t, 2, got.ActiveCourses) // the old submission and the old attempt
+	assert.EqualValues(t, 1, got.ActiveStudents)
+	assert.EqualValues(t, 1, got.Submissions)
+	if assert.NotNil(t, got.AverageQuizScore) {
+		assert.InDelta(t, 0, *got.AverageQuizScore, 1e-9)
+	}
+	assert.Nil(t, got.AttendanceRate)
+
+	assert.Equal(t, http.StatusBadRequest, get("admin1", "?from="+to+"&to="+from).Code)
+	assert.Equal(t, http.StatusForbidden, get("teacher1", "").Code)
+}
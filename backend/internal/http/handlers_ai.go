@@ -1,27 +1,110 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/config"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+// sseHeartbeatInterval is how long a chat stream may stay silent before an
+// SSE comment is sent so proxies do not close the idle connection.
+const sseHeartbeatInterval = 15 * time.Second
+
+const (
+	// chatSessionHeader carries the transcript session id back to the client,
+	// which sends it as session_id on later turns to continue the transcript.
+	chatSessionHeader        = "X-Chat-Session-ID"
+	chatTranscriptJobTimeout = 30 * time.Second
+	chatTranscriptAttempts   = 3
 )
 
 type aiHandlers struct {
-	ai *clients.AIClient
+	ai           *clients.AIClient
+	allowedModes map[string][]string
+	heartbeat    time.Duration
+	transcripts  *services.ChatTranscriptService
+	queue        *jobs.Queue
 }
 
-func newAIHandlers(ai *clients.AIClient) *aiHandlers {
-	return &aiHandlers{ai: ai}
+// newAIHandlers builds the AI handlers; allowedModes maps a role to the chat
+// modes it may use, and roles missing from it get the default student set.
+func newAIHandlers(ai *clients.AIClient, allowedModes map[string][]string) *aiHandlers {
+	return &aiHandlers{ai: ai, allowedModes: allowedModes, heartbeat: sseHeartbeatInterval}
+}
+
+// withTranscripts stores each chat turn through the job queue so saving a
+// transcript never delays the reply.
+func (h *aiHandlers) withTranscripts(transcripts *services.ChatTranscriptService, queue *jobs.Queue) *aiHandlers {
+	h.transcripts = transcripts
+	h.queue = queue
+	return h
+}
+
+// requireAI responds 503 AI_DISABLED and returns false when no AI service is
+// configured, so callers get a clear answer instead of a gateway error.
+func requireAI(c *gin.Context, ai *clients.AIClient) bool {
+	if ai.IsConfigured() {
+		return true
+	}
+	respondError(c, http.StatusServiceUnavailable, "AI_DISABLED", "AI features are not configured", nil)
+	return false
+}
+
+// modeAllowed reports whether role may use mode. Like the AI service, the mode
+// is compared case-insensitively with any "_rag" suffix removed; an empty mode
+// is always allowed.
+func (h *aiHandlers) modeAllowed(role, mode string) bool {
+	base := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(mode)), "_rag")
+	if base == "" {
+		return true
+	}
+	allowed, ok := h.allowedModes[role]
+	if !ok {
+		allowed = strings.Split(config.DefaultStudentAIModes, ",")
+	}
+	for _, m := range allowed {
+		if m == "*" || strings.ToLower(m) == base {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMode writes a MODE_NOT_ALLOWED response and returns false when the
+// caller's role may not use mode.
+func (h *aiHandlers) checkMode(c *gin.Context, mode string) bool {
+	user, _ := middleware.GetUser(c)
+	if h.modeAllowed(user.Role, mode) {
+		return true
+	}
+	respondError(c, http.StatusForbidden, "MODE_NOT_ALLOWED", "ai mode not allowed for your role", gin.H{"mode": mode})
+	return false
 }
 
 type chatRequest struct {
-	Mode     string                `json:"mode"`
-	Messages []clients.ChatMessage `json:"messages" binding:"required"`
-	Stream   bool                  `json:"stream"`
+	Mode      string                `json:"mode"`
+	Messages  []clients.ChatMessage `json:"messages" binding:"required"`
+	Stream    bool                  `json:"stream"`
+	SessionID string                `json:"session_id,omitempty" binding:"omitempty,max=64"`
+	CourseID  *uint                 `json:"course_id,omitempty"`
 }
 
 func (h *aiHandlers) Chat(c *gin.Context) {
@@ -30,6 +113,18 @@ func (h *aiHandlers) Chat(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request", nil)
 		return
 	}
+	if !h.checkMode(c, req.Mode) {
+		return
+	}
+	if !requireAI(c, h.ai) {
+		return
+	}
+	if h.transcripts != nil {
+		if req.SessionID == "" {
+			req.SessionID = newChatSessionID()
+		}
+		c.Header(chatSessionHeader, req.SessionID)
+	}
 
 	// Streaming mode
 	if req.Stream {
@@ -46,6 +141,7 @@ func (h *aiHandlers) Chat(c *gin.Context) {
 		respondError(c, http.StatusBadGateway, "BAD_GATEWAY", err.Error(), nil)
 		return
 	}
+	h.recordTranscript(c, req.SessionID, req.CourseID, req.Mode, req.Messages, resp.Reply)
 	respondOK(c, resp)
 }
 
@@ -68,21 +164,68 @@ func (h *aiHandlers) streamChat(c *gin.Context, req chatRequest) {
 		return
 	}
 	defer body.Close()
+	c.Writer.Flush()
 
-	// Stream response body directly to client
-	buf := make([]byte, 4096)
+	// Collect the reply as it is forwarded and store the turn once the stream
+	// ends, including when the client leaves part way through.
+	var reply sseContentCollector
+	defer func() {
+		h.recordTranscript(c, req.SessionID, req.CourseID, req.Mode, req.Messages, reply.String())
+	}()
+
+	// Read upstream in the background so the loop below can also send
+	// heartbeats and notice the client leaving while a read is blocked.
+	done := make(chan struct{})
+	defer close(done)
+	chunks := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				chunk := make([]byte, n)
+				copy(chunk, buf[:n])
+				select {
+				case chunks <- chunk:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	// A heartbeat may only go between events; tail holds the last bytes
+	// forwarded so a comment is never spliced into a half-sent event.
+	tail := []byte("\n\n")
 	for {
-		n, readErr := body.Read(buf)
-		if n > 0 {
-			c.Writer.Write(buf[:n])
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case chunk := <-chunks:
+			c.Writer.Write(chunk)
 			c.Writer.Flush()
-		}
-		if readErr != nil {
-			if readErr != io.EOF {
+			reply.Write(chunk)
+			tail = append(tail, chunk...)
+			tail = tail[len(tail)-2:]
+			ticker.Reset(h.heartbeat)
+		case err := <-readErr:
+			if err != io.EOF {
 				c.Writer.WriteString("data: {\"error\":\"stream read error\"}\n\n")
 				c.Writer.Flush()
 			}
-			break
+			return
+		case <-ticker.C:
+			if string(tail) == "\n\n" {
+				c.Writer.WriteString(": ping\n\n")
+				c.Writer.Flush()
+			}
 		}
 	}
 }
@@ -93,6 +236,12 @@ func (h *aiHandlers) ChatWithTools(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request", nil)
 		return
 	}
+	if !h.checkMode(c, req.Mode) {
+		return
+	}
+	if !requireAI(c, h.ai) {
+		return
+	}
 
 	resp, err := h.ai.ChatWithTools(c.Request.Context(), req)
 	if err != nil {
@@ -125,6 +274,9 @@ func (h *aiHandlers) ChatGuided(c *gin.Context) {
 		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "user not found in context", nil)
 		return
 	}
+	if !requireAI(c, h.ai) {
+		return
+	}
 
 	// Build AI service request with injected user_id
 	aiReq := clients.GuidedChatRequest{
@@ -140,5 +292,126 @@ func (h *aiHandlers) ChatGuided(c *gin.Context) {
 		respondError(c, http.StatusBadGateway, "BAD_GATEWAY", err.Error(), nil)
 		return
 	}
+
+	sessionID := resp.SessionID
+	if sessionID == "" {
+		sessionID = req.SessionID
+	}
+	var courseID *uint
+	if id, err := strconv.ParseUint(req.CourseID, 10, 32); err == nil {
+		cid := uint(id)
+		courseID = &cid
+	}
+	h.recordTranscript(c, sessionID, courseID, "guided", req.Messages, resp.Reply)
 	respondOK(c, resp)
 }
+
+// GetChatSession returns the stored transcript of a chat session
+// GET /chat-sessions/:id
+func (h *aiHandlers) GetChatSession(c *gin.Context) {
+	if h.transcripts == nil {
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "chat session not found", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	transcript, err := h.transcripts.GetTranscript(c.Request.Context(), c.Param("id"), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTranscriptNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "chat session not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load chat session", nil)
+		}
+		return
+	}
+	respondOK(c, transcript)
+}
+
+// recordTranscript queues the last user message and the assistant's reply
+// for storage in the session's transcript. It does nothing when transcripts
+// are not configured or there is nothing to store.
+func (h *aiHandlers) recordTranscript(c *gin.Context, sessionID string, courseID *uint, mode string, messages []clients.ChatMessage, reply string) {
+	if h.transcripts == nil || h.queue == nil || sessionID == "" {
+		return
+	}
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		return
+	}
+
+	turn := services.TranscriptTurn{SessionKey: sessionID, UserID: user.ID, Role: user.Role, CourseID: courseID, Mode: mode}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			turn.Messages = append(turn.Messages, services.TranscriptEntry{Role: "user", Content: messages[i].Content})
+			break
+		}
+	}
+	if reply != "" {
+		turn.Messages = append(turn.Messages, services.TranscriptEntry{Role: "assistant", Content: reply})
+	}
+	if len(turn.Messages) == 0 {
+		return
+	}
+
+	if err := h.queue.Enqueue(jobs.Task{
+		Name:        "chat_transcript:" + sessionID,
+		MaxAttempts: chatTranscriptAttempts,
+		Timeout:     chatTranscriptJobTimeout,
+		Run: func(ctx context.Context) error {
+			err := h.transcripts.AppendTurn(ctx, turn)
+			if errors.Is(err, services.ErrAccessDenied) {
+				return jobs.Permanent(err)
+			}
+			return err
+		},
+	}); err != nil {
+		logger.Log.Warn("chat transcript not queued", slog.String("session_id", sessionID), slog.Any("error", err))
+	}
+}
+
+// newChatSessionID returns a random id for a chat that did not name a session.
+func newChatSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sseContentCollector rebuilds the assistant reply from forwarded SSE bytes by
+// joining the "content" field of each data event. Chunks may split events, so
+// an incomplete line is held until the rest arrives. Collection stops after
+// MaxTranscriptMessageBytes since the stored message is cut there anyway.
+type sseContentCollector struct {
+	pending []byte
+	reply   strings.Builder
+}
+
+func (s *sseContentCollector) Write(chunk []byte) {
+	s.pending = append(s.pending, chunk...)
+	for {
+		i := bytes.IndexByte(s.pending, '\n')
+		if i < 0 {
+			return
+		}
+		line := bytes.TrimSpace(s.pending[:i])
+		s.pending = s.pending[i+1:]
+		if s.reply.Len() > services.MaxTranscriptMessageBytes || !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		var event struct {
+			Content string `json:"content"`
+		}
+		if json.Unmarshal(bytes.TrimSpace(line[len("data:"):]), &event) == nil {
+			s.reply.WriteString(event.Content)
+		}
+	}
+}
+
+func (s *sseContentCollector) String() string {
+	return s.reply.String()
+}
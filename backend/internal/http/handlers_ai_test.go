@@ -2,15 +2,24 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func init() {
@@ -41,7 +50,7 @@ func TestChatGuided_Success(t *testing.T) {
 	mockAI := &clients.AIClient{}
 	// Note: In real test, we'd use interface and mock
 
-	handler := newAIHandlers(mockAI)
+	handler := newAIHandlers(mockAI, nil)
 
 	// Create test router
 	r := gin.New()
@@ -79,7 +88,7 @@ func TestChatGuided_Success(t *testing.T) {
 
 func TestChatGuided_MissingUserID(t *testing.T) {
 	mockAI := &clients.AIClient{}
-	handler := newAIHandlers(mockAI)
+	handler := newAIHandlers(mockAI, nil)
 
 	r := gin.New()
 	// No user_id set - simulates missing JWT
@@ -103,7 +112,7 @@ func TestChatGuided_MissingUserID(t *testing.T) {
 
 func TestChatGuided_InvalidJSON(t *testing.T) {
 	mockAI := &clients.AIClient{}
-	handler := newAIHandlers(mockAI)
+	handler := newAIHandlers(mockAI, nil)
 
 	r := gin.New()
 	r.Use(func(c *gin.Context) {
@@ -124,7 +133,7 @@ func TestChatGuided_InvalidJSON(t *testing.T) {
 
 func TestChatGuided_EmptyMessages(t *testing.T) {
 	mockAI := &clients.AIClient{}
-	handler := newAIHandlers(mockAI)
+	handler := newAIHandlers(mockAI, nil)
 
 	r := gin.New()
 	r.Use(func(c *gin.Context) {
@@ -163,7 +172,7 @@ func TestUserIDInjection(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockAI := &clients.AIClient{}
-			handler := newAIHandlers(mockAI)
+			handler := newAIHandlers(mockAI, nil)
 
 			r := gin.New()
 			r.Use(func(c *gin.Context) {
@@ -202,3 +211,260 @@ func TestLearningProfileHandler_Integration(t *testing.T) {
 	// This would require actual DB setup
 	t.Skip("Integration test requires database connection")
 }
+
+func TestChat_ModeAllowlistByRole(t *testing.T) {
+	var forwarded []string
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req clients.ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		forwarded = append(forwarded, req.Mode)
+		_ = json.NewEncoder(w).Encode(map[string]string{"reply": "ok"})
+	}))
+	defer aiServer.Close()
+
+	tokens := auth.TokenConfig{Secret: "test-secret"}
+	handler := newAIHandlers(clients.NewAIClient(aiServer.URL), map[string][]string{
+		"student": {"tutor"},
+		"teacher": {"*"},
+	})
+	r := gin.New()
+	r.POST("/ai/chat", middleware.AuthRequired(tokens), handler.Chat)
+
+	chat := func(role, mode string) *httptest.ResponseRecorder {
+		token, err := auth.SignToken(tokens, 1, "user", role, time.Hour)
+		assert.NoError(t, err)
+		body, _ := json.Marshal(chatRequest{Mode: mode, Messages: []clients.ChatMessage{{Role: "user", Content: "hi"}}})
+		req := httptest.NewRequest(http.MethodPost, "/ai/chat", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, chat("student", "tutor").Code)
+	assert.Equal(t, http.StatusOK, chat("student", "Tutor_RAG").Code)
+
+	w := chat("student", "deep_research")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "MODE_NOT_ALLOWED")
+
+	assert.Equal(t, http.StatusOK, chat("teacher", "deep_research").Code)
+
+	// Roles without an explicit entry fall back to the default student set.
+	assert.Equal(t, http.StatusForbidden, chat("assistant", "deep_research").Code)
+	assert.Equal(t, http.StatusOK, chat("assistant", "polish").Code)
+
+	assert.Equal(t, []string{"tutor", "Tutor_RAG", "deep_research", "polish"}, forwarded)
+}
+
+func TestChat_AIDisabledWithoutBaseURL(t *testing.T) {
+	tokens := auth.TokenConfig{Secret: "test-secret"}
+	handler := newAIHandlers(clients.NewAIClient(""), map[string][]string{"": {"*"}})
+	r := gin.New()
+	r.POST("/ai/chat", middleware.AuthRequired(tokens), handler.Chat)
+	r.POST("/ai/chat/guided", middleware.AuthRequired(tokens), handler.ChatGuided)
+
+	token, err := auth.SignToken(tokens, 1, "user", "student", time.Hour)
+	assert.NoError(t, err)
+	for _, tc := range []struct{ path, body string }{
+		{"/ai/chat", `{"mode":"tutor","messages":[{"role":"user","content":"hi"}]}`},
+		{"/ai/chat", `{"mode":"tutor","stream":true,"messages":[{"role":"user","content":"hi"}]}`},
+		{"/ai/chat/guided", `{"messages":[{"role":"user","content":"hi"}]}`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, tc.body)
+		assert.Contains(t, w.Body.String(), "AI_DISABLED")
+	}
+}
+
+func TestStreamChat_HeartbeatBetweenEvents(t *testing.T) {
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for _, part := range []string{"data: a\n\n", "data: par", "tial\n\n"} {
+			_, _ = w.Write([]byte(part))
+			flusher.Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+	}))
+	defer aiServer.Close()
+
+	handler := newAIHandlers(clients.NewAIClient(aiServer.URL), map[string][]string{"": {"*"}})
+	handler.heartbeat = 10 * time.Millisecond
+	r := gin.New()
+	r.POST("/ai/chat", handler.Chat)
+
+	body, _ := json.Marshal(chatRequest{Mode: "tutor", Stream: true, Messages: []clients.ChatMessage{{Role: "user", Content: "hi"}}})
+	req := httptest.NewRequest(http.MethodPost, "/ai/chat", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	out := w.Body.String()
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Contains(t, out, "data: a\n\n: ping\n\n")
+	// No comment may be spliced into the event that arrived in two reads.
+	assert.Contains(t, out, "data: partial\n\n")
+}
+
+func TestStreamChat_StopsWhenClientLeaves(t *testing.T) {
+	upstreamDone := make(chan struct{})
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: a\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(upstreamDone)
+	}))
+	defer aiServer.Close()
+
+	handler := newAIHandlers(clients.NewAIClient(aiServer.URL), map[string][]string{"": {"*"}})
+	r := gin.New()
+	r.POST("/ai/chat", handler.Chat)
+
+	body, _ := json.Marshal(chatRequest{Stream: true, Messages: []clients.ChatMessage{{Role: "user", Content: "hi"}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/ai/chat", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	finished := make(chan struct{})
+	go func() {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		close(finished)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler kept streaming after the client left")
+	}
+	select {
+	case <-upstreamDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not cancelled")
+	}
+}
+
+func TestChatTranscripts_StoredPerSessionAndReadable(t *testing.T) {
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req clients.ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			_ = json.NewEncoder(w).Encode(map[string]string{"reply": "Use Gauss's law."})
+			return
+		}
+		for _, part := range []string{`data: {"type":"start"}` + "\n\n", `data: {"content":"Hel`, `lo"}` + "\n\n", `data: {"content":" there"}` + "\n\n"} {
+			_, _ = w.Write([]byte(part))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer aiServer.Close()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.Course{}, &models.CourseEnrollment{}, &models.ChatTranscript{}, &models.ChatTranscriptMessage{}))
+	course := models.Course{Name: "Test Course", TeacherID: 3}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: 1})
+
+	queue := jobs.NewQueue(1, 4)
+	t.Cleanup(func() { _ = queue.Shutdown(context.Background()) })
+	tokens := auth.TokenConfig{Secret: "test-secret"}
+	handler := newAIHandlers(clients.NewAIClient(aiServer.URL), nil).withTranscripts(services.NewChatTranscriptService(db), queue)
+	r := gin.New()
+	r.POST("/ai/chat", middleware.AuthRequired(tokens), handler.Chat)
+	r.GET("/chat-sessions/:id", middleware.AuthRequired(tokens), handler.GetChatSession)
+
+	do := func(userID uint, role, method, path string, body interface{}) *httptest.ResponseRecorder {
+		token, err := auth.SignToken(tokens, userID, "user", role, time.Hour)
+		assert.NoError(t, err)
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	messageCount := func(sessionID string) int {
+		var transcript models.ChatTranscript
+		db.Where("session_key = ?", sessionID).First(&transcript)
+		return transcript.MessageCount
+	}
+
+	w := do(1, "student", http.MethodPost, "/ai/chat", chatRequest{
+		Mode:     "tutor",
+		CourseID: &course.ID,
+		Messages: []clients.ChatMessage{{Role: "system", Content: "be brief"}, {Role: "user", Content: "What is flux?"}},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	sessionID := w.Header().Get(chatSessionHeader)
+	assert.Len(t, sessionID, 32)
+	assert.Eventually(t, func() bool { return messageCount(sessionID) == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	w = do(1, "student", http.MethodPost, "/ai/chat", chatRequest{
+		Mode:      "tutor",
+		Stream:    true,
+		SessionID: sessionID,
+		Messages:  []clients.ChatMessage{{Role: "user", Content: "Say hello"}},
+	})
+	assert.Equal(t, sessionID, w.Header().Get(chatSessionHeader))
+	assert.Eventually(t, func() bool { return messageCount(sessionID) == 4 }, 2*time.Second, 10*time.Millisecond)
+
+	w = do(1, "student", http.MethodGet, "/chat-sessions/"+sessionID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.ChatTranscriptView]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	var turns []string
+	for _, m := range resp.Data.Messages {
+		turns = append(turns, m.Role+": "+m.Content)
+	}
+	assert.Equal(t, []string{"user: What is flux?", "assistant: Use Gauss's law.", "user: Say hello", "assistant: Hello there"}, turns)
+
+	assert.Equal(t, http.StatusOK, do(3, "teacher", http.MethodGet, "/chat-sessions/"+sessionID, nil).Code)
+	assert.Equal(t, http.StatusForbidden, do(2, "student", http.MethodGet, "/chat-sessions/"+sessionID, nil).Code)
+	assert.Equal(t, http.StatusForbidden, do(4, "teacher", http.MethodGet, "/chat-sessions/"+sessionID, nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(1, "student", http.MethodGet, "/chat-sessions/missing", nil).Code)
+
+	// A course the student is not enrolled in is not attached to a transcript.
+	w = do(2, "student", http.MethodPost, "/ai/chat", chatRequest{
+		Mode:     "tutor",
+		CourseID: &course.ID,
+		Messages: []clients.ChatMessage{{Role: "user", Content: "What is flux?"}},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	outsider := w.Header().Get(chatSessionHeader)
+	w = do(2, "student", http.MethodPost, "/ai/chat", chatRequest{
+		Mode:     "tutor",
+		Messages: []clients.ChatMessage{{Role: "user", Content: "What is flux?"}},
+	})
+	assert.Eventually(t, func() bool { return messageCount(w.Header().Get(chatSessionHeader)) == 2 }, 2*time.Second, 10*time.Millisecond)
+	var outsiderCount int64
+	db.Model(&models.ChatTranscript{}).Where("session_key = ?", outsider).Count(&outsiderCount)
+	assert.Zero(t, outsiderCount)
+
+	// A long conversation stops growing once the limit is hit, leaving a marker.
+	full := models.ChatTranscript{SessionKey: "long-chat", UserID: 1, MessageCount: services.MaxTranscriptMessages - 1}
+	db.Create(&full)
+	do(1, "student", http.MethodPost, "/ai/chat", chatRequest{
+		SessionID: "long-chat",
+		Messages:  []clients.ChatMessage{{Role: "user", Content: strings.Repeat("长", services.MaxTranscriptMessageBytes)}},
+	})
+	assert.Eventually(t, func() bool {
+		db.First(&full, full.ID)
+		return full.Truncated
+	}, 2*time.Second, 10*time.Millisecond)
+	var stored []models.ChatTranscriptMessage
+	db.Where("transcript_id = ?", full.ID).Order("id").Find(&stored)
+	if assert.Len(t, stored, 2) {
+		assert.LessOrEqual(t, len(stored[0].Content), services.MaxTranscriptMessageBytes)
+		assert.True(t, strings.HasSuffix(stored[0].Content, "…[message truncated]"))
+		assert.Equal(t, "system", stored[1].Role)
+		assert.Contains(t, stored[1].Content, "conversation truncated")
+	}
+	assert.Equal(t, services.MaxTranscriptMessages, full.MessageCount)
+}
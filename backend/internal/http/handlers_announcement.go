@@ -1,22 +1,30 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
 	"gorm.io/gorm"
 )
 
 type announcementHandlers struct {
-	db *gorm.DB
+	db        *gorm.DB
+	repo      *repositories.AnnouncementRepository
+	maxPinned int
 }
 
-func newAnnouncementHandlers(db *gorm.DB) *announcementHandlers {
-	return &announcementHandlers{db: db}
+func newAnnouncementHandlers(db *gorm.DB, maxPinned int) *announcementHandlers {
+	return &announcementHandlers{db: db, repo: repositories.NewAnnouncementRepository(db), maxPinned: maxPinned}
 }
 
 // --- Summary ---
@@ -53,11 +61,11 @@ func (h *announcementHandlers) GetSummary(c *gin.Context) {
 
 	// Get total count
 	var totalCount int64
-	h.db.Model(&models.Announcement{}).Where("course_id = ?", courseID).Count(&totalCount)
+	h.db.WithContext(c.Request.Context()).Model(&models.Announcement{}).Where("course_id = ?", courseID).Count(&totalCount)
 
 	// Get unread count (announcements not in announcement_reads for this user)
 	var readCount int64
-	h.db.Model(&models.AnnouncementRead{}).
+	h.db.WithContext(c.Request.Context()).Model(&models.AnnouncementRead{}).
 		Joins("JOIN announcements ON announcements.id = announcement_reads.announcement_id").
 		Where("announcements.course_id = ? AND announcement_reads.user_id = ?", courseID, userID).
 		Count(&readCount)
@@ -66,7 +74,7 @@ func (h *announcementHandlers) GetSummary(c *gin.Context) {
 	// Get latest announcement
 	var latest models.Announcement
 	var latestInfo *AnnouncementLatestInfo
-	if err := h.db.Where("course_id = ?", courseID).Order("created_at DESC").First(&latest).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID).Order("created_at DESC").First(&latest).Error; err == nil {
 		latestInfo = &AnnouncementLatestInfo{
 			ID:        latest.ID,
 			Title:     latest.Title,
@@ -90,9 +98,11 @@ type AnnouncementListItem struct {
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	IsRead    bool      `json:"is_read"`
+	Pinned    bool      `json:"pinned"`
 }
 
-// List returns all announcements for a course
+// List returns all announcements for a course, pinned first. With q it
+// searches instead; see search
 // GET /courses/:id/announcements
 func (h *announcementHandlers) List(c *gin.Context) {
 	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
@@ -108,23 +118,103 @@ func (h *announcementHandlers) List(c *gin.Context) {
 	}
 	userID := userCtx.ID
 
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		h.search(c, uint(courseID), userID, query)
+		return
+	}
+
 	var announcements []models.Announcement
-	if err := h.db.Where("course_id = ?", courseID).Order("created_at DESC").Find(&announcements).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID).Order("pinned DESC, created_at DESC").Find(&announcements).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to fetch announcements", nil)
+		return
+	}
+
+	result, err := h.listItems(c.Request.Context(), userID, announcements)
+	if err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to fetch announcements", nil)
 		return
 	}
 
-	// Get read status for all announcements
-	var readRecords []models.AnnouncementRead
+	respondOK(c, result)
+}
+
+// --- Search ---
+
+// maxAnnouncementQueryLength caps the search text, in characters.
+const maxAnnouncementQueryLength = 100
+
+// AnnouncementSearchResponse is one page of announcements matching a search
+type AnnouncementSearchResponse struct {
+	Items    []AnnouncementListItem `json:"items"`
+	Total    int64                  `json:"total"`
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"page_size"`
+}
+
+// search pages through the course's announcements whose title or content
+// contains query, pinned first. Unlike the plain list it requires course
+// access: admins, the course teacher and enrolled students.
+func (h *announcementHandlers) search(c *gin.Context, courseID, userID uint, query string) {
+	if utf8.RuneCountInString(query) > maxAnnouncementQueryLength {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", fmt.Sprintf("q must be at most %d characters", maxAnnouncementQueryLength), nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var course models.Course
+	if err := h.db.WithContext(ctx).First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to search announcements", nil)
+		return
+	}
+	if !authorizeCourseAccess(c, h.db, &course) {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	announcements, total, err := h.repo.SearchByCourse(ctx, courseID, query, (page-1)*pageSize, pageSize)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to search announcements", nil)
+		return
+	}
+	items, err := h.listItems(ctx, userID, announcements)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to search announcements", nil)
+		return
+	}
+
+	respondOK(c, AnnouncementSearchResponse{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// listItems pairs announcements with the user's read state.
+func (h *announcementHandlers) listItems(ctx context.Context, userID uint, announcements []models.Announcement) ([]AnnouncementListItem, error) {
 	announcementIDs := make([]uint, len(announcements))
 	for i, a := range announcements {
 		announcementIDs[i] = a.ID
 	}
-	h.db.Where("announcement_id IN ? AND user_id = ?", announcementIDs, userID).Find(&readRecords)
-
-	readMap := make(map[uint]bool)
-	for _, r := range readRecords {
-		readMap[r.AnnouncementID] = true
+	readIDs, err := h.repo.ListReadIDs(ctx, userID, announcementIDs)
+	if err != nil {
+		return nil, err
+	}
+	readMap := make(map[uint]bool, len(readIDs))
+	for _, id := range readIDs {
+		readMap[id] = true
 	}
 
 	result := make([]AnnouncementListItem, len(announcements))
@@ -135,10 +225,10 @@ func (h *announcementHandlers) List(c *gin.Context) {
 			Content:   a.Content,
 			CreatedAt: a.CreatedAt,
 			IsRead:    readMap[a.ID],
+			Pinned:    a.Pinned,
 		}
 	}
-
-	respondOK(c, result)
+	return result, nil
 }
 
 // --- Create ---
@@ -177,7 +267,7 @@ func (h *announcementHandlers) Create(c *gin.Context) {
 		CreatedByID: userID,
 	}
 
-	if err := h.db.Create(&announcement).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&announcement).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create announcement", nil)
 		return
 	}
@@ -208,7 +298,7 @@ func (h *announcementHandlers) Update(c *gin.Context) {
 	}
 
 	var announcement models.Announcement
-	if err := h.db.First(&announcement, announcementID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&announcement, announcementID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "announcement not found", nil)
 		return
 	}
@@ -221,12 +311,12 @@ func (h *announcementHandlers) Update(c *gin.Context) {
 		updates["content"] = req.Content
 	}
 
-	if err := h.db.Model(&announcement).Updates(updates).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&announcement).Updates(updates).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update announcement", nil)
 		return
 	}
 
-	h.db.First(&announcement, announcementID)
+	h.db.WithContext(c.Request.Context()).First(&announcement, announcementID)
 	respondOK(c, announcement)
 }
 
@@ -242,9 +332,9 @@ func (h *announcementHandlers) Delete(c *gin.Context) {
 	}
 
 	// Delete read records first
-	h.db.Where("announcement_id = ?", announcementID).Delete(&models.AnnouncementRead{})
+	h.db.WithContext(c.Request.Context()).Where("announcement_id = ?", announcementID).Delete(&models.AnnouncementRead{})
 
-	if err := h.db.Delete(&models.Announcement{}, announcementID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Delete(&models.Announcement{}, announcementID).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to delete announcement", nil)
 		return
 	}
@@ -252,6 +342,78 @@ func (h *announcementHandlers) Delete(c *gin.Context) {
 	respondOK(c, gin.H{"message": "deleted"})
 }
 
+// --- Pin ---
+
+// Pin keeps an announcement at the top of its course's list; at most
+// maxPinned announcements per course may be pinned
+// POST /announcements/:id/pin
+func (h *announcementHandlers) Pin(c *gin.Context) {
+	h.setPinned(c, true)
+}
+
+// Unpin returns an announcement to date order
+// POST /announcements/:id/unpin
+func (h *announcementHandlers) Unpin(c *gin.Context) {
+	h.setPinned(c, false)
+}
+
+// setPinned is shared by Pin and Unpin. Only the author, the course teacher
+// or an admin may change it.
+func (h *announcementHandlers) setPinned(c *gin.Context, pinned bool) {
+	announcementID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid announcement id", nil)
+		return
+	}
+
+	userCtx, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var announcement models.Announcement
+	if err := h.db.WithContext(ctx).First(&announcement, announcementID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "announcement not found", nil)
+		return
+	}
+
+	if userCtx.Role != "admin" && announcement.CreatedByID != userCtx.ID {
+		var course models.Course
+		if err := h.db.WithContext(ctx).First(&course, announcement.CourseID).Error; err != nil || course.TeacherID != userCtx.ID {
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "only the author or course teacher can pin announcements", nil)
+			return
+		}
+	}
+
+	if announcement.Pinned == pinned {
+		respondOK(c, announcement)
+		return
+	}
+
+	if pinned {
+		var pinnedCount int64
+		if err := h.db.WithContext(ctx).Model(&models.Announcement{}).
+			Where("course_id = ? AND pinned = ?", announcement.CourseID, true).
+			Count(&pinnedCount).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to pin announcement", nil)
+			return
+		}
+		if int(pinnedCount) >= h.maxPinned {
+			respondError(c, http.StatusConflict, "PIN_LIMIT_REACHED", fmt.Sprintf("at most %d announcements can be pinned", h.maxPinned), gin.H{"limit": h.maxPinned})
+			return
+		}
+	}
+
+	if err := h.db.WithContext(ctx).Model(&announcement).Update("pinned", pinned).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update announcement", nil)
+		return
+	}
+
+	respondOK(c, announcement)
+}
+
 // --- Mark Read ---
 
 // MarkRead marks an announcement as read for the current user
@@ -272,7 +434,7 @@ func (h *announcementHandlers) MarkRead(c *gin.Context) {
 
 	// Check if announcement exists
 	var announcement models.Announcement
-	if err := h.db.First(&announcement, announcementID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&announcement, announcementID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "announcement not found", nil)
 		return
 	}
@@ -285,7 +447,7 @@ func (h *announcementHandlers) MarkRead(c *gin.Context) {
 	}
 
 	// Try to create, ignore duplicate key error
-	if err := h.db.Create(&readRecord).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&readRecord).Error; err != nil {
 		// If duplicate, it's already read - that's fine
 		if !isDuplicateKeyError(err) {
 			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to mark as read", nil)
@@ -296,6 +458,45 @@ func (h *announcementHandlers) MarkRead(c *gin.Context) {
 	respondOK(c, gin.H{"success": true})
 }
 
+// MarkAllRead marks every announcement in a course as read for the current
+// user and returns how many were newly marked; repeating it marks none
+// POST /courses/:courseId/announcements/read-all
+func (h *announcementHandlers) MarkAllRead(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+
+	userCtx, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var course models.Course
+	if err := h.db.WithContext(ctx).First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to mark as read", nil)
+		return
+	}
+	if !authorizeCourseAccess(c, h.db, &course) {
+		return
+	}
+
+	marked, err := h.repo.MarkAllReadByCourse(ctx, course.ID, userCtx.ID, time.Now())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to mark as read", nil)
+		return
+	}
+
+	respondOK(c, gin.H{"marked": marked})
+}
+
 // isDuplicateKeyError checks if error is a duplicate key constraint violation
 func isDuplicateKeyError(err error) bool {
 	if err == nil {
@@ -0,0 +1,217 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupAnnouncementTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Course{},
+		&models.CourseEnrollment{},
+		&models.Announcement{},
+		&models.AnnouncementRead{},
+	)
+	assert.NoError(t, err)
+
+	return db
+}
+
+func setupAnnouncementRouter(db *gorm.DB, jwtSecret string, maxPinned int) *gin.Engine {
+	hAnnouncement := newAnnouncementHandlers(db, maxPinned)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
+	{
+		api.GET("/courses/:courseId/announcements", hAnnouncement.List)
+		api.POST("/announcements/:id/pin", hAnnouncement.Pin)
+		api.POST("/announcements/:id/unpin", hAnnouncement.Unpin)
+		api.POST("/courses/:courseId/announcements/read-all", hAnnouncement.MarkAllRead)
+	}
+
+	return r
+}
+
+func TestAnnouncementPin_OrderingLimitAndOwnership(t *testing.T) {
+	db := setupAnnouncementTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	base := time.Now().Add(-time.Hour)
+	for i, title := range []string{"oldest", "middle", "newest"} {
+		a := models.Announcement{CourseID: course.ID, Title: title, Content: "c", CreatedByID: teacher.ID}
+		a.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		db.Create(&a)
+	}
+
+	r := setupAnnouncementRouter(db, "test-secret", 1)
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(token, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	titles := func() []string {
+		w := do(token, http.MethodGet, "/api/v1/courses/1/announcements")
+		var resp envelope[[]AnnouncementListItem]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		var out []string
+		for _, a := range resp.Data {
+			out = append(out, a.Title)
+		}
+		return out
+	}
+
+	// Another course's teacher may not pin.
+	other := loginAndGetToken(t, r, "teacher2", "pass123")
+	assert.Equal(t, http.StatusForbidden, do(other, http.MethodPost, "/api/v1/announcements/1/pin").Code)
+
+	assert.Equal(t, http.StatusOK, do(token, http.MethodPost, "/api/v1/announcements/1/pin").Code)
+	assert.Equal(t, []string{"oldest", "newest", "middle"}, titles())
+
+	// Re-pinning is a no-op; a second pin exceeds the limit.
+	assert.Equal(t, http.StatusOK, do(token, http.MethodPost, "/api/v1/announcements/1/pin").Code)
+	w := do(token, http.MethodPost, "/api/v1/announcements/2/pin")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "PIN_LIMIT_REACHED")
+
+	assert.Equal(t, http.StatusOK, do(token, http.MethodPost, "/api/v1/announcements/1/unpin").Code)
+	assert.Equal(t, http.StatusOK, do(token, http.MethodPost, "/api/v1/announcements/2/pin").Code)
+	assert.Equal(t, []string{"middle", "newest", "oldest"}, titles())
+}
+
+func TestAnnouncementSearch_FiltersPagesAndChecksMembership(t *testing.T) {
+	db := setupAnnouncementTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	createCourseTestUser(t, db, "outsider", "pass123", "student")
+	coTeacher := createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher3", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: coTeacher.ID, Role: "assistant"})
+	base := time.Now().Add(-time.Hour)
+	for i, a := range []models.Announcement{
+		{Title: "Midterm room", Content: "Room 101"},
+		{Title: "Lab moved", Content: "The midterm review is on Friday"},
+		{Title: "Grades", Content: "Scores are 100% final"},
+		{Title: "Midterm results", Content: "Posted"},
+	} {
+		a.CourseID, a.CreatedByID = course.ID, teacher.ID
+		a.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		db.Create(&a)
+	}
+	db.Create(&models.AnnouncementRead{AnnouncementID: 2, UserID: student.ID, ReadAt: time.Now()})
+
+	r := setupAnnouncementRouter(db, "test-secret", 1)
+	search := func(username, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/courses/1/announcements?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := search("student1", "q=midterm&page_size=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[AnnouncementSearchResponse]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(3), resp.Data.Total)
+	assert.Equal(t, 2, resp.Data.PageSize)
+	if assert.Len(t, resp.Data.Items, 2) {
+		assert.Equal(t, "Midterm results", resp.Data.Items[0].Title)
+		assert.Equal(t, "Lab moved", resp.Data.Items[1].Title)
+		assert.True(t, resp.Data.Items[1].IsRead)
+	}
+
+	w = search("student1", "q=midterm&page=2&page_size=2")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Items, 1) {
+		assert.Equal(t, "Midterm room", resp.Data.Items[0].Title)
+		assert.False(t, resp.Data.Items[0].IsRead)
+	}
+
+	// Wildcards in the query match literally.
+	w = search("teacher1", "q=_oom")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Data.Items)
+	w = search("teacher1", "q=100%25")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Items, 1) {
+		assert.Equal(t, "Grades", resp.Data.Items[0].Title)
+	}
+
+	assert.Equal(t, http.StatusForbidden, search("outsider", "q=midterm").Code)
+	// Teachers other than the owner get in as assistants, and only then.
+	assert.Equal(t, http.StatusOK, search("teacher2", "q=midterm").Code)
+	assert.Equal(t, http.StatusForbidden, search("teacher3", "q=midterm").Code)
+}
+
+func TestAnnouncementMarkAllRead_Idempotent(t *testing.T) {
+	db := setupAnnouncementTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	createCourseTestUser(t, db, "outsider", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	other := models.Course{Name: "Other Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&other)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID, Role: "student"})
+	for _, courseID := range []uint{course.ID, course.ID, course.ID, other.ID} {
+		db.Create(&models.Announcement{CourseID: courseID, Title: "t", Content: "c", CreatedByID: teacher.ID})
+	}
+	db.Create(&models.AnnouncementRead{AnnouncementID: 1, UserID: student.ID, ReadAt: time.Now()})
+
+	r := setupAnnouncementRouter(db, "test-secret", 1)
+	markAll := func(username string) (int, int64) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/courses/1/announcements/read-all", nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp envelope[struct {
+			Marked int64 `json:"marked"`
+		}]
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data.Marked
+	}
+
+	code, marked := markAll("student1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(2), marked)
+	code, marked = markAll("student1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(0), marked)
+
+	var reads int64
+	db.Model(&models.AnnouncementRead{}).Where("user_id = ?", student.ID).Count(&reads)
+	assert.Equal(t, int64(3), reads)
+
+	code, _ = markAll("outsider")
+	assert.Equal(t, http.StatusForbidden, code)
+}
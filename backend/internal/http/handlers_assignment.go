@@ -7,7 +7,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"gorm.io/gorm"
 )
@@ -16,13 +18,18 @@ type assignmentHandlers struct {
 	db       *gorm.DB
 	aiClient *clients.AIClient
 	service  *services.AssignmentService
+	files    submissionFileStore // nil when MinIO is not configured
 }
 
-func newAssignmentHandlers(db *gorm.DB, aiClient *clients.AIClient) *assignmentHandlers {
+func newAssignmentHandlers(db *gorm.DB, aiClient *clients.AIClient, notifier *clients.Notifier, queue *jobs.Queue) *assignmentHandlers {
+	service := services.NewAssignmentService(db).WithQueue(queue)
+	if notifier.Enabled() {
+		service = service.WithGradeNotifier(notifier, queue).WithCommentNotifier(notifier, queue)
+	}
 	return &assignmentHandlers{
 		db:       db,
 		aiClient: aiClient,
-		service:  services.NewAssignmentService(db),
+		service:  service,
 	}
 }
 
@@ -30,16 +37,24 @@ func newAssignmentHandlers(db *gorm.DB, aiClient *clients.AIClient) *assignmentH
 
 type createAssignmentRequest struct {
 	CourseID    uint   `json:"course_id" binding:"required"`
+	ChapterID   *uint  `json:"chapter_id"` // optional, a chapter of the same course
 	Title       string `json:"title" binding:"required"`
 	Description string `json:"description"`
-	Deadline    string `json:"deadline"` // ISO8601 format
+	Deadline    string `json:"deadline"` // RFC 3339, or YYYY-MM-DDTHH:MM[:SS] in the course's time zone
 	AllowFile   bool   `json:"allow_file"`
+	// GroupSubmission takes one shared submission per student group
+	GroupSubmission bool `json:"group_submission"`
+	// Late policy: accept work after the deadline, taking points off per
+	// started day late down to a floor. Omitted means late work is rejected.
+	AllowLate         bool `json:"allow_late"`
+	LatePenaltyPerDay int  `json:"late_penalty_per_day"`
+	LatePenaltyFloor  int  `json:"late_penalty_floor"`
 }
 
 func (h *assignmentHandlers) CreateAssignment(c *gin.Context) {
 	var req createAssignmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request", nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 	// If courseId is provided in path, enforce consistency
@@ -57,6 +72,16 @@ func (h *assignmentHandlers) CreateAssignment(c *gin.Context) {
 		}
 	}
 
+	var deadline *services.ScheduleTime
+	if req.Deadline != "" {
+		parsed, err := services.ParseScheduleTime(req.Deadline)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "deadline must be RFC 3339 or YYYY-MM-DDTHH:MM[:SS]", nil)
+			return
+		}
+		deadline = &parsed
+	}
+
 	// Get current user from context (set by AuthRequired middleware)
 	user, ok := middleware.GetUser(c)
 	if !ok {
@@ -69,11 +94,27 @@ func (h *assignmentHandlers) CreateAssignment(c *gin.Context) {
 		Role: user.Role,
 	}, services.CreateAssignmentRequest{
 		CourseID:    req.CourseID,
+		ChapterID:   req.ChapterID,
 		Title:       req.Title,
 		Description: req.Description,
+		Deadline:    deadline,
 		AllowFile:   req.AllowFile,
+
+		GroupSubmission: req.GroupSubmission,
+
+		AllowLate:         req.AllowLate,
+		LatePenaltyPerDay: req.LatePenaltyPerDay,
+		LatePenaltyFloor:  req.LatePenaltyFloor,
 	})
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidLatePolicy) {
+			respondError(c, http.StatusBadRequest, "INVALID_LATE_POLICY", "late penalties must be 0-100 points and need allow_late", nil)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidChapter) {
+			respondError(c, http.StatusBadRequest, "INVALID_CHAPTER", "chapter must belong to the same course", nil)
+			return
+		}
 		if errors.Is(err, services.ErrCourseNotFound) {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
 			return
@@ -97,7 +138,11 @@ func (h *assignmentHandlers) ListAssignments(c *gin.Context) {
 		return
 	}
 
-	assignments, err := h.service.ListAssignments(c.Request.Context(), uint(courseID))
+	user, _ := middleware.GetUser(c)
+	assignments, err := h.service.ListAssignments(c.Request.Context(), uint(courseID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list assignments", nil)
 		return
@@ -114,9 +159,13 @@ func (h *assignmentHandlers) GetAssignment(c *gin.Context) {
 		return
 	}
 
-	assignment, err := h.service.GetAssignment(c.Request.Context(), uint(id))
+	user, _ := middleware.GetUser(c)
+	assignment, err := h.service.GetAssignment(c.Request.Context(), uint(id), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
 	if err != nil {
-		if errors.Is(err, services.ErrAssignmentNotFound) {
+		if errors.Is(err, services.ErrAssignmentNotFound) || errors.Is(err, services.ErrAssignmentNotAvailable) {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
 			return
 		}
@@ -127,6 +176,244 @@ func (h *assignmentHandlers) GetAssignment(c *gin.Context) {
 	respondOK(c, assignment)
 }
 
+// PublishAssignment makes a draft assignment visible to students
+// POST /assignments/:id/publish
+func (h *assignmentHandlers) PublishAssignment(c *gin.Context) {
+	h.setPublished(c, true)
+}
+
+// UnpublishAssignment returns an assignment to draft (only before any submission)
+// POST /assignments/:id/unpublish
+func (h *assignmentHandlers) UnpublishAssignment(c *gin.Context) {
+	h.setPublished(c, false)
+}
+
+func (h *assignmentHandlers) setPublished(c *gin.Context, publish bool) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	userInfo := services.UserInfo{ID: user.ID, Role: user.Role}
+	var assignment *models.Assignment
+	if publish {
+		assignment, err = h.service.PublishAssignment(c.Request.Context(), uint(id), userInfo)
+	} else {
+		assignment, err = h.service.UnpublishAssignment(c.Request.Context(), uint(id), userInfo)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAssignmentNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		case errors.Is(err, services.ErrAssignmentUnpublishNotAllowed):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "cannot unpublish: students have already submitted", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update assignment", nil)
+		}
+		return
+	}
+	respondOK(c, assignment)
+}
+
+// MoveAssignmentToChapter attaches the assignment to another chapter of its
+// course, or detaches it with a null chapter_id
+// PUT /assignments/:id/chapter
+func (h *assignmentHandlers) MoveAssignmentToChapter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid id", nil)
+		return
+	}
+	chapterID, ok := bindChapterMove(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	assignment, err := h.service.MoveAssignmentToChapter(c.Request.Context(), uint(id), services.UserInfo{ID: user.ID, Role: user.Role}, chapterID)
+	if err != nil {
+		if errors.Is(err, services.ErrAssignmentNotFound) {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
+			return
+		}
+		if respondChapterMoveError(c, err) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update assignment", nil)
+		return
+	}
+	respondOK(c, assignment)
+}
+
+// --- Attachments ---
+
+type addAttachmentRequest struct {
+	Title string `json:"title" binding:"required"`
+	URL   string `json:"url" binding:"required"`
+}
+
+// AddAttachment attaches a reference file to an assignment
+// POST /assignments/:id/attachments
+func (h *assignmentHandlers) AddAttachment(c *gin.Context) {
+	idStr := c.Param("id")
+	assignmentID, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid assignment id", nil)
+		return
+	}
+
+	var req addAttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	attachment, err := h.service.AddAttachment(c.Request.Context(), uint(assignmentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, services.AddAttachmentRequest{
+		Title: req.Title,
+		URL:   req.URL,
+	})
+	if err != nil {
+		h.respondAttachmentError(c, err, "failed to add attachment")
+		return
+	}
+	respondCreated(c, attachment)
+}
+
+// RemoveAttachment deletes an attachment from an assignment
+// DELETE /assignments/:id/attachments/:attachmentId
+func (h *assignmentHandlers) RemoveAttachment(c *gin.Context) {
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid assignment id", nil)
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid attachment id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	if err := h.service.RemoveAttachment(c.Request.Context(), uint(assignmentID), uint(attachmentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}); err != nil {
+		h.respondAttachmentError(c, err, "failed to remove attachment")
+		return
+	}
+	respondOK(c, gin.H{"message": "attachment removed"})
+}
+
+func (h *assignmentHandlers) respondAttachmentError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAttachment):
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "title is required and url must be an http(s) URL", nil)
+	case errors.Is(err, services.ErrAssignmentNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
+	case errors.Is(err, services.ErrAttachmentNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "attachment not found", nil)
+	case errors.Is(err, services.ErrCourseNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+	case errors.Is(err, services.ErrAccessDenied):
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+	default:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", fallback, nil)
+	}
+}
+
+// --- Deadline extensions ---
+
+type grantExtensionRequest struct {
+	Deadline *services.ScheduleTime `json:"deadline" binding:"required"`
+}
+
+// GrantExtension sets a per-student deadline override
+// PUT /assignments/:id/extensions/:studentId
+func (h *assignmentHandlers) GrantExtension(c *gin.Context) {
+	assignmentID, studentID, ok := parseExtensionParams(c)
+	if !ok {
+		return
+	}
+
+	var req grantExtensionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	extension, err := h.service.GrantExtension(c.Request.Context(), assignmentID, studentID, services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, *req.Deadline)
+	if err != nil {
+		respondExtensionError(c, err, "failed to grant extension")
+		return
+	}
+	respondOK(c, extension)
+}
+
+// RevokeExtension removes a student's deadline override
+// DELETE /assignments/:id/extensions/:studentId
+func (h *assignmentHandlers) RevokeExtension(c *gin.Context) {
+	assignmentID, studentID, ok := parseExtensionParams(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	if err := h.service.RevokeExtension(c.Request.Context(), assignmentID, studentID, services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}); err != nil {
+		respondExtensionError(c, err, "failed to revoke extension")
+		return
+	}
+	respondOK(c, gin.H{"message": "extension revoked"})
+}
+
+func parseExtensionParams(c *gin.Context) (uint, uint, bool) {
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid assignment id", nil)
+		return 0, 0, false
+	}
+	studentID, err := strconv.ParseUint(c.Param("studentId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid student id", nil)
+		return 0, 0, false
+	}
+	return uint(assignmentID), uint(studentID), true
+}
+
+func respondExtensionError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrAssignmentNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
+	case errors.Is(err, services.ErrExtensionNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "extension not found", nil)
+	case errors.Is(err, services.ErrCourseNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+	case errors.Is(err, services.ErrAccessDenied):
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+	case errors.Is(err, services.ErrStudentNotInCourse):
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "student is not enrolled in this course", nil)
+	default:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", fallback, nil)
+	}
+}
+
 // --- Submission ---
 
 type submitRequest struct {
@@ -144,7 +431,7 @@ func (h *assignmentHandlers) SubmitAssignment(c *gin.Context) {
 
 	var req submitRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request", nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 
@@ -166,14 +453,31 @@ func (h *assignmentHandlers) SubmitAssignment(c *gin.Context) {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
 			return
 		}
+		if errors.Is(err, services.ErrAssignmentNotAvailable) {
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "assignment not available", nil)
+			return
+		}
+		if errors.Is(err, services.ErrAssignmentDeadlinePassed) {
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "assignment deadline has passed", nil)
+			return
+		}
+		if errors.Is(err, services.ErrNotInGroup) {
+			respondError(c, http.StatusForbidden, "NOT_IN_GROUP", "join a group before submitting this group assignment", nil)
+			return
+		}
+		if errors.Is(err, services.ErrSubmittedWithOtherGroup) {
+			respondError(c, http.StatusConflict, "CONFLICT", "you already submitted this assignment with another group", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to submit assignment", nil)
 		return
 	}
+	resp := submitResponse{Submission: submission, Receipt: h.service.IssueReceipt(submission, user.ID)}
 	if created {
-		respondCreated(c, submission)
+		respondCreated(c, resp)
 		return
 	}
-	respondOK(c, submission)
+	respondOK(c, resp)
 }
 
 // GetMySubmission returns the current user's submission for an assignment
@@ -224,7 +528,7 @@ func (h *assignmentHandlers) GradeSubmission(c *gin.Context) {
 
 	var req gradeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request", nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 
@@ -255,6 +559,10 @@ func (h *assignmentHandlers) GradeSubmission(c *gin.Context) {
 			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not authorized to grade this submission", nil)
 			return
 		}
+		if errors.Is(err, services.ErrSelfGrading) {
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you cannot grade your own submission", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to save grade", nil)
 		return
 	}
@@ -317,10 +625,18 @@ func (h *assignmentHandlers) AIGradeSubmission(c *gin.Context) {
 			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not authorized to grade this submission", nil)
 			return
 		}
+		if errors.Is(err, services.ErrSelfGrading) {
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you cannot grade your own submission", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load submission", nil)
 		return
 	}
 
+	if !requireAI(c, h.aiClient) {
+		return
+	}
+
 	// Build prompt for AI
 	prompt := "请评阅以下学生作业并给出评分建议（0-100分）和详细反馈。\n\n"
 	prompt += "作业题目: " + ctxData.Assignment.Title + "\n"
@@ -342,9 +658,17 @@ func (h *assignmentHandlers) AIGradeSubmission(c *gin.Context) {
 		return
 	}
 
+	submission, err := h.service.SaveAISuggestion(c.Request.Context(), ctxData, aiResponse.Reply)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to save AI suggestion", nil)
+		return
+	}
+
+	// The grade itself is still left to the teacher
 	respondOK(c, gin.H{
-		"suggestion":        aiResponse.Reply,
-		"recommended_grade": nil, // Let teacher decide based on AI suggestion
+		"suggestion":        submission.AISuggestion,
+		"recommended_grade": submission.AISuggestedGrade,
+		"ai_graded_at":      submission.AIGradedAt,
 	})
 }
 
@@ -413,3 +737,97 @@ func (h *assignmentHandlers) GetAssignmentStats(c *gin.Context) {
 
 	respondOK(c, stats)
 }
+
+// --- Similarity ---
+
+type similarityRequest struct {
+	Threshold *float64 `json:"threshold"`
+}
+
+// CheckSimilarity compares the text of all submissions to an assignment and
+// reports pairs above a similarity threshold (default 0.5). Large classes are
+// checked in the background: the response is then 202 with a pending report
+// to be polled with GetSimilarityReport
+// POST /assignments/:id/similarity
+func (h *assignmentHandlers) CheckSimilarity(c *gin.Context) {
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid assignment id", nil)
+		return
+	}
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "user not authenticated", nil)
+		return
+	}
+
+	var req similarityRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, "BAD_REQUEST", err)
+			return
+		}
+	}
+	threshold := services.DefaultSimilarityThreshold
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+
+	report, err := h.service.CheckSimilarity(c.Request.Context(), uint(assignmentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, threshold)
+	if err != nil {
+		respondSimilarityError(c, err, "failed to check similarity")
+		return
+	}
+	if report.Status == services.SimilarityPending {
+		respondAccepted(c, report)
+		return
+	}
+	respondOK(c, report)
+}
+
+// GetSimilarityReport returns the latest similarity report for an assignment
+// GET /assignments/:id/similarity
+func (h *assignmentHandlers) GetSimilarityReport(c *gin.Context) {
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid assignment id", nil)
+		return
+	}
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "user not authenticated", nil)
+		return
+	}
+
+	report, err := h.service.GetSimilarityReport(c.Request.Context(), uint(assignmentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		respondSimilarityError(c, err, "failed to load similarity report")
+		return
+	}
+	respondOK(c, report)
+}
+
+func respondSimilarityError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidThreshold):
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", err.Error(), nil)
+	case errors.Is(err, services.ErrAssignmentNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
+	case errors.Is(err, services.ErrCourseNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+	case errors.Is(err, services.ErrSimilarityReportNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "no similarity check has been run", nil)
+	case errors.Is(err, services.ErrAccessDenied):
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "only the course teacher can check similarity", nil)
+	case errors.Is(err, services.ErrSimilarityBusy):
+		respondError(c, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "similarity check queue is busy, try again later", nil)
+	default:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", fallback, nil)
+	}
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+type groupRequest struct {
+	Name      string `json:"name" binding:"required"`
+	MemberIDs []uint `json:"member_ids"`
+}
+
+// ListGroups returns the student groups of a course
+// GET /courses/:courseId/groups
+func (h *assignmentHandlers) ListGroups(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	groups, err := h.service.ListGroups(c.Request.Context(), uint(courseID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		respondGroupError(c, err, "failed to list groups")
+		return
+	}
+	respondOK(c, groups)
+}
+
+// CreateGroup adds a student group to a course
+// POST /courses/:courseId/groups
+func (h *assignmentHandlers) CreateGroup(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+
+	var req groupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	group, err := h.service.CreateGroup(c.Request.Context(), uint(courseID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, services.GroupRequest{
+		Name:      req.Name,
+		MemberIDs: req.MemberIDs,
+	})
+	if err != nil {
+		respondGroupError(c, err, "failed to create group")
+		return
+	}
+	respondCreated(c, group)
+}
+
+// UpdateGroup renames a group and replaces its members
+// PUT /groups/:id
+func (h *assignmentHandlers) UpdateGroup(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid group id", nil)
+		return
+	}
+
+	var req groupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	group, err := h.service.UpdateGroup(c.Request.Context(), uint(groupID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, services.GroupRequest{
+		Name:      req.Name,
+		MemberIDs: req.MemberIDs,
+	})
+	if err != nil {
+		respondGroupError(c, err, "failed to update group")
+		return
+	}
+	respondOK(c, group)
+}
+
+// DeleteGroup removes a group that has not submitted anything
+// DELETE /groups/:id
+func (h *assignmentHandlers) DeleteGroup(c *gin.Context) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid group id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	if err := h.service.DeleteGroup(c.Request.Context(), uint(groupID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}); err != nil {
+		respondGroupError(c, err, "failed to delete group")
+		return
+	}
+	respondOK(c, gin.H{"message": "group deleted"})
+}
+
+func respondGroupError(c *gin.Context, err error, fallback string) {
+	var membership *services.GroupMembershipError
+	switch {
+	case errors.As(err, &membership):
+		respondError(c, http.StatusConflict, "ALREADY_IN_GROUP", "students already belong to another group", gin.H{"student_ids": membership.StudentIDs})
+	case errors.Is(err, services.ErrInvalidGroupName):
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "group name must be 1-128 characters", nil)
+	case errors.Is(err, services.ErrStudentNotInCourse):
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "every member must be a student enrolled in this course", nil)
+	case errors.Is(err, services.ErrGroupHasSubmissions):
+		respondError(c, http.StatusConflict, "GROUP_HAS_SUBMISSIONS", "group has submissions and cannot be deleted", nil)
+	case errors.Is(err, services.ErrGroupNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "group not found", nil)
+	case errors.Is(err, services.ErrCourseNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+	case errors.Is(err, services.ErrAccessDenied):
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+	default:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", fallback, nil)
+	}
+}
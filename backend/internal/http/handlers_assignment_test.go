@@ -1,17 +1,31 @@
 package http
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -23,8 +37,16 @@ func setupAssignmentTestDB(t *testing.T) *gorm.DB {
 		&models.User{},
 		&models.Course{},
 		&models.CourseEnrollment{},
+		&models.Chapter{},
 		&models.Assignment{},
+		&models.AssignmentAttachment{},
+		&models.AssignmentExtension{},
+		&models.AuditLog{},
 		&models.Submission{},
+		&models.SubmissionComment{},
+		&models.SimilarityReport{},
+		&models.StudentGroup{},
+		&models.StudentGroupMember{},
 	)
 	assert.NoError(t, err)
 
@@ -32,18 +54,39 @@ func setupAssignmentTestDB(t *testing.T) *gorm.DB {
 }
 
 func setupAssignmentRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
-	hAssignment := newAssignmentHandlers(db, nil)
-	hAuth := newAuthHandlers(db, jwtSecret)
+	hAssignment := newAssignmentHandlers(db, nil, nil, nil)
+	hAssignment.service.WithReceiptSigner(services.NewReceiptSigner(jwtSecret))
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
 
 	r := gin.New()
 	r.POST("/auth/login", hAuth.Login)
 
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthRequired(jwtSecret))
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
 	{
 		api.GET("/courses/:courseId/assignments", hAssignment.ListAssignments)
+		api.POST("/courses/:courseId/assignments", hAssignment.CreateAssignment)
+		api.GET("/courses/:courseId/assignments/stats", hAssignment.GetCourseAssignmentStats)
+		api.GET("/assignments/:id/stats", hAssignment.GetAssignmentStats)
 		api.POST("/assignments/:id/submit", hAssignment.SubmitAssignment)
+		api.POST("/assignments/:id/publish", hAssignment.PublishAssignment)
+		api.POST("/assignments/:id/unpublish", hAssignment.UnpublishAssignment)
+		api.PUT("/assignments/:id/chapter", hAssignment.MoveAssignmentToChapter)
+		api.GET("/assignments/:id", hAssignment.GetAssignment)
+		api.POST("/assignments/:id/attachments", hAssignment.AddAttachment)
+		api.DELETE("/assignments/:id/attachments/:attachmentId", hAssignment.RemoveAttachment)
+		api.PUT("/assignments/:id/extensions/:studentId", hAssignment.GrantExtension)
+		api.DELETE("/assignments/:id/extensions/:studentId", hAssignment.RevokeExtension)
 		api.POST("/submissions/:submissionId/grade", hAssignment.GradeSubmission)
+		api.POST("/submissions/:submissionId/ai-grade", hAssignment.AIGradeSubmission)
+		api.GET("/submissions/:submissionId/comments", hAssignment.ListSubmissionComments)
+		api.POST("/submissions/:submissionId/comments", hAssignment.AddSubmissionComment)
+		api.GET("/submissions/:submissionId/verify-receipt", hAssignment.VerifyReceipt)
+		api.GET("/assignments/:id/my-submission", hAssignment.GetMySubmission)
+		api.GET("/courses/:courseId/groups", hAssignment.ListGroups)
+		api.POST("/courses/:courseId/groups", hAssignment.CreateGroup)
+		api.PUT("/groups/:id", hAssignment.UpdateGroup)
+		api.DELETE("/groups/:id", hAssignment.DeleteGroup)
 	}
 
 	return r
@@ -83,7 +126,7 @@ func TestSubmitAssignment_Success(t *testing.T) {
 	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
 	db.Create(&course)
 	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
-	db.Create(&models.Assignment{CourseID: course.ID, Title: "Homework 1"})
+	db.Create(&models.Assignment{CourseID: course.ID, Title: "Homework 1", IsPublished: true})
 
 	r := setupAssignmentRouter(db, "test-secret")
 	token := loginAndGetToken(t, r, "student1", "pass123")
@@ -136,4 +179,1088 @@ func TestGradeSubmission_Success(t *testing.T) {
 	var resp envelope[interface{}]
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
 	assert.True(t, resp.Success)
+
+	// Changing an existing grade is an override and is audited.
+	var logs []models.AuditLog
+	db.Find(&logs)
+	assert.Empty(t, logs)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/submissions/1/grade", bytes.NewReader([]byte(`{"grade":90,"feedback":"Rechecked"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.Find(&logs)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, "submission.grade_override", logs[0].Action)
+		assert.Equal(t, submission.ID, logs[0].TargetID)
+		assert.Contains(t, string(logs[0].Details), `"previous_grade":85`)
+	}
+}
+
+func TestAssignmentDraftVisibility(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	draft := models.Assignment{CourseID: course.ID, Title: "Draft"}
+	db.Create(&draft)
+
+	r := setupAssignmentRouter(db, "test-secret")
+	studentToken := loginAndGetToken(t, r, "student1", "pass123")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	listLen := func(token string) int {
+		w := do(http.MethodGet, "/api/v1/courses/1/assignments", token, "")
+		var resp envelope[[]models.Assignment]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return len(resp.Data)
+	}
+
+	assert.Equal(t, 0, listLen(studentToken))
+	assert.Equal(t, 1, listLen(teacherToken))
+
+	w := do(http.MethodPost, "/api/v1/assignments/1/submit", studentToken, `{"content":"early"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPost, "/api/v1/assignments/1/publish", studentToken, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPost, "/api/v1/assignments/1/publish", teacherToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, listLen(studentToken))
+
+	w = do(http.MethodPost, "/api/v1/assignments/1/submit", studentToken, `{"content":"done"}`)
+	assert.True(t, w.Code == http.StatusOK || w.Code == http.StatusCreated)
+
+	w = do(http.MethodPost, "/api/v1/assignments/1/unpublish", teacherToken, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAssignmentAttachments(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.Assignment{CourseID: course.ID, Title: "Homework 1", IsPublished: true})
+
+	r := setupAssignmentRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/assignments/1/attachments", token, `{"title":"Notes","url":"javascript:alert(1)"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/api/v1/assignments/1/attachments", otherToken, `{"title":"Notes","url":"https://example.com/notes.pdf"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPost, "/api/v1/assignments/1/attachments", token, `{"title":"Notes","url":"https://example.com/notes.pdf"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(http.MethodGet, "/api/v1/assignments/1", token, "")
+	var resp envelope[models.Assignment]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Attachments, 1) {
+		assert.Equal(t, "https://example.com/notes.pdf", resp.Data.Attachments[0].URL)
+	}
+
+	w = do(http.MethodDelete, "/api/v1/assignments/1/attachments/1", token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(http.MethodDelete, "/api/v1/assignments/1/attachments/1", token, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+type gradeNotification struct {
+	wecomID string
+	title   string
+	grade   int
+}
+
+type fakeGradeNotifier struct {
+	sent chan gradeNotification
+}
+
+func (f *fakeGradeNotifier) NotifyGrade(ctx context.Context, studentWecomID, assignmentTitle string, grade int, feedback string) error {
+	f.sent <- gradeNotification{wecomID: studentWecomID, title: assignmentTitle, grade: grade}
+	return nil
+}
+
+func TestGradeSubmission_NotifiesOptedInCourse(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	db.Model(&student).Update("wecom_user_id", "wx-student1")
+
+	optedIn := models.Course{Name: "Opted In", TeacherID: teacher.ID, EnabledModules: datatypes.JSON(`["notify.wecom"]`)}
+	db.Create(&optedIn)
+	plain := models.Course{Name: "Plain", TeacherID: teacher.ID, EnabledModules: datatypes.JSON(`["core.ai"]`)}
+	db.Create(&plain)
+
+	hw1 := models.Assignment{CourseID: optedIn.ID, Title: "Homework 1"}
+	db.Create(&hw1)
+	hw2 := models.Assignment{CourseID: plain.ID, Title: "Homework 2"}
+	db.Create(&hw2)
+	db.Create(&models.Submission{AssignmentID: hw1.ID, StudentID: student.ID, Content: "a"})
+	db.Create(&models.Submission{AssignmentID: hw2.ID, StudentID: student.ID, Content: "b"})
+
+	notifier := &fakeGradeNotifier{sent: make(chan gradeNotification, 2)}
+	hAssignment := newAssignmentHandlers(db, nil, nil, nil)
+	queue := jobs.NewQueue(1, 8)
+	t.Cleanup(func() { _ = queue.Shutdown(context.Background()) })
+	hAssignment.service = services.NewAssignmentService(db).WithGradeNotifier(notifier, queue)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: "test-secret"})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: "test-secret"}))
+	api.POST("/submissions/:submissionId/grade", hAssignment.GradeSubmission)
+
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	for _, id := range []string{"1", "2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/submissions/"+id+"/grade", bytes.NewReader([]byte(`{"grade":90,"feedback":"ok"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	select {
+	case n := <-notifier.sent:
+		assert.Equal(t, gradeNotification{wecomID: "wx-student1", title: "Homework 1", grade: 90}, n)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a grade notification for the opted-in course")
+	}
+	select {
+	case n := <-notifier.sent:
+		t.Fatalf("unexpected notification for course without notify.wecom: %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+type fakeCommentNotifier struct {
+	sent chan string
+}
+
+func (f *fakeCommentNotifier) NotifySubmissionComment(ctx context.Context, recipientWecomID, assignmentTitle, authorName, body string) error {
+	f.sent <- recipientWecomID + ":" + authorName + ":" + body
+	return nil
+}
+
+func TestSubmissionComments_ThreadAccessAndNotify(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	createCourseTestUser(t, db, "student2", "pass123", "student")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	db.Model(&teacher).Update("wecom_user_id", "wx-teacher1")
+	db.Model(&student).Update("wecom_user_id", "wx-student1")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID, EnabledModules: datatypes.JSON(`["notify.wecom"]`)}
+	db.Create(&course)
+	hw := models.Assignment{CourseID: course.ID, Title: "Homework 1", IsPublished: true}
+	db.Create(&hw)
+	db.Create(&models.Submission{AssignmentID: hw.ID, StudentID: student.ID, Content: "a"})
+
+	notifier := &fakeCommentNotifier{sent: make(chan string, 4)}
+	queue := jobs.NewQueue(1, 8)
+	t.Cleanup(func() { _ = queue.Shutdown(context.Background()) })
+	hAssignment := newAssignmentHandlers(db, nil, nil, nil)
+	hAssignment.service = services.NewAssignmentService(db).WithCommentNotifier(notifier, queue)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: "test-secret"})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: "test-secret"}))
+	api.GET("/submissions/:submissionId/comments", hAssignment.ListSubmissionComments)
+	api.POST("/submissions/:submissionId/comments", hAssignment.AddSubmissionComment)
+
+	do := func(username, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/submissions/1/comments", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectNotification := func(want string) {
+		select {
+		case got := <-notifier.sent:
+			assert.Equal(t, want, got)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected notification %q", want)
+		}
+	}
+
+	w := do("student1", http.MethodPost, `{"body":"  Why did I lose points on Q2?  "}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	expectNotification("wx-teacher1:Test student1:Why did I lose points on Q2?")
+
+	w = do("teacher1", http.MethodPost, `{"body":"The units were missing."}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	expectNotification("wx-student1:Test teacher1:The units were missing.")
+
+	assert.Equal(t, http.StatusBadRequest, do("student1", http.MethodPost, `{"body":"   "}`).Code)
+	for _, outsider := range []string{"student2", "teacher2"} {
+		assert.Equal(t, http.StatusForbidden, do(outsider, http.MethodGet, "").Code, outsider)
+		assert.Equal(t, http.StatusForbidden, do(outsider, http.MethodPost, `{"body":"hi"}`).Code, outsider)
+	}
+
+	w = do("student1", http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[[]services.SubmissionCommentView]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data, 2) {
+		assert.Equal(t, "Why did I lose points on Q2?", resp.Data[0].Body)
+		assert.Equal(t, student.ID, resp.Data[0].AuthorID)
+		assert.Equal(t, "Test teacher1", resp.Data[1].AuthorName)
+	}
+}
+
+func TestSubmissionReceipt(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	deadline := time.Now().Add(time.Hour)
+	db.Create(&models.Assignment{CourseID: course.ID, Title: "Homework 1", Deadline: &deadline, IsPublished: true})
+
+	r := setupAssignmentRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	bobToken := loginAndGetToken(t, r, "bob", "pass123")
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	submit := func(content string) services.SubmissionReceipt {
+		w := do(aliceToken, http.MethodPost, "/api/v1/assignments/1/submit", `{"content":"`+content+`"}`)
+		assert.True(t, w.Code == http.StatusOK || w.Code == http.StatusCreated)
+		var resp envelope[struct {
+			models.Submission
+			Receipt *services.SubmissionReceipt `json:"receipt"`
+		}]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, content, resp.Data.Content, "submission fields stay at the top level")
+		if !assert.NotNil(t, resp.Data.Receipt) {
+			t.FailNow()
+		}
+		return *resp.Data.Receipt
+	}
+	verify := func(token string, receipt services.SubmissionReceipt) (int, services.ReceiptVerification) {
+		q := url.Values{}
+		q.Set("assignment_id", strconv.FormatUint(uint64(receipt.AssignmentID), 10))
+		q.Set("student_id", strconv.FormatUint(uint64(receipt.StudentID), 10))
+		q.Set("submitted_at", receipt.SubmittedAt.Format(time.RFC3339Nano))
+		q.Set("content_hash", receipt.ContentHash)
+		q.Set("signature", receipt.Signature)
+		w := do(token, http.MethodGet, "/api/v1/submissions/"+strconv.FormatUint(uint64(receipt.SubmissionID), 10)+"/verify-receipt?"+q.Encode(), "")
+		var resp envelope[services.ReceiptVerification]
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+
+	first := submit("draft one")
+	assert.Equal(t, alice.ID, first.StudentID)
+	assert.Len(t, first.ContentHash, 64)
+
+	code, result := verify(aliceToken, first)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, result.Valid)
+	assert.True(t, result.ContentMatches)
+	if assert.NotNil(t, result.OnTime) {
+		assert.True(t, *result.OnTime)
+	}
+	code, result = verify(teacherToken, first)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, result.Valid)
+	code, _ = verify(bobToken, first)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	forged := first
+	forged.SubmittedAt = forged.SubmittedAt.Add(-time.Hour)
+	_, result = verify(aliceToken, forged)
+	assert.False(t, result.Valid)
+	assert.Nil(t, result.OnTime)
+
+	// Resubmitting keeps the old receipt genuine but no longer current.
+	second := submit("draft two")
+	_, result = verify(aliceToken, first)
+	assert.True(t, result.Valid)
+	assert.False(t, result.ContentMatches)
+	_, result = verify(aliceToken, second)
+	assert.True(t, result.Valid)
+	assert.True(t, result.ContentMatches)
+}
+
+func TestAssignmentExtension(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID})
+	past := time.Now().Add(-time.Hour)
+	db.Create(&models.Assignment{CourseID: course.ID, Title: "Homework 1", Deadline: &past, IsPublished: true})
+
+	r := setupAssignmentRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	bobToken := loginAndGetToken(t, r, "bob", "pass123")
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(aliceToken, http.MethodPost, "/api/v1/assignments/1/submit", `{"content":"late"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	newDeadline := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	w = do(aliceToken, http.MethodPut, "/api/v1/assignments/1/extensions/2", `{"deadline":"`+newDeadline+`"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(teacherToken, http.MethodPut, "/api/v1/assignments/1/extensions/2", `{"deadline":"`+newDeadline+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do(aliceToken, http.MethodGet, "/api/v1/assignments/1", "")
+	var resp envelope[models.Assignment]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.NotNil(t, resp.Data.EffectiveDeadline) {
+		assert.Equal(t, newDeadline, resp.Data.EffectiveDeadline.UTC().Format(time.RFC3339))
+	}
+
+	w = do(aliceToken, http.MethodPost, "/api/v1/assignments/1/submit", `{"content":"with extension"}`)
+	assert.True(t, w.Code == http.StatusOK || w.Code == http.StatusCreated)
+	w = do(bobToken, http.MethodPost, "/api/v1/assignments/1/submit", `{"content":"late"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(teacherToken, http.MethodDelete, "/api/v1/assignments/1/extensions/2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(aliceToken, http.MethodPost, "/api/v1/assignments/1/submit", `{"content":"edit"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(teacherToken, http.MethodDelete, "/api/v1/assignments/1/extensions/2", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAssignmentDeadline_UsesCourseTimeZone(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID, TimeZone: "Asia/Shanghai"}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+
+	r := setupAssignmentRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Midnight without an offset is local midnight.
+	w := do(http.MethodPost, fmt.Sprintf("/api/v1/courses/%d/assignments", course.ID), fmt.Sprintf(`{"course_id":%d,"title":"HW","deadline":"2026-06-30T23:59"}`, course.ID))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created envelope[map[string]interface{}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "2026-06-30T23:59:00+08:00", created.Data["deadline"])
+
+	var stored models.Assignment
+	assert.NoError(t, db.First(&stored).Error)
+	assert.True(t, stored.Deadline.Equal(time.Date(2026, 6, 30, 15, 59, 0, 0, time.UTC)))
+
+	w = do(http.MethodGet, fmt.Sprintf("/api/v1/courses/%d/assignments", course.ID), "")
+	var listed envelope[[]map[string]interface{}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	if assert.Len(t, listed.Data, 1) {
+		assert.Equal(t, "2026-06-30T23:59:00+08:00", listed.Data[0]["deadline"])
+	}
+
+	w = do(http.MethodPut, fmt.Sprintf("/api/v1/assignments/%d/extensions/%d", stored.ID, alice.ID), `{"deadline":"2026-07-02T08:00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var extension models.AssignmentExtension
+	assert.NoError(t, db.First(&extension).Error)
+	assert.True(t, extension.Deadline.Equal(time.Date(2026, 7, 2, 0, 0, 0, 0, time.UTC)))
+
+	w = do(http.MethodPost, fmt.Sprintf("/api/v1/courses/%d/assignments", course.ID), fmt.Sprintf(`{"course_id":%d,"title":"HW","deadline":"30/06/2026"}`, course.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGradeSubmission_LatePenalty(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+
+	r := setupAssignmentRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Penalties need late work to be accepted, and stay within 0-100 points.
+	path := fmt.Sprintf("/api/v1/courses/%d/assignments", course.ID)
+	body := func(fields string) string { return fmt.Sprintf(`{"course_id":%d,"title":"hw",%s}`, course.ID, fields) }
+	w := do(teacherToken, http.MethodPost, path, body(`"late_penalty_per_day":10`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "INVALID_LATE_POLICY")
+	w = do(teacherToken, http.MethodPost, path, body(`"allow_late":true,"late_penalty_per_day":101`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 30 hours late is two started days.
+	deadline := time.Now().Add(-30 * time.Hour).UTC().Format(time.RFC3339)
+	w = do(teacherToken, http.MethodPost, path, body(`"deadline":"`+deadline+`","allow_late":true,"late_penalty_per_day":10,"late_penalty_floor":50`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created envelope[models.Assignment]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, created.Data.AllowLate)
+	db.Model(&models.Assignment{}).Where("id = ?", created.Data.ID).Update("is_published", true)
+
+	w = do(aliceToken, http.MethodPost, fmt.Sprintf("/api/v1/assignments/%d/submit", created.Data.ID), `{"content":"late"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var submitted envelope[models.Submission]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &submitted))
+	assert.NotNil(t, submitted.Data.LateAt)
+
+	grade := func(raw int) models.Submission {
+		w := do(teacherToken, http.MethodPost, fmt.Sprintf("/api/v1/submissions/%d/grade", submitted.Data.ID), fmt.Sprintf(`{"grade":%d}`, raw))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[models.Submission]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+	for _, tc := range []struct{ raw, grade, penalty int }{
+		{90, 70, 20},
+		{55, 50, 5}, // the floor caps the penalty
+		{40, 40, 0}, // and never raises a grade
+	} {
+		got := grade(tc.raw)
+		if assert.NotNil(t, got.Grade) && assert.NotNil(t, got.RawGrade) {
+			assert.Equal(t, tc.grade, *got.Grade)
+			assert.Equal(t, tc.raw, *got.RawGrade)
+			assert.Equal(t, tc.penalty, got.LatePenalty)
+		}
+	}
+
+	// An extension past the late submission waives the penalty.
+	extended := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	w = do(teacherToken, http.MethodPut, fmt.Sprintf("/api/v1/assignments/%d/extensions/%d", created.Data.ID, alice.ID), `{"deadline":"`+extended+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	got := grade(90)
+	assert.Equal(t, 90, *got.Grade)
+	assert.Equal(t, 0, got.LatePenalty)
+}
+
+func TestAIGradeSubmission_PersistsSuggestion(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The student resubmits and the teacher grades while the AI is working.
+		db.Model(&models.Submission{}).Where("id = ?", 1).Updates(map[string]any{"content": "resubmitted", "grade": 70})
+		_ = json.NewEncoder(w).Encode(map[string]string{"reply": "建议分数: 85\n评语: 推导清晰"})
+	}))
+	defer aiServer.Close()
+
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	assignment := models.Assignment{CourseID: course.ID, Title: "Homework 1", IsPublished: true}
+	db.Create(&assignment)
+	db.Create(&models.Submission{AssignmentID: assignment.ID, StudentID: student.ID, Content: "answer"})
+
+	hAssignment := newAssignmentHandlers(db, clients.NewAIClient(aiServer.URL), nil, nil)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: "test-secret"})
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: "test-secret"}))
+	api.POST("/submissions/:submissionId/ai-grade", hAssignment.AIGradeSubmission)
+	api.GET("/assignments/:id/submissions", hAssignment.ListSubmissions)
+	api.GET("/assignments/:id/my-submission", hAssignment.GetMySubmission)
+
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	studentToken := loginAndGetToken(t, r, "student1", "pass123")
+	do := func(token, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(teacherToken, http.MethodPost, "/api/v1/submissions/1/ai-grade")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do(teacherToken, http.MethodGet, "/api/v1/assignments/1/submissions")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list envelope[[]models.Submission]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Data, 1) {
+		assert.Contains(t, list.Data[0].AISuggestion, "推导清晰")
+		if assert.NotNil(t, list.Data[0].AISuggestedGrade) {
+			assert.Equal(t, 85, *list.Data[0].AISuggestedGrade)
+		}
+		assert.NotNil(t, list.Data[0].AIGradedAt)
+		assert.Equal(t, "resubmitted", list.Data[0].Content)
+		if assert.NotNil(t, list.Data[0].Grade) {
+			assert.Equal(t, 70, *list.Data[0].Grade)
+		}
+	}
+
+	w = do(studentToken, http.MethodGet, "/api/v1/assignments/1/my-submission")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "ai_suggestion")
+	assert.NotContains(t, w.Body.String(), "ai_suggested_grade")
+}
+
+func TestAssignmentStats_SeparateUngradedFromZero(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "admin1", "pass123", "admin")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+	carol := createCourseTestUser(t, db, "carol", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	for _, u := range []models.User{alice, bob, carol} {
+		db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: u.ID, Role: "student"})
+	}
+	hw1 := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "HW1", IsPublished: true}
+	db.Create(&hw1)
+	hw2 := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "HW2", IsPublished: true}
+	db.Create(&hw2)
+	hw3 := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "HW3", IsPublished: true}
+	db.Create(&hw3)
+
+	zero, eighty := 0, 80
+	db.Create(&models.Submission{AssignmentID: hw1.ID, StudentID: alice.ID, Content: "a", Grade: &zero})
+	db.Create(&models.Submission{AssignmentID: hw1.ID, StudentID: bob.ID, Content: "b", Grade: &eighty})
+	db.Create(&models.Submission{AssignmentID: hw1.ID, StudentID: carol.ID, Content: "c"})
+	db.Create(&models.Submission{AssignmentID: hw2.ID, StudentID: alice.ID, Content: "a"})
+
+	r := setupAssignmentRouter(db, "test-secret")
+	get := func(token, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	adminToken := loginAndGetToken(t, r, "admin1", "pass123")
+	w := get(adminToken, "/api/v1/assignments/"+strconv.Itoa(int(hw1.ID))+"/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var detail envelope[services.AssignmentDetailedStats]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, 3, detail.Data.SubmittedCount)
+	assert.Equal(t, 0, detail.Data.NotSubmittedCount)
+	assert.Equal(t, 1, detail.Data.UngradedCount)
+	assert.Equal(t, 2, detail.Data.GradedCount)
+	if assert.NotNil(t, detail.Data.AverageGrade) {
+		assert.InDelta(t, 40.0, *detail.Data.AverageGrade, 0.001)
+	}
+	if assert.NotNil(t, detail.Data.LowestGrade) {
+		assert.Equal(t, 0, *detail.Data.LowestGrade)
+	}
+
+	w = get(adminToken, "/api/v1/assignments/"+strconv.Itoa(int(hw3.ID))+"/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	detail = envelope[services.AssignmentDetailedStats]{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, 3, detail.Data.NotSubmittedCount)
+	assert.Nil(t, detail.Data.AverageGrade)
+	assert.Nil(t, detail.Data.HighestGrade)
+
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	w = get(aliceToken, "/api/v1/courses/"+strconv.Itoa(int(course.ID))+"/assignments/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var mine envelope[services.CourseAssignmentStats]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &mine))
+	assert.Equal(t, 3, mine.Data.TotalAssignments)
+	assert.Equal(t, 2, mine.Data.SubmittedCount)
+	assert.Equal(t, 1, mine.Data.NotSubmittedCount)
+	assert.Equal(t, 1, mine.Data.UngradedCount)
+	assert.Equal(t, 1, mine.Data.GradedCount)
+	if assert.NotNil(t, mine.Data.AverageGrade) {
+		assert.InDelta(t, 0.0, *mine.Data.AverageGrade, 0.001)
+	}
+
+	carolToken := loginAndGetToken(t, r, "carol", "pass123")
+	w = get(carolToken, "/api/v1/courses/"+strconv.Itoa(int(course.ID))+"/assignments/stats")
+	mine = envelope[services.CourseAssignmentStats]{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &mine))
+	assert.Equal(t, 1, mine.Data.UngradedCount)
+	assert.Nil(t, mine.Data.AverageGrade)
+}
+
+func TestGradeSubmission_RejectsSelfGrading(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	ta := createCourseTestUser(t, db, "ta1", "pass123", "assistant")
+	course := models.Course{Name: "Other Section", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: ta.ID, Role: "student"})
+	hw := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "HW", IsPublished: true}
+	db.Create(&hw)
+	submission := models.Submission{AssignmentID: hw.ID, StudentID: ta.ID, Content: "my work"}
+	db.Create(&submission)
+
+	r := setupAssignmentRouter(db, "test-secret")
+	post := func(token, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	base := "/api/v1/submissions/" + strconv.Itoa(int(submission.ID))
+
+	taToken := loginAndGetToken(t, r, "ta1", "pass123")
+	w := post(taToken, base+"/grade", `{"grade":100,"feedback":"great"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "your own submission")
+
+	w = post(taToken, base+"/ai-grade", `{}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var stored models.Submission
+	db.First(&stored, submission.ID)
+	assert.Nil(t, stored.Grade)
+	assert.Empty(t, stored.AISuggestion)
+
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	w = post(teacherToken, base+"/grade", `{"grade":85,"feedback":"ok"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCheckSimilarity_FlagsNearDuplicates(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	small := models.Assignment{CourseID: course.ID, Title: "Essay", IsPublished: true}
+	db.Create(&small)
+
+	original := "Gauss's law states that the electric flux through any closed surface equals the enclosed charge divided by the permittivity of free space."
+	subs := []models.Submission{
+		{AssignmentID: small.ID, StudentID: 101, Content: original},
+		{AssignmentID: small.ID, StudentID: 102, Content: original + " This is why the field of a point charge falls off as one over r squared."},
+		{AssignmentID: small.ID, StudentID: 103, Content: "Faraday observed that a changing magnetic flux induces an electromotive force in a loop of wire."},
+		{AssignmentID: small.ID, StudentID: 104, Content: "   "},
+	}
+	for i := range subs {
+		db.Create(&subs[i])
+	}
+
+	queue := jobs.NewQueue(1, 4)
+	t.Cleanup(func() { _ = queue.Shutdown(context.Background()) })
+	h := newAssignmentHandlers(db, nil, nil, queue)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: "test-secret"})
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	api := r.Group("/api/v1", middleware.AuthRequired(auth.TokenConfig{Secret: "test-secret"}))
+	api.POST("/assignments/:id/similarity", h.CheckSimilarity)
+	api.GET("/assignments/:id/similarity", h.GetSimilarityReport)
+
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	type report struct {
+		Status          string                    `json:"status"`
+		SubmissionCount int                       `json:"submission_count"`
+		Pairs           []services.SimilarityPair `json:"pairs"`
+	}
+
+	other := loginAndGetToken(t, r, "teacher2", "pass123")
+	assert.Equal(t, http.StatusForbidden, do(other, http.MethodPost, "/api/v1/assignments/1/similarity", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(token, http.MethodPost, "/api/v1/assignments/1/similarity", `{"threshold":1.5}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(token, http.MethodGet, "/api/v1/assignments/1/similarity", "").Code)
+
+	// A small class is checked within the request.
+	w := do(token, http.MethodPost, "/api/v1/assignments/1/similarity", `{"threshold":0.4}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[report]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "done", resp.Data.Status)
+	assert.Equal(t, 3, resp.Data.SubmissionCount)
+	if assert.Len(t, resp.Data.Pairs, 1) {
+		pair := resp.Data.Pairs[0]
+		assert.Equal(t, []uint{subs[0].ID, subs[1].ID}, []uint{pair.SubmissionAID, pair.SubmissionBID})
+		assert.Equal(t, []uint{101, 102}, []uint{pair.StudentAID, pair.StudentBID})
+		assert.True(t, pair.Score >= 0.4 && pair.Score < 1, "score = %v", pair.Score)
+	}
+
+	// A large class is queued and read back once done.
+	large := models.Assignment{CourseID: course.ID, Title: "Lab report", IsPublished: true}
+	db.Create(&large)
+	for i := 0; i < 45; i++ {
+		content := "Independent lab report number " + strconv.Itoa(i) + " measuring field strength at distance " + strconv.Itoa(i*7) + " centimetres."
+		if i < 2 {
+			content = original
+		}
+		db.Create(&models.Submission{AssignmentID: large.ID, StudentID: uint(200 + i), Content: content})
+	}
+	path := "/api/v1/assignments/" + strconv.Itoa(int(large.ID)) + "/similarity"
+	w = do(token, http.MethodPost, path, `{"threshold":0.9}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	assert.Eventually(t, func() bool {
+		w := do(token, http.MethodGet, path, "")
+		var resp envelope[report]
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Data.Status == "done" && len(resp.Data.Pairs) == 1 && resp.Data.Pairs[0].Score == 1
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestGroupSubmission_SharedByMembers(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "admin1", "pass123", "admin")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+	carol := createCourseTestUser(t, db, "carol", "pass123", "student")
+	outsider := createCourseTestUser(t, db, "dave", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	for _, u := range []models.User{alice, bob, carol} {
+		db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: u.ID, Role: "student"})
+	}
+	project := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "Project", IsPublished: true, GroupSubmission: true}
+	db.Create(&project)
+
+	r := setupAssignmentRouter(db, "test-secret")
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	bobToken := loginAndGetToken(t, r, "bob", "pass123")
+	groupsPath := "/api/v1/courses/" + strconv.Itoa(int(course.ID)) + "/groups"
+	submitPath := "/api/v1/assignments/" + strconv.Itoa(int(project.ID)) + "/submit"
+	ids := func(users ...models.User) string {
+		var parts []string
+		for _, u := range users {
+			parts = append(parts, strconv.Itoa(int(u.ID)))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+
+	w := do(teacherToken, http.MethodPost, groupsPath, `{"name":"Team A","member_ids":`+ids(alice, bob)+`}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var teamA envelope[models.StudentGroup]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &teamA))
+	assert.Len(t, teamA.Data.Members, 2)
+
+	w = do(teacherToken, http.MethodPost, groupsPath, `{"name":"Team B","member_ids":`+ids(bob, carol)+`}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "ALREADY_IN_GROUP")
+	assert.Contains(t, w.Body.String(), `"student_ids":[`+strconv.Itoa(int(bob.ID))+`]`)
+	assert.Equal(t, http.StatusBadRequest, do(teacherToken, http.MethodPost, groupsPath, `{"name":"Team C","member_ids":`+ids(outsider)+`}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(aliceToken, http.MethodPost, groupsPath, `{"name":"Mine"}`).Code)
+
+	// Students outside any group cannot submit a group assignment.
+	w = do(loginAndGetToken(t, r, "carol", "pass123"), http.MethodPost, submitPath, `{"content":"solo"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "NOT_IN_GROUP")
+
+	w = do(aliceToken, http.MethodPost, submitPath, `{"content":"draft by alice"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var first envelope[models.Submission]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+	w = do(bobToken, http.MethodPost, submitPath, `{"content":"final by bob"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var second envelope[models.Submission]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+	assert.Equal(t, first.Data.ID, second.Data.ID)
+	assert.Equal(t, alice.ID, second.Data.StudentID)
+	// The database holds a group to one submission even if two members'
+	// first submits race past the lookup.
+	assert.Error(t, db.Create(&models.Submission{AssignmentID: project.ID, StudentID: bob.ID, GroupID: &teamA.Data.ID}).Error)
+
+	w = do(teacherToken, http.MethodPost, "/api/v1/submissions/"+strconv.Itoa(int(first.Data.ID))+"/grade", `{"grade":90}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do(bobToken, http.MethodGet, "/api/v1/assignments/"+strconv.Itoa(int(project.ID))+"/my-submission", "")
+	var mine envelope[models.Submission]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &mine))
+	assert.Equal(t, "final by bob", mine.Data.Content)
+	if assert.NotNil(t, mine.Data.Grade) {
+		assert.Equal(t, 90, *mine.Data.Grade)
+	}
+
+	w = do(loginAndGetToken(t, r, "admin1", "pass123"), http.MethodGet, "/api/v1/assignments/"+strconv.Itoa(int(project.ID))+"/stats", "")
+	var detail envelope[services.AssignmentDetailedStats]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, 2, detail.Data.SubmittedCount)
+	assert.Equal(t, 2, detail.Data.GradedCount)
+	assert.Equal(t, 1, detail.Data.NotSubmittedCount)
+	if assert.NotNil(t, detail.Data.AverageGrade) {
+		assert.InDelta(t, 90.0, *detail.Data.AverageGrade, 0.001)
+	}
+
+	w = do(bobToken, http.MethodGet, "/api/v1/courses/"+strconv.Itoa(int(course.ID))+"/assignments/stats", "")
+	var courseStats envelope[services.CourseAssignmentStats]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &courseStats))
+	assert.Equal(t, 1, courseStats.Data.SubmittedCount)
+	assert.Equal(t, 1, courseStats.Data.GradedCount)
+
+	groupPath := "/api/v1/groups/" + strconv.Itoa(int(teamA.Data.ID))
+	w = do(teacherToken, http.MethodDelete, groupPath, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "GROUP_HAS_SUBMISSIONS")
+
+	// Once bob moves to a new group he submits for that group instead.
+	assert.Equal(t, http.StatusOK, do(teacherToken, http.MethodPut, groupPath, `{"name":"Team A","member_ids":`+ids(alice)+`}`).Code)
+	assert.Equal(t, http.StatusCreated, do(teacherToken, http.MethodPost, groupsPath, `{"name":"Team B","member_ids":`+ids(bob, carol)+`}`).Code)
+	w = do(bobToken, http.MethodPost, submitPath, `{"content":"team b"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(teacherToken, http.MethodGet, groupsPath, "")
+	var groups envelope[[]models.StudentGroup]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &groups))
+	assert.Len(t, groups.Data, 2)
+}
+
+// fakeFileStore serves objects from memory under the "/submissions-bucket/" path.
+type fakeFileStore struct {
+	objects map[string]string
+}
+
+func (s *fakeFileStore) ObjectKeyFromURL(fileURL string) (string, bool) {
+	key, ok := strings.CutPrefix(fileURL, "http://minio.test/submissions-bucket/")
+	return key, ok && key != ""
+}
+
+func (s *fakeFileStore) OpenObject(_ context.Context, objectKey string) (io.ReadCloser, error) {
+	content, ok := s.objects[objectKey]
+	if !ok {
+		return nil, clients.ErrObjectNotFound
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestDownloadSubmissionsZip(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+	carol := createCourseTestUser(t, db, "carol", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	hw := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "Homework 1", AllowFile: true}
+	db.Create(&hw)
+	db.Create(&models.Submission{AssignmentID: hw.ID, StudentID: bob.ID, FileURL: "http://minio.test/submissions-bucket/assignments/gone.pdf"})
+	db.Create(&models.Submission{AssignmentID: hw.ID, StudentID: alice.ID, FileURL: "http://minio.test/submissions-bucket/assignments/a1.PDF"})
+	db.Create(&models.Submission{AssignmentID: hw.ID, StudentID: carol.ID, Content: "typed answer"})
+
+	hAssignment := newAssignmentHandlers(db, nil, nil, nil)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: "test-secret"})
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: "test-secret"}))
+	api.GET("/assignments/:id/submissions.zip", hAssignment.DownloadSubmissionsZip)
+
+	get := func(username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/assignments/1/submissions.zip", nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without storage there is nothing to download.
+	assert.Equal(t, http.StatusServiceUnavailable, get("teacher1").Code)
+
+	hAssignment.files = &fakeFileStore{objects: map[string]string{"assignments/a1.PDF": "%PDF-1.4 alice"}}
+	assert.Equal(t, http.StatusForbidden, get("teacher2").Code)
+	assert.Equal(t, http.StatusForbidden, get("alice").Code)
+
+	w := get("teacher1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "assignment-1-submissions.zip")
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+	entries := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+		entries[f.Name] = string(content)
+	}
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "%PDF-1.4 alice", entries["alice.pdf"])
+
+	rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(entries["manifest.csv"], "\uFEFF"))).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 4) {
+		assert.Equal(t, []string{"alice", "alice.pdf", "included"}, []string{rows[1][2], rows[1][3], rows[1][4]})
+		assert.Equal(t, []string{"bob", "", "missing"}, []string{rows[2][2], rows[2][3], rows[2][4]})
+		assert.Equal(t, []string{"carol", "", "no_file"}, []string{rows[3][2], rows[3][3], rows[3][4]})
+	}
+}
+
+func TestCreateAssignment_ChapterMustBelongToCourse(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	course := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	other := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&other)
+	chapter := models.Chapter{CourseID: course.ID, Title: "Statics", OrderNum: 1}
+	foreign := models.Chapter{CourseID: other.ID, Title: "Optics", OrderNum: 1}
+	db.Create(&chapter)
+	db.Create(&foreign)
+
+	r := setupAssignmentRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	create := func(chapterID uint) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"course_id":%d,"title":"hw","chapter_id":%d}`, course.ID, chapterID)
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/courses/%d/assignments", course.ID), bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(foreign.ID)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var failed envelope[any]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &failed))
+	if assert.NotNil(t, failed.Error) {
+		assert.Equal(t, "INVALID_CHAPTER", failed.Error.Code)
+	}
+	var count int64
+	db.Model(&models.Assignment{}).Count(&count)
+	assert.Zero(t, count)
+
+	w = create(chapter.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp envelope[models.Assignment]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.NotNil(t, resp.Data.ChapterID) {
+		assert.Equal(t, chapter.ID, *resp.Data.ChapterID)
+	}
+}
+
+func TestMoveAssignmentToChapter(t *testing.T) {
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	course := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	other := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&other)
+	chapter := models.Chapter{CourseID: course.ID, Title: "Statics", OrderNum: 1}
+	foreign := models.Chapter{CourseID: other.ID, Title: "Optics", OrderNum: 1}
+	db.Create(&chapter)
+	db.Create(&foreign)
+	assignment := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "hw"}
+	db.Create(&assignment)
+
+	r := setupAssignmentRouter(db, "test-secret")
+	move := func(username, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/assignments/%d/chapter", assignment.ID), bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := move("teacher1", fmt.Sprintf(`{"chapter_id":%d}`, chapter.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[models.Assignment]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.NotNil(t, resp.Data.ChapterID) {
+		assert.Equal(t, chapter.ID, *resp.Data.ChapterID)
+	}
+
+	w = move("teacher1", fmt.Sprintf(`{"chapter_id":%d}`, foreign.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var failed envelope[any]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &failed))
+	if assert.NotNil(t, failed.Error) {
+		assert.Equal(t, "INVALID_CHAPTER", failed.Error.Code)
+	}
+	assert.Equal(t, http.StatusForbidden, move("teacher2", `{"chapter_id":null}`).Code)
+
+	assert.Equal(t, http.StatusOK, move("teacher1", `{"chapter_id":null}`).Code)
+	var stored models.Assignment
+	db.First(&stored, assignment.ID)
+	assert.Nil(t, stored.ChapterID)
 }
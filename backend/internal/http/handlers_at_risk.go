@@ -0,0 +1,74 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+type atRiskHandlers struct {
+	service *services.AtRiskService
+}
+
+func newAtRiskHandlers(db *gorm.DB) *atRiskHandlers {
+	return &atRiskHandlers{service: services.NewAtRiskService(db)}
+}
+
+// ListAtRisk lists the course's students falling behind and why. The query
+// may override any threshold or weight of the default risk policy, e.g.
+// ?min_attendance_rate=0.9&quiz_weight=2
+// GET /courses/:courseId/at-risk
+func (h *atRiskHandlers) ListAtRisk(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+
+	policy := services.DefaultRiskPolicy
+	for param, field := range map[string]*float64{
+		"min_attendance_rate": &policy.MinAttendanceRate,
+		"min_submission_rate": &policy.MinSubmissionRate,
+		"min_quiz_average":    &policy.MinQuizAverage,
+		"attendance_weight":   &policy.AttendanceWeight,
+		"submission_weight":   &policy.SubmissionWeight,
+		"quiz_weight":         &policy.QuizWeight,
+	} {
+		raw, ok := c.GetQuery(param)
+		if !ok {
+			continue
+		}
+		if *field, err = strconv.ParseFloat(raw, 64); err != nil {
+			respondError(c, http.StatusBadRequest, "INVALID_RISK_POLICY", "invalid risk policy", gin.H{"param": param})
+			return
+		}
+	}
+
+	user := services.UserInfo{ID: u.ID, Role: u.Role}
+	report, err := h.service.ListAtRisk(c.Request.Context(), uint(courseID), user, policy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRiskPolicy):
+			respondError(c, http.StatusBadRequest, "INVALID_RISK_POLICY", "invalid risk policy", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list at-risk students", nil)
+		}
+		return
+	}
+	respondOK(c, report)
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAtRisk_FlagsStudentsWithReasons(t *testing.T) {
+	db := setupCourseOverviewTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.StudentGroup{}))
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	good := createCourseTestUser(t, db, "good", "pass123", "student")
+	absent := createCourseTestUser(t, db, "absent", "pass123", "student")
+	behind := createCourseTestUser(t, db, "behind", "pass123", "student")
+
+	course := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	db.Create(&course)
+	for _, s := range []models.User{good, absent, behind} {
+		db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: s.ID, Role: "student"})
+	}
+
+	past := time.Now().Add(-time.Hour)
+	for i := 0; i < 2; i++ {
+		session := models.AttendanceSession{CourseID: course.ID, StartedByID: teacher.ID, StartAt: past, EndAt: past, Code: "123456"}
+		db.Create(&session)
+		db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: good.ID, CheckedInAt: past})
+		db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: behind.ID, CheckedInAt: past})
+	}
+
+	// good and absent share a group; one submission covers both. Future and
+	// undated assignments are not due yet.
+	group := models.StudentGroup{CourseID: course.ID, Name: "A"}
+	assert.NoError(t, db.Create(&group).Error)
+	db.Create(&models.StudentGroupMember{GroupID: group.ID, CourseID: course.ID, UserID: good.ID})
+	db.Create(&models.StudentGroupMember{GroupID: group.ID, CourseID: course.ID, UserID: absent.ID})
+	later := time.Now().Add(48 * time.Hour)
+	due := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "due", IsPublished: true, Deadline: &past}
+	open := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "open", IsPublished: true, Deadline: &later}
+	db.Create(&due)
+	db.Create(&open)
+	db.Create(&models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "undated", IsPublished: true})
+	db.Create(&models.Submission{AssignmentID: due.ID, StudentID: good.ID, GroupID: &group.ID, Content: "ours"})
+
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "quiz", IsPublished: true}
+	db.Create(&quiz)
+	for student, score := range map[uint]int{good.ID: 9, absent.ID: 3} {
+		score := score
+		db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: student, StartedAt: past, Deadline: past, SubmittedAt: &past, Score: &score, MaxScore: 10})
+	}
+
+	tokens := auth.TokenConfig{Secret: "test-secret"}
+	hAtRisk := newAtRiskHandlers(db)
+	hAuth := newAuthHandlers(db, tokens)
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	r.GET("/courses/:courseId/at-risk", middleware.AuthRequired(tokens), middleware.RequirePermission(authz.PermCourseWrite), hAtRisk.ListAtRisk)
+	get := func(username, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/courses/%d/at-risk%s", course.ID, query), nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("teacher1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.AtRiskReport]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Data.Students)
+	if assert.Len(t, resp.Data.Items, 2) {
+		first, second := resp.Data.Items[0], resp.Data.Items[1]
+		assert.Equal(t, absent.ID, first.StudentID)
+		assert.Equal(t, "absent", first.Username)
+		if assert.Len(t, first.Reasons, 2) {
+			assert.Equal(t, services.RiskAttendance, first.Reasons[0].Signal)
+			assert.InDelta(t, 0, first.Reasons[0].Value, 1e-9)
+			assert.Equal(t, services.RiskQuiz, first.Reasons[1].Signal)
+			assert.InDelta(t, 30, first.Reasons[1].Value, 1e-9)
+		}
+		if assert.NotNil(t, first.SubmissionRate) {
+			assert.InDelta(t, 1, *first.SubmissionRate, 1e-9) // through the group
+		}
+		// (1 + 0.5) / 3
+		assert.InDelta(t, 0.5, first.RiskScore, 1e-9)
+
+		assert.Equal(t, behind.ID, second.StudentID)
+		if assert.Len(t, second.Reasons, 1) {
+			assert.Equal(t, services.RiskSubmission, second.Reasons[0].Signal)
+			assert.InDelta(t, 0.7, second.Reasons[0].Threshold, 1e-9)
+		}
+		assert.Nil(t, second.QuizAverage)
+		assert.InDelta(t, 1.0/3, second.RiskScore, 1e-9)
+	}
+
+	// Signals weighted zero are ignored: weighting only submissions flags
+	// behind alone.
+	w = get("teacher1", "?attendance_weight=0&quiz_weight=0")
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp = envelope[services.AtRiskReport]{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Items, 1) {
+		assert.Equal(t, behind.ID, resp.Data.Items[0].StudentID)
+		assert.Len(t, resp.Data.Items[0].Reasons, 1)
+		assert.InDelta(t, 1, resp.Data.Items[0].RiskScore, 1e-9)
+	}
+
+	w = get("teacher1", "?min_quiz_average=20&min_attendance_rate=0")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Items, 1) {
+		assert.Equal(t, behind.ID, resp.Data.Items[0].StudentID)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("teacher1", "?min_attendance_rate=80").Code)
+	assert.Equal(t, http.StatusBadRequest, get("teacher1", "?quiz_weight=abc").Code)
+	assert.Equal(t, http.StatusBadRequest, get("teacher1", "?attendance_weight=0&submission_weight=0&quiz_weight=0").Code)
+	assert.Equal(t, http.StatusForbidden, get("teacher2", "").Code)
+	assert.Equal(t, http.StatusForbidden, get("good", "").Code)
+}
@@ -2,12 +2,18 @@ package http
 
 import (
 	"crypto/rand"
-	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/big"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"gorm.io/gorm"
@@ -57,12 +63,12 @@ func (h *attendanceHandlers) GetSummary(c *gin.Context) {
 
 	// Count total sessions
 	var sessionsCount int64
-	h.db.Model(&models.AttendanceSession{}).Where("course_id = ?", courseID).Count(&sessionsCount)
+	h.db.WithContext(c.Request.Context()).Model(&models.AttendanceSession{}).Where("course_id = ?", courseID).Count(&sessionsCount)
 
 	// Get last session time
 	var lastSession models.AttendanceSession
 	var lastSessionAt *time.Time
-	if err := h.db.Where("course_id = ?", courseID).Order("start_at DESC").First(&lastSession).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID).Order("start_at DESC").First(&lastSession).Error; err == nil {
 		lastSessionAt = &lastSession.StartAt
 	}
 
@@ -72,7 +78,7 @@ func (h *attendanceHandlers) GetSummary(c *gin.Context) {
 		if role == "student" {
 			// Student: their own attendance rate
 			var attendedCount int64
-			h.db.Model(&models.AttendanceRecord{}).
+			h.db.WithContext(c.Request.Context()).Model(&models.AttendanceRecord{}).
 				Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id").
 				Where("attendance_sessions.course_id = ? AND attendance_records.student_id = ?", courseID, userID).
 				Count(&attendedCount)
@@ -80,10 +86,10 @@ func (h *attendanceHandlers) GetSummary(c *gin.Context) {
 		} else {
 			// Teacher: average attendance rate across all students
 			var totalEnrollments int64
-			h.db.Model(&models.CourseEnrollment{}).Where("course_id = ? AND role = 'student'", courseID).Count(&totalEnrollments)
+			h.db.WithContext(c.Request.Context()).Model(&models.CourseEnrollment{}).Where("course_id = ? AND role = 'student'", courseID).Count(&totalEnrollments)
 			if totalEnrollments > 0 && sessionsCount > 0 {
 				var totalRecords int64
-				h.db.Model(&models.AttendanceRecord{}).
+				h.db.WithContext(c.Request.Context()).Model(&models.AttendanceRecord{}).
 					Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id").
 					Where("attendance_sessions.course_id = ?", courseID).
 					Count(&totalRecords)
@@ -95,7 +101,7 @@ func (h *attendanceHandlers) GetSummary(c *gin.Context) {
 	// Check for active session
 	var activeSession *ActiveSessionInfo
 	var active models.AttendanceSession
-	if err := h.db.Where("course_id = ? AND is_active = ?", courseID, true).First(&active).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("course_id = ? AND is_active = ?", courseID, true).First(&active).Error; err == nil {
 		code := active.Code
 		if role == "student" {
 			code = "" // Hide code from students
@@ -136,7 +142,7 @@ func (h *attendanceHandlers) ListSessions(c *gin.Context) {
 	}
 
 	var sessions []models.AttendanceSession
-	if err := h.db.Where("course_id = ?", courseID).Order("start_at DESC").Find(&sessions).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID).Order("start_at DESC").Find(&sessions).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to fetch sessions", nil)
 		return
 	}
@@ -144,7 +150,7 @@ func (h *attendanceHandlers) ListSessions(c *gin.Context) {
 	result := make([]SessionListItem, len(sessions))
 	for i, s := range sessions {
 		var count int64
-		h.db.Model(&models.AttendanceRecord{}).Where("session_id = ?", s.ID).Count(&count)
+		h.db.WithContext(c.Request.Context()).Model(&models.AttendanceRecord{}).Where("session_id = ?", s.ID).Count(&count)
 		result[i] = SessionListItem{
 			ID:            s.ID,
 			StartAt:       s.StartAt,
@@ -161,6 +167,33 @@ func (h *attendanceHandlers) ListSessions(c *gin.Context) {
 
 type startSessionRequest struct {
 	TimeoutMinutes int `json:"timeout_minutes"`
+	// CodeLength and CodeAlphanumeric override the course's code settings for this session.
+	CodeLength       *int  `json:"code_length"`
+	CodeAlphanumeric *bool `json:"code_alphanumeric"`
+}
+
+// Check-in code limits. Without course or session settings a code is 6 digits.
+const (
+	defaultAttendanceCodeLength = 6
+	minAttendanceCodeLength     = 4
+	maxAttendanceCodeLength     = 16
+)
+
+// Check-in code alphabets. The alphanumeric one leaves out characters that are
+// easily confused when read off a projector (0/O, 1/I/L).
+const (
+	numericCodeAlphabet      = "0123456789"
+	alphanumericCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+)
+
+// codeRandom is the randomness source for check-in codes; tests swap it out.
+var codeRandom io.Reader = rand.Reader
+
+// AttendanceCodeOptions controls how check-in codes are generated. It is read
+// from module_settings["attendance"] of a course and may be overridden per session.
+type AttendanceCodeOptions struct {
+	Length       int  `json:"code_length"`
+	Alphanumeric bool `json:"code_alphanumeric"`
 }
 
 // StartSession creates a new attendance session
@@ -174,7 +207,7 @@ func (h *attendanceHandlers) StartSession(c *gin.Context) {
 
 	// Check if there's already an active session
 	var existing models.AttendanceSession
-	if err := h.db.Where("course_id = ? AND is_active = ?", courseID, true).First(&existing).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("course_id = ? AND is_active = ?", courseID, true).First(&existing).Error; err == nil {
 		respondError(c, http.StatusConflict, "CONFLICT", "active session already exists", gin.H{"session_id": existing.ID})
 		return
 	}
@@ -184,6 +217,29 @@ func (h *attendanceHandlers) StartSession(c *gin.Context) {
 	if req.TimeoutMinutes <= 0 || req.TimeoutMinutes > 60 {
 		req.TimeoutMinutes = 15 // default
 	}
+	if req.CodeLength != nil && (*req.CodeLength < minAttendanceCodeLength || *req.CodeLength > maxAttendanceCodeLength) {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "code_length must be between 4 and 16", nil)
+		return
+	}
+
+	var course models.Course
+	if err := h.db.WithContext(c.Request.Context()).First(&course, courseID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		return
+	}
+	codeOptions := attendanceCodeOptionsFor(&course)
+	if req.CodeLength != nil {
+		codeOptions.Length = *req.CodeLength
+	}
+	if req.CodeAlphanumeric != nil {
+		codeOptions.Alphanumeric = *req.CodeAlphanumeric
+	}
+	code, err := generateCode(codeOptions)
+	if err != nil {
+		logger.Log.Error("attendance code not generated", slog.Uint64("course_id", courseID), slog.Any("error", err))
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to generate code", nil)
+		return
+	}
 
 	userCtx, ok := middleware.GetUser(c)
 	if !ok {
@@ -199,11 +255,11 @@ func (h *attendanceHandlers) StartSession(c *gin.Context) {
 		StartAt:        now,
 		EndAt:          now.Add(time.Duration(req.TimeoutMinutes) * time.Minute),
 		TimeoutMinutes: req.TimeoutMinutes,
-		Code:           generateCode(),
+		Code:           code,
 		IsActive:       true,
 	}
 
-	if err := h.db.Create(&session).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&session).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create session", nil)
 		return
 	}
@@ -227,7 +283,7 @@ func (h *attendanceHandlers) EndSession(c *gin.Context) {
 	}
 
 	var session models.AttendanceSession
-	if err := h.db.First(&session, sessionID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&session, sessionID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "session not found", nil)
 		return
 	}
@@ -239,7 +295,7 @@ func (h *attendanceHandlers) EndSession(c *gin.Context) {
 
 	session.IsActive = false
 	session.EndAt = time.Now()
-	h.db.Save(&session)
+	h.db.WithContext(c.Request.Context()).Save(&session)
 
 	respondOK(c, gin.H{"message": "session ended"})
 }
@@ -281,7 +337,7 @@ func (h *attendanceHandlers) Checkin(c *gin.Context) {
 
 	// Get session
 	var session models.AttendanceSession
-	if err := h.db.First(&session, sessionID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&session, sessionID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "session not found", nil)
 		return
 	}
@@ -295,20 +351,20 @@ func (h *attendanceHandlers) Checkin(c *gin.Context) {
 	// Check if session has timed out
 	if time.Now().After(session.EndAt) {
 		// Auto-close session
-		h.db.Model(&session).Update("is_active", false)
+		h.db.WithContext(c.Request.Context()).Model(&session).Update("is_active", false)
 		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "session has expired", nil)
 		return
 	}
 
-	// Validate code
-	if req.Code != session.Code {
+	// Validate code; alphanumeric codes are accepted in any case
+	if !strings.EqualFold(strings.TrimSpace(req.Code), session.Code) {
 		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid code", nil)
 		return
 	}
 
 	// Check if already checked in
 	var existing models.AttendanceRecord
-	if err := h.db.Where("session_id = ? AND student_id = ?", sessionID, userID).First(&existing).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("session_id = ? AND student_id = ?", sessionID, userID).First(&existing).Error; err == nil {
 		respondOK(c, CheckinResponse{
 			Success:          true,
 			AlreadyCheckedIn: true,
@@ -326,7 +382,7 @@ func (h *attendanceHandlers) Checkin(c *gin.Context) {
 		IPAddress:   c.ClientIP(),
 	}
 
-	if err := h.db.Create(&record).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&record).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to check in", nil)
 		return
 	}
@@ -347,7 +403,17 @@ type RecordListItem struct {
 	IPAddress   string    `json:"ip_address"`
 }
 
-// GetRecords returns all check-in records for a session
+// RecordListResponse is a page of a session's check-in records.
+type RecordListResponse struct {
+	Items    []RecordListItem `json:"items"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+}
+
+// GetRecords returns a session's check-in records, earliest first, a page at
+// a time. since (RFC 3339) limits the list to check-ins at or after that time;
+// total counts every record matching the filter.
 // GET /attendance/:session_id/records
 func (h *attendanceHandlers) GetRecords(c *gin.Context) {
 	sessionID, err := strconv.ParseUint(c.Param("session_id"), 10, 32)
@@ -356,20 +422,52 @@ func (h *attendanceHandlers) GetRecords(c *gin.Context) {
 		return
 	}
 
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+
+	query := h.db.WithContext(c.Request.Context()).Model(&models.AttendanceRecord{}).Where("session_id = ?", sessionID)
+	if raw := c.Query("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "since must be an RFC 3339 time", nil)
+			return
+		}
+		query = query.Where("checked_in_at >= ?", since)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to fetch records", nil)
+		return
+	}
 	var records []models.AttendanceRecord
-	if err := h.db.Where("session_id = ?", sessionID).Find(&records).Error; err != nil {
+	if err := query.Order("checked_in_at ASC, id ASC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&records).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to fetch records", nil)
 		return
 	}
 
-	// Get student names
+	// Resolve the page's student names in one query
 	studentIDs := make([]uint, len(records))
 	for i, r := range records {
 		studentIDs[i] = r.StudentID
 	}
 
 	var users []models.User
-	h.db.Where("id IN ?", studentIDs).Find(&users)
+	if len(studentIDs) > 0 {
+		if err := h.db.WithContext(c.Request.Context()).Where("id IN ?", studentIDs).Find(&users).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to fetch records", nil)
+			return
+		}
+	}
 	userMap := make(map[uint]string)
 	for _, u := range users {
 		name := u.Name
@@ -389,13 +487,305 @@ func (h *attendanceHandlers) GetRecords(c *gin.Context) {
 		}
 	}
 
-	respondOK(c, result)
+	respondOK(c, RecordListResponse{Items: result, Total: total, Page: page, PageSize: pageSize})
+}
+
+// --- Compliance ---
+
+// Default attendance policy used when the course has no "attendance" module settings.
+const (
+	defaultAttendanceMinRate    = 0.8
+	defaultAttendanceGraceCount = 0
+)
+
+// AttendancePolicy is read from module_settings["attendance"] of a course.
+type AttendancePolicy struct {
+	MinRate    float64 `json:"min_rate"`    // required attendance rate in [0, 1]
+	GraceCount int     `json:"grace_count"` // absences forgiven before the rate is applied
+}
+
+// StudentAttendance is a student's attendance tally for a course.
+type StudentAttendance struct {
+	StudentID    uint    `json:"student_id"`
+	StudentName  string  `json:"student_name"`
+	Attended     int     `json:"attended"`
+	Missed       int     `json:"missed"`
+	Rate         float64 `json:"rate"`
+	AdjustedRate float64 `json:"adjusted_rate"` // rate after forgiving up to grace_count absences
+}
+
+// AttendanceComplianceResponse lists students below the course attendance policy.
+type AttendanceComplianceResponse struct {
+	Policy        AttendancePolicy    `json:"policy"`
+	SessionsCount int                 `json:"sessions_count"`
+	TotalStudents int                 `json:"total_students"`
+	BelowPolicy   []StudentAttendance `json:"below_policy"`
+}
+
+// GetCompliance lists enrolled students whose attendance is below the course policy
+// GET /courses/:courseId/attendance/compliance
+func (h *attendanceHandlers) GetCompliance(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+
+	var course models.Course
+	if err := h.db.WithContext(c.Request.Context()).First(&course, courseID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		return
+	}
+	if !authorizeCourseAccess(c, h.db, &course) {
+		return
+	}
+
+	policy := attendancePolicyFor(&course)
+	sessionsCount, students, err := courseStudentAttendance(h.db.WithContext(c.Request.Context()), course.ID, policy)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to compute attendance", nil)
+		return
+	}
+
+	below := make([]StudentAttendance, 0)
+	for _, s := range students {
+		if s.AdjustedRate < policy.MinRate {
+			below = append(below, s)
+		}
+	}
+
+	respondOK(c, AttendanceComplianceResponse{
+		Policy:        policy,
+		SessionsCount: sessionsCount,
+		TotalStudents: len(students),
+		BelowPolicy:   below,
+	})
+}
+
+// --- Trend ---
+
+// AttendanceTrendPoint is the attendance of one session.
+type AttendanceTrendPoint struct {
+	SessionID     uint      `json:"session_id"`
+	StartAt       time.Time `json:"start_at"`
+	Date          string    `json:"date"` // session start date, YYYY-MM-DD
+	AttendeeCount int       `json:"attendee_count"`
+	EnrolledCount int       `json:"enrolled_count"`
+	Rate          float64   `json:"rate"` // attendee_count / enrolled_count, capped at 1
+}
+
+// AttendanceTrendResponse lists a course's sessions oldest first.
+type AttendanceTrendResponse struct {
+	CourseID uint                   `json:"course_id"`
+	Sessions []AttendanceTrendPoint `json:"sessions"`
+}
+
+// GetTrend returns the attendance rate of every session in a course so
+// teachers can see engagement change over the term
+// GET /courses/:courseId/attendance/trend
+func (h *attendanceHandlers) GetTrend(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+
+	var course models.Course
+	if err := h.db.WithContext(c.Request.Context()).First(&course, courseID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		return
+	}
+	if !authorizeCourseAccess(c, h.db, &course) {
+		return
+	}
+
+	trend, err := courseAttendanceTrend(h.db.WithContext(c.Request.Context()), course.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to compute attendance trend", nil)
+		return
+	}
+	respondOK(c, AttendanceTrendResponse{CourseID: course.ID, Sessions: trend})
+}
+
+// courseAttendanceTrend computes each session's attendance. A session's
+// enrolled count only includes students who had enrolled by the time it
+// ended, so students joining mid-term do not depress earlier sessions.
+func courseAttendanceTrend(db *gorm.DB, courseID uint) ([]AttendanceTrendPoint, error) {
+	var sessions []models.AttendanceSession
+	if err := db.Where("course_id = ?", courseID).Order("start_at ASC, id ASC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		SessionID uint
+		Attendees int
+	}
+	if err := db.Model(&models.AttendanceRecord{}).
+		Select("attendance_records.session_id AS session_id, COUNT(*) AS attendees").
+		Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id").
+		Where("attendance_sessions.course_id = ? AND attendance_sessions.deleted_at IS NULL", courseID).
+		Group("attendance_records.session_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	attendees := make(map[uint]int, len(counts))
+	for _, row := range counts {
+		attendees[row.SessionID] = row.Attendees
+	}
+
+	var enrolledAt []time.Time
+	if err := db.Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND role = ?", courseID, "student").
+		Order("created_at ASC").
+		Pluck("created_at", &enrolledAt).Error; err != nil {
+		return nil, err
+	}
+
+	trend := make([]AttendanceTrendPoint, 0, len(sessions))
+	for _, s := range sessions {
+		enrolled := sort.Search(len(enrolledAt), func(i int) bool { return enrolledAt[i].After(s.EndAt) })
+		point := AttendanceTrendPoint{
+			SessionID:     s.ID,
+			StartAt:       s.StartAt,
+			Date:          s.StartAt.Format("2006-01-02"),
+			AttendeeCount: attendees[s.ID],
+			EnrolledCount: enrolled,
+		}
+		if enrolled > 0 {
+			point.Rate = math.Min(float64(point.AttendeeCount)/float64(enrolled), 1)
+		}
+		trend = append(trend, point)
+	}
+	return trend, nil
+}
+
+// attendancePolicyFor reads the attendance policy from course module settings,
+// falling back to defaults for missing or out-of-range values.
+func attendancePolicyFor(course *models.Course) AttendancePolicy {
+	policy := AttendancePolicy{MinRate: defaultAttendanceMinRate, GraceCount: defaultAttendanceGraceCount}
+	settings, err := parseModuleSettings(course.ModuleSettings)
+	if err != nil {
+		return policy
+	}
+	raw, ok := settings["attendance"].(map[string]interface{})
+	if !ok {
+		return policy
+	}
+	if v, ok := raw["min_rate"].(float64); ok && v >= 0 && v <= 1 {
+		policy.MinRate = v
+	}
+	if v, ok := raw["grace_count"].(float64); ok && v >= 0 {
+		policy.GraceCount = int(v)
+	}
+	return policy
 }
 
-// generateCode generates a 6-digit random code
-func generateCode() string {
-	b := make([]byte, 3)
-	rand.Read(b)
-	num := (int(b[0])<<16 | int(b[1])<<8 | int(b[2])) % 1000000
-	return fmt.Sprintf("%06d", num)
+// attendanceCodeOptionsFor reads the check-in code settings from course module
+// settings, falling back to 6-digit numeric codes for missing or out-of-range values.
+func attendanceCodeOptionsFor(course *models.Course) AttendanceCodeOptions {
+	options := AttendanceCodeOptions{Length: defaultAttendanceCodeLength}
+	settings, err := parseModuleSettings(course.ModuleSettings)
+	if err != nil {
+		return options
+	}
+	raw, ok := settings["attendance"].(map[string]interface{})
+	if !ok {
+		return options
+	}
+	if v, ok := raw["code_length"].(float64); ok && v >= minAttendanceCodeLength && v <= maxAttendanceCodeLength {
+		options.Length = int(v)
+	}
+	if v, ok := raw["code_alphanumeric"].(bool); ok {
+		options.Alphanumeric = v
+	}
+	return options
+}
+
+// courseStudentAttendance tallies attendance for every enrolled student of a course.
+// With no sessions yet, every student has a rate of 1.
+func courseStudentAttendance(db *gorm.DB, courseID uint, policy AttendancePolicy) (int, []StudentAttendance, error) {
+	var sessionsCount int64
+	if err := db.Model(&models.AttendanceSession{}).Where("course_id = ?", courseID).Count(&sessionsCount).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var studentIDs []uint
+	if err := db.Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND role = ?", courseID, "student").
+		Order("user_id ASC").
+		Pluck("user_id", &studentIDs).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var counts []struct {
+		StudentID uint
+		Attended  int
+	}
+	if err := db.Model(&models.AttendanceRecord{}).
+		Select("attendance_records.student_id AS student_id, COUNT(*) AS attended").
+		Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id").
+		Where("attendance_sessions.course_id = ? AND attendance_sessions.deleted_at IS NULL", courseID).
+		Group("attendance_records.student_id").
+		Scan(&counts).Error; err != nil {
+		return 0, nil, err
+	}
+	attended := make(map[uint]int, len(counts))
+	for _, row := range counts {
+		attended[row.StudentID] = row.Attended
+	}
+
+	var users []models.User
+	if len(studentIDs) > 0 {
+		if err := db.Where("id IN ?", studentIDs).Find(&users).Error; err != nil {
+			return 0, nil, err
+		}
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		name := u.Name
+		if name == "" {
+			name = u.Username
+		}
+		names[u.ID] = name
+	}
+
+	total := int(sessionsCount)
+	result := make([]StudentAttendance, 0, len(studentIDs))
+	for _, id := range studentIDs {
+		s := StudentAttendance{StudentID: id, StudentName: names[id], Attended: attended[id], Rate: 1, AdjustedRate: 1}
+		if total > 0 {
+			s.Missed = total - s.Attended
+			if s.Missed < 0 {
+				s.Missed = 0
+			}
+			forgiven := s.Missed
+			if forgiven > policy.GraceCount {
+				forgiven = policy.GraceCount
+			}
+			s.Rate = float64(s.Attended) / float64(total)
+			s.AdjustedRate = float64(s.Attended+forgiven) / float64(total)
+		}
+		result = append(result, s)
+	}
+	return total, result, nil
+}
+
+// generateCode generates a random check-in code. Each character is drawn
+// uniformly from the options' alphabet with crypto/rand.Int, so no value is
+// favoured. A failed read is returned rather than falling back to a weaker code.
+func generateCode(options AttendanceCodeOptions) (string, error) {
+	alphabet := numericCodeAlphabet
+	if options.Alphanumeric {
+		alphabet = alphanumericCodeAlphabet
+	}
+	size := big.NewInt(int64(len(alphabet)))
+	code := make([]byte, options.Length)
+	for i := range code {
+		n, err := rand.Int(codeRandom, size)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
 }
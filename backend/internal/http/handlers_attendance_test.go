@@ -0,0 +1,350 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func setupAttendanceTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Course{},
+		&models.CourseEnrollment{},
+		&models.AttendanceSession{},
+		&models.AttendanceRecord{},
+	)
+	assert.NoError(t, err)
+
+	return db
+}
+
+func setupAttendanceRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
+	hAttendance := newAttendanceHandlers(db)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
+	{
+		api.GET("/courses/:courseId/attendance/compliance", hAttendance.GetCompliance)
+		api.GET("/courses/:courseId/attendance/trend", hAttendance.GetTrend)
+		api.POST("/courses/:courseId/attendance/start", hAttendance.StartSession)
+		api.POST("/attendance/:session_id/end", hAttendance.EndSession)
+		api.POST("/attendance/:session_id/checkin", hAttendance.Checkin)
+		api.GET("/attendance/:session_id/records", hAttendance.GetRecords)
+	}
+
+	return r
+}
+
+func TestAttendanceCompliance(t *testing.T) {
+	db := setupAttendanceTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	good := createCourseTestUser(t, db, "good", "pass123", "student")
+	graced := createCourseTestUser(t, db, "graced", "pass123", "student")
+	absent := createCourseTestUser(t, db, "absent", "pass123", "student")
+
+	course := models.Course{
+		Name:           "Test Course",
+		TeacherID:      teacher.ID,
+		ModuleSettings: datatypes.JSON(`{"attendance":{"min_rate":0.8,"grace_count":1}}`),
+	}
+	db.Create(&course)
+	for _, s := range []models.User{good, graced, absent} {
+		db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: s.ID, Role: "student"})
+	}
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		session := models.AttendanceSession{CourseID: course.ID, StartedByID: teacher.ID, StartAt: now, EndAt: now, Code: "123456"}
+		db.Create(&session)
+		db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: good.ID, CheckedInAt: now})
+		if i < 3 {
+			db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: graced.ID, CheckedInAt: now})
+		}
+		if i == 0 {
+			db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: absent.ID, CheckedInAt: now})
+		}
+	}
+
+	r := setupAttendanceRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/courses/1/attendance/compliance", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp envelope[AttendanceComplianceResponse]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, AttendancePolicy{MinRate: 0.8, GraceCount: 1}, resp.Data.Policy)
+	assert.Equal(t, 4, resp.Data.SessionsCount)
+	assert.Equal(t, 3, resp.Data.TotalStudents)
+	// graced missed one session (rate 0.75) but the grace absence lifts them to 1.0
+	if assert.Len(t, resp.Data.BelowPolicy, 1) {
+		assert.Equal(t, absent.ID, resp.Data.BelowPolicy[0].StudentID)
+		assert.Equal(t, 3, resp.Data.BelowPolicy[0].Missed)
+		assert.InDelta(t, 0.25, resp.Data.BelowPolicy[0].Rate, 1e-9)
+		assert.InDelta(t, 0.5, resp.Data.BelowPolicy[0].AdjustedRate, 1e-9)
+	}
+}
+
+func TestAttendanceTrend(t *testing.T) {
+	db := setupAttendanceTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	early := createCourseTestUser(t, db, "early", "pass123", "student")
+	steady := createCourseTestUser(t, db, "steady", "pass123", "student")
+	late := createCourseTestUser(t, db, "late", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+
+	week := 7 * 24 * time.Hour
+	termStart := time.Now().Add(-4 * week)
+	for _, s := range []models.User{early, steady} {
+		enrollment := models.CourseEnrollment{CourseID: course.ID, UserID: s.ID, Role: "student"}
+		db.Create(&enrollment)
+		db.Model(&enrollment).UpdateColumn("created_at", termStart.Add(-time.Hour))
+	}
+	// late joins after the first session and does not count against it.
+	lateEnrollment := models.CourseEnrollment{CourseID: course.ID, UserID: late.ID, Role: "student"}
+	db.Create(&lateEnrollment)
+	db.Model(&lateEnrollment).UpdateColumn("created_at", termStart.Add(week/2))
+
+	attendance := [][]models.User{{early, steady}, {early, steady, late}, {steady}}
+	// Created out of order to check the trend is sorted by start time.
+	for _, i := range []int{2, 0, 1} {
+		start := termStart.Add(time.Duration(i) * week)
+		session := models.AttendanceSession{CourseID: course.ID, StartedByID: teacher.ID, StartAt: start, EndAt: start.Add(15 * time.Minute), Code: "123456"}
+		db.Create(&session)
+		for _, s := range attendance[i] {
+			db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: s.ID, CheckedInAt: start})
+		}
+	}
+
+	r := setupAttendanceRouter(db, "test-secret")
+	get := func(username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/courses/1/attendance/trend", nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("teacher1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[AttendanceTrendResponse]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Sessions, 3) {
+		first, second, third := resp.Data.Sessions[0], resp.Data.Sessions[1], resp.Data.Sessions[2]
+		assert.Equal(t, termStart.Format("2006-01-02"), first.Date)
+		assert.Equal(t, 2, first.AttendeeCount)
+		assert.Equal(t, 2, first.EnrolledCount)
+		assert.InDelta(t, 1.0, first.Rate, 1e-9)
+		assert.Equal(t, 3, second.EnrolledCount)
+		assert.InDelta(t, 1.0, second.Rate, 1e-9)
+		assert.Equal(t, 1, third.AttendeeCount)
+		assert.InDelta(t, 1.0/3, third.Rate, 1e-9)
+		assert.True(t, first.StartAt.Before(second.StartAt) && second.StartAt.Before(third.StartAt))
+	}
+
+	assert.Equal(t, http.StatusForbidden, get("teacher2").Code)
+}
+
+func TestStartSession_CodeOptions(t *testing.T) {
+	db := setupAttendanceTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	plain := models.Course{Name: "Plain", TeacherID: teacher.ID}
+	db.Create(&plain)
+	configured := models.Course{
+		Name:           "Configured",
+		TeacherID:      teacher.ID,
+		ModuleSettings: datatypes.JSON(`{"attendance":{"code_length":8,"code_alphanumeric":true}}`),
+	}
+	db.Create(&configured)
+	db.Create(&models.CourseEnrollment{CourseID: configured.ID, UserID: student.ID, Role: "student"})
+
+	r := setupAttendanceRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	post := func(token, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	start := func(courseID uint, body string) (uint, string) {
+		w := post(teacherToken, "/api/v1/courses/"+strconv.FormatUint(uint64(courseID), 10)+"/attendance/start", body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var resp envelope[struct {
+			ID   uint   `json:"id"`
+			Code string `json:"code"`
+		}]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.ID, resp.Data.Code
+	}
+	end := func(sessionID uint) {
+		post(teacherToken, "/api/v1/attendance/"+strconv.FormatUint(uint64(sessionID), 10)+"/end", "")
+	}
+
+	for _, tc := range []struct {
+		courseID uint
+		body     string
+		pattern  string
+	}{
+		{plain.ID, `{}`, `^[0-9]{6}$`},
+		{plain.ID, `{"code_length":10}`, `^[0-9]{10}$`},
+		{configured.ID, `{}`, `^[2-9A-Z]{8}$`},
+		{configured.ID, `{"code_length":5,"code_alphanumeric":false}`, `^[0-9]{5}$`},
+	} {
+		sessionID, code := start(tc.courseID, tc.body)
+		assert.Regexp(t, regexp.MustCompile(tc.pattern), code, tc.body)
+		end(sessionID)
+	}
+
+	w := post(teacherToken, "/api/v1/courses/1/attendance/start", `{"code_length":3}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Alphanumeric codes are accepted regardless of case.
+	sessionID, code := start(configured.ID, `{}`)
+	w = post(loginAndGetToken(t, r, "student1", "pass123"), "/api/v1/attendance/"+strconv.FormatUint(uint64(sessionID), 10)+"/checkin", `{"code":"`+strings.ToLower(code)+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGenerateCode_Uniform(t *testing.T) {
+	for _, options := range []AttendanceCodeOptions{
+		{Length: 6},
+		{Length: 6, Alphanumeric: true},
+	} {
+		alphabet := numericCodeAlphabet
+		if options.Alphanumeric {
+			alphabet = alphanumericCodeAlphabet
+		}
+		const samples = 20000
+		counts := make([]map[byte]int, options.Length)
+		for i := range counts {
+			counts[i] = make(map[byte]int, len(alphabet))
+		}
+		for i := 0; i < samples; i++ {
+			code, err := generateCode(options)
+			assert.NoError(t, err)
+			assert.Len(t, code, options.Length)
+			for pos := 0; pos < len(code); pos++ {
+				counts[pos][code[pos]]++
+			}
+		}
+
+		// Pearson's chi-squared per position against a uniform distribution.
+		// The bound is well above the 99.99th percentile for up to 30 degrees of
+		// freedom, so the test only fails on a real bias.
+		expected := float64(samples) / float64(len(alphabet))
+		for pos, seen := range counts {
+			assert.Len(t, seen, len(alphabet), "position %d misses characters", pos)
+			chi2 := 0.0
+			for j := 0; j < len(alphabet); j++ {
+				d := float64(seen[alphabet[j]]) - expected
+				chi2 += d * d / expected
+			}
+			assert.Less(t, chi2, 70.0, "position %d of alphanumeric=%v looks biased", pos, options.Alphanumeric)
+		}
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("entropy unavailable") }
+
+func TestStartSession_RandomFailure(t *testing.T) {
+	db := setupAttendanceTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	db.Create(&models.Course{Name: "Test Course", TeacherID: teacher.ID})
+
+	previous := codeRandom
+	codeRandom = failingReader{}
+	t.Cleanup(func() { codeRandom = previous })
+
+	r := setupAttendanceRouter(db, "test-secret")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/courses/1/attendance/start", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, "teacher1", "pass123"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var count int64
+	db.Model(&models.AttendanceSession{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestGetRecords_PagedAndFiltered(t *testing.T) {
+	db := setupAttendanceTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	session := models.AttendanceSession{CourseID: course.ID, StartedByID: teacher.ID, StartAt: start, EndAt: start.Add(time.Hour), Code: "123456"}
+	db.Create(&session)
+	// Created in reverse so the list has to be sorted by check-in time.
+	for i := 4; i >= 0; i-- {
+		s := createCourseTestUser(t, db, "student"+strconv.Itoa(i), "pass123", "student")
+		db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: s.ID, CheckedInAt: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	r := setupAttendanceRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	get := func(query string) (*httptest.ResponseRecorder, RecordListResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/attendance/1/records?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp envelope[RecordListResponse]
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp.Data
+	}
+
+	w, page := get("page=2&page_size=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(5), page.Total)
+	if assert.Len(t, page.Items, 2) {
+		assert.Equal(t, "Test student2", page.Items[0].StudentName)
+		assert.Equal(t, "Test student3", page.Items[1].StudentName)
+	}
+
+	since := start.Add(3 * time.Minute).Format(time.RFC3339)
+	w, page = get("since=" + url.QueryEscape(since))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2), page.Total)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, 50, page.PageSize)
+
+	w, _ = get("since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
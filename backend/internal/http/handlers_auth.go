@@ -1,24 +1,27 @@
 package http
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"gorm.io/gorm"
 )
 
 type authHandlers struct {
-	db        *gorm.DB
-	jwtSecret string
+	db     *gorm.DB
+	tokens auth.TokenConfig
 }
 
-func newAuthHandlers(db *gorm.DB, jwtSecret string) *authHandlers {
-	return &authHandlers{db: db, jwtSecret: jwtSecret}
+func newAuthHandlers(db *gorm.DB, tokens auth.TokenConfig) *authHandlers {
+	return &authHandlers{db: db, tokens: tokens}
 }
 
 type loginRequest struct {
@@ -33,6 +36,40 @@ type loginResponse struct {
 	UserID      uint   `json:"user_id,omitempty"`
 	Username    string `json:"username,omitempty"`
 	Role        string `json:"role,omitempty"`
+	// Counts is omitted when it could not be computed.
+	Counts *SessionCounts `json:"counts,omitempty"`
+}
+
+// SessionCounts are cheap counts the client shows on its first screen, sent
+// with the login and /auth/me responses to spare it follow-up requests.
+type SessionCounts struct {
+	Courses             int64 `json:"courses"`              // enrolled in, taught or assisted; all courses for admins
+	UnreadAnnouncements int64 `json:"unread_announcements"` // across those courses
+}
+
+// sessionCounts computes the user's SessionCounts. It is best-effort: a
+// failure is logged and yields nil, so it never blocks login.
+func (h *authHandlers) sessionCounts(ctx context.Context, userID uint, role string) *SessionCounts {
+	db := h.db.WithContext(ctx)
+	courseIDs := db.Model(&models.Course{}).Select("id")
+	if role != "admin" {
+		courseIDs = courseIDs.Where("teacher_id = ? OR id IN (?)", userID,
+			db.Model(&models.CourseEnrollment{}).Select("course_id").Where("user_id = ?", userID),
+		)
+	}
+	var counts SessionCounts
+	err := db.Table("(?) AS user_courses", courseIDs).Count(&counts.Courses).Error
+	if err == nil {
+		err = db.Model(&models.Announcement{}).
+			Where("course_id IN (?)", courseIDs).
+			Where("id NOT IN (?)", db.Model(&models.AnnouncementRead{}).Select("announcement_id").Where("user_id = ?", userID)).
+			Count(&counts.UnreadAnnouncements).Error
+	}
+	if err != nil {
+		logger.Log.Warn("session counts failed", slog.Uint64("user_id", uint64(userID)), slog.Any("error", err))
+		return nil
+	}
+	return &counts
 }
 
 func (h *authHandlers) Login(c *gin.Context) {
@@ -43,7 +80,7 @@ func (h *authHandlers) Login(c *gin.Context) {
 	}
 
 	var u models.User
-	if err := h.db.Where("username = ?", req.Username).First(&u).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("username = ?", req.Username).First(&u).Error; err != nil {
 		respondError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid username or password", nil)
 		return
 	}
@@ -51,9 +88,12 @@ func (h *authHandlers) Login(c *gin.Context) {
 		respondError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid username or password", nil)
 		return
 	}
+	if auth.NeedsRehash(u.PasswordHash) {
+		h.upgradePasswordHash(c.Request.Context(), &u, req.Password)
+	}
 
 	ttl := 24 * time.Hour
-	token, err := auth.SignToken(h.jwtSecret, u.ID, u.Username, u.Role, ttl)
+	token, err := auth.SignToken(h.tokens, u.ID, u.Username, u.Role, ttl)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "TOKEN_SIGN_FAILED", "token sign failed", nil)
 		return
@@ -66,9 +106,40 @@ func (h *authHandlers) Login(c *gin.Context) {
 		UserID:      u.ID,
 		Username:    u.Username,
 		Role:        u.Role,
+		Counts:      h.sessionCounts(c.Request.Context(), u.ID, u.Role),
 	})
 }
 
+// upgradePasswordHash re-hashes a password stored with an outdated cost. It is
+// best-effort: a failure is logged and the login still succeeds.
+func (h *authHandlers) upgradePasswordHash(ctx context.Context, u *models.User, plain string) {
+	hash, err := auth.HashPassword(plain)
+	if err == nil {
+		err = h.db.WithContext(ctx).Model(u).Update("password_hash", hash).Error
+	}
+	if err != nil {
+		logger.Log.Warn("password rehash failed", slog.Uint64("user_id", uint64(u.ID)), slog.Any("error", err))
+	}
+}
+
+// Logout revokes the caller's sessions, so every token issued to them so far
+// (on any device) stops working
+// POST /auth/logout
+func (h *authHandlers) Logout(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+	if h.tokens.Sessions != nil {
+		if err := h.tokens.Sessions.Revoke(c.Request.Context(), u.ID, "logout"); err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to revoke sessions", nil)
+			return
+		}
+	}
+	respondOK(c, gin.H{"message": "logged out"})
+}
+
 // MeResponse is the response for /auth/me endpoint
 type MeResponse struct {
 	ID          uint     `json:"id"`
@@ -76,6 +147,8 @@ type MeResponse struct {
 	Name        string   `json:"name"`
 	Role        string   `json:"role"`
 	Permissions []string `json:"permissions"`
+	// Counts is omitted when it could not be computed.
+	Counts *SessionCounts `json:"counts,omitempty"`
 }
 
 func (h *authHandlers) Me(c *gin.Context) {
@@ -87,7 +160,7 @@ func (h *authHandlers) Me(c *gin.Context) {
 
 	// Fetch fresh user data from database
 	var dbUser models.User
-	if err := h.db.First(&dbUser, u.ID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&dbUser, u.ID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "USER_NOT_FOUND", "user not found", nil)
 		return
 	}
@@ -101,5 +174,6 @@ func (h *authHandlers) Me(c *gin.Context) {
 		Name:        dbUser.Name,
 		Role:        dbUser.Role,
 		Permissions: permissions,
+		Counts:      h.sessionCounts(c.Request.Context(), dbUser.ID, dbUser.Role),
 	})
 }
@@ -5,42 +5,47 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type loginData struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int64  `json:"expires_in"`
-	UserID      uint   `json:"user_id"`
-	Username    string `json:"username"`
-	Role        string `json:"role"`
+	AccessToken string         `json:"access_token"`
+	TokenType   string         `json:"token_type"`
+	ExpiresIn   int64          `json:"expires_in"`
+	UserID      uint           `json:"user_id"`
+	Username    string         `json:"username"`
+	Role        string         `json:"role"`
+	Counts      *SessionCounts `json:"counts"`
 }
 
 func setupAuthTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	assert.NoError(t, err)
 
-	err = db.AutoMigrate(&models.User{})
+	err = db.AutoMigrate(&models.User{}, &models.SessionRevocation{})
 	assert.NoError(t, err)
 
 	return db
 }
 
-func setupAuthRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
-	hAuth := newAuthHandlers(db, jwtSecret)
+func setupAuthRouter(db *gorm.DB, tokens auth.TokenConfig) *gin.Engine {
+	hAuth := newAuthHandlers(db, tokens)
 
 	r := gin.New()
 	r.POST("/auth/login", hAuth.Login)
-	r.GET("/auth/me", middleware.AuthRequired(jwtSecret), hAuth.Me)
+	r.GET("/auth/me", middleware.AuthRequired(tokens), hAuth.Me)
 	return r
 }
 
@@ -62,7 +67,7 @@ func TestLogin_Success(t *testing.T) {
 	db := setupAuthTestDB(t)
 	createTestUser(t, db, "alice", "pass123", "teacher")
 
-	r := setupAuthRouter(db, "test-secret")
+	r := setupAuthRouter(db, auth.TokenConfig{Secret: "test-secret"})
 
 	payload := []byte(`{"username":"alice","password":"pass123"}`)
 	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(payload))
@@ -82,11 +87,70 @@ func TestLogin_Success(t *testing.T) {
 	assert.Equal(t, "teacher", resp.Data.Role)
 }
 
+func TestLogin_IncludesSessionCounts(t *testing.T) {
+	db := setupAnnouncementTestDB(t)
+	teacher := createTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createTestUser(t, db, "student1", "pass123", "student")
+	createTestUser(t, db, "admin1", "pass123", "admin")
+
+	enrolled := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	other := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	db.Create(&enrolled)
+	db.Create(&other)
+	db.Create(&models.CourseEnrollment{CourseID: enrolled.ID, UserID: student.ID, Role: "student"})
+	read := models.Announcement{CourseID: enrolled.ID, CreatedByID: teacher.ID, Title: "read", Content: "..."}
+	db.Create(&read)
+	db.Create(&models.Announcement{CourseID: enrolled.ID, CreatedByID: teacher.ID, Title: "unread", Content: "..."})
+	db.Create(&models.Announcement{CourseID: other.ID, CreatedByID: teacher.ID, Title: "elsewhere", Content: "..."})
+	db.Create(&models.AnnouncementRead{AnnouncementID: read.ID, UserID: student.ID, ReadAt: time.Now()})
+
+	tokens := auth.TokenConfig{Secret: "test-secret"}
+	r := setupAuthRouter(db, tokens)
+	login := func(username string) *SessionCounts {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader([]byte(`{"username":"`+username+`","password":"pass123"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[loginData]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.Counts
+	}
+
+	for username, want := range map[string]SessionCounts{
+		"student1": {Courses: 1, UnreadAnnouncements: 1},
+		"teacher1": {Courses: 2, UnreadAnnouncements: 3},
+		"admin1":   {Courses: 2, UnreadAnnouncements: 3},
+	} {
+		if got := login(username); assert.NotNil(t, got, username) {
+			assert.Equal(t, want, *got, username)
+		}
+	}
+
+	token := loginAndGetToken(t, r, "student1", "pass123")
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var me envelope[MeResponse]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &me))
+	if assert.NotNil(t, me.Data.Counts) {
+		assert.Equal(t, SessionCounts{Courses: 1, UnreadAnnouncements: 1}, *me.Data.Counts)
+	}
+
+	// Without the course tables the counts fail and are left out; login
+	// still succeeds.
+	db = setupAuthTestDB(t)
+	createTestUser(t, db, "student1", "pass123", "student")
+	r = setupAuthRouter(db, tokens)
+	assert.Nil(t, login("student1"))
+}
+
 func TestLogin_InvalidPassword(t *testing.T) {
 	db := setupAuthTestDB(t)
 	createTestUser(t, db, "alice", "pass123", "teacher")
 
-	r := setupAuthRouter(db, "test-secret")
+	r := setupAuthRouter(db, auth.TokenConfig{Secret: "test-secret"})
 
 	payload := []byte(`{"username":"alice","password":"wrong"}`)
 	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(payload))
@@ -108,7 +172,7 @@ func TestLogin_InvalidRequest(t *testing.T) {
 	db := setupAuthTestDB(t)
 	createTestUser(t, db, "alice", "pass123", "teacher")
 
-	r := setupAuthRouter(db, "test-secret")
+	r := setupAuthRouter(db, auth.TokenConfig{Secret: "test-secret"})
 
 	payload := []byte(`{"username":"alice"}`)
 	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(payload))
@@ -130,7 +194,7 @@ func TestMe_Success(t *testing.T) {
 	db := setupAuthTestDB(t)
 	user := createTestUser(t, db, "alice", "pass123", "teacher")
 
-	r := setupAuthRouter(db, "test-secret")
+	r := setupAuthRouter(db, auth.TokenConfig{Secret: "test-secret"})
 
 	// 登录获取token
 	payload := []byte(`{"username":"alice","password":"pass123"}`)
@@ -159,3 +223,183 @@ func TestMe_Success(t *testing.T) {
 	assert.Equal(t, user.Role, meResp.Data.Role)
 	assert.NotEmpty(t, meResp.Data.Permissions)
 }
+
+func TestMe_RejectsTokenForOtherAudience(t *testing.T) {
+	db := setupAuthTestDB(t)
+	user := createTestUser(t, db, "alice", "pass123", "teacher")
+
+	prod := auth.TokenConfig{Secret: "shared-secret", Issuer: "emfield", Audience: "prod"}
+	r := setupAuthRouter(db, prod)
+
+	me := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	token, err := auth.SignToken(prod, user.ID, user.Username, user.Role, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, me(token))
+
+	staging := prod
+	staging.Audience = "staging"
+	token, err = auth.SignToken(staging, user.ID, user.Username, user.Role, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, me(token))
+
+	otherIssuer := prod
+	otherIssuer.Issuer = "someone-else"
+	token, err = auth.SignToken(otherIssuer, user.ID, user.Username, user.Role, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, me(token))
+
+	token, err = auth.SignToken(auth.TokenConfig{Secret: prod.Secret}, user.ID, user.Username, user.Role, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, me(token), "tokens without iss/aud must be rejected when both are configured")
+}
+
+func TestSessionRevocation_LogoutAndAdminRevoke(t *testing.T) {
+	db := setupAuthTestDB(t)
+	createTestUser(t, db, "alice", "pass123", "student")
+	bob := createTestUser(t, db, "bob", "pass123", "student")
+	createTestUser(t, db, "admin1", "pass123", "admin")
+
+	newRouter := func() *gin.Engine {
+		tokens := auth.TokenConfig{Secret: "test-secret", Sessions: services.NewSessionService(db, time.Minute)}
+		hAuth := newAuthHandlers(db, tokens)
+		hAdmin := newAdminHandlers(db, tokens.Sessions)
+		r := gin.New()
+		r.POST("/auth/login", hAuth.Login)
+		r.GET("/auth/me", middleware.AuthRequired(tokens), hAuth.Me)
+		r.POST("/auth/logout", middleware.AuthRequired(tokens), hAuth.Logout)
+		r.POST("/admin/users/:id/revoke-sessions", middleware.AuthRequired(tokens), hAdmin.RevokeSessions)
+		return r
+	}
+	r := newRouter()
+	do := func(r *gin.Engine, method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	bobToken := loginAndGetToken(t, r, "bob", "pass123")
+	adminToken := loginAndGetToken(t, r, "admin1", "pass123")
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/auth/me", aliceToken))
+
+	assert.Equal(t, http.StatusOK, do(r, http.MethodPost, "/auth/logout", aliceToken))
+	assert.Equal(t, http.StatusUnauthorized, do(r, http.MethodGet, "/auth/me", aliceToken))
+
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/auth/me", bobToken))
+	assert.Equal(t, http.StatusOK, do(r, http.MethodPost, "/admin/users/"+strconv.Itoa(int(bob.ID))+"/revoke-sessions", adminToken))
+	assert.Equal(t, http.StatusUnauthorized, do(r, http.MethodGet, "/auth/me", bobToken))
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/auth/me", adminToken))
+
+	// Revocations are stored, so a fresh process still rejects the old tokens.
+	restarted := newRouter()
+	assert.Equal(t, http.StatusUnauthorized, do(restarted, http.MethodGet, "/auth/me", bobToken))
+	assert.Equal(t, http.StatusOK, do(restarted, http.MethodGet, "/auth/me", adminToken))
+}
+
+func TestLogin_RehashesOutdatedPasswordCost(t *testing.T) {
+	db := setupAuthTestDB(t)
+	defer func() { assert.NoError(t, auth.SetPasswordCost(bcrypt.DefaultCost)) }()
+
+	assert.NoError(t, auth.SetPasswordCost(bcrypt.MinCost))
+	user := createTestUser(t, db, "alice", "pass123", "teacher")
+	assert.NoError(t, auth.SetPasswordCost(bcrypt.MinCost+1))
+
+	r := setupAuthRouter(db, auth.TokenConfig{Secret: "test-secret"})
+	login := func(password string) int {
+		payload := []byte(`{"username":"alice","password":"` + password + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	storedCost := func() int {
+		var u models.User
+		assert.NoError(t, db.First(&u, user.ID).Error)
+		cost, err := bcrypt.Cost([]byte(u.PasswordHash))
+		assert.NoError(t, err)
+		return cost
+	}
+
+	// A failed login must not touch the stored hash.
+	assert.Equal(t, http.StatusUnauthorized, login("wrong"))
+	assert.Equal(t, bcrypt.MinCost, storedCost())
+
+	assert.Equal(t, http.StatusOK, login("pass123"))
+	assert.Equal(t, bcrypt.MinCost+1, storedCost())
+
+	// The upgraded hash still verifies.
+	assert.Equal(t, http.StatusOK, login("pass123"))
+}
+
+func TestAuditLogs_UserRoleChange(t *testing.T) {
+	db := setupAuthTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.AuditLog{}))
+	admin := createTestUser(t, db, "admin1", "pass123", "admin")
+	bob := createTestUser(t, db, "bob", "pass123", "student")
+
+	tokens := auth.TokenConfig{Secret: "test-secret"}
+	hAuth := newAuthHandlers(db, tokens)
+	hAdmin := newAdminHandlers(db, nil)
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+	r.PUT("/admin/users/:id", middleware.AuthRequired(tokens), hAdmin.UpdateUser)
+	r.GET("/audit-logs", middleware.AuthRequired(tokens), hAdmin.ListAuditLogs)
+	adminToken := loginAndGetToken(t, r, "admin1", "pass123")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	list := func(query string) AuditLogListResponse {
+		w := do(http.MethodGet, "/audit-logs"+query, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[AuditLogListResponse]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	userPath := "/admin/users/" + strconv.Itoa(int(bob.ID))
+	// Renaming is not audited; a role change is.
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, userPath, `{"name":"Bob"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, userPath, `{"role":"teacher"}`).Code)
+
+	page := list("")
+	assert.Equal(t, int64(1), page.Total)
+	if assert.Len(t, page.Items, 1) {
+		entry := page.Items[0]
+		assert.Equal(t, "user.role_change", entry.Action)
+		assert.Equal(t, admin.ID, entry.ActorID)
+		assert.Equal(t, "admin", entry.ActorRole)
+		assert.Equal(t, "user", entry.TargetType)
+		assert.Equal(t, bob.ID, entry.TargetID)
+		assert.JSONEq(t, `{"username":"bob","previous_role":"student","role":"teacher"}`, string(entry.Details))
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	assert.Equal(t, int64(1), list("?action=user.role_change&actor_id="+strconv.Itoa(int(admin.ID))+"&from="+today+"&to="+today).Total)
+	assert.Equal(t, int64(0), list("?action=quiz_attempt.reopen").Total)
+	assert.Equal(t, int64(0), list("?actor_id="+strconv.Itoa(int(bob.ID))).Total)
+	assert.Equal(t, int64(0), list("?to="+time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")).Total)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/audit-logs?from=yesterday", "").Code)
+
+	// A failed audit write rolls the role change back.
+	assert.NoError(t, db.Migrator().DropTable(&models.AuditLog{}))
+	assert.Equal(t, http.StatusInternalServerError, do(http.MethodPut, userPath, `{"role":"admin"}`).Code)
+	var reloaded models.User
+	db.First(&reloaded, bob.ID)
+	assert.Equal(t, "teacher", reloaded.Role)
+}
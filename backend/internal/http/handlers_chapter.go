@@ -1,9 +1,11 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
@@ -16,27 +18,31 @@ type chapterHandlers struct {
 	service *services.ChapterService
 }
 
-func newChapterHandlers(db *gorm.DB) *chapterHandlers {
+// newChapterHandlers builds the chapter handlers; sessionTimeout is how long
+// without heartbeats ends a study session.
+func newChapterHandlers(db *gorm.DB, sessionTimeout time.Duration) *chapterHandlers {
 	return &chapterHandlers{
 		db:      db,
-		service: services.NewChapterService(db),
+		service: services.NewChapterService(db).WithSessionTimeout(sessionTimeout),
 	}
 }
 
 // ============ Request/Response Types ============
 
 type createChapterRequest struct {
-	Title           string `json:"title" binding:"required"`
-	OrderNum        int    `json:"order_num"`
-	Summary         string `json:"summary"`
-	KnowledgePoints string `json:"knowledge_points"` // JSON array string
+	Title                 string `json:"title" binding:"required"`
+	OrderNum              int    `json:"order_num"`
+	Summary               string `json:"summary"`
+	KnowledgePoints       string `json:"knowledge_points"`        // JSON array string
+	PrerequisiteChapterID *uint  `json:"prerequisite_chapter_id"` // optional gating chapter
 }
 
 type updateChapterRequest struct {
-	Title           *string `json:"title"`
-	OrderNum        *int    `json:"order_num"`
-	Summary         *string `json:"summary"`
-	KnowledgePoints *string `json:"knowledge_points"`
+	Title                 *string `json:"title"`
+	OrderNum              *int    `json:"order_num"`
+	Summary               *string `json:"summary"`
+	KnowledgePoints       *string `json:"knowledge_points"`
+	PrerequisiteChapterID *uint   `json:"prerequisite_chapter_id"` // 0 removes gating
 }
 
 // ============ CRUD Handlers ============
@@ -92,7 +98,7 @@ func (h *chapterHandlers) CreateChapter(c *gin.Context) {
 
 	var req createChapterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "invalid request", nil)
+		respondBindError(c, "INVALID_REQUEST", err)
 		return
 	}
 
@@ -100,13 +106,22 @@ func (h *chapterHandlers) CreateChapter(c *gin.Context) {
 		ID:   u.ID,
 		Role: u.Role,
 	}, services.CreateChapterRequest{
-		CourseID:        uint(courseID),
-		Title:           req.Title,
-		OrderNum:        req.OrderNum,
-		Summary:         req.Summary,
-		KnowledgePoints: req.KnowledgePoints,
+		CourseID:              uint(courseID),
+		Title:                 req.Title,
+		OrderNum:              req.OrderNum,
+		Summary:               req.Summary,
+		KnowledgePoints:       req.KnowledgePoints,
+		PrerequisiteChapterID: req.PrerequisiteChapterID,
 	})
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidPrerequisite) {
+			respondError(c, http.StatusBadRequest, "INVALID_PREREQUISITE", "prerequisite must be another chapter of the same course", nil)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidKnowledgePoints) {
+			respondError(c, http.StatusBadRequest, "INVALID_KNOWLEDGE_POINTS", "knowledge_points must be a JSON array of strings", nil)
+			return
+		}
 		if errors.Is(err, services.ErrCourseNotFound) {
 			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
 			return
@@ -150,6 +165,10 @@ func (h *chapterHandlers) GetChapter(c *gin.Context) {
 			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
 			return
 		}
+		if errors.Is(err, services.ErrPrerequisiteNotMet) {
+			respondError(c, http.StatusForbidden, "PREREQUISITE_NOT_MET", "complete the prerequisite chapter first", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "GET_CHAPTER_FAILED", "get chapter failed", nil)
 		return
 	}
@@ -174,7 +193,7 @@ func (h *chapterHandlers) UpdateChapter(c *gin.Context) {
 
 	var req updateChapterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "invalid request", nil)
+		respondBindError(c, "INVALID_REQUEST", err)
 		return
 	}
 
@@ -182,12 +201,21 @@ func (h *chapterHandlers) UpdateChapter(c *gin.Context) {
 		ID:   u.ID,
 		Role: u.Role,
 	}, services.UpdateChapterRequest{
-		Title:           req.Title,
-		OrderNum:        req.OrderNum,
-		Summary:         req.Summary,
-		KnowledgePoints: req.KnowledgePoints,
+		Title:                 req.Title,
+		OrderNum:              req.OrderNum,
+		Summary:               req.Summary,
+		KnowledgePoints:       req.KnowledgePoints,
+		PrerequisiteChapterID: req.PrerequisiteChapterID,
 	})
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidPrerequisite) {
+			respondError(c, http.StatusBadRequest, "INVALID_PREREQUISITE", "prerequisite must be another chapter of the same course that does not depend on this one", nil)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidKnowledgePoints) {
+			respondError(c, http.StatusBadRequest, "INVALID_KNOWLEDGE_POINTS", "knowledge_points must be a JSON array of strings", nil)
+			return
+		}
 		if errors.Is(err, services.ErrChapterNotFound) {
 			respondError(c, http.StatusNotFound, "CHAPTER_NOT_FOUND", "chapter not found", nil)
 			return
@@ -245,6 +273,45 @@ func (h *chapterHandlers) DeleteChapter(c *gin.Context) {
 	respondOK(c, gin.H{"message": "deleted"})
 }
 
+// CompleteChapter marks a chapter as completed by the current student
+func (h *chapterHandlers) CompleteChapter(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid id", nil)
+		return
+	}
+
+	progress, err := h.service.CompleteChapter(c.Request.Context(), uint(id), services.UserInfo{
+		ID:   u.ID,
+		Role: u.Role,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrChapterNotFound) {
+			respondError(c, http.StatusNotFound, "CHAPTER_NOT_FOUND", "chapter not found", nil)
+			return
+		}
+		if errors.Is(err, services.ErrAccessDenied) {
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+			return
+		}
+		if errors.Is(err, services.ErrPrerequisiteNotMet) {
+			respondError(c, http.StatusForbidden, "PREREQUISITE_NOT_MET", "complete the prerequisite chapter first", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "COMPLETE_CHAPTER_FAILED", "complete chapter failed", nil)
+		return
+	}
+
+	respondOK(c, progress)
+}
+
 // ============ Heartbeat Handler ============
 
 // Heartbeat records student study time with idempotent logic
@@ -284,7 +351,7 @@ func (h *chapterHandlers) Heartbeat(c *gin.Context) {
 	}
 
 	if started {
-		respondOK(c, gin.H{"message": "started", "duration": 0})
+		respondOK(c, gin.H{"message": "started", "duration": duration})
 		return
 	}
 
@@ -324,6 +391,10 @@ func (h *chapterHandlers) GetMyStats(c *gin.Context) {
 			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
 			return
 		}
+		if errors.Is(err, services.ErrPrerequisiteNotMet) {
+			respondError(c, http.StatusForbidden, "PREREQUISITE_NOT_MET", "complete the prerequisite chapter first", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load stats", nil)
 		return
 	}
@@ -331,6 +402,42 @@ func (h *chapterHandlers) GetMyStats(c *gin.Context) {
 	respondOK(c, stats)
 }
 
+// GetCourseStudyTime returns the current user's study time across a course's
+// chapters, with class averages for teachers
+// GET /courses/:courseId/study-time
+func (h *chapterHandlers) GetCourseStudyTime(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+
+	response, err := h.service.GetCourseStudyTime(c.Request.Context(), uint(courseID), services.UserInfo{
+		ID:   u.ID,
+		Role: u.Role,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrCourseNotFound) {
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+			return
+		}
+		if errors.Is(err, services.ErrAccessDenied) {
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load study time", nil)
+		return
+	}
+
+	respondOK(c, response)
+}
+
 // GetClassStats returns class-wide stats for a chapter (teachers only)
 func (h *chapterHandlers) GetClassStats(c *gin.Context) {
 	u, ok := middleware.GetUser(c)
@@ -351,21 +458,124 @@ func (h *chapterHandlers) GetClassStats(c *gin.Context) {
 		Role: u.Role,
 	})
 	if err != nil {
-		if errors.Is(err, services.ErrChapterNotFound) {
-			respondError(c, http.StatusNotFound, "CHAPTER_NOT_FOUND", "chapter not found", nil)
+		respondClassStatsError(c, err)
+		return
+	}
+
+	respondOK(c, response)
+}
+
+// StreamClassStats streams per-student progress on a chapter as ND-JSON, one
+// object per line, for classes too large to render at once. The summary
+// figures stay on GetClassStats. A failure after the first line is reported
+// as a final {"error": ...} line.
+// GET /chapters/:id/class-stats/stream
+func (h *chapterHandlers) StreamClassStats(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	chapterID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_ID", "invalid id", nil)
+		return
+	}
+
+	started := false
+	start := func() {
+		if started {
 			return
 		}
-		if errors.Is(err, services.ErrCourseNotFound) {
-			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
-			return
+		started = true
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+	}
+	enc := json.NewEncoder(c.Writer)
+	err = h.service.StreamClassStats(c.Request.Context(), uint(chapterID), services.UserInfo{
+		ID:   u.ID,
+		Role: u.Role,
+	}, func(sp services.StudentProgress) error {
+		start()
+		if err := enc.Encode(sp); err != nil {
+			return err
 		}
-		if errors.Is(err, services.ErrAccessDenied) {
-			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		if !started {
+			respondClassStatsError(c, err)
 			return
 		}
-		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load stats", nil)
+		if c.Request.Context().Err() == nil {
+			_ = enc.Encode(gin.H{"error": apiError{Code: "INTERNAL_ERROR", Message: localizeMessage(c, "INTERNAL_ERROR", "failed to load stats")}})
+			c.Writer.Flush()
+		}
 		return
 	}
+	start()
+	c.Writer.WriteHeaderNow()
+}
+
+func respondClassStatsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrChapterNotFound):
+		respondError(c, http.StatusNotFound, "CHAPTER_NOT_FOUND", "chapter not found", nil)
+	case errors.Is(err, services.ErrCourseNotFound):
+		respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+	case errors.Is(err, services.ErrAccessDenied):
+		respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+	default:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load stats", nil)
+	}
+}
 
-	respondOK(c, response)
+// bindChapterMove reads the body of the routes moving a quiz or assignment
+// between chapters: {"chapter_id": 3}, or {"chapter_id": null} to detach it.
+// It responds with 400 and returns false when chapter_id is missing or not
+// an ID.
+func bindChapterMove(c *gin.Context) (*uint, bool) {
+	var req struct {
+		ChapterID json.RawMessage `json:"chapter_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return nil, false
+	}
+	if len(req.ChapterID) == 0 {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "chapter_id is required; use null to detach", nil)
+		return nil, false
+	}
+	return parseChapterID(c, req.ChapterID)
+}
+
+// parseChapterID decodes a raw chapter_id: a chapter ID, or null to detach.
+// It responds with 400 and returns false for any other value.
+func parseChapterID(c *gin.Context, raw json.RawMessage) (*uint, bool) {
+	var chapterID *uint
+	if err := json.Unmarshal(raw, &chapterID); err != nil || (chapterID != nil && *chapterID == 0) {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "chapter_id must be a chapter id or null", nil)
+		return nil, false
+	}
+	return chapterID, true
+}
+
+// respondChapterMoveError writes the response for errors shared by the move
+// routes and reports whether err was one of them.
+func respondChapterMoveError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrInvalidChapter):
+		respondError(c, http.StatusBadRequest, "INVALID_CHAPTER", "chapter must belong to the same course", nil)
+	case errors.Is(err, services.ErrCourseNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+	case errors.Is(err, services.ErrAccessDenied):
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+	default:
+		return false
+	}
+	return true
 }
@@ -3,14 +3,19 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -25,6 +30,11 @@ func setupChapterTestDB(t *testing.T) *gorm.DB {
 		&models.CourseEnrollment{},
 		&models.Chapter{},
 		&models.ChapterProgress{},
+		&models.LearningEvent{},
+		&models.Assignment{},
+		&models.Submission{},
+		&models.Resource{},
+		&models.Quiz{},
 	)
 	assert.NoError(t, err)
 
@@ -32,18 +42,25 @@ func setupChapterTestDB(t *testing.T) *gorm.DB {
 }
 
 func setupChapterRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
-	hChapter := newChapterHandlers(db)
-	hAuth := newAuthHandlers(db, jwtSecret)
+	hChapter := newChapterHandlers(db, services.DefaultStudySessionTimeout)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
 
 	r := gin.New()
 	r.POST("/auth/login", hAuth.Login)
 
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthRequired(jwtSecret))
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
 	{
 		api.GET("/courses/:courseId/chapters", hChapter.ListChapters)
 		api.POST("/courses/:courseId/chapters", hChapter.CreateChapter)
 		api.GET("/chapters/:id", hChapter.GetChapter)
+		api.PUT("/chapters/:id", hChapter.UpdateChapter)
+		api.DELETE("/chapters/:id", hChapter.DeleteChapter)
+		api.POST("/chapters/:id/complete", hChapter.CompleteChapter)
+		api.POST("/chapters/:id/heartbeat", hChapter.Heartbeat)
+		api.GET("/courses/:courseId/study-time", hChapter.GetCourseStudyTime)
+		api.GET("/chapters/:id/class-stats", hChapter.GetClassStats)
+		api.GET("/chapters/:id/class-stats/stream", hChapter.StreamClassStats)
 	}
 
 	return r
@@ -100,3 +117,389 @@ func TestCreateChapter_Success(t *testing.T) {
 	assert.True(t, resp.Success)
 	assert.Equal(t, "New Chapter", resp.Data.Title)
 }
+
+func TestChapter_KnowledgePointsValidated(t *testing.T) {
+	db := setupChapterTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	chapter := models.Chapter{CourseID: course.ID, Title: "Chapter 1", OrderNum: 1, KnowledgePoints: `["Gauss"]`}
+	db.Create(&chapter)
+
+	r := setupChapterRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, points := range []string{`"[\"Gauss\""`, `"{\"a\":1}"`, `"[1,2]"`, `"null"`} {
+		w := do(http.MethodPost, "/api/v1/courses/1/chapters", `{"title":"Bad","order_num":2,"knowledge_points":`+points+`}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, points)
+		assert.Contains(t, w.Body.String(), "INVALID_KNOWLEDGE_POINTS")
+
+		w = do(http.MethodPut, "/api/v1/chapters/1", `{"knowledge_points":`+points+`}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, points)
+	}
+	var count int64
+	db.Model(&models.Chapter{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+	var stored models.Chapter
+	db.First(&stored, chapter.ID)
+	assert.Equal(t, `["Gauss"]`, stored.KnowledgePoints)
+
+	w := do(http.MethodPost, "/api/v1/courses/1/chapters", `{"title":"Good","order_num":2,"knowledge_points":"[\"Ampere\",\"Faraday\"]"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = do(http.MethodPut, "/api/v1/chapters/1", `{"knowledge_points":""}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGetChapter_PrerequisiteGating(t *testing.T) {
+	db := setupChapterTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	first := models.Chapter{CourseID: course.ID, Title: "Chapter 1", OrderNum: 1}
+	db.Create(&first)
+	second := models.Chapter{CourseID: course.ID, Title: "Chapter 2", OrderNum: 2, PrerequisiteChapterID: &first.ID}
+	db.Create(&second)
+
+	r := setupChapterRouter(db, "test-secret")
+	studentToken := loginAndGetToken(t, r, "student1", "pass123")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/chapters/2", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(studentToken)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var errResp envelope[any]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "PREREQUISITE_NOT_MET", errResp.Error.Code)
+
+	assert.Equal(t, http.StatusOK, get(teacherToken).Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chapters/1/complete", nil)
+	req.Header.Set("Authorization", "Bearer "+studentToken)
+	cw := httptest.NewRecorder()
+	r.ServeHTTP(cw, req)
+	assert.Equal(t, http.StatusOK, cw.Code)
+
+	assert.Equal(t, http.StatusOK, get(studentToken).Code)
+}
+
+func TestChapterPrerequisite_CyclesAndDeletion(t *testing.T) {
+	db := setupChapterTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	first := models.Chapter{CourseID: course.ID, Title: "Chapter 1", OrderNum: 1}
+	db.Create(&first)
+	second := models.Chapter{CourseID: course.ID, Title: "Chapter 2", OrderNum: 2, PrerequisiteChapterID: &first.ID}
+	db.Create(&second)
+	third := models.Chapter{CourseID: course.ID, Title: "Chapter 3", OrderNum: 3, PrerequisiteChapterID: &second.ID}
+	db.Create(&third)
+
+	r := setupChapterRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 1 -> 2 and 1 -> 3 would close the chains 2 -> 1 and 3 -> 2 -> 1.
+	for _, prerequisite := range []uint{second.ID, third.ID} {
+		w := do(http.MethodPut, fmt.Sprintf("/api/v1/chapters/%d", first.ID), fmt.Sprintf(`{"prerequisite_chapter_id":%d}`, prerequisite))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "INVALID_PREREQUISITE")
+	}
+	// 3 -> 1 only skips a link of the existing chain.
+	w := do(http.MethodPut, fmt.Sprintf("/api/v1/chapters/%d", third.ID), fmt.Sprintf(`{"prerequisite_chapter_id":%d}`, first.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Deleting a prerequisite opens the chapters gated on it.
+	w = do(http.MethodDelete, fmt.Sprintf("/api/v1/chapters/%d", first.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var remaining []models.Chapter
+	assert.NoError(t, db.Order("id").Find(&remaining).Error)
+	if assert.Len(t, remaining, 2) {
+		assert.Nil(t, remaining[0].PrerequisiteChapterID)
+		assert.Nil(t, remaining[1].PrerequisiteChapterID)
+	}
+}
+
+func TestHeartbeat_BoundedAndMonotonic(t *testing.T) {
+	db := setupChapterTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	db.Create(&models.Chapter{CourseID: course.ID, Title: "Chapter 1", OrderNum: 1})
+
+	r := setupChapterRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	beat := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chapters/1/heartbeat", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[struct {
+			Duration int `json:"duration"`
+		}]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.Duration
+	}
+	setLastActive := func(at time.Time) {
+		assert.NoError(t, db.Model(&models.ChapterProgress{}).Where("chapter_id = ?", 1).Update("last_active_at", at).Error)
+	}
+
+	assert.Equal(t, 0, beat())
+
+	// Rapid-fire pings within the same second add nothing.
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 0, beat())
+	}
+
+	// A 33s gap is credited as at most one interval.
+	setLastActive(time.Now().Add(-33 * time.Second))
+	assert.Equal(t, 30, beat())
+	// Replaying immediately afterwards does not count again.
+	assert.Equal(t, 30, beat())
+
+	// A heartbeat whose stored LastActiveAt is in the future is ignored.
+	setLastActive(time.Now().Add(10 * time.Minute))
+	assert.Equal(t, 30, beat())
+
+	// A 10s gap credits only the elapsed 10s.
+	setLastActive(time.Now().Add(-10 * time.Second))
+	d := beat()
+	assert.GreaterOrEqual(t, d, 39)
+	assert.LessOrEqual(t, d, 41)
+}
+
+func TestHeartbeat_SessionTimeoutEndsSession(t *testing.T) {
+	db := setupChapterTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	db.Create(&models.Chapter{CourseID: course.ID, Title: "Chapter 1", OrderNum: 1})
+
+	r := setupChapterRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	type beatResult struct {
+		Message  string `json:"message"`
+		Duration int    `json:"duration"`
+	}
+	beat := func() beatResult {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chapters/1/heartbeat", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[beatResult]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+	setLastActive := func(at time.Time) {
+		assert.NoError(t, db.Model(&models.ChapterProgress{}).Where("chapter_id = ?", 1).Update("last_active_at", at).Error)
+	}
+	sessionEnds := func() []models.LearningEvent {
+		var events []models.LearningEvent
+		db.Where("event_type = ?", "session_end").Find(&events)
+		return events
+	}
+
+	assert.Equal(t, "started", beat().Message)
+	setLastActive(time.Now().Add(-20 * time.Second))
+	assert.Equal(t, 20, beat().Duration)
+
+	// A pause shorter than the session timeout is not credited and keeps the session.
+	setLastActive(time.Now().Add(-2 * time.Minute))
+	assert.Equal(t, beatResult{Message: "recorded", Duration: 20}, beat())
+	assert.Empty(t, sessionEnds())
+
+	// A longer one ends the session and starts another.
+	ended := time.Now().Add(-services.DefaultStudySessionTimeout - time.Minute)
+	setLastActive(ended)
+	assert.Equal(t, beatResult{Message: "started", Duration: 20}, beat())
+	events := sessionEnds()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, student.ID, events[0].StudentID)
+		var payload struct {
+			ChapterID    uint      `json:"chapter_id"`
+			EndedAt      time.Time `json:"ended_at"`
+			StudySeconds int       `json:"study_seconds"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(events[0].Payload), &payload))
+		assert.Equal(t, uint(1), payload.ChapterID)
+		assert.Equal(t, 20, payload.StudySeconds)
+		assert.WithinDuration(t, ended, payload.EndedAt, time.Second)
+	}
+	var progress models.ChapterProgress
+	db.First(&progress)
+	assert.Equal(t, 0, progress.SessionSeconds)
+
+	setLastActive(time.Now().Add(-10 * time.Second))
+	assert.Equal(t, "recorded", beat().Message)
+	db.First(&progress)
+	assert.Equal(t, 10, progress.SessionSeconds)
+	assert.Len(t, sessionEnds(), 1)
+}
+
+func TestGetCourseStudyTime_StudentTotalsAndClassAverages(t *testing.T) {
+	db := setupChapterTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID, Role: "student"})
+
+	ch1 := models.Chapter{CourseID: course.ID, Title: "Chapter 1", OrderNum: 1}
+	ch2 := models.Chapter{CourseID: course.ID, Title: "Chapter 2", OrderNum: 2}
+	db.Create(&ch1)
+	db.Create(&ch2)
+	db.Create(&models.ChapterProgress{ChapterID: ch1.ID, StudentID: alice.ID, StudyDurationSeconds: 3900})
+	db.Create(&models.ChapterProgress{ChapterID: ch2.ID, StudentID: alice.ID, StudyDurationSeconds: 600})
+	db.Create(&models.ChapterProgress{ChapterID: ch1.ID, StudentID: bob.ID, StudyDurationSeconds: 300})
+
+	r := setupChapterRouter(db, "test-secret")
+
+	get := func(token string) (int, envelope[services.CourseStudyTime]) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/courses/1/study-time", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp envelope[services.CourseStudyTime]
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := get(loginAndGetToken(t, r, "alice", "pass123"))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 4500, resp.Data.TotalSeconds)
+	assert.Equal(t, "1小时15分钟", resp.Data.TotalFormatted)
+	assert.Nil(t, resp.Data.ClassAvgSeconds)
+	if assert.Len(t, resp.Data.Chapters, 2) {
+		assert.Equal(t, 3900, resp.Data.Chapters[0].Seconds)
+		assert.Equal(t, "1小时5分钟", resp.Data.Chapters[0].Formatted)
+		assert.Equal(t, 600, resp.Data.Chapters[1].Seconds)
+		assert.Nil(t, resp.Data.Chapters[0].ClassAvgSeconds)
+	}
+
+	code, resp = get(loginAndGetToken(t, r, "teacher1", "pass123"))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, resp.Data.TotalStudents)
+	if assert.NotNil(t, resp.Data.ClassAvgSeconds) {
+		assert.Equal(t, 2400, *resp.Data.ClassAvgSeconds)
+	}
+	if assert.Len(t, resp.Data.Chapters, 2) && assert.NotNil(t, resp.Data.Chapters[1].ClassAvgSeconds) {
+		assert.Equal(t, 2100, *resp.Data.Chapters[0].ClassAvgSeconds)
+		assert.Equal(t, 300, *resp.Data.Chapters[1].ClassAvgSeconds)
+	}
+
+	createCourseTestUser(t, db, "outsider", "pass123", "student")
+	code, _ = get(loginAndGetToken(t, r, "outsider", "pass123"))
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestStreamClassStats_MatchesAggregate(t *testing.T) {
+	db := setupChapterTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	coTeacher := createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID, Role: "student"})
+	chapter := models.Chapter{CourseID: course.ID, Title: "Chapter 1", OrderNum: 1}
+	db.Create(&chapter)
+	db.Create(&models.ChapterProgress{ChapterID: chapter.ID, StudentID: alice.ID, StudyDurationSeconds: 900})
+	db.Create(&models.ChapterProgress{ChapterID: chapter.ID, StudentID: bob.ID, StudyDurationSeconds: 300})
+
+	hw1 := models.Assignment{CourseID: course.ID, ChapterID: &chapter.ID, TeacherID: teacher.ID, Title: "HW1"}
+	hw2 := models.Assignment{CourseID: course.ID, ChapterID: &chapter.ID, TeacherID: teacher.ID, Title: "HW2"}
+	db.Create(&hw1)
+	db.Create(&hw2)
+	grade80, grade95 := 80, 95
+	db.Create(&models.Submission{AssignmentID: hw1.ID, StudentID: alice.ID, Grade: &grade80})
+	db.Create(&models.Submission{AssignmentID: hw2.ID, StudentID: alice.ID, Grade: &grade95})
+	db.Create(&models.Submission{AssignmentID: hw1.ID, StudentID: bob.ID})
+
+	r := setupChapterRouter(db, "test-secret")
+	get := func(token, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	w := get(teacherToken, "/api/v1/chapters/1/class-stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var aggregate envelope[services.ChapterClassStats]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &aggregate))
+	assert.Equal(t, 2, aggregate.Data.TotalStudents)
+	assert.Equal(t, 600, aggregate.Data.AvgStudyDurationSecs)
+
+	w = get(teacherToken, "/api/v1/chapters/1/class-stats/stream")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	var streamed []services.StudentProgress
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		var sp services.StudentProgress
+		assert.NoError(t, dec.Decode(&sp))
+		streamed = append(streamed, sp)
+	}
+	assert.Equal(t, aggregate.Data.StudentProgress, streamed)
+	if assert.Len(t, streamed, 2) {
+		assert.Equal(t, alice.ID, streamed[0].StudentID)
+		assert.Equal(t, 87.5, streamed[0].AssignmentAvgScore)
+		assert.Equal(t, 0.0, streamed[1].AssignmentAvgScore)
+	}
+
+	w = get(loginAndGetToken(t, r, "teacher2", "pass123"), "/api/v1/chapters/1/class-stats/stream")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: coTeacher.ID, Role: "assistant"})
+	w = get(loginAndGetToken(t, r, "teacher2", "pass123"), "/api/v1/chapters/1/class-stats")
+	assert.Equal(t, http.StatusOK, w.Code, "teachers assisting the course see its stats")
+	w = get(loginAndGetToken(t, r, "alice", "pass123"), "/api/v1/chapters/1/class-stats/stream")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = get(teacherToken, "/api/v1/chapters/99/class-stats/stream")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -15,14 +15,15 @@ type courseHandlers struct {
 	service *services.CourseService
 }
 
-func newCourseHandlers(db *gorm.DB) *courseHandlers {
-	return &courseHandlers{service: services.NewCourseService(db)}
+func newCourseHandlers(db *gorm.DB, defaultModules []string) *courseHandlers {
+	return &courseHandlers{service: services.NewCourseService(db, defaultModules)}
 }
 
 type createCourseRequest struct {
 	Name           string                 `json:"name" binding:"required"`
 	Code           string                 `json:"code"`
 	Semester       string                 `json:"semester"`
+	TimeZone       string                 `json:"time_zone"`
 	EnabledModules []string               `json:"enabled_modules"`
 	ModuleSettings map[string]interface{} `json:"module_settings"`
 }
@@ -45,12 +46,21 @@ func (h *courseHandlers) Create(c *gin.Context) {
 		Name:           req.Name,
 		Code:           req.Code,
 		Semester:       req.Semester,
+		TimeZone:       req.TimeZone,
 		EnabledModules: req.EnabledModules,
 		ModuleSettings: req.ModuleSettings,
 	}
 
 	course, err := h.service.CreateCourse(c.Request.Context(), user, svcReq)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidModuleSettings) {
+			respondError(c, http.StatusBadRequest, "INVALID_MODULE_SETTINGS", err.Error(), nil)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidTimeZone) {
+			respondError(c, http.StatusBadRequest, "INVALID_TIME_ZONE", "time_zone must be an IANA zone such as Asia/Shanghai", nil)
+			return
+		}
 		if errors.Is(err, services.ErrAccessDeniedService) {
 			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
 			return
@@ -187,6 +197,10 @@ func (h *courseHandlers) UpdateModules(c *gin.Context) {
 			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
 			return
 		}
+		if errors.Is(err, services.ErrInvalidModuleSettings) {
+			respondError(c, http.StatusBadRequest, "INVALID_MODULE_SETTINGS", err.Error(), nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", "failed to update modules", nil)
 		return
 	}
@@ -197,3 +211,222 @@ func (h *courseHandlers) UpdateModules(c *gin.Context) {
 		"module_settings": settings,
 	})
 }
+
+type updateTimeZoneRequest struct {
+	TimeZone string `json:"time_zone"`
+}
+
+// UpdateTimeZone sets the zone quiz deadlines of the course are entered and shown in
+// PUT /courses/:courseId/time-zone
+func (h *courseHandlers) UpdateTimeZone(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+
+	var req updateTimeZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "invalid request", nil)
+		return
+	}
+
+	user := services.UserInfo{ID: u.ID, Role: u.Role}
+	course, err := h.service.UpdateTimeZone(c.Request.Context(), uint(courseID), user, req.TimeZone)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTimeZone):
+			respondError(c, http.StatusBadRequest, "INVALID_TIME_ZONE", "time_zone must be an IANA zone such as Asia/Shanghai", nil)
+		case errors.Is(err, services.ErrCourseNotFoundService):
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDeniedService):
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", "failed to update time zone", nil)
+		}
+		return
+	}
+
+	respondOK(c, course)
+}
+
+type updateEnrollmentRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=student assistant"`
+}
+
+// UpdateEnrollmentRole switches an enrolled user between student and assistant
+// PUT /courses/:courseId/enrollments/:userId/role
+func (h *courseHandlers) UpdateEnrollmentRole(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid user id", nil)
+		return
+	}
+
+	var req updateEnrollmentRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "INVALID_REQUEST", err)
+		return
+	}
+
+	user := services.UserInfo{ID: u.ID, Role: u.Role}
+	enrollment, err := h.service.UpdateEnrollmentRole(c.Request.Context(), uint(courseID), uint(userID), user, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidEnrollmentRole):
+			respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "role must be student or assistant", nil)
+		case errors.Is(err, services.ErrCourseNotFoundService):
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrEnrollmentNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "user is not enrolled in this course", nil)
+		case errors.Is(err, services.ErrAccessDeniedService):
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "UPDATE_FAILED", "failed to update enrollment", nil)
+		}
+		return
+	}
+
+	respondOK(c, enrollment)
+}
+
+// Unenroll lets a student leave a course they have no work in
+// DELETE /courses/:courseId/enrollments/me
+func (h *courseHandlers) Unenroll(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+
+	user := services.UserInfo{ID: u.ID, Role: u.Role}
+	if err := h.service.Unenroll(c.Request.Context(), uint(courseID), user); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCourseNotFoundService):
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrEnrollmentNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "you are not enrolled in this course", nil)
+		case errors.Is(err, services.ErrAccessDeniedService):
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "only students can leave a course", nil)
+		case errors.Is(err, services.ErrEnrollmentHasWork):
+			respondError(c, http.StatusConflict, "ENROLLMENT_HAS_WORK", "you have submissions or quiz attempts in this course; ask the teacher to remove you", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "UNENROLL_FAILED", "failed to leave course", nil)
+		}
+		return
+	}
+
+	respondOK(c, gin.H{"course_id": courseID, "unenrolled": true})
+}
+
+type purgeCourseRequest struct {
+	Confirm string `json:"confirm"`
+}
+
+// Purge permanently deletes a course and everything in it; the body must
+// repeat the course name as confirmation
+// DELETE /courses/:courseId
+func (h *courseHandlers) Purge(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+
+	var req purgeCourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "INVALID_REQUEST", err)
+		return
+	}
+
+	user := services.UserInfo{ID: u.ID, Role: u.Role}
+	result, err := h.service.PurgeCourse(c.Request.Context(), uint(courseID), user, req.Confirm)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCourseNotFoundService):
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDeniedService):
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+		case errors.Is(err, services.ErrPurgeNotConfirmed):
+			respondError(c, http.StatusBadRequest, "CONFIRMATION_REQUIRED", "confirm must be the course name", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "DELETE_FAILED", "failed to delete course", nil)
+		}
+		return
+	}
+
+	respondOK(c, result)
+}
+
+// Clone copies a course's material into a new course for another semester
+// POST /courses/:courseId/clone?semester=2025-spring
+func (h *courseHandlers) Clone(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	idStr := c.Param("courseId")
+	courseID, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+
+	user := services.UserInfo{ID: u.ID, Role: u.Role}
+	result, err := h.service.CloneCourse(c.Request.Context(), uint(courseID), user, c.Query("semester"))
+	if err != nil {
+		if errors.Is(err, services.ErrCourseNotFoundService) {
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+			return
+		}
+		if errors.Is(err, services.ErrAccessDeniedService) {
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "CLONE_COURSE_FAILED", "clone course failed", nil)
+		return
+	}
+
+	respondCreated(c, gin.H{
+		"course_id": result.Course.ID,
+		"course":    result.Course,
+		"copied": gin.H{
+			"chapters":    result.Chapters,
+			"quizzes":     result.Quizzes,
+			"questions":   result.Questions,
+			"assignments": result.Assignments,
+			"resources":   result.Resources,
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+type courseOverviewHandlers struct {
+	service *services.CourseOverviewService
+}
+
+func newCourseOverviewHandlers(db *gorm.DB) *courseOverviewHandlers {
+	return &courseOverviewHandlers{service: services.NewCourseOverviewService(db)}
+}
+
+// GetOverview returns a course page in one payload, with up to ?limit= items
+// per list
+// GET /courses/:courseId/overview
+func (h *courseOverviewHandlers) GetOverview(c *gin.Context) {
+	u, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course id", nil)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.DefaultOverviewLimit)))
+
+	user := services.UserInfo{ID: u.ID, Role: u.Role}
+	overview, err := h.service.GetOverview(c.Request.Context(), uint(courseID), user, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "ACCESS_DENIED", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load course overview", nil)
+		}
+		return
+	}
+	respondOK(c, overview)
+}
@@ -0,0 +1,188 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupCourseOverviewTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Course{},
+		&models.CourseEnrollment{},
+		&models.Chapter{},
+		&models.ChapterProgress{},
+		&models.Quiz{},
+		&models.QuizAttempt{},
+		&models.QuizAttemptGrant{},
+		&models.Assignment{},
+		&models.Submission{},
+		&models.StudentGroupMember{},
+		&models.Announcement{},
+		&models.AnnouncementRead{},
+		&models.AttendanceSession{},
+		&models.AttendanceRecord{},
+	)
+	assert.NoError(t, err)
+
+	return db
+}
+
+func setupCourseOverviewRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
+	hOverview := newCourseOverviewHandlers(db)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
+	{
+		api.GET("/courses/:courseId/overview", hOverview.GetOverview)
+	}
+
+	return r
+}
+
+func TestGetCourseOverview_ByRole(t *testing.T) {
+	db := setupCourseOverviewTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	createCourseTestUser(t, db, "outsider", "pass123", "student")
+
+	course := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID, Role: "student"})
+
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		chapter := models.Chapter{CourseID: course.ID, Title: fmt.Sprintf("chapter %d", i), OrderNum: i}
+		db.Create(&chapter)
+		if i == 1 {
+			db.Create(&models.ChapterProgress{ChapterID: chapter.ID, StudentID: student.ID, CompletedAt: &now})
+		}
+	}
+
+	soon := now.Add(48 * time.Hour)
+	open := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "open", IsPublished: true, Deadline: &soon}
+	done := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "done", IsPublished: true}
+	draft := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "draft"}
+	for _, a := range []*models.Assignment{&open, &done, &draft} {
+		db.Create(a)
+	}
+	db.Create(&models.Submission{AssignmentID: done.ID, StudentID: student.ID, Content: "mine"})
+
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "quiz", IsPublished: true, MaxAttempts: 1, EndTime: &soon}
+	db.Create(&quiz)
+	db.Create(&models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "unpublished", MaxAttempts: 1})
+
+	for i := 0; i < 3; i++ {
+		db.Create(&models.Announcement{CourseID: course.ID, CreatedByID: teacher.ID, Title: fmt.Sprintf("news %d", i), Content: "..."})
+	}
+	pinned := models.Announcement{CourseID: course.ID, CreatedByID: teacher.ID, Title: "pinned", Content: "...", Pinned: true}
+	db.Create(&pinned)
+	db.Create(&models.AnnouncementRead{AnnouncementID: pinned.ID, UserID: student.ID, ReadAt: now})
+
+	past := now.Add(-time.Hour)
+	for i := 0; i < 2; i++ {
+		session := models.AttendanceSession{CourseID: course.ID, StartedByID: teacher.ID, StartAt: past, EndAt: past, Code: "123456"}
+		db.Create(&session)
+		if i == 0 {
+			db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: student.ID, CheckedInAt: past})
+		}
+	}
+
+	r := setupCourseOverviewRouter(db, "test-secret")
+	get := func(username, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/courses/%d/overview%s", course.ID, query), nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("student1", "?limit=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var studentResp envelope[services.CourseOverview]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &studentResp))
+	overview := studentResp.Data
+	assert.Equal(t, "student", overview.View)
+	assert.Nil(t, overview.Staff)
+
+	assert.Len(t, overview.Chapters.Items, 2)
+	assert.EqualValues(t, 3, overview.Chapters.Total)
+	assert.True(t, overview.Chapters.HasMore)
+	if assert.NotNil(t, overview.Chapters.Items[0].Completed) {
+		assert.True(t, *overview.Chapters.Items[0].Completed)
+		assert.False(t, *overview.Chapters.Items[1].Completed)
+	}
+
+	assert.EqualValues(t, 2, overview.Assignments.Total)
+	assert.False(t, overview.Assignments.HasMore)
+	for _, a := range overview.Assignments.Items {
+		if assert.NotNil(t, a.Submitted) {
+			assert.Equal(t, a.ID == done.ID, *a.Submitted)
+		}
+	}
+
+	if assert.Len(t, overview.Quizzes.Items, 1) && assert.NotNil(t, overview.Quizzes.Items[0].AttemptsUsed) {
+		assert.Equal(t, 0, *overview.Quizzes.Items[0].AttemptsUsed)
+	}
+
+	assert.EqualValues(t, 4, overview.Announcements.Total)
+	if assert.Len(t, overview.Announcements.Items, 2) {
+		assert.Equal(t, pinned.ID, overview.Announcements.Items[0].ID)
+		assert.True(t, overview.Announcements.Items[0].Read)
+	}
+
+	assert.Equal(t, 2, overview.Attendance.Sessions)
+	if assert.NotNil(t, overview.Attendance.Rate) {
+		assert.InDelta(t, 0.5, *overview.Attendance.Rate, 1e-9)
+	}
+	if assert.NotNil(t, overview.Progress) {
+		assert.Equal(t, services.OverviewProgress{
+			CompletedChapters:   1,
+			TotalChapters:       3,
+			PendingAssignments:  1,
+			PendingQuizzes:      1,
+			UnreadAnnouncements: 3,
+		}, *overview.Progress)
+	}
+
+	w = get("teacher1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var teacherResp envelope[services.CourseOverview]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &teacherResp))
+	overview = teacherResp.Data
+	assert.Equal(t, "staff", overview.View)
+	assert.Nil(t, overview.Progress)
+	assert.Len(t, overview.Chapters.Items, 3)
+	assert.Nil(t, overview.Chapters.Items[0].Completed)
+	assert.EqualValues(t, 3, overview.Assignments.Total)
+	assert.EqualValues(t, 2, overview.Quizzes.Total)
+	assert.Nil(t, overview.Attendance.Attended)
+	if assert.NotNil(t, overview.Staff) {
+		assert.EqualValues(t, 1, overview.Staff.Students)
+		assert.EqualValues(t, 1, overview.Staff.PendingGrading)
+		assert.EqualValues(t, 1, overview.Staff.UnpublishedQuizzes)
+	}
+
+	w = get("outsider", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -27,20 +31,25 @@ func setupCourseTestDB(t *testing.T) *gorm.DB {
 }
 
 func setupCourseRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
-	hCourse := newCourseHandlers(db)
-	hAuth := newAuthHandlers(db, jwtSecret)
+	hCourse := newCourseHandlers(db, nil)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
 
 	r := gin.New()
 	r.POST("/auth/login", hAuth.Login)
 
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthRequired(jwtSecret))
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
 	{
 		api.GET("/courses", hCourse.List)
 		api.POST("/courses", hCourse.Create)
 		api.GET("/courses/:courseId", hCourse.Get)
 		api.GET("/courses/:courseId/modules", hCourse.GetModules)
 		api.PUT("/courses/:courseId/modules", hCourse.UpdateModules)
+		api.POST("/courses/:courseId/clone", hCourse.Clone)
+		api.PUT("/courses/:courseId/enrollments/:userId/role", hCourse.UpdateEnrollmentRole)
+		api.DELETE("/courses/:courseId/enrollments/me", hCourse.Unenroll)
+		api.DELETE("/courses/:courseId", hCourse.Purge)
+		api.PUT("/courses/:courseId/time-zone", hCourse.UpdateTimeZone)
 	}
 
 	return r
@@ -129,6 +138,45 @@ func TestListCourses_AsStudent(t *testing.T) {
 	assert.Equal(t, "Enrolled Course", resp.Data[0].Name)
 }
 
+func TestListCourses_IncludesAssistedCourses(t *testing.T) {
+	db := setupCourseTestDB(t)
+	owner := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	coTeacher := createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	ta := createCourseTestUser(t, db, "ta1", "pass123", "student")
+
+	assisted := models.Course{Name: "Assisted Course", TeacherID: owner.ID}
+	db.Create(&assisted)
+	left := models.Course{Name: "Left Course", TeacherID: owner.ID}
+	db.Create(&left)
+	db.Create(&models.Course{Name: "Unrelated Course", TeacherID: owner.ID})
+	db.Create(&models.Course{Name: "Own Course", TeacherID: coTeacher.ID})
+	db.Create(&models.CourseEnrollment{CourseID: assisted.ID, UserID: coTeacher.ID, Role: "assistant"})
+	db.Create(&models.CourseEnrollment{CourseID: assisted.ID, UserID: ta.ID, Role: "assistant"})
+	gone := models.CourseEnrollment{CourseID: left.ID, UserID: coTeacher.ID, Role: "assistant"}
+	db.Create(&gone)
+	db.Delete(&gone)
+
+	r := setupCourseRouter(db, "test-secret")
+	names := func(username string) []string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/courses", nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[[]models.Course]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		var out []string
+		for _, c := range resp.Data {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"Own Course", "Assisted Course"}, names("teacher2"))
+	assert.Equal(t, []string{"Assisted Course"}, names("ta1"))
+	assert.Len(t, names("teacher1"), 3)
+}
+
 func TestCreateCourse_Success(t *testing.T) {
 	db := setupCourseTestDB(t)
 	createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
@@ -223,3 +271,407 @@ func TestUpdateModules_Success(t *testing.T) {
 	modules := resp.Data["enabled_modules"].([]interface{})
 	assert.Len(t, modules, 2)
 }
+
+func TestUpdateModules_RejectsInvalidQuizLimits(t *testing.T) {
+	db := setupCourseTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+
+	course := models.Course{Name: "My Course", TeacherID: teacher.ID}
+	db.Create(&course)
+
+	r := setupCourseRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/courses/1/modules", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(`{"enabled_modules":["core.ai"],"module_settings":{"quiz":{"max_options":1000}}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "quiz.max_options must be an integer between 2 and 26")
+
+	w = do(`{"enabled_modules":["core.ai"],"module_settings":{"quiz":{"max_answers_bytes":2048.5}}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(`{"enabled_modules":["core.ai"],"module_settings":{"quiz":{"max_options":4,"max_answers_bytes":2048}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCloneCourse_CopiesMaterialWithoutStudentData(t *testing.T) {
+	db := setupCourseTestDB(t)
+	assert.NoError(t, db.AutoMigrate(
+		&models.Chapter{},
+		&models.Quiz{},
+		&models.Question{},
+		&models.QuizAttempt{},
+		&models.QuizAttemptGrant{},
+		&models.Assignment{},
+		&models.AssignmentAttachment{},
+		&models.Submission{},
+		&models.Resource{},
+	))
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "EM Fields", Semester: "2024-fall", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID, Role: "student"})
+	chapter := models.Chapter{CourseID: course.ID, Title: "Electrostatics"}
+	db.Create(&chapter)
+	quiz := models.Quiz{CourseID: course.ID, ChapterID: &chapter.ID, CreatedByID: teacher.ID, Title: "Quiz 1", IsPublished: true, MaxAttempts: 1}
+	db.Create(&quiz)
+	db.Create(&models.Question{QuizID: quiz.ID, Type: "true_false", Content: "E is conservative", Answer: "true", Points: 1})
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID})
+	assignment := models.Assignment{CourseID: course.ID, ChapterID: &chapter.ID, TeacherID: teacher.ID, Title: "HW1"}
+	db.Create(&assignment)
+	db.Create(&models.Submission{AssignmentID: assignment.ID, StudentID: student.ID, Content: "answer"})
+	db.Create(&models.Resource{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Slides", Type: "link", URL: "https://example.com"})
+
+	r := setupCourseRouter(db, "test-secret")
+
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/courses/1/clone?semester=2025-spring", nil)
+	req.Header.Set("Authorization", "Bearer "+otherToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/courses/1/clone?semester=2025-spring", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var resp envelope[struct {
+		CourseID uint           `json:"course_id"`
+		Copied   map[string]int `json:"copied"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, map[string]int{"chapters": 1, "quizzes": 1, "questions": 1, "assignments": 1, "resources": 1}, resp.Data.Copied)
+
+	var cloned models.Course
+	assert.NoError(t, db.First(&cloned, resp.Data.CourseID).Error)
+	assert.Equal(t, "2025-spring", cloned.Semester)
+
+	var clonedQuiz models.Quiz
+	assert.NoError(t, db.Where("course_id = ?", cloned.ID).First(&clonedQuiz).Error)
+	assert.False(t, clonedQuiz.IsPublished)
+	var clonedChapter models.Chapter
+	assert.NoError(t, db.Where("course_id = ?", cloned.ID).First(&clonedChapter).Error)
+	assert.Equal(t, clonedChapter.ID, *clonedQuiz.ChapterID)
+
+	var count int64
+	db.Model(&models.CourseEnrollment{}).Where("course_id = ?", cloned.ID).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.QuizAttempt{}).Where("quiz_id = ?", clonedQuiz.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestUpdateEnrollmentRole(t *testing.T) {
+	db := setupCourseTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.StudentGroup{}, &models.StudentGroupMember{}, &models.AuditLog{}))
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	ta := createCourseTestUser(t, db, "student1", "pass123", "student")
+	outsider := createCourseTestUser(t, db, "student2", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: ta.ID, Role: "student"})
+	group := models.StudentGroup{CourseID: course.ID, Name: "G1"}
+	db.Create(&group)
+	db.Create(&models.StudentGroupMember{GroupID: group.ID, CourseID: course.ID, UserID: ta.ID})
+
+	r := setupCourseRouter(db, "test-secret")
+	put := func(username string, userID uint, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/courses/1/enrollments/"+strconv.FormatUint(uint64(userID), 10)+"/role", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := put("teacher1", ta.ID, `{"role":"assistant"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[models.CourseEnrollment]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "assistant", resp.Data.Role)
+	assert.Equal(t, ta.ID, resp.Data.UserID)
+
+	var stored models.CourseEnrollment
+	db.Where("course_id = ? AND user_id = ?", course.ID, ta.ID).First(&stored)
+	assert.Equal(t, "assistant", stored.Role)
+	var memberships int64
+	db.Model(&models.StudentGroupMember{}).Where("user_id = ?", ta.ID).Count(&memberships)
+	assert.Equal(t, int64(0), memberships)
+
+	assert.Equal(t, http.StatusBadRequest, put("teacher1", ta.ID, `{"role":"teacher"}`).Code)
+	assert.Equal(t, http.StatusNotFound, put("teacher1", outsider.ID, `{"role":"assistant"}`).Code)
+	assert.Equal(t, http.StatusForbidden, put("teacher2", ta.ID, `{"role":"student"}`).Code)
+
+	w = put("teacher1", ta.ID, `{"role":"student"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.Where("course_id = ? AND user_id = ?", course.ID, ta.ID).First(&stored)
+	assert.Equal(t, "student", stored.Role)
+}
+
+func TestUnenrollSelf(t *testing.T) {
+	db := setupCourseTestDB(t)
+	assert.NoError(t, db.AutoMigrate(
+		&models.StudentGroup{},
+		&models.StudentGroupMember{},
+		&models.Assignment{},
+		&models.Submission{},
+		&models.Quiz{},
+		&models.QuizAttempt{},
+	))
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	leaver := createCourseTestUser(t, db, "student1", "pass123", "student")
+	worker := createCourseTestUser(t, db, "student2", "pass123", "student")
+	quizzer := createCourseTestUser(t, db, "student3", "pass123", "student")
+	ta := createCourseTestUser(t, db, "student4", "pass123", "student")
+	createCourseTestUser(t, db, "student5", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	for _, id := range []uint{leaver.ID, worker.ID, quizzer.ID} {
+		db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: id, Role: "student"})
+	}
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: ta.ID, Role: "assistant"})
+	group := models.StudentGroup{CourseID: course.ID, Name: "G1"}
+	db.Create(&group)
+	db.Create(&models.StudentGroupMember{GroupID: group.ID, CourseID: course.ID, UserID: leaver.ID})
+	assignment := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "HW"}
+	db.Create(&assignment)
+	db.Create(&models.Submission{AssignmentID: assignment.ID, StudentID: worker.ID, Content: "work"})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz"}
+	db.Create(&quiz)
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: quizzer.ID, AttemptNumber: 1})
+	// Work in a deleted assignment still counts.
+	db.Delete(&assignment)
+
+	r := setupCourseRouter(db, "test-secret")
+	leave := func(username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/courses/1/enrollments/me", nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, leave("teacher1").Code)
+	assert.Equal(t, http.StatusForbidden, leave("student4").Code)
+	assert.Equal(t, http.StatusNotFound, leave("student5").Code)
+	assert.Equal(t, http.StatusConflict, leave("student2").Code)
+	assert.Equal(t, http.StatusConflict, leave("student3").Code)
+
+	assert.Equal(t, http.StatusOK, leave("student1").Code)
+	var count int64
+	db.Model(&models.CourseEnrollment{}).Where("course_id = ? AND user_id = ?", course.ID, leaver.ID).Count(&count)
+	assert.Zero(t, count)
+	db.Unscoped().Model(&models.CourseEnrollment{}).Where("course_id = ? AND user_id = ?", course.ID, leaver.ID).Count(&count)
+	assert.Equal(t, int64(1), count, "enrollment is soft-deleted")
+	db.Model(&models.StudentGroupMember{}).Where("user_id = ?", leaver.ID).Count(&count)
+	assert.Zero(t, count)
+	assert.Equal(t, http.StatusNotFound, leave("student1").Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/courses", nil)
+	req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, "student1", "pass123"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var courses envelope[[]models.Course]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &courses))
+	assert.Empty(t, courses.Data)
+}
+
+func TestUpdateTimeZone(t *testing.T) {
+	db := setupCourseTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+
+	r := setupCourseRouter(db, "test-secret")
+	put := func(username string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/courses/1/time-zone", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := put("teacher1", `{"time_zone":"Asia/Shanghai"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[models.Course]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Asia/Shanghai", resp.Data.TimeZone)
+
+	var stored models.Course
+	db.First(&stored, course.ID)
+	assert.Equal(t, "Asia/Shanghai", stored.TimeZone)
+
+	assert.Equal(t, http.StatusBadRequest, put("teacher1", `{"time_zone":"Mars/Olympus"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put("teacher1", `{"time_zone":"Local"}`).Code)
+	assert.Equal(t, http.StatusForbidden, put("teacher2", `{"time_zone":"UTC"}`).Code)
+
+	assert.Equal(t, http.StatusOK, put("teacher1", `{"time_zone":""}`).Code)
+	db.First(&stored, course.ID)
+	assert.Equal(t, "", stored.TimeZone)
+}
+
+func TestPurgeCourse(t *testing.T) {
+	db := setupCourseTestDB(t)
+	assert.NoError(t, db.AutoMigrate(
+		&models.AuditLog{},
+		&models.Chapter{},
+		&models.ChapterProgress{},
+		&models.Quiz{},
+		&models.Question{},
+		&models.QuizAttempt{},
+		&models.QuizAttemptGrant{},
+		&models.QuizFeedback{},
+		&models.Assignment{},
+		&models.AssignmentAttachment{},
+		&models.AssignmentExtension{},
+		&models.SimilarityReport{},
+		&models.Submission{},
+		&models.SubmissionComment{},
+		&models.StudentGroup{},
+		&models.StudentGroupMember{},
+		&models.Resource{},
+		&models.Announcement{},
+		&models.AnnouncementRead{},
+		&models.AttendanceSession{},
+		&models.AttendanceRecord{},
+		&models.WritingSubmission{},
+		&models.StudentLearningProfile{},
+		&models.LearningEvent{},
+		&models.ChatTranscript{},
+		&models.ChatTranscriptMessage{},
+	))
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	// seed fills a course with one of everything and returns its id.
+	seed := func(name string) uint {
+		course := models.Course{Name: name, TeacherID: teacher.ID}
+		assert.NoError(t, db.Create(&course).Error)
+		db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID, Role: "student"})
+		chapter := models.Chapter{CourseID: course.ID, Title: "Ch1"}
+		db.Create(&chapter)
+		db.Create(&models.ChapterProgress{ChapterID: chapter.ID, StudentID: student.ID})
+		db.Create(&models.Resource{CourseID: course.ID, ChapterID: &chapter.ID, CreatedByID: teacher.ID, Title: "R", Type: "link", URL: "https://example.com"})
+
+		quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz"}
+		db.Create(&quiz)
+		db.Create(&models.Question{QuizID: quiz.ID, Type: "fill_blank", Content: "Q", Answer: "x"})
+		attempt := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 1}
+		db.Create(&attempt)
+		db.Create(&models.QuizAttemptGrant{QuizID: quiz.ID, StudentID: student.ID, GrantedByID: teacher.ID})
+		db.Create(&models.QuizFeedback{AttemptID: attempt.ID, QuizID: quiz.ID, StudentID: student.ID, Difficulty: 3})
+
+		assignment := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "HW"}
+		db.Create(&assignment)
+		db.Create(&models.AssignmentAttachment{AssignmentID: assignment.ID, Title: "A", URL: "https://example.com/a.pdf"})
+		db.Create(&models.AssignmentExtension{AssignmentID: assignment.ID, StudentID: student.ID, Deadline: time.Now(), GrantedByID: teacher.ID})
+		db.Create(&models.SimilarityReport{AssignmentID: assignment.ID, RequestedByID: teacher.ID, Status: "done"})
+		submission := models.Submission{AssignmentID: assignment.ID, StudentID: student.ID, Content: "work"}
+		db.Create(&submission)
+		db.Create(&models.SubmissionComment{SubmissionID: submission.ID, AuthorID: student.ID, Body: "?"})
+		group := models.StudentGroup{CourseID: course.ID, Name: "G"}
+		db.Create(&group)
+		db.Create(&models.StudentGroupMember{GroupID: group.ID, CourseID: course.ID, UserID: student.ID})
+		db.Create(&models.WritingSubmission{StudentID: student.ID, CourseID: course.ID, WritingType: "abstract"})
+
+		announcement := models.Announcement{CourseID: course.ID, Title: "Hi", Content: "Hi", CreatedByID: teacher.ID}
+		db.Create(&announcement)
+		db.Create(&models.AnnouncementRead{AnnouncementID: announcement.ID, UserID: student.ID})
+		session := models.AttendanceSession{CourseID: course.ID, StartedByID: teacher.ID, Code: "123456"}
+		db.Create(&session)
+		db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: student.ID})
+
+		db.Create(&models.StudentLearningProfile{StudentID: student.ID, CourseID: course.ID})
+		db.Create(&models.LearningEvent{StudentID: student.ID, CourseID: &course.ID, EventType: "chat"})
+		transcript := models.ChatTranscript{SessionKey: name, UserID: student.ID, CourseID: &course.ID}
+		db.Create(&transcript)
+		db.Create(&models.ChatTranscriptMessage{TranscriptID: transcript.ID, Role: "user", Content: "hi"})
+
+		// Soft-deleted rows go too.
+		deleted := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Old"}
+		db.Create(&deleted)
+		db.Delete(&deleted)
+		return course.ID
+	}
+	target := seed("电磁场 Demo")
+	kept := seed("Waves")
+
+	r := setupCourseRouter(db, "test-secret")
+	purge := func(username, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/courses/"+strconv.Itoa(int(target)), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, purge("teacher2", `{"confirm":"电磁场 Demo"}`).Code)
+	assert.Equal(t, http.StatusForbidden, purge("student1", `{"confirm":"电磁场 Demo"}`).Code)
+	w := purge("teacher1", `{"confirm":"Waves"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "CONFIRMATION_REQUIRED")
+	assert.Equal(t, http.StatusBadRequest, purge("teacher1", `{}`).Code)
+
+	w = purge("teacher1", `{"confirm":"电磁场 Demo"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.CoursePurgeResult]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, target, resp.Data.CourseID)
+	assert.Equal(t, int64(2), resp.Data.Deleted["quizzes"])
+	for _, table := range []string{
+		"courses", "course_enrollments", "chapters", "chapter_progresses", "resources",
+		"questions", "quiz_attempts", "quiz_attempt_grants", "quiz_feedbacks",
+		"assignments", "assignment_attachments", "assignment_extensions", "similarity_reports",
+		"submissions", "submission_comments", "student_groups", "student_group_members",
+		"announcements", "announcement_reads", "attendance_sessions", "attendance_records",
+		"writing_submissions", "student_learning_profiles", "learning_events",
+		"chat_transcripts", "chat_transcript_messages",
+	} {
+		assert.Equal(t, int64(1), resp.Data.Deleted[table], table)
+	}
+
+	// Nothing of the purged course is left, soft-deleted or not, and the
+	// other course is untouched.
+	var count int64
+	db.Unscoped().Model(&models.Quiz{}).Where("course_id = ?", target).Count(&count)
+	assert.Zero(t, count)
+	db.Unscoped().Model(&models.Course{}).Where("id = ?", target).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.QuizAttempt{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+	db.Model(&models.SubmissionComment{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+	db.Unscoped().Model(&models.Quiz{}).Where("course_id = ?", kept).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	var logs []models.AuditLog
+	db.Find(&logs)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, services.AuditCoursePurge, logs[0].Action)
+		assert.Equal(t, target, logs[0].TargetID)
+		assert.Equal(t, teacher.ID, logs[0].ActorID)
+	}
+
+	assert.Equal(t, http.StatusNotFound, purge("teacher1", `{"confirm":"电磁场 Demo"}`).Code)
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+type dashboardHandlers struct {
+	service *services.DashboardService
+}
+
+func newDashboardHandlers(db *gorm.DB) *dashboardHandlers {
+	return &dashboardHandlers{service: services.NewDashboardService(db)}
+}
+
+// GetDashboard returns the caller's home dashboard for their role
+// GET /me/dashboard
+func (h *dashboardHandlers) GetDashboard(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	dashboard, err := h.service.GetDashboard(c.Request.Context(), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load dashboard", nil)
+		return
+	}
+	respondOK(c, dashboard)
+}
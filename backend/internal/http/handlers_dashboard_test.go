@@ -0,0 +1,148 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupDashboardTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Course{},
+		&models.CourseEnrollment{},
+		&models.Quiz{},
+		&models.QuizAttempt{},
+		&models.QuizAttemptGrant{},
+		&models.Assignment{},
+		&models.Submission{},
+		&models.StudentGroupMember{},
+		&models.AttendanceSession{},
+		&models.AttendanceRecord{},
+	)
+	assert.NoError(t, err)
+
+	return db
+}
+
+func setupDashboardRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
+	hDashboard := newDashboardHandlers(db)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
+	{
+		api.GET("/me/dashboard", hDashboard.GetDashboard)
+	}
+
+	return r
+}
+
+func TestGetDashboard_ByRole(t *testing.T) {
+	db := setupDashboardTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	classmate := createCourseTestUser(t, db, "student2", "pass123", "student")
+
+	course := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	db.Create(&course)
+	quiet := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	db.Create(&quiet)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: classmate.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: quiet.ID, UserID: student.ID, Role: "student"})
+
+	now := time.Now()
+	soon := now.Add(48 * time.Hour)
+	past := now.Add(-time.Hour)
+	open := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "open", IsPublished: true, Deadline: &soon}
+	done := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "done", IsPublished: true}
+	closed := models.Assignment{CourseID: course.ID, TeacherID: teacher.ID, Title: "closed", IsPublished: true, Deadline: &past}
+	for _, a := range []*models.Assignment{&open, &done, &closed} {
+		db.Create(a)
+	}
+	db.Create(&models.Submission{AssignmentID: done.ID, StudentID: student.ID, Content: "mine"})
+	db.Create(&models.Submission{AssignmentID: done.ID, StudentID: classmate.ID, Content: "theirs"})
+	db.Create(&models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "quiz", IsPublished: true, MaxAttempts: 1, EndTime: &soon})
+
+	for i := 0; i < 4; i++ {
+		session := models.AttendanceSession{CourseID: course.ID, StartedByID: teacher.ID, StartAt: past, EndAt: past, Code: "123456"}
+		if i == 3 {
+			session.EndAt, session.IsActive = soon, true
+		}
+		db.Create(&session)
+		if i > 0 {
+			db.Create(&models.AttendanceRecord{SessionID: session.ID, StudentID: student.ID, CheckedInAt: past})
+		}
+	}
+	// The explicit false is skipped by GORM on create, so close the old sessions afterwards.
+	db.Model(&models.AttendanceSession{}).Where("end_at < ?", now).Update("is_active", false)
+
+	r := setupDashboardRouter(db, "test-secret")
+	get := func(username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/me/dashboard", nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("student1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var studentResp envelope[struct {
+		Role     string                          `json:"role"`
+		Courses  []services.StudentCourseSummary `json:"courses"`
+		Upcoming []services.UpcomingItem         `json:"upcoming"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &studentResp))
+	assert.Equal(t, "student", studentResp.Data.Role)
+	assert.Len(t, studentResp.Data.Upcoming, 2)
+	if assert.Len(t, studentResp.Data.Courses, 2) {
+		waves, fields := studentResp.Data.Courses[0], studentResp.Data.Courses[1]
+		assert.Equal(t, "Waves", waves.CourseName)
+		assert.Nil(t, waves.AttendanceRate)
+		assert.Equal(t, 0, waves.PendingAssignments)
+
+		assert.Equal(t, "Fields", fields.CourseName)
+		assert.Equal(t, 1, fields.PendingAssignments)
+		assert.Equal(t, 1, fields.UpcomingQuizzes)
+		if assert.NotNil(t, fields.AttendanceRate) {
+			assert.InDelta(t, 0.75, *fields.AttendanceRate, 1e-9)
+		}
+	}
+
+	w = get("teacher1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var teacherResp envelope[struct {
+		Role    string                          `json:"role"`
+		Courses []services.TeacherCourseSummary `json:"courses"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &teacherResp))
+	assert.Equal(t, "teacher", teacherResp.Data.Role)
+	if assert.Len(t, teacherResp.Data.Courses, 2) {
+		waves, fields := teacherResp.Data.Courses[0], teacherResp.Data.Courses[1]
+		assert.Equal(t, int64(0), waves.PendingGrading)
+		assert.Nil(t, waves.ActiveSession)
+		assert.Equal(t, int64(2), fields.PendingGrading)
+		if assert.NotNil(t, fields.ActiveSession) {
+			assert.Equal(t, "123456", fields.ActiveSession.Code)
+		}
+	}
+}
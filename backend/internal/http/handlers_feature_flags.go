@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+type featureFlagHandlers struct {
+	flags *services.FeatureFlags
+}
+
+func newFeatureFlagHandlers(flags *services.FeatureFlags) *featureFlagHandlers {
+	return &featureFlagHandlers{flags: flags}
+}
+
+// ListFeatureFlags returns the feature flags that are on for the caller's role
+// GET /feature-flags
+func (h *featureFlagHandlers) ListFeatureFlags(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+	respondOK(c, gin.H{"flags": h.flags.EnabledFor(user.Role)})
+}
+
+// requireFeature hides a route behind a feature flag. Users the flag is off
+// for get a 404, as if the route did not exist.
+func requireFeature(flags *services.FeatureFlags, flag string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := middleware.GetUser(c)
+		if !ok || !flags.Enabled(flag, user.Role) {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "not found", nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
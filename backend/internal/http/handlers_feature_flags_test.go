@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureFlags_GateRoutes(t *testing.T) {
+	var aiCalls atomic.Int32
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		aiCalls.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]string{"reply": "建议分数: 85\n评语: 推导清晰"})
+	}))
+	defer aiServer.Close()
+
+	db := setupAssignmentTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	assignment := models.Assignment{CourseID: course.ID, Title: "Homework 1", IsPublished: true}
+	db.Create(&assignment)
+	db.Create(&models.Submission{AssignmentID: assignment.ID, StudentID: student.ID, Content: "answer"})
+
+	newRouter := func(overrides map[string][]string) *gin.Engine {
+		features := services.NewFeatureFlags(overrides)
+		hFeatureFlags := newFeatureFlagHandlers(features)
+		hAssignment := newAssignmentHandlers(db, clients.NewAIClient(aiServer.URL), nil, nil)
+		hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: "test-secret"})
+		r := gin.New()
+		r.POST("/auth/login", hAuth.Login)
+		api := r.Group("/api/v1")
+		api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: "test-secret"}))
+		api.GET("/feature-flags", hFeatureFlags.ListFeatureFlags)
+		api.POST("/submissions/:submissionId/ai-grade", requireFeature(features, services.FeatureAIGrading), hAssignment.AIGradeSubmission)
+		return r
+	}
+	do := func(r *gin.Engine, username, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	flags := func(r *gin.Engine, username string) []string {
+		w := do(r, username, http.MethodGet, "/api/v1/feature-flags")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[struct {
+			Flags []string `json:"flags"`
+		}]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.Flags
+	}
+
+	// Off: the route answers 404 without reaching the AI service.
+	r := newRouter(map[string][]string{services.FeatureAIGrading: {}})
+	w := do(r, "teacher1", http.MethodPost, "/api/v1/submissions/1/ai-grade")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.EqualValues(t, 0, aiCalls.Load())
+	assert.Empty(t, flags(r, "teacher1"))
+
+	// Scoped to a role, and flags unknown to the server are passed to clients.
+	r = newRouter(map[string][]string{"peer_review": {"teacher"}})
+	assert.Equal(t, []string{services.FeatureAIGrading, "peer_review"}, flags(r, "teacher1"))
+	assert.Equal(t, []string{services.FeatureAIGrading}, flags(r, "student1"))
+
+	// On by default.
+	w = do(r, "teacher1", http.MethodPost, "/api/v1/submissions/1/ai-grade")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.EqualValues(t, 1, aiCalls.Load())
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type globalProfileHandlers struct {
@@ -36,7 +37,7 @@ func (h *globalProfileHandlers) GetGlobalProfile(c *gin.Context) {
 	}
 
 	var profile models.StudentGlobalProfile
-	result := h.db.Where("student_id = ?", studentID).First(&profile)
+	result := h.db.WithContext(c.Request.Context()).Where("student_id = ?", studentID).First(&profile)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			// Return empty profile if not found
@@ -85,18 +86,27 @@ func (h *globalProfileHandlers) SaveGlobalProfile(c *gin.Context) {
 		UpdatedAt:          &now,
 	}
 
-	// Upsert using ON CONFLICT
-	result := h.db.Save(&profile)
-	if result.Error != nil {
-		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", result.Error.Error(), nil)
+	// Upsert on student_id so a resubmitted profile updates the one row
+	// whatever the driver does with Save
+	err = h.db.WithContext(c.Request.Context()).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "student_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"global_competencies", "total_study_hours", "learning_style", "updated_at"}),
+	}).Create(&profile).Error
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
 		return
 	}
 
 	respondOK(c, profile)
 }
 
-// GetLearningTimeline returns paginated learning events for a student
+// GetLearningTimeline returns learning events for a student, newest first
 // GET /api/v1/students/:studentId/learning-timeline
+//
+// Cursor paging is preferred for the activity feed: pass before_id (older
+// events) or after_id (newer events) with the previous response's next_cursor.
+// Event IDs only grow, so pages stay stable as new events arrive. Without a
+// cursor the legacy page/page_size offset mode is used.
 func (h *globalProfileHandlers) GetLearningTimeline(c *gin.Context) {
 	studentID, err := strconv.ParseUint(c.Param("studentId"), 10, 32)
 	if err != nil {
@@ -122,6 +132,21 @@ func (h *globalProfileHandlers) GetLearningTimeline(c *gin.Context) {
 		pageSize = 20
 	}
 
+	beforeID, err := parseCursor(c.Query("before_id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid before_id", nil)
+		return
+	}
+	afterID, err := parseCursor(c.Query("after_id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid after_id", nil)
+		return
+	}
+	if beforeID != 0 && afterID != 0 {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "use either before_id or after_id, not both", nil)
+		return
+	}
+
 	// Optional course filter
 	courseIDStr := c.Query("course_id")
 	var courseID *uint
@@ -136,11 +161,38 @@ func (h *globalProfileHandlers) GetLearningTimeline(c *gin.Context) {
 	var events []models.LearningEvent
 	var total int64
 
-	query := h.db.Model(&models.LearningEvent{}).Where("student_id = ?", studentID)
+	query := h.db.WithContext(c.Request.Context()).Model(&models.LearningEvent{}).Where("student_id = ?", studentID)
 	if courseID != nil {
 		query = query.Where("course_id = ?", *courseID)
 	}
 
+	switch {
+	case beforeID != 0:
+		if err := query.Where("id < ?", beforeID).Order("id DESC").Limit(pageSize).Find(&events).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load timeline", nil)
+			return
+		}
+		respondOK(c, gin.H{"items": events, "page_size": pageSize, "next_cursor": olderCursor(events, pageSize)})
+		return
+	case afterID != 0:
+		// Take the oldest events after the cursor so none are skipped, then
+		// return them newest first like every other mode.
+		if err := query.Where("id > ?", afterID).Order("id ASC").Limit(pageSize).Find(&events).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load timeline", nil)
+			return
+		}
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+		// The newest ID seen is the cursor for the next poll, even on a short page.
+		next := afterID
+		if len(events) > 0 {
+			next = events[0].ID
+		}
+		respondOK(c, gin.H{"items": events, "page_size": pageSize, "next_cursor": next})
+		return
+	}
+
 	query.Count(&total)
 	query.Order("created_at DESC").
 		Offset((page - 1) * pageSize).
@@ -150,6 +202,28 @@ func (h *globalProfileHandlers) GetLearningTimeline(c *gin.Context) {
 	respondOK(c, gin.H{"items": events, "total": total, "page": page, "page_size": pageSize})
 }
 
+// parseCursor parses an optional event ID cursor; empty means no cursor.
+func parseCursor(raw string) (uint, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return uint(id), nil
+}
+
+// olderCursor returns the oldest event ID on a full page, to pass as the next
+// before_id, or nil once the end of the timeline is reached.
+func olderCursor(events []models.LearningEvent, pageSize int) *uint {
+	if len(events) == 0 || len(events) < pageSize {
+		return nil
+	}
+	id := events[len(events)-1].ID
+	return &id
+}
+
 // RecordLearningEvent creates a new learning event
 // POST /api/v1/learning-events
 type recordLearningEventRequest struct {
@@ -174,7 +248,7 @@ func (h *globalProfileHandlers) RecordLearningEvent(c *gin.Context) {
 		CreatedAt: time.Now(),
 	}
 
-	if err := h.db.Create(&event).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&event).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
 		return
 	}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type timelinePage struct {
+	Items      []models.LearningEvent `json:"items"`
+	NextCursor *uint                  `json:"next_cursor"`
+	Total      *int64                 `json:"total"`
+}
+
+func TestGetLearningTimeline_CursorPaging(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.LearningEvent{}))
+	for i := 0; i < 5; i++ {
+		db.Create(&models.LearningEvent{StudentID: 7, EventType: "chat"})
+	}
+	db.Create(&models.LearningEvent{StudentID: 8, EventType: "chat"})
+
+	h := newGlobalProfileHandlers(db)
+	r := gin.New()
+	r.GET("/students/:studentId/learning-timeline", h.GetLearningTimeline)
+
+	get := func(query string) (int, timelinePage) {
+		req := httptest.NewRequest(http.MethodGet, "/students/7/learning-timeline?"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp envelope[timelinePage]
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+	ids := func(p timelinePage) []uint {
+		out := make([]uint, len(p.Items))
+		for i, e := range p.Items {
+			out[i] = e.ID
+		}
+		return out
+	}
+
+	code, page := get("before_id=6&page_size=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []uint{5, 4}, ids(page))
+	assert.Nil(t, page.Total)
+	if assert.NotNil(t, page.NextCursor) {
+		assert.Equal(t, uint(4), *page.NextCursor)
+	}
+
+	// A new event arriving between pages does not shift the older page.
+	db.Create(&models.LearningEvent{StudentID: 7, EventType: "chat"})
+	_, page = get("before_id=4&page_size=2")
+	assert.Equal(t, []uint{3, 2}, ids(page))
+	_, page = get("before_id=2&page_size=2")
+	assert.Equal(t, []uint{1}, ids(page))
+	assert.Nil(t, page.NextCursor)
+
+	_, page = get("after_id=4&page_size=10")
+	assert.Equal(t, []uint{7, 5}, ids(page))
+	if assert.NotNil(t, page.NextCursor) {
+		assert.Equal(t, uint(7), *page.NextCursor)
+	}
+
+	code, _ = get("before_id=3&after_id=1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("before_id=abc")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Offset mode is unchanged.
+	_, page = get("page=1&page_size=2")
+	assert.Len(t, page.Items, 2)
+	if assert.NotNil(t, page.Total) {
+		assert.Equal(t, int64(6), *page.Total)
+	}
+}
+
+func TestSaveGlobalProfile_UpsertsOneRow(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.StudentGlobalProfile{}))
+
+	h := newGlobalProfileHandlers(db)
+	r := gin.New()
+	r.POST("/students/:studentId/global-profile", h.SaveGlobalProfile)
+	save := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/students/7/global-profile", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, save(`{"global_competencies":"{}","total_study_hours":2,"learning_style":"{}"}`))
+	var first models.StudentGlobalProfile
+	assert.NoError(t, db.First(&first, "student_id = ?", 7).Error)
+
+	assert.Equal(t, http.StatusOK, save(`{"global_competencies":"{\"citation\":0.5}","total_study_hours":5,"learning_style":"{}"}`))
+	var profiles []models.StudentGlobalProfile
+	assert.NoError(t, db.Find(&profiles).Error)
+	if assert.Len(t, profiles, 1) {
+		assert.Equal(t, 5, profiles[0].TotalStudyHours)
+		assert.Equal(t, `{"citation":0.5}`, profiles[0].GlobalCompetencies)
+		if assert.NotNil(t, profiles[0].UpdatedAt) && assert.NotNil(t, first.UpdatedAt) {
+			assert.False(t, profiles[0].UpdatedAt.Before(*first.UpdatedAt))
+		}
+	}
+}
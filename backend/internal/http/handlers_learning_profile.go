@@ -42,7 +42,7 @@ func (h *learningProfileHandlers) GetProfile(c *gin.Context) {
 	}
 
 	var profile models.StudentLearningProfile
-	result := h.db.Where("course_id = ? AND student_id = ?", courseID, studentID).First(&profile)
+	result := h.db.WithContext(c.Request.Context()).Where("course_id = ? AND student_id = ?", courseID, studentID).First(&profile)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "profile not found", nil)
@@ -77,7 +77,7 @@ func (h *learningProfileHandlers) SaveProfile(c *gin.Context) {
 
 	// Upsert profile
 	var profile models.StudentLearningProfile
-	result := h.db.Where("course_id = ? AND student_id = ?", req.CourseID, req.StudentID).First(&profile)
+	result := h.db.WithContext(c.Request.Context()).Where("course_id = ? AND student_id = ?", req.CourseID, req.StudentID).First(&profile)
 
 	if result.Error == gorm.ErrRecordNotFound {
 		// Create new profile
@@ -90,7 +90,7 @@ func (h *learningProfileHandlers) SaveProfile(c *gin.Context) {
 			TotalStudyMinutes: req.TotalStudyMinutes,
 			RecommendedTopics: req.RecommendedTopics,
 		}
-		if err := h.db.Create(&profile).Error; err != nil {
+		if err := h.db.WithContext(c.Request.Context()).Create(&profile).Error; err != nil {
 			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
 			return
 		}
@@ -110,7 +110,7 @@ func (h *learningProfileHandlers) SaveProfile(c *gin.Context) {
 	profile.TotalStudyMinutes = req.TotalStudyMinutes
 	profile.RecommendedTopics = req.RecommendedTopics
 
-	if err := h.db.Save(&profile).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Save(&profile).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
 		return
 	}
@@ -127,7 +127,7 @@ func (h *learningProfileHandlers) ListCourseProfiles(c *gin.Context) {
 	}
 
 	var profiles []models.StudentLearningProfile
-	result := h.db.Where("course_id = ?", courseID).Find(&profiles)
+	result := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID).Find(&profiles)
 	if result.Error != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", result.Error.Error(), nil)
 		return
@@ -1,10 +1,12 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
@@ -33,40 +35,100 @@ func (h *quizHandlers) ListQuizzes(c *gin.Context) {
 		return
 	}
 
+	includeDeleted := c.Query("include_deleted") == "true"
+
 	user, _ := middleware.GetUser(c)
 	data, err := h.service.ListQuizzes(c.Request.Context(), uint(courseID), services.UserInfo{
 		ID:   user.ID,
 		Role: user.Role,
-	})
+	}, includeDeleted)
 	if err != nil {
+		if errors.Is(err, services.ErrAccessDenied) {
+			if includeDeleted {
+				respondError(c, http.StatusForbidden, "FORBIDDEN", "only the course teacher or an admin can list deleted quizzes", nil)
+				return
+			}
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not enrolled in this course", nil)
+			return
+		}
+		if errors.Is(err, services.ErrCourseNotFound) {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quizzes", nil)
 		return
 	}
 	respondOK(c, data)
 }
 
+// GetCourseQuizSummary returns aggregate quiz stats for a course
+// GET /courses/:courseId/quizzes/summary
+func (h *quizHandlers) GetCourseQuizSummary(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	summary, err := h.service.GetCourseQuizSummary(c.Request.Context(), uint(courseID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quiz summary", nil)
+		}
+		return
+	}
+	respondOK(c, summary)
+}
+
+// respondScheduleError writes the response for quiz window validation errors
+// and reports whether err was one of them.
+func respondScheduleError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrInvalidQuizWindow):
+		respondError(c, http.StatusBadRequest, "INVALID_QUIZ_WINDOW", "end_time must be after start_time", nil)
+	case errors.Is(err, services.ErrInvalidTimeLimit):
+		respondError(c, http.StatusBadRequest, "INVALID_TIME_LIMIT", "time_limit must be at least 0 and fit between start_time and end_time", nil)
+	default:
+		return false
+	}
+	return true
+}
+
 // CreateQuiz creates a new quiz
 // POST /quizzes
 func (h *quizHandlers) CreateQuiz(c *gin.Context) {
 	user, _ := middleware.GetUser(c)
 
 	var req struct {
-		CourseID           uint       `json:"course_id" binding:"required"`
-		Title              string     `json:"title" binding:"required"`
-		Description        string     `json:"description"`
-		TimeLimit          int        `json:"time_limit"`
-		StartTime          *time.Time `json:"start_time"`
-		EndTime            *time.Time `json:"end_time"`
-		MaxAttempts        int        `json:"max_attempts"`
-		ShowAnswerAfterEnd bool       `json:"show_answer_after_end"`
+		CourseID           uint                   `json:"course_id" binding:"required"`
+		ChapterID          *uint                  `json:"chapter_id"`
+		Title              string                 `json:"title" binding:"required"`
+		Description        string                 `json:"description"`
+		TimeLimit          int                    `json:"time_limit"`
+		StartTime          *services.ScheduleTime `json:"start_time"`
+		EndTime            *services.ScheduleTime `json:"end_time"`
+		MaxAttempts        int                    `json:"max_attempts"`
+		ShowAnswerAfterEnd bool                   `json:"show_answer_after_end"`
+		LeaderboardEnabled bool                   `json:"leaderboard_enabled"`
+		RequireAllAnswered bool                   `json:"require_all_answered"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", err.Error(), nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 
 	quiz, err := h.service.CreateQuiz(c.Request.Context(), services.CreateQuizRequest{
 		CourseID:           req.CourseID,
+		ChapterID:          req.ChapterID,
 		Title:              req.Title,
 		Description:        req.Description,
 		TimeLimit:          req.TimeLimit,
@@ -74,9 +136,22 @@ func (h *quizHandlers) CreateQuiz(c *gin.Context) {
 		EndTime:            req.EndTime,
 		MaxAttempts:        req.MaxAttempts,
 		ShowAnswerAfterEnd: req.ShowAnswerAfterEnd,
+		LeaderboardEnabled: req.LeaderboardEnabled,
+		RequireAllAnswered: req.RequireAllAnswered,
 		CreatedByID:        user.ID,
 	})
 	if err != nil {
+		if errors.Is(err, services.ErrCourseNotFound) {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidChapter) {
+			respondError(c, http.StatusBadRequest, "INVALID_CHAPTER", "chapter must belong to the same course", nil)
+			return
+		}
+		if respondScheduleError(c, err) {
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create quiz", nil)
 		return
 	}
@@ -107,6 +182,14 @@ func (h *quizHandlers) GetQuiz(c *gin.Context) {
 			respondError(c, http.StatusForbidden, "FORBIDDEN", "quiz not available", nil)
 			return
 		}
+		if errors.Is(err, services.ErrAccessDenied) {
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not enrolled in this course", nil)
+			return
+		}
+		if errors.Is(err, services.ErrCourseNotFound) {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quiz", nil)
 		return
 	}
@@ -114,7 +197,66 @@ func (h *quizHandlers) GetQuiz(c *gin.Context) {
 	respondOK(c, gin.H{
 		"quiz":      detail.Quiz,
 		"questions": detail.Questions,
+		"time_zone": detail.TimeZone,
+	})
+}
+
+// GetQuizInfo returns quiz rules and the caller's attempts without questions
+// GET /quizzes/:id/info
+func (h *quizHandlers) GetQuizInfo(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	info, err := h.service.GetQuizInfo(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
 	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrQuizNotAvailable):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "quiz not available", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not enrolled in this course", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quiz", nil)
+		}
+		return
+	}
+	respondOK(c, info)
+}
+
+// ListMyQuizzes pages through the published quizzes of the caller's courses
+// with their attempts; status filters by available, upcoming or ended
+// GET /me/quizzes?status=available&page=1&page_size=20
+func (h *quizHandlers) ListMyQuizzes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	user, _ := middleware.GetUser(c)
+	result, err := h.service.ListStudentQuizzes(c.Request.Context(), user.ID, c.Query("status"), page, pageSize)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuizAvailability) {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "status must be available, upcoming or ended", nil)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quizzes", nil)
+		return
+	}
+	respondOK(c, result)
 }
 
 // UpdateQuiz updates quiz metadata
@@ -127,20 +269,23 @@ func (h *quizHandlers) UpdateQuiz(c *gin.Context) {
 	}
 
 	var req struct {
-		Title              *string    `json:"title"`
-		Description        *string    `json:"description"`
-		TimeLimit          *int       `json:"time_limit"`
-		StartTime          *time.Time `json:"start_time"`
-		EndTime            *time.Time `json:"end_time"`
-		MaxAttempts        *int       `json:"max_attempts"`
-		ShowAnswerAfterEnd *bool      `json:"show_answer_after_end"`
+		ChapterID          json.RawMessage        `json:"chapter_id"` // null detaches
+		Title              *string                `json:"title"`
+		Description        *string                `json:"description"`
+		TimeLimit          *int                   `json:"time_limit"`
+		StartTime          *services.ScheduleTime `json:"start_time"`
+		EndTime            *services.ScheduleTime `json:"end_time"`
+		MaxAttempts        *int                   `json:"max_attempts"`
+		ShowAnswerAfterEnd *bool                  `json:"show_answer_after_end"`
+		LeaderboardEnabled *bool                  `json:"leaderboard_enabled"`
+		RequireAllAnswered *bool                  `json:"require_all_answered"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", err.Error(), nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 
-	updated, err := h.service.UpdateQuiz(c.Request.Context(), uint(quizID), services.UpdateQuizRequest{
+	update := services.UpdateQuizRequest{
 		Title:              req.Title,
 		Description:        req.Description,
 		TimeLimit:          req.TimeLimit,
@@ -148,12 +293,30 @@ func (h *quizHandlers) UpdateQuiz(c *gin.Context) {
 		EndTime:            req.EndTime,
 		MaxAttempts:        req.MaxAttempts,
 		ShowAnswerAfterEnd: req.ShowAnswerAfterEnd,
-	})
+		LeaderboardEnabled: req.LeaderboardEnabled,
+		RequireAllAnswered: req.RequireAllAnswered,
+	}
+	if len(req.ChapterID) > 0 {
+		chapterID, ok := parseChapterID(c, req.ChapterID)
+		if !ok {
+			return
+		}
+		update.MoveChapter, update.ChapterID = true, chapterID
+	}
+
+	updated, err := h.service.UpdateQuiz(c.Request.Context(), uint(quizID), update)
 	if err != nil {
 		if errors.Is(err, services.ErrQuizNotFound) {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
 			return
 		}
+		if errors.Is(err, services.ErrInvalidChapter) {
+			respondError(c, http.StatusBadRequest, "INVALID_CHAPTER", "chapter must belong to the same course", nil)
+			return
+		}
+		if respondScheduleError(c, err) {
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update quiz", nil)
 		return
 	}
@@ -170,18 +333,67 @@ func (h *quizHandlers) DeleteQuiz(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteQuiz(c.Request.Context(), uint(quizID)); err != nil {
-		if errors.Is(err, services.ErrQuizNotFound) {
+	user, _ := middleware.GetUser(c)
+	err = h.service.DeleteQuiz(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
-			return
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to delete quiz", nil)
 		}
-		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to delete quiz", nil)
 		return
 	}
 
 	respondOK(c, gin.H{"message": "quiz deleted"})
 }
 
+// RestoreQuiz brings back a deleted quiz
+// POST /quizzes/:id/restore
+func (h *quizHandlers) RestoreQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	quiz, err := h.service.RestoreQuiz(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "deleted quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to restore quiz", nil)
+		}
+		return
+	}
+
+	respondOK(c, quiz)
+}
+
+type publishQuizRequest struct {
+	// NormalizeTo reports scores out of this total; 0 keeps raw points and
+	// omitting it keeps the quiz's current setting.
+	NormalizeTo *int `json:"normalize_to" binding:"omitempty,min=0,max=1000"`
+	// Preview returns the per-question weights without publishing.
+	Preview bool `json:"preview"`
+}
+
 // PublishQuiz publishes a quiz (locks questions)
 // POST /quizzes/:id/publish
 func (h *quizHandlers) PublishQuiz(c *gin.Context) {
@@ -191,13 +403,162 @@ func (h *quizHandlers) PublishQuiz(c *gin.Context) {
 		return
 	}
 
-	quiz, err := h.service.PublishQuiz(c.Request.Context(), uint(quizID))
+	// The body is optional; publishing without one keeps raw points.
+	var req publishQuizRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	userInfo := services.UserInfo{ID: user.ID, Role: user.Role}
+	var data interface{}
+	if req.Preview {
+		data, err = h.service.PreviewNormalization(c.Request.Context(), uint(quizID), userInfo, req.NormalizeTo)
+	} else {
+		data, err = h.service.PublishQuiz(c.Request.Context(), uint(quizID), userInfo, req.NormalizeTo)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		case errors.Is(err, services.ErrInvalidNormalizeTo):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "normalize_to must be between 0 and 1000", nil)
+		case errors.Is(err, services.ErrNormalizeToLocked):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "cannot change normalize_to: students have already attempted", nil)
+		case respondQuizSizeError(c, err):
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to publish quiz", nil)
+		}
+		return
+	}
+	respondOK(c, data)
+}
+
+type bulkQuizRequest struct {
+	QuizIDs []uint `json:"quiz_ids" binding:"required,min=1,max=100"`
+}
+
+// PublishQuizzes publishes several quizzes of a course in one transaction
+// POST /courses/:courseId/quizzes/publish
+func (h *quizHandlers) PublishQuizzes(c *gin.Context) {
+	h.bulkSetPublished(c, true)
+}
+
+// UnpublishQuizzes unpublishes the quizzes of a course that have no attempts
+// POST /courses/:courseId/quizzes/unpublish
+func (h *quizHandlers) UnpublishQuizzes(c *gin.Context) {
+	h.bulkSetPublished(c, false)
+}
+
+// bulkSetPublished is shared by PublishQuizzes and UnpublishQuizzes.
+func (h *quizHandlers) bulkSetPublished(c *gin.Context, publish bool) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+
+	var req bulkQuizRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	userInfo := services.UserInfo{ID: user.ID, Role: user.Role}
+	var results []services.BulkQuizResult
+	if publish {
+		results, err = h.service.PublishQuizzes(c.Request.Context(), uint(courseID), userInfo, req.QuizIDs)
+	} else {
+		results, err = h.service.UnpublishQuizzes(c.Request.Context(), uint(courseID), userInfo, req.QuizIDs)
+	}
+	if err != nil {
+		var notInCourse *services.QuizNotInCourseError
+		switch {
+		case errors.As(err, &notInCourse):
+			respondError(c, http.StatusBadRequest, "QUIZ_NOT_IN_COURSE", "every quiz must belong to this course", gin.H{"quiz_ids": notInCourse.QuizIDs})
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		case respondQuizSizeError(c, err):
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update quizzes", nil)
+		}
+		return
+	}
+	respondOK(c, results)
+}
+
+// CloseQuiz ends a published quiz early
+// POST /quizzes/:id/close
+func (h *quizHandlers) CloseQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	quiz, err := h.service.CloseQuiz(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		case errors.Is(err, services.ErrQuizNotAvailable):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "only published quizzes can be closed", nil)
+		case errors.Is(err, services.ErrQuizEnded):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "quiz has already ended", nil)
+		case errors.Is(err, services.ErrQuizNotStarted):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "quiz has not started yet; unpublish it instead", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to close quiz", nil)
+		}
+		return
+	}
+	respondOK(c, quiz)
+}
+
+// MoveQuizToChapter attaches the quiz to another chapter of its course, or
+// detaches it with a null chapter_id
+// PUT /quizzes/:id/chapter
+func (h *quizHandlers) MoveQuizToChapter(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+	chapterID, ok := bindChapterMove(c)
+	if !ok {
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	quiz, err := h.service.MoveQuizToChapter(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, chapterID)
 	if err != nil {
 		if errors.Is(err, services.ErrQuizNotFound) {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
 			return
 		}
-		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to publish quiz", nil)
+		if respondChapterMoveError(c, err) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update quiz", nil)
 		return
 	}
 	respondOK(c, quiz)
@@ -228,8 +589,388 @@ func (h *quizHandlers) UnpublishQuiz(c *gin.Context) {
 	respondOK(c, quiz)
 }
 
+type grantAttemptRequest struct {
+	Reason string `json:"reason" binding:"required,max=512"`
+}
+
+// GrantAttempt gives one student an extra attempt on a quiz
+// POST /quizzes/:id/students/:studentId/grant-attempt
+func (h *quizHandlers) GrantAttempt(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+	studentID, err := strconv.ParseUint(c.Param("studentId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid student id", nil)
+		return
+	}
+
+	var req grantAttemptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	result, err := h.service.GrantExtraAttempt(c.Request.Context(), uint(quizID), uint(studentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		case errors.Is(err, services.ErrStudentNotEnrolled):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "student is not enrolled in this course", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to grant attempt", nil)
+		}
+		return
+	}
+	respondCreated(c, result)
+}
+
+type reopenAttemptRequest struct {
+	Reason string `json:"reason" binding:"required,max=512"`
+}
+
+// ReopenAttempt returns a submitted attempt to progress so the student can resume it
+// POST /quiz-attempts/:id/reopen
+func (h *quizHandlers) ReopenAttempt(c *gin.Context) {
+	attemptID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid attempt id", nil)
+		return
+	}
+
+	var req reopenAttemptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	attempt, err := h.service.ReopenAttempt(c.Request.Context(), uint(attemptID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAttemptNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "attempt not found", nil)
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		case errors.Is(err, services.ErrAttemptNotSubmitted):
+			respondError(c, http.StatusConflict, "ATTEMPT_NOT_SUBMITTED", "attempt is still in progress", nil)
+		case errors.Is(err, services.ErrAttemptInProgress):
+			respondError(c, http.StatusConflict, "ATTEMPT_IN_PROGRESS", "student already has an attempt in progress", nil)
+		case errors.Is(err, services.ErrQuizEnded):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "quiz has already ended", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to reopen attempt", nil)
+		}
+		return
+	}
+	respondOK(c, attempt)
+}
+
+type quizFeedbackRequest struct {
+	Difficulty int    `json:"difficulty" binding:"required,min=1,max=5"`
+	Confidence *int   `json:"confidence" binding:"omitempty,min=1,max=5"`
+	Comment    string `json:"comment"`
+}
+
+// SubmitQuizFeedback records the student's rating of their submitted attempt
+// POST /quiz-attempts/:id/feedback
+func (h *quizHandlers) SubmitQuizFeedback(c *gin.Context) {
+	attemptID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid attempt id", nil)
+		return
+	}
+
+	var req quizFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	feedback, err := h.service.SubmitFeedback(c.Request.Context(), uint(attemptID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, services.QuizFeedbackRequest{
+		Difficulty: req.Difficulty,
+		Confidence: req.Confidence,
+		Comment:    req.Comment,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidFeedback):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "difficulty and confidence must be 1-5 and the comment at most 1000 characters", nil)
+		case errors.Is(err, services.ErrAttemptNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "attempt not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you can only rate your own attempts", nil)
+		case errors.Is(err, services.ErrAttemptNotSubmitted):
+			respondError(c, http.StatusConflict, "ATTEMPT_NOT_SUBMITTED", "submit the attempt before leaving feedback", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to save feedback", nil)
+		}
+		return
+	}
+	respondOK(c, feedback)
+}
+
+// GetQuizFeedbackSummary aggregates the feedback students left on a quiz
+// GET /quizzes/:id/feedback-summary
+func (h *quizHandlers) GetQuizFeedbackSummary(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	summary, err := h.service.GetFeedbackSummary(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load feedback", nil)
+		}
+		return
+	}
+	respondOK(c, summary)
+}
+
+type gradePreviewRequest struct {
+	Answers map[string]interface{} `json:"answers" binding:"required"`
+}
+
+// GradePreview scores sample answers without creating an attempt
+// POST /quizzes/:id/grade-preview
+func (h *quizHandlers) GradePreview(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	var req gradePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	preview, err := h.service.PreviewGrade(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, req.Answers)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to preview grade", nil)
+		}
+		return
+	}
+	respondOK(c, preview)
+}
+
+type regradeRequest struct {
+	Corrections []struct {
+		QuestionID uint   `json:"question_id" binding:"required"`
+		Answer     string `json:"answer" binding:"required"`
+	} `json:"corrections" binding:"dive"`
+}
+
+// RegradeQuiz re-scores submitted attempts, optionally correcting answers first
+// POST /quizzes/:id/regrade
+func (h *quizHandlers) RegradeQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	var req regradeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, "BAD_REQUEST", err)
+			return
+		}
+	}
+	corrections := make([]services.AnswerCorrection, len(req.Corrections))
+	for i, corr := range req.Corrections {
+		corrections[i] = services.AnswerCorrection{QuestionID: corr.QuestionID, Answer: corr.Answer}
+	}
+
+	user, _ := middleware.GetUser(c)
+	result, err := h.service.RegradeQuiz(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, corrections)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		case errors.Is(err, services.ErrQuestionNotFound):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "question does not belong to this quiz", nil)
+		case errors.Is(err, services.ErrQuestionAnswerTooLong):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "answer too long (max 512 bytes)", nil)
+		case errors.Is(err, services.ErrInvalidQuestionAnswer):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "answer does not match options", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to regrade quiz", nil)
+		}
+		return
+	}
+	respondOK(c, result)
+}
+
 // --- Question CRUD ---
 
+// optionsErrorMessage describes an option validation error; ok is false for
+// any other error.
+func optionsErrorMessage(err error) (msg string, details gin.H, ok bool) {
+	var dup *services.DuplicateOptionError
+	var limitErr *services.LimitError
+	switch {
+	case errors.As(err, &dup):
+		return "duplicate option", gin.H{"duplicate": dup.Value}, true
+	case errors.Is(err, services.ErrBlankOption):
+		return "options must not be blank", nil, true
+	case errors.Is(err, services.ErrInvalidQuestionAnswer):
+		return "answer does not match options", nil, true
+	case errors.As(err, &limitErr) && errors.Is(err, services.ErrTooManyOptions):
+		return fmt.Sprintf("too many options (max %d)", limitErr.Limit), gin.H{"limit": limitErr.Limit}, true
+	case errors.As(err, &limitErr) && errors.Is(err, services.ErrOptionsTooLarge):
+		return fmt.Sprintf("options too large (max %d bytes)", limitErr.Limit), gin.H{"limit": limitErr.Limit}, true
+	}
+	return "", nil, false
+}
+
+// respondQuizSizeError writes the response for a quiz over its questions or
+// total points limit and reports whether err was one.
+func respondQuizSizeError(c *gin.Context, err error) bool {
+	msg, details, ok := quizSizeErrorMessage(err)
+	if ok {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", msg, details)
+	}
+	return ok
+}
+
+// quizSizeErrorMessage describes a quiz over its questions or total points
+// limit; ok is false for any other error.
+func quizSizeErrorMessage(err error) (msg string, details gin.H, ok bool) {
+	var limitErr *services.LimitError
+	if !errors.As(err, &limitErr) {
+		return "", nil, false
+	}
+	details = gin.H{"limit": limitErr.Limit}
+	if limitErr.QuizID != 0 {
+		details["quiz_id"] = limitErr.QuizID
+	}
+	switch {
+	case errors.Is(err, services.ErrTooManyQuestions):
+		return fmt.Sprintf("too many questions (max %d)", limitErr.Limit), details, true
+	case errors.Is(err, services.ErrTooManyPoints):
+		return fmt.Sprintf("total points too high (max %d)", limitErr.Limit), details, true
+	}
+	return "", nil, false
+}
+
+// questionErrorMessage describes a question AddQuestion or UpdateQuestion
+// rejected as invalid; ok is false for any other error, including those about
+// the quiz itself.
+func questionErrorMessage(err error) (msg string, details gin.H, ok bool) {
+	if msg, details, ok := quizSizeErrorMessage(err); ok {
+		return msg, details, true
+	}
+	if msg, details, ok := optionsErrorMessage(err); ok {
+		return msg, details, true
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidQuestionType):
+		return "invalid question type", nil, true
+	case errors.Is(err, services.ErrInvalidImageURL):
+		return "invalid image url (http/https only)", nil, true
+	case errors.Is(err, services.ErrInvalidContentFormat):
+		return "invalid content format (plain, markdown, latex)", nil, true
+	case errors.Is(err, services.ErrContentTooLarge):
+		return "question content too large", nil, true
+	case errors.Is(err, services.ErrQuestionAnswerTooLong):
+		return "answer too long (max 512 bytes)", nil, true
+	case errors.Is(err, services.ErrUnbalancedLatex):
+		return "unbalanced latex math delimiters", nil, true
+	case errors.Is(err, services.ErrTooManyTags):
+		return "too many tags (max 10)", nil, true
+	case errors.Is(err, services.ErrInvalidTag):
+		return "tags must be 1-32 characters", nil, true
+	}
+	return "", nil, false
+}
+
+// ListQuestions returns a quiz's questions with answers, optionally only
+// those with a tag
+// GET /quizzes/:id/questions?tag=
+func (h *quizHandlers) ListQuestions(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	questions, err := h.service.ListQuestions(c.Request.Context(), uint(quizID), c.Query("tag"), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list questions", nil)
+		}
+		return
+	}
+	respondOK(c, questions)
+}
+
 // AddQuestion adds a question to a quiz
 // POST /quizzes/:id/questions
 func (h *quizHandlers) AddQuestion(c *gin.Context) {
@@ -240,26 +981,32 @@ func (h *quizHandlers) AddQuestion(c *gin.Context) {
 	}
 
 	var req struct {
-		Type      string   `json:"type" binding:"required"`
-		Content   string   `json:"content" binding:"required"`
-		Options   []string `json:"options"`
-		Answer    string   `json:"answer" binding:"required"`
-		MatchRule string   `json:"match_rule"`
-		Points    int      `json:"points"`
-		OrderNum  int      `json:"order_num"`
+		Type          string   `json:"type" binding:"required"`
+		Content       string   `json:"content" binding:"required"`
+		Options       []string `json:"options"`
+		Answer        string   `json:"answer" binding:"required"`
+		MatchRule     string   `json:"match_rule"`
+		Points        int      `json:"points"`
+		OrderNum      int      `json:"order_num"`
+		ImageURL      string   `json:"image_url"`
+		ContentFormat string   `json:"content_format"`
+		Tags          []string `json:"tags"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", err.Error(), nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 	question, err := h.service.AddQuestion(c.Request.Context(), uint(quizID), services.AddQuestionRequest{
-		Type:      req.Type,
-		Content:   req.Content,
-		Options:   req.Options,
-		Answer:    req.Answer,
-		MatchRule: req.MatchRule,
-		Points:    req.Points,
-		OrderNum:  req.OrderNum,
+		Type:          req.Type,
+		Content:       req.Content,
+		Options:       req.Options,
+		Answer:        req.Answer,
+		MatchRule:     req.MatchRule,
+		Points:        req.Points,
+		OrderNum:      req.OrderNum,
+		ImageURL:      req.ImageURL,
+		ContentFormat: req.ContentFormat,
+		Tags:          req.Tags,
 	})
 	if err != nil {
 		if errors.Is(err, services.ErrQuizNotFound) {
@@ -270,16 +1017,8 @@ func (h *quizHandlers) AddQuestion(c *gin.Context) {
 			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "cannot add questions to published quiz", nil)
 			return
 		}
-		if errors.Is(err, services.ErrInvalidQuestionType) {
-			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid question type", nil)
-			return
-		}
-		if errors.Is(err, services.ErrTooManyOptions) {
-			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "too many options (max 10)", nil)
-			return
-		}
-		if errors.Is(err, services.ErrOptionsTooLarge) {
-			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "options too large", nil)
+		if msg, details, ok := questionErrorMessage(err); ok {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", msg, details)
 			return
 		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create question", nil)
@@ -289,6 +1028,53 @@ func (h *quizHandlers) AddQuestion(c *gin.Context) {
 	respondCreated(c, question)
 }
 
+type copyQuestionsRequest struct {
+	SourceQuizID uint   `json:"source_quiz_id" binding:"required"`
+	QuestionIDs  []uint `json:"question_ids" binding:"required,min=1,max=100"`
+}
+
+// CopyQuestions copies selected questions of another quiz into an
+// unpublished quiz and returns the new questions
+// POST /quizzes/:id/questions/copy-from
+func (h *quizHandlers) CopyQuestions(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	var req copyQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	questions, err := h.service.CopyQuestions(c.Request.Context(), uint(quizID), req.SourceQuizID, req.QuestionIDs, services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you must manage both courses", nil)
+		case errors.Is(err, services.ErrQuizPublished):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "cannot add questions to published quiz", nil)
+		case errors.Is(err, services.ErrQuestionNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "question not found in source quiz", nil)
+		case respondQuizSizeError(c, err):
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to copy questions", nil)
+		}
+		return
+	}
+	respondCreated(c, questions)
+}
+
 // UpdateQuestion updates a question
 // PUT /questions/:id
 func (h *quizHandlers) UpdateQuestion(c *gin.Context) {
@@ -299,24 +1085,30 @@ func (h *quizHandlers) UpdateQuestion(c *gin.Context) {
 	}
 
 	var req struct {
-		Content   *string  `json:"content"`
-		Options   []string `json:"options"`
-		Answer    *string  `json:"answer"`
-		MatchRule *string  `json:"match_rule"`
-		Points    *int     `json:"points"`
-		OrderNum  *int     `json:"order_num"`
+		Content       *string  `json:"content"`
+		Options       []string `json:"options"`
+		Answer        *string  `json:"answer"`
+		MatchRule     *string  `json:"match_rule"`
+		Points        *int     `json:"points"`
+		OrderNum      *int     `json:"order_num"`
+		ImageURL      *string  `json:"image_url"`
+		ContentFormat *string  `json:"content_format"`
+		Tags          []string `json:"tags"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", err.Error(), nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 	updated, err := h.service.UpdateQuestion(c.Request.Context(), uint(questionID), services.UpdateQuestionRequest{
-		Content:   req.Content,
-		Options:   req.Options,
-		Answer:    req.Answer,
-		MatchRule: req.MatchRule,
-		Points:    req.Points,
-		OrderNum:  req.OrderNum,
+		Content:       req.Content,
+		Options:       req.Options,
+		Answer:        req.Answer,
+		MatchRule:     req.MatchRule,
+		Points:        req.Points,
+		OrderNum:      req.OrderNum,
+		ImageURL:      req.ImageURL,
+		ContentFormat: req.ContentFormat,
+		Tags:          req.Tags,
 	})
 	if err != nil {
 		if errors.Is(err, services.ErrQuestionNotFound) {
@@ -327,6 +1119,10 @@ func (h *quizHandlers) UpdateQuestion(c *gin.Context) {
 			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "cannot edit questions in published quiz", nil)
 			return
 		}
+		if msg, details, ok := questionErrorMessage(err); ok {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", msg, details)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to update question", nil)
 		return
 	}
@@ -386,6 +1182,10 @@ func (h *quizHandlers) StartQuiz(c *gin.Context) {
 			respondError(c, http.StatusForbidden, "FORBIDDEN", "quiz has ended", nil)
 		case errors.Is(err, services.ErrMaxAttemptsReached):
 			respondError(c, http.StatusForbidden, "FORBIDDEN", "maximum attempts reached", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not enrolled in this course", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
 		default:
 			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to start quiz", nil)
 		}
@@ -399,7 +1199,39 @@ func (h *quizHandlers) StartQuiz(c *gin.Context) {
 	})
 }
 
-// SubmitQuiz submits quiz answers
+// GetAttemptRemaining returns authoritative remaining seconds for the active attempt
+// GET /quizzes/:id/attempt/remaining
+func (h *quizHandlers) GetAttemptRemaining(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	remaining, err := h.service.GetAttemptRemaining(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrNoActiveAttempt):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "no active attempt found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not enrolled in this course", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load attempt", nil)
+		}
+		return
+	}
+	respondOK(c, remaining)
+}
+
+// SubmitQuiz submits quiz answers; accepted up to services.SubmissionGracePeriod past the deadline
 // POST /quizzes/:id/submit
 func (h *quizHandlers) SubmitQuiz(c *gin.Context) {
 	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
@@ -413,21 +1245,44 @@ func (h *quizHandlers) SubmitQuiz(c *gin.Context) {
 		Answers map[string]interface{} `json:"answers" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		respondError(c, http.StatusBadRequest, "BAD_REQUEST", err.Error(), nil)
+		respondBindError(c, "BAD_REQUEST", err)
 		return
 	}
 	result, err := h.service.SubmitQuiz(c.Request.Context(), uint(quizID), services.UserInfo{
 		ID:   user.ID,
 		Role: user.Role,
-	}, services.SubmitQuizRequest{Answers: req.Answers})
+	}, services.SubmitQuizRequest{
+		Answers:   req.Answers,
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
 	if err != nil {
+		var limitErr *services.LimitError
+		var dupErr *services.AlreadySubmittedError
+		var unansweredErr *services.UnansweredError
 		switch {
+		case errors.As(err, &dupErr):
+			respondError(c, http.StatusConflict, "ALREADY_SUBMITTED", "attempt already submitted", gin.H{
+				"score":     dupErr.Result.Score,
+				"max_score": dupErr.Result.MaxScore,
+				"attempt":   dupErr.Result.Attempt,
+			})
+		case errors.As(err, &unansweredErr):
+			respondError(c, http.StatusBadRequest, "UNANSWERED_QUESTIONS", "all questions must be answered", gin.H{
+				"question_numbers": unansweredErr.QuestionNumbers,
+			})
 		case errors.Is(err, services.ErrNoActiveAttempt):
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "no active attempt found", nil)
 		case errors.Is(err, services.ErrSubmissionDeadline):
 			respondError(c, http.StatusForbidden, "FORBIDDEN", "submission deadline passed", nil)
-		case errors.Is(err, services.ErrAnswersTooLarge):
-			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "answers too large", nil)
+		case errors.As(err, &limitErr) && errors.Is(err, services.ErrAnswersTooLarge):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", fmt.Sprintf("answers too large (max %d bytes)", limitErr.Limit), gin.H{"limit": limitErr.Limit})
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not enrolled in this course", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
 		default:
 			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to submit quiz", nil)
 		}
@@ -456,25 +1311,105 @@ func (h *quizHandlers) GetQuizResult(c *gin.Context) {
 		Role: user.Role,
 	})
 	if err != nil {
-		if errors.Is(err, services.ErrQuizNotFound) {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
-			return
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quiz result", nil)
 		}
-		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quiz result", nil)
 		return
 	}
 
+	data := gin.H{
+		"quiz":     result.Quiz,
+		"attempts": result.Attempts,
+	}
 	if result.Questions != nil {
-		respondOK(c, gin.H{
-			"quiz":      result.Quiz,
-			"attempts":  result.Attempts,
-			"questions": result.Questions,
-		})
+		data["questions"] = result.Questions
+	}
+	if result.ReviewAttemptID != nil {
+		data["review_attempt_id"] = *result.ReviewAttemptID
+	}
+	if result.Snapshots != nil {
+		data["snapshots"] = result.Snapshots
+	}
+	if result.Origins != nil {
+		data["origins"] = result.Origins
+	}
+	respondOK(c, data)
+}
+
+// ListStudentAttempts returns a student's submitted attempts across a course's quizzes
+// GET /courses/:courseId/students/:studentId/quiz-attempts
+func (h *quizHandlers) ListStudentAttempts(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course id", nil)
+		return
+	}
+	studentID, err := strconv.ParseUint(c.Param("studentId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid student id", nil)
 		return
 	}
 
+	user, _ := middleware.GetUser(c)
+	attempts, err := h.service.ListStudentAttempts(c.Request.Context(), uint(courseID), uint(studentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "access denied", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load attempts", nil)
+		}
+		return
+	}
 	respondOK(c, gin.H{
-		"quiz":     result.Quiz,
-		"attempts": result.Attempts,
+		"course_id":  courseID,
+		"student_id": studentID,
+		"attempts":   attempts,
 	})
 }
+
+// GetLeaderboard returns the top students by best score
+// GET /quizzes/:id/leaderboard?limit=10
+func (h *quizHandlers) GetLeaderboard(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	user, _ := middleware.GetUser(c)
+	board, err := h.service.GetLeaderboard(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrQuizNotAvailable):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "quiz not available", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not enrolled in this course", nil)
+		case errors.Is(err, services.ErrLeaderboardDisabled):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "leaderboard is not enabled for this quiz", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load leaderboard", nil)
+		}
+		return
+	}
+
+	respondOK(c, board)
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/csvutil"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+// GetQuizResults returns every enrolled student's results for a quiz
+// GET /quizzes/:id/results
+func (h *quizHandlers) GetQuizResults(c *gin.Context) {
+	results, ok := h.loadQuizResults(c)
+	if !ok {
+		return
+	}
+	respondOK(c, results)
+}
+
+// ExportQuizResultsCSV downloads the quiz results as a CSV file
+// GET /quizzes/:id/results.csv
+func (h *quizHandlers) ExportQuizResultsCSV(c *gin.Context) {
+	results, ok := h.loadQuizResults(c)
+	if !ok {
+		return
+	}
+
+	filename := quizResultsFilename(results.Quiz.Title)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="quiz-%d-results.csv"; filename*=UTF-8''%s`,
+		results.Quiz.ID, url.PathEscape(filename)))
+	c.Status(http.StatusOK)
+
+	w := csvutil.NewWriter(c.Writer)
+	_ = w.Write([]string{"student_id", "username", "name", "attempts", "best_score", "latest_score", "max_score", "time_spent_seconds", "last_submitted_at"})
+	maxScore := strconv.Itoa(results.MaxScore)
+	for _, row := range results.Students {
+		record := []string{
+			strconv.FormatUint(uint64(row.StudentID), 10),
+			csvutil.Safe(row.Username),
+			csvutil.Safe(row.Name),
+			strconv.Itoa(row.Attempts),
+			formatOptionalInt(row.BestScore),
+			formatOptionalInt(row.LatestScore),
+			maxScore,
+			"",
+			"",
+		}
+		if row.Attempts > 0 {
+			record[7] = strconv.Itoa(row.TimeSpentSeconds)
+		}
+		if row.LastSubmittedAt != nil {
+			record[8] = row.LastSubmittedAt.Format(time.RFC3339)
+		}
+		_ = w.Write(record)
+	}
+	_ = w.Flush()
+}
+
+// loadQuizResults parses the quiz id, loads the results and writes the error
+// response on failure.
+func (h *quizHandlers) loadQuizResults(c *gin.Context) (*services.QuizResults, bool) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return nil, false
+	}
+
+	user, _ := middleware.GetUser(c)
+	results, err := h.service.GetQuizResults(c.Request.Context(), uint(quizID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load quiz results", nil)
+		}
+		return nil, false
+	}
+	return results, true
+}
+
+// quizResultsFilename builds "<title>-results.csv", keeping letters and digits
+// (including CJK) and collapsing everything else to underscores.
+func quizResultsFilename(title string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			b.WriteRune(r)
+		} else if !strings.HasSuffix(b.String(), "_") {
+			b.WriteByte('_')
+		}
+	}
+	name := strings.Trim(b.String(), "_")
+	if name == "" {
+		name = "quiz"
+	}
+	return name + "-results.csv"
+}
+
+func formatOptionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
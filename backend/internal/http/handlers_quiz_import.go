@@ -0,0 +1,182 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/csvutil"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+// questionImportColumns are the columns of a question import, under their
+// English keys or the labels the quiz editor shows.
+var questionImportColumns = []csvutil.Column{
+	{Key: "type", Headers: []string{"题型"}, Required: true},
+	{Key: "content", Headers: []string{"题目", "题干"}, Required: true},
+	{Key: "options", Headers: []string{"选项"}},
+	{Key: "answer", Headers: []string{"答案"}, Required: true},
+	{Key: "points", Headers: []string{"分值"}},
+	{Key: "match_rule", Headers: []string{"匹配规则"}},
+	{Key: "order_num", Headers: []string{"顺序"}},
+	{Key: "content_format", Headers: []string{"格式"}},
+	{Key: "tags", Headers: []string{"标签"}},
+}
+
+// questionTypeLabels maps the quiz editor's labels to question types, so an
+// import may name a type either way.
+var questionTypeLabels = map[string]string{
+	"单选": "single_choice",
+	"多选": "multiple_choice",
+	"判断": "true_false",
+	"填空": "fill_blank",
+	"排序": "ordering",
+	"连线": "matching",
+}
+
+// importListSeparator separates options and tags within one cell.
+const importListSeparator = "|"
+
+// questionImportError is one row the import skipped.
+type questionImportError struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+	Details gin.H  `json:"details,omitempty"`
+}
+
+// questionImportResult lists the questions imported and the rows skipped.
+type questionImportResult struct {
+	Imported []services.QuestionResponse `json:"imported"`
+	Errors   []questionImportError       `json:"errors"`
+}
+
+// ImportQuestions adds questions to an unpublished quiz from an uploaded CSV
+// file, one question per row. Options and tags are separated by "|". Rows
+// that fail validation are skipped and reported with their line numbers; the
+// rest are imported in file order, provided together they fit the quiz's size
+// limits.
+// POST /quizzes/:id/questions/import
+func (h *quizHandlers) ImportQuestions(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid quiz id", nil)
+		return
+	}
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "file is required", nil)
+		return
+	}
+	defer file.Close()
+
+	rows, rowErrs, err := csvutil.Read(file, questionImportColumns)
+	if err != nil {
+		if errors.Is(err, csvutil.ErrNoHeader) || errors.Is(err, csvutil.ErrMissingColumn) {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", err.Error(), nil)
+			return
+		}
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid csv file", nil)
+		return
+	}
+	result := questionImportResult{Imported: []services.QuestionResponse{}, Errors: []questionImportError{}}
+	for _, e := range rowErrs {
+		result.Errors = append(result.Errors, questionImportError{Line: e.Line, Column: e.Column, Message: e.Err.Error()})
+	}
+
+	reqs := make([]services.AddQuestionRequest, 0, len(rows))
+	lines := make([]int, 0, len(rows))
+	for _, row := range rows {
+		req, rowErr := parseQuestionRow(row)
+		if rowErr != nil {
+			result.Errors = append(result.Errors, questionImportError{Line: rowErr.Line, Column: rowErr.Column, Message: rowErr.Err.Error()})
+			continue
+		}
+		reqs = append(reqs, req)
+		lines = append(lines, row.Line)
+	}
+
+	user, _ := middleware.GetUser(c)
+	imported, questionErrs, err := h.service.ImportQuestions(c.Request.Context(), uint(quizID), reqs, services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuizNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "quiz not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "access denied", nil)
+		case errors.Is(err, services.ErrQuizPublished):
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "cannot add questions to published quiz", nil)
+		case respondQuizSizeError(c, err):
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to import questions", nil)
+		}
+		return
+	}
+	result.Imported = append(result.Imported, imported...)
+	for i, err := range questionErrs {
+		if err == nil {
+			continue
+		}
+		msg, details, ok := questionErrorMessage(err)
+		if !ok {
+			msg = err.Error()
+		}
+		result.Errors = append(result.Errors, questionImportError{Line: lines[i], Message: msg, Details: details})
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+	respondOK(c, result)
+}
+
+// parseQuestionRow reads one import row into a question, failing on values
+// that are not even well-formed; the quiz service validates the rest.
+func parseQuestionRow(row csvutil.Row) (services.AddQuestionRequest, *csvutil.RowError) {
+	req := services.AddQuestionRequest{
+		Type:          row.Get("type"),
+		Content:       row.Get("content"),
+		Options:       splitImportList(row.Get("options")),
+		Answer:        row.Get("answer"),
+		MatchRule:     row.Get("match_rule"),
+		ContentFormat: row.Get("content_format"),
+		Tags:          splitImportList(row.Get("tags")),
+	}
+	if t, ok := questionTypeLabels[strings.TrimSuffix(req.Type, "题")]; ok {
+		req.Type = t
+	}
+	for _, f := range []struct {
+		key string
+		dst *int
+	}{{"points", &req.Points}, {"order_num", &req.OrderNum}} {
+		value := row.Get(f.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return req, row.Errorf(f.key, "must be a non-negative integer")
+		}
+		*f.dst = n
+	}
+	return req, nil
+}
+
+// splitImportList splits a cell on importListSeparator, trimming each item.
+// Blank items are kept so the service can reject them.
+func splitImportList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	items := strings.Split(value, importListSeparator)
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
@@ -2,17 +2,28 @@ package http
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/grading"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -24,9 +35,13 @@ func setupQuizTestDB(t *testing.T) *gorm.DB {
 		&models.User{},
 		&models.Course{},
 		&models.CourseEnrollment{},
+		&models.Chapter{},
 		&models.Quiz{},
 		&models.Question{},
 		&models.QuizAttempt{},
+		&models.AuditLog{},
+		&models.QuizAttemptGrant{},
+		&models.QuizFeedback{},
 	)
 	assert.NoError(t, err)
 
@@ -35,20 +50,47 @@ func setupQuizTestDB(t *testing.T) *gorm.DB {
 
 func setupQuizRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
 	hQuiz := newQuizHandlers(db)
-	hAuth := newAuthHandlers(db, jwtSecret)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
 
 	r := gin.New()
 	r.POST("/auth/login", hAuth.Login)
 
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthRequired(jwtSecret))
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
 	{
 		api.GET("/courses/:courseId/quizzes", hQuiz.ListQuizzes)
+		api.GET("/courses/:courseId/quizzes/summary", hQuiz.GetCourseQuizSummary)
+		api.POST("/courses/:courseId/quizzes/publish", hQuiz.PublishQuizzes)
+		api.POST("/quizzes/:id/publish", hQuiz.PublishQuiz)
+		api.POST("/courses/:courseId/quizzes/unpublish", hQuiz.UnpublishQuizzes)
+		api.GET("/courses/:courseId/students/:studentId/quiz-attempts", hQuiz.ListStudentAttempts)
 		api.POST("/quizzes", hQuiz.CreateQuiz)
 		api.GET("/quizzes/:id", hQuiz.GetQuiz)
+		api.GET("/quizzes/:id/info", hQuiz.GetQuizInfo)
+		api.GET("/me/quizzes", hQuiz.ListMyQuizzes)
+		api.PUT("/quizzes/:id", hQuiz.UpdateQuiz)
+		api.DELETE("/quizzes/:id", hQuiz.DeleteQuiz)
+		api.POST("/quizzes/:id/restore", hQuiz.RestoreQuiz)
+		api.POST("/quizzes/:id/close", hQuiz.CloseQuiz)
+		api.PUT("/quizzes/:id/chapter", hQuiz.MoveQuizToChapter)
+		api.GET("/quizzes/:id/questions", hQuiz.ListQuestions)
+		api.POST("/quizzes/:id/questions", hQuiz.AddQuestion)
+		api.POST("/quizzes/:id/questions/import", hQuiz.ImportQuestions)
+		api.POST("/quizzes/:id/questions/copy-from", hQuiz.CopyQuestions)
+		api.PUT("/questions/:id", hQuiz.UpdateQuestion)
 		api.POST("/quizzes/:id/start", hQuiz.StartQuiz)
 		api.POST("/quizzes/:id/submit", hQuiz.SubmitQuiz)
+		api.GET("/quizzes/:id/attempt/remaining", hQuiz.GetAttemptRemaining)
+		api.POST("/quizzes/:id/students/:studentId/grant-attempt", hQuiz.GrantAttempt)
+		api.POST("/quiz-attempts/:id/reopen", hQuiz.ReopenAttempt)
+		api.POST("/quiz-attempts/:id/feedback", hQuiz.SubmitQuizFeedback)
+		api.GET("/quizzes/:id/feedback-summary", hQuiz.GetQuizFeedbackSummary)
+		api.POST("/quizzes/:id/grade-preview", hQuiz.GradePreview)
+		api.POST("/quizzes/:id/regrade", hQuiz.RegradeQuiz)
+		api.GET("/quizzes/:id/results", hQuiz.GetQuizResults)
+		api.GET("/quizzes/:id/results.csv", hQuiz.ExportQuizResultsCSV)
 		api.GET("/quizzes/:id/result", hQuiz.GetQuizResult)
+		api.GET("/quizzes/:id/leaderboard", hQuiz.GetLeaderboard)
 	}
 
 	return r
@@ -228,4 +270,2458 @@ func TestGetQuizResult_Success(t *testing.T) {
 	var resp envelope[map[string]interface{}]
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
 	assert.True(t, resp.Success)
+
+	// A student outside the course cannot read the quiz.
+	createCourseTestUser(t, db, "student2", "pass123", "student")
+	outsiderToken := loginAndGetToken(t, r, "student2", "pass123")
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/result", nil)
+	req.Header.Set("Authorization", "Bearer "+outsiderToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "Quiz")
+}
+
+func TestGetQuizResult_PerQuestionReview(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	ended := time.Now().Add(-time.Hour)
+	quiz := models.Quiz{
+		CourseID:           course.ID,
+		CreatedByID:        teacher.ID,
+		Title:              "Quiz",
+		IsPublished:        true,
+		MaxAttempts:        1,
+		TotalPoints:        15,
+		EndTime:            &ended,
+		ShowAnswerAfterEnd: true,
+	}
+	db.Create(&quiz)
+
+	q1 := models.Question{QuizID: quiz.ID, Content: "2+2?", Type: "single_choice", Options: `["3","4"]`, Answer: "4", Points: 10}
+	q2 := models.Question{QuizID: quiz.ID, Content: "Unit of E?", Type: "fill_blank", Answer: "V/m", Points: 5, OrderNum: 1}
+	db.Create(&q1)
+	db.Create(&q2)
+
+	answers, _ := json.Marshal(map[string]interface{}{
+		strconv.FormatUint(uint64(q1.ID), 10): "4",
+		strconv.FormatUint(uint64(q2.ID), 10): "N/C",
+	})
+	score := 10
+	submitted := ended.Add(-time.Minute)
+	db.Create(&models.QuizAttempt{
+		QuizID:      quiz.ID,
+		StudentID:   student.ID,
+		SubmittedAt: &submitted,
+		Answers:     string(answers),
+		Score:       &score,
+		MaxScore:    15,
+	})
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/result", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp envelope[struct {
+		Questions []struct {
+			ID           uint `json:"ID"`
+			Correct      bool `json:"correct"`
+			EarnedPoints int  `json:"earned_points"`
+		} `json:"questions"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data.Questions, 2)
+	assert.True(t, resp.Data.Questions[0].Correct)
+	assert.Equal(t, 10, resp.Data.Questions[0].EarnedPoints)
+	assert.False(t, resp.Data.Questions[1].Correct)
+	assert.Equal(t, 0, resp.Data.Questions[1].EarnedPoints)
+}
+
+func TestGetQuizResult_AnswerSnapshotWithoutSolutions(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	quiz := models.Quiz{
+		CourseID:    course.ID,
+		CreatedByID: teacher.ID,
+		Title:       "Quiz",
+		IsPublished: true,
+		MaxAttempts: 2,
+		TotalPoints: 10,
+	}
+	db.Create(&quiz)
+
+	question := models.Question{QuizID: quiz.ID, Content: "Unit of E?", Type: "fill_blank", Answer: "V/m", Points: 10}
+	db.Create(&question)
+	snapshot, _ := json.Marshal([]models.Question{question})
+	key := strconv.FormatUint(uint64(question.ID), 10)
+	answers, _ := json.Marshal(map[string]interface{}{key: "N/C"})
+
+	score := 0
+	submitted := time.Now().Add(-time.Minute)
+	db.Create(&models.QuizAttempt{
+		QuizID:         quiz.ID,
+		StudentID:      student.ID,
+		AttemptNumber:  1,
+		SubmittedAt:    &submitted,
+		Answers:        string(answers),
+		AnswerSnapshot: string(snapshot),
+		Score:          &score,
+		MaxScore:       10,
+	})
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 2, MaxScore: 10})
+
+	// The teacher rewords the question after the attempt was submitted.
+	db.Model(&question).Update("content", "What is the SI unit of electric field?")
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/result", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "V/m")
+
+	var resp envelope[struct {
+		Questions []interface{} `json:"questions"`
+		Snapshots []struct {
+			AttemptNumber int `json:"attempt_number"`
+			Questions     []struct {
+				Content       string      `json:"content"`
+				StudentAnswer interface{} `json:"student_answer"`
+			} `json:"questions"`
+		} `json:"snapshots"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Nil(t, resp.Data.Questions)
+	if assert.Len(t, resp.Data.Snapshots, 1) {
+		assert.Equal(t, 1, resp.Data.Snapshots[0].AttemptNumber)
+		if assert.Len(t, resp.Data.Snapshots[0].Questions, 1) {
+			assert.Equal(t, "Unit of E?", resp.Data.Snapshots[0].Questions[0].Content)
+			assert.Equal(t, "N/C", resp.Data.Snapshots[0].Questions[0].StudentAnswer)
+		}
+	}
+}
+
+func TestSubmitQuiz_RecordsOriginForStaff(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	quiz := models.Quiz{
+		CourseID:    course.ID,
+		CreatedByID: teacher.ID,
+		Title:       "Quiz",
+		IsPublished: true,
+		MaxAttempts: 1,
+		TotalPoints: 10,
+	}
+	db.Create(&quiz)
+	question := models.Question{QuizID: quiz.ID, Content: "What is 2+2?", Type: "single_choice", Options: `["3","4","5"]`, Answer: "4", Points: 10}
+	db.Create(&question)
+
+	r := setupQuizRouter(db, "test-secret")
+	studentToken := loginAndGetToken(t, r, "student1", "pass123")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	startReq := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/start", nil)
+	startReq.Header.Set("Authorization", "Bearer "+studentToken)
+	startW := httptest.NewRecorder()
+	r.ServeHTTP(startW, startReq)
+	assert.Equal(t, http.StatusOK, startW.Code)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"answers": map[string]interface{}{strconv.FormatUint(uint64(question.ID), 10): "4"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/submit", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+studentToken)
+	req.Header.Set("User-Agent", "QuizBrowser/1.0")
+	req.RemoteAddr = "203.0.113.7:51234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "203.0.113.7")
+
+	var attempt models.QuizAttempt
+	assert.NoError(t, db.First(&attempt).Error)
+	assert.Equal(t, "203.0.113.7", attempt.SubmitIP)
+	assert.Equal(t, "QuizBrowser/1.0", attempt.SubmitUserAgent)
+
+	// Students never see where their submission came from.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/result", nil)
+	req.Header.Set("Authorization", "Bearer "+studentToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "203.0.113.7")
+	assert.NotContains(t, w.Body.String(), "origins")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/result", nil)
+	req.Header.Set("Authorization", "Bearer "+teacherToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp envelope[struct {
+		Origins []services.AttemptOrigin `json:"origins"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Origins, 1) {
+		assert.Equal(t, attempt.ID, resp.Data.Origins[0].AttemptID)
+		assert.Equal(t, student.ID, resp.Data.Origins[0].StudentID)
+		assert.Equal(t, "203.0.113.7", resp.Data.Origins[0].IP)
+		assert.Equal(t, "QuizBrowser/1.0", resp.Data.Origins[0].UserAgent)
+	}
+
+	// A teacher of another course sees nothing of this quiz's attempts.
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/result", nil)
+	req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, "teacher2", "pass123"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "origins")
+	assert.NotContains(t, w.Body.String(), "203.0.113.7")
+}
+
+func TestQuizWindow_UsesCourseTimeZone(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID, TimeZone: "Asia/Shanghai"}
+	db.Create(&course)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	// Midnight without an offset is local midnight; an explicit offset wins.
+	body := `{"course_id":1,"title":"Quiz","start_time":"2026-06-01T08:00:00Z","end_time":"2026-06-30T23:59"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created envelope[map[string]interface{}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "2026-06-30T23:59:00+08:00", created.Data["end_time"])
+	assert.Equal(t, "2026-06-01T16:00:00+08:00", created.Data["start_time"])
+
+	var stored models.Quiz
+	assert.NoError(t, db.First(&stored).Error)
+	assert.True(t, stored.EndTime.Equal(time.Date(2026, 6, 30, 15, 59, 0, 0, time.UTC)))
+	assert.True(t, stored.StartTime.Equal(time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var detail envelope[struct {
+		Quiz     map[string]interface{} `json:"quiz"`
+		TimeZone string                 `json:"time_zone"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, "Asia/Shanghai", detail.Data.TimeZone)
+	assert.Equal(t, "2026-06-30T23:59:00+08:00", detail.Data.Quiz["end_time"])
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/quizzes", strings.NewReader(`{"course_id":1,"title":"Quiz","end_time":"30/06/2026"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestQuizFeedback_OwnerOnlyAndSummarized(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID})
+
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 2, TotalPoints: 10}
+	db.Create(&quiz)
+	submitted := time.Now().Add(-time.Minute)
+	aliceDone := models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 1, SubmittedAt: &submitted, MaxScore: 10}
+	db.Create(&aliceDone)
+	aliceOpen := models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 2, MaxScore: 10}
+	db.Create(&aliceOpen)
+	bobDone := models.QuizAttempt{QuizID: quiz.ID, StudentID: bob.ID, AttemptNumber: 1, SubmittedAt: &submitted, MaxScore: 10}
+	db.Create(&bobDone)
+
+	r := setupQuizRouter(db, "test-secret")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	bobToken := loginAndGetToken(t, r, "bob", "pass123")
+	post := func(token string, attemptID uint, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/quiz-attempts/"+strconv.FormatUint(uint64(attemptID), 10)+"/feedback", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, post(aliceToken, aliceDone.ID, `{"difficulty":4,"confidence":2,"comment":"Too long"}`).Code)
+	// Sending feedback again replaces it.
+	assert.Equal(t, http.StatusOK, post(aliceToken, aliceDone.ID, `{"difficulty":5,"confidence":2,"comment":" Too long "}`).Code)
+	assert.Equal(t, http.StatusConflict, post(aliceToken, aliceOpen.ID, `{"difficulty":3}`).Code)
+	assert.Equal(t, http.StatusForbidden, post(aliceToken, bobDone.ID, `{"difficulty":3}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(bobToken, bobDone.ID, `{"difficulty":6}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(bobToken, bobDone.ID, `{"difficulty":3,"confidence":0}`).Code)
+	assert.Equal(t, http.StatusOK, post(bobToken, bobDone.ID, `{"difficulty":3}`).Code)
+
+	var stored int64
+	db.Model(&models.QuizFeedback{}).Count(&stored)
+	assert.Equal(t, int64(2), stored)
+
+	get := func(username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/feedback-summary", nil)
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusForbidden, get("alice").Code)
+
+	w := get("teacher1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.QuizFeedbackSummary]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Data.Responses)
+	assert.Equal(t, [5]int{0, 0, 1, 0, 1}, resp.Data.DifficultyCounts)
+	if assert.NotNil(t, resp.Data.AverageDifficulty) {
+		assert.InDelta(t, 4.0, *resp.Data.AverageDifficulty, 0.001)
+	}
+	if assert.NotNil(t, resp.Data.AverageConfidence) {
+		assert.InDelta(t, 2.0, *resp.Data.AverageConfidence, 0.001)
+	}
+	if assert.Len(t, resp.Data.Comments, 1) {
+		assert.Equal(t, "Too long", resp.Data.Comments[0].Comment)
+		assert.Equal(t, 5, resp.Data.Comments[0].Difficulty)
+	}
+	assert.NotContains(t, w.Body.String(), "student_id")
+}
+
+func TestPublishQuiz_NormalizeTo(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", MaxAttempts: 1}
+	db.Create(&quiz)
+	raw := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Raw", MaxAttempts: 1}
+	db.Create(&raw)
+	questions := []models.Question{
+		{QuizID: quiz.ID, Content: "Q1", Type: "true_false", Answer: "true", Points: 2},
+		{QuizID: quiz.ID, Content: "Q2", Type: "true_false", Answer: "true", Points: 3},
+		{QuizID: quiz.ID, Content: "Q3", Type: "true_false", Answer: "true", Points: 5},
+		{QuizID: raw.ID, Content: "R1", Type: "true_false", Answer: "true", Points: 4},
+	}
+	db.Create(&questions)
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	studentToken := loginAndGetToken(t, r, "student1", "pass123")
+	post := func(token, path, body string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req := httptest.NewRequest(http.MethodPost, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Only the course teacher may publish or preview.
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+	assert.Equal(t, http.StatusForbidden, post(otherToken, "/api/v1/quizzes/1/publish", `{"normalize_to":50}`).Code)
+	assert.Equal(t, http.StatusForbidden, post(otherToken, "/api/v1/quizzes/1/publish", `{"normalize_to":50,"preview":true}`).Code)
+	var untouched models.Quiz
+	db.First(&untouched, quiz.ID)
+	assert.False(t, untouched.IsPublished)
+	assert.Equal(t, 0, untouched.NormalizeTo)
+
+	// A preview weighs the questions without publishing.
+	w := post(teacherToken, "/api/v1/quizzes/1/publish", `{"normalize_to":100,"preview":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var preview envelope[services.NormalizationPreview]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, 10, preview.Data.TotalPoints)
+	assert.InDelta(t, 10.0, preview.Data.Scale, 0.0001)
+	if assert.Len(t, preview.Data.Questions, 3) {
+		assert.InDelta(t, 50.0, preview.Data.Questions[2].WeightedPoints, 0.0001)
+	}
+	var stored models.Quiz
+	db.First(&stored, quiz.ID)
+	assert.False(t, stored.IsPublished)
+
+	assert.Equal(t, http.StatusBadRequest, post(teacherToken, "/api/v1/quizzes/1/publish", `{"normalize_to":-1}`).Code)
+	assert.Equal(t, http.StatusOK, post(teacherToken, "/api/v1/quizzes/1/publish", `{"normalize_to":100}`).Code)
+	assert.Equal(t, http.StatusOK, post(teacherToken, "/api/v1/quizzes/2/publish", "").Code)
+	db.First(&stored, quiz.ID)
+	assert.True(t, stored.IsPublished)
+	assert.Equal(t, 10, stored.TotalPoints)
+	assert.Equal(t, 100, stored.NormalizeTo)
+	var storedRaw models.Quiz
+	db.First(&storedRaw, raw.ID)
+	assert.True(t, storedRaw.IsPublished)
+	assert.Equal(t, 0.0, storedRaw.ScoreScale)
+
+	assert.Equal(t, http.StatusOK, post(studentToken, "/api/v1/quizzes/1/start", "").Code)
+	answers, _ := json.Marshal(map[string]interface{}{"answers": map[string]interface{}{
+		strconv.FormatUint(uint64(questions[0].ID), 10): "true",
+		strconv.FormatUint(uint64(questions[1].ID), 10): "false",
+		strconv.FormatUint(uint64(questions[2].ID), 10): "true",
+	}})
+	w = post(studentToken, "/api/v1/quizzes/1/submit", string(answers))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result envelope[struct {
+		Score    int `json:"score"`
+		MaxScore int `json:"max_score"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 70, result.Data.Score)
+	assert.Equal(t, 100, result.Data.MaxScore)
+
+	// Once attempted, the quiz can be republished but not renormalized.
+	assert.Equal(t, http.StatusBadRequest, post(teacherToken, "/api/v1/quizzes/1/publish", `{"normalize_to":50}`).Code)
+	assert.Equal(t, http.StatusOK, post(teacherToken, "/api/v1/quizzes/1/publish", `{"normalize_to":100}`).Code)
+	db.First(&stored, quiz.ID)
+	assert.Equal(t, 100, stored.NormalizeTo)
+
+	assert.Equal(t, http.StatusOK, post(studentToken, "/api/v1/quizzes/2/start", "").Code)
+	answers, _ = json.Marshal(map[string]interface{}{"answers": map[string]interface{}{
+		strconv.FormatUint(uint64(questions[3].ID), 10): "true",
+	}})
+	w = post(studentToken, "/api/v1/quizzes/2/submit", string(answers))
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 4, result.Data.Score)
+	assert.Equal(t, 4, result.Data.MaxScore)
+}
+
+func TestCopyQuestions(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	other := createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+
+	course := models.Course{Name: "Course A", TeacherID: teacher.ID}
+	db.Create(&course)
+	otherCourse := models.Course{Name: "Course B", TeacherID: other.ID}
+	db.Create(&otherCourse)
+	source := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Source", IsPublished: true}
+	db.Create(&source)
+	target := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Target"}
+	db.Create(&target)
+	foreign := models.Quiz{CourseID: otherCourse.ID, CreatedByID: other.ID, Title: "Foreign"}
+	db.Create(&foreign)
+	q1 := models.Question{QuizID: source.ID, Type: "single_choice", Content: "Q1", Options: `["A","B"]`, Answer: "A", Points: 2, OrderNum: 1}
+	q2 := models.Question{QuizID: source.ID, Type: "fill_blank", Content: "Q2", Answer: "E", Points: 3, OrderNum: 2}
+	q3 := models.Question{QuizID: source.ID, Type: "true_false", Content: "Q3", Answer: "true", Points: 1, OrderNum: 3}
+	existing := models.Question{QuizID: target.ID, Type: "true_false", Content: "Existing", Answer: "false", Points: 1, OrderNum: 4}
+	for _, q := range []*models.Question{&q1, &q2, &q3, &existing} {
+		db.Create(q)
+	}
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+	copyFrom := func(token string, quizID uint, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/"+strconv.Itoa(int(quizID))+"/questions/copy-from", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	body := func(sourceID uint, ids ...uint) string {
+		raw, _ := json.Marshal(map[string]interface{}{"source_quiz_id": sourceID, "question_ids": ids})
+		return string(raw)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, copyFrom(teacherToken, target.ID, body(source.ID)).Code)
+	assert.Equal(t, http.StatusForbidden, copyFrom(otherToken, foreign.ID, body(source.ID, q1.ID)).Code)
+	assert.Equal(t, http.StatusForbidden, copyFrom(teacherToken, target.ID, body(foreign.ID, q1.ID)).Code)
+	assert.Equal(t, http.StatusBadRequest, copyFrom(teacherToken, source.ID, body(target.ID, existing.ID)).Code, "published target")
+	assert.Equal(t, http.StatusNotFound, copyFrom(teacherToken, target.ID, body(source.ID, q1.ID, existing.ID)).Code)
+
+	w := copyFrom(teacherToken, target.ID, body(source.ID, q3.ID, q1.ID, q1.ID))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp envelope[[]services.QuestionResponse]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data, 2) {
+		assert.Equal(t, "Q1", resp.Data[0].Content)
+		assert.Equal(t, []interface{}{"A", "B"}, resp.Data[0].Options)
+		assert.Equal(t, "A", resp.Data[0].Answer)
+		assert.Equal(t, 2, resp.Data[0].Points)
+		assert.Equal(t, 5, resp.Data[0].OrderNum)
+		assert.Equal(t, "Q3", resp.Data[1].Content)
+		assert.Equal(t, 6, resp.Data[1].OrderNum)
+		assert.NotEqual(t, q1.ID, resp.Data[0].ID)
+		assert.Equal(t, target.ID, resp.Data[0].QuizID)
+	}
+
+	var count int64
+	db.Model(&models.Question{}).Where("quiz_id = ?", target.ID).Count(&count)
+	assert.Equal(t, int64(3), count)
+	db.Model(&models.Question{}).Where("quiz_id = ?", source.ID).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestQuestionTags(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz"}
+	db.Create(&quiz)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(token, http.MethodPost, "/api/v1/quizzes/1/questions", `{"type":"true_false","content":"Q1","answer":"true","tags":[" Gauss-Law ","gauss-law","flux"]}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created envelope[services.QuestionResponse]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, []string{"Gauss-Law", "flux"}, created.Data.Tags)
+	w = do(token, http.MethodPost, "/api/v1/quizzes/1/questions", `{"type":"true_false","content":"Q2","answer":"false"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(token, http.MethodPost, "/api/v1/quizzes/1/questions", `{"type":"true_false","content":"Q3","answer":"true","tags":["  "]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	tooMany, _ := json.Marshal(map[string]interface{}{"tags": []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}})
+	w = do(token, http.MethodPut, "/api/v1/questions/2", string(tooMany))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(token, http.MethodPut, "/api/v1/questions/2", `{"tags":["flux"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	list := func(query string) []services.QuestionResponse {
+		w := do(token, http.MethodGet, "/api/v1/quizzes/1/questions"+query, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[[]services.QuestionResponse]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+	assert.Len(t, list(""), 2)
+	assert.Len(t, list("?tag=FLUX"), 2)
+	if got := list("?tag=gauss-law"); assert.Len(t, got, 1) {
+		assert.Equal(t, "Q1", got[0].Content)
+		assert.Equal(t, "true", got[0].Answer)
+	}
+
+	w = do(token, http.MethodPut, "/api/v1/questions/1", `{"tags":[]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, list("?tag=gauss-law"))
+
+	w = do(loginAndGetToken(t, r, "teacher2", "pass123"), http.MethodGet, "/api/v1/quizzes/1/questions", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestGetLeaderboard_StudentAnonymized(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID})
+
+	quiz := models.Quiz{
+		CourseID:    course.ID,
+		CreatedByID: teacher.ID,
+		Title:       "Quiz",
+		IsPublished: true,
+		MaxAttempts: 2,
+		TotalPoints: 100,
+	}
+	db.Create(&quiz)
+
+	submitted := time.Now()
+	for _, a := range []struct {
+		studentID uint
+		score     int
+	}{{alice.ID, 60}, {alice.ID, 90}, {bob.ID, 75}} {
+		score := a.score
+		db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: a.studentID, SubmittedAt: &submitted, Score: &score, MaxScore: 100})
+	}
+
+	r := setupQuizRouter(db, "test-secret")
+	studentToken := loginAndGetToken(t, r, "bob", "pass123")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/leaderboard", nil)
+	req.Header.Set("Authorization", "Bearer "+studentToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	db.Model(&quiz).Update("leaderboard_enabled", true)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp envelope[services.Leaderboard]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Data.Anonymized)
+	assert.Len(t, resp.Data.Entries, 2)
+	assert.Equal(t, 90, resp.Data.Entries[0].BestScore)
+	assert.Equal(t, "T*********", resp.Data.Entries[0].DisplayName)
+	assert.Zero(t, resp.Data.Entries[0].StudentID)
+	assert.True(t, resp.Data.Entries[1].IsMe)
+
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/leaderboard", nil)
+	req.Header.Set("Authorization", "Bearer "+teacherToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Data.Anonymized)
+	assert.Equal(t, "Test alice", resp.Data.Entries[0].DisplayName)
+}
+
+func TestGetCourseQuizSummary(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	s1 := createCourseTestUser(t, db, "student1", "pass123", "student")
+	s2 := createCourseTestUser(t, db, "student2", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: s1.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: s2.ID, Role: "student"})
+
+	db.Create(&models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Draft"})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 2, TotalPoints: 10}
+	db.Create(&quiz)
+
+	submitted := time.Now()
+	for _, score := range []int{5, 10} {
+		sc := score
+		db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: s1.ID, SubmittedAt: &submitted, Score: &sc, MaxScore: 10})
+	}
+
+	r := setupQuizRouter(db, "test-secret")
+
+	token := loginAndGetToken(t, r, "student1", "pass123")
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/courses/1/quizzes/summary", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	token = loginAndGetToken(t, r, "teacher1", "pass123")
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/courses/1/quizzes/summary", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp envelope[services.CourseQuizSummary]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(2), resp.Data.TotalQuizzes)
+	assert.Equal(t, int64(1), resp.Data.PublishedQuizzes)
+	assert.Equal(t, int64(2), resp.Data.SubmittedAttempts)
+	assert.InDelta(t, 0.75, resp.Data.AvgScoreRate, 0.001)
+	assert.InDelta(t, 0.5, resp.Data.ParticipationRate, 0.001)
+}
+
+func TestSubmitQuiz_FillBlankFullWidthInput(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1, TotalPoints: 6}
+	db.Create(&quiz)
+
+	q1 := models.Question{QuizID: quiz.ID, Content: "2+2=?", Type: "fill_blank", Answer: "4", MatchRule: "exact_trim", Points: 2}
+	q2 := models.Question{QuizID: quiz.ID, Content: "Unit?", Type: "fill_blank", Answer: "V/m", MatchRule: "exact_trim", Points: 2}
+	q3 := models.Question{QuizID: quiz.ID, Content: "Law?", Type: "fill_blank", Answer: "gauss", MatchRule: "contains", Points: 2}
+	db.Create(&q1)
+	db.Create(&q2)
+	db.Create(&q3)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	startReq := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/start", nil)
+	startReq.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(httptest.NewRecorder(), startReq)
+
+	payload, _ := json.Marshal(map[string]interface{}{"answers": map[string]interface{}{
+		strconv.FormatUint(uint64(q1.ID), 10): "　４ ",
+		strconv.FormatUint(uint64(q2.ID), 10): "Ｖ／ｍ",
+		strconv.FormatUint(uint64(q3.ID), 10): "ＧＡＵＳＳ定律",
+	}})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/submit", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[map[string]interface{}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(6), resp.Data["score"])
+}
+
+func TestSubmitQuiz_ScoreMatchesGradingPackage(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	ended := time.Now().Add(time.Hour)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1, TotalPoints: 14, EndTime: &ended, ShowAnswerAfterEnd: true}
+	db.Create(&quiz)
+
+	questions := []models.Question{
+		{QuizID: quiz.ID, Content: "single", Type: "single_choice", Options: `["A","B"]`, Answer: "B", Points: 3},
+		{QuizID: quiz.ID, Content: "multi", Type: "multiple_choice", Options: `["A","B","C"]`, Answer: `["A","C"]`, Points: 4},
+		{QuizID: quiz.ID, Content: "tf", Type: "true_false", Answer: "true", Points: 2},
+		{QuizID: quiz.ID, Content: "blank", Type: "fill_blank", Answer: `["E=mc^2","E = mc^2"]`, MatchRule: "exact_trim", Points: 5},
+	}
+	for i := range questions {
+		db.Create(&questions[i])
+	}
+	answers := map[string]interface{}{
+		strconv.FormatUint(uint64(questions[0].ID), 10): "B",
+		strconv.FormatUint(uint64(questions[1].ID), 10): []interface{}{"C", "A"},
+		strconv.FormatUint(uint64(questions[2].ID), 10): "false",
+		strconv.FormatUint(uint64(questions[3].ID), 10): " e = MC^2 ",
+	}
+
+	expected := 0
+	for _, q := range questions {
+		expected += grading.Score(q, answers[strconv.FormatUint(uint64(q.ID), 10)])
+	}
+	assert.Equal(t, 12, expected)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	startReq := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/start", nil)
+	startReq.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(httptest.NewRecorder(), startReq)
+
+	payload, _ := json.Marshal(map[string]interface{}{"answers": answers})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/submit", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp envelope[map[string]interface{}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(expected), resp.Data["score"])
+
+	// The per-question review re-grades the stored attempt and must agree.
+	past := time.Now().Add(-time.Minute)
+	db.Model(&quiz).Update("end_time", past)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/quizzes/1/result", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var review envelope[struct {
+		Questions []struct {
+			EarnedPoints int `json:"earned_points"`
+		} `json:"questions"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &review))
+	total := 0
+	for _, q := range review.Data.Questions {
+		total += q.EarnedPoints
+	}
+	assert.Equal(t, expected, total)
+}
+
+func TestCreateQuiz_ValidationDetails(t *testing.T) {
+	db := setupQuizTestDB(t)
+	createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes", bytes.NewReader([]byte(`{"course_id": 1}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp struct {
+		Error struct {
+			Code    string       `json:"code"`
+			Details []fieldError `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "BAD_REQUEST", resp.Error.Code)
+	assert.Equal(t, []fieldError{{Field: "title", Rule: "required"}}, resp.Error.Details)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/quizzes", bytes.NewReader([]byte(`{"course_id": "x", "title": "t"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "course_id", resp.Error.Details[0].Field)
+	assert.Equal(t, "type", resp.Error.Details[0].Rule)
+}
+
+func TestQuizSchedule_Validated(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	db.Create(&models.Course{Name: "Test Course", TeacherID: teacher.ID})
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(method, path, body string) (int, string) {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Error.Code
+	}
+
+	for _, tc := range []struct {
+		body string
+		code string
+	}{
+		{`{"course_id":1,"title":"Q","start_time":"2026-03-01T10:00:00Z","end_time":"2026-03-01T09:00:00Z"}`, "INVALID_QUIZ_WINDOW"},
+		{`{"course_id":1,"title":"Q","start_time":"2026-03-01T10:00:00Z","end_time":"2026-03-01T10:00:00Z"}`, "INVALID_QUIZ_WINDOW"},
+		{`{"course_id":1,"title":"Q","time_limit":-5}`, "INVALID_TIME_LIMIT"},
+		{`{"course_id":1,"title":"Q","time_limit":90,"start_time":"2026-03-01T10:00:00Z","end_time":"2026-03-01T11:00:00Z"}`, "INVALID_TIME_LIMIT"},
+	} {
+		status, code := do(http.MethodPost, "/api/v1/quizzes", tc.body)
+		assert.Equal(t, http.StatusBadRequest, status, tc.body)
+		assert.Equal(t, tc.code, code, tc.body)
+	}
+
+	status, _ := do(http.MethodPost, "/api/v1/quizzes", `{"course_id":1,"title":"Q","time_limit":60,"start_time":"2026-03-01T10:00:00Z","end_time":"2026-03-01T11:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = do(http.MethodPost, "/api/v1/quizzes", `{"course_id":1,"title":"Open ended","time_limit":600,"start_time":"2026-03-01T10:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, status)
+
+	// Updates are checked against the bounds the quiz already has.
+	status, code := do(http.MethodPut, "/api/v1/quizzes/1", `{"end_time":"2026-03-01T09:30:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "INVALID_QUIZ_WINDOW", code)
+	status, code = do(http.MethodPut, "/api/v1/quizzes/1", `{"time_limit":61}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "INVALID_TIME_LIMIT", code)
+	status, _ = do(http.MethodPut, "/api/v1/quizzes/1", `{"end_time":"2026-03-01T12:00:00Z","time_limit":120}`)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestQuizEndpoints_NonEnrolledStudentForbidden(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "outsider", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1})
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "outsider", "pass123")
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/v1/courses/1/quizzes", ""},
+		{http.MethodGet, "/api/v1/quizzes/1", ""},
+		{http.MethodPost, "/api/v1/quizzes/1/start", ""},
+		{http.MethodPost, "/api/v1/quizzes/1/submit", `{"answers":{}}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewReader([]byte(tc.body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, tc.path)
+	}
+}
+
+func TestQuizAttemptRemainingAndGracePeriod(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 3, TimeLimit: 60}
+	db.Create(&quiz)
+
+	now := time.Now()
+	attempt := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 1, StartedAt: now, Deadline: now.Add(10 * time.Minute)}
+	db.Create(&attempt)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/v1/quizzes/1/attempt/remaining", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.AttemptRemaining]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, attempt.ID, resp.Data.AttemptID)
+	assert.InDelta(t, 600, resp.Data.RemainingSeconds, 5)
+
+	// Just past the deadline but inside the grace window: accepted.
+	db.Model(&attempt).Update("deadline", time.Now().Add(-2*time.Second))
+	w = do(http.MethodPost, "/api/v1/quizzes/1/submit", `{"answers":{}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do(http.MethodGet, "/api/v1/quizzes/1/attempt/remaining", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Beyond the grace window: rejected.
+	late := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 2, StartedAt: now, Deadline: time.Now().Add(-services.SubmissionGracePeriod - 10*time.Second)}
+	db.Create(&late)
+	w = do(http.MethodPost, "/api/v1/quizzes/1/submit", `{"answers":{}}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestGrantAttempt_PerStudent(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1}
+	db.Create(&quiz)
+	submitted := time.Now()
+	for _, id := range []uint{alice.ID, bob.ID} {
+		db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: id, AttemptNumber: 1, StartedAt: submitted, Deadline: submitted, SubmittedAt: &submitted})
+	}
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	bobToken := loginAndGetToken(t, r, "bob", "pass123")
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/students/2/grant-attempt", `{"reason":"self-serve"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/students/2/grant-attempt", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/students/2/grant-attempt", `{"reason":"medical leave"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp envelope[services.AttemptGrantResult]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(2), resp.Data.MaxAttempts)
+	assert.Equal(t, int64(1), resp.Data.AttemptsUsed)
+
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/start", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(bobToken, http.MethodPost, "/api/v1/quizzes/1/start", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestGetQuizInfo_UsesNoAttempt(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	createCourseTestUser(t, db, "outsider", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 2, TimeLimit: 30, TotalPoints: 5}
+	db.Create(&quiz)
+	db.Create(&models.Question{QuizID: quiz.ID, Type: "true_false", Content: "E is conservative", Answer: "true", Points: 5})
+	draft := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Draft", MaxAttempts: 1}
+	db.Create(&draft)
+	submitted := time.Now()
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 1, StartedAt: submitted, Deadline: submitted, SubmittedAt: &submitted})
+
+	r := setupQuizRouter(db, "test-secret")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	do := func(token, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	info := func() services.QuizInfo {
+		w := do(aliceToken, http.MethodGet, "/api/v1/quizzes/1/info")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "conservative")
+		var resp envelope[services.QuizInfo]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	got := info()
+	assert.Equal(t, "open", got.Status)
+	assert.Equal(t, 30, got.TimeLimit)
+	assert.Equal(t, 5, got.MaxScore)
+	assert.EqualValues(t, 1, got.QuestionCount)
+	assert.Equal(t, 1, got.AttemptsUsed)
+	assert.Equal(t, 1, got.AttemptsRemaining)
+	assert.False(t, got.InProgress)
+	info()
+	var attempts int64
+	db.Model(&models.QuizAttempt{}).Where("quiz_id = ?", quiz.ID).Count(&attempts)
+	assert.EqualValues(t, 1, attempts)
+
+	w := do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/start")
+	assert.Equal(t, http.StatusOK, w.Code)
+	got = info()
+	assert.True(t, got.InProgress)
+	assert.NotNil(t, got.AttemptDeadline)
+	assert.Equal(t, 0, got.AttemptsRemaining)
+
+	w = do(aliceToken, http.MethodGet, "/api/v1/quizzes/2/info")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(loginAndGetToken(t, r, "outsider", "pass123"), http.MethodGet, "/api/v1/quizzes/1/info")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestReopenAttempt(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	endTime := time.Now().Add(30 * time.Minute)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1, TimeLimit: 60, EndTime: &endTime}
+	db.Create(&quiz)
+	submitted := time.Now().Add(-time.Minute)
+	score := 3
+	attempt := models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 1, StartedAt: submitted, Deadline: submitted,
+		SubmittedAt: &submitted, Score: &score, Answers: `{"1":"A"}`}
+	db.Create(&attempt)
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The only attempt is used up until it is reopened.
+	assert.Equal(t, http.StatusForbidden, do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/start", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(otherToken, http.MethodPost, "/api/v1/quiz-attempts/1/reopen", `{"reason":"network"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(teacherToken, http.MethodPost, "/api/v1/quiz-attempts/1/reopen", `{}`).Code)
+
+	w := do(teacherToken, http.MethodPost, "/api/v1/quiz-attempts/1/reopen", `{"reason":"browser crashed on submit"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var reopened envelope[models.QuizAttempt]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &reopened))
+	assert.Nil(t, reopened.Data.SubmittedAt)
+	assert.Nil(t, reopened.Data.Score)
+	// The time limit would run past the quiz end, so the deadline is the end.
+	assert.WithinDuration(t, endTime, reopened.Data.Deadline, time.Second)
+
+	var logs []models.AuditLog
+	db.Find(&logs)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, "quiz_attempt.reopen", logs[0].Action)
+		assert.Equal(t, teacher.ID, logs[0].ActorID)
+		assert.Equal(t, attempt.ID, logs[0].TargetID)
+		assert.Contains(t, string(logs[0].Details), "browser crashed on submit")
+	}
+
+	w = do(teacherToken, http.MethodPost, "/api/v1/quiz-attempts/1/reopen", `{"reason":"again"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "ATTEMPT_NOT_SUBMITTED")
+
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/start", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var started envelope[struct {
+		Attempt models.QuizAttempt `json:"attempt"`
+		Resumed bool               `json:"resumed"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &started))
+	assert.True(t, started.Data.Resumed)
+	assert.Equal(t, attempt.ID, started.Data.Attempt.ID)
+	assert.Equal(t, `{"1":"A"}`, started.Data.Attempt.Answers)
+
+	// Once the quiz has ended nothing can be reopened.
+	db.Model(&models.QuizAttempt{}).Where("id = ?", attempt.ID).Update("submitted_at", time.Now())
+	db.Model(&quiz).Update("end_time", time.Now().Add(-time.Minute))
+	assert.Equal(t, http.StatusBadRequest, do(teacherToken, http.MethodPost, "/api/v1/quiz-attempts/1/reopen", `{"reason":"late"}`).Code)
+}
+
+func TestBulkPublishQuizzes(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	other := models.Course{Name: "Other Course", TeacherID: teacher.ID}
+	db.Create(&other)
+	for i, courseID := range []uint{course.ID, course.ID, other.ID} {
+		quiz := models.Quiz{CourseID: courseID, CreatedByID: teacher.ID, Title: "Quiz " + strconv.Itoa(i+1), MaxAttempts: 1}
+		db.Create(&quiz)
+		db.Create(&models.Question{QuizID: quiz.ID, Type: "true_false", Content: "q", Answer: "true", Points: i + 2})
+		db.Create(&models.Question{QuizID: quiz.ID, Type: "true_false", Content: "q", Answer: "true", Points: 1})
+	}
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+
+	do := func(token, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	published := func() []bool {
+		var quizzes []models.Quiz
+		db.Order("id").Find(&quizzes)
+		out := make([]bool, len(quizzes))
+		for i, q := range quizzes {
+			out[i] = q.IsPublished
+		}
+		return out
+	}
+
+	assert.Equal(t, http.StatusForbidden, do(otherToken, "/api/v1/courses/1/quizzes/publish", `{"quiz_ids":[1,2]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(teacherToken, "/api/v1/courses/1/quizzes/publish", `{"quiz_ids":[]}`).Code)
+
+	// One quiz from another course rejects the whole request.
+	w := do(teacherToken, "/api/v1/courses/1/quizzes/publish", `{"quiz_ids":[1,3,99]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "QUIZ_NOT_IN_COURSE")
+	assert.Contains(t, w.Body.String(), `"quiz_ids":[3,99]`)
+	assert.Equal(t, []bool{false, false, false}, published())
+
+	w = do(teacherToken, "/api/v1/courses/1/quizzes/publish", `{"quiz_ids":[2,1,2]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[[]services.BulkQuizResult]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data, 2) {
+		assert.Equal(t, uint(2), resp.Data[0].QuizID)
+		assert.True(t, resp.Data[0].Success)
+		assert.Equal(t, 4, resp.Data[0].Quiz.TotalPoints)
+		assert.Equal(t, 3, resp.Data[1].Quiz.TotalPoints)
+	}
+	assert.Equal(t, []bool{true, true, false}, published())
+
+	now := time.Now()
+	db.Create(&models.QuizAttempt{QuizID: 1, StudentID: alice.ID, AttemptNumber: 1, StartedAt: now, Deadline: now})
+	w = do(teacherToken, "/api/v1/courses/1/quizzes/unpublish", `{"quiz_ids":[1,2]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data, 2) {
+		assert.False(t, resp.Data[0].Success)
+		assert.Equal(t, "attempts_exist", resp.Data[0].Error)
+		assert.True(t, resp.Data[1].Success)
+	}
+	assert.Equal(t, []bool{true, false, false}, published())
+}
+
+func TestQuestionOptions_RejectDuplicateAndBlank(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz"}
+	db.Create(&quiz)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	type errorBody struct {
+		Error struct {
+			Message string            `json:"message"`
+			Details map[string]string `json:"details"`
+		} `json:"error"`
+	}
+
+	w := do(http.MethodPost, "/api/v1/quizzes/1/questions", `{"type":"single_choice","content":"Q","options":["A"," B","B "],"answer":"A"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp errorBody
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "duplicate option", resp.Error.Message)
+	assert.Equal(t, "B", resp.Error.Details["duplicate"])
+
+	w = do(http.MethodPost, "/api/v1/quizzes/1/questions", `{"type":"multiple_choice","content":"Q","options":["A","  "],"answer":"A"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "options must not be blank")
+
+	w = do(http.MethodPost, "/api/v1/quizzes/1/questions", `{"type":"single_choice","content":"Q","options":["A","B"],"answer":"A"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(http.MethodPut, "/api/v1/questions/1", `{"options":["A","C","A"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	resp = errorBody{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "A", resp.Error.Details["duplicate"])
+
+	w = do(http.MethodPut, "/api/v1/questions/1", `{"options":["A",""]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Updates return the options as a list, like every other question response.
+	w = do(http.MethodPut, "/api/v1/questions/1", `{"content":"Q2"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated envelope[struct {
+		Options []string `json:"options"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, []string{"A", "B"}, updated.Data.Options)
+
+	var question models.Question
+	db.First(&question, 1)
+	assert.Equal(t, `["A","B"]`, question.Options)
+}
+
+func TestQuestionContentFormat(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz"}
+	db.Create(&quiz)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(method, path string, body map[string]interface{}) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	addPath := fmt.Sprintf("/api/v1/quizzes/%d/questions", quiz.ID)
+
+	for _, tc := range []struct {
+		name    string
+		format  string
+		content string
+		stored  string
+	}{
+		{"omitted defaults to plain", "", "What is flux?", "plain"},
+		{"plain keeps stray dollars", "plain", "It costs $5", "plain"},
+		{"markdown", "markdown", "**Gauss** law", "markdown"},
+		{"inline latex", "latex", `$\nabla \times E = 0$`, "latex"},
+		{"display latex", "latex", `$$\oint E \cdot dA = Q/\varepsilon_0$$`, "latex"},
+		{"escaped dollar", "latex", `Pay \$5 for $x^2$`, "latex"},
+		{"content at the size limit", "latex", "$" + strings.Repeat("x", 65533) + "$", "latex"},
+	} {
+		t.Run("accepts "+tc.name, func(t *testing.T) {
+			body := map[string]interface{}{"type": "true_false", "content": tc.content, "answer": "true"}
+			if tc.format != "" {
+				body["content_format"] = tc.format
+			}
+			w := do(http.MethodPost, addPath, body)
+			assert.Equal(t, http.StatusCreated, w.Code)
+			var resp envelope[services.QuestionResponse]
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tc.stored, resp.Data.ContentFormat)
+			assert.Equal(t, tc.content, resp.Data.Content)
+		})
+	}
+
+	for _, tc := range []struct {
+		name    string
+		format  string
+		content string
+		message string
+	}{
+		{"unknown format", "html", "<b>flux</b>", "invalid content format (plain, markdown, latex)"},
+		{"format in upper case", "LaTeX", "$x$", "invalid content format (plain, markdown, latex)"},
+		{"unbalanced inline math", "latex", `$\nabla \times E`, "unbalanced latex math delimiters"},
+		{"unbalanced display math", "latex", "$$E = mc^2$", "unbalanced latex math delimiters"},
+		{"content over the size limit", "latex", "$" + strings.Repeat("x", 65534) + "$", "question content too large"},
+	} {
+		t.Run("rejects "+tc.name, func(t *testing.T) {
+			w := do(http.MethodPost, addPath, map[string]interface{}{"type": "true_false", "content": tc.content, "answer": "true", "content_format": tc.format})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.message)
+		})
+	}
+
+	// Switching the format on update checks the stored content against it.
+	w := do(http.MethodPost, addPath, map[string]interface{}{"type": "true_false", "content": "It costs $5", "answer": "true"})
+	var created envelope[services.QuestionResponse]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	w = do(http.MethodPut, fmt.Sprintf("/api/v1/questions/%d", created.Data.ID), map[string]interface{}{"content_format": "latex"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unbalanced latex math delimiters")
+	w = do(http.MethodPut, fmt.Sprintf("/api/v1/questions/%d", created.Data.ID), map[string]interface{}{"content_format": "markdown"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestQuizLimits_FromModuleSettings(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "alice", "pass123", "student")
+	limited := models.Course{
+		Name:           "Limited",
+		TeacherID:      teacher.ID,
+		ModuleSettings: datatypes.JSON(`{"quiz":{"max_options":3,"max_answers_bytes":1024}}`),
+	}
+	db.Create(&limited)
+	defaults := models.Course{Name: "Defaults", TeacherID: teacher.ID}
+	db.Create(&defaults)
+	db.Create(&models.CourseEnrollment{CourseID: limited.ID, UserID: student.ID, Role: "student"})
+	draft := models.Quiz{CourseID: limited.ID, CreatedByID: teacher.ID, Title: "Draft"}
+	db.Create(&draft)
+	other := models.Quiz{CourseID: defaults.ID, CreatedByID: teacher.ID, Title: "Other"}
+	db.Create(&other)
+	live := models.Quiz{CourseID: limited.ID, CreatedByID: teacher.ID, Title: "Live", IsPublished: true, MaxAttempts: 1}
+	db.Create(&live)
+	db.Create(&models.Question{QuizID: live.ID, Type: "fill_blank", Content: "Q", Answer: "x", Points: 1, OrderNum: 1})
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	type errorBody struct {
+		Error struct {
+			Message string                 `json:"message"`
+			Details map[string]interface{} `json:"details"`
+		} `json:"error"`
+	}
+
+	fourOptions := `{"type":"single_choice","content":"Q","options":["A","B","C","D"],"answer":"A"}`
+	w := do(teacherToken, http.MethodPost, "/api/v1/quizzes/"+strconv.Itoa(int(draft.ID))+"/questions", fourOptions)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp errorBody
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "too many options (max 3)", resp.Error.Message)
+	assert.Equal(t, float64(3), resp.Error.Details["limit"])
+
+	w = do(teacherToken, http.MethodPost, "/api/v1/quizzes/"+strconv.Itoa(int(other.ID))+"/questions", fourOptions)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/"+strconv.Itoa(int(live.ID))+"/start", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	big := strings.Repeat("x", 2000)
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/"+strconv.Itoa(int(live.ID))+"/submit", `{"answers":{"1":"`+big+`"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	resp = errorBody{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "answers too large (max 1024 bytes)", resp.Error.Message)
+}
+
+func TestQuizSizeLimits(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	course := models.Course{
+		Name:           "Limited",
+		TeacherID:      teacher.ID,
+		ModuleSettings: datatypes.JSON(`{"quiz":{"max_questions":2,"max_total_points":10}}`),
+	}
+	db.Create(&course)
+	draft := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Draft"}
+	db.Create(&draft)
+	// Questions written before the limits were lowered only fail on publish.
+	oversized := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Oversized"}
+	db.Create(&oversized)
+	for i := 1; i <= 3; i++ {
+		db.Create(&models.Question{QuizID: oversized.ID, Type: "fill_blank", Content: "Q", Answer: "x", Points: 1, OrderNum: i})
+	}
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	type errorBody struct {
+		Error struct {
+			Message string                 `json:"message"`
+			Details map[string]interface{} `json:"details"`
+		} `json:"error"`
+	}
+	question := func(points int) string {
+		return `{"type":"fill_blank","content":"Q","answer":"x","points":` + strconv.Itoa(points) + `}`
+	}
+	addPath := "/api/v1/quizzes/" + strconv.Itoa(int(draft.ID)) + "/questions"
+
+	assert.Equal(t, http.StatusCreated, post(addPath, question(6)).Code)
+	w := post(addPath, question(5))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp errorBody
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "total points too high (max 10)", resp.Error.Message)
+	assert.Equal(t, float64(10), resp.Error.Details["limit"])
+
+	assert.Equal(t, http.StatusCreated, post(addPath, question(4)).Code)
+	w = post(addPath, question(1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	resp = errorBody{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "too many questions (max 2)", resp.Error.Message)
+
+	// Copied questions count against the limits too, all or none.
+	target := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Target"}
+	db.Create(&target)
+	var sourceIDs []uint
+	db.Model(&models.Question{}).Where("quiz_id = ?", oversized.ID).Order("id").Pluck("id", &sourceIDs)
+	copyPath := "/api/v1/quizzes/" + strconv.Itoa(int(target.ID)) + "/questions/copy-from"
+	copyBody := func(ids []uint) string {
+		b, _ := json.Marshal(map[string]interface{}{"source_quiz_id": oversized.ID, "question_ids": ids})
+		return string(b)
+	}
+	w = post(copyPath, copyBody(sourceIDs))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "too many questions (max 2)")
+	var copied int64
+	db.Model(&models.Question{}).Where("quiz_id = ?", target.ID).Count(&copied)
+	assert.Zero(t, copied)
+	assert.Equal(t, http.StatusCreated, post(copyPath, copyBody(sourceIDs[:2])).Code)
+
+	w = post("/api/v1/quizzes/"+strconv.Itoa(int(oversized.ID))+"/publish", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A bulk publish names the quiz over the limit and publishes none.
+	w = post("/api/v1/courses/"+strconv.Itoa(int(course.ID))+"/quizzes/publish", `{"quiz_ids":[`+strconv.Itoa(int(draft.ID))+`,`+strconv.Itoa(int(oversized.ID))+`]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	resp = errorBody{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(oversized.ID), resp.Error.Details["quiz_id"])
+	var published int64
+	db.Model(&models.Quiz{}).Where("is_published = ?", true).Count(&published)
+	assert.Zero(t, published)
+
+	assert.Equal(t, http.StatusOK, post("/api/v1/quizzes/"+strconv.Itoa(int(draft.ID))+"/publish", "").Code)
+}
+
+func TestImportQuestions_ChineseCSV(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	course := models.Course{
+		Name:           "Test Course",
+		TeacherID:      teacher.ID,
+		ModuleSettings: datatypes.JSON(`{"quiz":{"max_questions":4}}`),
+	}
+	db.Create(&course)
+	draft := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Draft"}
+	db.Create(&draft)
+	live := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Live", IsPublished: true}
+	db.Create(&live)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+	uploadAs := func(token string, quizID uint, content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("file", "题库.csv")
+		_, _ = part.Write([]byte(content))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/quizzes/%d/questions/import", quizID), &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	upload := func(quizID uint, content string) *httptest.ResponseRecorder {
+		return uploadAs(token, quizID, content)
+	}
+
+	file := "\uFEFF题型,题目,选项,答案,分值,标签\n" +
+		"单选题,真空中的光速是多少？,3e8 m/s|3e6 m/s,3e8 m/s,2,电磁波\n" +
+		"填空,\"高斯定律：∮E·dA = ___\",,Q/ε₀,,\n" +
+		"单选,重复选项,A|A,A,1,\n" +
+		"问答,不支持的题型,,答,1,\n" +
+		"判断,分值不是数字,,true,两分,\n" +
+		"true_false,麦克斯韦方程组共有四个方程,,true,1,电磁场|方程\n"
+	w := upload(draft.ID, file)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[questionImportResult]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Data.Imported, 3) {
+		first := resp.Data.Imported[0]
+		assert.Equal(t, "single_choice", first.Type)
+		assert.Equal(t, "真空中的光速是多少？", first.Content)
+		assert.Equal(t, []interface{}{"3e8 m/s", "3e6 m/s"}, first.Options)
+		assert.Equal(t, 2, first.Points)
+		assert.Equal(t, []string{"电磁波"}, first.Tags)
+		assert.Equal(t, "fill_blank", resp.Data.Imported[1].Type)
+		assert.Equal(t, "高斯定律：∮E·dA = ___", resp.Data.Imported[1].Content)
+		assert.Equal(t, 1, resp.Data.Imported[1].Points)
+		assert.Equal(t, []string{"电磁场", "方程"}, resp.Data.Imported[2].Tags)
+	}
+	if assert.Len(t, resp.Data.Errors, 3) {
+		assert.Equal(t, 4, resp.Data.Errors[0].Line)
+		assert.Equal(t, "duplicate option", resp.Data.Errors[0].Message)
+		assert.Equal(t, 5, resp.Data.Errors[1].Line)
+		assert.Equal(t, "invalid question type", resp.Data.Errors[1].Message)
+		assert.Equal(t, 6, resp.Data.Errors[2].Line)
+		assert.Equal(t, "points", resp.Data.Errors[2].Column)
+	}
+	var count int64
+	db.Model(&models.Question{}).Where("quiz_id = ?", draft.ID).Count(&count)
+	assert.EqualValues(t, 3, count)
+
+	w = upload(draft.ID, "题目,答案\n没有题型,A\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing column: type")
+	assert.Equal(t, http.StatusBadRequest, upload(live.ID, "type,content,answer\nfill_blank,Q,A\n").Code)
+	assert.Equal(t, http.StatusNotFound, upload(999, "type,content,answer\nfill_blank,Q,A\n").Code)
+	assert.Equal(t, http.StatusForbidden, uploadAs(otherToken, draft.ID, "type,content,answer\nfill_blank,Q,A\n").Code)
+
+	// The rows must fit the quiz's limits together, or none is imported.
+	w = upload(draft.ID, "type,content,answer\nfill_blank,Q1,A\nfill_blank,Q2,A\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "too many questions (max 4)")
+	db.Model(&models.Question{}).Where("quiz_id = ?", draft.ID).Count(&count)
+	assert.EqualValues(t, 3, count)
+}
+
+func TestGradePreview_UnpublishedQuiz(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "alice", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz"}
+	db.Create(&quiz)
+	db.Create(&models.Question{QuizID: quiz.ID, Type: "single_choice", Content: "Q1", Options: `["A","B"]`, Answer: "A", Points: 2, OrderNum: 1})
+	db.Create(&models.Question{QuizID: quiz.ID, Type: "fill_blank", Content: "Q2", Answer: "E=mc^2", MatchRule: "exact_trim", Points: 3, OrderNum: 2})
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+
+	do := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/grade-preview", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(aliceToken, `{"answers":{"1":"A"}}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(teacherToken, `{"answers":{"1":"B","2":" E=mc^2 "}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.GradePreview]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Data.Score)
+	assert.Equal(t, 5, resp.Data.MaxScore)
+	if assert.Len(t, resp.Data.Questions, 2) {
+		assert.Equal(t, 0, resp.Data.Questions[0].EarnedPoints)
+		assert.Equal(t, 3, resp.Data.Questions[1].EarnedPoints)
+		assert.True(t, resp.Data.Questions[1].Correct)
+	}
+
+	var attempts int64
+	db.Model(&models.QuizAttempt{}).Count(&attempts)
+	assert.Equal(t, int64(0), attempts)
+}
+
+func TestDeleteQuiz_SoftDeleteAndRestore(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 2}
+	db.Create(&quiz)
+	db.Create(&models.Question{QuizID: quiz.ID, Type: "true_false", Content: "Q", Answer: "true", Points: 1})
+	submitted := time.Now()
+	score := 1
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 1, StartedAt: submitted, Deadline: submitted, SubmittedAt: &submitted, Score: &score})
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+
+	do := func(token, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	listCount := func(token, query string) int {
+		w := do(token, http.MethodGet, "/api/v1/courses/1/quizzes"+query)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[[]json.RawMessage]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return len(resp.Data)
+	}
+
+	w := do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/restore")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// A teacher of another course cannot delete it.
+	w = do(loginAndGetToken(t, r, "teacher2", "pass123"), http.MethodDelete, "/api/v1/quizzes/1")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 1, listCount(aliceToken, ""))
+
+	w = do(teacherToken, http.MethodDelete, "/api/v1/quizzes/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, 0, listCount(aliceToken, ""))
+	assert.Equal(t, 0, listCount(teacherToken, ""))
+	assert.Equal(t, 1, listCount(teacherToken, "?include_deleted=true"))
+	w = do(aliceToken, http.MethodGet, "/api/v1/courses/1/quizzes?include_deleted=true")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/start")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var questions, attempts int64
+	db.Model(&models.Question{}).Where("quiz_id = ?", quiz.ID).Count(&questions)
+	db.Model(&models.QuizAttempt{}).Where("quiz_id = ?", quiz.ID).Count(&attempts)
+	assert.Equal(t, int64(1), questions)
+	assert.Equal(t, int64(1), attempts)
+
+	w = do(loginAndGetToken(t, r, "teacher2", "pass123"), http.MethodPost, "/api/v1/quizzes/1/restore")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/restore")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, listCount(aliceToken, ""))
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/start")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCloseQuiz_EndsEarlyWithGrace(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID})
+	end := time.Now().Add(24 * time.Hour)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1, EndTime: &end, ShowAnswerAfterEnd: true}
+	db.Create(&quiz)
+	db.Create(&models.Question{QuizID: quiz.ID, Type: "true_false", Content: "Q", Answer: "true", Points: 1})
+	carol := createCourseTestUser(t, db, "carol", "pass123", "student")
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: carol.ID})
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	aliceToken := loginAndGetToken(t, r, "alice", "pass123")
+	bobToken := loginAndGetToken(t, r, "bob", "pass123")
+	carolToken := loginAndGetToken(t, r, "carol", "pass123")
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/start", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(carolToken, http.MethodPost, "/api/v1/quizzes/1/start", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/close", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/close", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var closed envelope[models.Quiz]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &closed))
+	if assert.NotNil(t, closed.Data.EndTime) {
+		assert.WithinDuration(t, time.Now(), *closed.Data.EndTime, 5*time.Second)
+	}
+	w = do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/close", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(bobToken, http.MethodPost, "/api/v1/quizzes/1/start", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var attempt models.QuizAttempt
+	db.Where("student_id = ?", alice.ID).First(&attempt)
+	assert.WithinDuration(t, time.Now().Add(services.CloseGracePeriod), attempt.Deadline, 5*time.Second)
+
+	w = do(aliceToken, http.MethodPost, "/api/v1/quizzes/1/submit", `{"answers":{"1":"true"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Answers stay hidden until the last attempt in the grace period is in.
+	hasAnswers := func() bool {
+		w := do(aliceToken, http.MethodGet, "/api/v1/quizzes/1/result", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[map[string]json.RawMessage]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		_, ok := resp.Data["questions"]
+		return ok
+	}
+	assert.False(t, hasAnswers())
+	w = do(carolToken, http.MethodPost, "/api/v1/quizzes/1/submit", `{"answers":{"1":"false"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, hasAnswers())
+
+	// A quiz that has not opened yet cannot be closed.
+	start := time.Now().Add(time.Hour)
+	later := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Later", IsPublished: true, MaxAttempts: 1, StartTime: &start}
+	db.Create(&later)
+	w = do(teacherToken, http.MethodPost, fmt.Sprintf("/api/v1/quizzes/%d/close", later.ID), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	db.First(&later, later.ID)
+	assert.Nil(t, later.EndTime)
+}
+
+func TestListStudentAttempts_AcrossCourseQuizzes(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	otherCourse := models.Course{Name: "Other Course", TeacherID: teacher.ID}
+	db.Create(&otherCourse)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID, Role: "student"})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID, Role: "student"})
+
+	quiz1 := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz 1", IsPublished: true}
+	db.Create(&quiz1)
+	quiz2 := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz 2", IsPublished: true}
+	db.Create(&quiz2)
+	elsewhere := models.Quiz{CourseID: otherCourse.ID, CreatedByID: teacher.ID, Title: "Elsewhere", IsPublished: true}
+	db.Create(&elsewhere)
+
+	now := time.Now()
+	submitted := func(quizID uint, number, score int, at time.Time) {
+		db.Create(&models.QuizAttempt{QuizID: quizID, StudentID: alice.ID, AttemptNumber: number, StartedAt: at.Add(-time.Minute), Deadline: at.Add(time.Hour), SubmittedAt: &at, Score: &score, MaxScore: 10})
+	}
+	submitted(quiz1.ID, 1, 4, now.Add(-3*time.Hour))
+	submitted(quiz2.ID, 1, 9, now.Add(-2*time.Hour))
+	submitted(quiz1.ID, 2, 7, now.Add(-time.Hour))
+	submitted(elsewhere.ID, 1, 10, now.Add(-30*time.Minute))
+	db.Create(&models.QuizAttempt{QuizID: quiz2.ID, StudentID: alice.ID, AttemptNumber: 2, StartedAt: now, Deadline: now.Add(time.Hour), MaxScore: 10})
+
+	r := setupQuizRouter(db, "test-secret")
+	path := "/api/v1/courses/" + strconv.Itoa(int(course.ID)) + "/students/" + strconv.Itoa(int(alice.ID)) + "/quiz-attempts"
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, username := range []string{"teacher1", "alice"} {
+		w := do(loginAndGetToken(t, r, username, "pass123"))
+		assert.Equal(t, http.StatusOK, w.Code, username)
+		var resp envelope[struct {
+			Attempts []services.StudentQuizAttempt `json:"attempts"`
+		}]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		if assert.Len(t, resp.Data.Attempts, 3, username) {
+			assert.Equal(t, "Quiz 1", resp.Data.Attempts[0].QuizTitle)
+			assert.Equal(t, 2, resp.Data.Attempts[0].AttemptNumber)
+			assert.Equal(t, 7, *resp.Data.Attempts[0].Score)
+			assert.Equal(t, "Quiz 2", resp.Data.Attempts[1].QuizTitle)
+			assert.Equal(t, "Quiz 1", resp.Data.Attempts[2].QuizTitle)
+			assert.Equal(t, 1, resp.Data.Attempts[2].AttemptNumber)
+		}
+	}
+
+	w := do(loginAndGetToken(t, r, "bob", "pass123"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestSubmitQuiz_ConcurrentSubmitReturnsFirstResult(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1, TotalPoints: 10}
+	db.Create(&quiz)
+	question := models.Question{QuizID: quiz.ID, Content: "2+2?", Type: "single_choice", Options: `["3","4"]`, Answer: "4", Points: 10}
+	db.Create(&question)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	startReq := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/start", nil)
+	startReq.Header.Set("Authorization", "Bearer "+token)
+	startW := httptest.NewRecorder()
+	r.ServeHTTP(startW, startReq)
+	assert.Equal(t, http.StatusOK, startW.Code)
+
+	// Simulate a concurrent request that submits the attempt (with full
+	// marks) between this request's lookup and its write.
+	raced := false
+	err := db.Callback().Update().Before("gorm:update").Register("test:race_submit", func(tx *gorm.DB) {
+		if raced {
+			return
+		}
+		raced = true
+		score := 10
+		now := time.Now()
+		tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+			Model(&models.QuizAttempt{}).
+			Where("submitted_at IS NULL").
+			Updates(map[string]interface{}{"submitted_at": &now, "score": &score})
+	})
+	assert.NoError(t, err)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"answers": map[string]interface{}{strconv.FormatUint(uint64(question.ID), 10): "3"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/submit", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.True(t, raced)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details struct {
+				Score    int `json:"score"`
+				MaxScore int `json:"max_score"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ALREADY_SUBMITTED", resp.Error.Code)
+	assert.Equal(t, 10, resp.Error.Details.Score)
+	assert.Equal(t, 10, resp.Error.Details.MaxScore)
+
+	var attempt models.QuizAttempt
+	db.First(&attempt)
+	if assert.NotNil(t, attempt.Score) {
+		assert.Equal(t, 10, *attempt.Score)
+	}
+}
+
+func TestSubmitQuiz_RequireAllAnswered(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 3, RequireAllAnswered: true}
+	db.Create(&quiz)
+	q1 := models.Question{QuizID: quiz.ID, Content: "Q1", Type: "true_false", Answer: "true", Points: 1, OrderNum: 1}
+	q2 := models.Question{QuizID: quiz.ID, Content: "Q2", Type: "fill_blank", Answer: "E", Points: 1, OrderNum: 2}
+	db.Create(&q1)
+	db.Create(&q2)
+
+	now := time.Now()
+	attempt := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 1, StartedAt: now, Deadline: now.Add(10 * time.Minute)}
+	db.Create(&attempt)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+	submit := func(answers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/submit", strings.NewReader(`{"answers":`+answers+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	q1Key := strconv.FormatUint(uint64(q1.ID), 10)
+	q2Key := strconv.FormatUint(uint64(q2.ID), 10)
+
+	for _, answers := range []string{
+		`{"` + q1Key + `":"true"}`,
+		`{"` + q1Key + `":"true","` + q2Key + `":"  "}`,
+	} {
+		w := submit(answers)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var resp struct {
+			Error struct {
+				Code    string `json:"code"`
+				Details struct {
+					QuestionNumbers []int `json:"question_numbers"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "UNANSWERED_QUESTIONS", resp.Error.Code)
+		assert.Equal(t, []int{2}, resp.Error.Details.QuestionNumbers)
+	}
+
+	// Inside the grace window partial answers are accepted.
+	db.Model(&attempt).Update("deadline", time.Now().Add(-2*time.Second))
+	w := submit(`{"` + q1Key + `":"true"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	second := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 2, StartedAt: now, Deadline: now.Add(10 * time.Minute)}
+	db.Create(&second)
+	w = submit(`{"` + q1Key + `":"true","` + q2Key + `":"E"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestOrderingAndMatchingQuestions(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", MaxAttempts: 1}
+	db.Create(&quiz)
+
+	r := setupQuizRouter(db, "test-secret")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Answers must fit the options.
+	for _, body := range []string{
+		`{"type":"ordering","content":"Q","options":["A","B","C"],"answer":"[\"A\",\"B\"]"}`,
+		`{"type":"ordering","content":"Q","options":["A","B","C"],"answer":"[\"A\",\"B\",\"D\"]"}`,
+		`{"type":"matching","content":"Q","options":["x","y","1"],"answer":"{\"x\":\"1\"}"}`,
+		`{"type":"matching","content":"Q","options":["x","y","1","2"],"answer":"{\"x\":\"1\",\"y\":\"1\"}"}`,
+	} {
+		w := do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/questions", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), "answer does not match options")
+	}
+
+	w := do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/questions",
+		`{"type":"ordering","content":"Order","options":["A","B","C","D"],"answer":"[\"B\",\"A\",\"C\",\"D\"]","points":4,"order_num":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = do(teacherToken, http.MethodPost, "/api/v1/quizzes/1/questions",
+		`{"type":"matching","content":"Match","options":["∇·E","∇×E","Gauss","Faraday"],"answer":"{\"∇·E\":\"Gauss\",\"∇×E\":\"Faraday\"}","points":6,"order_num":2}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Changing options must keep the stored answer valid.
+	w = do(teacherToken, http.MethodPut, "/api/v1/questions/1", `{"options":["A","B","C","E"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	db.Model(&quiz).Update("is_published", true)
+	studentToken := loginAndGetToken(t, r, "student1", "pass123")
+	w = do(studentToken, http.MethodPost, "/api/v1/quizzes/1/start", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Two of four items in place (2 of 4 points), one of two pairs (3 of 6).
+	w = do(studentToken, http.MethodPost, "/api/v1/quizzes/1/submit",
+		`{"answers":{"1":["B","C","A","D"],"2":{"∇·E":"Gauss","∇×E":"Gauss"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[map[string]interface{}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(5), resp.Data["score"])
+}
+
+func TestRegradeQuiz_CorrectsAnswerAndRescores(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 3}
+	db.Create(&quiz)
+	q1 := models.Question{QuizID: quiz.ID, Content: "Q1", Type: "single_choice", Options: `["A","B"]`, Answer: "A", Points: 2, OrderNum: 1}
+	q2 := models.Question{QuizID: quiz.ID, Content: "Q2", Type: "true_false", Answer: "true", Points: 1, OrderNum: 2}
+	db.Create(&q1)
+	db.Create(&q2)
+
+	q1Key := strconv.FormatUint(uint64(q1.ID), 10)
+	q2Key := strconv.FormatUint(uint64(q2.ID), 10)
+	now := time.Now()
+	scoreA, scoreB := 3, 1
+	answered := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 1, StartedAt: now, Deadline: now,
+		SubmittedAt: &now, Score: &scoreA, MaxScore: 3, Answers: `{"` + q1Key + `":"A","` + q2Key + `":"true"}`}
+	other := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 2, StartedAt: now, Deadline: now,
+		SubmittedAt: &now, Score: &scoreB, MaxScore: 3, Answers: `{"` + q1Key + `":"B","` + q2Key + `":"true"}`}
+	inProgress := models.QuizAttempt{QuizID: quiz.ID, StudentID: student.ID, AttemptNumber: 3, StartedAt: now, Deadline: now.Add(time.Hour)}
+	db.Create(&answered)
+	db.Create(&other)
+	db.Create(&inProgress)
+
+	r := setupQuizRouter(db, "test-secret")
+	regrade := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/quizzes/1/regrade", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	otherToken := loginAndGetToken(t, r, "teacher2", "pass123")
+	w := regrade(otherToken, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	w = regrade(token, `{"corrections":[{"question_id":999,"answer":"B"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Nothing changed yet, so a plain regrade leaves every score alone.
+	w = regrade(token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.RegradeResult]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Data.AttemptsChecked)
+	assert.Equal(t, 0, resp.Data.AttemptsChanged)
+
+	w = regrade(token, `{"corrections":[{"question_id":`+q1Key+`,"answer":"B"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Data.QuestionsCorrected)
+	assert.Equal(t, 2, resp.Data.AttemptsChecked)
+	assert.Equal(t, 2, resp.Data.AttemptsChanged)
+
+	var stored models.Question
+	db.First(&stored, q1.ID)
+	assert.Equal(t, "B", stored.Answer)
+	db.First(&answered, answered.ID)
+	db.First(&other, other.ID)
+	db.First(&inProgress, inProgress.ID)
+	assert.Equal(t, 1, *answered.Score)
+	assert.Equal(t, 3, *other.Score)
+	assert.Nil(t, inProgress.Score)
+}
+
+func TestExportQuizResults_CSV(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+	bob := createCourseTestUser(t, db, "bob", "pass123", "student")
+	db.Model(&alice).Update("name", "=Alice")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: alice.ID})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: bob.ID})
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "电磁场 Quiz 1", IsPublished: true, MaxAttempts: 3}
+	db.Create(&quiz)
+	db.Create(&models.Question{QuizID: quiz.ID, Content: "Q1", Type: "true_false", Answer: "true", Points: 5, OrderNum: 1})
+
+	start := time.Now().Add(-time.Hour)
+	firstEnd, secondEnd := start.Add(90*time.Second), start.Add(10*time.Minute)
+	high, low := 5, 2
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 1, StartedAt: start, Deadline: start.Add(time.Hour), SubmittedAt: &firstEnd, Score: &high, MaxScore: 5})
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 2, StartedAt: start.Add(9 * time.Minute), Deadline: start.Add(time.Hour), SubmittedAt: &secondEnd, Score: &low, MaxScore: 5})
+	db.Create(&models.QuizAttempt{QuizID: quiz.ID, StudentID: alice.ID, AttemptNumber: 3, StartedAt: time.Now(), Deadline: time.Now().Add(time.Hour)})
+
+	r := setupQuizRouter(db, "test-secret")
+	get := func(token, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	studentToken := loginAndGetToken(t, r, "alice", "pass123")
+	assert.Equal(t, http.StatusForbidden, get(studentToken, "/api/v1/quizzes/1/results.csv").Code)
+
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	w := get(token, "/api/v1/quizzes/1/results.csv")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "filename*=UTF-8''"+url.PathEscape("电磁场_Quiz_1-results.csv"))
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\uFEFF"))).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"student_id", "username", "name", "attempts", "best_score", "latest_score", "max_score", "time_spent_seconds", "last_submitted_at"},
+		{strconv.FormatUint(uint64(alice.ID), 10), "alice", "'=Alice", "2", "5", "2", "5", "150", secondEnd.Format(time.RFC3339)},
+		{strconv.FormatUint(uint64(bob.ID), 10), "bob", "Test bob", "0", "", "", "5", "", ""},
+	}, records)
+
+	w = get(token, "/api/v1/quizzes/1/results")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.QuizResults]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data.Students, 2)
+	assert.Nil(t, resp.Data.Students[1].BestScore)
+}
+
+func TestGetQuiz_ViewAsStudent(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	other := createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&models.Course{Name: "Other Course", TeacherID: other.ID})
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	quiz := models.Quiz{CourseID: course.ID, CreatedByID: teacher.ID, Title: "Quiz", IsPublished: true, MaxAttempts: 1, TotalPoints: 5}
+	db.Create(&quiz)
+	db.Create(&models.Question{QuizID: quiz.ID, Content: "Unit of E?", Type: "fill_blank", Answer: "V/m", Points: 5})
+
+	tokens := auth.TokenConfig{Secret: "test-secret", Courses: services.NewCourseService(db, nil)}
+	hQuiz := newQuizHandlers(db)
+	r := gin.New()
+	api := r.Group("/api/v1", middleware.AuthRequired(tokens))
+	api.GET("/quizzes/:id", hQuiz.GetQuiz)
+	api.POST("/quizzes/:id/start", hQuiz.StartQuiz)
+
+	do := func(method, path string, user models.User, viewAs bool, courseID uint) *httptest.ResponseRecorder {
+		token, err := auth.SignToken(tokens, user.ID, user.Username, user.Role, time.Hour)
+		assert.NoError(t, err)
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if viewAs {
+			req.Header.Set(middleware.ViewAsHeader, "student")
+			req.Header.Set(middleware.ViewAsCourseHeader, strconv.FormatUint(uint64(courseID), 10))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/v1/quizzes/1", teacher, false, 0)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "V/m")
+
+	w = do(http.MethodGet, "/api/v1/quizzes/1", teacher, true, course.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Unit of E?")
+	assert.NotContains(t, w.Body.String(), "V/m")
+
+	// Previews never change data.
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/quizzes/1/start", teacher, true, course.ID).Code)
+	var attempts int64
+	db.Model(&models.QuizAttempt{}).Count(&attempts)
+	assert.Equal(t, int64(0), attempts)
+
+	// Only courses the caller manages can be previewed, and only by staff.
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/v1/quizzes/1", other, true, course.ID).Code)
+	w = do(http.MethodGet, "/api/v1/quizzes/1", other, true, 2)
+	assert.Equal(t, http.StatusForbidden, w.Code, "previewing another course gives no access to this one")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/v1/quizzes/1", student, true, course.ID).Code)
+}
+
+func TestListMyQuizzes_AcrossCoursesByAvailability(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	alice := createCourseTestUser(t, db, "alice", "pass123", "student")
+
+	fields := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	waves := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	other := models.Course{Name: "Other", TeacherID: teacher.ID}
+	db.Create(&fields)
+	db.Create(&waves)
+	db.Create(&other)
+	db.Create(&models.CourseEnrollment{CourseID: fields.ID, UserID: alice.ID})
+	db.Create(&models.CourseEnrollment{CourseID: waves.ID, UserID: alice.ID})
+
+	now := time.Now()
+	at := func(d time.Duration) *time.Time { t := now.Add(d); return &t }
+	for _, q := range []models.Quiz{
+		{CourseID: fields.ID, Title: "open late", EndTime: at(48 * time.Hour)},
+		{CourseID: waves.ID, Title: "open soon", EndTime: at(time.Hour)},
+		{CourseID: fields.ID, Title: "no deadline"},
+		{CourseID: waves.ID, Title: "next week", StartTime: at(7 * 24 * time.Hour)},
+		{CourseID: fields.ID, Title: "closed", StartTime: at(-48 * time.Hour), EndTime: at(-time.Hour)},
+		{CourseID: fields.ID, Title: "draft", IsPublished: false},
+		{CourseID: other.ID, Title: "not enrolled"},
+	} {
+		q.CreatedByID, q.MaxAttempts = teacher.ID, 2
+		q.IsPublished = q.Title != "draft"
+		db.Create(&q)
+	}
+	for _, score := range []int{3, 7} {
+		db.Create(&models.QuizAttempt{QuizID: 5, StudentID: alice.ID, StartedAt: now, Deadline: now, SubmittedAt: &now, Score: &score})
+	}
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "alice", "pass123")
+	list := func(query string) (int, services.StudentQuizPage) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/me/quizzes?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp envelope[services.StudentQuizPage]
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+	titles := func(p services.StudentQuizPage) []string {
+		var out []string
+		for _, q := range p.Items {
+			out = append(out, q.Title)
+		}
+		return out
+	}
+
+	code, page := list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, page.Total)
+	assert.Equal(t, []string{"open soon", "open late", "no deadline", "next week", "closed"}, titles(page))
+	assert.Equal(t, "Waves", page.Items[0].CourseName)
+	assert.Equal(t, services.QuizAvailable, page.Items[0].Status)
+	closed := page.Items[4]
+	assert.Equal(t, services.QuizEnded, closed.Status)
+	assert.Equal(t, 2, closed.AttemptCount)
+	if assert.NotNil(t, closed.BestScore) {
+		assert.Equal(t, 7, *closed.BestScore)
+	}
+
+	_, page = list("status=available&page=2&page_size=2")
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, []string{"no deadline"}, titles(page))
+	_, page = list("status=upcoming")
+	assert.Equal(t, []string{"next week"}, titles(page))
+
+	code, _ = list("status=soon")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestQuizChapter_MustBelongToCourse(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	course := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	other := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&other)
+	chapter := models.Chapter{CourseID: course.ID, Title: "Statics", OrderNum: 1}
+	foreign := models.Chapter{CourseID: other.ID, Title: "Optics", OrderNum: 1}
+	db.Create(&chapter)
+	db.Create(&foreign)
+
+	r := setupQuizRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "teacher1", "pass123")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	errorCode := func(w *httptest.ResponseRecorder) string {
+		var resp envelope[any]
+		if json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Error == nil {
+			return ""
+		}
+		return resp.Error.Code
+	}
+
+	w := do(http.MethodPost, "/api/v1/quizzes", fmt.Sprintf(`{"course_id":%d,"title":"Q","chapter_id":%d}`, course.ID, foreign.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "INVALID_CHAPTER", errorCode(w))
+	w = do(http.MethodPost, "/api/v1/quizzes", fmt.Sprintf(`{"course_id":%d,"title":"Q","chapter_id":9999}`, course.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "INVALID_CHAPTER", errorCode(w))
+
+	w = do(http.MethodPost, "/api/v1/quizzes", fmt.Sprintf(`{"course_id":%d,"title":"Q","chapter_id":%d}`, course.ID, chapter.ID))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created envelope[models.Quiz]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	if assert.NotNil(t, created.Data.ChapterID) {
+		assert.Equal(t, chapter.ID, *created.Data.ChapterID)
+	}
+
+	path := fmt.Sprintf("/api/v1/quizzes/%d", created.Data.ID)
+	w = do(http.MethodPut, path, fmt.Sprintf(`{"chapter_id":%d}`, foreign.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "INVALID_CHAPTER", errorCode(w))
+
+	w = do(http.MethodPut, path, `{"chapter_id":0}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPut, path, `{"title":"Renamed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stored models.Quiz
+	db.First(&stored, created.Data.ID)
+	assert.NotNil(t, stored.ChapterID)
+
+	w = do(http.MethodPut, path, `{"chapter_id":null}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored = models.Quiz{}
+	db.First(&stored, created.Data.ID)
+	assert.Nil(t, stored.ChapterID)
+}
+
+func TestMoveQuizToChapter(t *testing.T) {
+	db := setupQuizTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	course := models.Course{Name: "Fields", TeacherID: teacher.ID}
+	other := models.Course{Name: "Waves", TeacherID: teacher.ID}
+	db.Create(&course)
+	db.Create(&other)
+	first := models.Chapter{CourseID: course.ID, Title: "Statics", OrderNum: 1}
+	second := models.Chapter{CourseID: course.ID, Title: "Dynamics", OrderNum: 2}
+	foreign := models.Chapter{CourseID: other.ID, Title: "Optics", OrderNum: 1}
+	for _, ch := range []*models.Chapter{&first, &second, &foreign} {
+		db.Create(ch)
+	}
+	quiz := models.Quiz{CourseID: course.ID, ChapterID: &first.ID, CreatedByID: teacher.ID, Title: "quiz", MaxAttempts: 1}
+	db.Create(&quiz)
+
+	r := setupQuizRouter(db, "test-secret")
+	path := fmt.Sprintf("/api/v1/quizzes/%d/chapter", quiz.ID)
+	move := func(username, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+loginAndGetToken(t, r, username, "pass123"))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	storedChapter := func() *uint {
+		var stored models.Quiz
+		db.First(&stored, quiz.ID)
+		return stored.ChapterID
+	}
+
+	w := move("teacher1", fmt.Sprintf(`{"chapter_id":%d}`, second.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[models.Quiz]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.NotNil(t, resp.Data.ChapterID) {
+		assert.Equal(t, second.ID, *resp.Data.ChapterID)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, move("teacher1", fmt.Sprintf(`{"chapter_id":%d}`, foreign.ID)).Code)
+	assert.Equal(t, http.StatusBadRequest, move("teacher1", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, move("teacher1", `{"chapter_id":"x"}`).Code)
+	assert.Equal(t, http.StatusForbidden, move("teacher2", fmt.Sprintf(`{"chapter_id":%d}`, first.ID)).Code)
+	if assert.NotNil(t, storedChapter()) {
+		assert.Equal(t, second.ID, *storedChapter())
+	}
+
+	w = move("teacher1", `{"chapter_id":null}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, storedChapter())
 }
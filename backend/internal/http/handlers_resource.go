@@ -8,15 +8,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"gorm.io/gorm"
 )
 
 type resourceHandlers struct {
-	db *gorm.DB
+	db       *gorm.DB
+	chapters *services.ChapterService
 }
 
 func newResourceHandlers(db *gorm.DB) *resourceHandlers {
-	return &resourceHandlers{db: db}
+	return &resourceHandlers{db: db, chapters: services.NewChapterService(db)}
 }
 
 // --- Resource CRUD ---
@@ -51,7 +53,7 @@ func (h *resourceHandlers) CreateResource(c *gin.Context) {
 
 	// Validate user is teacher of the course
 	var course models.Course
-	if err := h.db.First(&course, req.CourseID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&course, req.CourseID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
 		return
 	}
@@ -76,7 +78,7 @@ func (h *resourceHandlers) CreateResource(c *gin.Context) {
 		Description: req.Description,
 	}
 
-	if err := h.db.Create(&resource).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&resource).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create resource", nil)
 		return
 	}
@@ -95,11 +97,23 @@ func (h *resourceHandlers) ListResources(c *gin.Context) {
 	// Optional type filter
 	typeFilter := c.Query("type")
 
-	query := h.db.Where("course_id = ?", courseID)
+	query := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID)
 	if typeFilter != "" {
 		query = query.Where("type = ?", typeFilter)
 	}
 
+	// Hide resources of chapters still gated behind an incomplete prerequisite
+	if u, ok := middleware.GetUser(c); ok {
+		locked, err := h.chapters.LockedChapterIDs(c.Request.Context(), uint(courseID), services.UserInfo{ID: u.ID, Role: u.Role})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list resources", nil)
+			return
+		}
+		if len(locked) > 0 {
+			query = query.Where("chapter_id IS NULL OR chapter_id NOT IN ?", locked)
+		}
+	}
+
 	var resources []models.Resource
 	if err := query.Order("created_at DESC").Find(&resources).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list resources", nil)
@@ -124,7 +138,7 @@ func (h *resourceHandlers) DeleteResource(c *gin.Context) {
 	}
 
 	var resource models.Resource
-	if err := h.db.First(&resource, id).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&resource, id).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "resource not found", nil)
 		return
 	}
@@ -135,7 +149,7 @@ func (h *resourceHandlers) DeleteResource(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&resource).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Delete(&resource).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to delete resource", nil)
 		return
 	}
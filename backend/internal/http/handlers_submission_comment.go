@@ -0,0 +1,77 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+type submissionCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// ListSubmissionComments returns the discussion on a submission, oldest first
+// GET /submissions/:submissionId/comments
+func (h *assignmentHandlers) ListSubmissionComments(c *gin.Context) {
+	submissionID, err := strconv.ParseUint(c.Param("submissionId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid submission id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	comments, err := h.service.ListSubmissionComments(c.Request.Context(), uint(submissionID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		respondCommentError(c, err, "failed to list comments")
+		return
+	}
+	respondOK(c, comments)
+}
+
+// AddSubmissionComment posts a comment on a submission and notifies the other side
+// POST /submissions/:submissionId/comments
+func (h *assignmentHandlers) AddSubmissionComment(c *gin.Context) {
+	submissionID, err := strconv.ParseUint(c.Param("submissionId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid submission id", nil)
+		return
+	}
+
+	var req submissionCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	comment, err := h.service.AddSubmissionComment(c.Request.Context(), uint(submissionID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, req.Body)
+	if err != nil {
+		respondCommentError(c, err, "failed to add comment")
+		return
+	}
+	respondCreated(c, comment)
+}
+
+func respondCommentError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidComment):
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", fmt.Sprintf("comment must be 1-%d characters", services.MaxCommentRunes), nil)
+	case errors.Is(err, services.ErrSubmissionNotFound):
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "submission not found", nil)
+	case errors.Is(err, services.ErrAccessDenied):
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "only the student and course staff can see this discussion", nil)
+	default:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", fallback, nil)
+	}
+}
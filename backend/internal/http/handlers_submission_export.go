@@ -0,0 +1,155 @@
+package http
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/csvutil"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+// submissionFileStore opens uploaded submission files. *clients.MinioClient
+// implements it.
+type submissionFileStore interface {
+	ObjectKeyFromURL(fileURL string) (string, bool)
+	OpenObject(ctx context.Context, objectKey string) (io.ReadCloser, error)
+}
+
+// Manifest statuses of DownloadSubmissionsZip.
+const (
+	zipStatusIncluded = "included" // file is in the archive
+	zipStatusNoFile   = "no_file"  // text-only submission
+	zipStatusMissing  = "missing"  // file URL is not ours or the object is gone
+	zipStatusFailed   = "failed"   // storage error while reading the file
+)
+
+// DownloadSubmissionsZip streams every submission file of an assignment as a
+// zip named by student username, plus a manifest.csv listing each submission
+// and whether its file was included
+// GET /assignments/:id/submissions.zip
+func (h *assignmentHandlers) DownloadSubmissionsZip(c *gin.Context) {
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid assignment id", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	assignment, files, err := h.service.ListSubmissionFiles(c.Request.Context(), uint(assignmentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAssignmentNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "you are not the course teacher", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list submissions", nil)
+		}
+		return
+	}
+	if h.files == nil {
+		respondError(c, http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE", "file storage is not configured", nil)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="assignment-%d-submissions.zip"`, assignment.ID))
+	c.Status(http.StatusOK)
+
+	// Files are copied straight from storage into the response, one at a
+	// time, so memory use does not grow with the archive.
+	ctx := c.Request.Context()
+	zw := zip.NewWriter(c.Writer)
+	statuses := make([]string, len(files))
+	names := make([]string, len(files))
+	for i, f := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		names[i], statuses[i] = h.addSubmissionFile(ctx, zw, f)
+	}
+
+	manifest, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.csv", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return
+	}
+	w := csvutil.NewWriter(manifest)
+	_ = w.Write([]string{"submission_id", "student_id", "username", "file", "status", "submitted_at"})
+	for i, f := range files {
+		_ = w.Write([]string{
+			strconv.FormatUint(uint64(f.SubmissionID), 10),
+			strconv.FormatUint(uint64(f.StudentID), 10),
+			csvutil.Safe(f.Username),
+			csvutil.Safe(names[i]),
+			statuses[i],
+			f.SubmittedAt.Format(time.RFC3339),
+		})
+	}
+	_ = w.Flush()
+	_ = zw.Close()
+}
+
+// addSubmissionFile copies one submission's file into the archive and returns
+// the entry name and manifest status.
+func (h *assignmentHandlers) addSubmissionFile(ctx context.Context, zw *zip.Writer, f services.SubmissionFile) (string, string) {
+	if f.FileURL == "" {
+		return "", zipStatusNoFile
+	}
+	key, ok := h.files.ObjectKeyFromURL(f.FileURL)
+	if !ok {
+		return "", zipStatusMissing
+	}
+	obj, err := h.files.OpenObject(ctx, key)
+	if err != nil {
+		if errors.Is(err, clients.ErrObjectNotFound) {
+			return "", zipStatusMissing
+		}
+		logger.Log.Warn("submission file unavailable", slog.Uint64("submission_id", uint64(f.SubmissionID)), slog.Any("error", err))
+		return "", zipStatusFailed
+	}
+	defer obj.Close()
+
+	name := submissionZipName(f, key)
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: f.SubmittedAt})
+	if err != nil {
+		return name, zipStatusFailed
+	}
+	if _, err := io.Copy(entry, obj); err != nil {
+		logger.Log.Warn("submission file copy failed", slog.Uint64("submission_id", uint64(f.SubmissionID)), slog.Any("error", err))
+		return name, zipStatusFailed
+	}
+	return name, zipStatusIncluded
+}
+
+// submissionZipName names an entry "<username><ext>", falling back to the
+// submission id when the student no longer exists. Path separators are
+// replaced so every entry stays at the top of the archive.
+func submissionZipName(f services.SubmissionFile, objectKey string) string {
+	base := f.Username
+	if base == "" {
+		base = "submission-" + strconv.FormatUint(uint64(f.SubmissionID), 10)
+	}
+	base = strings.NewReplacer("/", "_", "\\", "_").Replace(base)
+	if base == "." || base == ".." {
+		base = "_"
+	}
+	return base + strings.ToLower(path.Ext(objectKey))
+}
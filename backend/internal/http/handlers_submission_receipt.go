@@ -0,0 +1,71 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+)
+
+// submitResponse is a saved submission with the receipt proving when the
+// server received it.
+type submitResponse struct {
+	*models.Submission
+	Receipt *services.SubmissionReceipt `json:"receipt,omitempty"`
+}
+
+type verifyReceiptQuery struct {
+	AssignmentID uint      `form:"assignment_id" binding:"required"`
+	StudentID    uint      `form:"student_id" binding:"required"`
+	SubmittedAt  time.Time `form:"submitted_at" binding:"required"`
+	ContentHash  string    `form:"content_hash" binding:"required"`
+	Signature    string    `form:"signature" binding:"required"`
+}
+
+// VerifyReceipt checks a submission receipt presented as query parameters,
+// and whether the submission was on time and is unchanged since
+// GET /submissions/:submissionId/verify-receipt
+func (h *assignmentHandlers) VerifyReceipt(c *gin.Context) {
+	submissionID, err := strconv.ParseUint(c.Param("submissionId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid submission id", nil)
+		return
+	}
+
+	var q verifyReceiptQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		respondBindError(c, "BAD_REQUEST", err)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	result, err := h.service.VerifyReceipt(c.Request.Context(), uint(submissionID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, services.SubmissionReceipt{
+		AssignmentID: q.AssignmentID,
+		StudentID:    q.StudentID,
+		SubmittedAt:  q.SubmittedAt,
+		ContentHash:  q.ContentHash,
+		Signature:    q.Signature,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReceiptsDisabled):
+			respondError(c, http.StatusServiceUnavailable, "RECEIPTS_UNAVAILABLE", "submission receipts are not configured", nil)
+		case errors.Is(err, services.ErrSubmissionNotFound):
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "submission not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "only the student and course staff can verify this receipt", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to verify receipt", nil)
+		}
+		return
+	}
+	respondOK(c, result)
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"gorm.io/gorm"
+)
+
+type upcomingHandlers struct {
+	service *services.UpcomingService
+}
+
+func newUpcomingHandlers(db *gorm.DB) *upcomingHandlers {
+	return &upcomingHandlers{service: services.NewUpcomingService(db)}
+}
+
+// ListUpcoming returns quizzes and assignments due soon across the user's courses
+// GET /me/upcoming?days=7
+func (h *upcomingHandlers) ListUpcoming(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized", nil)
+		return
+	}
+
+	var horizon time.Duration
+	if raw := c.Query("days"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days <= 0 {
+			respondError(c, http.StatusBadRequest, "BAD_REQUEST", "days must be a positive integer", nil)
+			return
+		}
+		horizon = time.Duration(days) * 24 * time.Hour
+	}
+
+	items, err := h.service.ListUpcoming(c.Request.Context(), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, horizon)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to list upcoming work", nil)
+		return
+	}
+	respondOK(c, items)
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupUpcomingTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Course{},
+		&models.CourseEnrollment{},
+		&models.Quiz{},
+		&models.QuizAttempt{},
+		&models.QuizAttemptGrant{},
+		&models.Assignment{},
+		&models.Submission{},
+		&models.StudentGroupMember{},
+	)
+	assert.NoError(t, err)
+
+	return db
+}
+
+func setupUpcomingRouter(db *gorm.DB, jwtSecret string) *gin.Engine {
+	hUpcoming := newUpcomingHandlers(db)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
+	{
+		api.GET("/me/upcoming", hUpcoming.ListUpcoming)
+	}
+
+	return r
+}
+
+func TestListUpcoming(t *testing.T) {
+	db := setupUpcomingTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	course := models.Course{Name: "Enrolled", TeacherID: teacher.ID}
+	db.Create(&course)
+	other := models.Course{Name: "Other", TeacherID: teacher.ID}
+	db.Create(&other)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	at := func(d time.Duration) *time.Time {
+		v := time.Now().Add(d)
+		return &v
+	}
+	db.Create(&models.Quiz{CourseID: course.ID, Title: "Open quiz", EndTime: at(48 * time.Hour), MaxAttempts: 2, IsPublished: true})
+	done := models.Quiz{CourseID: course.ID, Title: "Done quiz", EndTime: at(24 * time.Hour), MaxAttempts: 1, IsPublished: true}
+	db.Create(&done)
+	db.Create(&models.QuizAttempt{QuizID: done.ID, StudentID: student.ID, SubmittedAt: at(-time.Hour)})
+	db.Create(&models.Quiz{CourseID: course.ID, Title: "Draft quiz", EndTime: at(24 * time.Hour), MaxAttempts: 1})
+	db.Create(&models.Quiz{CourseID: course.ID, Title: "Far quiz", EndTime: at(30 * 24 * time.Hour), MaxAttempts: 1, IsPublished: true})
+	db.Create(&models.Quiz{CourseID: other.ID, Title: "Other quiz", EndTime: at(24 * time.Hour), MaxAttempts: 1, IsPublished: true})
+
+	db.Create(&models.Assignment{CourseID: course.ID, Title: "Open HW", Deadline: at(12 * time.Hour), IsPublished: true})
+	submitted := models.Assignment{CourseID: course.ID, Title: "Submitted HW", Deadline: at(12 * time.Hour), IsPublished: true}
+	db.Create(&submitted)
+	db.Create(&models.Submission{AssignmentID: submitted.ID, StudentID: student.ID, Content: "x"})
+	db.Create(&models.Assignment{CourseID: course.ID, Title: "Past HW", Deadline: at(-time.Hour), IsPublished: true})
+
+	r := setupUpcomingRouter(db, "test-secret")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	get := func(path string) []services.UpcomingItem {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp envelope[[]services.UpcomingItem]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	items := get("/api/v1/me/upcoming")
+	titles := make([]string, len(items))
+	for i, item := range items {
+		titles[i] = item.Title
+	}
+	assert.Equal(t, []string{"Open HW", "Open quiz"}, titles)
+	assert.Equal(t, "Enrolled", items[0].CourseName)
+
+	assert.Len(t, get("/api/v1/me/upcoming?days=60"), 3)
+}
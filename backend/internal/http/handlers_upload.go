@@ -62,7 +62,7 @@ func (h *uploadHandlers) UploadAssignmentFile(c *gin.Context) {
 
 	// Verify assignment exists and user can submit
 	var assignment models.Assignment
-	if err := h.db.First(&assignment, assignmentID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&assignment, assignmentID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "assignment not found", nil)
 		return
 	}
@@ -105,7 +105,7 @@ func (h *uploadHandlers) UploadResourceFile(c *gin.Context) {
 
 	// Verify course exists
 	var course models.Course
-	if err := h.db.First(&course, courseID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&course, courseID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "NOT_FOUND", "course not found", nil)
 		return
 	}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"net/http"
 	"sort"
 	"time"
@@ -69,17 +70,17 @@ func (h *userHandlers) GetStats(c *gin.Context) {
 
 	switch u.Role {
 	case "student":
-		stats := h.getStudentStats(u.ID)
+		stats := h.getStudentStats(c.Request.Context(), u.ID)
 		respondOK(c, stats)
 	case "teacher", "admin", "assistant":
-		stats := h.getTeacherStats(u.ID, u.Role)
+		stats := h.getTeacherStats(c.Request.Context(), u.ID, u.Role)
 		respondOK(c, stats)
 	default:
 		respondOK(c, gin.H{})
 	}
 }
 
-func (h *userHandlers) getStudentStats(userID uint) StudentStats {
+func (h *userHandlers) getStudentStats(ctx context.Context, userID uint) StudentStats {
 	stats := StudentStats{
 		Pending:        []PendingItem{},
 		RecentActivity: []Activity{},
@@ -87,17 +88,17 @@ func (h *userHandlers) getStudentStats(userID uint) StudentStats {
 
 	// Count courses (all courses for now, since no enrollment table)
 	var coursesCount int64
-	h.db.Model(&models.Course{}).Count(&coursesCount)
+	h.db.WithContext(ctx).Model(&models.Course{}).Count(&coursesCount)
 	stats.CoursesCount = int(coursesCount)
 
 	// Count assignments
 	var assignmentsTotal int64
-	h.db.Model(&models.Assignment{}).Count(&assignmentsTotal)
+	h.db.WithContext(ctx).Model(&models.Assignment{}).Count(&assignmentsTotal)
 	stats.AssignmentsTotal = int(assignmentsTotal)
 
 	// Count submitted assignments
 	var assignmentsSubmitted int64
-	h.db.Model(&models.Submission{}).
+	h.db.WithContext(ctx).Model(&models.Submission{}).
 		Where("student_id = ?", userID).
 		Distinct("assignment_id").
 		Count(&assignmentsSubmitted)
@@ -105,7 +106,7 @@ func (h *userHandlers) getStudentStats(userID uint) StudentStats {
 
 	// Quiz statistics
 	var quizAttempts []models.QuizAttempt
-	h.db.Where("student_id = ? AND submitted_at IS NOT NULL", userID).Find(&quizAttempts)
+	h.db.WithContext(ctx).Where("student_id = ? AND submitted_at IS NOT NULL", userID).Find(&quizAttempts)
 
 	stats.QuizzesTaken = len(quizAttempts)
 	if stats.QuizzesTaken > 0 {
@@ -120,10 +121,10 @@ func (h *userHandlers) getStudentStats(userID uint) StudentStats {
 
 	// Pending assignments (not submitted, deadline in future)
 	var assignments []models.Assignment
-	h.db.Where("deadline > ?", time.Now()).Find(&assignments)
+	h.db.WithContext(ctx).Where("deadline > ?", time.Now()).Find(&assignments)
 
 	var submittedAssignmentIDs []uint
-	h.db.Model(&models.Submission{}).
+	h.db.WithContext(ctx).Model(&models.Submission{}).
 		Where("student_id = ?", userID).
 		Pluck("assignment_id", &submittedAssignmentIDs)
 
@@ -146,11 +147,11 @@ func (h *userHandlers) getStudentStats(userID uint) StudentStats {
 
 	// Pending quizzes (published, not ended, not submitted or can retry)
 	var quizzes []models.Quiz
-	h.db.Where("is_published = ? AND end_time > ?", true, time.Now()).Find(&quizzes)
+	h.db.WithContext(ctx).Where("is_published = ? AND end_time > ?", true, time.Now()).Find(&quizzes)
 
 	for _, q := range quizzes {
 		var attemptCount int64
-		h.db.Model(&models.QuizAttempt{}).
+		h.db.WithContext(ctx).Model(&models.QuizAttempt{}).
 			Where("quiz_id = ? AND student_id = ? AND submitted_at IS NOT NULL", q.ID, userID).
 			Count(&attemptCount)
 
@@ -177,14 +178,14 @@ func (h *userHandlers) getStudentStats(userID uint) StudentStats {
 
 	// Recent activity - assignment submissions
 	var submissions []models.Submission
-	h.db.Where("student_id = ?", userID).
+	h.db.WithContext(ctx).Where("student_id = ?", userID).
 		Order("created_at DESC").
 		Limit(10).
 		Find(&submissions)
 
 	for _, s := range submissions {
 		var assignment models.Assignment
-		if h.db.First(&assignment, s.AssignmentID).Error == nil {
+		if h.db.WithContext(ctx).First(&assignment, s.AssignmentID).Error == nil {
 			var score, maxScore float64
 			if s.Grade != nil {
 				score = float64(*s.Grade)
@@ -204,7 +205,7 @@ func (h *userHandlers) getStudentStats(userID uint) StudentStats {
 	// Recent activity - quiz attempts
 	for _, a := range quizAttempts {
 		var quiz models.Quiz
-		if h.db.First(&quiz, a.QuizID).Error == nil && a.SubmittedAt != nil {
+		if h.db.WithContext(ctx).First(&quiz, a.QuizID).Error == nil && a.SubmittedAt != nil {
 			var score, maxScore float64
 			if a.Score != nil {
 				score = float64(*a.Score)
@@ -232,14 +233,14 @@ func (h *userHandlers) getStudentStats(userID uint) StudentStats {
 	return stats
 }
 
-func (h *userHandlers) getTeacherStats(userID uint, role string) TeacherStats {
+func (h *userHandlers) getTeacherStats(ctx context.Context, userID uint, role string) TeacherStats {
 	stats := TeacherStats{
 		RecentSubmissions: []Activity{},
 	}
 
 	// For admin, count all; for teacher, count own courses
 	var coursesCount int64
-	courseQuery := h.db.Model(&models.Course{})
+	courseQuery := h.db.WithContext(ctx).Model(&models.Course{})
 	if role == "teacher" {
 		courseQuery = courseQuery.Where("teacher_id = ?", userID)
 	}
@@ -249,29 +250,29 @@ func (h *userHandlers) getTeacherStats(userID uint, role string) TeacherStats {
 	// Get course IDs for this teacher
 	var courseIDs []uint
 	if role == "teacher" {
-		h.db.Model(&models.Course{}).Where("teacher_id = ?", userID).Pluck("id", &courseIDs)
+		h.db.WithContext(ctx).Model(&models.Course{}).Where("teacher_id = ?", userID).Pluck("id", &courseIDs)
 	} else {
-		h.db.Model(&models.Course{}).Pluck("id", &courseIDs)
+		h.db.WithContext(ctx).Model(&models.Course{}).Pluck("id", &courseIDs)
 	}
 
 	if len(courseIDs) > 0 {
 		// Count assignments in these courses
 		var assignmentsCount int64
-		h.db.Model(&models.Assignment{}).
+		h.db.WithContext(ctx).Model(&models.Assignment{}).
 			Where("course_id IN ?", courseIDs).
 			Count(&assignmentsCount)
 		stats.AssignmentsCreated = int(assignmentsCount)
 
 		// Count quizzes in these courses
 		var quizzesCount int64
-		h.db.Model(&models.Quiz{}).
+		h.db.WithContext(ctx).Model(&models.Quiz{}).
 			Where("course_id IN ?", courseIDs).
 			Count(&quizzesCount)
 		stats.QuizzesCreated = int(quizzesCount)
 
 		// Count pending grades (submissions without grade)
 		var pendingGrades int64
-		h.db.Model(&models.Submission{}).
+		h.db.WithContext(ctx).Model(&models.Submission{}).
 			Joins("JOIN assignments ON assignments.id = submissions.assignment_id").
 			Where("assignments.course_id IN ? AND submissions.grade IS NULL", courseIDs).
 			Count(&pendingGrades)
@@ -279,7 +280,7 @@ func (h *userHandlers) getTeacherStats(userID uint, role string) TeacherStats {
 
 		// Recent submissions for grading
 		var submissions []models.Submission
-		h.db.Joins("JOIN assignments ON assignments.id = submissions.assignment_id").
+		h.db.WithContext(ctx).Joins("JOIN assignments ON assignments.id = submissions.assignment_id").
 			Where("assignments.course_id IN ?", courseIDs).
 			Order("submissions.created_at DESC").
 			Limit(10).
@@ -287,9 +288,9 @@ func (h *userHandlers) getTeacherStats(userID uint, role string) TeacherStats {
 
 		for _, s := range submissions {
 			var assignment models.Assignment
-			if h.db.First(&assignment, s.AssignmentID).Error == nil {
+			if h.db.WithContext(ctx).First(&assignment, s.AssignmentID).Error == nil {
 				var student models.User
-				h.db.First(&student, s.StudentID)
+				h.db.WithContext(ctx).First(&student, s.StudentID)
 				stats.RecentSubmissions = append(stats.RecentSubmissions, Activity{
 					Type:      "assignment_submit",
 					Title:     assignment.Title + " - " + student.Name,
@@ -16,16 +16,16 @@ import (
 )
 
 type wecomHandlers struct {
-	wecom     *clients.WecomClient
-	db        *gorm.DB
-	jwtSecret string
+	wecom  *clients.WecomClient
+	db     *gorm.DB
+	tokens auth.TokenConfig
 }
 
-func newWecomHandlers(wecom *clients.WecomClient, db *gorm.DB, jwtSecret string) *wecomHandlers {
+func newWecomHandlers(wecom *clients.WecomClient, db *gorm.DB, tokens auth.TokenConfig) *wecomHandlers {
 	return &wecomHandlers{
-		wecom:     wecom,
-		db:        db,
-		jwtSecret: jwtSecret,
+		wecom:  wecom,
+		db:     db,
+		tokens: tokens,
 	}
 }
 
@@ -82,7 +82,7 @@ func (h *wecomHandlers) Login(c *gin.Context) {
 
 	// Find or create user in database by WecomUserID
 	var user models.User
-	result := h.db.Where("wecom_user_id = ?", userInfo.UserID).First(&user)
+	result := h.db.WithContext(c.Request.Context()).Where("wecom_user_id = ?", userInfo.UserID).First(&user)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			// Create new user with student role by default
@@ -93,7 +93,7 @@ func (h *wecomHandlers) Login(c *gin.Context) {
 				Name:         userName,
 				WecomUserID:  userInfo.UserID,
 			}
-			if err := h.db.Create(&user).Error; err != nil {
+			if err := h.db.WithContext(c.Request.Context()).Create(&user).Error; err != nil {
 				respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to create user", nil)
 				return
 			}
@@ -105,12 +105,12 @@ func (h *wecomHandlers) Login(c *gin.Context) {
 
 	// Update user name if changed
 	if userName != "" && user.Name != userName {
-		h.db.Model(&user).Update("name", userName)
+		h.db.WithContext(c.Request.Context()).Model(&user).Update("name", userName)
 	}
 
 	// Generate JWT token
 	expiresIn := 86400 // 24 hours
-	token, err := auth.SignToken(h.jwtSecret, user.ID, user.Username, user.Role, time.Duration(expiresIn)*time.Second)
+	token, err := auth.SignToken(h.tokens, user.ID, user.Username, user.Role, time.Duration(expiresIn)*time.Second)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to generate token", nil)
 		return
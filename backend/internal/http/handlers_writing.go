@@ -3,6 +3,8 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,19 +12,33 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"gorm.io/gorm"
 )
 
 type writingHandlers struct {
 	db       *gorm.DB
 	aiClient *clients.AIClient
+	queue    *jobs.Queue
+	service  *services.WritingService
 }
 
-func newWritingHandlers(db *gorm.DB, aiClient *clients.AIClient) *writingHandlers {
-	return &writingHandlers{db: db, aiClient: aiClient}
+func newWritingHandlers(db *gorm.DB, aiClient *clients.AIClient, queue *jobs.Queue) *writingHandlers {
+	return &writingHandlers{db: db, aiClient: aiClient, queue: queue, service: services.NewWritingService(db)}
 }
 
+const (
+	writingAnalysisTimeout  = 5 * time.Minute
+	writingAnalysisAttempts = 2
+	// writingDraftTTL is how long an untouched draft is kept before it is
+	// removed the next time its author saves a new one.
+	writingDraftTTL = 30 * 24 * time.Hour
+)
+
 // WritingType validation
 var validWritingTypes = map[string]bool{
 	"literature_review": true,
@@ -31,7 +47,8 @@ var validWritingTypes = map[string]bool{
 	"abstract":          true,
 }
 
-// SubmitWriting creates a new writing submission
+// SubmitWriting saves a new writing draft; it is analyzed and shown to
+// teachers only after FinalizeWriting
 // POST /api/v1/courses/:courseId/writing
 type submitWritingRequest struct {
 	Title        string `json:"title" binding:"required"`
@@ -59,45 +76,130 @@ func (h *writingHandlers) SubmitWriting(c *gin.Context) {
 		return
 	}
 
-	studentID, _ := c.Get("user_id")
+	user, _ := middleware.GetUser(c)
 
 	// Count words (simple split by whitespace)
 	wordCount := len(strings.Fields(req.Content))
 
 	submission := models.WritingSubmission{
-		StudentID:    studentID.(uint),
+		StudentID:    user.ID,
 		CourseID:     uint(courseID),
 		AssignmentID: req.AssignmentID,
 		WritingType:  req.WritingType,
 		Title:        req.Title,
 		Content:      req.Content,
 		WordCount:    wordCount,
+		Draft:        true,
+	}
+
+	if err := h.db.WithContext(c.Request.Context()).Create(&submission).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
+		return
+	}
+
+	// Drop this student's abandoned drafts; failing to do so never blocks saving
+	if err := h.db.WithContext(c.Request.Context()).
+		Where("student_id = ? AND draft = ? AND updated_at < ?", submission.StudentID, true, time.Now().Add(-writingDraftTTL)).
+		Delete(&models.WritingSubmission{}).Error; err != nil {
+		logger.Log.Warn("expired writing drafts not removed", slog.Uint64("student_id", uint64(submission.StudentID)), slog.Any("error", err))
 	}
 
-	if err := h.db.Create(&submission).Error; err != nil {
+	respondCreated(c, submission)
+}
+
+// FinalizeWriting turns the caller's draft into a submission, making it
+// visible to teachers and queueing AI analysis
+// POST /api/v1/writing/:id/finalize
+func (h *writingHandlers) FinalizeWriting(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid id", nil)
+		return
+	}
+
+	var submission models.WritingSubmission
+	if err := h.db.WithContext(c.Request.Context()).First(&submission, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, "NOT_FOUND", "submission not found", nil)
+			return
+		}
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
 		return
 	}
 
+	if !requireCourseModuleForCourseID(c, h.db, submission.CourseID, "course.writing") {
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	if submission.StudentID != user.ID {
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "cannot finalize other student's submission", nil)
+		return
+	}
+
+	// Only one of two racing finalize calls flips the flag and queues analysis
+	result := h.db.WithContext(c.Request.Context()).Model(&models.WritingSubmission{}).
+		Where("id = ? AND draft = ?", submission.ID, true).
+		Update("draft", false)
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", result.Error.Error(), nil)
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusConflict, "ALREADY_FINALIZED", "submission already finalized", nil)
+		return
+	}
+	submission.Draft = false
+
 	// Record learning event
-	h.db.Create(&models.LearningEvent{
-		StudentID: studentID.(uint),
+	h.db.WithContext(c.Request.Context()).Create(&models.LearningEvent{
+		StudentID: submission.StudentID,
 		CourseID:  &submission.CourseID,
 		EventType: "writing_submit",
-		Payload:   `{"submission_id":` + strconv.Itoa(int(submission.ID)) + `,"writing_type":"` + req.WritingType + `"}`,
+		Payload:   `{"submission_id":` + strconv.Itoa(int(submission.ID)) + `,"writing_type":"` + submission.WritingType + `"}`,
 	})
 
-	// Trigger async AI analysis
-	go h.triggerAIAnalysis(submission)
+	// Queue AI analysis; the submission is finalized either way, and without
+	// an AI service there is nothing to queue
+	if !h.aiClient.IsConfigured() {
+		logger.Log.Info("writing analysis skipped, AI not configured", slog.Uint64("submission_id", uint64(submission.ID)))
+	} else if err := h.queue.Enqueue(jobs.Task{
+		Name:        "writing_analysis:submission_" + strconv.Itoa(int(submission.ID)),
+		MaxAttempts: writingAnalysisAttempts,
+		Timeout:     writingAnalysisTimeout,
+		Run: func(ctx context.Context) error {
+			return h.analyzeWriting(ctx, submission)
+		},
+	}); err != nil {
+		logger.Log.Warn("writing analysis not queued", slog.Uint64("submission_id", uint64(submission.ID)), slog.Any("error", err))
+	}
 
-	respondCreated(c, submission)
+	respondOK(c, submission)
 }
 
-func (h *writingHandlers) triggerAIAnalysis(submission models.WritingSubmission) {
-	// Create context with timeout for analysis
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+// DeleteWritingDrafts removes the caller's unfinished drafts in a course
+// DELETE /api/v1/courses/:courseId/writing/drafts
+func (h *writingHandlers) DeleteWritingDrafts(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid course_id", nil)
+		return
+	}
 
+	user, _ := middleware.GetUser(c)
+	result := h.db.WithContext(c.Request.Context()).
+		Where("course_id = ? AND student_id = ? AND draft = ?", courseID, user.ID, true).
+		Delete(&models.WritingSubmission{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", result.Error.Error(), nil)
+		return
+	}
+
+	respondOK(c, gin.H{"deleted": result.RowsAffected})
+}
+
+// analyzeWriting runs AI analysis for a submission and stores the feedback.
+func (h *writingHandlers) analyzeWriting(ctx context.Context, submission models.WritingSubmission) error {
 	// Prepare request
 	req := clients.WritingAnalysisRequest{
 		Content:     submission.Content,
@@ -108,9 +210,7 @@ func (h *writingHandlers) triggerAIAnalysis(submission models.WritingSubmission)
 	// Call AI service
 	resp, err := h.aiClient.AnalyzeWriting(ctx, req)
 	if err != nil {
-		// Log error (in a real app, use a logger)
-		// fmt.Printf("AI analysis failed for submission %d: %v\n", submission.ID, err)
-		return
+		return err
 	}
 
 	// Serialize results
@@ -118,18 +218,20 @@ func (h *writingHandlers) triggerAIAnalysis(submission models.WritingSubmission)
 	dimensionJSON, _ := json.Marshal(resp.Dimensions)
 
 	// Update submission
-	h.db.Model(&submission).Updates(map[string]interface{}{
+	if err := h.db.WithContext(ctx).Model(&submission).Updates(map[string]interface{}{
 		"feedback_json":  string(feedbackJSON),
 		"dimension_json": string(dimensionJSON),
-	})
+	}).Error; err != nil {
+		return err
+	}
 
 	// Record completion event
-	h.db.Create(&models.LearningEvent{
+	return h.db.WithContext(ctx).Create(&models.LearningEvent{
 		StudentID: submission.StudentID,
 		CourseID:  &submission.CourseID,
 		EventType: "writing_analyzed",
 		Payload:   `{"submission_id":` + strconv.Itoa(int(submission.ID)) + `,"score":` + strconv.FormatFloat(resp.OverallScore, 'f', 1, 64) + `}`,
-	})
+	}).Error
 }
 
 // GetWritingSubmissions returns writing submissions for a student in a course
@@ -141,15 +243,16 @@ func (h *writingHandlers) GetWritingSubmissions(c *gin.Context) {
 		return
 	}
 
-	studentID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
+	user, _ := middleware.GetUser(c)
 
 	var submissions []models.WritingSubmission
-	query := h.db.Where("course_id = ?", courseID)
+	query := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID)
 
-	// Students can only see their own submissions
-	if role == "student" {
-		query = query.Where("student_id = ?", studentID)
+	// Students can only see their own submissions; others never see drafts
+	if user.Role == "student" {
+		query = query.Where("student_id = ?", user.ID)
+	} else {
+		query = query.Where("draft = ?", false)
 	}
 
 	// Optional writing_type filter
@@ -172,7 +275,7 @@ func (h *writingHandlers) GetWritingSubmission(c *gin.Context) {
 	}
 
 	var submission models.WritingSubmission
-	if err := h.db.First(&submission, id).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&submission, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "submission not found", nil)
 			return
@@ -186,12 +289,15 @@ func (h *writingHandlers) GetWritingSubmission(c *gin.Context) {
 	}
 
 	// Check permission
-	studentID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
-	if role == "student" && submission.StudentID != studentID.(uint) {
+	user, _ := middleware.GetUser(c)
+	if user.Role == "student" && submission.StudentID != user.ID {
 		respondError(c, http.StatusForbidden, "FORBIDDEN", "cannot view other student's submission", nil)
 		return
 	}
+	if submission.Draft && submission.StudentID != user.ID {
+		respondError(c, http.StatusNotFound, "NOT_FOUND", "submission not found", nil)
+		return
+	}
 
 	respondOK(c, submission)
 }
@@ -211,7 +317,7 @@ func (h *writingHandlers) UpdateWritingFeedback(c *gin.Context) {
 	}
 
 	var submission models.WritingSubmission
-	if err := h.db.First(&submission, id).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&submission, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			respondError(c, http.StatusNotFound, "NOT_FOUND", "submission not found", nil)
 			return
@@ -230,7 +336,7 @@ func (h *writingHandlers) UpdateWritingFeedback(c *gin.Context) {
 		return
 	}
 
-	result := h.db.Model(&models.WritingSubmission{}).Where("id = ?", id).Updates(map[string]interface{}{
+	result := h.db.WithContext(c.Request.Context()).Model(&models.WritingSubmission{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"feedback_json":  req.FeedbackJSON,
 		"dimension_json": req.DimensionJSON,
 	})
@@ -258,7 +364,7 @@ func (h *writingHandlers) GetWritingStats(c *gin.Context) {
 	}
 
 	var profiles []models.StudentLearningProfile
-	if err := h.db.Where("course_id = ?", courseID).Find(&profiles).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("course_id = ?", courseID).Find(&profiles).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to fetch profiles", nil)
 		return
 	}
@@ -292,3 +398,46 @@ func (h *writingHandlers) GetWritingStats(c *gin.Context) {
 		"student_count":  len(profiles),
 	})
 }
+
+// GetWritingTrend returns a student's overall and per-dimension writing scores over time
+// GET /api/v1/students/:studentId/writing-trend?course_id=&type=
+func (h *writingHandlers) GetWritingTrend(c *gin.Context) {
+	studentID, err := strconv.ParseUint(c.Param("studentId"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid student_id", nil)
+		return
+	}
+	var courseID uint64
+	if raw := c.Query("course_id"); raw != "" {
+		courseID, err = strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "INVALID_COURSE_ID", "invalid course_id", nil)
+			return
+		}
+	}
+	writingType := c.Query("type")
+	if writingType != "" && !validWritingTypes[writingType] {
+		respondError(c, http.StatusBadRequest, "BAD_REQUEST", "invalid type, must be one of: literature_review, course_paper, thesis, abstract", nil)
+		return
+	}
+
+	user, _ := middleware.GetUser(c)
+	trend, err := h.service.GetWritingTrend(c.Request.Context(), uint(studentID), services.UserInfo{
+		ID:   user.ID,
+		Role: user.Role,
+	}, uint(courseID), writingType)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCourseIDRequired):
+			respondError(c, http.StatusBadRequest, "COURSE_ID_REQUIRED", "course_id is required", nil)
+		case errors.Is(err, services.ErrCourseNotFound):
+			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
+		case errors.Is(err, services.ErrAccessDenied):
+			respondError(c, http.StatusForbidden, "FORBIDDEN", "cannot view this student's writing trend", nil)
+		default:
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to load writing trend", nil)
+		}
+		return
+	}
+	respondOK(c, trend)
+}
@@ -0,0 +1,286 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func setupWritingTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Course{},
+		&models.CourseEnrollment{},
+		&models.WritingSubmission{},
+		&models.LearningEvent{},
+	)
+	assert.NoError(t, err)
+
+	return db
+}
+
+func setupWritingRouter(t *testing.T, db *gorm.DB, jwtSecret string, aiBaseURL string) *gin.Engine {
+	queue := jobs.NewQueue(1, 4)
+	t.Cleanup(func() { _ = queue.Shutdown(context.Background()) })
+	hWriting := newWritingHandlers(db, clients.NewAIClient(aiBaseURL), queue)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: jwtSecret})
+
+	r := gin.New()
+	r.POST("/auth/login", hAuth.Login)
+
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthRequired(auth.TokenConfig{Secret: jwtSecret}))
+	{
+		api.POST("/courses/:courseId/writing", hWriting.SubmitWriting)
+		api.GET("/courses/:courseId/writing", hWriting.GetWritingSubmissions)
+		api.DELETE("/courses/:courseId/writing/drafts", hWriting.DeleteWritingDrafts)
+		api.GET("/writing/:id", hWriting.GetWritingSubmission)
+		api.POST("/writing/:id/finalize", hWriting.FinalizeWriting)
+		api.GET("/students/:studentId/writing-trend", hWriting.GetWritingTrend)
+	}
+
+	return r
+}
+
+func TestWritingDrafts_FinalizeBeforeAnalysisAndTeacherView(t *testing.T) {
+	var analyzed atomic.Int32
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		analyzed.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"overall_score": 80, "summary": "ok"})
+	}))
+	defer aiServer.Close()
+
+	db := setupWritingTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	createCourseTestUser(t, db, "student2", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID, EnabledModules: datatypes.JSON(`["course.writing"]`)}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	// An abandoned draft past the expiry window is cleaned up on the next save.
+	stale := models.WritingSubmission{StudentID: student.ID, CourseID: course.ID, WritingType: "abstract", Title: "old", Content: "old", Draft: true}
+	db.Create(&stale)
+	db.Model(&stale).UpdateColumn("updated_at", time.Now().Add(-writingDraftTTL-time.Hour))
+
+	r := setupWritingRouter(t, db, "test-secret", aiServer.URL)
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	studentToken := loginAndGetToken(t, r, "student1", "pass123")
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+
+	w := do(studentToken, http.MethodPost, "/api/v1/courses/1/writing", `{"title":"Paper","content":"some words here","writing_type":"course_paper"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created envelope[models.WritingSubmission]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, created.Data.Draft)
+	var staleCount int64
+	db.Model(&models.WritingSubmission{}).Where("id = ?", stale.ID).Count(&staleCount)
+	assert.Equal(t, int64(0), staleCount)
+
+	w = do(studentToken, http.MethodPost, "/api/v1/courses/1/writing", `{"title":"Scratch","content":"never finished","writing_type":"abstract"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(teacherToken, http.MethodGet, "/api/v1/courses/1/writing", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list envelope[[]models.WritingSubmission]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Data)
+	assert.Equal(t, http.StatusNotFound, do(teacherToken, http.MethodGet, "/api/v1/writing/2", "").Code)
+
+	otherToken := loginAndGetToken(t, r, "student2", "pass123")
+	assert.Equal(t, http.StatusForbidden, do(otherToken, http.MethodPost, "/api/v1/writing/2/finalize", "").Code)
+
+	w = do(studentToken, http.MethodPost, "/api/v1/writing/2/finalize", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(studentToken, http.MethodPost, "/api/v1/writing/2/finalize", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "ALREADY_FINALIZED")
+
+	assert.Eventually(t, func() bool {
+		var s models.WritingSubmission
+		db.First(&s, 2)
+		return s.FeedbackJSON != ""
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), analyzed.Load())
+
+	w = do(teacherToken, http.MethodGet, "/api/v1/courses/1/writing", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Data, 1) {
+		assert.Equal(t, "Paper", list.Data[0].Title)
+	}
+
+	w = do(studentToken, http.MethodDelete, "/api/v1/courses/1/writing/drafts", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var deleted envelope[struct {
+		Deleted int64 `json:"deleted"`
+	}]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &deleted))
+	assert.Equal(t, int64(1), deleted.Data.Deleted)
+
+	w = do(studentToken, http.MethodGet, "/api/v1/courses/1/writing", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Data, 1) {
+		assert.False(t, list.Data[0].Draft)
+	}
+}
+
+func TestFinalizeWriting_WithoutAIService(t *testing.T) {
+	db := setupWritingTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID, EnabledModules: datatypes.JSON(`["course.writing"]`)}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	r := setupWritingRouter(t, db, "test-secret", "")
+	token := loginAndGetToken(t, r, "student1", "pass123")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v1/courses/1/writing", `{"title":"Paper","content":"some words","writing_type":"abstract"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/writing/1/finalize", "").Code)
+
+	var s models.WritingSubmission
+	assert.NoError(t, db.First(&s, 1).Error)
+	assert.False(t, s.Draft)
+	assert.Empty(t, s.FeedbackJSON)
+}
+
+func TestSubmitWriting_PayloadTooLarge(t *testing.T) {
+	db := setupWritingTestDB(t)
+	createCourseTestUser(t, db, "student1", "pass123", "student")
+
+	queue := jobs.NewQueue(1, 4)
+	t.Cleanup(func() { _ = queue.Shutdown(context.Background()) })
+	hWriting := newWritingHandlers(db, clients.NewAIClient(""), queue)
+	hAuth := newAuthHandlers(db, auth.TokenConfig{Secret: "test-secret"})
+	r := gin.New()
+	r.Use(middleware.MaxBodyBytes(64))
+	r.POST("/auth/login", hAuth.Login)
+	r.POST("/courses/:courseId/writing", middleware.MaxBodyBytes(160), middleware.AuthRequired(auth.TokenConfig{Secret: "test-secret"}), hWriting.SubmitWriting)
+	token := loginAndGetToken(t, r, "student1", "pass123")
+
+	submit := func(content string, chunked bool) *httptest.ResponseRecorder {
+		body := `{"title":"Paper","writing_type":"abstract","content":"` + content + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/courses/1/writing", strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Over the route's limit, whether the length is declared up front or
+	// only found while binding.
+	for _, chunked := range []bool{false, true} {
+		w := submit(strings.Repeat("x", 200), chunked)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		var resp envelope[any]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		if assert.NotNil(t, resp.Error) {
+			assert.Equal(t, "PAYLOAD_TOO_LARGE", resp.Error.Code)
+		}
+	}
+
+	// Over the global limit but within the route's, with a declared length.
+	w := submit(strings.Repeat("x", 80), false)
+	assert.NotEqual(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestGetWritingTrend_OrderedDimensionScores(t *testing.T) {
+	db := setupWritingTestDB(t)
+	teacher := createCourseTestUser(t, db, "teacher1", "pass123", "teacher")
+	createCourseTestUser(t, db, "teacher2", "pass123", "teacher")
+	student := createCourseTestUser(t, db, "student1", "pass123", "student")
+	createCourseTestUser(t, db, "student2", "pass123", "student")
+	course := models.Course{Name: "Test Course", TeacherID: teacher.ID, EnabledModules: datatypes.JSON(`["course.writing"]`)}
+	db.Create(&course)
+	db.Create(&models.CourseEnrollment{CourseID: course.ID, UserID: student.ID})
+
+	base := time.Now().Add(-72 * time.Hour)
+	for i, sub := range []models.WritingSubmission{
+		{Title: "second", FeedbackJSON: `{"overall_score":78}`, DimensionJSON: `[{"name":"structure","score":80},{"name":"clarity","score":70}]`},
+		{Title: "first", FeedbackJSON: `{"overall_score":61.5}`, DimensionJSON: `[{"name":"structure","score":60}]`},
+		{Title: "pending"},
+		{Title: "draft", FeedbackJSON: `{"overall_score":99}`, Draft: true},
+		{Title: "broken", FeedbackJSON: `not json`},
+	} {
+		sub.StudentID, sub.CourseID, sub.WritingType, sub.Content = student.ID, course.ID, "course_paper", "text"
+		db.Create(&sub)
+		created := base.Add(time.Duration(i) * time.Hour)
+		if sub.Title == "first" {
+			created = base.Add(-time.Hour)
+		}
+		db.Model(&sub).UpdateColumn("created_at", created)
+	}
+
+	r := setupWritingRouter(t, db, "test-secret", "")
+	get := func(token, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	path := "/api/v1/students/" + strconv.FormatUint(uint64(student.ID), 10) + "/writing-trend"
+
+	w := get(loginAndGetToken(t, r, "student1", "pass123"), path+"?type=course_paper")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp envelope[services.WritingTrend]
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"clarity", "structure"}, resp.Data.Dimensions)
+	if assert.Len(t, resp.Data.Points, 2) {
+		assert.Equal(t, "first", resp.Data.Points[0].Title)
+		assert.Equal(t, 61.5, resp.Data.Points[0].OverallScore)
+		assert.Equal(t, map[string]float64{"structure": 60}, resp.Data.Points[0].Dimensions)
+		assert.Equal(t, map[string]float64{"structure": 80, "clarity": 70}, resp.Data.Points[1].Dimensions)
+	}
+
+	assert.Equal(t, http.StatusForbidden, get(loginAndGetToken(t, r, "student2", "pass123"), path).Code)
+
+	teacherToken := loginAndGetToken(t, r, "teacher1", "pass123")
+	assert.Equal(t, http.StatusBadRequest, get(teacherToken, path).Code)
+	w = get(teacherToken, path+"?course_id=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data.Points, 2)
+
+	assert.Equal(t, http.StatusForbidden, get(loginAndGetToken(t, r, "teacher2", "pass123"), path+"?course_id=1").Code)
+}
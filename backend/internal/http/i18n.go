@@ -0,0 +1,103 @@
+package http
+
+import (
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// supportedLanguages lists the error message languages; the first is the
+// default when Accept-Language is missing or matches none of them.
+var supportedLanguages = []language.Tag{
+	language.English,
+	language.SimplifiedChinese,
+}
+
+var languageMatcher = language.NewMatcher(supportedLanguages)
+
+// localizedMessage holds the generic message for an error code per language.
+type localizedMessage struct {
+	en string
+	zh string
+}
+
+// errorCatalog localizes the standard error codes. Codes not listed keep the
+// handler's message as is.
+var errorCatalog = map[string]localizedMessage{
+	"BAD_REQUEST":             {en: "invalid request", zh: "请求参数错误"},
+	"INVALID_REQUEST":         {en: "invalid request", zh: "请求参数错误"},
+	"INVALID_ID":              {en: "invalid id", zh: "无效的 ID"},
+	"INVALID_COURSE_ID":       {en: "invalid course id", zh: "无效的课程 ID"},
+	"COURSE_ID_REQUIRED":      {en: "course_id is required", zh: "缺少课程 ID"},
+	"UNAUTHORIZED":            {en: "authentication required", zh: "请先登录"},
+	"INVALID_CREDENTIALS":     {en: "invalid username or password", zh: "用户名或密码错误"},
+	"FORBIDDEN":               {en: "permission denied", zh: "没有权限执行此操作"},
+	"ACCESS_DENIED":           {en: "access denied", zh: "无权访问"},
+	"ROLE_NOT_ALLOWED":        {en: "role not allowed", zh: "当前角色不允许此操作"},
+	"NOT_FOUND":               {en: "resource not found", zh: "资源不存在"},
+	"COURSE_NOT_FOUND":        {en: "course not found", zh: "课程不存在"},
+	"CHAPTER_NOT_FOUND":       {en: "chapter not found", zh: "章节不存在"},
+	"INVALID_CHAPTER":         {en: "chapter must belong to the same course", zh: "所选章节须属于同一课程"},
+	"CONFIRMATION_REQUIRED":   {en: "confirmation does not match the course name", zh: "请输入课程名称以确认删除"},
+	"USER_NOT_FOUND":          {en: "user not found", zh: "用户不存在"},
+	"CONFLICT":                {en: "resource already exists", zh: "资源已存在"},
+	"ALREADY_SUBMITTED":       {en: "attempt already submitted", zh: "该作答已提交"},
+	"UNANSWERED_QUESTIONS":    {en: "all questions must be answered", zh: "请先回答所有题目"},
+	"PIN_LIMIT_REACHED":       {en: "too many pinned announcements", zh: "置顶公告数量已达上限"},
+	"ALREADY_FINALIZED":       {en: "submission already finalized", zh: "该写作已正式提交"},
+	"MODE_NOT_ALLOWED":        {en: "ai mode not allowed for your role", zh: "当前角色不能使用该 AI 模式"},
+	"NOT_IN_GROUP":            {en: "join a group before submitting", zh: "请先加入小组再提交"},
+	"ALREADY_IN_GROUP":        {en: "student already in another group", zh: "学生已在其他小组中"},
+	"GROUP_HAS_SUBMISSIONS":   {en: "group has submissions", zh: "该小组已有提交，无法删除"},
+	"MODULE_DISABLED":         {en: "module disabled for this course", zh: "该课程未启用此模块"},
+	"INVALID_MODULE_SETTINGS": {en: "invalid module settings", zh: "课程模块设置无效"},
+	"PREREQUISITE_NOT_MET":    {en: "prerequisite not met", zh: "未完成前置章节"},
+	"INVALID_QUIZ_WINDOW":     {en: "end time must be after start time", zh: "结束时间必须晚于开始时间"},
+	"INVALID_TIME_LIMIT":      {en: "invalid time limit", zh: "限时不能为负数，也不能超过开放时段"},
+	"INVALID_LATE_POLICY":     {en: "invalid late policy", zh: "迟交扣分须在 0-100 分之间，且仅在允许迟交时设置"},
+	"INVALID_RISK_POLICY":     {en: "invalid risk policy", zh: "预警阈值须在有效范围内，权重不能为负且不能全为零"},
+	"INVALID_DATE_RANGE":      {en: "invalid date range", zh: "开始时间须早于结束时间，且跨度不超过 366 天"},
+	"INTERNAL_ERROR":          {en: "internal server error", zh: "服务器内部错误"},
+	"DATABASE_ERROR":          {en: "database error", zh: "数据库错误"},
+	"BAD_GATEWAY":             {en: "upstream service error", zh: "上游服务异常"},
+	"SERVICE_UNAVAILABLE":     {en: "service unavailable", zh: "服务暂不可用"},
+	"PAYLOAD_TOO_LARGE":       {en: "request body too large", zh: "请求内容过大"},
+	"AI_DISABLED":             {en: "AI features are not configured", zh: "AI 功能未配置"},
+}
+
+// requestLanguage picks the error message language from Accept-Language.
+func requestLanguage(c *gin.Context) language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	if err != nil || len(tags) == 0 {
+		return supportedLanguages[0]
+	}
+	_, idx, _ := languageMatcher.Match(tags...)
+	return supportedLanguages[idx]
+}
+
+// localizeMessage returns the message for code in the request's language.
+// Chinese clients get the catalog message for standard codes. English clients
+// keep the handler's more specific message unless it is not in English.
+func localizeMessage(c *gin.Context, code, message string) string {
+	entry, ok := errorCatalog[code]
+	if !ok {
+		return message
+	}
+	if requestLanguage(c) == language.SimplifiedChinese {
+		return entry.zh
+	}
+	if containsHan(message) {
+		return entry.en
+	}
+	return message
+}
+
+func containsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
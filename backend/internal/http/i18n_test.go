@@ -0,0 +1,47 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondError_LocalizesMessage(t *testing.T) {
+	r := gin.New()
+	r.GET("/err/:code", func(c *gin.Context) {
+		respondError(c, http.StatusBadRequest, c.Param("code"), "invalid quiz id", nil)
+	})
+
+	get := func(code, acceptLanguage string) apiError {
+		req := httptest.NewRequest(http.MethodGet, "/err/"+code, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp envelope[any]
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return *resp.Error
+	}
+
+	cases := map[string]string{
+		"ACCESS_DENIED":  "无权访问",
+		"NOT_FOUND":      "资源不存在",
+		"INTERNAL_ERROR": "服务器内部错误",
+	}
+	for code, want := range cases {
+		got := get(code, "zh-CN,zh;q=0.9,en;q=0.8")
+		assert.Equal(t, code, got.Code)
+		assert.Equal(t, want, got.Message)
+	}
+
+	// English and missing headers keep the handler's message.
+	assert.Equal(t, "invalid quiz id", get("BAD_REQUEST", "en-US").Message)
+	assert.Equal(t, "invalid quiz id", get("BAD_REQUEST", "").Message)
+	// Codes outside the catalog are never rewritten.
+	assert.Equal(t, "invalid quiz id", get("SOME_CUSTOM_CODE", "zh-CN").Message)
+}
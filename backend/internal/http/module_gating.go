@@ -31,7 +31,7 @@ func RequireCourseModule(db *gorm.DB, moduleKey string) gin.HandlerFunc {
 		}
 
 		var course models.Course
-		if err := db.First(&course, courseID).Error; err != nil {
+		if err := db.WithContext(c.Request.Context()).First(&course, courseID).Error; err != nil {
 			respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
 			return
 		}
@@ -57,7 +57,7 @@ func RequireCourseModule(db *gorm.DB, moduleKey string) gin.HandlerFunc {
 // requireCourseModuleForCourseID checks module gating for handlers that already resolved course ID.
 func requireCourseModuleForCourseID(c *gin.Context, db *gorm.DB, courseID uint, moduleKey string) bool {
 	var course models.Course
-	if err := db.First(&course, courseID).Error; err != nil {
+	if err := db.WithContext(c.Request.Context()).First(&course, courseID).Error; err != nil {
 		respondError(c, http.StatusNotFound, "COURSE_NOT_FOUND", "course not found", nil)
 		return false
 	}
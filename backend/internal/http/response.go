@@ -1,11 +1,31 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"reflect"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
 )
 
+func init() {
+	// Report validation failures by JSON field name rather than Go field name.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
 type apiError struct {
 	Code    string      `json:"code,omitempty"`
 	Message string      `json:"message"`
@@ -32,6 +52,51 @@ func respondCreated(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusCreated, apiEnvelope{Success: true, Data: data})
 }
 
+// respondAccepted reports work that will finish in the background.
+func respondAccepted(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusAccepted, apiEnvelope{Success: true, Data: data})
+}
+
+// respondError writes an error envelope. The code is stable for clients; the
+// message is localized from Accept-Language (see localizeMessage).
+//
+// A request rejected because its body could not be read past the
+// middleware.MaxBodyBytes limit is reported as 413 PAYLOAD_TOO_LARGE instead.
 func respondError(c *gin.Context, status int, code string, message string, details interface{}) {
+	if limit, ok := middleware.BodyTooLarge(c); ok && status == http.StatusBadRequest {
+		status, code, message = http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "request body too large"
+		details = gin.H{"limit_bytes": limit}
+	}
+	message = localizeMessage(c, code, message)
 	c.JSON(status, apiEnvelope{Success: false, Error: &apiError{Code: code, Message: message, Details: details}})
 }
+
+// fieldError describes one request field that failed binding or validation.
+type fieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// respondBindError reports a ShouldBind* failure without leaking binder
+// internals. Validation and type errors are listed per field in details.
+func respondBindError(c *gin.Context, code string, err error) {
+	var details []fieldError
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fe := range validationErrs {
+			details = append(details, fieldError{Field: fe.Field(), Rule: fe.Tag(), Param: fe.Param()})
+		}
+	case errors.As(err, &typeErr):
+		details = append(details, fieldError{Field: typeErr.Field, Rule: "type", Param: typeErr.Type.String()})
+	}
+
+	if len(details) == 0 {
+		respondError(c, http.StatusBadRequest, code, "invalid request body", nil)
+		return
+	}
+	respondError(c, http.StatusBadRequest, code, "invalid request", details)
+}
@@ -6,16 +6,19 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/clients"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/config"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/middleware"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/services"
 	"golang.org/x/time/rate"
 	"gorm.io/gorm"
 )
 
 // NewRouter builds the Gin engine with all routes and middleware configured.
-func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, simClient *clients.SimClient, minioClient *clients.MinioClient) *gin.Engine {
+func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, simClient *clients.SimClient, minioClient *clients.MinioClient, queue *jobs.Queue) *gin.Engine {
 	r := gin.New()
 	r.Use(middleware.RequestID(), middleware.RequestLogger(), gin.Recovery())
 	r.Use(newCORS(cfg.CorsOrigins))
@@ -25,45 +28,82 @@ func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, s
 	aiLimiter := middleware.NewRateLimiter(rate.Every(3*time.Second), 10, 30*time.Minute)
 
 	r.Use(middleware.RateLimitByIP(globalLimiter))
+	r.Use(middleware.MaxBodyBytes(cfg.MaxBodyBytes))
 
 	r.GET("/healthz", func(c *gin.Context) {
-		respondOK(c, gin.H{"status": "ok"})
+		respondOK(c, gin.H{"status": "ok", "job_queue_depth": queue.Depth()})
 	})
 
-	hAuth := newAuthHandlers(gormDB, cfg.JWTSecret)
-	hCourse := newCourseHandlers(gormDB)
-	hAI := newAIHandlers(aiClient)
+	// WeChat Work client (optional)
+	wecomClient := clients.NewWecomClient(clients.WecomConfig{
+		CorpID:  cfg.WecomCorpID,
+		AgentID: cfg.WecomAgentID,
+		Secret:  cfg.WecomSecret,
+	})
+
+	sessions := services.NewSessionService(gormDB, 30*time.Second)
+	tokens := auth.TokenConfig{
+		Secret:   cfg.JWTSecret,
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Sessions: sessions,
+		Courses:  services.NewCourseService(gormDB, cfg.DefaultCourseModules),
+	}
+
+	hAuth := newAuthHandlers(gormDB, tokens)
+	hCourse := newCourseHandlers(gormDB, cfg.DefaultCourseModules)
+	hAI := newAIHandlers(aiClient, cfg.AIAllowedModes).withTranscripts(services.NewChatTranscriptService(gormDB), queue)
 	hSim := newSimHandlers(simClient)
-	hAssignment := newAssignmentHandlers(gormDB, aiClient)
+	hAssignment := newAssignmentHandlers(gormDB, aiClient, clients.NewNotifier(wecomClient), queue)
+	if minioClient != nil {
+		hAssignment.files = minioClient
+	}
+	receiptSecret := cfg.ReceiptSecret
+	if receiptSecret == "" {
+		receiptSecret = cfg.JWTSecret
+	}
+	hAssignment.service.WithReceiptSigner(services.NewReceiptSigner(receiptSecret))
 	hResource := newResourceHandlers(gormDB)
 	hUpload := newUploadHandlers(gormDB, minioClient)
 	hQuiz := newQuizHandlers(gormDB)
 	hUser := newUserHandlers(gormDB)
-	hChapter := newChapterHandlers(gormDB)
-	hAnnouncement := newAnnouncementHandlers(gormDB)
+	hChapter := newChapterHandlers(gormDB, cfg.StudySessionTimeout)
+	hAnnouncement := newAnnouncementHandlers(gormDB, cfg.MaxPinnedAnnouncements)
 	hAttendance := newAttendanceHandlers(gormDB)
 	hLearningProfile := newLearningProfileHandlers(gormDB)
-	hAdmin := newAdminHandlers(gormDB)
+	hAdmin := newAdminHandlers(gormDB, sessions)
 	hGlobalProfile := newGlobalProfileHandlers(gormDB)
-	hWriting := newWritingHandlers(gormDB, aiClient)
-
-	// WeChat Work client (optional)
-	wecomClient := clients.NewWecomClient(clients.WecomConfig{
-		CorpID:  cfg.WecomCorpID,
-		AgentID: cfg.WecomAgentID,
-		Secret:  cfg.WecomSecret,
-	})
-	hWecom := newWecomHandlers(wecomClient, gormDB, cfg.JWTSecret)
-
-	api := r.Group("/api/v1")
+	hWriting := newWritingHandlers(gormDB, aiClient, queue)
+	hUpcoming := newUpcomingHandlers(gormDB)
+	hDashboard := newDashboardHandlers(gormDB)
+	hCourseOverview := newCourseOverviewHandlers(gormDB)
+	hAtRisk := newAtRiskHandlers(gormDB)
+	features := services.NewFeatureFlags(cfg.FeatureFlags)
+	hFeatureFlags := newFeatureFlagHandlers(features)
+
+	hWecom := newWecomHandlers(wecomClient, gormDB, tokens)
+
+	// Ordinary routes get a short deadline; AI, simulation and upload routes
+	// share the prefix through longAPI with a deadline sized for the AI client.
+	// Responses streamed for as long as the client keeps reading, such as
+	// archive downloads and server-sent events, go on streamAPI, which has no
+	// deadline.
+	api := r.Group("/api/v1", middleware.Timeout(cfg.RequestTimeout))
+	longAPI := r.Group("/api/v1", middleware.Timeout(cfg.AIRequestTimeout))
+	streamAPI := r.Group("/api/v1")
 	{
 		api.POST("/auth/login", middleware.RateLimitByIP(authLimiter), hAuth.Login)
-		api.GET("/auth/me", middleware.AuthRequired(cfg.JWTSecret), hAuth.Me)
+		api.GET("/auth/me", middleware.AuthRequired(tokens), hAuth.Me)
+		api.POST("/auth/logout", middleware.AuthRequired(tokens), hAuth.Logout)
+		api.GET("/feature-flags", middleware.AuthRequired(tokens), hFeatureFlags.ListFeatureFlags)
 
 		// User stats route
-		api.GET("/user/stats", middleware.AuthRequired(cfg.JWTSecret), middleware.RequirePermission(authz.PermUserStats), hUser.GetStats)
+		api.GET("/user/stats", middleware.AuthRequired(tokens), middleware.RequirePermission(authz.PermUserStats), hUser.GetStats)
 		// Compatibility alias for mobile client
-		api.GET("/users/me/stats", middleware.AuthRequired(cfg.JWTSecret), middleware.RequirePermission(authz.PermUserStats), hUser.GetStats)
+		api.GET("/users/me/stats", middleware.AuthRequired(tokens), middleware.RequirePermission(authz.PermUserStats), hUser.GetStats)
+		api.GET("/me/upcoming", middleware.AuthRequired(tokens), middleware.RequirePermission(authz.PermCourseRead), hUpcoming.ListUpcoming)
+		api.GET("/me/dashboard", middleware.AuthRequired(tokens), middleware.RequirePermission(authz.PermCourseRead), hDashboard.GetDashboard)
+		api.GET("/me/quizzes", middleware.AuthRequired(tokens), middleware.RequirePermission(authz.PermQuizRead), hQuiz.ListMyQuizzes)
 
 		// WeChat Work OAuth routes (no auth required)
 		api.POST("/auth/wecom", hWecom.Login)
@@ -72,291 +112,494 @@ func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, s
 
 		api.GET(
 			"/courses",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hCourse.List,
 		)
 		api.GET(
 			"/courses/:courseId",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hCourse.Get,
 		)
+		api.DELETE(
+			"/courses/:courseId",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseWrite),
+			hCourse.Purge,
+		)
+		api.GET(
+			"/courses/:courseId/overview",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseRead),
+			hCourseOverview.GetOverview,
+		)
+		api.GET(
+			"/courses/:courseId/at-risk",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseWrite),
+			hAtRisk.ListAtRisk,
+		)
 		api.GET(
 			"/courses/:courseId/modules",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hCourse.GetModules,
 		)
 		api.POST(
 			"/courses",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseWrite),
 			hCourse.Create,
 		)
 		api.PUT(
 			"/courses/:courseId/modules",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseWrite),
 			hCourse.UpdateModules,
 		)
+		api.PUT(
+			"/courses/:courseId/time-zone",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseWrite),
+			hCourse.UpdateTimeZone,
+		)
+		api.PUT(
+			"/courses/:courseId/enrollments/:userId/role",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseWrite),
+			hCourse.UpdateEnrollmentRole,
+		)
+		api.DELETE(
+			"/courses/:courseId/enrollments/me",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseRead),
+			hCourse.Unenroll,
+		)
+		api.POST(
+			"/courses/:courseId/clone",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseWrite),
+			hCourse.Clone,
+		)
 
 		// Chapter routes
 		api.GET(
 			"/courses/:courseId/chapters",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hChapter.ListChapters,
 		)
 		api.POST(
 			"/chapters",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseWrite),
 			hChapter.CreateChapter,
 		)
 		api.GET(
 			"/chapters/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hChapter.GetChapter,
 		)
 		api.PUT(
 			"/chapters/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseWrite),
 			hChapter.UpdateChapter,
 		)
 		api.DELETE(
 			"/chapters/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseWrite),
 			hChapter.DeleteChapter,
 		)
+		api.POST(
+			"/chapters/:id/complete",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseRead),
+			hChapter.CompleteChapter,
+		)
 		api.POST(
 			"/chapters/:id/heartbeat",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hChapter.Heartbeat,
 		)
 		// Compatibility alias for mobile client
 		api.POST(
 			"/chapters/:id/study-time",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hChapter.Heartbeat,
 		)
 		api.GET(
 			"/chapters/:id/my-stats",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hChapter.GetMyStats,
 		)
 		api.GET(
 			"/chapters/:id/class-stats",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseWrite),
 			hChapter.GetClassStats,
 		)
+		streamAPI.GET(
+			"/chapters/:id/class-stats/stream",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseWrite),
+			hChapter.StreamClassStats,
+		)
+		api.GET(
+			"/courses/:courseId/study-time",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseRead),
+			hChapter.GetCourseStudyTime,
+		)
 
 		// Assignment routes
 		api.GET(
 			"/courses/:courseId/assignments/stats",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentRead),
 			hAssignment.GetCourseAssignmentStats,
 		)
 		api.GET(
 			"/courses/:courseId/assignments",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentRead),
 			hAssignment.ListAssignments,
 		)
 		api.POST(
 			"/courses/:courseId/assignments",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentWrite),
 			hAssignment.CreateAssignment,
 		)
 		// Compatibility alias for web client
 		api.POST(
 			"/assignments",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentWrite),
 			hAssignment.CreateAssignment,
 		)
 		api.GET(
 			"/assignments/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentRead),
 			hAssignment.GetAssignment,
 		)
+		api.PUT(
+			"/assignments/:id/chapter",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.MoveAssignmentToChapter,
+		)
+		api.POST(
+			"/assignments/:id/publish",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.PublishAssignment,
+		)
+		api.POST(
+			"/assignments/:id/unpublish",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.UnpublishAssignment,
+		)
+		api.POST(
+			"/assignments/:id/attachments",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.AddAttachment,
+		)
+		api.DELETE(
+			"/assignments/:id/attachments/:attachmentId",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.RemoveAttachment,
+		)
+		api.PUT(
+			"/assignments/:id/extensions/:studentId",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.GrantExtension,
+		)
+		api.DELETE(
+			"/assignments/:id/extensions/:studentId",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.RevokeExtension,
+		)
 		api.GET(
 			"/assignments/:id/stats",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentRead),
 			hAssignment.GetAssignmentStats,
 		)
 		api.POST(
 			"/assignments/:id/submit",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentSubmit),
 			hAssignment.SubmitAssignment,
 		)
 		api.GET(
 			"/assignments/:id/my-submission",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentRead),
 			hAssignment.GetMySubmission,
 		)
 		api.GET(
 			"/assignments/:id/submissions",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentGrade),
 			hAssignment.ListSubmissions,
 		)
+		streamAPI.GET(
+			"/assignments/:id/submissions.zip",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.DownloadSubmissionsZip,
+		)
+		api.POST(
+			"/assignments/:id/similarity",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentGrade),
+			hAssignment.CheckSimilarity,
+		)
+		api.GET(
+			"/assignments/:id/similarity",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentGrade),
+			hAssignment.GetSimilarityReport,
+		)
+
+		// Student groups for group-submission assignments
+		api.GET(
+			"/courses/:courseId/groups",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.ListGroups,
+		)
+		api.POST(
+			"/courses/:courseId/groups",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.CreateGroup,
+		)
+		api.PUT(
+			"/groups/:id",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.UpdateGroup,
+		)
+		api.DELETE(
+			"/groups/:id",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentWrite),
+			hAssignment.DeleteGroup,
+		)
 		api.POST(
 			"/submissions/:submissionId/grade",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentGrade),
 			hAssignment.GradeSubmission,
 		)
+		api.GET(
+			"/submissions/:submissionId/comments",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentRead),
+			hAssignment.ListSubmissionComments,
+		)
+		api.POST(
+			"/submissions/:submissionId/comments",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentRead),
+			hAssignment.AddSubmissionComment,
+		)
+		api.GET(
+			"/submissions/:submissionId/verify-receipt",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentRead),
+			hAssignment.VerifyReceipt,
+		)
 
 		// Resource routes
 		api.GET(
 			"/courses/:courseId/resources",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermResourceRead),
 			hResource.ListResources,
 		)
 		api.POST(
 			"/resources",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermResourceWrite),
 			hResource.CreateResource,
 		)
 		api.DELETE(
 			"/resources/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermResourceWrite),
 			hResource.DeleteResource,
 		)
 
 		// Upload routes (file handling)
-		api.POST(
+		longAPI.POST(
 			"/upload/assignment/:assignmentId",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.MaxBodyBytes(cfg.MaxUploadBodyBytes),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentSubmit),
 			hUpload.UploadAssignmentFile,
 		)
-		api.POST(
+		longAPI.POST(
 			"/upload/resource/:courseId",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.MaxBodyBytes(cfg.MaxUploadBodyBytes),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermResourceWrite),
 			hUpload.UploadResourceFile,
 		)
 
 		// AI grading route
-		api.POST(
+		longAPI.POST(
 			"/submissions/:submissionId/ai-grade",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentGrade),
+			requireFeature(features, services.FeatureAIGrading),
 			hAssignment.AIGradeSubmission,
 		)
 
-		api.POST(
+		longAPI.POST(
 			"/ai/chat",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAIUse),
 			middleware.RateLimitByUserOrIP(aiLimiter),
 			hAI.Chat,
 		)
-		api.POST(
+		longAPI.POST(
 			"/ai/chat_with_tools",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAIUse),
 			middleware.RateLimitByUserOrIP(aiLimiter),
 			hAI.ChatWithTools,
 		)
-		api.POST(
+		longAPI.POST(
 			"/ai/chat/guided",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAIUse),
 			middleware.RateLimitByUserOrIP(aiLimiter),
 			hAI.ChatGuided,
 		)
+		api.GET(
+			"/chat-sessions/:id",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAIUse),
+			hAI.GetChatSession,
+		)
 
 		// Announcement routes
 		api.GET(
 			"/courses/:courseId/announcements/summary",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAnnouncementRead),
 			hAnnouncement.GetSummary,
 		)
 		api.GET(
 			"/courses/:courseId/announcements",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAnnouncementRead),
 			hAnnouncement.List,
 		)
 		api.POST(
 			"/courses/:courseId/announcements",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAnnouncementWrite),
 			hAnnouncement.Create,
 		)
 		api.PUT(
 			"/announcements/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAnnouncementWrite),
 			hAnnouncement.Update,
 		)
 		api.DELETE(
 			"/announcements/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAnnouncementWrite),
 			hAnnouncement.Delete,
 		)
 		api.POST(
 			"/announcements/:id/read",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAnnouncementRead),
 			hAnnouncement.MarkRead,
 		)
+		api.POST(
+			"/courses/:courseId/announcements/read-all",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAnnouncementRead),
+			hAnnouncement.MarkAllRead,
+		)
+		api.POST(
+			"/announcements/:id/pin",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAnnouncementWrite),
+			hAnnouncement.Pin,
+		)
+		api.POST(
+			"/announcements/:id/unpin",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAnnouncementWrite),
+			hAnnouncement.Unpin,
+		)
 
 		// Attendance routes
 		api.GET(
 			"/courses/:courseId/attendance/summary",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAttendanceRead),
 			hAttendance.GetSummary,
 		)
+		api.GET(
+			"/courses/:courseId/attendance/compliance",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAttendanceWrite),
+			hAttendance.GetCompliance,
+		)
+		api.GET(
+			"/courses/:courseId/attendance/trend",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAttendanceWrite),
+			hAttendance.GetTrend,
+		)
 		api.GET(
 			"/courses/:courseId/attendance/sessions",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAttendanceRead),
 			hAttendance.ListSessions,
 		)
 		api.POST(
 			"/courses/:courseId/attendance/start",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAttendanceWrite),
 			hAttendance.StartSession,
 		)
 		api.POST(
 			"/attendance/:session_id/end",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAttendanceWrite),
 			hAttendance.EndSession,
 		)
 		api.POST(
 			"/attendance/:session_id/checkin",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAttendanceCheckin),
 			hAttendance.Checkin,
 		)
 		api.GET(
 			"/attendance/:session_id/records",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAttendanceRead),
 			hAttendance.GetRecords,
 		)
@@ -364,19 +607,19 @@ func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, s
 		// Learning Profile routes
 		api.GET(
 			"/learning-profiles/:courseId/:studentId",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hLearningProfile.GetProfile,
 		)
 		api.POST(
 			"/learning-profiles",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hLearningProfile.SaveProfile,
 		)
 		api.GET(
 			"/courses/:courseId/learning-profiles",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseWrite),
 			hLearningProfile.ListCourseProfiles,
 		)
@@ -384,25 +627,31 @@ func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, s
 		// Global Profile routes (student-centric multi-course tracking)
 		api.GET(
 			"/students/:studentId/global-profile",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hGlobalProfile.GetGlobalProfile,
 		)
 		api.POST(
 			"/students/:studentId/global-profile",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hGlobalProfile.SaveGlobalProfile,
 		)
 		api.GET(
 			"/students/:studentId/learning-timeline",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hGlobalProfile.GetLearningTimeline,
 		)
+		api.GET(
+			"/students/:studentId/writing-trend",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermCourseRead),
+			hWriting.GetWritingTrend,
+		)
 		api.POST(
 			"/learning-events",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCourseRead),
 			hGlobalProfile.RecordLearningEvent,
 		)
@@ -410,34 +659,48 @@ func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, s
 		// Writing submission routes
 		api.POST(
 			"/courses/:courseId/writing",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.MaxBodyBytes(cfg.MaxWritingBodyBytes),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentSubmit),
 			RequireCourseModule(gormDB, "course.writing"),
 			hWriting.SubmitWriting,
 		)
 		api.GET(
 			"/courses/:courseId/writing",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentRead),
 			RequireCourseModule(gormDB, "course.writing"),
 			hWriting.GetWritingSubmissions,
 		)
 		api.GET(
 			"/courses/:courseId/writing/stats",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentGrade),
 			RequireCourseModule(gormDB, "course.writing"),
 			hWriting.GetWritingStats,
 		)
+		api.DELETE(
+			"/courses/:courseId/writing/drafts",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentSubmit),
+			RequireCourseModule(gormDB, "course.writing"),
+			hWriting.DeleteWritingDrafts,
+		)
 		api.GET(
 			"/writing/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentRead),
 			hWriting.GetWritingSubmission,
 		)
+		api.POST(
+			"/writing/:id/finalize",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermAssignmentSubmit),
+			hWriting.FinalizeWriting,
+		)
 		api.PUT(
 			"/writing/:id/feedback",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermAssignmentGrade),
 			hWriting.UpdateWritingFeedback,
 		)
@@ -445,130 +708,259 @@ func NewRouter(cfg config.Config, gormDB *gorm.DB, aiClient *clients.AIClient, s
 		// Quiz routes
 		api.GET(
 			"/courses/:courseId/quizzes",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizRead),
 			hQuiz.ListQuizzes,
 		)
+		api.GET(
+			"/courses/:courseId/quizzes/summary",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.GetCourseQuizSummary,
+		)
+		api.POST(
+			"/courses/:courseId/quizzes/publish",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.PublishQuizzes,
+		)
+		api.POST(
+			"/courses/:courseId/quizzes/unpublish",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.UnpublishQuizzes,
+		)
+		api.GET(
+			"/courses/:courseId/students/:studentId/quiz-attempts",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizRead),
+			hQuiz.ListStudentAttempts,
+		)
 		api.POST(
 			"/quizzes",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.CreateQuiz,
 		)
 		api.GET(
 			"/quizzes/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizRead),
 			hQuiz.GetQuiz,
 		)
+		api.GET(
+			"/quizzes/:id/info",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizRead),
+			hQuiz.GetQuizInfo,
+		)
 		api.PUT(
 			"/quizzes/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.UpdateQuiz,
 		)
 		api.DELETE(
 			"/quizzes/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.DeleteQuiz,
 		)
+		api.POST(
+			"/quizzes/:id/restore",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.RestoreQuiz,
+		)
+		api.PUT(
+			"/quizzes/:id/chapter",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.MoveQuizToChapter,
+		)
+		api.POST(
+			"/quizzes/:id/close",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.CloseQuiz,
+		)
 		api.POST(
 			"/quizzes/:id/publish",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.PublishQuiz,
 		)
 		api.POST(
 			"/quizzes/:id/unpublish",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.UnpublishQuiz,
 		)
+		api.POST(
+			"/quizzes/:id/students/:studentId/grant-attempt",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.GrantAttempt,
+		)
+		api.POST(
+			"/quiz-attempts/:id/reopen",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.ReopenAttempt,
+		)
+		api.POST(
+			"/quiz-attempts/:id/feedback",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizTake),
+			hQuiz.SubmitQuizFeedback,
+		)
+		api.GET(
+			"/quizzes/:id/feedback-summary",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.GetQuizFeedbackSummary,
+		)
+		api.POST(
+			"/quizzes/:id/grade-preview",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.GradePreview,
+		)
+		api.POST(
+			"/quizzes/:id/regrade",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.RegradeQuiz,
+		)
+		api.GET(
+			"/quizzes/:id/results",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.GetQuizResults,
+		)
+		api.GET(
+			"/quizzes/:id/results.csv",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.ExportQuizResultsCSV,
+		)
+		api.GET(
+			"/quizzes/:id/questions",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.ListQuestions,
+		)
 		api.POST(
 			"/quizzes/:id/questions",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.AddQuestion,
 		)
+		api.POST(
+			"/quizzes/:id/questions/import",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.ImportQuestions,
+		)
+		api.POST(
+			"/quizzes/:id/questions/copy-from",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizWrite),
+			hQuiz.CopyQuestions,
+		)
 		api.PUT(
 			"/questions/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.UpdateQuestion,
 		)
 		api.DELETE(
 			"/questions/:id",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizWrite),
 			hQuiz.DeleteQuestion,
 		)
 		api.POST(
 			"/quizzes/:id/start",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizTake),
 			hQuiz.StartQuiz,
 		)
+		api.GET(
+			"/quizzes/:id/attempt/remaining",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizTake),
+			hQuiz.GetAttemptRemaining,
+		)
 		api.POST(
 			"/quizzes/:id/submit",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizTake),
 			hQuiz.SubmitQuiz,
 		)
 		api.GET(
 			"/quizzes/:id/result",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermQuizRead),
 			hQuiz.GetQuizResult,
 		)
+		api.GET(
+			"/quizzes/:id/leaderboard",
+			middleware.AuthRequired(tokens),
+			middleware.RequirePermission(authz.PermQuizRead),
+			hQuiz.GetLeaderboard,
+		)
 
 		// Simulation endpoints (require sim:use permission)
 		simMW := []gin.HandlerFunc{
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermSimUse),
 			RequireCourseModule(gormDB, "course.simulation"),
 		}
 
 		// Legacy Laplace2D endpoint
-		api.POST("/sim/laplace2d", append(simMW, hSim.Laplace2D)...)
+		longAPI.POST("/sim/laplace2d", append(simMW, hSim.Laplace2D)...)
 
 		// Electrostatics endpoints
-		api.POST("/sim/point_charges", append(simMW, hSim.SimProxy("/v1/sim/point_charges"))...)
-		api.POST("/sim/gauss_flux", append(simMW, hSim.SimProxy("/v1/sim/gauss_flux"))...)
+		longAPI.POST("/sim/point_charges", append(simMW, hSim.SimProxy("/v1/sim/point_charges"))...)
+		longAPI.POST("/sim/gauss_flux", append(simMW, hSim.SimProxy("/v1/sim/gauss_flux"))...)
 
 		// Magnetostatics endpoints
-		api.POST("/sim/wire_field", append(simMW, hSim.SimProxy("/v1/sim/wire_field"))...)
-		api.POST("/sim/solenoid", append(simMW, hSim.SimProxy("/v1/sim/solenoid"))...)
-		api.POST("/sim/ampere_loop", append(simMW, hSim.SimProxy("/v1/sim/ampere_loop"))...)
+		longAPI.POST("/sim/wire_field", append(simMW, hSim.SimProxy("/v1/sim/wire_field"))...)
+		longAPI.POST("/sim/solenoid", append(simMW, hSim.SimProxy("/v1/sim/solenoid"))...)
+		longAPI.POST("/sim/ampere_loop", append(simMW, hSim.SimProxy("/v1/sim/ampere_loop"))...)
 
 		// Wave endpoints
-		api.POST("/sim/wave_1d", append(simMW, hSim.SimProxy("/v1/sim/wave_1d"))...)
-		api.POST("/sim/fresnel", append(simMW, hSim.SimProxy("/v1/sim/fresnel"))...)
+		longAPI.POST("/sim/wave_1d", append(simMW, hSim.SimProxy("/v1/sim/wave_1d"))...)
+		longAPI.POST("/sim/fresnel", append(simMW, hSim.SimProxy("/v1/sim/fresnel"))...)
 
 		// Numerical computation endpoints
-		api.POST("/calc/integrate", append(simMW, hSim.CalcProxy("/v1/calc/integrate"))...)
-		api.POST("/calc/differentiate", append(simMW, hSim.CalcProxy("/v1/calc/differentiate"))...)
-		api.POST("/calc/evaluate", append(simMW, hSim.CalcProxy("/v1/calc/evaluate"))...)
-		api.POST("/calc/vector_op", append(simMW, hSim.CalcProxy("/v1/calc/vector_op"))...)
+		longAPI.POST("/calc/integrate", append(simMW, hSim.CalcProxy("/v1/calc/integrate"))...)
+		longAPI.POST("/calc/differentiate", append(simMW, hSim.CalcProxy("/v1/calc/differentiate"))...)
+		longAPI.POST("/calc/evaluate", append(simMW, hSim.CalcProxy("/v1/calc/evaluate"))...)
+		longAPI.POST("/calc/vector_op", append(simMW, hSim.CalcProxy("/v1/calc/vector_op"))...)
 
 		// Code execution endpoint (sandboxed)
-		api.POST(
+		longAPI.POST(
 			"/sim/run_code",
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermCodeRun),
 			hSim.SimProxy("/v1/sim/run_code"),
 		)
 
 		// Admin routes (require user:manage permission)
 		adminMW := []gin.HandlerFunc{
-			middleware.AuthRequired(cfg.JWTSecret),
+			middleware.AuthRequired(tokens),
 			middleware.RequirePermission(authz.PermUserManage),
 		}
 		api.GET("/admin/stats", append(adminMW, hAdmin.GetSystemStats)...)
+		api.GET("/admin/analytics", append(adminMW, hAdmin.GetAnalytics)...)
 		api.GET("/admin/users", append(adminMW, hAdmin.ListUsers)...)
 		api.POST("/admin/users", append(adminMW, hAdmin.CreateUser)...)
 		api.PUT("/admin/users/:id", append(adminMW, hAdmin.UpdateUser)...)
 		api.DELETE("/admin/users/:id", append(adminMW, hAdmin.DeleteUser)...)
+		api.POST("/admin/users/:id/revoke-sessions", append(adminMW, hAdmin.RevokeSessions)...)
+		api.GET("/audit-logs", append(adminMW, hAdmin.ListAuditLogs)...)
 	}
 
 	return r
@@ -580,7 +972,8 @@ func newCORS(origins []string) gin.HandlerFunc {
 			return cors.New(cors.Config{
 				AllowAllOrigins: true,
 				AllowMethods:    []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-				AllowHeaders:    []string{"Authorization", "Content-Type"},
+				AllowHeaders:    []string{"Authorization", "Content-Type", middleware.ViewAsHeader, middleware.ViewAsCourseHeader},
+				ExposeHeaders:   []string{chatSessionHeader},
 				MaxAge:          12 * time.Hour,
 			})
 		}
@@ -588,7 +981,8 @@ func newCORS(origins []string) gin.HandlerFunc {
 	return cors.New(cors.Config{
 		AllowOrigins:     origins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", middleware.ViewAsHeader, middleware.ViewAsCourseHeader},
+		ExposeHeaders:    []string{chatSessionHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	})
@@ -0,0 +1,187 @@
+// Package jobs runs background work on a bounded in-process worker pool with
+// per-task retries and a graceful drain on shutdown.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
+)
+
+var (
+	// ErrQueueFull indicates the queue is at capacity and the task was dropped.
+	ErrQueueFull = errors.New("job queue full")
+	// ErrQueueClosed indicates the queue is shutting down and accepts no new tasks.
+	ErrQueueClosed = errors.New("job queue closed")
+)
+
+// retryBackoff is the delay before the first retry; it doubles on each attempt.
+const retryBackoff = time.Second
+
+// Task is a unit of background work.
+type Task struct {
+	// Name identifies the task in logs.
+	Name string
+	// Run does the work. A returned error is retried until MaxAttempts,
+	// unless it is wrapped with Permanent.
+	Run func(ctx context.Context) error
+	// MaxAttempts is the total number of tries; zero means a single try.
+	MaxAttempts int
+	// Timeout bounds each attempt; zero means no per-attempt limit.
+	Timeout time.Duration
+}
+
+// permanentError marks a task failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the queue gives up on the task instead of retrying.
+// A nil err stays nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Queue is a bounded FIFO of tasks served by a fixed number of workers.
+type Queue struct {
+	tasks  chan Task
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+	// ctx is cancelled when Shutdown stops waiting, aborting in-flight tasks.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewQueue starts workers goroutines serving a queue that holds up to capacity pending tasks.
+func NewQueue(workers, capacity int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		tasks:  make(chan Task, capacity),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// Enqueue adds a task without blocking. It fails when the queue is full or closed.
+func (q *Queue) Enqueue(t Task) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.tasks <- t:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Depth returns the number of tasks waiting for a worker.
+func (q *Queue) Depth() int {
+	return len(q.tasks)
+}
+
+// Shutdown stops accepting tasks and waits for queued and running tasks to
+// finish. If ctx ends first, in-flight tasks are cancelled and ctx's error is
+// returned; tasks still queued are then dropped.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for t := range q.tasks {
+		if q.ctx.Err() != nil {
+			continue
+		}
+		q.run(t)
+	}
+}
+
+// run executes t, retrying failures with exponential backoff.
+func (q *Queue) run(t Task) {
+	maxAttempts := t.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	delay := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := q.attempt(t)
+		if err == nil {
+			return
+		}
+		var permanent *permanentError
+		if attempt >= maxAttempts || errors.As(err, &permanent) || q.ctx.Err() != nil {
+			logger.Log.Warn("background task failed",
+				slog.String("task", t.Name),
+				slog.Int("attempts", attempt),
+				slog.Any("error", err),
+			)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-q.ctx.Done():
+		}
+		delay *= 2
+	}
+}
+
+func (q *Queue) attempt(t Task) (err error) {
+	ctx := q.ctx
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return t.Run(ctx)
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue_RetriesUntilSuccess(t *testing.T) {
+	q := NewQueue(1, 4)
+	var calls atomic.Int32
+	done := make(chan struct{})
+	err := q.Enqueue(Task{
+		Name:        "flaky",
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			if calls.Add(1) < 2 {
+				return errors.New("transient")
+			}
+			close(done)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not succeed on retry")
+	}
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestQueue_ShutdownDrainsAndRejects(t *testing.T) {
+	q := NewQueue(1, 1)
+	release := make(chan struct{})
+	var ran atomic.Int32
+	block := Task{Name: "block", Run: func(ctx context.Context) error {
+		<-release
+		ran.Add(1)
+		return nil
+	}}
+	assert.NoError(t, q.Enqueue(block))
+	// Wait for the worker to pick up the first task so the second one queues.
+	assert.Eventually(t, func() bool { return q.Depth() == 0 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, q.Enqueue(Task{Name: "queued", Run: func(ctx context.Context) error {
+		ran.Add(1)
+		return nil
+	}}))
+	assert.ErrorIs(t, q.Enqueue(block), ErrQueueFull)
+	assert.Equal(t, 1, q.Depth())
+
+	close(release)
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.Equal(t, int32(2), ran.Load())
+	assert.ErrorIs(t, q.Enqueue(block), ErrQueueClosed)
+}
+
+func TestQueue_PermanentErrorIsNotRetried(t *testing.T) {
+	q := NewQueue(1, 4)
+	var calls atomic.Int32
+	assert.NoError(t, q.Enqueue(Task{
+		Name:        "denied",
+		MaxAttempts: 3,
+		Run: func(ctx context.Context) error {
+			calls.Add(1)
+			return Permanent(errors.New("access denied"))
+		},
+	}))
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.Equal(t, int32(1), calls.Load())
+	assert.NoError(t, Permanent(nil))
+}
@@ -1,24 +1,33 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
 )
 
-// UserContext stores authenticated user info in the request context.
+// UserContext stores authenticated user info in the request context. While a
+// teacher previews a course as a student, Role is "student", ActualRole holds
+// the real role and ViewAsCourseID the previewed course.
 type UserContext struct {
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	ID             uint   `json:"id"`
+	Username       string `json:"username"`
+	Role           string `json:"role"`
+	ActualRole     string `json:"actual_role,omitempty"`
+	ViewAsCourseID uint   `json:"view_as_course_id,omitempty"`
 }
 
 const userContextKey = "user"
 
-// AuthRequired validates the JWT and injects UserContext into the request.
-func AuthRequired(jwtSecret string) gin.HandlerFunc {
+// AuthRequired validates the JWT (signature, expiry, the configured
+// issuer/audience, and revocation) and injects UserContext into the request.
+// A ViewAsHeader on the request is applied here as well.
+func AuthRequired(tokens auth.TokenConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authz := c.GetHeader("Authorization")
 		if authz == "" {
@@ -32,16 +41,36 @@ func AuthRequired(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := auth.ParseToken(jwtSecret, tokenString)
+		claims, err := auth.ParseToken(tokens, tokenString)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
-		c.Set(userContextKey, UserContext{
+		if tokens.Sessions != nil {
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			revoked, err := tokens.Sessions.IsRevoked(c.Request.Context(), claims.UserID, issuedAt)
+			if err != nil {
+				logger.Log.Error("session revocation check failed", slog.Uint64("user_id", uint64(claims.UserID)), slog.Any("error", err))
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "session check unavailable"})
+				return
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+				return
+			}
+		}
+		u, ok := applyViewAs(c, tokens, UserContext{
 			ID:       claims.UserID,
 			Username: claims.Username,
 			Role:     claims.Role,
 		})
+		if !ok {
+			return
+		}
+		c.Set(userContextKey, u)
 		c.Next()
 	}
 }
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ctxKeyOriginalBody = "max_body_original"
+	ctxKeyBodyTooLarge = "max_body_exceeded"
+)
+
+// MaxBodyBytes caps the request body at limit bytes so an oversized payload
+// fails while it is read instead of being buffered whole. A body declared
+// too large by Content-Length fails on its first read, before any of it is
+// read; one that only turns out too large while read fails then. Either way
+// the request is marked, see BodyTooLarge, so the handler can answer 413.
+//
+// Applied again further down the chain, e.g. on a route, it replaces the
+// earlier limit rather than nesting inside it, so routes can raise the
+// global limit. The declared length is therefore only checked against the
+// limit in force when the body is read, never against one a later
+// MaxBodyBytes replaces. A non-positive limit removes it.
+func MaxBodyBytes(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		body := c.Request.Body
+		if original, ok := c.Get(ctxKeyOriginalBody); ok {
+			body = original.(io.ReadCloser)
+		} else {
+			c.Set(ctxKeyOriginalBody, body)
+		}
+		if limit <= 0 {
+			c.Request.Body = body
+			c.Next()
+			return
+		}
+		c.Request.Body = &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, body, limit), c: c, limit: limit}
+		c.Next()
+	}
+}
+
+// BodyTooLarge reports whether reading the request body hit the limit set by
+// MaxBodyBytes, and the limit, so a failed bind can be reported as such.
+func BodyTooLarge(c *gin.Context) (int64, bool) {
+	limit, ok := c.Get(ctxKeyBodyTooLarge)
+	if !ok {
+		return 0, false
+	}
+	return limit.(int64), true
+}
+
+// limitedBody records on the context when the wrapped reader hits its limit.
+type limitedBody struct {
+	io.ReadCloser
+	c     *gin.Context
+	limit int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.c.Request.ContentLength > b.limit {
+		b.c.Set(ctxKeyBodyTooLarge, b.limit)
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.c.Set(ctxKeyBodyTooLarge, b.limit)
+	}
+	return n, err
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBodyBytes_LimitsAndGroupOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaxBodyBytes(8))
+	read := func(c *gin.Context) {
+		b, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			limit, tooLarge := BodyTooLarge(c)
+			assert.True(t, tooLarge)
+			assert.Equal(t, int64(8), limit)
+			c.String(http.StatusBadRequest, "too large")
+			return
+		}
+		c.String(http.StatusOK, string(b))
+	}
+	r.POST("/small", read)
+	r.POST("/large", MaxBodyBytes(64), read)
+
+	do := func(path, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/small", "tiny", false)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "tiny", w.Body.String())
+
+	// A declared length over the limit fails the first read, before any of
+	// the body is read, and marks the request.
+	assert.Equal(t, http.StatusBadRequest, do("/small", strings.Repeat("x", 20), false).Code)
+
+	// Without a length the read fails once it passes the limit.
+	assert.Equal(t, http.StatusBadRequest, do("/small", strings.Repeat("x", 20), true).Code)
+
+	// The route's own limit replaces the global one instead of nesting in it,
+	// whether or not the length is declared.
+	for _, chunked := range []bool{false, true} {
+		w = do("/large", strings.Repeat("x", 20), chunked)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, w.Body.String(), 20)
+	}
+}
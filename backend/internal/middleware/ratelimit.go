@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -42,6 +43,33 @@ func (rl *RateLimiter) Allow(key string) bool {
 	return limiter.Allow()
 }
 
+// limitState describes a key's bucket after a request was let through or refused.
+type limitState struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration // until one token is available; zero when allowed
+	reset      time.Duration // until the bucket is full again
+}
+
+// take consumes a token for key if one is available and reports the bucket state.
+func (rl *RateLimiter) take(key string) limitState {
+	limiter := rl.getLimiter(key)
+	now := time.Now()
+	allowed := limiter.AllowN(now, 1)
+	tokens := limiter.TokensAt(now)
+
+	state := limitState{allowed: allowed, remaining: max(int(tokens), 0)}
+	if rl.rate == rate.Inf || rl.rate <= 0 {
+		return state
+	}
+	perToken := float64(time.Second) / float64(rl.rate)
+	state.reset = time.Duration((float64(rl.burst) - tokens) * perToken)
+	if !allowed {
+		state.retryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return state
+}
+
 func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
 	now := time.Now()
 
@@ -95,9 +123,22 @@ func rateLimitWithKey(rl *RateLimiter, keyFn func(*gin.Context) string) gin.Hand
 			key = c.ClientIP()
 		}
 
-		if !rl.Allow(key) {
+		state := rl.take(key)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rl.burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(state.remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(state.reset)))
+
+		if !state.allowed {
+			retryAfter := max(ceilSeconds(state.retryAfter), 1)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			// Same shape as the API's standard error envelope.
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": "rate limit exceeded",
+				"success": false,
+				"error": gin.H{
+					"code":    "RATE_LIMITED",
+					"message": "rate limit exceeded",
+					"details": gin.H{"retry_after_seconds": retryAfter},
+				},
 			})
 			return
 		}
@@ -105,3 +146,10 @@ func rateLimitWithKey(rl *RateLimiter, keyFn func(*gin.Context) string) gin.Hand
 		c.Next()
 	}
 }
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
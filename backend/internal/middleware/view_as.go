@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/auth"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
+)
+
+const (
+	// ViewAsHeader lets a teacher or admin preview a course as a student. The
+	// only accepted value is "student".
+	ViewAsHeader = "X-View-As"
+	// ViewAsCourseHeader names the course being previewed. The caller must
+	// manage it.
+	ViewAsCourseHeader = "X-View-As-Course"
+)
+
+// applyViewAs handles ViewAsHeader for an authenticated user. Without the
+// header the user is returned unchanged. Otherwise the request becomes a
+// read-only request with the student role, scoped to the previewed course, and
+// the user's real role is kept in ActualRole. It aborts the request and
+// returns false when the preview is not allowed.
+func applyViewAs(c *gin.Context, tokens auth.TokenConfig, u UserContext) (UserContext, bool) {
+	viewAs := c.GetHeader(ViewAsHeader)
+	if viewAs == "" {
+		return u, true
+	}
+	if viewAs != "student" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unsupported view-as role"})
+		return u, false
+	}
+	if u.Role != "admin" && u.Role != "teacher" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "view-as requires a teacher or admin"})
+		return u, false
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "view-as requests are read-only"})
+		return u, false
+	}
+	courseID, err := strconv.ParseUint(c.GetHeader(ViewAsCourseHeader), 10, 32)
+	if err != nil || courseID == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "view-as requires a course id"})
+		return u, false
+	}
+	if tokens.Courses == nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "view-as unavailable"})
+		return u, false
+	}
+	manages, err := tokens.Courses.ManagesCourse(c.Request.Context(), u.ID, u.Role, uint(courseID))
+	if err != nil {
+		logger.Log.Error("view-as course check failed", slog.Uint64("user_id", uint64(u.ID)), slog.Any("error", err))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "view-as check unavailable"})
+		return u, false
+	}
+	if !manages {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "view-as is limited to courses you manage"})
+		return u, false
+	}
+
+	logger.Log.Info("view-as active",
+		slog.Uint64("user_id", uint64(u.ID)),
+		slog.String("actual_role", u.Role),
+		slog.Uint64("course_id", courseID),
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+	)
+	c.Request = c.Request.WithContext(authz.WithViewAsCourse(c.Request.Context(), uint(courseID)))
+	u.ActualRole = u.Role
+	u.Role = "student"
+	u.ViewAsCourseID = uint(courseID)
+	return u, true
+}
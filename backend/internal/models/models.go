@@ -17,12 +17,34 @@ type User struct {
 	WecomUserID  string `gorm:"size:64;index" json:"wecom_user_id,omitempty"`
 }
 
+// SessionRevocation invalidates every token issued to a user at or before RevokedAt
+type SessionRevocation struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	RevokedAt time.Time `gorm:"not null" json:"revoked_at"`
+	Reason    string    `gorm:"size:64" json:"reason"` // logout, admin, user_updated, user_deleted
+}
+
+// AuditLog records a privileged action. Rows are only ever inserted, in the
+// same transaction as the action they describe.
+type AuditLog struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	ActorID    uint           `gorm:"not null;index" json:"actor_id"`
+	ActorRole  string         `gorm:"size:32" json:"actor_role"`
+	Action     string         `gorm:"size:64;not null;index" json:"action"`
+	TargetType string         `gorm:"size:32;not null" json:"target_type"`
+	TargetID   uint           `gorm:"not null" json:"target_id"`
+	Details    datatypes.JSON `gorm:"type:json" json:"details,omitempty"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+}
+
 // Course represents a course managed on the platform.
 type Course struct {
 	gorm.Model
 	Name           string         `gorm:"size:128;not null" json:"name"`
 	Code           string         `gorm:"size:64;index" json:"code,omitempty"`
 	Semester       string         `gorm:"size:64;index" json:"semester,omitempty"`
+	TimeZone       string         `gorm:"size:64" json:"time_zone,omitempty"` // IANA zone for deadlines, empty=UTC
 	TeacherID      uint           `gorm:"index" json:"teacher_id"`
 	EnabledModules datatypes.JSON `gorm:"type:json" json:"enabled_modules,omitempty"`
 	ModuleSettings datatypes.JSON `gorm:"type:json" json:"module_settings,omitempty"`
@@ -48,18 +70,103 @@ type Assignment struct {
 	Deadline    *time.Time `json:"deadline,omitempty"`
 	AllowFile   bool       `gorm:"default:true" json:"allow_file"`
 	MaxFileSize int64      `gorm:"default:10485760" json:"max_file_size"` // 10MB default
+	IsPublished bool       `gorm:"default:false" json:"is_published"`     // drafts are hidden from students
+	// GroupSubmission means one submission per student group, shared by all members
+	GroupSubmission bool `gorm:"default:false" json:"group_submission"`
+	// Late policy: AllowLate accepts submissions after the deadline, and each
+	// started day late takes LatePenaltyPerDay points off the grade, never
+	// below LatePenaltyFloor. The zero value rejects late work.
+	AllowLate         bool `gorm:"default:false" json:"allow_late"`
+	LatePenaltyPerDay int  `gorm:"default:0" json:"late_penalty_per_day"`
+	LatePenaltyFloor  int  `gorm:"default:0" json:"late_penalty_floor"`
+
+	Attachments []AssignmentAttachment `gorm:"foreignKey:AssignmentID" json:"attachments,omitempty"`
+
+	// EffectiveDeadline is the requesting student's deadline after extensions; not stored
+	EffectiveDeadline *time.Time `gorm:"-" json:"effective_deadline,omitempty"`
+}
+
+// AssignmentExtension overrides an assignment deadline for a single student
+type AssignmentExtension struct {
+	gorm.Model
+	AssignmentID uint      `gorm:"not null;uniqueIndex:idx_extension_assignment_student" json:"assignment_id"`
+	StudentID    uint      `gorm:"not null;uniqueIndex:idx_extension_assignment_student" json:"student_id"`
+	Deadline     time.Time `gorm:"not null" json:"deadline"`
+	GrantedByID  uint      `gorm:"not null" json:"granted_by_id"`
+}
+
+// SimilarityReport is the result of a text-similarity check across an assignment's submissions
+type SimilarityReport struct {
+	gorm.Model
+	AssignmentID    uint           `gorm:"not null;index" json:"assignment_id"`
+	RequestedByID   uint           `gorm:"not null" json:"requested_by_id"`
+	Status          string         `gorm:"size:16;not null" json:"status"` // pending, done, failed
+	Threshold       float64        `json:"threshold"`
+	SubmissionCount int            `json:"submission_count"`       // submissions compared
+	Truncated       bool           `json:"truncated"`              // more submissions existed than were compared
+	Pairs           datatypes.JSON `gorm:"type:json" json:"pairs"` // [{submission_a_id, student_a_id, submission_b_id, student_b_id, score}]
+	Error           string         `gorm:"size:512" json:"error,omitempty"`
+	CompletedAt     *time.Time     `json:"completed_at,omitempty"`
+}
+
+// AssignmentAttachment is a reference file (e.g. a PDF handout) attached to an assignment
+type AssignmentAttachment struct {
+	gorm.Model
+	AssignmentID uint   `gorm:"not null;index" json:"assignment_id"`
+	Title        string `gorm:"size:256;not null" json:"title"`
+	URL          string `gorm:"size:1024;not null" json:"url"`
 }
 
 // Submission represents a student's submission for an assignment
 type Submission struct {
 	gorm.Model
-	AssignmentID uint   `gorm:"not null;index;uniqueIndex:idx_assignment_student" json:"assignment_id"`
-	StudentID    uint   `gorm:"not null;index;uniqueIndex:idx_assignment_student" json:"student_id"`
+	AssignmentID uint   `gorm:"not null;index;uniqueIndex:idx_assignment_student;uniqueIndex:idx_assignment_group" json:"assignment_id"`
+	StudentID    uint   `gorm:"not null;index;uniqueIndex:idx_assignment_student" json:"student_id"` // the submitting member for group submissions
+	GroupID      *uint  `gorm:"index;uniqueIndex:idx_assignment_group" json:"group_id,omitempty"`    // set for group submissions; one per group
 	Content      string `gorm:"type:text" json:"content"`
 	FileURL      string `gorm:"size:512" json:"file_url,omitempty"`
-	Grade        *int   `json:"grade,omitempty"` // nil = not graded
+	Grade        *int   `json:"grade,omitempty"` // nil = not graded; after any late penalty
 	Feedback     string `gorm:"type:text" json:"feedback,omitempty"`
 	GradedBy     *uint  `json:"graded_by,omitempty"`
+	// Late work: LateAt is when the content last changed after the student's
+	// deadline, nil when on time. RawGrade is the grade as entered and
+	// LatePenalty the points the late policy took off it.
+	LateAt      *time.Time `json:"late_at,omitempty"`
+	RawGrade    *int       `json:"raw_grade,omitempty"`
+	LatePenalty int        `gorm:"default:0" json:"late_penalty"`
+	// AI grading suggestion, visible to course staff only.
+	AISuggestion     string     `gorm:"type:text" json:"ai_suggestion,omitempty"`
+	AISuggestedGrade *int       `json:"ai_suggested_grade,omitempty"`
+	AIGradedAt       *time.Time `json:"ai_graded_at,omitempty"`
+}
+
+// SubmissionComment is one message in the discussion of a submission between
+// the student and course staff
+type SubmissionComment struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	SubmissionID uint      `gorm:"not null;index" json:"submission_id"`
+	AuthorID     uint      `gorm:"not null" json:"author_id"`
+	Body         string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StudentGroup is a named set of students in a course that submits group
+// assignments together
+type StudentGroup struct {
+	gorm.Model
+	CourseID uint                 `gorm:"not null;index" json:"course_id"`
+	Name     string               `gorm:"size:128;not null" json:"name"`
+	Members  []StudentGroupMember `gorm:"foreignKey:GroupID" json:"members,omitempty"`
+}
+
+// StudentGroupMember places a student in a group; a student belongs to at
+// most one group per course
+type StudentGroupMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GroupID   uint      `gorm:"not null;index" json:"group_id"`
+	CourseID  uint      `gorm:"not null;uniqueIndex:idx_group_member_course_user" json:"course_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_group_member_course_user" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Resource represents a course resource (video, paper, link)
@@ -77,11 +184,12 @@ type Resource struct {
 // Chapter represents a chapter within a course
 type Chapter struct {
 	gorm.Model
-	CourseID        uint   `gorm:"not null;index" json:"course_id"`
-	Title           string `gorm:"size:256;not null" json:"title"`
-	OrderNum        int    `gorm:"index" json:"order_num"`                      // sort by (order_num, id)
-	Summary         string `gorm:"type:text" json:"summary,omitempty"`          // chapter summary
-	KnowledgePoints string `gorm:"type:text" json:"knowledge_points,omitempty"` // JSON array: ["知识点1", "知识点2"]
+	CourseID              uint   `gorm:"not null;index" json:"course_id"`
+	Title                 string `gorm:"size:256;not null" json:"title"`
+	OrderNum              int    `gorm:"index" json:"order_num"`                         // sort by (order_num, id)
+	Summary               string `gorm:"type:text" json:"summary,omitempty"`             // chapter summary
+	KnowledgePoints       string `gorm:"type:text" json:"knowledge_points,omitempty"`    // JSON array: ["知识点1", "知识点2"]
+	PrerequisiteChapterID *uint  `gorm:"index" json:"prerequisite_chapter_id,omitempty"` // opt-in gating: students must complete it first
 }
 
 // ChapterProgress tracks student's study time in a chapter
@@ -91,6 +199,11 @@ type ChapterProgress struct {
 	StudentID            uint       `gorm:"not null;uniqueIndex:idx_chapter_student" json:"student_id"`
 	StudyDurationSeconds int        `gorm:"default:0" json:"study_duration_seconds"`
 	LastActiveAt         *time.Time `json:"last_active_at,omitempty"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty"` // set when the student marks the chapter complete
+	// The current study session: when it started and the seconds credited
+	// in it. A session ends after the study session timeout without heartbeats.
+	SessionStartedAt *time.Time `json:"session_started_at,omitempty"`
+	SessionSeconds   int        `gorm:"default:0" json:"session_seconds"`
 }
 
 // Quiz represents an online quiz/test for a course
@@ -108,19 +221,26 @@ type Quiz struct {
 	ShowAnswerAfterEnd bool       `gorm:"default:true" json:"show_answer_after_end"` // show answers after EndTime
 	IsPublished        bool       `gorm:"default:false" json:"is_published"`         // published = questions locked
 	TotalPoints        int        `gorm:"default:0" json:"total_points"`             // sum of question points
+	NormalizeTo        int        `gorm:"default:0" json:"normalize_to"`             // report scores out of this total, 0=raw points
+	ScoreScale         float64    `gorm:"default:0" json:"score_scale"`              // NormalizeTo/TotalPoints, set on publish; 0=raw points
+	LeaderboardEnabled bool       `gorm:"default:false" json:"leaderboard_enabled"`  // students may view an anonymized leaderboard
+	RequireAllAnswered bool       `gorm:"default:false" json:"require_all_answered"` // reject submits with unanswered questions before the deadline
 }
 
 // Question represents a quiz question
 type Question struct {
 	gorm.Model
-	QuizID    uint   `gorm:"not null;index" json:"quiz_id"`
-	Type      string `gorm:"size:32;not null" json:"type"`                   // single_choice, multiple_choice, true_false, fill_blank
-	Content   string `gorm:"type:text;not null" json:"content"`              // question text
-	Options   string `gorm:"type:text" json:"options,omitempty"`             // JSON array: ["Option A", "Option B", ...]
-	Answer    string `gorm:"size:512;not null" json:"-"`                     // correct answer, hidden from students
-	MatchRule string `gorm:"size:32;default:'exact_trim'" json:"match_rule"` // exact, exact_trim, contains, regex (for fill_blank)
-	Points    int    `gorm:"default:1" json:"points"`                        // points for this question
-	OrderNum  int    `gorm:"default:0" json:"order_num"`                     // display order
+	QuizID        uint           `gorm:"not null;index" json:"quiz_id"`
+	Type          string         `gorm:"size:32;not null" json:"type"`                   // single_choice, multiple_choice, true_false, fill_blank, ordering, matching
+	Content       string         `gorm:"type:text;not null" json:"content"`              // question text
+	Options       string         `gorm:"type:text" json:"options,omitempty"`             // JSON array: ["Option A", "Option B", ...]
+	Answer        string         `gorm:"size:512;not null" json:"-"`                     // correct answer, hidden from students
+	MatchRule     string         `gorm:"size:32;default:'exact_trim'" json:"match_rule"` // exact, exact_trim, contains, regex (for fill_blank)
+	Points        int            `gorm:"default:1" json:"points"`                        // points for this question
+	OrderNum      int            `gorm:"default:0" json:"order_num"`                     // display order
+	ImageURL      string         `gorm:"size:1024" json:"image_url,omitempty"`           // optional diagram reference (http/https or MinIO signed URL), not hosted here
+	ContentFormat string         `gorm:"size:16;default:'plain'" json:"content_format"`  // how the frontend renders Content: plain, markdown, latex
+	Tags          datatypes.JSON `gorm:"type:json" json:"tags,omitempty"`                // JSON array of topic labels, e.g. ["gauss-law"]
 }
 
 // QuizAttempt represents a student's attempt at a quiz
@@ -136,6 +256,30 @@ type QuizAttempt struct {
 	Answers        string     `gorm:"type:text" json:"answers,omitempty"` // JSON: {"1": "A", "2": ["A","C"], ...}
 	Score          *int       `json:"score,omitempty"`                    // nil = not graded
 	MaxScore       int        `json:"max_score"`                          // total points at submission time
+	// Where the submission came from, for integrity reviews. Staff only.
+	SubmitIP        string `gorm:"size:45" json:"-"`
+	SubmitUserAgent string `gorm:"size:512" json:"-"`
+}
+
+// QuizAttemptGrant gives one student one extra attempt on a quiz beyond MaxAttempts
+type QuizAttemptGrant struct {
+	gorm.Model
+	QuizID      uint   `gorm:"not null;index:idx_attempt_grant_quiz_student" json:"quiz_id"`
+	StudentID   uint   `gorm:"not null;index:idx_attempt_grant_quiz_student" json:"student_id"`
+	GrantedByID uint   `gorm:"not null" json:"granted_by_id"`
+	Reason      string `gorm:"size:512" json:"reason"`
+}
+
+// QuizFeedback is a student's optional rating of a quiz after submitting an
+// attempt. There is at most one per attempt
+type QuizFeedback struct {
+	gorm.Model
+	AttemptID  uint   `gorm:"not null;uniqueIndex" json:"attempt_id"`
+	QuizID     uint   `gorm:"not null;index" json:"quiz_id"`
+	StudentID  uint   `gorm:"not null" json:"student_id"`
+	Difficulty int    `gorm:"not null" json:"difficulty"` // 1 (easy) to 5 (hard)
+	Confidence *int   `json:"confidence,omitempty"`       // 1 (guessing) to 5 (sure), optional
+	Comment    string `gorm:"type:text" json:"comment,omitempty"`
 }
 
 // Announcement represents a course announcement
@@ -145,6 +289,7 @@ type Announcement struct {
 	Title       string `gorm:"size:200;not null" json:"title"`
 	Content     string `gorm:"type:text;not null" json:"content"`
 	CreatedByID uint   `gorm:"not null" json:"created_by_id"`
+	Pinned      bool   `gorm:"default:false" json:"pinned"` // listed before unpinned announcements
 }
 
 // AnnouncementRead tracks which users have read which announcements
@@ -163,14 +308,14 @@ type AttendanceSession struct {
 	StartAt        time.Time `json:"start_at"`
 	EndAt          time.Time `json:"end_at"`
 	TimeoutMinutes int       `gorm:"default:15" json:"timeout_minutes"`
-	Code           string    `gorm:"size:6;not null" json:"code"`
+	Code           string    `gorm:"size:16;not null" json:"code"`
 	IsActive       bool      `gorm:"default:true;index" json:"is_active"`
 }
 
 // AttendanceRecord represents a student's check-in for a session
 type AttendanceRecord struct {
 	gorm.Model
-	SessionID   uint      `gorm:"not null;index:idx_attendance_record_session" json:"session_id"`
+	SessionID   uint      `gorm:"not null;index:idx_attendance_record_session;uniqueIndex:idx_session_student" json:"session_id"`
 	StudentID   uint      `gorm:"not null;uniqueIndex:idx_session_student" json:"student_id"`
 	CheckedInAt time.Time `json:"checked_in_at"`
 	IPAddress   string    `gorm:"size:45" json:"ip_address"`
@@ -203,7 +348,7 @@ type LearningEvent struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	StudentID uint      `gorm:"not null;index:idx_learning_event_student_time" json:"student_id"`
 	CourseID  *uint     `gorm:"index:idx_learning_event_course_time" json:"course_id,omitempty"`
-	EventType string    `gorm:"size:32;not null" json:"event_type"` // chat, quiz_submit, assignment_submit, heartbeat, writing_submit
+	EventType string    `gorm:"size:32;not null" json:"event_type"` // chat, quiz_submit, assignment_submit, heartbeat, writing_submit, session_end
 	Payload   string    `gorm:"type:text" json:"payload"`           // JSON: event-specific data
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
@@ -220,4 +365,25 @@ type WritingSubmission struct {
 	WordCount     int    `gorm:"default:0" json:"word_count"`
 	FeedbackJSON  string `gorm:"type:text" json:"feedback_json,omitempty"`  // AI-generated feedback
 	DimensionJSON string `gorm:"type:text" json:"dimension_json,omitempty"` // Multi-dimension scores
+	Draft         bool   `gorm:"not null;default:false;index" json:"draft"` // hidden from teachers and not analyzed until finalized
+}
+
+// ChatTranscript is one stored AI chat conversation, keyed by the session id
+// the client sends with each turn
+type ChatTranscript struct {
+	gorm.Model
+	SessionKey   string `gorm:"size:64;not null;uniqueIndex" json:"session_id"`
+	UserID       uint   `gorm:"not null;index" json:"user_id"`
+	CourseID     *uint  `gorm:"index" json:"course_id,omitempty"`
+	Mode         string `gorm:"size:64" json:"mode"`
+	MessageCount int    `gorm:"default:0" json:"message_count"`
+	Truncated    bool   `gorm:"default:false" json:"truncated"` // later messages were dropped once the size limit was hit
+}
+
+// ChatTranscriptMessage is a single user, assistant or system message in a transcript
+type ChatTranscriptMessage struct {
+	gorm.Model
+	TranscriptID uint   `gorm:"not null;index" json:"transcript_id"`
+	Role         string `gorm:"size:16;not null" json:"role"` // user, assistant, system
+	Content      string `gorm:"type:text" json:"content"`
 }
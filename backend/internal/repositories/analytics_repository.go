@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// AnalyticsRepository aggregates activity across all courses. Every range
+// is [from, to), and every query counts or averages in the database.
+type AnalyticsRepository struct {
+	db *gorm.DB
+}
+
+func NewAnalyticsRepository(db *gorm.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db}
+}
+
+func (r *AnalyticsRepository) CountCourses(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Course{}).Count(&count).Error
+	return count, err
+}
+
+func (r *AnalyticsRepository) CountUsersByRole(ctx context.Context, role string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+// CountActiveCourses counts courses with a new submission, a submitted quiz
+// attempt or an attendance session in the range.
+func (r *AnalyticsRepository) CountActiveCourses(ctx context.Context, from, to time.Time) (int64, error) {
+	db := r.db.WithContext(ctx)
+	submissions := db.Table("submissions").
+		Select("assignments.course_id").
+		Joins("JOIN assignments ON assignments.id = submissions.assignment_id").
+		Where("submissions.deleted_at IS NULL AND submissions.created_at >= ? AND submissions.created_at < ?", from, to)
+	attempts := db.Table("quiz_attempts").
+		Select("quizzes.course_id").
+		Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id").
+		Where("quiz_attempts.deleted_at IS NULL AND quiz_attempts.submitted_at >= ? AND quiz_attempts.submitted_at < ?", from, to)
+	sessions := db.Table("attendance_sessions").
+		Select("course_id").
+		Where("deleted_at IS NULL AND start_at >= ? AND start_at < ?", from, to)
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM (? UNION ? UNION ?) AS active", submissions, attempts, sessions).Scan(&count).Error
+	return count, err
+}
+
+// CountActiveStudents counts students who submitted work, submitted a quiz
+// attempt or checked in during the range.
+func (r *AnalyticsRepository) CountActiveStudents(ctx context.Context, from, to time.Time) (int64, error) {
+	db := r.db.WithContext(ctx)
+	submissions := db.Table("submissions").
+		Select("student_id").
+		Where("deleted_at IS NULL AND created_at >= ? AND created_at < ?", from, to)
+	attempts := db.Table("quiz_attempts").
+		Select("student_id").
+		Where("deleted_at IS NULL AND submitted_at >= ? AND submitted_at < ?", from, to)
+	checkIns := db.Table("attendance_records").
+		Select("student_id").
+		Where("deleted_at IS NULL AND checked_in_at >= ? AND checked_in_at < ?", from, to)
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM (? UNION ? UNION ?) AS active", submissions, attempts, checkIns).Scan(&count).Error
+	return count, err
+}
+
+func (r *AnalyticsRepository) CountSubmissions(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Submission{}).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&count).Error
+	return count, err
+}
+
+// QuizScoreStats counts the graded attempts submitted in the range and
+// averages their scores as a percentage of the attempt's maximum. The
+// average is nil without graded attempts.
+func (r *AnalyticsRepository) QuizScoreStats(ctx context.Context, from, to time.Time) (int64, *float64, error) {
+	var row struct {
+		AttemptCount int64
+		AvgPercent   *float64
+	}
+	err := r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Select("COUNT(*) AS attempt_count, AVG(score * 100.0 / max_score) AS avg_percent").
+		Where("submitted_at >= ? AND submitted_at < ? AND score IS NOT NULL AND max_score > 0", from, to).
+		Scan(&row).Error
+	return row.AttemptCount, row.AvgPercent, err
+}
+
+// AttendanceTotals returns, for the sessions started in the range, how many
+// check-ins were expected (sessions times enrolled students of the course)
+// and how many were made.
+func (r *AnalyticsRepository) AttendanceTotals(ctx context.Context, from, to time.Time) (int64, int64, error) {
+	var expected, attended int64
+	err := r.db.WithContext(ctx).
+		Model(&models.AttendanceSession{}).
+		Joins("JOIN course_enrollments ON course_enrollments.course_id = attendance_sessions.course_id AND course_enrollments.role = 'student' AND course_enrollments.deleted_at IS NULL").
+		Where("attendance_sessions.start_at >= ? AND attendance_sessions.start_at < ?", from, to).
+		Count(&expected).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	err = r.db.WithContext(ctx).
+		Model(&models.AttendanceRecord{}).
+		Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id AND attendance_sessions.deleted_at IS NULL").
+		Where("attendance_sessions.start_at >= ? AND attendance_sessions.start_at < ?", from, to).
+		Count(&attended).Error
+	return expected, attended, err
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AnnouncementRepository struct {
+	db *gorm.DB
+}
+
+func NewAnnouncementRepository(db *gorm.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db}
+}
+
+// likeEscaper escapes LIKE wildcards with '!', which needs no quoting in
+// either MySQL or SQLite.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func (r *AnnouncementRepository) SearchByCourse(ctx context.Context, courseID uint, query string, offset, limit int) ([]models.Announcement, int64, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	matching := func() *gorm.DB {
+		return r.db.WithContext(ctx).
+			Model(&models.Announcement{}).
+			Where("course_id = ?", courseID).
+			Where("title LIKE ? ESCAPE '!' OR content LIKE ? ESCAPE '!'", pattern, pattern)
+	}
+
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var announcements []models.Announcement
+	if err := matching().
+		Order("pinned DESC, created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&announcements).Error; err != nil {
+		return nil, 0, err
+	}
+	return announcements, total, nil
+}
+
+func (r *AnnouncementRepository) ListPageByCourse(ctx context.Context, courseID uint, offset, limit int) ([]models.Announcement, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&models.Announcement{}).Where("course_id = ?", courseID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var announcements []models.Announcement
+	if err := r.db.WithContext(ctx).
+		Where("course_id = ?", courseID).
+		Order("pinned DESC, created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&announcements).Error; err != nil {
+		return nil, 0, err
+	}
+	return announcements, total, nil
+}
+
+func (r *AnnouncementRepository) CountUnreadByCourse(ctx context.Context, courseID uint, userID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.Announcement{}).
+		Where("course_id = ?", courseID).
+		Where("id NOT IN (?)", r.db.Model(&models.AnnouncementRead{}).Select("announcement_id").Where("user_id = ?", userID)).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkAllReadByCourse records a read for every announcement in the course the
+// user has not read yet and returns how many were newly marked. Reads that
+// already exist, including ones racing in, are left alone.
+func (r *AnnouncementRepository) MarkAllReadByCourse(ctx context.Context, courseID uint, userID uint, readAt time.Time) (int64, error) {
+	var marked int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&models.Announcement{}).
+			Where("course_id = ?", courseID).
+			Where("id NOT IN (?)", tx.Model(&models.AnnouncementRead{}).Select("announcement_id").Where("user_id = ?", userID)).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		reads := make([]models.AnnouncementRead, len(ids))
+		for i, id := range ids {
+			reads[i] = models.AnnouncementRead{AnnouncementID: id, UserID: userID, ReadAt: readAt}
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reads)
+		marked = result.RowsAffected
+		return result.Error
+	})
+	return marked, err
+}
+
+func (r *AnnouncementRepository) ListReadIDs(ctx context.Context, userID uint, announcementIDs []uint) ([]uint, error) {
+	if len(announcementIDs) == 0 {
+		return nil, nil
+	}
+	var ids []uint
+	err := r.db.WithContext(ctx).
+		Model(&models.AnnouncementRead{}).
+		Where("announcement_id IN ? AND user_id = ?", announcementIDs, userID).
+		Pluck("announcement_id", &ids).Error
+	return ids, err
+}
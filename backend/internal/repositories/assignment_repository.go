@@ -2,9 +2,13 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AssignmentRepository struct {
@@ -15,6 +19,18 @@ func NewAssignmentRepository(db *gorm.DB) *AssignmentRepository {
 	return &AssignmentRepository{db: db}
 }
 
+func (r *AssignmentRepository) Transaction(ctx context.Context, fn func(tx *AssignmentRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&AssignmentRepository{db: tx})
+	})
+}
+
+// Audit returns an audit repository on the same connection, so audit entries
+// written inside Transaction commit or roll back with the action.
+func (r *AssignmentRepository) Audit() *AuditRepository {
+	return &AuditRepository{db: r.db}
+}
+
 func (r *AssignmentRepository) FindCourse(ctx context.Context, courseID uint) (*models.Course, error) {
 	var course models.Course
 	if err := r.db.WithContext(ctx).First(&course, courseID).Error; err != nil {
@@ -23,6 +39,33 @@ func (r *AssignmentRepository) FindCourse(ctx context.Context, courseID uint) (*
 	return &course, nil
 }
 
+func (r *AssignmentRepository) FindChapter(ctx context.Context, chapterID uint) (*models.Chapter, error) {
+	var chapter models.Chapter
+	if err := r.db.WithContext(ctx).First(&chapter, chapterID).Error; err != nil {
+		return nil, err
+	}
+	return &chapter, nil
+}
+
+func (r *AssignmentRepository) FindUser(ctx context.Context, userID uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *AssignmentRepository) ListUsers(ctx context.Context, userIDs []uint) ([]models.User, error) {
+	var users []models.User
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *AssignmentRepository) FindAssignment(ctx context.Context, assignmentID uint) (*models.Assignment, error) {
 	var assignment models.Assignment
 	if err := r.db.WithContext(ctx).First(&assignment, assignmentID).Error; err != nil {
@@ -31,18 +74,67 @@ func (r *AssignmentRepository) FindAssignment(ctx context.Context, assignmentID
 	return &assignment, nil
 }
 
+func (r *AssignmentRepository) FindAssignmentWithAttachments(ctx context.Context, assignmentID uint) (*models.Assignment, error) {
+	var assignment models.Assignment
+	if err := r.db.WithContext(ctx).Preload("Attachments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&assignment, assignmentID).Error; err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+func (r *AssignmentRepository) CreateAttachment(ctx context.Context, attachment *models.AssignmentAttachment) error {
+	return r.db.WithContext(ctx).Create(attachment).Error
+}
+
+func (r *AssignmentRepository) FindAttachment(ctx context.Context, assignmentID, attachmentID uint) (*models.AssignmentAttachment, error) {
+	var attachment models.AssignmentAttachment
+	if err := r.db.WithContext(ctx).Where("assignment_id = ?", assignmentID).First(&attachment, attachmentID).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *AssignmentRepository) DeleteAttachment(ctx context.Context, attachment *models.AssignmentAttachment) error {
+	return r.db.WithContext(ctx).Delete(attachment).Error
+}
+
 func (r *AssignmentRepository) CreateAssignment(ctx context.Context, assignment *models.Assignment) error {
 	return r.db.WithContext(ctx).Create(assignment).Error
 }
 
-func (r *AssignmentRepository) ListByCourse(ctx context.Context, courseID uint) ([]models.Assignment, error) {
+func (r *AssignmentRepository) ListByCourse(ctx context.Context, courseID uint, publishedOnly bool) ([]models.Assignment, error) {
+	db := r.db.WithContext(ctx).Where("course_id = ?", courseID).Order("created_at DESC")
+	if publishedOnly {
+		db = db.Where("is_published = ?", true)
+	}
 	var assignments []models.Assignment
-	if err := r.db.WithContext(ctx).Where("course_id = ?", courseID).Order("created_at DESC").Find(&assignments).Error; err != nil {
+	if err := db.Find(&assignments).Error; err != nil {
 		return nil, err
 	}
 	return assignments, nil
 }
 
+func (r *AssignmentRepository) ListPageByCourse(ctx context.Context, courseID uint, publishedOnly bool, offset, limit int) ([]models.Assignment, int64, error) {
+	scope := func() *gorm.DB {
+		db := r.db.WithContext(ctx).Model(&models.Assignment{}).Where("course_id = ?", courseID)
+		if publishedOnly {
+			db = db.Where("is_published = ?", true)
+		}
+		return db
+	}
+	var total int64
+	if err := scope().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var assignments []models.Assignment
+	if err := scope().Order("created_at DESC").Offset(offset).Limit(limit).Find(&assignments).Error; err != nil {
+		return nil, 0, err
+	}
+	return assignments, total, nil
+}
+
 func (r *AssignmentRepository) FindSubmission(ctx context.Context, assignmentID uint, studentID uint) (*models.Submission, error) {
 	var submission models.Submission
 	if err := r.db.WithContext(ctx).Where("assignment_id = ? AND student_id = ?", assignmentID, studentID).First(&submission).Error; err != nil {
@@ -63,6 +155,12 @@ func (r *AssignmentRepository) SaveSubmission(ctx context.Context, submission *m
 	return r.db.WithContext(ctx).Save(submission).Error
 }
 
+// UpdateSubmissionFields writes only the given columns, leaving changes made
+// to the rest of the row since it was loaded in place.
+func (r *AssignmentRepository) UpdateSubmissionFields(ctx context.Context, submissionID uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Model(&models.Submission{Model: gorm.Model{ID: submissionID}}).Updates(updates).Error
+}
+
 func (r *AssignmentRepository) CreateSubmission(ctx context.Context, submission *models.Submission) error {
 	return r.db.WithContext(ctx).Create(submission).Error
 }
@@ -75,6 +173,21 @@ func (r *AssignmentRepository) ListSubmissionsByAssignment(ctx context.Context,
 	return submissions, nil
 }
 
+func (r *AssignmentRepository) CreateSubmissionComment(ctx context.Context, comment *models.SubmissionComment) error {
+	return r.db.WithContext(ctx).Create(comment).Error
+}
+
+func (r *AssignmentRepository) ListSubmissionComments(ctx context.Context, submissionID uint) ([]models.SubmissionComment, error) {
+	var comments []models.SubmissionComment
+	if err := r.db.WithContext(ctx).
+		Where("submission_id = ?", submissionID).
+		Order("created_at ASC, id ASC").
+		Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
 func (r *AssignmentRepository) CountAssignmentsByCourse(ctx context.Context, courseID uint) (int64, error) {
 	var count int64
 	if err := r.db.WithContext(ctx).Model(&models.Assignment{}).Where("course_id = ?", courseID).Count(&count).Error; err != nil {
@@ -88,7 +201,8 @@ func (r *AssignmentRepository) CountSubmissionsByCourseAndStudent(ctx context.Co
 	err := r.db.WithContext(ctx).
 		Table("submissions").
 		Joins("JOIN assignments ON submissions.assignment_id = assignments.id").
-		Where("assignments.course_id = ? AND submissions.student_id = ?", courseID, studentID).
+		Where("assignments.course_id = ?", courseID).
+		Where(r.studentSubmissions(studentID)).
 		Count(&count).Error
 	if err != nil {
 		return 0, err
@@ -96,6 +210,46 @@ func (r *AssignmentRepository) CountSubmissionsByCourseAndStudent(ctx context.Co
 	return count, nil
 }
 
+// CountDueByCourse counts the course's published assignments whose deadline
+// has passed by now.
+func (r *AssignmentRepository) CountDueByCourse(ctx context.Context, courseID uint, now time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Assignment{}).
+		Where("course_id = ? AND is_published = ? AND deadline <= ?", courseID, true, now).
+		Count(&count).Error
+	return count, err
+}
+
+// CountSubmittedDueByStudents counts, per student, the assignments counted by
+// CountDueByCourse that they submitted, themselves or through their group.
+func (r *AssignmentRepository) CountSubmittedDueByStudents(ctx context.Context, courseID uint, now time.Time) (map[uint]int64, error) {
+	db := r.db.WithContext(ctx)
+	due := db.Model(&models.Assignment{}).
+		Select("id").
+		Where("course_id = ? AND is_published = ? AND deadline <= ?", courseID, true, now)
+	own := db.Table("submissions").
+		Select("student_id, assignment_id").
+		Where("deleted_at IS NULL AND assignment_id IN (?)", due)
+	viaGroup := db.Table("submissions").
+		Select("student_group_members.user_id AS student_id, submissions.assignment_id AS assignment_id").
+		Joins("JOIN student_group_members ON student_group_members.group_id = submissions.group_id").
+		Where("submissions.deleted_at IS NULL AND submissions.assignment_id IN (?)", due)
+	var rows []struct {
+		StudentID uint
+		Count     int64
+	}
+	if err := db.Raw("SELECT student_id, COUNT(*) AS count FROM (? UNION ?) AS submitted GROUP BY student_id", own, viaGroup).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.StudentID] = row.Count
+	}
+	return counts, nil
+}
+
 func (r *AssignmentRepository) CountPendingGradingByCourse(ctx context.Context, courseID uint) (int64, error) {
 	var count int64
 	err := r.db.WithContext(ctx).
@@ -109,12 +263,65 @@ func (r *AssignmentRepository) CountPendingGradingByCourse(ctx context.Context,
 	return count, nil
 }
 
+func (r *AssignmentRepository) CountPendingGradingByCourses(ctx context.Context, courseIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		CourseID uint
+		Count    int64
+	}
+	if err := r.db.WithContext(ctx).
+		Table("submissions").
+		Select("assignments.course_id AS course_id, COUNT(*) AS count").
+		Joins("JOIN assignments ON submissions.assignment_id = assignments.id").
+		Where("assignments.course_id IN ? AND submissions.grade IS NULL", courseIDs).
+		Where("submissions.deleted_at IS NULL AND assignments.deleted_at IS NULL").
+		Group("assignments.course_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.CourseID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *AssignmentRepository) CountGradedSubmissionsByCourseAndStudent(ctx context.Context, courseID uint, studentID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("submissions").
+		Joins("JOIN assignments ON submissions.assignment_id = assignments.id").
+		Where("assignments.course_id = ? AND submissions.grade IS NOT NULL", courseID).
+		Where(r.studentSubmissions(studentID)).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *AssignmentRepository) CountGradedSubmissionsByCourse(ctx context.Context, courseID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("submissions").
+		Joins("JOIN assignments ON submissions.assignment_id = assignments.id").
+		Where("assignments.course_id = ? AND submissions.grade IS NOT NULL", courseID).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (r *AssignmentRepository) AvgGradeByCourseAndStudent(ctx context.Context, courseID uint, studentID uint) (float64, error) {
 	var avg float64
 	err := r.db.WithContext(ctx).
 		Table("submissions").
 		Joins("JOIN assignments ON submissions.assignment_id = assignments.id").
-		Where("assignments.course_id = ? AND submissions.student_id = ? AND submissions.grade IS NOT NULL", courseID, studentID).
+		Where("assignments.course_id = ? AND submissions.grade IS NOT NULL", courseID).
+		Where(r.studentSubmissions(studentID)).
 		Select("AVG(submissions.grade)").
 		Row().
 		Scan(&avg)
@@ -163,3 +370,238 @@ func (r *AssignmentRepository) HasEnrollment(ctx context.Context, courseID uint,
 	}
 	return false, err
 }
+
+func (r *AssignmentRepository) SaveAssignment(ctx context.Context, assignment *models.Assignment) error {
+	return r.db.WithContext(ctx).Save(assignment).Error
+}
+
+// UpdateAssignmentChapter sets only the assignment's chapter_id; a nil
+// chapterID clears it.
+func (r *AssignmentRepository) UpdateAssignmentChapter(ctx context.Context, assignment *models.Assignment, chapterID *uint) error {
+	return r.db.WithContext(ctx).Model(assignment).Update("chapter_id", chapterID).Error
+}
+
+func (r *AssignmentRepository) CountSubmissionsByAssignment(ctx context.Context, assignmentID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Submission{}).Where("assignment_id = ?", assignmentID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *AssignmentRepository) ListDueByCourses(ctx context.Context, courseIDs []uint, from, to time.Time) ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	if len(courseIDs) == 0 {
+		return assignments, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("course_id IN ? AND is_published = ? AND deadline > ? AND deadline <= ?", courseIDs, true, from, to).
+		Order("deadline ASC").
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+func (r *AssignmentRepository) ListOpenByCourses(ctx context.Context, courseIDs []uint, now time.Time) ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	if len(courseIDs) == 0 {
+		return assignments, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("course_id IN ? AND is_published = ? AND (deadline IS NULL OR deadline > ?)", courseIDs, true, now).
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+func (r *AssignmentRepository) ListSubmittedAssignmentIDs(ctx context.Context, studentID uint, assignmentIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(assignmentIDs) == 0 {
+		return ids, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.Submission{}).
+		Where("assignment_id IN ?", assignmentIDs).
+		Where(r.studentSubmissions(studentID)).
+		Distinct().
+		Pluck("assignment_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *AssignmentRepository) FindExtension(ctx context.Context, assignmentID uint, studentID uint) (*models.AssignmentExtension, error) {
+	var extension models.AssignmentExtension
+	if err := r.db.WithContext(ctx).Where("assignment_id = ? AND student_id = ?", assignmentID, studentID).First(&extension).Error; err != nil {
+		return nil, err
+	}
+	return &extension, nil
+}
+
+func (r *AssignmentRepository) UpsertExtension(ctx context.Context, extension *models.AssignmentExtension) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "assignment_id"}, {Name: "student_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"deadline", "granted_by_id", "updated_at"}),
+	}).Create(extension).Error
+}
+
+func (r *AssignmentRepository) DeleteExtension(ctx context.Context, assignmentID uint, studentID uint) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("assignment_id = ? AND student_id = ?", assignmentID, studentID).
+		Delete(&models.AssignmentExtension{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *AssignmentRepository) CreateSimilarityReport(ctx context.Context, report *models.SimilarityReport) error {
+	return r.db.WithContext(ctx).Create(report).Error
+}
+
+func (r *AssignmentRepository) UpdateSimilarityReport(ctx context.Context, report *models.SimilarityReport, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(report).Updates(updates).Error
+}
+
+func (r *AssignmentRepository) FindLatestSimilarityReport(ctx context.Context, assignmentID uint) (*models.SimilarityReport, error) {
+	var report models.SimilarityReport
+	if err := r.db.WithContext(ctx).
+		Where("assignment_id = ?", assignmentID).
+		Order("id DESC").
+		First(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// studentSubmissions matches the student's own submissions and those of the
+// groups they belong to, so group members share one submission. It matches by
+// submission id, so a submission that is both the student's own and their
+// group's counts once whatever the caller joins it with.
+func (r *AssignmentRepository) studentSubmissions(studentID uint) *gorm.DB {
+	groupIDs := r.db.Model(&models.StudentGroupMember{}).Select("group_id").Where("user_id = ?", studentID)
+	ids := r.db.Model(&models.Submission{}).
+		Select("id").
+		Where("student_id = ?", studentID).
+		Or("group_id IN (?)", groupIDs)
+	return r.db.Where("submissions.id IN (?)", ids)
+}
+
+// IsDuplicateKey reports whether err is a unique index violation.
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Duplicate entry") || strings.Contains(msg, "UNIQUE constraint failed")
+}
+
+func (r *AssignmentRepository) FindGroupSubmission(ctx context.Context, assignmentID uint, groupID uint) (*models.Submission, error) {
+	var submission models.Submission
+	if err := r.db.WithContext(ctx).Where("assignment_id = ? AND group_id = ?", assignmentID, groupID).First(&submission).Error; err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+func (r *AssignmentRepository) FindGroup(ctx context.Context, groupID uint) (*models.StudentGroup, error) {
+	var group models.StudentGroup
+	if err := r.db.WithContext(ctx).Preload("Members").First(&group, groupID).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *AssignmentRepository) FindGroupByMember(ctx context.Context, courseID uint, userID uint) (*models.StudentGroup, error) {
+	var member models.StudentGroupMember
+	if err := r.db.WithContext(ctx).Where("course_id = ? AND user_id = ?", courseID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return r.FindGroup(ctx, member.GroupID)
+}
+
+func (r *AssignmentRepository) ListGroupsByCourse(ctx context.Context, courseID uint) ([]models.StudentGroup, error) {
+	var groups []models.StudentGroup
+	if err := r.db.WithContext(ctx).Preload("Members").Where("course_id = ?", courseID).Order("id ASC").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (r *AssignmentRepository) ListGroupMembers(ctx context.Context, groupIDs []uint) ([]models.StudentGroupMember, error) {
+	var members []models.StudentGroupMember
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+	if err := r.db.WithContext(ctx).Where("group_id IN ?", groupIDs).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *AssignmentRepository) ListGroupMembersInOtherGroups(ctx context.Context, courseID uint, userIDs []uint, groupID uint) ([]models.StudentGroupMember, error) {
+	var members []models.StudentGroupMember
+	if len(userIDs) == 0 {
+		return members, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("course_id = ? AND user_id IN ? AND group_id <> ?", courseID, userIDs, groupID).
+		Order("user_id ASC").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *AssignmentRepository) ListEnrolledStudentIDs(ctx context.Context, courseID uint, userIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(userIDs) == 0 {
+		return ids, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND role = 'student' AND user_id IN ?", courseID, userIDs).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *AssignmentRepository) CreateGroup(ctx context.Context, group *models.StudentGroup) error {
+	return r.db.WithContext(ctx).Omit("Members").Create(group).Error
+}
+
+func (r *AssignmentRepository) ReplaceGroupMembers(ctx context.Context, group *models.StudentGroup, userIDs []uint) error {
+	if err := r.db.WithContext(ctx).Where("group_id = ?", group.ID).Delete(&models.StudentGroupMember{}).Error; err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	members := make([]models.StudentGroupMember, 0, len(userIDs))
+	for _, id := range userIDs {
+		members = append(members, models.StudentGroupMember{GroupID: group.ID, CourseID: group.CourseID, UserID: id})
+	}
+	return r.db.WithContext(ctx).Create(&members).Error
+}
+
+func (r *AssignmentRepository) UpdateGroupName(ctx context.Context, group *models.StudentGroup) error {
+	return r.db.WithContext(ctx).Model(group).Update("name", group.Name).Error
+}
+
+func (r *AssignmentRepository) DeleteGroup(ctx context.Context, group *models.StudentGroup) error {
+	if err := r.db.WithContext(ctx).Where("group_id = ?", group.ID).Delete(&models.StudentGroupMember{}).Error; err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Delete(group).Error
+}
+
+func (r *AssignmentRepository) CountSubmissionsByGroup(ctx context.Context, groupID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Submission{}).Where("group_id = ?", groupID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type AttendanceRepository struct {
+	db *gorm.DB
+}
+
+func NewAttendanceRepository(db *gorm.DB) *AttendanceRepository {
+	return &AttendanceRepository{db: db}
+}
+
+func (r *AttendanceRepository) CountSessionsByCourses(ctx context.Context, courseIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		CourseID uint
+		Count    int
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.AttendanceSession{}).
+		Select("course_id, COUNT(*) AS count").
+		Where("course_id IN ?", courseIDs).
+		Group("course_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.CourseID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *AttendanceRepository) CountAttendedByCourses(ctx context.Context, studentID uint, courseIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		CourseID uint
+		Count    int
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.AttendanceRecord{}).
+		Select("attendance_sessions.course_id AS course_id, COUNT(*) AS count").
+		Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id").
+		Where("attendance_records.student_id = ? AND attendance_sessions.course_id IN ? AND attendance_sessions.deleted_at IS NULL", studentID, courseIDs).
+		Group("attendance_sessions.course_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.CourseID] = row.Count
+	}
+	return counts, nil
+}
+
+// CountAttendedByStudents counts each student's check-ins to the course's
+// sessions, keyed by student.
+func (r *AttendanceRepository) CountAttendedByStudents(ctx context.Context, courseID uint) (map[uint]int, error) {
+	var rows []struct {
+		StudentID uint
+		Count     int
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.AttendanceRecord{}).
+		Select("attendance_records.student_id AS student_id, COUNT(*) AS count").
+		Joins("JOIN attendance_sessions ON attendance_sessions.id = attendance_records.session_id").
+		Where("attendance_sessions.course_id = ? AND attendance_sessions.deleted_at IS NULL", courseID).
+		Group("attendance_records.student_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.StudentID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *AttendanceRepository) ListActiveSessionsByCourses(ctx context.Context, courseIDs []uint, now time.Time) ([]models.AttendanceSession, error) {
+	var sessions []models.AttendanceSession
+	if len(courseIDs) == 0 {
+		return sessions, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("course_id IN ? AND is_active = ? AND end_at > ?", courseIDs, true, now).
+		Order("start_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
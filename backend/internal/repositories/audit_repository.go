@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditLogFilter narrows an audit log listing. Zero fields match everything;
+// From is inclusive and To exclusive.
+type AuditLogFilter struct {
+	ActorID uint
+	Action  string
+	From    *time.Time
+	To      *time.Time
+}
+
+func (r *AuditRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r *AuditRepository) List(ctx context.Context, filter AuditLogFilter, offset, limit int) ([]models.AuditLog, int64, error) {
+	matching := func() *gorm.DB {
+		query := r.db.WithContext(ctx).Model(&models.AuditLog{})
+		if filter.ActorID != 0 {
+			query = query.Where("actor_id = ?", filter.ActorID)
+		}
+		if filter.Action != "" {
+			query = query.Where("action = ?", filter.Action)
+		}
+		if filter.From != nil {
+			query = query.Where("created_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			query = query.Where("created_at < ?", *filter.To)
+		}
+		return query
+	}
+
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []models.AuditLog
+	if err := matching().
+		Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
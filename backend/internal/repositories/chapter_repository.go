@@ -7,6 +7,17 @@ import (
 	"gorm.io/gorm"
 )
 
+type ChapterStudyTime struct {
+	ChapterID uint
+	Seconds   int
+}
+
+type StudentGradeTotal struct {
+	StudentID uint
+	Total     int
+	Count     int
+}
+
 type ChapterRepository struct {
 	db *gorm.DB
 }
@@ -15,6 +26,12 @@ func NewChapterRepository(db *gorm.DB) *ChapterRepository {
 	return &ChapterRepository{db: db}
 }
 
+func (r *ChapterRepository) Transaction(ctx context.Context, fn func(tx *ChapterRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&ChapterRepository{db: tx})
+	})
+}
+
 func (r *ChapterRepository) FindCourse(ctx context.Context, courseID uint) (*models.Course, error) {
 	var course models.Course
 	if err := r.db.WithContext(ctx).First(&course, courseID).Error; err != nil {
@@ -68,6 +85,17 @@ func (r *ChapterRepository) HasEnrollment(ctx context.Context, courseID uint, us
 	return false, err
 }
 
+func (r *ChapterRepository) IsCourseAssistant(ctx context.Context, courseID uint, userID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND user_id = ? AND role = 'assistant'", courseID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (r *ChapterRepository) ClearChapterReferences(ctx context.Context, chapterID uint) error {
 	if err := r.db.WithContext(ctx).Model(&models.Resource{}).Where("chapter_id = ?", chapterID).Update("chapter_id", nil).Error; err != nil {
 		return err
@@ -78,9 +106,137 @@ func (r *ChapterRepository) ClearChapterReferences(ctx context.Context, chapterI
 	if err := r.db.WithContext(ctx).Model(&models.Quiz{}).Where("chapter_id = ?", chapterID).Update("chapter_id", nil).Error; err != nil {
 		return err
 	}
+	if err := r.db.WithContext(ctx).Model(&models.Chapter{}).Where("prerequisite_chapter_id = ?", chapterID).Update("prerequisite_chapter_id", nil).Error; err != nil {
+		return err
+	}
 	return nil
 }
 
 func (r *ChapterRepository) DeleteProgressByChapter(ctx context.Context, chapterID uint) error {
 	return r.db.WithContext(ctx).Where("chapter_id = ?", chapterID).Delete(&models.ChapterProgress{}).Error
 }
+
+func (r *ChapterRepository) FindProgress(ctx context.Context, chapterID uint, studentID uint) (*models.ChapterProgress, error) {
+	var progress models.ChapterProgress
+	if err := r.db.WithContext(ctx).
+		Where("chapter_id = ? AND student_id = ?", chapterID, studentID).
+		First(&progress).Error; err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+func (r *ChapterRepository) SaveProgress(ctx context.Context, progress *models.ChapterProgress) error {
+	return r.db.WithContext(ctx).Save(progress).Error
+}
+
+func (r *ChapterRepository) ListCompletedChapterIDs(ctx context.Context, courseID uint, studentID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).
+		Model(&models.ChapterProgress{}).
+		Joins("JOIN chapters ON chapters.id = chapter_progresses.chapter_id").
+		Where("chapters.course_id = ? AND chapter_progresses.student_id = ? AND chapter_progresses.completed_at IS NOT NULL", courseID, studentID).
+		Pluck("chapter_progresses.chapter_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *ChapterRepository) SumStudyTimeByChapter(ctx context.Context, courseID uint, studentID uint) ([]ChapterStudyTime, error) {
+	var rows []ChapterStudyTime
+	if err := r.db.WithContext(ctx).
+		Table("chapter_progresses").
+		Select("chapter_progresses.chapter_id, SUM(chapter_progresses.study_duration_seconds) AS seconds").
+		Joins("JOIN chapters ON chapters.id = chapter_progresses.chapter_id AND chapters.deleted_at IS NULL").
+		Where("chapters.course_id = ? AND chapter_progresses.student_id = ? AND chapter_progresses.deleted_at IS NULL", courseID, studentID).
+		Group("chapter_progresses.chapter_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *ChapterRepository) SumClassStudyTimeByChapter(ctx context.Context, courseID uint) ([]ChapterStudyTime, error) {
+	var rows []ChapterStudyTime
+	if err := r.db.WithContext(ctx).
+		Table("chapter_progresses").
+		Select("chapter_progresses.chapter_id, SUM(chapter_progresses.study_duration_seconds) AS seconds").
+		Joins("JOIN chapters ON chapters.id = chapter_progresses.chapter_id AND chapters.deleted_at IS NULL").
+		Joins("JOIN course_enrollments ON course_enrollments.course_id = chapters.course_id AND course_enrollments.user_id = chapter_progresses.student_id AND course_enrollments.deleted_at IS NULL").
+		Where("chapters.course_id = ? AND course_enrollments.role = 'student' AND chapter_progresses.deleted_at IS NULL", courseID).
+		Group("chapter_progresses.chapter_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *ChapterRepository) CountStudentsByCourse(ctx context.Context, courseID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND role = 'student'", courseID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *ChapterRepository) AvgStudyDuration(ctx context.Context, chapterID uint) (int, error) {
+	var row struct {
+		Seconds int
+		Count   int
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.ChapterProgress{}).
+		Select("COALESCE(SUM(study_duration_seconds), 0) AS seconds, COUNT(*) AS count").
+		Where("chapter_id = ?", chapterID).
+		Scan(&row).Error; err != nil {
+		return 0, err
+	}
+	if row.Count == 0 {
+		return 0, nil
+	}
+	return row.Seconds / row.Count, nil
+}
+
+func (r *ChapterRepository) ListProgressAfter(ctx context.Context, chapterID uint, afterID uint, limit int) ([]models.ChapterProgress, error) {
+	var progress []models.ChapterProgress
+	if err := r.db.WithContext(ctx).
+		Where("chapter_id = ? AND id > ?", chapterID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&progress).Error; err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+func (r *ChapterRepository) FindUsers(ctx context.Context, userIDs []uint) ([]models.User, error) {
+	var users []models.User
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *ChapterRepository) SumGradesByStudent(ctx context.Context, chapterID uint, studentIDs []uint) ([]StudentGradeTotal, error) {
+	var rows []StudentGradeTotal
+	if len(studentIDs) == 0 {
+		return rows, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Table("submissions").
+		Select("submissions.student_id, SUM(submissions.grade) AS total, COUNT(*) AS count").
+		Joins("JOIN assignments ON assignments.id = submissions.assignment_id AND assignments.deleted_at IS NULL").
+		Where("assignments.chapter_id = ? AND submissions.student_id IN ? AND submissions.grade IS NOT NULL AND submissions.deleted_at IS NULL", chapterID, studentIDs).
+		Group("submissions.student_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type ChatRepository struct {
+	db *gorm.DB
+}
+
+func NewChatRepository(db *gorm.DB) *ChatRepository {
+	return &ChatRepository{db: db}
+}
+
+func (r *ChatRepository) Transaction(ctx context.Context, fn func(tx *ChatRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&ChatRepository{db: tx})
+	})
+}
+
+func (r *ChatRepository) FindCourse(ctx context.Context, courseID uint) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.WithContext(ctx).First(&course, courseID).Error; err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+func (r *ChatRepository) HasEnrollment(ctx context.Context, courseID uint, userID uint) (bool, error) {
+	var enrollment models.CourseEnrollment
+	err := r.db.WithContext(ctx).
+		Where("course_id = ? AND user_id = ?", courseID, userID).
+		First(&enrollment).Error
+	if err == nil {
+		return true, nil
+	}
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+func (r *ChatRepository) FindTranscriptByKey(ctx context.Context, sessionKey string) (*models.ChatTranscript, error) {
+	var transcript models.ChatTranscript
+	if err := r.db.WithContext(ctx).Where("session_key = ?", sessionKey).First(&transcript).Error; err != nil {
+		return nil, err
+	}
+	return &transcript, nil
+}
+
+func (r *ChatRepository) CreateTranscript(ctx context.Context, transcript *models.ChatTranscript) error {
+	return r.db.WithContext(ctx).Create(transcript).Error
+}
+
+func (r *ChatRepository) UpdateTranscript(ctx context.Context, transcript *models.ChatTranscript) error {
+	return r.db.WithContext(ctx).Model(transcript).Updates(map[string]interface{}{
+		"message_count": transcript.MessageCount,
+		"truncated":     transcript.Truncated,
+	}).Error
+}
+
+func (r *ChatRepository) CreateTranscriptMessage(ctx context.Context, message *models.ChatTranscriptMessage) error {
+	return r.db.WithContext(ctx).Create(message).Error
+}
+
+func (r *ChatRepository) ListTranscriptMessages(ctx context.Context, transcriptID uint) ([]models.ChatTranscriptMessage, error) {
+	var messages []models.ChatTranscriptMessage
+	if err := r.db.WithContext(ctx).
+		Where("transcript_id = ?", transcriptID).
+		Order("id ASC").
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -42,6 +42,19 @@ func (r *CourseRepository) FindByTeacherID(ctx context.Context, teacherID uint)
 	return courses, nil
 }
 
+func (r *CourseRepository) FindByTeacherOrAssistant(ctx context.Context, userID uint) ([]models.Course, error) {
+	var courses []models.Course
+	if err := r.db.WithContext(ctx).
+		Where("teacher_id = ? OR id IN (?)", userID,
+			r.db.Model(&models.CourseEnrollment{}).Select("course_id").Where("user_id = ? AND role = 'assistant'", userID),
+		).
+		Order("id desc").
+		Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	return courses, nil
+}
+
 func (r *CourseRepository) FindByStudentID(ctx context.Context, studentID uint) ([]models.Course, error) {
 	var courses []models.Course
 	if err := r.db.WithContext(ctx).
@@ -66,6 +79,64 @@ func (r *CourseRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&models.Course{}, id).Error
 }
 
+func (r *CourseRepository) Transaction(ctx context.Context, fn func(tx *CourseRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&CourseRepository{db: tx})
+	})
+}
+
+// Audit returns an audit repository on the same connection, so audit entries
+// written inside Transaction commit or roll back with the action.
+func (r *CourseRepository) Audit() *AuditRepository {
+	return &AuditRepository{db: r.db}
+}
+
+func (r *CourseRepository) FindEnrollment(ctx context.Context, courseID uint, userID uint) (*models.CourseEnrollment, error) {
+	var enrollment models.CourseEnrollment
+	if err := r.db.WithContext(ctx).
+		Where("course_id = ? AND user_id = ?", courseID, userID).
+		First(&enrollment).Error; err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func (r *CourseRepository) UpdateEnrollmentRole(ctx context.Context, enrollment *models.CourseEnrollment) error {
+	return r.db.WithContext(ctx).Model(enrollment).Update("role", enrollment.Role).Error
+}
+
+func (r *CourseRepository) DeleteGroupMemberships(ctx context.Context, courseID uint, userID uint) error {
+	return r.db.WithContext(ctx).
+		Where("course_id = ? AND user_id = ?", courseID, userID).
+		Delete(&models.StudentGroupMember{}).Error
+}
+
+func (r *CourseRepository) DeleteEnrollment(ctx context.Context, enrollment *models.CourseEnrollment) error {
+	return r.db.WithContext(ctx).Delete(enrollment).Error
+}
+
+// CountStudentWork counts the user's submissions and quiz attempts in the
+// course, including those on since-deleted assignments and quizzes.
+func (r *CourseRepository) CountStudentWork(ctx context.Context, courseID uint, userID uint) (int64, error) {
+	var submissions int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.Submission{}).
+		Joins("JOIN assignments ON assignments.id = submissions.assignment_id").
+		Where("assignments.course_id = ? AND submissions.student_id = ?", courseID, userID).
+		Count(&submissions).Error; err != nil {
+		return 0, err
+	}
+	var attempts int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id").
+		Where("quizzes.course_id = ? AND quiz_attempts.student_id = ?", courseID, userID).
+		Count(&attempts).Error; err != nil {
+		return 0, err
+	}
+	return submissions + attempts, nil
+}
+
 func (r *CourseRepository) HasEnrollment(ctx context.Context, courseID uint, userID uint) (bool, error) {
 	var enrollment models.CourseEnrollment
 	err := r.db.WithContext(ctx).
@@ -79,3 +150,65 @@ func (r *CourseRepository) HasEnrollment(ctx context.Context, courseID uint, use
 	}
 	return false, err
 }
+
+// Purge permanently deletes the course and everything that belongs to it,
+// soft-deleted rows included, and returns how many rows went from each
+// table. Children go before their parents, since they are found through
+// them. Call it inside Transaction so a failure deletes nothing.
+func (r *CourseRepository) Purge(ctx context.Context, courseID uint) (map[string]int64, error) {
+	// A session, so each statement below starts from the unscoped base.
+	db := r.db.WithContext(ctx).Unscoped().Session(&gorm.Session{})
+	ids := func(model interface{}, column string, values interface{}) *gorm.DB {
+		return db.Model(model).Select("id").Where(column+" IN (?)", values)
+	}
+	quizzes := db.Model(&models.Quiz{}).Select("id").Where("course_id = ?", courseID)
+	assignments := db.Model(&models.Assignment{}).Select("id").Where("course_id = ?", courseID)
+	chapters := db.Model(&models.Chapter{}).Select("id").Where("course_id = ?", courseID)
+	announcements := db.Model(&models.Announcement{}).Select("id").Where("course_id = ?", courseID)
+	sessions := db.Model(&models.AttendanceSession{}).Select("id").Where("course_id = ?", courseID)
+	transcripts := db.Model(&models.ChatTranscript{}).Select("id").Where("course_id = ?", courseID)
+
+	steps := []struct {
+		name  string
+		model interface{}
+		where string
+		arg   interface{}
+	}{
+		{"quiz_feedbacks", &models.QuizFeedback{}, "quiz_id IN (?)", quizzes},
+		{"quiz_attempt_grants", &models.QuizAttemptGrant{}, "quiz_id IN (?)", quizzes},
+		{"quiz_attempts", &models.QuizAttempt{}, "quiz_id IN (?)", quizzes},
+		{"questions", &models.Question{}, "quiz_id IN (?)", quizzes},
+		{"quizzes", &models.Quiz{}, "course_id = ?", courseID},
+		{"submission_comments", &models.SubmissionComment{}, "submission_id IN (?)", ids(&models.Submission{}, "assignment_id", assignments)},
+		{"submissions", &models.Submission{}, "assignment_id IN (?)", assignments},
+		{"assignment_extensions", &models.AssignmentExtension{}, "assignment_id IN (?)", assignments},
+		{"assignment_attachments", &models.AssignmentAttachment{}, "assignment_id IN (?)", assignments},
+		{"similarity_reports", &models.SimilarityReport{}, "assignment_id IN (?)", assignments},
+		{"writing_submissions", &models.WritingSubmission{}, "course_id = ?", courseID},
+		{"assignments", &models.Assignment{}, "course_id = ?", courseID},
+		{"student_group_members", &models.StudentGroupMember{}, "course_id = ?", courseID},
+		{"student_groups", &models.StudentGroup{}, "course_id = ?", courseID},
+		{"chapter_progresses", &models.ChapterProgress{}, "chapter_id IN (?)", chapters},
+		{"resources", &models.Resource{}, "course_id = ?", courseID},
+		{"chapters", &models.Chapter{}, "course_id = ?", courseID},
+		{"announcement_reads", &models.AnnouncementRead{}, "announcement_id IN (?)", announcements},
+		{"announcements", &models.Announcement{}, "course_id = ?", courseID},
+		{"attendance_records", &models.AttendanceRecord{}, "session_id IN (?)", sessions},
+		{"attendance_sessions", &models.AttendanceSession{}, "course_id = ?", courseID},
+		{"chat_transcript_messages", &models.ChatTranscriptMessage{}, "transcript_id IN (?)", transcripts},
+		{"chat_transcripts", &models.ChatTranscript{}, "course_id = ?", courseID},
+		{"student_learning_profiles", &models.StudentLearningProfile{}, "course_id = ?", courseID},
+		{"learning_events", &models.LearningEvent{}, "course_id = ?", courseID},
+		{"course_enrollments", &models.CourseEnrollment{}, "course_id = ?", courseID},
+		{"courses", &models.Course{}, "id = ?", courseID},
+	}
+	counts := make(map[string]int64, len(steps))
+	for _, step := range steps {
+		result := db.Where(step.where, step.arg).Delete(step.model)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		counts[step.name] = result.RowsAffected
+	}
+	return counts, nil
+}
@@ -2,11 +2,29 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"gorm.io/gorm"
 )
 
+type StudentBestScore struct {
+	StudentID uint
+	Username  string
+	Name      string
+	BestScore int
+}
+
+type StudentCourseAttempt struct {
+	AttemptID     uint
+	QuizID        uint
+	QuizTitle     string
+	AttemptNumber int
+	Score         *int
+	MaxScore      int
+	SubmittedAt   time.Time
+}
+
 type QuizRepository struct {
 	db *gorm.DB
 }
@@ -15,11 +33,14 @@ func NewQuizRepository(db *gorm.DB) *QuizRepository {
 	return &QuizRepository{db: db}
 }
 
-func (r *QuizRepository) ListByCourse(ctx context.Context, courseID uint, publishedOnly bool) ([]models.Quiz, error) {
+func (r *QuizRepository) ListByCourse(ctx context.Context, courseID uint, publishedOnly bool, includeDeleted bool) ([]models.Quiz, error) {
 	db := r.db.WithContext(ctx).Where("course_id = ?", courseID).Order("created_at DESC")
 	if publishedOnly {
 		db = db.Where("is_published = ?", true)
 	}
+	if includeDeleted {
+		db = db.Unscoped()
+	}
 	var quizzes []models.Quiz
 	if err := db.Find(&quizzes).Error; err != nil {
 		return nil, err
@@ -27,6 +48,25 @@ func (r *QuizRepository) ListByCourse(ctx context.Context, courseID uint, publis
 	return quizzes, nil
 }
 
+func (r *QuizRepository) ListPageByCourse(ctx context.Context, courseID uint, publishedOnly bool, offset, limit int) ([]models.Quiz, int64, error) {
+	scope := func() *gorm.DB {
+		db := r.db.WithContext(ctx).Model(&models.Quiz{}).Where("course_id = ?", courseID)
+		if publishedOnly {
+			db = db.Where("is_published = ?", true)
+		}
+		return db
+	}
+	var total int64
+	if err := scope().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var quizzes []models.Quiz
+	if err := scope().Order("created_at DESC").Offset(offset).Limit(limit).Find(&quizzes).Error; err != nil {
+		return nil, 0, err
+	}
+	return quizzes, total, nil
+}
+
 func (r *QuizRepository) FindByID(ctx context.Context, quizID uint) (*models.Quiz, error) {
 	var quiz models.Quiz
 	if err := r.db.WithContext(ctx).First(&quiz, quizID).Error; err != nil {
@@ -35,6 +75,17 @@ func (r *QuizRepository) FindByID(ctx context.Context, quizID uint) (*models.Qui
 	return &quiz, nil
 }
 
+func (r *QuizRepository) FindByIDs(ctx context.Context, quizIDs []uint) ([]models.Quiz, error) {
+	var quizzes []models.Quiz
+	if len(quizIDs) == 0 {
+		return quizzes, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", quizIDs).Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+	return quizzes, nil
+}
+
 func (r *QuizRepository) Create(ctx context.Context, quiz *models.Quiz) error {
 	return r.db.WithContext(ctx).Create(quiz).Error
 }
@@ -51,6 +102,18 @@ func (r *QuizRepository) DeleteByID(ctx context.Context, quizID uint) error {
 	return r.db.WithContext(ctx).Delete(&models.Quiz{}, quizID).Error
 }
 
+func (r *QuizRepository) FindDeletedByID(ctx context.Context, quizID uint) (*models.Quiz, error) {
+	var quiz models.Quiz
+	if err := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&quiz, quizID).Error; err != nil {
+		return nil, err
+	}
+	return &quiz, nil
+}
+
+func (r *QuizRepository) Restore(ctx context.Context, quizID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Model(&models.Quiz{}).Where("id = ?", quizID).Update("deleted_at", nil).Error
+}
+
 func (r *QuizRepository) ListQuestions(ctx context.Context, quizID uint) ([]models.Question, error) {
 	var questions []models.Question
 	if err := r.db.WithContext(ctx).Where("quiz_id = ?", quizID).Order("order_num ASC").Find(&questions).Error; err != nil {
@@ -79,12 +142,42 @@ func (r *QuizRepository) DeleteQuestion(ctx context.Context, questionID uint) er
 	return r.db.WithContext(ctx).Delete(&models.Question{}, questionID).Error
 }
 
-func (r *QuizRepository) DeleteQuestionsByQuiz(ctx context.Context, quizID uint) error {
-	return r.db.WithContext(ctx).Where("quiz_id = ?", quizID).Delete(&models.Question{}).Error
+func (r *QuizRepository) CreateAttemptGrant(ctx context.Context, grant *models.QuizAttemptGrant) error {
+	return r.db.WithContext(ctx).Create(grant).Error
 }
 
-func (r *QuizRepository) DeleteAttemptsByQuiz(ctx context.Context, quizID uint) error {
-	return r.db.WithContext(ctx).Where("quiz_id = ?", quizID).Delete(&models.QuizAttempt{}).Error
+func (r *QuizRepository) CountAttemptGrants(ctx context.Context, quizID uint, studentID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.QuizAttemptGrant{}).
+		Where("quiz_id = ? AND student_id = ?", quizID, studentID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *QuizRepository) CountAttemptGrantsByStudent(ctx context.Context, studentID uint, quizIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int, len(quizIDs))
+	if len(quizIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		QuizID uint
+		Count  int
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.QuizAttemptGrant{}).
+		Select("quiz_id, COUNT(*) AS count").
+		Where("student_id = ? AND quiz_id IN ?", studentID, quizIDs).
+		Group("quiz_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.QuizID] = row.Count
+	}
+	return counts, nil
 }
 
 func (r *QuizRepository) CountAttempts(ctx context.Context, quizID uint) (int64, error) {
@@ -95,6 +188,14 @@ func (r *QuizRepository) CountAttempts(ctx context.Context, quizID uint) (int64,
 	return count, nil
 }
 
+func (r *QuizRepository) CountQuestions(ctx context.Context, quizID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Question{}).Where("quiz_id = ?", quizID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (r *QuizRepository) CountAttemptsByQuizAndStudent(ctx context.Context, quizID uint, studentID uint) (int64, error) {
 	var count int64
 	if err := r.db.WithContext(ctx).
@@ -116,12 +217,89 @@ func (r *QuizRepository) FindInProgressAttempt(ctx context.Context, quizID uint,
 	return &attempt, nil
 }
 
+// HasOpenAttempts reports whether any unsubmitted attempt on the quiz has a
+// deadline after the given time.
+func (r *QuizRepository) HasOpenAttempts(ctx context.Context, quizID uint, after time.Time) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Where("quiz_id = ? AND submitted_at IS NULL AND deadline > ?", quizID, after).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *QuizRepository) CapInProgressAttemptDeadlines(ctx context.Context, quizID uint, deadline time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Where("quiz_id = ? AND submitted_at IS NULL AND deadline > ?", quizID, deadline).
+		Update("deadline", deadline).Error
+}
+
 func (r *QuizRepository) CreateAttempt(ctx context.Context, attempt *models.QuizAttempt) error {
 	return r.db.WithContext(ctx).Create(attempt).Error
 }
 
-func (r *QuizRepository) SaveAttempt(ctx context.Context, attempt *models.QuizAttempt) error {
-	return r.db.WithContext(ctx).Save(attempt).Error
+func (r *QuizRepository) SubmitAttempt(ctx context.Context, attempt *models.QuizAttempt) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Where("id = ? AND submitted_at IS NULL", attempt.ID).
+		Updates(map[string]interface{}{
+			"answers":           attempt.Answers,
+			"answer_snapshot":   attempt.AnswerSnapshot,
+			"submitted_at":      attempt.SubmittedAt,
+			"score":             attempt.Score,
+			"submit_ip":         attempt.SubmitIP,
+			"submit_user_agent": attempt.SubmitUserAgent,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *QuizRepository) ReopenAttempt(ctx context.Context, attemptID uint, deadline time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Where("id = ? AND submitted_at IS NOT NULL", attemptID).
+		Updates(map[string]interface{}{
+			"submitted_at":      nil,
+			"score":             nil,
+			"answer_snapshot":   "",
+			"submit_ip":         "",
+			"submit_user_agent": "",
+			"deadline":          deadline,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *QuizRepository) FindAttemptByID(ctx context.Context, attemptID uint) (*models.QuizAttempt, error) {
+	var attempt models.QuizAttempt
+	if err := r.db.WithContext(ctx).First(&attempt, attemptID).Error; err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *QuizRepository) FindFeedbackByAttempt(ctx context.Context, attemptID uint) (*models.QuizFeedback, error) {
+	var feedback models.QuizFeedback
+	if err := r.db.WithContext(ctx).Where("attempt_id = ?", attemptID).First(&feedback).Error; err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+func (r *QuizRepository) SaveFeedback(ctx context.Context, feedback *models.QuizFeedback) error {
+	return r.db.WithContext(ctx).Save(feedback).Error
+}
+
+func (r *QuizRepository) ListFeedbackByQuiz(ctx context.Context, quizID uint) ([]models.QuizFeedback, error) {
+	var feedback []models.QuizFeedback
+	if err := r.db.WithContext(ctx).
+		Where("quiz_id = ?", quizID).
+		Order("created_at DESC, id DESC").
+		Find(&feedback).Error; err != nil {
+		return nil, err
+	}
+	return feedback, nil
 }
 
 func (r *QuizRepository) SumQuestionPoints(ctx context.Context, quizID uint) (int, error) {
@@ -155,6 +333,43 @@ func (r *QuizRepository) ListAttemptsByQuiz(ctx context.Context, quizID uint, or
 	return attempts, nil
 }
 
+func (r *QuizRepository) ListSubmittedAttemptsByQuiz(ctx context.Context, quizID uint) ([]models.QuizAttempt, error) {
+	var attempts []models.QuizAttempt
+	if err := r.db.WithContext(ctx).
+		Where("quiz_id = ? AND submitted_at IS NOT NULL", quizID).
+		Order("id ASC").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (r *QuizRepository) UpdateAttemptScore(ctx context.Context, attemptID uint, score int) error {
+	return r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Where("id = ?", attemptID).
+		Update("score", score).Error
+}
+
+func (r *QuizRepository) UpdateQuestionAnswer(ctx context.Context, questionID uint, answer string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Question{}).
+		Where("id = ?", questionID).
+		Update("answer", answer).Error
+}
+
+func (r *QuizRepository) Transaction(ctx context.Context, fn func(tx *QuizRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&QuizRepository{db: tx})
+	})
+}
+
+// Audit returns an audit repository on the same connection, so audit entries
+// written inside Transaction commit or roll back with the action.
+func (r *QuizRepository) Audit() *AuditRepository {
+	return &AuditRepository{db: r.db}
+}
+
 func (r *QuizRepository) ListAttemptsByQuizAndStudentOrder(ctx context.Context, quizID uint, studentID uint, order string) ([]models.QuizAttempt, error) {
 	db := r.db.WithContext(ctx).Where("quiz_id = ? AND student_id = ?", quizID, studentID)
 	if order != "" {
@@ -166,3 +381,234 @@ func (r *QuizRepository) ListAttemptsByQuizAndStudentOrder(ctx context.Context,
 	}
 	return attempts, nil
 }
+
+func (r *QuizRepository) TopScoresByQuiz(ctx context.Context, quizID uint, limit int) ([]StudentBestScore, error) {
+	var rows []StudentBestScore
+	if err := r.db.WithContext(ctx).
+		Table("quiz_attempts").
+		Select("quiz_attempts.student_id, users.username, users.name, MAX(quiz_attempts.score) AS best_score").
+		Joins("JOIN users ON users.id = quiz_attempts.student_id").
+		Where("quiz_attempts.quiz_id = ? AND quiz_attempts.submitted_at IS NOT NULL AND quiz_attempts.score IS NOT NULL", quizID).
+		Where("quiz_attempts.deleted_at IS NULL").
+		Group("quiz_attempts.student_id, users.username, users.name").
+		Order("best_score DESC, quiz_attempts.student_id ASC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *QuizRepository) ListSubmittedAttemptsByCourseAndStudent(ctx context.Context, courseID uint, studentID uint) ([]StudentCourseAttempt, error) {
+	var rows []StudentCourseAttempt
+	if err := r.db.WithContext(ctx).
+		Table("quiz_attempts").
+		Select("quiz_attempts.id AS attempt_id, quiz_attempts.quiz_id, quizzes.title AS quiz_title, quiz_attempts.attempt_number, quiz_attempts.score, quiz_attempts.max_score, quiz_attempts.submitted_at").
+		Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id AND quizzes.deleted_at IS NULL").
+		Where("quizzes.course_id = ? AND quiz_attempts.student_id = ?", courseID, studentID).
+		Where("quiz_attempts.submitted_at IS NOT NULL AND quiz_attempts.deleted_at IS NULL").
+		Order("quiz_attempts.submitted_at DESC, quiz_attempts.id DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *QuizRepository) FindCourse(ctx context.Context, courseID uint) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.WithContext(ctx).First(&course, courseID).Error; err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+func (r *QuizRepository) FindChapter(ctx context.Context, chapterID uint) (*models.Chapter, error) {
+	var chapter models.Chapter
+	if err := r.db.WithContext(ctx).First(&chapter, chapterID).Error; err != nil {
+		return nil, err
+	}
+	return &chapter, nil
+}
+
+func (r *QuizRepository) HasEnrollment(ctx context.Context, courseID uint, userID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND user_id = ?", courseID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *QuizRepository) CountByCourse(ctx context.Context, courseID uint, publishedOnly bool) (int64, error) {
+	db := r.db.WithContext(ctx).Model(&models.Quiz{}).Where("course_id = ?", courseID)
+	if publishedOnly {
+		db = db.Where("is_published = ?", true)
+	}
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *QuizRepository) SubmittedAttemptStatsByCourse(ctx context.Context, courseID uint) (int64, float64, error) {
+	var row struct {
+		AttemptCount int64
+		AvgRate      float64
+	}
+	err := r.db.WithContext(ctx).
+		Table("quiz_attempts").
+		Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id AND quizzes.deleted_at IS NULL").
+		Where("quizzes.course_id = ? AND quiz_attempts.deleted_at IS NULL", courseID).
+		Where("quiz_attempts.submitted_at IS NOT NULL AND quiz_attempts.score IS NOT NULL AND quiz_attempts.max_score > 0").
+		Select("COUNT(*) AS attempt_count, COALESCE(AVG(quiz_attempts.score * 1.0 / quiz_attempts.max_score), 0) AS avg_rate").
+		Scan(&row).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	return row.AttemptCount, row.AvgRate, nil
+}
+
+// AverageScoreByStudents averages each student's graded attempts in the
+// course as a percentage of the attempt's maximum, keyed by student.
+// Students without a graded attempt are missing from the map.
+func (r *QuizRepository) AverageScoreByStudents(ctx context.Context, courseID uint) (map[uint]float64, error) {
+	var rows []struct {
+		StudentID  uint
+		AvgPercent float64
+	}
+	if err := r.db.WithContext(ctx).
+		Table("quiz_attempts").
+		Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id AND quizzes.deleted_at IS NULL").
+		Where("quizzes.course_id = ? AND quiz_attempts.deleted_at IS NULL", courseID).
+		Where("quiz_attempts.submitted_at IS NOT NULL AND quiz_attempts.score IS NOT NULL AND quiz_attempts.max_score > 0").
+		Select("quiz_attempts.student_id AS student_id, AVG(quiz_attempts.score * 100.0 / quiz_attempts.max_score) AS avg_percent").
+		Group("quiz_attempts.student_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	averages := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		averages[row.StudentID] = row.AvgPercent
+	}
+	return averages, nil
+}
+
+func (r *QuizRepository) CountStudentsByCourse(ctx context.Context, courseID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND role = 'student'", courseID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *QuizRepository) ListStudentIDsByCourse(ctx context.Context, courseID uint) ([]uint, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).
+		Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND role = 'student'", courseID).
+		Order("user_id ASC").
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *QuizRepository) FindUsersByIDs(ctx context.Context, userIDs []uint) (map[uint]models.User, error) {
+	users := make(map[uint]models.User, len(userIDs))
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	var rows []models.User
+	if err := r.db.WithContext(ctx).Where("id IN ?", userIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range rows {
+		users[u.ID] = u
+	}
+	return users, nil
+}
+
+func (r *QuizRepository) CountParticipatingStudentsByCourse(ctx context.Context, courseID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("quiz_attempts").
+		Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id AND quizzes.deleted_at IS NULL").
+		Joins("JOIN course_enrollments ON course_enrollments.course_id = quizzes.course_id AND course_enrollments.user_id = quiz_attempts.student_id AND course_enrollments.deleted_at IS NULL").
+		Where("quizzes.course_id = ? AND quiz_attempts.deleted_at IS NULL AND course_enrollments.role = 'student'", courseID).
+		Distinct("quiz_attempts.student_id").
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *QuizRepository) ListDueByCourses(ctx context.Context, courseIDs []uint, from, to time.Time) ([]models.Quiz, error) {
+	var quizzes []models.Quiz
+	if len(courseIDs) == 0 {
+		return quizzes, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("course_id IN ? AND is_published = ? AND end_time > ? AND end_time <= ?", courseIDs, true, from, to).
+		Order("end_time ASC").
+		Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+	return quizzes, nil
+}
+
+func (r *QuizRepository) ListPublishedByCourses(ctx context.Context, courseIDs []uint) ([]models.Quiz, error) {
+	var quizzes []models.Quiz
+	if len(courseIDs) == 0 {
+		return quizzes, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("course_id IN ? AND is_published = ?", courseIDs, true).
+		Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+	return quizzes, nil
+}
+
+func (r *QuizRepository) ListOpenByCourses(ctx context.Context, courseIDs []uint, now time.Time) ([]models.Quiz, error) {
+	var quizzes []models.Quiz
+	if len(courseIDs) == 0 {
+		return quizzes, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("course_id IN ? AND is_published = ?", courseIDs, true).
+		Where("(start_time IS NULL OR start_time <= ?) AND (end_time IS NULL OR end_time > ?)", now, now).
+		Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+	return quizzes, nil
+}
+
+func (r *QuizRepository) CountSubmittedAttemptsByStudent(ctx context.Context, studentID uint, quizIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int, len(quizIDs))
+	if len(quizIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		QuizID uint
+		Count  int
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.QuizAttempt{}).
+		Select("quiz_id, COUNT(*) AS count").
+		Where("student_id = ? AND quiz_id IN ? AND submitted_at IS NOT NULL", studentID, quizIDs).
+		Group("quiz_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.QuizID] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) FindRevocation(ctx context.Context, userID uint) (*models.SessionRevocation, error) {
+	var revocation models.SessionRevocation
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&revocation).Error; err != nil {
+		return nil, err
+	}
+	return &revocation, nil
+}
+
+func (r *SessionRepository) UpsertRevocation(ctx context.Context, userID uint, revokedAt time.Time, reason string) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at", "reason", "updated_at"}),
+	}).Create(&models.SessionRevocation{UserID: userID, RevokedAt: revokedAt, Reason: reason}).Error
+}
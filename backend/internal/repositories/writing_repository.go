@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type WritingRepository struct {
+	db *gorm.DB
+}
+
+func NewWritingRepository(db *gorm.DB) *WritingRepository {
+	return &WritingRepository{db: db}
+}
+
+func (r *WritingRepository) FindCourse(ctx context.Context, courseID uint) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.WithContext(ctx).First(&course, courseID).Error; err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+func (r *WritingRepository) HasEnrollment(ctx context.Context, courseID uint, userID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.CourseEnrollment{}).
+		Where("course_id = ? AND user_id = ?", courseID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *WritingRepository) ListFinalizedByStudent(ctx context.Context, studentID uint, courseID uint, writingType string) ([]models.WritingSubmission, error) {
+	db := r.db.WithContext(ctx).Where("student_id = ? AND draft = ?", studentID, false)
+	if courseID != 0 {
+		db = db.Where("course_id = ?", courseID)
+	}
+	if writingType != "" {
+		db = db.Where("writing_type = ?", writingType)
+	}
+	var submissions []models.WritingSubmission
+	if err := db.Order("created_at ASC, id ASC").Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidDateRange indicates a range that ends before it starts or spans
+// more than MaxAnalyticsRange.
+var ErrInvalidDateRange = errors.New("invalid date range")
+
+// MaxAnalyticsRange is the longest range institution analytics cover.
+const MaxAnalyticsRange = 366 * 24 * time.Hour
+
+// AnalyticsService computes institution-wide KPIs for admins.
+type AnalyticsService struct {
+	repo *repositories.AnalyticsRepository
+}
+
+// NewAnalyticsService builds an AnalyticsService.
+func NewAnalyticsService(db *gorm.DB) *AnalyticsService {
+	return &AnalyticsService{repo: repositories.NewAnalyticsRepository(db)}
+}
+
+// InstitutionAnalytics summarizes activity across all courses from From
+// (inclusive) to To (exclusive). Totals count everything regardless of the
+// range; the rates are nil when there is nothing to rate.
+type InstitutionAnalytics struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	TotalCourses   int64 `json:"total_courses"`
+	ActiveCourses  int64 `json:"active_courses"` // with a submission, quiz attempt or attendance session
+	TotalStudents  int64 `json:"total_students"`
+	ActiveStudents int64 `json:"active_students"` // who submitted, took a quiz or checked in
+	Submissions    int64 `json:"submissions"`
+	QuizAttempts   int64 `json:"quiz_attempts"` // graded attempts submitted
+	// AverageQuizScore is the mean attempt score as a percentage of its maximum.
+	AverageQuizScore *float64 `json:"average_quiz_score"`
+	// AttendanceRate is check-ins over sessions times enrolled students.
+	AttendanceRate *float64 `json:"attendance_rate"`
+}
+
+// GetAnalytics returns the institution KPIs for the range.
+func (s *AnalyticsService) GetAnalytics(ctx context.Context, from, to time.Time) (*InstitutionAnalytics, error) {
+	if !to.After(from) || to.Sub(from) > MaxAnalyticsRange {
+		return nil, ErrInvalidDateRange
+	}
+	result := &InstitutionAnalytics{From: from, To: to}
+	var err error
+	if result.TotalCourses, err = s.repo.CountCourses(ctx); err != nil {
+		return nil, err
+	}
+	if result.ActiveCourses, err = s.repo.CountActiveCourses(ctx, from, to); err != nil {
+		return nil, err
+	}
+	if result.TotalStudents, err = s.repo.CountUsersByRole(ctx, "student"); err != nil {
+		return nil, err
+	}
+	if result.ActiveStudents, err = s.repo.CountActiveStudents(ctx, from, to); err != nil {
+		return nil, err
+	}
+	if result.Submissions, err = s.repo.CountSubmissions(ctx, from, to); err != nil {
+		return nil, err
+	}
+	if result.QuizAttempts, result.AverageQuizScore, err = s.repo.QuizScoreStats(ctx, from, to); err != nil {
+		return nil, err
+	}
+	expected, attended, err := s.repo.AttendanceTotals(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if expected > 0 {
+		rate := float64(attended) / float64(expected)
+		result.AttendanceRate = &rate
+	}
+	return result, nil
+}
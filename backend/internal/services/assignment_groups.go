@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGroupNotFound indicates the student group does not exist.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrInvalidGroupName indicates a group name is blank or too long.
+	ErrInvalidGroupName = errors.New("invalid group name")
+	// ErrStudentInOtherGroup indicates a student already belongs to another group in the course.
+	ErrStudentInOtherGroup = errors.New("student already in another group")
+	// ErrGroupHasSubmissions indicates a group cannot be deleted because it has submitted work.
+	ErrGroupHasSubmissions = errors.New("group has submissions")
+	// ErrNotInGroup indicates a student must join a group before submitting a group assignment.
+	ErrNotInGroup = errors.New("student is not in a group")
+	// ErrSubmittedWithOtherGroup indicates the student already submitted the assignment for a previous group.
+	ErrSubmittedWithOtherGroup = errors.New("already submitted with another group")
+)
+
+// GroupMembershipError lists the students that already belong to another group
+// in the course. It unwraps to ErrStudentInOtherGroup.
+type GroupMembershipError struct {
+	StudentIDs []uint
+}
+
+func (e *GroupMembershipError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrStudentInOtherGroup, e.StudentIDs)
+}
+
+func (e *GroupMembershipError) Unwrap() error {
+	return ErrStudentInOtherGroup
+}
+
+// GroupRequest names a group and lists its members.
+type GroupRequest struct {
+	Name      string
+	MemberIDs []uint
+}
+
+// ListGroups returns the course's groups with their members.
+func (s *AssignmentService) ListGroups(ctx context.Context, courseID uint, user UserInfo) ([]models.StudentGroup, error) {
+	if err := s.checkCourseTeacher(ctx, courseID, user); err != nil {
+		return nil, err
+	}
+	return s.repo.ListGroupsByCourse(ctx, courseID)
+}
+
+// CreateGroup adds a group to the course. Every member must be an enrolled
+// student who is not already in another group of the course.
+func (s *AssignmentService) CreateGroup(ctx context.Context, courseID uint, user UserInfo, req GroupRequest) (*models.StudentGroup, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 128 {
+		return nil, ErrInvalidGroupName
+	}
+	if err := s.checkCourseTeacher(ctx, courseID, user); err != nil {
+		return nil, err
+	}
+
+	group := &models.StudentGroup{CourseID: courseID, Name: name}
+	err := s.repo.Transaction(ctx, func(tx *repositories.AssignmentRepository) error {
+		if err := tx.CreateGroup(ctx, group); err != nil {
+			return err
+		}
+		return setGroupMembers(ctx, tx, group, req.MemberIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindGroup(ctx, group.ID)
+}
+
+// UpdateGroup renames a group and replaces its members, under the same member
+// rules as CreateGroup. Members who leave keep no share of submissions the
+// group made while they belonged to it.
+func (s *AssignmentService) UpdateGroup(ctx context.Context, groupID uint, user UserInfo, req GroupRequest) (*models.StudentGroup, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 128 {
+		return nil, ErrInvalidGroupName
+	}
+	group, err := s.findManagedGroup(ctx, groupID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	group.Name = name
+	err = s.repo.Transaction(ctx, func(tx *repositories.AssignmentRepository) error {
+		if err := tx.UpdateGroupName(ctx, group); err != nil {
+			return err
+		}
+		return setGroupMembers(ctx, tx, group, req.MemberIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindGroup(ctx, group.ID)
+}
+
+// DeleteGroup removes a group that has not submitted anything yet.
+func (s *AssignmentService) DeleteGroup(ctx context.Context, groupID uint, user UserInfo) error {
+	group, err := s.findManagedGroup(ctx, groupID, user)
+	if err != nil {
+		return err
+	}
+	count, err := s.repo.CountSubmissionsByGroup(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrGroupHasSubmissions
+	}
+	return s.repo.Transaction(ctx, func(tx *repositories.AssignmentRepository) error {
+		return tx.DeleteGroup(ctx, group)
+	})
+}
+
+// setGroupMembers validates memberIDs and makes them the group's members.
+func setGroupMembers(ctx context.Context, tx *repositories.AssignmentRepository, group *models.StudentGroup, memberIDs []uint) error {
+	ids := make([]uint, 0, len(memberIDs))
+	seen := make(map[uint]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	enrolled, err := tx.ListEnrolledStudentIDs(ctx, group.CourseID, ids)
+	if err != nil {
+		return err
+	}
+	if len(enrolled) != len(ids) {
+		return ErrStudentNotInCourse
+	}
+	taken, err := tx.ListGroupMembersInOtherGroups(ctx, group.CourseID, ids, group.ID)
+	if err != nil {
+		return err
+	}
+	if len(taken) > 0 {
+		conflict := &GroupMembershipError{}
+		for _, m := range taken {
+			conflict.StudentIDs = append(conflict.StudentIDs, m.UserID)
+		}
+		return conflict
+	}
+	return tx.ReplaceGroupMembers(ctx, group, ids)
+}
+
+// findManagedGroup loads a group the user may edit (course teacher or admin).
+func (s *AssignmentService) findManagedGroup(ctx context.Context, groupID uint, user UserInfo) (*models.StudentGroup, error) {
+	group, err := s.repo.FindGroup(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+	if err := s.checkCourseTeacher(ctx, group.CourseID, user); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// checkCourseTeacher allows the course teacher and admins.
+func (s *AssignmentService) checkCourseTeacher(ctx context.Context, courseID uint, user UserInfo) error {
+	course, err := s.repo.FindCourse(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCourseNotFound
+		}
+		return err
+	}
+	if course.TeacherID != user.ID && user.Role != "admin" {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// findStudentGroup returns the caller's group in the assignment's course, or
+// ErrNotInGroup when they have none.
+func (s *AssignmentService) findStudentGroup(ctx context.Context, assignment *models.Assignment, studentID uint) (*models.StudentGroup, error) {
+	group, err := s.repo.FindGroupByMember(ctx, assignment.CourseID, studentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotInGroup
+		}
+		return nil, err
+	}
+	return group, nil
+}
+
+// groupSubmissionGrades expands group submissions to one entry per student:
+// every current member of the submitting group, plus the member who
+// submitted. A nil grade means the submission is not graded yet.
+func (s *AssignmentService) groupSubmissionGrades(ctx context.Context, submissions []models.Submission) ([]*int, error) {
+	var groupIDs []uint
+	for _, sub := range submissions {
+		if sub.GroupID != nil {
+			groupIDs = append(groupIDs, *sub.GroupID)
+		}
+	}
+	members, err := s.repo.ListGroupMembers(ctx, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	membersByGroup := make(map[uint][]uint)
+	for _, m := range members {
+		membersByGroup[m.GroupID] = append(membersByGroup[m.GroupID], m.UserID)
+	}
+
+	grades := make(map[uint]*int)
+	for _, sub := range submissions {
+		students := []uint{sub.StudentID}
+		if sub.GroupID != nil {
+			students = append(students, membersByGroup[*sub.GroupID]...)
+		}
+		for _, id := range students {
+			if _, ok := grades[id]; !ok || grades[id] == nil {
+				grades[id] = sub.Grade
+			}
+		}
+	}
+
+	out := make([]*int, 0, len(grades))
+	for _, grade := range grades {
+		out = append(out, grade)
+	}
+	return out, nil
+}
@@ -3,7 +3,16 @@ package services
 import (
 	"context"
 	"errors"
-
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
 	"gorm.io/gorm"
@@ -14,11 +23,42 @@ var (
 	ErrAssignmentNotFound = errors.New("assignment not found")
 	// ErrSubmissionNotFound indicates the submission does not exist.
 	ErrSubmissionNotFound = errors.New("submission not found")
+	// ErrAssignmentNotAvailable indicates the assignment is a draft hidden from students.
+	ErrAssignmentNotAvailable = errors.New("assignment not available")
+	// ErrAssignmentUnpublishNotAllowed indicates an assignment cannot return to draft due to submissions.
+	ErrAssignmentUnpublishNotAllowed = errors.New("cannot unpublish: submissions exist")
+	// ErrAttachmentNotFound indicates the attachment does not exist on the assignment.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrInvalidAttachment indicates an attachment title or URL is invalid.
+	ErrInvalidAttachment = errors.New("invalid attachment")
+	// ErrAssignmentDeadlinePassed indicates the student's effective deadline has passed.
+	ErrAssignmentDeadlinePassed = errors.New("assignment deadline passed")
+	// ErrExtensionNotFound indicates the student has no deadline extension.
+	ErrExtensionNotFound = errors.New("extension not found")
+	// ErrStudentNotInCourse indicates the target student is not enrolled in the course.
+	ErrStudentNotInCourse = errors.New("student not enrolled in course")
+	// ErrSelfGrading indicates a grader tried to grade their own submission.
+	ErrSelfGrading = errors.New("cannot grade own submission")
 )
 
+// GradeNotifier delivers graded results to a student's WeChat Work account.
+type GradeNotifier interface {
+	NotifyGrade(ctx context.Context, studentWecomID, assignmentTitle string, grade int, feedback string) error
+}
+
+// gradeNotifyTimeout bounds a single grade notification attempt.
+const gradeNotifyTimeout = 15 * time.Second
+
+// gradeNotifyAttempts is how many times a failed grade notification is tried.
+const gradeNotifyAttempts = 3
+
 // AssignmentService handles assignment CRUD and grading workflows.
 type AssignmentService struct {
-	repo *repositories.AssignmentRepository
+	repo     *repositories.AssignmentRepository
+	notifier GradeNotifier
+	comments CommentNotifier
+	queue    *jobs.Queue
+	receipts *ReceiptSigner
 }
 
 // NewAssignmentService builds an AssignmentService with its repository.
@@ -26,12 +66,28 @@ func NewAssignmentService(db *gorm.DB) *AssignmentService {
 	return &AssignmentService{repo: repositories.NewAssignmentRepository(db)}
 }
 
+// WithGradeNotifier enables grade notifications for courses that opt in via
+// ModuleWecomNotify. Notifications are delivered on queue.
+func (s *AssignmentService) WithGradeNotifier(n GradeNotifier, queue *jobs.Queue) *AssignmentService {
+	s.notifier = n
+	s.queue = queue
+	return s
+}
+
 // CreateAssignmentRequest contains the fields required to create an assignment.
 type CreateAssignmentRequest struct {
 	CourseID    uint
+	ChapterID   *uint // optional; must be a chapter of the course
 	Title       string
 	Description string
+	Deadline    *ScheduleTime // read in the course's time zone when it has no offset
 	AllowFile   bool
+	// GroupSubmission makes the assignment take one submission per student group.
+	GroupSubmission bool
+	// Late policy, see models.Assignment; the zero value rejects late work.
+	AllowLate         bool
+	LatePenaltyPerDay int
+	LatePenaltyFloor  int
 }
 
 // SubmitAssignmentRequest contains the student submission payload.
@@ -42,20 +98,26 @@ type SubmitAssignmentRequest struct {
 
 // CourseAssignmentStats summarizes assignment progress for a course.
 type CourseAssignmentStats struct {
-	TotalAssignments int     `json:"total_assignments"`
-	PendingCount     int     `json:"pending_count"`
-	SubmittedCount   int     `json:"submitted_count"`
-	AverageGrade     float64 `json:"average_grade"`
+	TotalAssignments  int      `json:"total_assignments"`
+	PendingCount      int      `json:"pending_count"`
+	SubmittedCount    int      `json:"submitted_count"`
+	NotSubmittedCount int      `json:"not_submitted_count"`
+	UngradedCount     int      `json:"ungraded_count"`
+	GradedCount       int      `json:"graded_count"`
+	AverageGrade      *float64 `json:"average_grade"` // graded submissions only; nil until one is graded
 }
 
 // AssignmentDetailedStats summarizes grading progress for a single assignment.
+// Grade aggregates cover graded submissions only and are nil until one is graded.
 type AssignmentDetailedStats struct {
-	TotalStudents  int     `json:"total_students"`
-	SubmittedCount int     `json:"submitted_count"`
-	GradedCount    int     `json:"graded_count"`
-	AverageGrade   float64 `json:"average_grade"`
-	HighestGrade   int     `json:"highest_grade"`
-	LowestGrade    int     `json:"lowest_grade"`
+	TotalStudents     int      `json:"total_students"`
+	SubmittedCount    int      `json:"submitted_count"`
+	NotSubmittedCount int      `json:"not_submitted_count"`
+	UngradedCount     int      `json:"ungraded_count"`
+	GradedCount       int      `json:"graded_count"`
+	AverageGrade      *float64 `json:"average_grade"`
+	HighestGrade      *int     `json:"highest_grade"`
+	LowestGrade       *int     `json:"lowest_grade"`
 }
 
 // AssignmentGradingContext bundles the submission, assignment, and course.
@@ -77,26 +139,250 @@ func (s *AssignmentService) CreateAssignment(ctx context.Context, user UserInfo,
 	if course.TeacherID != user.ID && user.Role != "admin" {
 		return nil, ErrAccessDenied
 	}
+	if err := validateLatePolicy(req.AllowLate, req.LatePenaltyPerDay, req.LatePenaltyFloor); err != nil {
+		return nil, err
+	}
+	if req.ChapterID != nil {
+		if err := validateContentChapter(ctx, s.repo.FindChapter, course.ID, *req.ChapterID); err != nil {
+			return nil, err
+		}
+	}
 	assignment := &models.Assignment{
 		CourseID:    req.CourseID,
+		ChapterID:   req.ChapterID,
 		TeacherID:   user.ID,
 		Title:       req.Title,
 		Description: req.Description,
+		Deadline:    resolveSchedule(req.Deadline, courseLocation(course)),
 		AllowFile:   req.AllowFile,
+
+		GroupSubmission: req.GroupSubmission,
+
+		AllowLate:         req.AllowLate,
+		LatePenaltyPerDay: req.LatePenaltyPerDay,
+		LatePenaltyFloor:  req.LatePenaltyFloor,
 	}
 	if err := s.repo.CreateAssignment(ctx, assignment); err != nil {
 		return nil, err
 	}
+	localizeAssignment(assignment, courseLocation(course))
 	return assignment, nil
 }
 
-// ListAssignments returns assignments for a course.
-func (s *AssignmentService) ListAssignments(ctx context.Context, courseID uint) ([]models.Assignment, error) {
-	return s.repo.ListByCourse(ctx, courseID)
+// ListAssignments returns assignments for a course; students only see published ones.
+// Deadlines are written in the course's time zone.
+func (s *AssignmentService) ListAssignments(ctx context.Context, courseID uint, user UserInfo) ([]models.Assignment, error) {
+	assignments, err := s.repo.ListByCourse(ctx, courseID, !user.IsTeacher())
+	if err != nil || len(assignments) == 0 {
+		return assignments, err
+	}
+	loc, err := s.courseLocation(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range assignments {
+		localizeAssignment(&assignments[i], loc)
+	}
+	return assignments, nil
+}
+
+// courseLocation returns the time zone the course's deadlines are read and
+// shown in.
+func (s *AssignmentService) courseLocation(ctx context.Context, courseID uint) (*time.Location, error) {
+	course, err := s.repo.FindCourse(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	return courseLocation(course), nil
+}
+
+// GetAssignment fetches a single assignment with its attachments; drafts are hidden from students.
+// Deadlines are written in the course's time zone.
+func (s *AssignmentService) GetAssignment(ctx context.Context, assignmentID uint, user UserInfo) (*models.Assignment, error) {
+	assignment, err := s.repo.FindAssignmentWithAttachments(ctx, assignmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAssignmentNotFound
+		}
+		return nil, err
+	}
+	if !user.IsTeacher() {
+		if !assignment.IsPublished {
+			return nil, ErrAssignmentNotAvailable
+		}
+		if assignment.EffectiveDeadline, err = s.effectiveDeadline(ctx, assignment, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	loc, err := s.courseLocation(ctx, assignment.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	localizeAssignment(assignment, loc)
+	return assignment, nil
+}
+
+// effectiveDeadline returns the student's extension deadline if one exists,
+// otherwise the assignment deadline (nil = no deadline).
+func (s *AssignmentService) effectiveDeadline(ctx context.Context, assignment *models.Assignment, studentID uint) (*time.Time, error) {
+	extension, err := s.repo.FindExtension(ctx, assignment.ID, studentID)
+	if err == nil {
+		return &extension.Deadline, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return assignment.Deadline, nil
+}
+
+// GrantExtension sets a per-student deadline override, replacing any existing one.
+// A deadline without an offset is read in the course's time zone.
+func (s *AssignmentService) GrantExtension(ctx context.Context, assignmentID, studentID uint, user UserInfo, deadline ScheduleTime) (*models.AssignmentExtension, error) {
+	assignment, err := s.findManagedAssignment(ctx, assignmentID, user)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := s.courseLocation(ctx, assignment.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	enrolled, err := s.repo.HasEnrollment(ctx, assignment.CourseID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if !enrolled {
+		return nil, ErrStudentNotInCourse
+	}
+	extension := &models.AssignmentExtension{
+		AssignmentID: assignmentID,
+		StudentID:    studentID,
+		Deadline:     deadline.In(loc),
+		GrantedByID:  user.ID,
+	}
+	if err := s.repo.UpsertExtension(ctx, extension); err != nil {
+		return nil, err
+	}
+	extension, err = s.repo.FindExtension(ctx, assignmentID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	extension.Deadline = extension.Deadline.In(loc)
+	return extension, nil
+}
+
+// RevokeExtension removes a student's deadline override.
+func (s *AssignmentService) RevokeExtension(ctx context.Context, assignmentID, studentID uint, user UserInfo) error {
+	if _, err := s.findManagedAssignment(ctx, assignmentID, user); err != nil {
+		return err
+	}
+	deleted, err := s.repo.DeleteExtension(ctx, assignmentID, studentID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrExtensionNotFound
+	}
+	return nil
+}
+
+// PublishAssignment makes a draft assignment visible to students.
+func (s *AssignmentService) PublishAssignment(ctx context.Context, assignmentID uint, user UserInfo) (*models.Assignment, error) {
+	assignment, err := s.findManagedAssignment(ctx, assignmentID, user)
+	if err != nil {
+		return nil, err
+	}
+	assignment.IsPublished = true
+	if err := s.repo.SaveAssignment(ctx, assignment); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// UnpublishAssignment returns an assignment to draft when nobody has submitted.
+func (s *AssignmentService) UnpublishAssignment(ctx context.Context, assignmentID uint, user UserInfo) (*models.Assignment, error) {
+	assignment, err := s.findManagedAssignment(ctx, assignmentID, user)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.repo.CountSubmissionsByAssignment(ctx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAssignmentUnpublishNotAllowed
+	}
+	assignment.IsPublished = false
+	if err := s.repo.SaveAssignment(ctx, assignment); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// MoveAssignmentToChapter attaches the assignment to a chapter of its course,
+// or detaches it when chapterID is nil.
+func (s *AssignmentService) MoveAssignmentToChapter(ctx context.Context, assignmentID uint, user UserInfo, chapterID *uint) (*models.Assignment, error) {
+	assignment, err := s.findManagedAssignment(ctx, assignmentID, user)
+	if err != nil {
+		return nil, err
+	}
+	if chapterID != nil {
+		if err := validateContentChapter(ctx, s.repo.FindChapter, assignment.CourseID, *chapterID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.UpdateAssignmentChapter(ctx, assignment, chapterID); err != nil {
+		return nil, err
+	}
+	assignment.ChapterID = chapterID
+	return assignment, nil
+}
+
+// AddAttachmentRequest contains the fields for a new assignment attachment.
+type AddAttachmentRequest struct {
+	Title string
+	URL   string
+}
+
+// AddAttachment attaches a reference file URL to an assignment.
+func (s *AssignmentService) AddAttachment(ctx context.Context, assignmentID uint, user UserInfo, req AddAttachmentRequest) (*models.AssignmentAttachment, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" || len(title) > 256 || !isHTTPURL(req.URL) {
+		return nil, ErrInvalidAttachment
+	}
+	if _, err := s.findManagedAssignment(ctx, assignmentID, user); err != nil {
+		return nil, err
+	}
+	attachment := &models.AssignmentAttachment{
+		AssignmentID: assignmentID,
+		Title:        title,
+		URL:          req.URL,
+	}
+	if err := s.repo.CreateAttachment(ctx, attachment); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// RemoveAttachment deletes an attachment from an assignment.
+func (s *AssignmentService) RemoveAttachment(ctx context.Context, assignmentID, attachmentID uint, user UserInfo) error {
+	if _, err := s.findManagedAssignment(ctx, assignmentID, user); err != nil {
+		return err
+	}
+	attachment, err := s.repo.FindAttachment(ctx, assignmentID, attachmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAttachmentNotFound
+		}
+		return err
+	}
+	return s.repo.DeleteAttachment(ctx, attachment)
 }
 
-// GetAssignment fetches a single assignment by ID.
-func (s *AssignmentService) GetAssignment(ctx context.Context, assignmentID uint) (*models.Assignment, error) {
+// findManagedAssignment loads an assignment the user may edit (course teacher or admin).
+func (s *AssignmentService) findManagedAssignment(ctx context.Context, assignmentID uint, user UserInfo) (*models.Assignment, error) {
 	assignment, err := s.repo.FindAssignment(ctx, assignmentID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -104,25 +390,48 @@ func (s *AssignmentService) GetAssignment(ctx context.Context, assignmentID uint
 		}
 		return nil, err
 	}
+	course, err := s.repo.FindCourse(ctx, assignment.CourseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	if course.TeacherID != user.ID && user.Role != "admin" {
+		return nil, ErrAccessDenied
+	}
 	return assignment, nil
 }
 
 // SubmitAssignment creates or updates a student's submission.
 func (s *AssignmentService) SubmitAssignment(ctx context.Context, assignmentID uint, user UserInfo, req SubmitAssignmentRequest) (*models.Submission, bool, error) {
-	if _, err := s.repo.FindAssignment(ctx, assignmentID); err != nil {
+	assignment, err := s.repo.FindAssignment(ctx, assignmentID)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, false, ErrAssignmentNotFound
 		}
 		return nil, false, err
 	}
+	if !assignment.IsPublished {
+		return nil, false, ErrAssignmentNotAvailable
+	}
+	deadline, err := s.effectiveDeadline(ctx, assignment, user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	var lateAt *time.Time
+	if now := time.Now(); deadline != nil && now.After(*deadline) {
+		if !assignment.AllowLate {
+			return nil, false, ErrAssignmentDeadlinePassed
+		}
+		lateAt = &now
+	}
+	if assignment.GroupSubmission {
+		return s.submitGroupAssignment(ctx, assignment, user, req, lateAt)
+	}
 	existing, err := s.repo.FindSubmission(ctx, assignmentID, user.ID)
 	if err == nil {
-		existing.Content = req.Content
-		existing.FileURL = req.FileURL
-		if err := s.repo.SaveSubmission(ctx, existing); err != nil {
-			return nil, false, err
-		}
-		return existing, false, nil
+		return s.resubmit(ctx, existing, req, lateAt)
 	}
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, false, err
@@ -132,6 +441,7 @@ func (s *AssignmentService) SubmitAssignment(ctx context.Context, assignmentID u
 		StudentID:    user.ID,
 		Content:      req.Content,
 		FileURL:      req.FileURL,
+		LateAt:       lateAt,
 	}
 	if err := s.repo.CreateSubmission(ctx, submission); err != nil {
 		return nil, false, err
@@ -139,10 +449,73 @@ func (s *AssignmentService) SubmitAssignment(ctx context.Context, assignmentID u
 	return submission, true, nil
 }
 
+// submitGroupAssignment creates or updates the single submission of the
+// caller's group. The submission row belongs to the member who first submitted
+// it; a student who already submitted for a previous group cannot submit
+// again, since each student holds at most one submission per assignment.
+func (s *AssignmentService) submitGroupAssignment(ctx context.Context, assignment *models.Assignment, user UserInfo, req SubmitAssignmentRequest, lateAt *time.Time) (*models.Submission, bool, error) {
+	group, err := s.findStudentGroup(ctx, assignment, user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	existing, err := s.repo.FindGroupSubmission(ctx, assignment.ID, group.ID)
+	if err == nil {
+		return s.resubmit(ctx, existing, req, lateAt)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	if _, err := s.repo.FindSubmission(ctx, assignment.ID, user.ID); err == nil {
+		return nil, false, ErrSubmittedWithOtherGroup
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	submission := &models.Submission{
+		AssignmentID: assignment.ID,
+		StudentID:    user.ID,
+		GroupID:      &group.ID,
+		Content:      req.Content,
+		FileURL:      req.FileURL,
+		LateAt:       lateAt,
+	}
+	if err := s.repo.CreateSubmission(ctx, submission); err != nil {
+		// Another member created the group's submission since the lookup
+		// above; the unique index on (assignment_id, group_id) refused this
+		// one, so update theirs instead.
+		if repositories.IsDuplicateKey(err) {
+			if existing, findErr := s.repo.FindGroupSubmission(ctx, assignment.ID, group.ID); findErr == nil {
+				return s.resubmit(ctx, existing, req, lateAt)
+			}
+		}
+		return nil, false, err
+	}
+	return submission, true, nil
+}
+
+// resubmit replaces the content of an existing submission. lateAt is nil
+// when the new content arrived on time.
+func (s *AssignmentService) resubmit(ctx context.Context, existing *models.Submission, req SubmitAssignmentRequest, lateAt *time.Time) (*models.Submission, bool, error) {
+	existing.Content = req.Content
+	existing.FileURL = req.FileURL
+	existing.LateAt = lateAt
+	// A suggestion for the old content no longer applies.
+	existing.AISuggestion = ""
+	existing.AISuggestedGrade = nil
+	existing.AIGradedAt = nil
+	if err := s.repo.SaveSubmission(ctx, existing); err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
 // GetMySubmission fetches the current user's submission for the assignment.
+// For group assignments this is their group's shared submission.
 func (s *AssignmentService) GetMySubmission(ctx context.Context, assignmentID uint, user UserInfo) (*models.Submission, bool, error) {
-	submission, err := s.repo.FindSubmission(ctx, assignmentID, user.ID)
+	submission, err := s.findMySubmission(ctx, assignmentID, user.ID)
 	if err == nil {
+		if !user.IsTeacher() {
+			hideAISuggestion(submission)
+		}
 		return submission, true, nil
 	}
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -151,12 +524,25 @@ func (s *AssignmentService) GetMySubmission(ctx context.Context, assignmentID ui
 	return nil, false, err
 }
 
+func (s *AssignmentService) findMySubmission(ctx context.Context, assignmentID uint, studentID uint) (*models.Submission, error) {
+	assignment, err := s.repo.FindAssignment(ctx, assignmentID)
+	if err != nil || !assignment.GroupSubmission {
+		return s.repo.FindSubmission(ctx, assignmentID, studentID)
+	}
+	group, err := s.repo.FindGroupByMember(ctx, assignment.CourseID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindGroupSubmission(ctx, assignmentID, group.ID)
+}
+
 // ListSubmissions lists all submissions for an assignment.
 func (s *AssignmentService) ListSubmissions(ctx context.Context, assignmentID uint) ([]models.Submission, error) {
 	return s.repo.ListSubmissionsByAssignment(ctx, assignmentID)
 }
 
-// GetSubmissionForGrading loads submission details for grading.
+// GetSubmissionForGrading loads submission details for grading. It is shared by
+// manual and AI grading and rejects graders who submitted the work themselves.
 func (s *AssignmentService) GetSubmissionForGrading(ctx context.Context, submissionID uint, user UserInfo) (*AssignmentGradingContext, error) {
 	submission, err := s.repo.FindSubmissionByID(ctx, submissionID)
 	if err != nil {
@@ -182,6 +568,10 @@ func (s *AssignmentService) GetSubmissionForGrading(ctx context.Context, submiss
 	if course.TeacherID != user.ID && user.Role != "admin" && user.Role != "assistant" {
 		return nil, ErrAccessDenied
 	}
+	// A TA may also be a student elsewhere; nobody grades their own work.
+	if submission.StudentID == user.ID {
+		return nil, ErrSelfGrading
+	}
 	return &AssignmentGradingContext{
 		Submission: *submission,
 		Assignment: *assignment,
@@ -189,21 +579,154 @@ func (s *AssignmentService) GetSubmissionForGrading(ctx context.Context, submiss
 	}, nil
 }
 
+// aiSuggestedGradePattern extracts the score from the "建议分数: N" line the AI
+// grading prompt asks for.
+var aiSuggestedGradePattern = regexp.MustCompile(`建议分数\s*[:：]\s*(\d{1,3})`)
+
+// SaveAISuggestion stores an AI grading suggestion on the submission so staff
+// can revisit it later. The suggested grade is parsed from the reply when
+// present and within 0-100; it never sets the actual grade. The AI call can
+// take minutes, so only the suggestion columns are written and the
+// submission is reloaded, keeping any resubmission or grade made meanwhile.
+func (s *AssignmentService) SaveAISuggestion(ctx context.Context, data *AssignmentGradingContext, suggestion string) (*models.Submission, error) {
+	var suggestedGrade *int
+	if m := aiSuggestedGradePattern.FindStringSubmatch(suggestion); m != nil {
+		if grade, err := strconv.Atoi(m[1]); err == nil && grade <= 100 {
+			suggestedGrade = &grade
+		}
+	}
+	if err := s.repo.UpdateSubmissionFields(ctx, data.Submission.ID, map[string]any{
+		"ai_suggestion":      suggestion,
+		"ai_suggested_grade": suggestedGrade,
+		"ai_graded_at":       time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	return s.repo.FindSubmissionByID(ctx, data.Submission.ID)
+}
+
+// hideAISuggestion clears staff-only AI grading fields before a submission is
+// shown to a student.
+func hideAISuggestion(submission *models.Submission) {
+	submission.AISuggestion = ""
+	submission.AISuggestedGrade = nil
+	submission.AIGradedAt = nil
+}
+
 // GradeSubmission sets the grade and feedback on a submission.
 func (s *AssignmentService) GradeSubmission(ctx context.Context, submissionID uint, user UserInfo, grade int, feedback string) (*models.Submission, error) {
 	ctxData, err := s.GetSubmissionForGrading(ctx, submissionID, user)
 	if err != nil {
 		return nil, err
 	}
+	previous := ctxData.Submission.Grade
+	penalty, err := s.latePenalty(ctx, &ctxData.Assignment, &ctxData.Submission, grade)
+	if err != nil {
+		return nil, err
+	}
+	raw := grade
+	grade -= penalty
+	ctxData.Submission.RawGrade = &raw
+	ctxData.Submission.LatePenalty = penalty
 	ctxData.Submission.Grade = &grade
 	ctxData.Submission.Feedback = feedback
 	ctxData.Submission.GradedBy = &user.ID
-	if err := s.repo.SaveSubmission(ctx, &ctxData.Submission); err != nil {
+	err = s.repo.Transaction(ctx, func(tx *repositories.AssignmentRepository) error {
+		if err := tx.SaveSubmission(ctx, &ctxData.Submission); err != nil {
+			return err
+		}
+		// Only replacing an existing grade is audited; first grades are routine.
+		if previous == nil || *previous == grade {
+			return nil
+		}
+		return RecordAudit(ctx, tx.Audit(), AuditEntry{
+			Actor:      user,
+			Action:     AuditGradeOverride,
+			TargetType: "submission",
+			TargetID:   ctxData.Submission.ID,
+			Details: map[string]interface{}{
+				"assignment_id":  ctxData.Assignment.ID,
+				"student_id":     ctxData.Submission.StudentID,
+				"previous_grade": *previous,
+				"grade":          grade,
+			},
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
+	s.notifyGrade(ctx, ctxData, grade, feedback)
 	return &ctxData.Submission, nil
 }
 
+// notifyGrade queues a push of the grade to the student, or to every member
+// for a group submission. It is a no-op unless a notifier is set, the course
+// opted in, and the student has a WeCom ID; delivery failures are retried and
+// logged by the queue and never affect grading.
+func (s *AssignmentService) notifyGrade(ctx context.Context, data *AssignmentGradingContext, grade int, feedback string) {
+	if s.notifier == nil || s.queue == nil {
+		return
+	}
+	modules, err := parseEnabledModules(data.Course.EnabledModules)
+	if err != nil || !containsString(modules, ModuleWecomNotify) {
+		return
+	}
+	recipients, err := s.submissionStudentIDs(ctx, &data.Submission)
+	if err != nil {
+		return
+	}
+
+	notifier := s.notifier
+	title := data.Assignment.Title
+	submissionID := data.Submission.ID
+	for _, studentID := range recipients {
+		student, err := s.repo.FindUser(ctx, studentID)
+		if err != nil || student.WecomUserID == "" {
+			continue
+		}
+		wecomID := student.WecomUserID
+		err = s.queue.Enqueue(jobs.Task{
+			Name:        fmt.Sprintf("grade_notification:submission_%d:student_%d", submissionID, studentID),
+			MaxAttempts: gradeNotifyAttempts,
+			Timeout:     gradeNotifyTimeout,
+			Run: func(ctx context.Context) error {
+				return notifier.NotifyGrade(ctx, wecomID, title, grade, feedback)
+			},
+		})
+		if err != nil {
+			logger.Log.Warn("grade notification not queued", slog.Uint64("submission_id", uint64(submissionID)), slog.Any("error", err))
+		}
+	}
+}
+
+// submissionStudentIDs returns the student who made the submission followed by
+// the other current members of the submitting group, if any.
+func (s *AssignmentService) submissionStudentIDs(ctx context.Context, submission *models.Submission) ([]uint, error) {
+	ids := []uint{submission.StudentID}
+	if submission.GroupID == nil {
+		return ids, nil
+	}
+	members, err := s.repo.ListGroupMembers(ctx, []uint{*submission.GroupID})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if m.UserID != submission.StudentID {
+			ids = append(ids, m.UserID)
+		}
+	}
+	return ids, nil
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
 // GetCourseAssignmentStats returns aggregated stats for a course.
 func (s *AssignmentService) GetCourseAssignmentStats(ctx context.Context, courseID uint, user UserInfo) (CourseAssignmentStats, error) {
 	var stats CourseAssignmentStats
@@ -219,22 +742,39 @@ func (s *AssignmentService) GetCourseAssignmentStats(ctx context.Context, course
 		if err != nil {
 			return stats, err
 		}
+		gradedCount, err := s.repo.CountGradedSubmissionsByCourseAndStudent(ctx, courseID, user.ID)
+		if err != nil {
+			return stats, err
+		}
 		stats.SubmittedCount = int(submittedCount)
-		stats.PendingCount = int(totalAssignments) - int(submittedCount)
-
-		avgGrade, err := s.repo.AvgGradeByCourseAndStudent(ctx, courseID, user.ID)
-		if err == nil {
-			stats.AverageGrade = avgGrade
+		stats.GradedCount = int(gradedCount)
+		stats.UngradedCount = stats.SubmittedCount - stats.GradedCount
+		stats.NotSubmittedCount = max(stats.TotalAssignments-stats.SubmittedCount, 0)
+		stats.PendingCount = stats.NotSubmittedCount
+
+		if gradedCount > 0 {
+			if avgGrade, err := s.repo.AvgGradeByCourseAndStudent(ctx, courseID, user.ID); err == nil {
+				stats.AverageGrade = &avgGrade
+			}
 		}
 	} else {
 		pendingCount, err := s.repo.CountPendingGradingByCourse(ctx, courseID)
-		if err == nil {
-			stats.PendingCount = int(pendingCount)
+		if err != nil {
+			return stats, err
 		}
-
-		avgGrade, err := s.repo.AvgGradeByCourse(ctx, courseID)
-		if err == nil {
-			stats.AverageGrade = avgGrade
+		gradedCount, err := s.repo.CountGradedSubmissionsByCourse(ctx, courseID)
+		if err != nil {
+			return stats, err
+		}
+		stats.PendingCount = int(pendingCount)
+		stats.UngradedCount = int(pendingCount)
+		stats.GradedCount = int(gradedCount)
+		stats.SubmittedCount = stats.UngradedCount + stats.GradedCount
+
+		if gradedCount > 0 {
+			if avgGrade, err := s.repo.AvgGradeByCourse(ctx, courseID); err == nil {
+				stats.AverageGrade = &avgGrade
+			}
 		}
 	}
 
@@ -274,34 +814,51 @@ func (s *AssignmentService) GetAssignmentStats(ctx context.Context, assignmentID
 		return stats, err
 	}
 
-	stats.SubmittedCount = len(submissions)
+	// Group submissions count once for every member, who all share the grade.
+	var grades []*int
+	if assignment.GroupSubmission {
+		if grades, err = s.groupSubmissionGrades(ctx, submissions); err != nil {
+			return stats, err
+		}
+	} else {
+		for _, sub := range submissions {
+			grades = append(grades, sub.Grade)
+		}
+	}
+
+	stats.SubmittedCount = len(grades)
+	stats.NotSubmittedCount = max(stats.TotalStudents-stats.SubmittedCount, 0)
 
 	totalGrade := 0
-	gradedCount := 0
-	maxGrade := 0
-	minGrade := 100
-
-	for _, s := range submissions {
-		if s.Grade == nil {
+	for _, grade := range grades {
+		if grade == nil {
+			stats.UngradedCount++
 			continue
 		}
-		g := *s.Grade
+		g := *grade
 		totalGrade += g
-		gradedCount++
-		if g > maxGrade {
-			maxGrade = g
+		stats.GradedCount++
+		if stats.HighestGrade == nil || g > *stats.HighestGrade {
+			stats.HighestGrade = &g
 		}
-		if g < minGrade {
-			minGrade = g
+		if stats.LowestGrade == nil || g < *stats.LowestGrade {
+			stats.LowestGrade = &g
 		}
 	}
 
-	stats.GradedCount = gradedCount
-	if gradedCount > 0 {
-		stats.AverageGrade = float64(totalGrade) / float64(gradedCount)
-		stats.HighestGrade = maxGrade
-		stats.LowestGrade = minGrade
+	if stats.GradedCount > 0 {
+		avg := float64(totalGrade) / float64(stats.GradedCount)
+		stats.AverageGrade = &avg
 	}
 
 	return stats, nil
 }
+
+// isHTTPURL reports whether raw is an absolute http(s) URL that fits the 1024-byte column.
+func isHTTPURL(raw string) bool {
+	if raw == "" || len(raw) > 1024 {
+		return false
+	}
+	u, err := url.Parse(raw)
+	return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https")
+}
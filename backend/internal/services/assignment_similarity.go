@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/similarity"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidThreshold indicates a similarity threshold outside (0, 1].
+	ErrInvalidThreshold = errors.New("threshold must be greater than 0 and at most 1")
+	// ErrSimilarityReportNotFound indicates no similarity check has been run for the assignment.
+	ErrSimilarityReportNotFound = errors.New("similarity report not found")
+	// ErrSimilarityBusy indicates the background queue could not accept the check.
+	ErrSimilarityBusy = errors.New("similarity check could not be queued")
+)
+
+const (
+	// DefaultSimilarityThreshold is used when the request does not set one.
+	DefaultSimilarityThreshold = 0.5
+	// similaritySyncLimit is the largest class checked within the request;
+	// bigger ones are checked on the job queue.
+	similaritySyncLimit = 40
+	// maxSimilaritySubmissions caps how many submissions one check compares.
+	maxSimilaritySubmissions = 300
+	// maxSimilarityTextBytes caps how much of each submission is compared.
+	maxSimilarityTextBytes = 20000
+	// similarityTimeout bounds a queued check.
+	similarityTimeout = 2 * time.Minute
+)
+
+// Similarity report statuses.
+const (
+	SimilarityPending = "pending"
+	SimilarityDone    = "done"
+	SimilarityFailed  = "failed"
+)
+
+// SimilarityPair is one flagged pair in a similarity report.
+type SimilarityPair struct {
+	SubmissionAID uint    `json:"submission_a_id"`
+	StudentAID    uint    `json:"student_a_id"`
+	SubmissionBID uint    `json:"submission_b_id"`
+	StudentBID    uint    `json:"student_b_id"`
+	Score         float64 `json:"score"`
+}
+
+// WithQueue sets the queue that runs similarity checks for large classes.
+// Without one every check runs within the request.
+func (s *AssignmentService) WithQueue(queue *jobs.Queue) *AssignmentService {
+	s.queue = queue
+	return s
+}
+
+// CheckSimilarity compares the text of every submission to an assignment and
+// records the pairs whose Jaccard similarity reaches threshold. Small classes
+// are checked immediately and the report is returned done; larger ones are
+// queued and the report is returned pending, to be read back later with
+// GetSimilarityReport. Only the course teacher or an admin may run a check.
+func (s *AssignmentService) CheckSimilarity(ctx context.Context, assignmentID uint, user UserInfo, threshold float64) (*models.SimilarityReport, error) {
+	if threshold <= 0 || threshold > 1 || math.IsNaN(threshold) {
+		return nil, ErrInvalidThreshold
+	}
+	assignment, err := s.findManagedAssignment(ctx, assignmentID, user)
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := s.repo.ListSubmissionsByAssignment(ctx, assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var texts []models.Submission
+	for _, sub := range submissions {
+		if strings.TrimSpace(sub.Content) != "" {
+			texts = append(texts, sub)
+		}
+	}
+	report := &models.SimilarityReport{
+		AssignmentID:  assignment.ID,
+		RequestedByID: user.ID,
+		Status:        SimilarityPending,
+		Threshold:     threshold,
+	}
+	if len(texts) > maxSimilaritySubmissions {
+		texts = texts[:maxSimilaritySubmissions]
+		report.Truncated = true
+	}
+	report.SubmissionCount = len(texts)
+	if err := s.repo.CreateSimilarityReport(ctx, report); err != nil {
+		return nil, err
+	}
+
+	if len(texts) <= similaritySyncLimit || s.queue == nil {
+		if err := s.runSimilarity(ctx, report, texts); err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+
+	reportCopy := *report
+	err = s.queue.Enqueue(jobs.Task{
+		Name:    fmt.Sprintf("similarity:report_%d", report.ID),
+		Timeout: similarityTimeout,
+		Run: func(ctx context.Context) error {
+			err := s.runSimilarity(ctx, &reportCopy, texts)
+			if err != nil {
+				// The task is not retried, so record the failure; ctx may
+				// already be past its deadline.
+				_ = s.repo.UpdateSimilarityReport(context.WithoutCancel(ctx), &reportCopy, map[string]interface{}{
+					"status": SimilarityFailed,
+					"error":  err.Error(),
+				})
+			}
+			return err
+		},
+	})
+	if err != nil {
+		logger.Log.Warn("similarity check not queued", slog.Uint64("report_id", uint64(report.ID)), slog.Any("error", err))
+		_ = s.repo.UpdateSimilarityReport(ctx, report, map[string]interface{}{
+			"status": SimilarityFailed,
+			"error":  err.Error(),
+		})
+		return nil, ErrSimilarityBusy
+	}
+	return report, nil
+}
+
+// GetSimilarityReport returns the most recent similarity report for an assignment.
+func (s *AssignmentService) GetSimilarityReport(ctx context.Context, assignmentID uint, user UserInfo) (*models.SimilarityReport, error) {
+	if _, err := s.findManagedAssignment(ctx, assignmentID, user); err != nil {
+		return nil, err
+	}
+	report, err := s.repo.FindLatestSimilarityReport(ctx, assignmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSimilarityReportNotFound
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+// runSimilarity computes the pairs for report and stores them, marking the
+// report done.
+func (s *AssignmentService) runSimilarity(ctx context.Context, report *models.SimilarityReport, submissions []models.Submission) error {
+	docs := make([]similarity.Document, len(submissions))
+	students := make(map[uint]uint, len(submissions))
+	for i, sub := range submissions {
+		text := sub.Content
+		if len(text) > maxSimilarityTextBytes {
+			text = strings.ToValidUTF8(text[:maxSimilarityTextBytes], "")
+		}
+		docs[i] = similarity.Document{ID: sub.ID, Text: text}
+		students[sub.ID] = sub.StudentID
+	}
+
+	found := similarity.FindPairs(docs, report.Threshold)
+	pairs := make([]SimilarityPair, len(found))
+	for i, p := range found {
+		pairs[i] = SimilarityPair{
+			SubmissionAID: p.A,
+			StudentAID:    students[p.A],
+			SubmissionBID: p.B,
+			StudentBID:    students[p.B],
+			Score:         math.Round(p.Score*1000) / 1000,
+		}
+	}
+	pairsJSON, err := json.Marshal(pairs)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.repo.UpdateSimilarityReport(ctx, report, map[string]interface{}{
+		"status":       SimilarityDone,
+		"pairs":        datatypes.JSON(pairsJSON),
+		"completed_at": &now,
+	})
+}
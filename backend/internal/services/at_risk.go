@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// Signals an at-risk student can be flagged on.
+const (
+	RiskAttendance = "attendance" // attendance rate
+	RiskSubmission = "submission" // assignment submission rate
+	RiskQuiz       = "quiz"       // quiz average
+)
+
+// ErrInvalidRiskPolicy indicates a threshold out of range, or weights that
+// are negative or all zero.
+var ErrInvalidRiskPolicy = errors.New("invalid risk policy")
+
+// RiskPolicy sets when a student is flagged and how much each signal weighs
+// in their risk score. Rates are fractions from 0 to 1, the quiz average a
+// percentage.
+type RiskPolicy struct {
+	MinAttendanceRate float64 `json:"min_attendance_rate"`
+	MinSubmissionRate float64 `json:"min_submission_rate"`
+	MinQuizAverage    float64 `json:"min_quiz_average"`
+	AttendanceWeight  float64 `json:"attendance_weight"`
+	SubmissionWeight  float64 `json:"submission_weight"`
+	QuizWeight        float64 `json:"quiz_weight"`
+}
+
+// DefaultRiskPolicy flags students attending under 80% of sessions,
+// submitting under 70% of due assignments or averaging under 60% on
+// quizzes, with all signals weighted alike.
+var DefaultRiskPolicy = RiskPolicy{
+	MinAttendanceRate: 0.8,
+	MinSubmissionRate: 0.7,
+	MinQuizAverage:    60,
+	AttendanceWeight:  1,
+	SubmissionWeight:  1,
+	QuizWeight:        1,
+}
+
+// Validate checks the thresholds are in range and the weights finite,
+// non-negative and not all zero.
+func (p RiskPolicy) Validate() error {
+	for _, v := range []float64{p.MinAttendanceRate, p.MinSubmissionRate, p.MinQuizAverage, p.AttendanceWeight, p.SubmissionWeight, p.QuizWeight} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ErrInvalidRiskPolicy
+		}
+	}
+	if p.MinAttendanceRate < 0 || p.MinAttendanceRate > 1 ||
+		p.MinSubmissionRate < 0 || p.MinSubmissionRate > 1 ||
+		p.MinQuizAverage < 0 || p.MinQuizAverage > 100 {
+		return ErrInvalidRiskPolicy
+	}
+	if p.AttendanceWeight < 0 || p.SubmissionWeight < 0 || p.QuizWeight < 0 {
+		return ErrInvalidRiskPolicy
+	}
+	if p.AttendanceWeight+p.SubmissionWeight+p.QuizWeight == 0 {
+		return ErrInvalidRiskPolicy
+	}
+	return nil
+}
+
+// RiskReason is one signal a student fell below the threshold on.
+type RiskReason struct {
+	Signal    string  `json:"signal"` // attendance, submission or quiz
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+// AtRiskStudent is a flagged student with their signals. A signal is nil
+// when there is nothing to rate it on yet: no attendance sessions, no
+// assignment past its deadline or no graded quiz attempt.
+type AtRiskStudent struct {
+	StudentID      uint         `json:"student_id"`
+	Username       string       `json:"username"`
+	Name           string       `json:"name"`
+	AttendanceRate *float64     `json:"attendance_rate"`
+	SubmissionRate *float64     `json:"submission_rate"`
+	QuizAverage    *float64     `json:"quiz_average"`
+	RiskScore      float64      `json:"risk_score"` // 0 to 1
+	Reasons        []RiskReason `json:"reasons"`
+}
+
+// AtRiskReport lists a course's flagged students, riskiest first, out of
+// Students enrolled.
+type AtRiskReport struct {
+	Policy   RiskPolicy      `json:"policy"`
+	Students int             `json:"students"`
+	Items    []AtRiskStudent `json:"items"`
+}
+
+// AtRiskService flags students falling behind in a course.
+type AtRiskService struct {
+	courses     *repositories.CourseRepository
+	assignments *repositories.AssignmentRepository
+	quizzes     *repositories.QuizRepository
+	attendance  *repositories.AttendanceRepository
+}
+
+// NewAtRiskService builds an AtRiskService with its repositories.
+func NewAtRiskService(db *gorm.DB) *AtRiskService {
+	return &AtRiskService{
+		courses:     repositories.NewCourseRepository(db),
+		assignments: repositories.NewAssignmentRepository(db),
+		quizzes:     repositories.NewQuizRepository(db),
+		attendance:  repositories.NewAttendanceRepository(db),
+	}
+}
+
+// ListAtRisk returns the course's students below any threshold of the
+// policy, with the reasons each was flagged. The risk score is the weighted
+// mean of how far each signal falls short of its threshold, relative to the
+// threshold; signals without data count as met, and signals weighted zero are
+// ignored. Only admins and the course teacher may list them.
+func (s *AtRiskService) ListAtRisk(ctx context.Context, courseID uint, user UserInfo, policy RiskPolicy) (*AtRiskReport, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	course, err := s.courses.FindByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	if user.Role != "admin" && (user.Role != "teacher" || course.TeacherID != user.ID) {
+		return nil, ErrAccessDenied
+	}
+
+	studentIDs, err := s.quizzes.ListStudentIDsByCourse(ctx, course.ID)
+	if err != nil {
+		return nil, err
+	}
+	report := &AtRiskReport{Policy: policy, Students: len(studentIDs), Items: []AtRiskStudent{}}
+	if len(studentIDs) == 0 {
+		return report, nil
+	}
+
+	sessions, err := s.attendance.CountSessionsByCourses(ctx, []uint{course.ID})
+	if err != nil {
+		return nil, err
+	}
+	attended, err := s.attendance.CountAttendedByStudents(ctx, course.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	due, err := s.assignments.CountDueByCourse(ctx, course.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	submitted, err := s.assignments.CountSubmittedDueByStudents(ctx, course.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	quizAverages, err := s.quizzes.AverageScoreByStudents(ctx, course.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	totalWeight := policy.AttendanceWeight + policy.SubmissionWeight + policy.QuizWeight
+	for _, id := range studentIDs {
+		student := AtRiskStudent{StudentID: id, Reasons: []RiskReason{}}
+		var shortfall float64
+		check := func(signal string, value *float64, threshold, weight float64) {
+			if weight == 0 || value == nil || *value >= threshold {
+				return
+			}
+			student.Reasons = append(student.Reasons, RiskReason{Signal: signal, Value: *value, Threshold: threshold})
+			shortfall += weight * (threshold - *value) / threshold
+		}
+		if n := sessions[course.ID]; n > 0 {
+			rate := float64(min(attended[id], n)) / float64(n)
+			student.AttendanceRate = &rate
+		}
+		if due > 0 {
+			rate := float64(min(submitted[id], due)) / float64(due)
+			student.SubmissionRate = &rate
+		}
+		if avg, ok := quizAverages[id]; ok {
+			student.QuizAverage = &avg
+		}
+		check(RiskAttendance, student.AttendanceRate, policy.MinAttendanceRate, policy.AttendanceWeight)
+		check(RiskSubmission, student.SubmissionRate, policy.MinSubmissionRate, policy.SubmissionWeight)
+		check(RiskQuiz, student.QuizAverage, policy.MinQuizAverage, policy.QuizWeight)
+		if len(student.Reasons) == 0 {
+			continue
+		}
+		student.RiskScore = shortfall / totalWeight
+		report.Items = append(report.Items, student)
+	}
+
+	flagged := make([]uint, len(report.Items))
+	for i, item := range report.Items {
+		flagged[i] = item.StudentID
+	}
+	users, err := s.quizzes.FindUsersByIDs(ctx, flagged)
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Items {
+		report.Items[i].Username = users[report.Items[i].StudentID].Username
+		report.Items[i].Name = users[report.Items[i].StudentID].Name
+	}
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		if report.Items[i].RiskScore != report.Items[j].RiskScore {
+			return report.Items[i].RiskScore > report.Items[j].RiskScore
+		}
+		return len(report.Items[i].Reasons) > len(report.Items[j].Reasons)
+	})
+	return report, nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+)
+
+// Audited actions.
+const (
+	AuditAttemptReopen        = "quiz_attempt.reopen"
+	AuditGradeOverride        = "submission.grade_override"
+	AuditUserRoleChange       = "user.role_change"
+	AuditEnrollmentRoleChange = "enrollment.role_change"
+	AuditCoursePurge          = "course.purge"
+)
+
+// AuditEntry describes one privileged action for RecordAudit.
+type AuditEntry struct {
+	Actor      UserInfo
+	Action     string
+	TargetType string // quiz_attempt, submission, user, enrollment, course
+	TargetID   uint
+	Details    map[string]interface{}
+}
+
+// RecordAudit writes entry to the audit log. repo must be bound to the
+// action's transaction, so that the action and its record commit together
+// and a failed write rolls the action back.
+func RecordAudit(ctx context.Context, repo *repositories.AuditRepository, entry AuditEntry) error {
+	log := &models.AuditLog{
+		ActorID:    entry.Actor.ID,
+		ActorRole:  entry.Actor.Role,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+	}
+	if len(entry.Details) > 0 {
+		details, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		log.Details = details
+	}
+	return repo.Create(ctx, log)
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
 	"gorm.io/gorm"
@@ -19,22 +20,55 @@ var (
 	ErrCourseNotFound = errors.New("course not found")
 	// ErrAccessDenied indicates the user is not authorized for the action.
 	ErrAccessDenied = errors.New("access denied")
+	// ErrPrerequisiteNotMet indicates the chapter's prerequisite has not been completed.
+	ErrPrerequisiteNotMet = errors.New("prerequisite not met")
+	// ErrInvalidPrerequisite indicates the prerequisite is not another chapter of the same course,
+	// or would make the chapters depend on each other in a cycle.
+	ErrInvalidPrerequisite = errors.New("invalid prerequisite chapter")
+	// ErrInvalidChapter indicates a quiz or assignment chapter that is not a chapter of its course.
+	ErrInvalidChapter = errors.New("invalid chapter")
+	// ErrInvalidKnowledgePoints indicates knowledge points are not a JSON array of strings.
+	ErrInvalidKnowledgePoints = errors.New("invalid knowledge points")
+)
+
+// Study time is credited from heartbeats sent every heartbeatInterval. A gap
+// up to heartbeatMaxGap is time spent studying; a longer one is a pause, and
+// one longer than the session timeout ends the study session.
+const (
+	heartbeatInterval = 30 * time.Second
+	heartbeatMaxGap   = 35 * time.Second
+
+	// DefaultStudySessionTimeout is how long without heartbeats ends a study
+	// session unless WithSessionTimeout changes it.
+	DefaultStudySessionTimeout = 10 * time.Minute
 )
 
 // ChapterService handles chapter CRUD and study tracking.
 type ChapterService struct {
-	repo *repositories.ChapterRepository
-	db   *gorm.DB
+	repo           *repositories.ChapterRepository
+	db             *gorm.DB
+	sessionTimeout time.Duration
 }
 
 // NewChapterService builds a ChapterService with its repository.
 func NewChapterService(db *gorm.DB) *ChapterService {
 	return &ChapterService{
-		repo: repositories.NewChapterRepository(db),
-		db:   db,
+		repo:           repositories.NewChapterRepository(db),
+		db:             db,
+		sessionTimeout: DefaultStudySessionTimeout,
 	}
 }
 
+// WithSessionTimeout sets how long without heartbeats ends a study session.
+// Timeouts shorter than the heartbeat gap are raised to it; zero keeps the
+// default.
+func (s *ChapterService) WithSessionTimeout(d time.Duration) *ChapterService {
+	if d > 0 {
+		s.sessionTimeout = max(d, heartbeatMaxGap)
+	}
+	return s
+}
+
 // CreateChapterRequest contains the fields required to create a chapter.
 type CreateChapterRequest struct {
 	CourseID        uint
@@ -42,6 +76,8 @@ type CreateChapterRequest struct {
 	OrderNum        int
 	Summary         string
 	KnowledgePoints string
+	// PrerequisiteChapterID enables gating; nil leaves the chapter open.
+	PrerequisiteChapterID *uint
 }
 
 // UpdateChapterRequest contains the fields that can be updated on a chapter.
@@ -50,15 +86,21 @@ type UpdateChapterRequest struct {
 	OrderNum        *int
 	Summary         *string
 	KnowledgePoints *string
+	// PrerequisiteChapterID sets the prerequisite; a value of 0 removes it.
+	PrerequisiteChapterID *uint
 }
 
-// AssignmentStats summarizes assignment progress for a student.
+// AssignmentStats summarizes assignment progress for a student. Every
+// assignment is exactly one of not submitted, ungraded, or graded; the
+// averages cover graded work only and are nil until something is graded.
 type AssignmentStats struct {
-	Total        int     `json:"total"`
-	Submitted    int     `json:"submitted"`
-	Graded       int     `json:"graded"`
-	AvgScore     float64 `json:"avg_score"`
-	AccuracyRate float64 `json:"accuracy_rate"`
+	Total        int      `json:"total"`
+	Submitted    int      `json:"submitted"`
+	Graded       int      `json:"graded"`
+	NotSubmitted int      `json:"not_submitted"`
+	Ungraded     int      `json:"ungraded"`
+	AvgScore     *float64 `json:"avg_score"`
+	AccuracyRate *float64 `json:"accuracy_rate"`
 }
 
 // QuizStats summarizes quiz progress for a student.
@@ -97,6 +139,29 @@ type ChapterClassStats struct {
 	StudentProgress      []StudentProgress `json:"student_progress"`
 }
 
+// ChapterStudyTime is one chapter's share of a course study-time roll-up.
+type ChapterStudyTime struct {
+	ChapterID         uint   `json:"chapter_id"`
+	Title             string `json:"title"`
+	Seconds           int    `json:"seconds"`
+	Formatted         string `json:"formatted"`
+	ClassAvgSeconds   *int   `json:"class_avg_seconds,omitempty"`
+	ClassAvgFormatted string `json:"class_avg_formatted,omitempty"`
+}
+
+// CourseStudyTime rolls study time up to the course. Seconds are the current
+// user's own; class averages are filled in for teachers only and average over
+// every enrolled student, counting those who have not studied as zero.
+type CourseStudyTime struct {
+	CourseID          uint               `json:"course_id"`
+	TotalSeconds      int                `json:"total_seconds"`
+	TotalFormatted    string             `json:"total_formatted"`
+	TotalStudents     int                `json:"total_students,omitempty"`
+	ClassAvgSeconds   *int               `json:"class_avg_seconds,omitempty"`
+	ClassAvgFormatted string             `json:"class_avg_formatted,omitempty"`
+	Chapters          []ChapterStudyTime `json:"chapters"`
+}
+
 // HasCourseAccess checks whether the user can access a course.
 func (s *ChapterService) HasCourseAccess(ctx context.Context, courseID uint, user UserInfo) (bool, error) {
 	if user.Role == "admin" {
@@ -112,6 +177,9 @@ func (s *ChapterService) HasCourseAccess(ctx context.Context, courseID uint, use
 		}
 		return course.TeacherID == user.ID, nil
 	}
+	if authz.ViewingAsStudent(ctx, courseID) {
+		return true, nil
+	}
 	return s.repo.HasEnrollment(ctx, courseID, user.ID)
 }
 
@@ -154,12 +222,21 @@ func (s *ChapterService) CreateChapter(ctx context.Context, user UserInfo, req C
 	if !canManage {
 		return nil, ErrAccessDenied
 	}
+	if err := validateKnowledgePoints(req.KnowledgePoints); err != nil {
+		return nil, err
+	}
+	if req.PrerequisiteChapterID != nil {
+		if err := s.validatePrerequisite(ctx, req.CourseID, 0, *req.PrerequisiteChapterID); err != nil {
+			return nil, err
+		}
+	}
 	chapter := &models.Chapter{
-		CourseID:        req.CourseID,
-		Title:           req.Title,
-		OrderNum:        req.OrderNum,
-		Summary:         req.Summary,
-		KnowledgePoints: req.KnowledgePoints,
+		CourseID:              req.CourseID,
+		Title:                 req.Title,
+		OrderNum:              req.OrderNum,
+		Summary:               req.Summary,
+		KnowledgePoints:       req.KnowledgePoints,
+		PrerequisiteChapterID: req.PrerequisiteChapterID,
 	}
 	if err := s.repo.Create(ctx, chapter); err != nil {
 		return nil, err
@@ -183,6 +260,9 @@ func (s *ChapterService) GetChapter(ctx context.Context, chapterID uint, user Us
 	if !ok {
 		return nil, ErrAccessDenied
 	}
+	if err := s.checkPrerequisite(ctx, chapter, user); err != nil {
+		return nil, err
+	}
 	return chapter, nil
 }
 
@@ -214,8 +294,21 @@ func (s *ChapterService) UpdateChapter(ctx context.Context, chapterID uint, user
 		updates["summary"] = *req.Summary
 	}
 	if req.KnowledgePoints != nil {
+		if err := validateKnowledgePoints(*req.KnowledgePoints); err != nil {
+			return nil, err
+		}
 		updates["knowledge_points"] = *req.KnowledgePoints
 	}
+	if req.PrerequisiteChapterID != nil {
+		if *req.PrerequisiteChapterID == 0 {
+			updates["prerequisite_chapter_id"] = nil
+		} else {
+			if err := s.validatePrerequisite(ctx, chapter.CourseID, chapter.ID, *req.PrerequisiteChapterID); err != nil {
+				return nil, err
+			}
+			updates["prerequisite_chapter_id"] = *req.PrerequisiteChapterID
+		}
+	}
 	if len(updates) > 0 {
 		if err := s.repo.Update(ctx, chapter, updates); err != nil {
 			return nil, err
@@ -224,6 +317,18 @@ func (s *ChapterService) UpdateChapter(ctx context.Context, chapterID uint, user
 	return s.repo.FindChapter(ctx, chapterID)
 }
 
+// validateKnowledgePoints accepts an empty string or a JSON array of strings.
+func validateKnowledgePoints(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	var points []string
+	if err := json.Unmarshal([]byte(raw), &points); err != nil || points == nil {
+		return ErrInvalidKnowledgePoints
+	}
+	return nil
+}
+
 // DeleteChapter removes a chapter and related data.
 func (s *ChapterService) DeleteChapter(ctx context.Context, chapterID uint, user UserInfo) error {
 	chapter, err := s.repo.FindChapter(ctx, chapterID)
@@ -240,13 +345,142 @@ func (s *ChapterService) DeleteChapter(ctx context.Context, chapterID uint, user
 	if !canManage {
 		return ErrAccessDenied
 	}
-	if err := s.repo.ClearChapterReferences(ctx, chapterID); err != nil {
+	return s.repo.Transaction(ctx, func(tx *repositories.ChapterRepository) error {
+		// Chapters gated on this one open up rather than pointing at a
+		// deleted chapter nobody can complete.
+		if err := tx.ClearChapterReferences(ctx, chapterID); err != nil {
+			return err
+		}
+		if err := tx.DeleteProgressByChapter(ctx, chapterID); err != nil {
+			return err
+		}
+		return tx.Delete(ctx, chapterID)
+	})
+}
+
+// CompleteChapter marks a chapter as completed by the student, which unlocks
+// chapters that list it as their prerequisite.
+func (s *ChapterService) CompleteChapter(ctx context.Context, chapterID uint, user UserInfo) (*models.ChapterProgress, error) {
+	if user.Role != "student" {
+		return nil, ErrAccessDenied
+	}
+	chapter, err := s.GetChapter(ctx, chapterID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	progress, err := s.repo.FindProgress(ctx, chapter.ID, user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		progress = &models.ChapterProgress{ChapterID: chapter.ID, StudentID: user.ID}
+	} else if err != nil {
+		return nil, err
+	}
+	if progress.CompletedAt == nil {
+		progress.CompletedAt = &now
+		if err := s.repo.SaveProgress(ctx, progress); err != nil {
+			return nil, err
+		}
+	}
+	return progress, nil
+}
+
+// LockedChapterIDs returns the chapters of a course the user cannot open yet
+// because their prerequisite is incomplete. Staff never have locked chapters.
+func (s *ChapterService) LockedChapterIDs(ctx context.Context, courseID uint, user UserInfo) ([]uint, error) {
+	if user.IsTeacher() {
+		return nil, nil
+	}
+	chapters, err := s.repo.ListByCourse(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	completedIDs, err := s.repo.ListCompletedChapterIDs(ctx, courseID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	completed := make(map[uint]bool, len(completedIDs))
+	for _, id := range completedIDs {
+		completed[id] = true
+	}
+	var locked []uint
+	for _, ch := range chapters {
+		if ch.PrerequisiteChapterID != nil && !completed[*ch.PrerequisiteChapterID] {
+			locked = append(locked, ch.ID)
+		}
+	}
+	return locked, nil
+}
+
+// checkPrerequisite enforces opt-in chapter gating for students.
+func (s *ChapterService) checkPrerequisite(ctx context.Context, chapter *models.Chapter, user UserInfo) error {
+	if chapter.PrerequisiteChapterID == nil || user.IsTeacher() {
+		return nil
+	}
+	progress, err := s.repo.FindProgress(ctx, *chapter.PrerequisiteChapterID, user.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPrerequisiteNotMet
+		}
+		return err
+	}
+	if progress.CompletedAt == nil {
+		return ErrPrerequisiteNotMet
+	}
+	return nil
+}
+
+func (s *ChapterService) validatePrerequisite(ctx context.Context, courseID uint, chapterID uint, prerequisiteID uint) error {
+	if prerequisiteID == chapterID {
+		return ErrInvalidPrerequisite
+	}
+	prerequisite, err := s.repo.FindChapter(ctx, prerequisiteID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidPrerequisite
+		}
+		return err
+	}
+	if prerequisite.CourseID != courseID {
+		return ErrInvalidPrerequisite
+	}
+	if chapterID == 0 {
+		return nil
+	}
+	// Reject a cycle: the new prerequisite must not itself depend on the
+	// chapter, directly or through a chain of prerequisites.
+	chapters, err := s.repo.ListByCourse(ctx, courseID)
+	if err != nil {
 		return err
 	}
-	if err := s.repo.DeleteProgressByChapter(ctx, chapterID); err != nil {
+	next := make(map[uint]*uint, len(chapters))
+	for _, ch := range chapters {
+		next[ch.ID] = ch.PrerequisiteChapterID
+	}
+	seen := map[uint]bool{}
+	for id := prerequisite.PrerequisiteChapterID; id != nil && !seen[*id]; id = next[*id] {
+		if *id == chapterID {
+			return ErrInvalidPrerequisite
+		}
+		seen[*id] = true
+	}
+	return nil
+}
+
+// validateContentChapter checks that chapterID is a chapter of the course,
+// for attaching a quiz or assignment to it. find looks the chapter up.
+func validateContentChapter(ctx context.Context, find func(context.Context, uint) (*models.Chapter, error), courseID uint, chapterID uint) error {
+	chapter, err := find(ctx, chapterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidChapter
+		}
 		return err
 	}
-	return s.repo.Delete(ctx, chapterID)
+	if chapter.CourseID != courseID {
+		return ErrInvalidChapter
+	}
+	return nil
 }
 
 // GetChapterCourseID returns the course ID for a chapter.
@@ -261,7 +495,10 @@ func (s *ChapterService) GetChapterCourseID(ctx context.Context, chapterID uint)
 	return chapter.CourseID, nil
 }
 
-// RecordHeartbeat updates study duration based on a heartbeat ping.
+// RecordHeartbeat updates study duration based on a heartbeat ping. It
+// reports whether the ping started a study session, and the total duration.
+// A ping after the session timeout ends the previous session, recording a
+// session_end learning event, and starts a new one.
 func (s *ChapterService) RecordHeartbeat(ctx context.Context, chapterID uint, user UserInfo) (bool, int, error) {
 	if user.Role != "student" {
 		return false, 0, ErrAccessDenied
@@ -279,9 +516,6 @@ func (s *ChapterService) RecordHeartbeat(ctx context.Context, chapterID uint, us
 		return false, 0, ErrAccessDenied
 	}
 
-	const heartbeatInterval = 30 // seconds
-	const maxGap = 35            // seconds
-
 	now := time.Now()
 
 	var progress models.ChapterProgress
@@ -292,6 +526,7 @@ func (s *ChapterService) RecordHeartbeat(ctx context.Context, chapterID uint, us
 			StudentID:            user.ID,
 			StudyDurationSeconds: 0,
 			LastActiveAt:         &now,
+			SessionStartedAt:     &now,
 		}
 		if err := s.db.WithContext(ctx).Create(&progress).Error; err != nil {
 			return false, 0, err
@@ -302,22 +537,52 @@ func (s *ChapterService) RecordHeartbeat(ctx context.Context, chapterID uint, us
 		return false, 0, err
 	}
 
+	started := false
 	if progress.LastActiveAt != nil {
-		gap := now.Sub(*progress.LastActiveAt).Seconds()
-		if gap <= float64(maxGap) {
-			if err := s.db.WithContext(ctx).Model(&progress).Updates(map[string]interface{}{
-				"study_duration_seconds": gorm.Expr("study_duration_seconds + ?", heartbeatInterval),
-				"last_active_at":         now,
-			}).Error; err != nil {
-				return false, 0, err
+		last := *progress.LastActiveAt
+		gap := now.Sub(last)
+		switch {
+		case gap < 0:
+			// LastActiveAt is in the future (clock jumped back or a replayed
+			// heartbeat raced ahead): ignore rather than rewind or credit time.
+		case gap <= heartbeatMaxGap:
+			// Credit only whole seconds that actually elapsed, never more than
+			// one interval per call. Below the interval LastActiveAt advances by
+			// exactly the credited amount, so rapid-fire pings cannot count the
+			// same seconds twice; the conditional update drops concurrent replays.
+			credit := int(gap / time.Second)
+			next := last.Add(time.Duration(credit) * time.Second)
+			if credit > int(heartbeatInterval/time.Second) {
+				credit = int(heartbeatInterval / time.Second)
+				next = now
 			}
-		} else {
+			if credit > 0 {
+				if err := s.db.WithContext(ctx).Model(&models.ChapterProgress{}).
+					Where("id = ? AND last_active_at = ?", progress.ID, last).
+					Updates(map[string]interface{}{
+						"study_duration_seconds": gorm.Expr("study_duration_seconds + ?", credit),
+						"session_seconds":        gorm.Expr("session_seconds + ?", credit),
+						"last_active_at":         next,
+					}).Error; err != nil {
+					return false, 0, err
+				}
+			}
+		case gap <= s.sessionTimeout:
+			// A pause within the session: resume without crediting the gap.
 			if err := s.db.WithContext(ctx).Model(&progress).Update("last_active_at", now).Error; err != nil {
 				return false, 0, err
 			}
+		default:
+			if started, err = s.restartStudySession(ctx, courseID, &progress, now); err != nil {
+				return false, 0, err
+			}
 		}
 	} else {
-		if err := s.db.WithContext(ctx).Model(&progress).Update("last_active_at", now).Error; err != nil {
+		if err := s.db.WithContext(ctx).Model(&progress).Updates(map[string]interface{}{
+			"last_active_at":     now,
+			"session_started_at": now,
+			"session_seconds":    0,
+		}).Error; err != nil {
 			return false, 0, err
 		}
 	}
@@ -326,7 +591,44 @@ func (s *ChapterService) RecordHeartbeat(ctx context.Context, chapterID uint, us
 		return false, 0, err
 	}
 
-	return false, progress.StudyDurationSeconds, nil
+	return started, progress.StudyDurationSeconds, nil
+}
+
+// restartStudySession ends the session progress was last active in, recording
+// a session_end learning event, and starts a new one at now. It reports false
+// when a concurrent heartbeat already did so.
+func (s *ChapterService) restartStudySession(ctx context.Context, courseID uint, progress *models.ChapterProgress, now time.Time) (bool, error) {
+	payload, err := json.Marshal(struct {
+		ChapterID    uint       `json:"chapter_id"`
+		StartedAt    *time.Time `json:"started_at,omitempty"`
+		EndedAt      time.Time  `json:"ended_at"`
+		StudySeconds int        `json:"study_seconds"`
+	}{progress.ChapterID, progress.SessionStartedAt, *progress.LastActiveAt, progress.SessionSeconds})
+	if err != nil {
+		return false, err
+	}
+
+	restarted := false
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ChapterProgress{}).
+			Where("id = ? AND last_active_at = ?", progress.ID, *progress.LastActiveAt).
+			Updates(map[string]interface{}{
+				"last_active_at":     now,
+				"session_started_at": now,
+				"session_seconds":    0,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		restarted = true
+		return tx.Create(&models.LearningEvent{
+			StudentID: progress.StudentID,
+			CourseID:  &courseID,
+			EventType: "session_end",
+			Payload:   string(payload),
+		}).Error
+	})
+	return restarted, err
 }
 
 // GetMyStats returns student-specific stats for a chapter.
@@ -347,6 +649,9 @@ func (s *ChapterService) GetMyStats(ctx context.Context, chapterID uint, user Us
 	if !ok {
 		return stats, ErrAccessDenied
 	}
+	if err := s.checkPrerequisite(ctx, chapter, user); err != nil {
+		return stats, err
+	}
 
 	stats = ChapterStudentStats{
 		ChapterID:       chapterID,
@@ -378,29 +683,29 @@ func (s *ChapterService) GetMyStats(ctx context.Context, chapterID uint, user Us
 			assignmentIDs[i] = a.ID
 		}
 
-		var submittedCount int64
-		_ = s.db.WithContext(ctx).Model(&models.Submission{}).
-			Where("assignment_id IN ? AND student_id = ?", assignmentIDs, user.ID).
-			Count(&submittedCount).Error
-		stats.AssignmentStats.Submitted = int(submittedCount)
-
 		var submissions []models.Submission
 		_ = s.db.WithContext(ctx).
-			Where("assignment_id IN ? AND student_id = ? AND grade IS NOT NULL", assignmentIDs, user.ID).
+			Where("assignment_id IN ? AND student_id = ?", assignmentIDs, user.ID).
 			Find(&submissions).Error
-		stats.AssignmentStats.Graded = len(submissions)
-
-		if len(submissions) > 0 {
-			totalScore := 0
-			for _, s := range submissions {
-				if s.Grade != nil {
-					totalScore += *s.Grade
-				}
+		stats.AssignmentStats.Submitted = len(submissions)
+		totalScore := 0
+		for _, sub := range submissions {
+			if sub.Grade == nil {
+				stats.AssignmentStats.Ungraded++
+				continue
 			}
-			stats.AssignmentStats.AvgScore = float64(totalScore) / float64(len(submissions))
-			stats.AssignmentStats.AccuracyRate = stats.AssignmentStats.AvgScore / 100.0
+			stats.AssignmentStats.Graded++
+			totalScore += *sub.Grade
+		}
+
+		if stats.AssignmentStats.Graded > 0 {
+			avg := float64(totalScore) / float64(stats.AssignmentStats.Graded)
+			accuracy := avg / 100.0
+			stats.AssignmentStats.AvgScore = &avg
+			stats.AssignmentStats.AccuracyRate = &accuracy
 		}
 	}
+	stats.AssignmentStats.NotSubmitted = stats.AssignmentStats.Total - stats.AssignmentStats.Submitted
 
 	var quizzes []models.Quiz
 	_ = s.db.WithContext(ctx).Where("chapter_id = ?", chapterID).Find(&quizzes).Error
@@ -436,89 +741,224 @@ func (s *ChapterService) GetMyStats(ctx context.Context, chapterID uint, user Us
 	return stats, nil
 }
 
+// classStatsBatchSize is how many students' progress GetClassStats and
+// StreamClassStats load per round of queries.
+const classStatsBatchSize = 200
+
 // GetClassStats returns class-level stats for a chapter.
 func (s *ChapterService) GetClassStats(ctx context.Context, chapterID uint, user UserInfo) (ChapterClassStats, error) {
 	var response ChapterClassStats
 
+	chapter, err := s.findClassStatsChapter(ctx, chapterID, user)
+	if err != nil {
+		return response, err
+	}
+
+	var enrollments []models.CourseEnrollment
+	_ = s.db.WithContext(ctx).Where("course_id = ? AND role = 'student'", chapter.CourseID).Find(&enrollments).Error
+
+	response = ChapterClassStats{
+		ChapterID:       chapterID,
+		TotalStudents:   len(enrollments),
+		StudentProgress: []StudentProgress{},
+	}
+
+	response.AvgStudyDurationSecs, err = s.repo.AvgStudyDuration(ctx, chapterID)
+	if err != nil {
+		return response, err
+	}
+
+	err = s.eachStudentProgress(ctx, chapterID, func(sp StudentProgress) error {
+		response.StudentProgress = append(response.StudentProgress, sp)
+		return nil
+	})
+	return response, err
+}
+
+// StreamClassStats passes each student's progress on a chapter to emit as it
+// is loaded, so huge classes need not be held in memory. Access is checked
+// before the first call to emit; an error from emit stops the stream.
+func (s *ChapterService) StreamClassStats(ctx context.Context, chapterID uint, user UserInfo, emit func(StudentProgress) error) error {
+	if _, err := s.findClassStatsChapter(ctx, chapterID, user); err != nil {
+		return err
+	}
+	return s.eachStudentProgress(ctx, chapterID, emit)
+}
+
+// findClassStatsChapter loads a chapter whose class stats the user may see:
+// admins and assistants see any chapter, teachers those of courses they own
+// or are enrolled in as assistant.
+func (s *ChapterService) findClassStatsChapter(ctx context.Context, chapterID uint, user UserInfo) (*models.Chapter, error) {
 	if user.Role != "admin" && user.Role != "teacher" && user.Role != "assistant" {
-		return response, ErrAccessDenied
+		return nil, ErrAccessDenied
 	}
 
 	chapter, err := s.repo.FindChapter(ctx, chapterID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return response, ErrChapterNotFound
+			return nil, ErrChapterNotFound
 		}
-		return response, err
+		return nil, err
 	}
 
 	if user.Role == "teacher" {
 		course, err := s.repo.FindCourse(ctx, chapter.CourseID)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return response, ErrCourseNotFound
+				return nil, ErrCourseNotFound
 			}
-			return response, err
+			return nil, err
 		}
 		if course.TeacherID != user.ID {
-			return response, ErrAccessDenied
+			assists, err := s.repo.IsCourseAssistant(ctx, course.ID, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !assists {
+				return nil, ErrAccessDenied
+			}
 		}
 	}
+	return chapter, nil
+}
 
-	var enrollments []models.CourseEnrollment
-	_ = s.db.WithContext(ctx).Where("course_id = ? AND role = 'student'", chapter.CourseID).Find(&enrollments).Error
+// eachStudentProgress walks the chapter's progress records in id order,
+// loading names and grade averages for a batch of students at a time.
+// Records of deleted users are skipped.
+func (s *ChapterService) eachStudentProgress(ctx context.Context, chapterID uint, fn func(StudentProgress) error) error {
+	var afterID uint
+	for {
+		batch, err := s.repo.ListProgressAfter(ctx, chapterID, afterID, classStatsBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		afterID = batch[len(batch)-1].ID
 
-	response = ChapterClassStats{
-		ChapterID:       chapterID,
-		TotalStudents:   len(enrollments),
-		StudentProgress: []StudentProgress{},
+		studentIDs := make([]uint, len(batch))
+		for i, p := range batch {
+			studentIDs[i] = p.StudentID
+		}
+		users, err := s.repo.FindUsers(ctx, studentIDs)
+		if err != nil {
+			return err
+		}
+		names := make(map[uint]string, len(users))
+		for _, u := range users {
+			names[u.ID] = u.Name
+		}
+		grades, err := s.repo.SumGradesByStudent(ctx, chapterID, studentIDs)
+		if err != nil {
+			return err
+		}
+		avgScores := make(map[uint]float64, len(grades))
+		for _, g := range grades {
+			if g.Count > 0 {
+				avgScores[g.StudentID] = float64(g.Total) / float64(g.Count)
+			}
+		}
+
+		for _, p := range batch {
+			name, ok := names[p.StudentID]
+			if !ok {
+				continue
+			}
+			if err := fn(StudentProgress{
+				StudentID:          p.StudentID,
+				StudentName:        name,
+				StudyDurationSecs:  p.StudyDurationSeconds,
+				AssignmentAvgScore: avgScores[p.StudentID],
+			}); err != nil {
+				return err
+			}
+		}
+		if len(batch) < classStatsBatchSize {
+			return nil
+		}
 	}
+}
+
+// GetCourseStudyTime returns the user's study time per chapter of a course,
+// plus class averages when the user teaches or assists the course.
+func (s *ChapterService) GetCourseStudyTime(ctx context.Context, courseID uint, user UserInfo) (CourseStudyTime, error) {
+	response := CourseStudyTime{CourseID: courseID, Chapters: []ChapterStudyTime{}}
 
-	var allProgress []models.ChapterProgress
-	_ = s.db.WithContext(ctx).Where("chapter_id = ?", chapterID).Find(&allProgress).Error
+	if _, err := s.repo.FindCourse(ctx, courseID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response, ErrCourseNotFound
+		}
+		return response, err
+	}
+	ok, err := s.HasCourseAccess(ctx, courseID, user)
+	if err != nil {
+		return response, err
+	}
+	if !ok {
+		return response, ErrAccessDenied
+	}
 
-	totalDuration := 0
-	for _, p := range allProgress {
-		totalDuration += p.StudyDurationSeconds
+	chapters, err := s.repo.ListByCourse(ctx, courseID)
+	if err != nil {
+		return response, err
 	}
-	if len(allProgress) > 0 {
-		response.AvgStudyDurationSecs = totalDuration / len(allProgress)
+	own, err := s.repo.SumStudyTimeByChapter(ctx, courseID, user.ID)
+	if err != nil {
+		return response, err
+	}
+	ownByChapter := make(map[uint]int, len(own))
+	for _, row := range own {
+		ownByChapter[row.ChapterID] = row.Seconds
 	}
 
-	var assignments []models.Assignment
-	_ = s.db.WithContext(ctx).Where("chapter_id = ?", chapterID).Find(&assignments).Error
-	assignmentIDs := make([]uint, len(assignments))
-	for i, a := range assignments {
-		assignmentIDs[i] = a.ID
-	}
-
-	for _, p := range allProgress {
-		var userModel models.User
-		if err := s.db.WithContext(ctx).First(&userModel, p.StudentID).Error; err == nil {
-			sp := StudentProgress{
-				StudentID:         p.StudentID,
-				StudentName:       userModel.Name,
-				StudyDurationSecs: p.StudyDurationSeconds,
-			}
+	var classByChapter map[uint]int
+	students := 0
+	if user.IsTeacher() {
+		count, err := s.repo.CountStudentsByCourse(ctx, courseID)
+		if err != nil {
+			return response, err
+		}
+		class, err := s.repo.SumClassStudyTimeByChapter(ctx, courseID)
+		if err != nil {
+			return response, err
+		}
+		students = int(count)
+		classByChapter = make(map[uint]int, len(class))
+		for _, row := range class {
+			classByChapter[row.ChapterID] = row.Seconds
+		}
+		response.TotalStudents = students
+	}
 
-			if len(assignmentIDs) > 0 {
-				var submissions []models.Submission
-				_ = s.db.WithContext(ctx).
-					Where("assignment_id IN ? AND student_id = ? AND grade IS NOT NULL", assignmentIDs, p.StudentID).
-					Find(&submissions).Error
-				if len(submissions) > 0 {
-					total := 0
-					for _, s := range submissions {
-						if s.Grade != nil {
-							total += *s.Grade
-						}
-					}
-					sp.AssignmentAvgScore = float64(total) / float64(len(submissions))
-				}
+	classTotal := 0
+	for _, chapter := range chapters {
+		item := ChapterStudyTime{
+			ChapterID: chapter.ID,
+			Title:     chapter.Title,
+			Seconds:   ownByChapter[chapter.ID],
+		}
+		item.Formatted = formatDuration(item.Seconds)
+		response.TotalSeconds += item.Seconds
+		if classByChapter != nil {
+			avg := 0
+			if students > 0 {
+				avg = classByChapter[chapter.ID] / students
 			}
-
-			response.StudentProgress = append(response.StudentProgress, sp)
+			item.ClassAvgSeconds = &avg
+			item.ClassAvgFormatted = formatDuration(avg)
+			classTotal += classByChapter[chapter.ID]
+		}
+		response.Chapters = append(response.Chapters, item)
+	}
+	response.TotalFormatted = formatDuration(response.TotalSeconds)
+	if classByChapter != nil {
+		avg := 0
+		if students > 0 {
+			avg = classTotal / students
 		}
+		response.ClassAvgSeconds = &avg
+		response.ClassAvgFormatted = formatDuration(avg)
 	}
 
 	return response, nil
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTranscriptNotFound indicates no chat transcript exists for the session id.
+	ErrTranscriptNotFound = errors.New("chat transcript not found")
+)
+
+const (
+	// MaxTranscriptMessages caps how many messages one transcript keeps. Once
+	// reached, a marker message is stored and later turns are dropped.
+	MaxTranscriptMessages = 200
+	// MaxTranscriptMessageBytes caps the stored size of a single message.
+	MaxTranscriptMessageBytes = 16 * 1024
+
+	transcriptMessageTruncatedMarker = "…[message truncated]"
+)
+
+// ChatTranscriptService stores AI chat conversations and serves them back.
+type ChatTranscriptService struct {
+	repo *repositories.ChatRepository
+}
+
+// NewChatTranscriptService builds a ChatTranscriptService with its repository.
+func NewChatTranscriptService(db *gorm.DB) *ChatTranscriptService {
+	return &ChatTranscriptService{repo: repositories.NewChatRepository(db)}
+}
+
+// TranscriptEntry is one message to append to a transcript.
+type TranscriptEntry struct {
+	Role    string
+	Content string
+}
+
+// TranscriptTurn is a user message and the assistant's reply in a session.
+type TranscriptTurn struct {
+	SessionKey string
+	UserID     uint
+	Role       string
+	CourseID   *uint
+	Mode       string
+	Messages   []TranscriptEntry
+}
+
+// ChatTranscriptView is a transcript with its messages in order.
+type ChatTranscriptView struct {
+	models.ChatTranscript
+	Messages []models.ChatTranscriptMessage `json:"messages"`
+}
+
+// AppendTurn adds a turn's messages to the session's transcript, creating it
+// on first use. Messages over MaxTranscriptMessageBytes are cut short, and once
+// the transcript holds MaxTranscriptMessages a single marker is stored and the
+// rest of the conversation is dropped. A session id already used by another
+// user, or a new session naming a course the user neither teaches nor is
+// enrolled in, is rejected with ErrAccessDenied.
+func (s *ChatTranscriptService) AppendTurn(ctx context.Context, turn TranscriptTurn) error {
+	if turn.SessionKey == "" || len(turn.Messages) == 0 {
+		return nil
+	}
+	return s.repo.Transaction(ctx, func(tx *repositories.ChatRepository) error {
+		transcript, err := tx.FindTranscriptByKey(ctx, turn.SessionKey)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if turn.CourseID != nil {
+				if err := s.checkCourseMember(ctx, tx, *turn.CourseID, turn.UserID, turn.Role); err != nil {
+					return err
+				}
+			}
+			transcript = &models.ChatTranscript{
+				SessionKey: turn.SessionKey,
+				UserID:     turn.UserID,
+				CourseID:   turn.CourseID,
+				Mode:       turn.Mode,
+			}
+			err = tx.CreateTranscript(ctx, transcript)
+		}
+		if err != nil {
+			return err
+		}
+		if transcript.UserID != turn.UserID {
+			return ErrAccessDenied
+		}
+		if transcript.Truncated {
+			return nil
+		}
+
+		for _, entry := range turn.Messages {
+			message := &models.ChatTranscriptMessage{
+				TranscriptID: transcript.ID,
+				Role:         entry.Role,
+				Content:      truncateTranscriptContent(entry.Content),
+			}
+			if transcript.MessageCount >= MaxTranscriptMessages {
+				message.Role = "system"
+				message.Content = fmt.Sprintf("[conversation truncated after %d messages]", transcript.MessageCount)
+				transcript.Truncated = true
+			}
+			if err := tx.CreateTranscriptMessage(ctx, message); err != nil {
+				return err
+			}
+			if transcript.Truncated {
+				break
+			}
+			transcript.MessageCount++
+		}
+		return tx.UpdateTranscript(ctx, transcript)
+	})
+}
+
+// GetTranscript returns a stored conversation. Its owner and admins may read
+// it, as may the teacher of the course it was held in.
+func (s *ChatTranscriptService) GetTranscript(ctx context.Context, sessionKey string, user UserInfo) (*ChatTranscriptView, error) {
+	transcript, err := s.repo.FindTranscriptByKey(ctx, sessionKey)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTranscriptNotFound
+		}
+		return nil, err
+	}
+
+	if transcript.UserID != user.ID && user.Role != "admin" {
+		if user.Role != "teacher" || transcript.CourseID == nil {
+			return nil, ErrAccessDenied
+		}
+		course, err := s.repo.FindCourse(ctx, *transcript.CourseID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrAccessDenied
+			}
+			return nil, err
+		}
+		if course.TeacherID != user.ID {
+			return nil, ErrAccessDenied
+		}
+	}
+
+	messages, err := s.repo.ListTranscriptMessages(ctx, transcript.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatTranscriptView{ChatTranscript: *transcript, Messages: messages}, nil
+}
+
+// checkCourseMember returns ErrAccessDenied unless the user is an admin, the
+// course teacher or enrolled in the course.
+func (s *ChatTranscriptService) checkCourseMember(ctx context.Context, tx *repositories.ChatRepository, courseID, userID uint, role string) error {
+	if role == "admin" {
+		return nil
+	}
+	course, err := tx.FindCourse(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccessDenied
+		}
+		return err
+	}
+	if role == "teacher" && course.TeacherID == userID {
+		return nil
+	}
+	enrolled, err := tx.HasEnrollment(ctx, courseID, userID)
+	if err != nil {
+		return err
+	}
+	if !enrolled {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// truncateTranscriptContent cuts content to MaxTranscriptMessageBytes on a
+// rune boundary and appends a marker when anything was removed.
+func truncateTranscriptContent(content string) string {
+	if len(content) <= MaxTranscriptMessageBytes {
+		return content
+	}
+	cut := MaxTranscriptMessageBytes - len(transcriptMessageTruncatedMarker)
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + transcriptMessageTruncatedMarker
+}
@@ -0,0 +1,465 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// DefaultOverviewLimit and MaxOverviewLimit bound how many items each list
+// in a course overview holds. Further items come from the section's own
+// list endpoint.
+const (
+	DefaultOverviewLimit = 5
+	MaxOverviewLimit     = 20
+)
+
+// CourseOverviewService bundles what a course page shows into one payload,
+// so clients need a single request instead of one per section.
+type CourseOverviewService struct {
+	courses       *repositories.CourseRepository
+	chapters      *repositories.ChapterRepository
+	assignments   *repositories.AssignmentRepository
+	quizzes       *repositories.QuizRepository
+	announcements *repositories.AnnouncementRepository
+	attendance    *repositories.AttendanceRepository
+}
+
+// NewCourseOverviewService builds a CourseOverviewService with its repositories.
+func NewCourseOverviewService(db *gorm.DB) *CourseOverviewService {
+	return &CourseOverviewService{
+		courses:       repositories.NewCourseRepository(db),
+		chapters:      repositories.NewChapterRepository(db),
+		assignments:   repositories.NewAssignmentRepository(db),
+		quizzes:       repositories.NewQuizRepository(db),
+		announcements: repositories.NewAnnouncementRepository(db),
+		attendance:    repositories.NewAttendanceRepository(db),
+	}
+}
+
+// CourseOverview is a course page in one payload. View is "staff" for admins,
+// the course teacher and its assistants, with Staff counts and unpublished
+// work listed; otherwise it is "student", with the caller's own Progress and
+// per-item status. Times are written in the course's time zone.
+type CourseOverview struct {
+	Course        models.Course                         `json:"course"`
+	View          string                                `json:"view"`
+	Chapters      OverviewSection[OverviewChapter]      `json:"chapters"`
+	Assignments   OverviewSection[OverviewAssignment]   `json:"assignments"`
+	Quizzes       OverviewSection[OverviewQuiz]         `json:"quizzes"`
+	Announcements OverviewSection[OverviewAnnouncement] `json:"announcements"`
+	Attendance    OverviewAttendance                    `json:"attendance"`
+	Progress      *OverviewProgress                     `json:"progress,omitempty"`
+	Staff         *OverviewStaffCounts                  `json:"staff,omitempty"`
+}
+
+// OverviewSection is the first items of a list and the length of the whole
+// list.
+type OverviewSection[T any] struct {
+	Items   []T   `json:"items"`
+	Total   int64 `json:"total"`
+	HasMore bool  `json:"has_more"`
+}
+
+// OverviewChapter is a chapter in reading order. Completed is set in the
+// student view.
+type OverviewChapter struct {
+	ID        uint   `json:"id"`
+	Title     string `json:"title"`
+	OrderNum  int    `json:"order_num"`
+	Completed *bool  `json:"completed,omitempty"`
+}
+
+// OverviewAssignment is an assignment, newest first. Submitted is set in the
+// student view.
+type OverviewAssignment struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	IsPublished bool       `json:"is_published"`
+	Submitted   *bool      `json:"submitted,omitempty"`
+}
+
+// OverviewQuiz is a quiz, newest first. AttemptsUsed is set in the student
+// view, where MaxAttempts includes attempts granted to the student.
+type OverviewQuiz struct {
+	ID           uint       `json:"id"`
+	Title        string     `json:"title"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	TimeLimit    int        `json:"time_limit"`
+	IsPublished  bool       `json:"is_published"`
+	MaxAttempts  int        `json:"max_attempts"`
+	AttemptsUsed *int       `json:"attempts_used,omitempty"`
+}
+
+// OverviewAnnouncement is an announcement, pinned first, then newest first.
+type OverviewAnnouncement struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Pinned    bool      `json:"pinned"`
+	CreatedAt time.Time `json:"created_at"`
+	Read      bool      `json:"read"`
+}
+
+// OverviewAttendance counts the course's attendance sessions. Attended and
+// Rate are the student's own; ActiveSession is shown to staff only.
+type OverviewAttendance struct {
+	Sessions      int                     `json:"sessions"`
+	Attended      *int                    `json:"attended,omitempty"`
+	Rate          *float64                `json:"rate,omitempty"` // nil before the first session
+	ActiveSession *DashboardActiveSession `json:"active_session,omitempty"`
+}
+
+// OverviewProgress is what the student has done and still has to do.
+type OverviewProgress struct {
+	CompletedChapters   int   `json:"completed_chapters"`
+	TotalChapters       int   `json:"total_chapters"`
+	PendingAssignments  int   `json:"pending_assignments"` // published, open and not yet submitted
+	PendingQuizzes      int   `json:"pending_quizzes"`     // published, open now and with attempts left
+	UnreadAnnouncements int64 `json:"unread_announcements"`
+}
+
+// OverviewStaffCounts is what needs the staff's attention.
+type OverviewStaffCounts struct {
+	Students            int64 `json:"students"`
+	PendingGrading      int64 `json:"pending_grading"`
+	UnpublishedQuizzes  int64 `json:"unpublished_quizzes"`
+	UnreadAnnouncements int64 `json:"unread_announcements"`
+}
+
+// GetOverview returns the overview of a course with at most limit items per
+// list. Admins, the course teacher and enrolled users may read it; teachers
+// previewing the course as a student get the student view.
+func (s *CourseOverviewService) GetOverview(ctx context.Context, courseID uint, user UserInfo, limit int) (*CourseOverview, error) {
+	if limit <= 0 {
+		limit = DefaultOverviewLimit
+	}
+	if limit > MaxOverviewLimit {
+		limit = MaxOverviewLimit
+	}
+
+	course, err := s.courses.FindByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	staff, err := s.isStaff(ctx, course, user)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &CourseOverview{Course: *course, View: "student"}
+	if staff {
+		overview.View = "staff"
+	}
+	loc := courseLocation(course)
+
+	chapterIDs, err := s.chapterSection(ctx, overview, user, staff, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.assignmentSection(ctx, overview, user, staff, limit, loc); err != nil {
+		return nil, err
+	}
+	if err := s.quizSection(ctx, overview, user, staff, limit, loc); err != nil {
+		return nil, err
+	}
+	if err := s.announcementSection(ctx, overview, user, limit); err != nil {
+		return nil, err
+	}
+	if err := s.attendanceSection(ctx, overview, user, staff); err != nil {
+		return nil, err
+	}
+
+	unread, err := s.announcements.CountUnreadByCourse(ctx, course.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if staff {
+		counts := &OverviewStaffCounts{UnreadAnnouncements: unread}
+		if counts.Students, err = s.assignments.CountStudentsByCourse(ctx, course.ID); err != nil {
+			return nil, err
+		}
+		if counts.PendingGrading, err = s.assignments.CountPendingGradingByCourse(ctx, course.ID); err != nil {
+			return nil, err
+		}
+		published, err := s.quizzes.CountByCourse(ctx, course.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		counts.UnpublishedQuizzes = overview.Quizzes.Total - published
+		overview.Staff = counts
+		return overview, nil
+	}
+
+	progress, err := s.studentProgress(ctx, course.ID, user, chapterIDs)
+	if err != nil {
+		return nil, err
+	}
+	progress.UnreadAnnouncements = unread
+	overview.Progress = progress
+	return overview, nil
+}
+
+// isStaff reports whether the user sees the course as staff, and fails with
+// ErrAccessDenied when they may not see it at all.
+func (s *CourseOverviewService) isStaff(ctx context.Context, course *models.Course, user UserInfo) (bool, error) {
+	if authz.ViewingAsStudent(ctx, course.ID) {
+		return false, nil
+	}
+	if user.Role == "admin" || (user.Role == "teacher" && course.TeacherID == user.ID) {
+		return true, nil
+	}
+	enrollment, err := s.courses.FindEnrollment(ctx, course.ID, user.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrAccessDenied
+		}
+		return false, err
+	}
+	return enrollment.Role == "assistant", nil
+}
+
+// chapterSection lists the chapters and returns the IDs of all of them.
+func (s *CourseOverviewService) chapterSection(ctx context.Context, overview *CourseOverview, user UserInfo, staff bool, limit int) ([]uint, error) {
+	chapters, err := s.chapters.ListByCourse(ctx, overview.Course.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(chapters))
+	for i, ch := range chapters {
+		ids[i] = ch.ID
+	}
+	var completed map[uint]bool
+	if !staff {
+		done, err := s.chapters.ListCompletedChapterIDs(ctx, overview.Course.ID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		completed = make(map[uint]bool, len(done))
+		for _, id := range done {
+			completed[id] = true
+		}
+	}
+
+	section := OverviewSection[OverviewChapter]{Items: []OverviewChapter{}, Total: int64(len(chapters))}
+	for _, ch := range chapters {
+		if len(section.Items) == limit {
+			section.HasMore = true
+			break
+		}
+		item := OverviewChapter{ID: ch.ID, Title: ch.Title, OrderNum: ch.OrderNum}
+		if completed != nil {
+			done := completed[ch.ID]
+			item.Completed = &done
+		}
+		section.Items = append(section.Items, item)
+	}
+	overview.Chapters = section
+	return ids, nil
+}
+
+func (s *CourseOverviewService) assignmentSection(ctx context.Context, overview *CourseOverview, user UserInfo, staff bool, limit int, loc *time.Location) error {
+	assignments, total, err := s.assignments.ListPageByCourse(ctx, overview.Course.ID, !staff, 0, limit)
+	if err != nil {
+		return err
+	}
+	var submitted map[uint]bool
+	if !staff {
+		ids := make([]uint, len(assignments))
+		for i, a := range assignments {
+			ids[i] = a.ID
+		}
+		submittedIDs, err := s.assignments.ListSubmittedAssignmentIDs(ctx, user.ID, ids)
+		if err != nil {
+			return err
+		}
+		submitted = make(map[uint]bool, len(submittedIDs))
+		for _, id := range submittedIDs {
+			submitted[id] = true
+		}
+	}
+
+	section := OverviewSection[OverviewAssignment]{Items: make([]OverviewAssignment, len(assignments)), Total: total}
+	section.HasMore = total > int64(len(assignments))
+	for i, a := range assignments {
+		item := OverviewAssignment{ID: a.ID, Title: a.Title, IsPublished: a.IsPublished}
+		if a.Deadline != nil {
+			deadline := a.Deadline.In(loc)
+			item.Deadline = &deadline
+		}
+		if submitted != nil {
+			done := submitted[a.ID]
+			item.Submitted = &done
+		}
+		section.Items[i] = item
+	}
+	overview.Assignments = section
+	return nil
+}
+
+func (s *CourseOverviewService) quizSection(ctx context.Context, overview *CourseOverview, user UserInfo, staff bool, limit int, loc *time.Location) error {
+	quizzes, total, err := s.quizzes.ListPageByCourse(ctx, overview.Course.ID, !staff, 0, limit)
+	if err != nil {
+		return err
+	}
+	var used, granted map[uint]int
+	if !staff {
+		ids := make([]uint, len(quizzes))
+		for i, q := range quizzes {
+			ids[i] = q.ID
+		}
+		if used, err = s.quizzes.CountSubmittedAttemptsByStudent(ctx, user.ID, ids); err != nil {
+			return err
+		}
+		if granted, err = s.quizzes.CountAttemptGrantsByStudent(ctx, user.ID, ids); err != nil {
+			return err
+		}
+	}
+
+	section := OverviewSection[OverviewQuiz]{Items: make([]OverviewQuiz, len(quizzes)), Total: total}
+	section.HasMore = total > int64(len(quizzes))
+	for i := range quizzes {
+		q := &quizzes[i]
+		localizeQuiz(q, loc)
+		item := OverviewQuiz{
+			ID:          q.ID,
+			Title:       q.Title,
+			StartTime:   q.StartTime,
+			EndTime:     q.EndTime,
+			TimeLimit:   q.TimeLimit,
+			IsPublished: q.IsPublished,
+			MaxAttempts: q.MaxAttempts,
+		}
+		if used != nil {
+			n := used[q.ID]
+			item.AttemptsUsed = &n
+			item.MaxAttempts += granted[q.ID]
+		}
+		section.Items[i] = item
+	}
+	overview.Quizzes = section
+	return nil
+}
+
+func (s *CourseOverviewService) announcementSection(ctx context.Context, overview *CourseOverview, user UserInfo, limit int) error {
+	announcements, total, err := s.announcements.ListPageByCourse(ctx, overview.Course.ID, 0, limit)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, len(announcements))
+	for i, a := range announcements {
+		ids[i] = a.ID
+	}
+	readIDs, err := s.announcements.ListReadIDs(ctx, user.ID, ids)
+	if err != nil {
+		return err
+	}
+	read := make(map[uint]bool, len(readIDs))
+	for _, id := range readIDs {
+		read[id] = true
+	}
+
+	section := OverviewSection[OverviewAnnouncement]{Items: make([]OverviewAnnouncement, len(announcements)), Total: total}
+	section.HasMore = total > int64(len(announcements))
+	for i, a := range announcements {
+		section.Items[i] = OverviewAnnouncement{
+			ID:        a.ID,
+			Title:     a.Title,
+			Pinned:    a.Pinned,
+			CreatedAt: a.CreatedAt,
+			Read:      read[a.ID],
+		}
+	}
+	overview.Announcements = section
+	return nil
+}
+
+func (s *CourseOverviewService) attendanceSection(ctx context.Context, overview *CourseOverview, user UserInfo, staff bool) error {
+	courseIDs := []uint{overview.Course.ID}
+	sessions, err := s.attendance.CountSessionsByCourses(ctx, courseIDs)
+	if err != nil {
+		return err
+	}
+	attendance := OverviewAttendance{Sessions: sessions[overview.Course.ID]}
+	if staff {
+		active, err := s.attendance.ListActiveSessionsByCourses(ctx, courseIDs, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(active) > 0 {
+			attendance.ActiveSession = &DashboardActiveSession{ID: active[0].ID, Code: active[0].Code, EndsAt: active[0].EndAt}
+		}
+	} else {
+		attended, err := s.attendance.CountAttendedByCourses(ctx, user.ID, courseIDs)
+		if err != nil {
+			return err
+		}
+		n := attended[overview.Course.ID]
+		attendance.Attended = &n
+		if attendance.Sessions > 0 {
+			rate := float64(n) / float64(attendance.Sessions)
+			attendance.Rate = &rate
+		}
+	}
+	overview.Attendance = attendance
+	return nil
+}
+
+// studentProgress counts the student's completed chapters and the open work
+// they have left.
+func (s *CourseOverviewService) studentProgress(ctx context.Context, courseID uint, user UserInfo, chapterIDs []uint) (*OverviewProgress, error) {
+	progress := &OverviewProgress{TotalChapters: len(chapterIDs)}
+	done, err := s.chapters.ListCompletedChapterIDs(ctx, courseID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	progress.CompletedChapters = len(done)
+
+	now := time.Now()
+	courseIDs := []uint{courseID}
+	assignments, err := s.assignments.ListOpenByCourses(ctx, courseIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	assignmentIDs := make([]uint, len(assignments))
+	for i, a := range assignments {
+		assignmentIDs[i] = a.ID
+	}
+	submitted, err := s.assignments.ListSubmittedAssignmentIDs(ctx, user.ID, assignmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	progress.PendingAssignments = len(assignments) - len(submitted)
+
+	quizzes, err := s.quizzes.ListOpenByCourses(ctx, courseIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	quizIDs := make([]uint, len(quizzes))
+	for i, q := range quizzes {
+		quizIDs[i] = q.ID
+	}
+	used, err := s.quizzes.CountSubmittedAttemptsByStudent(ctx, user.ID, quizIDs)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := s.quizzes.CountAttemptGrantsByStudent(ctx, user.ID, quizIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range quizzes {
+		if used[q.ID] < q.MaxAttempts+granted[q.ID] {
+			progress.PendingQuizzes++
+		}
+	}
+	return progress, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// ErrPurgeNotConfirmed indicates a course purge whose confirmation does not
+// match the course name.
+var ErrPurgeNotConfirmed = errors.New("purge not confirmed")
+
+// CoursePurgeResult reports a purged course and how many rows were deleted
+// from each table, keyed by table name.
+type CoursePurgeResult struct {
+	CourseID uint             `json:"course_id"`
+	Name     string           `json:"name"`
+	Deleted  map[string]int64 `json:"deleted"`
+}
+
+// PurgeCourse permanently deletes a course with its chapters, quizzes,
+// assignments, resources, announcements, attendance, enrollments, writing
+// submissions and the records hanging off them, in one transaction. confirm
+// must repeat the course name, so a stray request cannot wipe a course. Only
+// admins and the course teacher may purge it, and the purge is audit-logged
+// with the counts. Uploaded files stay in object storage.
+func (s *CourseService) PurgeCourse(ctx context.Context, courseID uint, user UserInfo, confirm string) (*CoursePurgeResult, error) {
+	course, err := s.repo.FindByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFoundService
+		}
+		return nil, err
+	}
+	if !s.canManageCourse(course, user) {
+		return nil, ErrAccessDeniedService
+	}
+	if confirm != course.Name {
+		return nil, ErrPurgeNotConfirmed
+	}
+
+	result := &CoursePurgeResult{CourseID: course.ID, Name: course.Name}
+	err = s.repo.Transaction(ctx, func(tx *repositories.CourseRepository) error {
+		deleted, err := tx.Purge(ctx, course.ID)
+		if err != nil {
+			return err
+		}
+		result.Deleted = deleted
+		return RecordAudit(ctx, tx.Audit(), AuditEntry{
+			Actor:      user,
+			Action:     AuditCoursePurge,
+			TargetType: "course",
+			TargetID:   course.ID,
+			Details: map[string]interface{}{
+				"name":       course.Name,
+				"teacher_id": course.TeacherID,
+				"deleted":    deleted,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
 	"gorm.io/datatypes"
@@ -16,6 +17,14 @@ var (
 	ErrCourseNotFoundService = errors.New("course not found")
 	// ErrAccessDeniedService indicates the user is not authorized for the action.
 	ErrAccessDeniedService   = errors.New("access denied")
+	// ErrInvalidModuleSettings indicates module settings failed validation.
+	ErrInvalidModuleSettings = errors.New("invalid module settings")
+	// ErrEnrollmentNotFound indicates the user is not enrolled in the course.
+	ErrEnrollmentNotFound = errors.New("enrollment not found")
+	// ErrInvalidEnrollmentRole indicates an enrollment role other than student or assistant.
+	ErrInvalidEnrollmentRole = errors.New("invalid enrollment role")
+	// ErrEnrollmentHasWork indicates the student has submissions or quiz attempts in the course.
+	ErrEnrollmentHasWork = errors.New("enrollment has submissions or attempts")
 )
 
 // UserInfo represents user context for authorization decisions.
@@ -29,17 +38,47 @@ func (u UserInfo) IsTeacher() bool {
 	return u.Role == "admin" || u.Role == "teacher" || u.Role == "assistant"
 }
 
+// KnownModules lists the course module keys the platform understands.
+var KnownModules = []string{"core.ai", "core.analytics", "course.writing", "course.simulation", ModuleWecomNotify}
+
+// ModuleWecomNotify opts a course into WeChat Work notifications (e.g. graded results).
+const ModuleWecomNotify = "notify.wecom"
+
+// DefaultCourseModules is used when no default module set is configured.
+var DefaultCourseModules = []string{"core.ai", "core.analytics"}
+
+// UnknownModules returns the entries of modules that are not in KnownModules.
+func UnknownModules(modules []string) []string {
+	known := make(map[string]bool, len(KnownModules))
+	for _, m := range KnownModules {
+		known[m] = true
+	}
+	var unknown []string
+	for _, m := range modules {
+		if !known[m] {
+			unknown = append(unknown, m)
+		}
+	}
+	return unknown
+}
+
 // CourseService handles course management and module configuration.
 type CourseService struct {
-	repo *repositories.CourseRepository
-	db   *gorm.DB
+	repo           *repositories.CourseRepository
+	db             *gorm.DB
+	defaultModules []string
 }
 
-// NewCourseService builds a CourseService with its repository.
-func NewCourseService(db *gorm.DB) *CourseService {
+// NewCourseService builds a CourseService with its repository. defaultModules
+// are enabled on new courses that request none; empty means DefaultCourseModules.
+func NewCourseService(db *gorm.DB, defaultModules []string) *CourseService {
+	if len(defaultModules) == 0 {
+		defaultModules = DefaultCourseModules
+	}
 	return &CourseService{
-		repo: repositories.NewCourseRepository(db),
-		db:   db,
+		repo:           repositories.NewCourseRepository(db),
+		db:             db,
+		defaultModules: defaultModules,
 	}
 }
 
@@ -48,6 +87,7 @@ type CreateCourseRequest struct {
 	Name           string
 	Code           string
 	Semester       string
+	TimeZone       string
 	EnabledModules []string
 	ModuleSettings map[string]interface{}
 }
@@ -58,14 +98,16 @@ type UpdateModulesRequest struct {
 	ModuleSettings map[string]interface{}
 }
 
-// ListCourses returns courses visible to the given user.
+// ListCourses returns courses visible to the given user. Teachers see the
+// courses they own and those they assist in.
 func (s *CourseService) ListCourses(ctx context.Context, user UserInfo) ([]models.Course, error) {
 	switch user.Role {
 	case "admin":
 		return s.repo.FindAll(ctx)
 	case "teacher":
-		return s.repo.FindByTeacherID(ctx, user.ID)
+		return s.repo.FindByTeacherOrAssistant(ctx, user.ID)
 	default:
+		// Every enrollment, including courses the user assists.
 		return s.repo.FindByStudentID(ctx, user.ID)
 	}
 }
@@ -92,10 +134,13 @@ func (s *CourseService) CreateCourse(ctx context.Context, user UserInfo, req Cre
 	if user.Role != "admin" && user.Role != "teacher" {
 		return nil, ErrAccessDeniedService
 	}
+	if err := ValidateTimeZone(req.TimeZone); err != nil {
+		return nil, err
+	}
 
 	modules := normalizeModules(req.EnabledModules)
 	if len(modules) == 0 {
-		modules = []string{"core.ai", "core.analytics"}
+		modules = append([]string(nil), s.defaultModules...)
 	}
 	modulesJSON, err := json.Marshal(modules)
 	if err != nil {
@@ -106,6 +151,9 @@ func (s *CourseService) CreateCourse(ctx context.Context, user UserInfo, req Cre
 	if settings == nil {
 		settings = map[string]interface{}{}
 	}
+	if err := validateQuizSettings(settings); err != nil {
+		return nil, err
+	}
 	settingsJSON, err := json.Marshal(settings)
 	if err != nil {
 		return nil, err
@@ -115,6 +163,7 @@ func (s *CourseService) CreateCourse(ctx context.Context, user UserInfo, req Cre
 		Name:           req.Name,
 		Code:           req.Code,
 		Semester:       req.Semester,
+		TimeZone:       req.TimeZone,
 		TeacherID:      user.ID,
 		EnabledModules: datatypes.JSON(modulesJSON),
 		ModuleSettings: datatypes.JSON(settingsJSON),
@@ -178,6 +227,9 @@ func (s *CourseService) UpdateModules(ctx context.Context, courseID uint, user U
 	if settings == nil {
 		settings = map[string]interface{}{}
 	}
+	if err := validateQuizSettings(settings); err != nil {
+		return nil, nil, err
+	}
 	settingsJSON, err := json.Marshal(settings)
 	if err != nil {
 		return nil, nil, err
@@ -195,6 +247,289 @@ func (s *CourseService) UpdateModules(ctx context.Context, courseID uint, user U
 	return modules, settings, nil
 }
 
+// UpdateTimeZone sets the zone deadlines of the course are entered and shown
+// in. Stored quiz windows are instants and do not move.
+func (s *CourseService) UpdateTimeZone(ctx context.Context, courseID uint, user UserInfo, timeZone string) (*models.Course, error) {
+	if err := ValidateTimeZone(timeZone); err != nil {
+		return nil, err
+	}
+	course, err := s.repo.FindByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFoundService
+		}
+		return nil, err
+	}
+	if !s.canManageCourse(course, user) {
+		return nil, ErrAccessDeniedService
+	}
+	if err := s.repo.Update(ctx, course, map[string]interface{}{"time_zone": timeZone}); err != nil {
+		return nil, err
+	}
+	course.TimeZone = timeZone
+	return course, nil
+}
+
+// CloneCourseResult reports the new course and how many entities were copied.
+type CloneCourseResult struct {
+	Course      *models.Course `json:"course"`
+	Chapters    int            `json:"chapters"`
+	Quizzes     int            `json:"quizzes"`
+	Questions   int            `json:"questions"`
+	Assignments int            `json:"assignments"`
+	Resources   int            `json:"resources"`
+}
+
+// CloneCourse deep-copies a course's teaching material into a new course owned
+// by the requester. Student data (enrollments, submissions, attempts, progress)
+// is never copied; quizzes come back unpublished and schedule fields are
+// cleared so they can be re-planned for the new semester.
+func (s *CourseService) CloneCourse(ctx context.Context, courseID uint, user UserInfo, semester string) (*CloneCourseResult, error) {
+	source, err := s.repo.FindByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFoundService
+		}
+		return nil, err
+	}
+	if !s.canManageCourse(source, user) {
+		return nil, ErrAccessDeniedService
+	}
+	if semester == "" {
+		semester = source.Semester
+	}
+
+	result := &CloneCourseResult{}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		course := &models.Course{
+			Name:           source.Name,
+			Code:           source.Code,
+			Semester:       semester,
+			TimeZone:       source.TimeZone,
+			TeacherID:      user.ID,
+			EnabledModules: source.EnabledModules,
+			ModuleSettings: source.ModuleSettings,
+		}
+		if err := tx.Create(course).Error; err != nil {
+			return err
+		}
+		result.Course = course
+
+		var chapters []models.Chapter
+		if err := tx.Where("course_id = ?", source.ID).Order("order_num ASC, id ASC").Find(&chapters).Error; err != nil {
+			return err
+		}
+		chapterIDs := make(map[uint]uint, len(chapters))
+		for _, ch := range chapters {
+			oldID := ch.ID
+			ch.Model = gorm.Model{}
+			ch.CourseID = course.ID
+			ch.PrerequisiteChapterID = nil
+			if err := tx.Create(&ch).Error; err != nil {
+				return err
+			}
+			chapterIDs[oldID] = ch.ID
+		}
+		result.Chapters = len(chapters)
+
+		remap := func(id *uint) *uint {
+			if id == nil {
+				return nil
+			}
+			newID, ok := chapterIDs[*id]
+			if !ok {
+				return nil
+			}
+			return &newID
+		}
+
+		// Prerequisites may point at chapters created later in the loop above.
+		for _, ch := range chapters {
+			if prerequisite := remap(ch.PrerequisiteChapterID); prerequisite != nil {
+				if err := tx.Model(&models.Chapter{}).Where("id = ?", chapterIDs[ch.ID]).
+					Update("prerequisite_chapter_id", *prerequisite).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		var quizzes []models.Quiz
+		if err := tx.Where("course_id = ?", source.ID).Find(&quizzes).Error; err != nil {
+			return err
+		}
+		for _, q := range quizzes {
+			oldID := q.ID
+			q.Model = gorm.Model{}
+			q.CourseID = course.ID
+			q.ChapterID = remap(q.ChapterID)
+			q.CreatedByID = user.ID
+			q.StartTime = nil
+			q.EndTime = nil
+			q.IsPublished = false
+			if err := tx.Create(&q).Error; err != nil {
+				return err
+			}
+
+			var questions []models.Question
+			if err := tx.Where("quiz_id = ?", oldID).Order("order_num ASC").Find(&questions).Error; err != nil {
+				return err
+			}
+			for _, question := range questions {
+				question.Model = gorm.Model{}
+				question.QuizID = q.ID
+				if err := tx.Create(&question).Error; err != nil {
+					return err
+				}
+			}
+			result.Questions += len(questions)
+		}
+		result.Quizzes = len(quizzes)
+
+		var assignments []models.Assignment
+		if err := tx.Preload("Attachments").Where("course_id = ?", source.ID).Find(&assignments).Error; err != nil {
+			return err
+		}
+		for _, a := range assignments {
+			a.Model = gorm.Model{}
+			a.CourseID = course.ID
+			a.ChapterID = remap(a.ChapterID)
+			a.TeacherID = user.ID
+			a.Deadline = nil
+			a.IsPublished = false
+			for i := range a.Attachments {
+				a.Attachments[i].Model = gorm.Model{}
+				a.Attachments[i].AssignmentID = 0
+			}
+			if err := tx.Create(&a).Error; err != nil {
+				return err
+			}
+		}
+		result.Assignments = len(assignments)
+
+		var resources []models.Resource
+		if err := tx.Where("course_id = ?", source.ID).Find(&resources).Error; err != nil {
+			return err
+		}
+		for _, r := range resources {
+			r.Model = gorm.Model{}
+			r.CourseID = course.ID
+			r.ChapterID = remap(r.ChapterID)
+			r.CreatedByID = user.ID
+			if err := tx.Create(&r).Error; err != nil {
+				return err
+			}
+		}
+		result.Resources = len(resources)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateEnrollmentRole switches an enrolled user between "student" and
+// "assistant" in one course. Only the course teacher or an admin may do so.
+// Student groups only hold students, so a user made assistant leaves their
+// group in the course; submissions the group already made are kept.
+func (s *CourseService) UpdateEnrollmentRole(ctx context.Context, courseID, userID uint, user UserInfo, role string) (*models.CourseEnrollment, error) {
+	if role != "student" && role != "assistant" {
+		return nil, ErrInvalidEnrollmentRole
+	}
+	course, err := s.repo.FindByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFoundService
+		}
+		return nil, err
+	}
+	if !s.canManageCourse(course, user) {
+		return nil, ErrAccessDeniedService
+	}
+
+	var enrollment *models.CourseEnrollment
+	err = s.repo.Transaction(ctx, func(tx *repositories.CourseRepository) error {
+		var err error
+		enrollment, err = tx.FindEnrollment(ctx, courseID, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEnrollmentNotFound
+			}
+			return err
+		}
+		if enrollment.Role == role {
+			return nil
+		}
+		previousRole := enrollment.Role
+		enrollment.Role = role
+		if err := tx.UpdateEnrollmentRole(ctx, enrollment); err != nil {
+			return err
+		}
+		if err := RecordAudit(ctx, tx.Audit(), AuditEntry{
+			Actor:      user,
+			Action:     AuditEnrollmentRoleChange,
+			TargetType: "enrollment",
+			TargetID:   enrollment.ID,
+			Details: map[string]interface{}{
+				"course_id":     courseID,
+				"user_id":       userID,
+				"previous_role": previousRole,
+				"role":          role,
+			},
+		}); err != nil {
+			return err
+		}
+		if role == "assistant" {
+			return tx.DeleteGroupMemberships(ctx, courseID, userID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// Unenroll removes the student's own enrollment from a course. Only students
+// may leave this way; once they have submitted work or attempted a quiz the
+// course teacher has to remove them instead. The enrollment is soft-deleted
+// and the student leaves their group in the course.
+func (s *CourseService) Unenroll(ctx context.Context, courseID uint, user UserInfo) error {
+	if user.Role != "student" {
+		return ErrAccessDeniedService
+	}
+	if _, err := s.repo.FindByID(ctx, courseID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCourseNotFoundService
+		}
+		return err
+	}
+
+	return s.repo.Transaction(ctx, func(tx *repositories.CourseRepository) error {
+		enrollment, err := tx.FindEnrollment(ctx, courseID, user.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEnrollmentNotFound
+			}
+			return err
+		}
+		if enrollment.Role != "student" {
+			return ErrAccessDeniedService
+		}
+		work, err := tx.CountStudentWork(ctx, courseID, user.ID)
+		if err != nil {
+			return err
+		}
+		if work > 0 {
+			return ErrEnrollmentHasWork
+		}
+		if err := tx.DeleteGroupMemberships(ctx, courseID, user.ID); err != nil {
+			return err
+		}
+		return tx.DeleteEnrollment(ctx, enrollment)
+	})
+}
+
 func (s *CourseService) hasCourseAccess(ctx context.Context, course *models.Course, user UserInfo) bool {
 	if user.Role == "admin" {
 		return true
@@ -202,6 +537,9 @@ func (s *CourseService) hasCourseAccess(ctx context.Context, course *models.Cour
 	if user.Role == "teacher" && course.TeacherID == user.ID {
 		return true
 	}
+	if authz.ViewingAsStudent(ctx, course.ID) {
+		return true
+	}
 	enrolled, _ := s.repo.HasEnrollment(ctx, course.ID, user.ID)
 	return enrolled
 }
@@ -213,6 +551,19 @@ func (s *CourseService) canManageCourse(course *models.Course, user UserInfo) bo
 	return user.Role == "teacher" && course.TeacherID == user.ID
 }
 
+// ManagesCourse reports whether the user is an admin or the course teacher.
+// A missing course is managed by nobody.
+func (s *CourseService) ManagesCourse(ctx context.Context, userID uint, role string, courseID uint) (bool, error) {
+	course, err := s.repo.FindByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return s.canManageCourse(course, UserInfo{ID: userID, Role: role}), nil
+}
+
 // Helper functions - extracted from handlers for reuse
 
 func normalizeModules(modules []string) []string {
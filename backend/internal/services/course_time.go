@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+	// Embedded zone data so course time zones resolve on hosts without
+	// /usr/share/zoneinfo (e.g. minimal containers).
+	_ "time/tzdata"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+)
+
+// ErrInvalidTimeZone indicates a course time zone that is not an IANA zone name.
+var ErrInvalidTimeZone = errors.New("invalid time zone")
+
+// ValidateTimeZone checks that name is an IANA zone such as "Asia/Shanghai".
+// An empty name means UTC. "Local" is rejected because it depends on the
+// server's configuration.
+func ValidateTimeZone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if name == "Local" {
+		return ErrInvalidTimeZone
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ErrInvalidTimeZone
+	}
+	return nil
+}
+
+// courseLocation returns the course's time zone, falling back to UTC when it
+// is unset or no longer loads.
+func courseLocation(course *models.Course) *time.Location {
+	if course == nil || course.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(course.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// localizeQuiz renders the quiz window in loc. The instants are unchanged;
+// only the offset they are written with differs.
+func localizeQuiz(quiz *models.Quiz, loc *time.Location) {
+	if quiz.StartTime != nil {
+		t := quiz.StartTime.In(loc)
+		quiz.StartTime = &t
+	}
+	if quiz.EndTime != nil {
+		t := quiz.EndTime.In(loc)
+		quiz.EndTime = &t
+	}
+}
+
+// localizeAssignment renders the assignment's deadlines in loc, as
+// localizeQuiz does for quiz windows.
+func localizeAssignment(assignment *models.Assignment, loc *time.Location) {
+	if assignment.Deadline != nil {
+		t := assignment.Deadline.In(loc)
+		assignment.Deadline = &t
+	}
+	if assignment.EffectiveDeadline != nil {
+		t := assignment.EffectiveDeadline.In(loc)
+		assignment.EffectiveDeadline = &t
+	}
+}
+
+// scheduleLayouts are the wall-clock formats ScheduleTime accepts without a
+// UTC offset.
+var scheduleLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// ScheduleTime is a quiz window bound or assignment deadline as sent by a
+// client. An RFC 3339 value names an absolute instant; a value without an
+// offset, such as "2026-06-30T23:59", is wall-clock time in the course's time
+// zone.
+type ScheduleTime struct {
+	t     time.Time
+	local bool
+}
+
+// UnmarshalJSON accepts RFC 3339 or one of the offset-less wall-clock layouts.
+func (s *ScheduleTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	parsed, err := ParseScheduleTime(raw)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// ParseScheduleTime reads RFC 3339 or one of the offset-less wall-clock
+// layouts.
+func ParseScheduleTime(raw string) (ScheduleTime, error) {
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return ScheduleTime{t: t}, nil
+	}
+	for _, layout := range scheduleLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return ScheduleTime{t: t, local: true}, nil
+		}
+	}
+	return ScheduleTime{}, &time.ParseError{Layout: time.RFC3339, Value: raw, Message: ": expected RFC 3339 or YYYY-MM-DDTHH:MM[:SS]"}
+}
+
+// In returns the instant s names, reading wall-clock values in loc. The
+// result is in UTC, which is how quiz windows and deadlines are stored.
+func (s ScheduleTime) In(loc *time.Location) time.Time {
+	if !s.local {
+		return s.t.UTC()
+	}
+	return time.Date(s.t.Year(), s.t.Month(), s.t.Day(), s.t.Hour(), s.t.Minute(), s.t.Second(), s.t.Nanosecond(), loc).UTC()
+}
+
+// resolveSchedule resolves an optional bound in loc.
+func resolveSchedule(s *ScheduleTime, loc *time.Location) *time.Time {
+	if s == nil {
+		return nil
+	}
+	t := s.In(loc)
+	return &t
+}
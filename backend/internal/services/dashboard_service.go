@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// MaxDashboardCourses caps how many courses a dashboard summarises. Courses
+// are taken newest first.
+const MaxDashboardCourses = 20
+
+// DashboardService builds the home dashboard from the course, assignment,
+// attendance and upcoming-work data of the user's courses.
+type DashboardService struct {
+	courses     *repositories.CourseRepository
+	assignments *repositories.AssignmentRepository
+	attendance  *repositories.AttendanceRepository
+	upcoming    *UpcomingService
+}
+
+// NewDashboardService builds a DashboardService with its repositories.
+func NewDashboardService(db *gorm.DB) *DashboardService {
+	return &DashboardService{
+		courses:     repositories.NewCourseRepository(db),
+		assignments: repositories.NewAssignmentRepository(db),
+		attendance:  repositories.NewAttendanceRepository(db),
+		upcoming:    NewUpcomingService(db),
+	}
+}
+
+// Dashboard is a user's home page summary. Teachers and admins get a
+// TeacherCourseSummary per course they own; everyone else gets a
+// StudentCourseSummary per enrolled course plus their upcoming work.
+type Dashboard struct {
+	Role     string         `json:"role"`    // "student" or "teacher"
+	Courses  interface{}    `json:"courses"` // []StudentCourseSummary or []TeacherCourseSummary
+	Upcoming []UpcomingItem `json:"upcoming,omitempty"`
+}
+
+// StudentCourseSummary is what a student still has to do in one course.
+type StudentCourseSummary struct {
+	CourseID           uint     `json:"course_id"`
+	CourseName         string   `json:"course_name"`
+	PendingAssignments int      `json:"pending_assignments"` // published, open and not yet submitted
+	UpcomingQuizzes    int      `json:"upcoming_quizzes"`    // due within DefaultUpcomingHorizon with attempts left
+	AttendanceRate     *float64 `json:"attendance_rate"`     // nil before the first session
+}
+
+// TeacherCourseSummary is what needs a teacher's attention in one course.
+type TeacherCourseSummary struct {
+	CourseID       uint                    `json:"course_id"`
+	CourseName     string                  `json:"course_name"`
+	PendingGrading int64                   `json:"pending_grading"`
+	ActiveSession  *DashboardActiveSession `json:"active_session"`
+}
+
+// DashboardActiveSession is an attendance session still open for check-in.
+type DashboardActiveSession struct {
+	ID     uint      `json:"id"`
+	Code   string    `json:"code"`
+	EndsAt time.Time `json:"ends_at"`
+}
+
+// GetDashboard returns the dashboard for the user's role.
+func (s *DashboardService) GetDashboard(ctx context.Context, user UserInfo) (*Dashboard, error) {
+	if user.Role == "teacher" || user.Role == "admin" {
+		courses, err := s.teacherCourses(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &Dashboard{Role: "teacher", Courses: courses}, nil
+	}
+
+	courses, upcoming, err := s.studentCourses(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &Dashboard{Role: "student", Courses: courses, Upcoming: upcoming}, nil
+}
+
+func (s *DashboardService) studentCourses(ctx context.Context, user UserInfo) ([]StudentCourseSummary, []UpcomingItem, error) {
+	courses, err := s.courses.FindByStudentID(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	courses, courseIDs := limitDashboardCourses(courses)
+	summaries := make([]StudentCourseSummary, len(courses))
+	if len(courses) == 0 {
+		return summaries, []UpcomingItem{}, nil
+	}
+
+	assignments, err := s.assignments.ListOpenByCourses(ctx, courseIDs, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	assignmentIDs := make([]uint, len(assignments))
+	for i, a := range assignments {
+		assignmentIDs[i] = a.ID
+	}
+	submittedIDs, err := s.assignments.ListSubmittedAssignmentIDs(ctx, user.ID, assignmentIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	submitted := make(map[uint]bool, len(submittedIDs))
+	for _, id := range submittedIDs {
+		submitted[id] = true
+	}
+	pending := make(map[uint]int, len(courses))
+	for _, a := range assignments {
+		if !submitted[a.ID] {
+			pending[a.CourseID]++
+		}
+	}
+
+	upcoming, err := s.upcoming.ListUpcoming(ctx, user, DefaultUpcomingHorizon)
+	if err != nil {
+		return nil, nil, err
+	}
+	quizzes := make(map[uint]int, len(courses))
+	for _, item := range upcoming {
+		if item.Type == "quiz" {
+			quizzes[item.CourseID]++
+		}
+	}
+
+	sessions, err := s.attendance.CountSessionsByCourses(ctx, courseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	attended, err := s.attendance.CountAttendedByCourses(ctx, user.ID, courseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, c := range courses {
+		summary := StudentCourseSummary{
+			CourseID:           c.ID,
+			CourseName:         c.Name,
+			PendingAssignments: pending[c.ID],
+			UpcomingQuizzes:    quizzes[c.ID],
+		}
+		if total := sessions[c.ID]; total > 0 {
+			rate := float64(attended[c.ID]) / float64(total)
+			summary.AttendanceRate = &rate
+		}
+		summaries[i] = summary
+	}
+	return summaries, upcoming, nil
+}
+
+func (s *DashboardService) teacherCourses(ctx context.Context, user UserInfo) ([]TeacherCourseSummary, error) {
+	courses, err := s.courses.FindByTeacherID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	courses, courseIDs := limitDashboardCourses(courses)
+	summaries := make([]TeacherCourseSummary, len(courses))
+	if len(courses) == 0 {
+		return summaries, nil
+	}
+
+	grading, err := s.assignments.CountPendingGradingByCourses(ctx, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.attendance.ListActiveSessionsByCourses(ctx, courseIDs, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[uint]*DashboardActiveSession, len(sessions))
+	for _, session := range sessions {
+		if _, ok := active[session.CourseID]; !ok {
+			active[session.CourseID] = &DashboardActiveSession{ID: session.ID, Code: session.Code, EndsAt: session.EndAt}
+		}
+	}
+
+	for i, c := range courses {
+		summaries[i] = TeacherCourseSummary{
+			CourseID:       c.ID,
+			CourseName:     c.Name,
+			PendingGrading: grading[c.ID],
+			ActiveSession:  active[c.ID],
+		}
+	}
+	return summaries, nil
+}
+
+// limitDashboardCourses keeps the first MaxDashboardCourses courses and
+// returns their IDs.
+func limitDashboardCourses(courses []models.Course) ([]models.Course, []uint) {
+	if len(courses) > MaxDashboardCourses {
+		courses = courses[:MaxDashboardCourses]
+	}
+	ids := make([]uint, len(courses))
+	for i, c := range courses {
+		ids[i] = c.ID
+	}
+	return courses, ids
+}
@@ -0,0 +1,56 @@
+package services
+
+import "sort"
+
+// FeatureAIGrading gates AI grading suggestions on submissions.
+const FeatureAIGrading = "ai_grading"
+
+// KnownFeatures maps the feature flags the server checks to whether they are
+// on by default. FEATURE_FLAGS may also set flags the server does not know,
+// for clients to dark-launch their own features.
+var KnownFeatures = map[string]bool{
+	FeatureAIGrading: true,
+}
+
+// FeatureFlags toggles experimental features per environment and role,
+// without module settings per course.
+type FeatureFlags struct {
+	roles map[string][]string // flag -> roles it is on for, "*" for everyone
+}
+
+// NewFeatureFlags applies overrides, as parsed into config.FeatureFlags, to
+// the defaults in KnownFeatures.
+func NewFeatureFlags(overrides map[string][]string) *FeatureFlags {
+	roles := make(map[string][]string, len(KnownFeatures)+len(overrides))
+	for flag, on := range KnownFeatures {
+		if on {
+			roles[flag] = []string{"*"}
+		}
+	}
+	for flag, r := range overrides {
+		roles[flag] = r
+	}
+	return &FeatureFlags{roles: roles}
+}
+
+// Enabled reports whether flag is on for users with role.
+func (f *FeatureFlags) Enabled(flag, role string) bool {
+	for _, r := range f.roles[flag] {
+		if r == "*" || r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// EnabledFor lists, sorted, the flags that are on for users with role.
+func (f *FeatureFlags) EnabledFor(role string) []string {
+	flags := []string{}
+	for flag := range f.roles {
+		if f.Enabled(flag, role) {
+			flags = append(flags, flag)
+		}
+	}
+	sort.Strings(flags)
+	return flags
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+)
+
+// ErrInvalidLatePolicy indicates a late penalty outside 0-100 points, or one
+// set on an assignment that does not accept late work.
+var ErrInvalidLatePolicy = errors.New("invalid late policy")
+
+func validateLatePolicy(allowLate bool, perDay, floor int) error {
+	if perDay < 0 || perDay > 100 || floor < 0 || floor > 100 {
+		return ErrInvalidLatePolicy
+	}
+	if !allowLate && (perDay > 0 || floor > 0) {
+		return ErrInvalidLatePolicy
+	}
+	return nil
+}
+
+// latePenalty returns the points the assignment's late policy takes off raw
+// for the submission. Every started day past the submitting student's
+// effective deadline costs LatePenaltyPerDay, down to LatePenaltyFloor; a
+// grade already below the floor is left alone. The deadline is read at
+// grading time, so an extension granted after a late submission waives the
+// days it covers.
+func (s *AssignmentService) latePenalty(ctx context.Context, assignment *models.Assignment, submission *models.Submission, raw int) (int, error) {
+	if submission.LateAt == nil || assignment.LatePenaltyPerDay == 0 {
+		return 0, nil
+	}
+	deadline, err := s.effectiveDeadline(ctx, assignment, submission.StudentID)
+	if err != nil {
+		return 0, err
+	}
+	if deadline == nil || !submission.LateAt.After(*deadline) {
+		return 0, nil
+	}
+	late := submission.LateAt.Sub(*deadline)
+	days := int((late + 24*time.Hour - 1) / (24 * time.Hour))
+	adjusted := max(raw-days*assignment.LatePenaltyPerDay, assignment.LatePenaltyFloor)
+	if adjusted >= raw {
+		return 0, nil
+	}
+	return raw - adjusted, nil
+}
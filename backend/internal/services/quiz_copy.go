@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+)
+
+// CopyQuestions copies the given questions of sourceQuizID into the
+// unpublished quiz quizID with fresh ids, appended after its existing
+// questions in their source order. The user must manage both quizzes'
+// courses. A question id that is not in the source quiz fails the whole copy,
+// as does taking the target quiz over its size limits.
+func (s *QuizService) CopyQuestions(ctx context.Context, quizID, sourceQuizID uint, questionIDs []uint, user UserInfo) ([]QuestionResponse, error) {
+	target, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	if target.IsPublished {
+		return nil, ErrQuizPublished
+	}
+	source, err := s.findManagedQuiz(ctx, sourceQuizID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[uint]bool, len(questionIDs))
+	for _, id := range questionIDs {
+		wanted[id] = true
+	}
+	sourceQuestions, err := s.repo.ListQuestions(ctx, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]models.Question, 0, len(wanted))
+	for _, q := range sourceQuestions {
+		if wanted[q.ID] {
+			selected = append(selected, q)
+		}
+	}
+	if len(selected) != len(wanted) {
+		return nil, ErrQuestionNotFound
+	}
+	limits, err := s.quizLimits(ctx, target.CourseID)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := make([]QuestionResponse, len(selected))
+	err = s.repo.Transaction(ctx, func(tx *repositories.QuizRepository) error {
+		existing, err := tx.ListQuestions(ctx, target.ID)
+		if err != nil {
+			return err
+		}
+		nextOrder := 0
+		for _, q := range existing {
+			if q.OrderNum > nextOrder {
+				nextOrder = q.OrderNum
+			}
+		}
+		questions := make([]*models.Question, len(selected))
+		for i, q := range selected {
+			nextOrder++
+			questions[i] = &models.Question{
+				QuizID:        target.ID,
+				Type:          q.Type,
+				Content:       q.Content,
+				Options:       q.Options,
+				Answer:        q.Answer,
+				MatchRule:     q.MatchRule,
+				Points:        q.Points,
+				OrderNum:      nextOrder,
+				ImageURL:      q.ImageURL,
+				ContentFormat: q.ContentFormat,
+				Tags:          q.Tags,
+			}
+		}
+		if err := createQuestions(ctx, tx, target.ID, questions, limits); err != nil {
+			return err
+		}
+		for i, question := range questions {
+			copied[i] = toQuestionResponse(*question)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidFeedback indicates a difficulty or confidence outside 1-5, or a comment that is too long.
+var ErrInvalidFeedback = errors.New("invalid feedback")
+
+const (
+	// MaxFeedbackCommentRunes caps the length of a quiz feedback comment.
+	MaxFeedbackCommentRunes = 1000
+	// MaxFeedbackSummaryComments caps how many comments a feedback summary lists.
+	MaxFeedbackSummaryComments = 50
+)
+
+// QuizFeedbackRequest is a student's rating of a submitted attempt.
+type QuizFeedbackRequest struct {
+	Difficulty int
+	Confidence *int
+	Comment    string
+}
+
+// QuizFeedbackSummary aggregates the feedback left on a quiz. Comments are
+// listed newest first without the student's identity.
+type QuizFeedbackSummary struct {
+	QuizID            uint              `json:"quiz_id"`
+	Responses         int               `json:"responses"`
+	AverageDifficulty *float64          `json:"average_difficulty"`
+	DifficultyCounts  [5]int            `json:"difficulty_counts"` // index 0 counts difficulty 1
+	AverageConfidence *float64          `json:"average_confidence"`
+	Comments          []FeedbackComment `json:"comments"`
+}
+
+// FeedbackComment is one free-text comment in a QuizFeedbackSummary.
+type FeedbackComment struct {
+	Difficulty int       `json:"difficulty"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SubmitFeedback records the student's feedback on their own submitted
+// attempt. Sending it again replaces the earlier feedback.
+func (s *QuizService) SubmitFeedback(ctx context.Context, attemptID uint, user UserInfo, req QuizFeedbackRequest) (*models.QuizFeedback, error) {
+	comment := strings.TrimSpace(req.Comment)
+	if req.Difficulty < 1 || req.Difficulty > 5 ||
+		(req.Confidence != nil && (*req.Confidence < 1 || *req.Confidence > 5)) ||
+		utf8.RuneCountInString(comment) > MaxFeedbackCommentRunes {
+		return nil, ErrInvalidFeedback
+	}
+
+	attempt, err := s.repo.FindAttemptByID(ctx, attemptID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttemptNotFound
+		}
+		return nil, err
+	}
+	if attempt.StudentID != user.ID {
+		return nil, ErrAccessDenied
+	}
+	if attempt.SubmittedAt == nil {
+		return nil, ErrAttemptNotSubmitted
+	}
+
+	feedback, err := s.repo.FindFeedbackByAttempt(ctx, attempt.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		feedback = &models.QuizFeedback{AttemptID: attempt.ID, QuizID: attempt.QuizID, StudentID: attempt.StudentID}
+	} else if err != nil {
+		return nil, err
+	}
+	feedback.Difficulty = req.Difficulty
+	feedback.Confidence = req.Confidence
+	feedback.Comment = comment
+	if err := s.repo.SaveFeedback(ctx, feedback); err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// GetFeedbackSummary aggregates a quiz's feedback for the course teacher or
+// an admin.
+func (s *QuizService) GetFeedbackSummary(ctx context.Context, quizID uint, user UserInfo) (*QuizFeedbackSummary, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	feedback, err := s.repo.ListFeedbackByQuiz(ctx, quiz.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &QuizFeedbackSummary{QuizID: quiz.ID, Responses: len(feedback), Comments: []FeedbackComment{}}
+	var difficultySum, confidenceSum, confidenceCount int
+	for _, f := range feedback {
+		if f.Difficulty >= 1 && f.Difficulty <= 5 {
+			summary.DifficultyCounts[f.Difficulty-1]++
+		}
+		difficultySum += f.Difficulty
+		if f.Confidence != nil {
+			confidenceSum += *f.Confidence
+			confidenceCount++
+		}
+		if f.Comment != "" && len(summary.Comments) < MaxFeedbackSummaryComments {
+			summary.Comments = append(summary.Comments, FeedbackComment{
+				Difficulty: f.Difficulty,
+				Comment:    f.Comment,
+				CreatedAt:  f.CreatedAt,
+			})
+		}
+	}
+	if len(feedback) > 0 {
+		avg := float64(difficultySum) / float64(len(feedback))
+		summary.AverageDifficulty = &avg
+	}
+	if confidenceCount > 0 {
+		avg := float64(confidenceSum) / float64(confidenceCount)
+		summary.AverageConfidence = &avg
+	}
+	return summary, nil
+}
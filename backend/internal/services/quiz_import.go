@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+)
+
+// ImportQuestions adds a batch of questions to an unpublished quiz the user
+// manages. Each question is validated on its own: rowErrs holds, per request,
+// the error that kept it out or nil. The valid ones are added in request
+// order as one batch, which must fit the quiz's size limits as a whole; a
+// batch over them fails with a LimitError and adds nothing.
+func (s *QuizService) ImportQuestions(ctx context.Context, quizID uint, reqs []AddQuestionRequest, user UserInfo) ([]QuestionResponse, []error, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if quiz.IsPublished {
+		return nil, nil, ErrQuizPublished
+	}
+	limits, err := s.quizLimits(ctx, quiz.CourseID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rowErrs := make([]error, len(reqs))
+	questions := make([]*models.Question, 0, len(reqs))
+	for i, req := range reqs {
+		question, err := buildQuestion(quiz.ID, req, limits)
+		if err != nil {
+			rowErrs[i] = err
+			continue
+		}
+		questions = append(questions, question)
+	}
+	if len(questions) > 0 {
+		err = s.repo.Transaction(ctx, func(tx *repositories.QuizRepository) error {
+			return createQuestions(ctx, tx, quiz.ID, questions, limits)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	imported := make([]QuestionResponse, len(questions))
+	for i, question := range questions {
+		imported[i] = toQuestionResponse(*question)
+	}
+	return imported, rowErrs, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuizInfo is what a student sees before starting a quiz: its rules and
+// their own attempt count, without the questions.
+type QuizInfo struct {
+	QuizID             uint       `json:"quiz_id"`
+	Title              string     `json:"title"`
+	Description        string     `json:"description"`
+	TimeLimit          int        `json:"time_limit"` // minutes, 0=unlimited
+	StartTime          *time.Time `json:"start_time,omitempty"`
+	EndTime            *time.Time `json:"end_time,omitempty"`
+	TimeZone           string     `json:"time_zone"`
+	Status             string     `json:"status"` // not_started, open or ended
+	QuestionCount      int64      `json:"question_count"`
+	MaxScore           int        `json:"max_score"` // after normalization, as results report it
+	RequireAllAnswered bool       `json:"require_all_answered"`
+	// MaxAttempts includes attempts granted to the student. An in-progress
+	// attempt counts as used and is resumed by StartQuiz.
+	MaxAttempts       int        `json:"max_attempts"`
+	AttemptsUsed      int        `json:"attempts_used"`
+	AttemptsRemaining int        `json:"attempts_remaining"`
+	InProgress        bool       `json:"in_progress"`
+	AttemptDeadline   *time.Time `json:"attempt_deadline,omitempty"` // of the in-progress attempt
+}
+
+// GetQuizInfo returns a quiz's rules and the user's attempts on it. Unlike
+// GetQuiz it lists no questions, and unlike StartQuiz it uses no attempt.
+// Students only see published quizzes of their courses.
+func (s *QuizService) GetQuizInfo(ctx context.Context, quizID uint, user UserInfo) (*QuizInfo, error) {
+	quiz, err := s.findAccessibleQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsTeacher() && !quiz.IsPublished {
+		return nil, ErrQuizNotAvailable
+	}
+	loc, err := s.courseLocation(ctx, quiz.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	localizeQuiz(quiz, loc)
+	questions, err := s.repo.CountQuestions(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.repo.CountAttemptsByQuizAndStudent(ctx, quizID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := s.repo.CountAttemptGrants(ctx, quizID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &QuizInfo{
+		QuizID:             quiz.ID,
+		Title:              quiz.Title,
+		Description:        quiz.Description,
+		TimeLimit:          quiz.TimeLimit,
+		StartTime:          quiz.StartTime,
+		EndTime:            quiz.EndTime,
+		TimeZone:           loc.String(),
+		Status:             "open",
+		QuestionCount:      questions,
+		MaxScore:           quizMaxScore(quiz),
+		RequireAllAnswered: quiz.RequireAllAnswered,
+		MaxAttempts:        quiz.MaxAttempts + int(granted),
+		AttemptsUsed:       int(used),
+	}
+	info.AttemptsRemaining = max(info.MaxAttempts-info.AttemptsUsed, 0)
+	switch quizAvailability(quiz.StartTime, quiz.EndTime, time.Now()) {
+	case QuizUpcoming:
+		info.Status = "not_started"
+	case QuizEnded:
+		info.Status = "ended"
+	}
+
+	attempt, err := s.repo.FindInProgressAttempt(ctx, quizID, user.ID)
+	if err == nil {
+		deadline := attempt.Deadline.In(loc)
+		info.InProgress = true
+		info.AttemptDeadline = &deadline
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+)
+
+// QuizLimits bounds the size of question options, submitted answers and
+// whole quizzes, which keeps the answer snapshot stored per attempt bounded.
+// Courses may override the defaults under the "quiz" module setting.
+type QuizLimits struct {
+	MaxAnswersBytes int
+	MaxOptions      int
+	MaxOptionsBytes int
+	MaxQuestions    int
+	MaxTotalPoints  int
+}
+
+// DefaultQuizLimits applies when a course sets no override.
+var DefaultQuizLimits = QuizLimits{
+	MaxAnswersBytes: 100 * 1024,
+	MaxOptions:      10,
+	MaxOptionsBytes: 10 * 1024,
+	MaxQuestions:    200,
+	MaxTotalPoints:  1000,
+}
+
+// quizLimitBounds lists the overridable keys and the values they may take.
+var quizLimitBounds = []struct {
+	key      string
+	min, max int
+}{
+	{"max_answers_bytes", 1024, 1024 * 1024},
+	{"max_options", 2, 26},
+	{"max_options_bytes", 1024, 64 * 1024},
+	{"max_questions", 1, 1000},
+	{"max_total_points", 1, 10000},
+}
+
+// LimitError reports a payload over the effective limit. It unwraps to the
+// matching sentinel (ErrAnswersTooLarge, ErrTooManyOptions, ErrOptionsTooLarge,
+// ErrTooManyQuestions, ErrTooManyPoints).
+type LimitError struct {
+	Err   error
+	Limit int
+	// QuizID names the quiz over the limit in a bulk publish.
+	QuizID uint
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s (max %d)", e.Err, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// validateQuizSettings checks the "quiz" section of module settings so bad
+// limits are rejected when saved instead of silently ignored later.
+func validateQuizSettings(settings map[string]interface{}) error {
+	raw, ok := settings["quiz"]
+	if !ok {
+		return nil
+	}
+	section, ok := raw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: quiz must be an object", ErrInvalidModuleSettings)
+	}
+	for _, b := range quizLimitBounds {
+		value, ok := section[b.key]
+		if !ok {
+			continue
+		}
+		v, isNumber := value.(float64)
+		if !isNumber || v != math.Trunc(v) || v < float64(b.min) || v > float64(b.max) {
+			return fmt.Errorf("%w: quiz.%s must be an integer between %d and %d", ErrInvalidModuleSettings, b.key, b.min, b.max)
+		}
+	}
+	return nil
+}
+
+// quizLimitsFor reads the quiz limits from course module settings, falling
+// back to defaults for missing or out-of-range values.
+func quizLimitsFor(course *models.Course) QuizLimits {
+	limits := DefaultQuizLimits
+	if course == nil {
+		return limits
+	}
+	settings, err := parseModuleSettings(course.ModuleSettings)
+	if err != nil {
+		return limits
+	}
+	section, ok := settings["quiz"].(map[string]interface{})
+	if !ok {
+		return limits
+	}
+	targets := map[string]*int{
+		"max_answers_bytes": &limits.MaxAnswersBytes,
+		"max_options":       &limits.MaxOptions,
+		"max_options_bytes": &limits.MaxOptionsBytes,
+		"max_questions":     &limits.MaxQuestions,
+		"max_total_points":  &limits.MaxTotalPoints,
+	}
+	for _, b := range quizLimitBounds {
+		v, ok := section[b.key].(float64)
+		if ok && v == math.Trunc(v) && v >= float64(b.min) && v <= float64(b.max) {
+			*targets[b.key] = int(v)
+		}
+	}
+	return limits
+}
+
+// checkQuizSize fails with a LimitError when a quiz of questions questions
+// worth points in total is over limits.
+func checkQuizSize(questions int64, points int, limits QuizLimits) error {
+	if questions > int64(limits.MaxQuestions) {
+		return &LimitError{Err: ErrTooManyQuestions, Limit: limits.MaxQuestions}
+	}
+	if points > limits.MaxTotalPoints {
+		return &LimitError{Err: ErrTooManyPoints, Limit: limits.MaxTotalPoints}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+)
+
+// MaxNormalizeTo caps the total a quiz may be normalized to.
+const MaxNormalizeTo = 1000
+
+var (
+	// ErrInvalidNormalizeTo indicates a normalization target outside 0-MaxNormalizeTo.
+	ErrInvalidNormalizeTo = errors.New("invalid normalize_to")
+	// ErrNormalizeToLocked indicates a change of normalization target on a
+	// quiz students have already attempted, whose scores it would misstate.
+	ErrNormalizeToLocked = errors.New("normalize_to cannot change: attempts exist")
+)
+
+// NormalizationPreview shows how a quiz's question points would be weighted
+// if it were published with NormalizeTo.
+type NormalizationPreview struct {
+	QuizID      uint                 `json:"quiz_id"`
+	TotalPoints int                  `json:"total_points"`
+	NormalizeTo int                  `json:"normalize_to"` // 0 keeps raw points
+	Scale       float64              `json:"scale"`
+	Questions   []NormalizedQuestion `json:"questions"`
+}
+
+// NormalizedQuestion is one question's raw and weighted points.
+type NormalizedQuestion struct {
+	QuestionID     uint    `json:"question_id"`
+	Points         int     `json:"points"`
+	WeightedPoints float64 `json:"weighted_points"`
+}
+
+// PreviewNormalization reports the per-question weights publishing with
+// normalizeTo would give, without changing the quiz. A nil normalizeTo
+// previews the quiz's current setting. Only the course teacher or an admin
+// may preview it.
+func (s *QuizService) PreviewNormalization(ctx context.Context, quizID uint, user UserInfo, normalizeTo *int) (*NormalizationPreview, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := setNormalizeTo(quiz, normalizeTo); err != nil {
+		return nil, err
+	}
+	questions, err := s.repo.ListQuestions(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, q := range questions {
+		total += q.Points
+	}
+	scale := normalizationScale(quiz.NormalizeTo, total)
+	preview := &NormalizationPreview{
+		QuizID:      quiz.ID,
+		TotalPoints: total,
+		NormalizeTo: quiz.NormalizeTo,
+		Scale:       scale,
+		Questions:   make([]NormalizedQuestion, len(questions)),
+	}
+	if scale == 0 {
+		preview.Scale = 1
+	}
+	for i, q := range questions {
+		preview.Questions[i] = NormalizedQuestion{
+			QuestionID:     q.ID,
+			Points:         q.Points,
+			WeightedPoints: float64(q.Points) * preview.Scale,
+		}
+	}
+	return preview, nil
+}
+
+// setNormalizeTo applies a requested target: nil keeps the quiz's setting
+// and 0 clears it.
+func setNormalizeTo(quiz *models.Quiz, normalizeTo *int) error {
+	if normalizeTo == nil {
+		return nil
+	}
+	if *normalizeTo < 0 || *normalizeTo > MaxNormalizeTo {
+		return ErrInvalidNormalizeTo
+	}
+	quiz.NormalizeTo = *normalizeTo
+	return nil
+}
+
+// applyPublishedPoints records the quiz's total points and the score scale
+// derived from them, as done on every publish.
+func applyPublishedPoints(quiz *models.Quiz, totalPoints int) {
+	quiz.TotalPoints = totalPoints
+	quiz.ScoreScale = normalizationScale(quiz.NormalizeTo, totalPoints)
+}
+
+// normalizationScale is the factor that maps totalPoints to normalizeTo, or 0
+// when scores stay in raw points.
+func normalizationScale(normalizeTo, totalPoints int) float64 {
+	if normalizeTo <= 0 || totalPoints <= 0 {
+		return 0
+	}
+	return float64(normalizeTo) / float64(totalPoints)
+}
+
+// scaledScore converts raw points to the scale the quiz reports scores in.
+func scaledScore(quiz *models.Quiz, raw int) int {
+	if quiz.ScoreScale <= 0 {
+		return raw
+	}
+	return int(math.Round(float64(raw) * quiz.ScoreScale))
+}
+
+// quizMaxScore is the score a fully correct attempt reports.
+func quizMaxScore(quiz *models.Quiz) int {
+	return scaledScore(quiz, quiz.TotalPoints)
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"regexp"
-	"sort"
+	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/authz"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/grading"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
 	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
 	"gorm.io/gorm"
@@ -28,8 +32,20 @@ var (
 	ErrMaxAttemptsReached = errors.New("maximum attempts reached")
 	// ErrNoActiveAttempt indicates no in-progress attempt exists.
 	ErrNoActiveAttempt = errors.New("no active attempt")
+	// ErrAttemptNotFound indicates the quiz attempt does not exist.
+	ErrAttemptNotFound = errors.New("attempt not found")
+	// ErrAttemptNotSubmitted indicates the attempt is still in progress.
+	ErrAttemptNotSubmitted = errors.New("attempt not submitted")
+	// ErrAttemptInProgress indicates the student already has an attempt in progress on the quiz.
+	ErrAttemptInProgress = errors.New("another attempt in progress")
+	// ErrAlreadySubmitted indicates a concurrent request submitted the attempt first.
+	ErrAlreadySubmitted = errors.New("attempt already submitted")
+	// ErrUnansweredQuestions indicates the quiz requires every question to be answered.
+	ErrUnansweredQuestions = errors.New("unanswered questions")
 	// ErrSubmissionDeadline indicates the attempt deadline has passed.
 	ErrSubmissionDeadline = errors.New("submission deadline passed")
+	// ErrStudentNotEnrolled indicates the target student is not enrolled in the quiz's course.
+	ErrStudentNotEnrolled = errors.New("student not enrolled in course")
 	// ErrAnswersTooLarge indicates the answer payload exceeds limits.
 	ErrAnswersTooLarge = errors.New("answers too large")
 	// ErrQuizPublished indicates edits are blocked for published quizzes.
@@ -38,22 +54,119 @@ var (
 	ErrQuestionNotFound = errors.New("question not found")
 	// ErrInvalidQuestionType indicates the question type is not supported.
 	ErrInvalidQuestionType = errors.New("invalid question type")
+	// ErrInvalidQuestionAnswer indicates an ordering or matching answer does not fit its options.
+	ErrInvalidQuestionAnswer = errors.New("answer does not match options")
 	// ErrTooManyOptions indicates a question exceeds the options limit.
 	ErrTooManyOptions = errors.New("too many options")
 	// ErrOptionsTooLarge indicates the options payload exceeds limits.
 	ErrOptionsTooLarge = errors.New("options too large")
+	// ErrTooManyQuestions indicates a quiz exceeds the questions limit.
+	ErrTooManyQuestions = errors.New("too many questions")
+	// ErrTooManyPoints indicates a quiz's questions are worth more than the total points limit.
+	ErrTooManyPoints = errors.New("total points too high")
+	// ErrBlankOption indicates a choice option is empty after trimming.
+	ErrBlankOption = errors.New("blank option")
+	// ErrDuplicateOption indicates two choice options are equal after trimming.
+	ErrDuplicateOption = errors.New("duplicate option")
 	// ErrUnpublishNotAllowed indicates a quiz cannot be unpublished due to attempts.
 	ErrUnpublishNotAllowed = errors.New("cannot unpublish: attempts exist")
+	// ErrQuizNotInCourse indicates a quiz in a bulk request is missing or belongs to another course.
+	ErrQuizNotInCourse = errors.New("quiz not in course")
+	// ErrInvalidImageURL indicates a question image reference is not a valid http(s) URL.
+	ErrInvalidImageURL = errors.New("invalid image url")
+	// ErrLeaderboardDisabled indicates the quiz leaderboard is not visible to students.
+	ErrLeaderboardDisabled = errors.New("leaderboard disabled")
+	// ErrInvalidContentFormat indicates the question content format is not supported.
+	ErrInvalidContentFormat = errors.New("invalid content format")
+	// ErrContentTooLarge indicates question content would not fit the content column.
+	ErrContentTooLarge = errors.New("question content too large")
+	// ErrQuestionAnswerTooLong indicates the correct answer exceeds the answer column size.
+	ErrQuestionAnswerTooLong = errors.New("question answer too long")
+	// ErrUnbalancedLatex indicates latex content has an unterminated math delimiter.
+	ErrUnbalancedLatex = errors.New("unbalanced latex math delimiters")
+	// ErrInvalidQuizWindow indicates a quiz would end at or before its start.
+	ErrInvalidQuizWindow = errors.New("quiz end time must be after start time")
+	// ErrInvalidTimeLimit indicates a negative time limit or one longer than the quiz window.
+	ErrInvalidTimeLimit = errors.New("invalid time limit")
 )
 
+// maxQuestionContentBytes matches the MySQL TEXT column limit. Content above it
+// is rejected rather than stored, so a LaTeX expression is never cut in half.
+const maxQuestionContentBytes = 65535
+
+// maxQuestionAnswerBytes matches the size of models.Question.Answer.
+const maxQuestionAnswerBytes = 512
+
+var validContentFormats = map[string]bool{"plain": true, "markdown": true, "latex": true}
+
+// DuplicateOptionError reports which option value is repeated; it matches ErrDuplicateOption.
+type DuplicateOptionError struct {
+	Value string
+}
+
+func (e *DuplicateOptionError) Error() string {
+	return fmt.Sprintf("duplicate option %q", e.Value)
+}
+
+// Unwrap lets errors.Is(err, ErrDuplicateOption) match.
+func (e *DuplicateOptionError) Unwrap() error {
+	return ErrDuplicateOption
+}
+
+// QuizNotInCourseError lists the quizzes of a bulk request that are missing
+// or belong to another course. It unwraps to ErrQuizNotInCourse.
+type QuizNotInCourseError struct {
+	QuizIDs []uint
+}
+
+func (e *QuizNotInCourseError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrQuizNotInCourse, e.QuizIDs)
+}
+
+// Unwrap lets errors.Is(err, ErrQuizNotInCourse) match.
+func (e *QuizNotInCourseError) Unwrap() error {
+	return ErrQuizNotInCourse
+}
+
+// AlreadySubmittedError carries the result of the submission that won a race
+// for the same attempt; it matches ErrAlreadySubmitted.
+type AlreadySubmittedError struct {
+	Result SubmitQuizResult
+}
+
+func (e *AlreadySubmittedError) Error() string {
+	return fmt.Sprintf("attempt %d already submitted", e.Result.Attempt.ID)
+}
+
+// Unwrap lets errors.Is(err, ErrAlreadySubmitted) match.
+func (e *AlreadySubmittedError) Unwrap() error {
+	return ErrAlreadySubmitted
+}
+
+// UnansweredError lists the 1-based numbers of questions left blank on a quiz
+// that requires all answers; it matches ErrUnansweredQuestions.
+type UnansweredError struct {
+	QuestionNumbers []int
+}
+
+func (e *UnansweredError) Error() string {
+	return fmt.Sprintf("%d unanswered questions", len(e.QuestionNumbers))
+}
+
+// Unwrap lets errors.Is(err, ErrUnansweredQuestions) match.
+func (e *UnansweredError) Unwrap() error {
+	return ErrUnansweredQuestions
+}
+
 // QuizService handles quiz management and attempts.
 type QuizService struct {
-	repo *repositories.QuizRepository
+	repo    *repositories.QuizRepository
+	courses *repositories.CourseRepository
 }
 
 // NewQuizService builds a QuizService with its repository.
 func NewQuizService(db *gorm.DB) *QuizService {
-	return &QuizService{repo: repositories.NewQuizRepository(db)}
+	return &QuizService{repo: repositories.NewQuizRepository(db), courses: repositories.NewCourseRepository(db)}
 }
 
 // QuizWithAttempt decorates a quiz with attempt statistics.
@@ -73,30 +186,40 @@ type QuestionWithAnswer struct {
 type QuizDetail struct {
 	Quiz      models.Quiz
 	Questions interface{}
+	TimeZone  string // the course's zone the quiz window is shown in
 }
 
 // CreateQuizRequest contains the fields required to create a quiz.
 type CreateQuizRequest struct {
 	CourseID           uint
+	ChapterID          *uint // optional; must be a chapter of the course
 	Title              string
 	Description        string
 	TimeLimit          int
-	StartTime          *time.Time
-	EndTime            *time.Time
+	StartTime          *ScheduleTime
+	EndTime            *ScheduleTime
 	MaxAttempts        int
 	ShowAnswerAfterEnd bool
+	LeaderboardEnabled bool
+	RequireAllAnswered bool
 	CreatedByID        uint
 }
 
 // UpdateQuizRequest contains fields that can be updated on a quiz.
 type UpdateQuizRequest struct {
+	// MoveChapter makes the update attach the quiz to ChapterID, or detach it
+	// from its chapter when ChapterID is nil.
+	MoveChapter        bool
+	ChapterID          *uint
 	Title              *string
 	Description        *string
 	TimeLimit          *int
-	StartTime          *time.Time
-	EndTime            *time.Time
+	StartTime          *ScheduleTime
+	EndTime            *ScheduleTime
 	MaxAttempts        *int
 	ShowAnswerAfterEnd *bool
+	LeaderboardEnabled *bool
+	RequireAllAnswered *bool
 }
 
 // AddQuestionRequest contains the fields required to add a question.
@@ -108,29 +231,63 @@ type AddQuestionRequest struct {
 	MatchRule string
 	Points    int
 	OrderNum  int
+	ImageURL  string
+	// ContentFormat is plain (default), markdown or latex.
+	ContentFormat string
+	Tags          []string
 }
 
 // UpdateQuestionRequest contains the fields that can be updated on a question.
 type UpdateQuestionRequest struct {
-	Content   *string
-	Options   []string
-	Answer    *string
-	MatchRule *string
-	Points    *int
-	OrderNum  *int
+	Content       *string
+	Options       []string
+	Answer        *string
+	MatchRule     *string
+	Points        *int
+	OrderNum      *int
+	ImageURL      *string
+	ContentFormat *string
+	// Tags replaces the question's tags when non-nil; empty clears them.
+	Tags []string
 }
 
 // QuestionResponse is the API response payload for a question.
 type QuestionResponse struct {
-	ID        uint        `json:"ID"`
-	QuizID    uint        `json:"quiz_id"`
-	Type      string      `json:"type"`
-	Content   string      `json:"content"`
-	Options   interface{} `json:"options"`
-	Answer    string      `json:"answer"`
-	MatchRule string      `json:"match_rule"`
-	Points    int         `json:"points"`
-	OrderNum  int         `json:"order_num"`
+	ID            uint        `json:"ID"`
+	QuizID        uint        `json:"quiz_id"`
+	Type          string      `json:"type"`
+	Content       string      `json:"content"`
+	Options       interface{} `json:"options"`
+	Answer        string      `json:"answer"`
+	MatchRule     string      `json:"match_rule"`
+	Points        int         `json:"points"`
+	OrderNum      int         `json:"order_num"`
+	ImageURL      string      `json:"image_url,omitempty"`
+	ContentFormat string      `json:"content_format"`
+	Tags          []string    `json:"tags"`
+}
+
+// toQuestionResponse builds the response for a stored question, decoding its
+// options and tags from their JSON columns.
+func toQuestionResponse(q models.Question) QuestionResponse {
+	var options []string
+	if q.Options != "" {
+		_ = json.Unmarshal([]byte(q.Options), &options)
+	}
+	return QuestionResponse{
+		ID:            q.ID,
+		QuizID:        q.QuizID,
+		Type:          q.Type,
+		Content:       q.Content,
+		Options:       options,
+		Answer:        q.Answer,
+		MatchRule:     q.MatchRule,
+		Points:        q.Points,
+		OrderNum:      q.OrderNum,
+		ImageURL:      q.ImageURL,
+		ContentFormat: q.ContentFormat,
+		Tags:          decodeTags(q.Tags),
+	}
 }
 
 // StartQuizResult returns the attempt and questions for a started quiz.
@@ -143,6 +300,10 @@ type StartQuizResult struct {
 // SubmitQuizRequest contains the student's answers.
 type SubmitQuizRequest struct {
 	Answers map[string]interface{}
+	// ClientIP and UserAgent identify where the submission came from. They
+	// are stored on the attempt and shown only to staff.
+	ClientIP  string
+	UserAgent string
 }
 
 // SubmitQuizResult returns the attempt score summary.
@@ -152,25 +313,265 @@ type SubmitQuizResult struct {
 	MaxScore int
 }
 
+// CloseGracePeriod is how long in-progress attempts may still be submitted
+// after a teacher closes a quiz early.
+const CloseGracePeriod = 2 * time.Minute
+
+// SubmissionGracePeriod is how long after an attempt's deadline a submission is
+// still accepted. It absorbs network latency and small client clock drift;
+// clients should sync their timer via GetAttemptRemaining rather than rely on it.
+const SubmissionGracePeriod = 5 * time.Second
+
+// AttemptRemaining is the server-authoritative time left on an active attempt.
+type AttemptRemaining struct {
+	AttemptID        uint      `json:"attempt_id"`
+	Deadline         time.Time `json:"deadline"`
+	ServerTime       time.Time `json:"server_time"`
+	RemainingSeconds int       `json:"remaining_seconds"`
+}
+
 // QuizResult represents quiz attempts and optional answer data.
 type QuizResult struct {
 	Quiz      models.Quiz
 	Attempts  []models.QuizAttempt
 	Questions interface{}
+	// ReviewAttemptID is the attempt used for per-question review, if any.
+	ReviewAttemptID *uint
+	// Snapshots holds, for a student, each submitted attempt's questions as
+	// they were answered. Correct answers are never included.
+	Snapshots []AttemptSnapshot
+	// Origins holds, for staff, where each submitted attempt came from.
+	Origins []AttemptOrigin
+}
+
+// AttemptOrigin is the client that submitted an attempt.
+type AttemptOrigin struct {
+	AttemptID uint   `json:"attempt_id"`
+	StudentID uint   `json:"student_id"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// AttemptSnapshot is the questions of one submitted attempt with the
+// student's responses.
+type AttemptSnapshot struct {
+	AttemptID     uint               `json:"attempt_id"`
+	AttemptNumber int                `json:"attempt_number"`
+	Questions     []SnapshotQuestion `json:"questions"`
+}
+
+// SnapshotQuestion is a question as shown in an attempt and the student's answer to it.
+type SnapshotQuestion struct {
+	models.Question
+	StudentAnswer interface{} `json:"student_answer,omitempty"`
+}
+
+// QuestionReview shows how a student's answer to a question was graded.
+type QuestionReview struct {
+	QuestionWithAnswer
+	StudentAnswer interface{} `json:"student_answer,omitempty"`
+	Correct       bool        `json:"correct"`
+	EarnedPoints  int         `json:"earned_points"`
+}
+
+// GradePreview is the score a set of sample answers would get, without an attempt.
+type GradePreview struct {
+	QuizID    uint             `json:"quiz_id"`
+	Score     int              `json:"score"`
+	MaxScore  int              `json:"max_score"`
+	Questions []QuestionReview `json:"questions"`
+}
+
+// AnswerCorrection replaces the correct answer of one question during a regrade.
+type AnswerCorrection struct {
+	QuestionID uint   `json:"question_id"`
+	Answer     string `json:"answer"`
+}
+
+// RegradeResult summarizes a regrade: how many submitted attempts were
+// re-scored and how many of those ended up with a different score.
+type RegradeResult struct {
+	QuizID             uint `json:"quiz_id"`
+	QuestionsCorrected int  `json:"questions_corrected"`
+	AttemptsChecked    int  `json:"attempts_checked"`
+	AttemptsChanged    int  `json:"attempts_changed"`
+}
+
+// QuizResultRow is one student's line in a quiz results export. Students who
+// never submitted have zero attempts and nil scores.
+type QuizResultRow struct {
+	StudentID        uint       `json:"student_id"`
+	Username         string     `json:"username"`
+	Name             string     `json:"name"`
+	Attempts         int        `json:"attempts"`
+	BestScore        *int       `json:"best_score"`
+	LatestScore      *int       `json:"latest_score"`
+	TimeSpentSeconds int        `json:"time_spent_seconds"`
+	LastSubmittedAt  *time.Time `json:"last_submitted_at"`
+}
+
+// QuizResults lists every enrolled student's results for one quiz.
+type QuizResults struct {
+	Quiz     models.Quiz     `json:"quiz"`
+	MaxScore int             `json:"max_score"`
+	Students []QuizResultRow `json:"students"`
+}
+
+// LeaderboardEntry is a single ranked row on a quiz leaderboard.
+type LeaderboardEntry struct {
+	Rank        int    `json:"rank"`
+	StudentID   uint   `json:"student_id,omitempty"`
+	DisplayName string `json:"display_name"`
+	BestScore   int    `json:"best_score"`
+	IsMe        bool   `json:"is_me,omitempty"`
+}
+
+// StudentQuizAttempt is one submitted attempt in a student's course history.
+type StudentQuizAttempt struct {
+	AttemptID     uint      `json:"attempt_id"`
+	QuizID        uint      `json:"quiz_id"`
+	QuizTitle     string    `json:"quiz_title"`
+	AttemptNumber int       `json:"attempt_number"`
+	Score         *int      `json:"score"`
+	MaxScore      int       `json:"max_score"`
+	SubmittedAt   time.Time `json:"submitted_at"`
+}
+
+// Leaderboard is the ranked best-score list for a quiz.
+type Leaderboard struct {
+	QuizID     uint               `json:"quiz_id"`
+	MaxScore   int                `json:"max_score"`
+	Anonymized bool               `json:"anonymized"`
+	Entries    []LeaderboardEntry `json:"entries"`
+}
+
+// CourseQuizSummary aggregates quiz activity for a course dashboard.
+type CourseQuizSummary struct {
+	CourseID              uint    `json:"course_id"`
+	TotalQuizzes          int64   `json:"total_quizzes"`
+	PublishedQuizzes      int64   `json:"published_quizzes"`
+	SubmittedAttempts     int64   `json:"submitted_attempts"`
+	AvgScoreRate          float64 `json:"avg_score_rate"` // mean of score/max_score over submitted attempts, 0-1
+	EnrolledStudents      int64   `json:"enrolled_students"`
+	ParticipatingStudents int64   `json:"participating_students"`
+	ParticipationRate     float64 `json:"participation_rate"` // students with at least one attempt / enrolled, 0-1
+}
+
+// checkCourseAccess returns ErrAccessDenied unless the user is an admin, the
+// course teacher, or enrolled in the course.
+func (s *QuizService) checkCourseAccess(ctx context.Context, courseID uint, user UserInfo) error {
+	if user.Role == "admin" {
+		return nil
+	}
+	if user.Role == "teacher" {
+		course, err := s.repo.FindCourse(ctx, courseID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCourseNotFound
+			}
+			return err
+		}
+		if course.TeacherID != user.ID {
+			return ErrAccessDenied
+		}
+		return nil
+	}
+	if authz.ViewingAsStudent(ctx, courseID) {
+		return nil
+	}
+	enrolled, err := s.repo.HasEnrollment(ctx, courseID, user.ID)
+	if err != nil {
+		return err
+	}
+	if !enrolled {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// findManagedQuiz loads a quiz and checks the user is an admin or the course teacher.
+func (s *QuizService) findManagedQuiz(ctx context.Context, quizID uint, user UserInfo) (*models.Quiz, error) {
+	quiz, err := s.repo.FindByID(ctx, quizID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuizNotFound
+		}
+		return nil, err
+	}
+	course, err := s.repo.FindCourse(ctx, quiz.CourseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	if user.Role != "admin" && !(user.Role == "teacher" && course.TeacherID == user.ID) {
+		return nil, ErrAccessDenied
+	}
+	return quiz, nil
+}
+
+// courseLocation returns the time zone quiz windows of the course are read and
+// shown in.
+func (s *QuizService) courseLocation(ctx context.Context, courseID uint) (*time.Location, error) {
+	course, err := s.repo.FindCourse(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	return courseLocation(course), nil
+}
+
+// findAccessibleQuiz loads a quiz and checks the user may access its course.
+func (s *QuizService) findAccessibleQuiz(ctx context.Context, quizID uint, user UserInfo) (*models.Quiz, error) {
+	quiz, err := s.repo.FindByID(ctx, quizID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuizNotFound
+		}
+		return nil, err
+	}
+	if err := s.checkCourseAccess(ctx, quiz.CourseID, user); err != nil {
+		return nil, err
+	}
+	return quiz, nil
 }
 
 // ListQuizzes lists quizzes for a course, with student attempt metadata.
-func (s *QuizService) ListQuizzes(ctx context.Context, courseID uint, user UserInfo) (interface{}, error) {
-	quizzes, err := s.repo.ListByCourse(ctx, courseID, !user.IsTeacher())
+// includeDeleted adds soft-deleted quizzes and is limited to admins and the
+// course teacher.
+func (s *QuizService) ListQuizzes(ctx context.Context, courseID uint, user UserInfo, includeDeleted bool) (interface{}, error) {
+	if err := s.checkCourseAccess(ctx, courseID, user); err != nil {
+		return nil, err
+	}
+	if includeDeleted && user.Role != "admin" && user.Role != "teacher" {
+		return nil, ErrAccessDenied
+	}
+	quizzes, err := s.repo.ListByCourse(ctx, courseID, !user.IsTeacher(), includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := s.courseLocation(ctx, courseID)
 	if err != nil {
 		return nil, err
 	}
+	for i := range quizzes {
+		localizeQuiz(&quizzes[i], loc)
+	}
 	if user.IsTeacher() {
 		return quizzes, nil
 	}
+	return s.withAttempts(ctx, quizzes, user.ID)
+}
+
+// withAttempts decorates quizzes with the student's attempt count and best
+// score.
+func (s *QuizService) withAttempts(ctx context.Context, quizzes []models.Quiz, studentID uint) ([]QuizWithAttempt, error) {
 	result := make([]QuizWithAttempt, 0, len(quizzes))
 	for _, q := range quizzes {
-		attempts, err := s.repo.ListAttemptsByQuizAndStudent(ctx, q.ID, user.ID)
+		attempts, err := s.repo.ListAttemptsByQuizAndStudent(ctx, q.ID, studentID)
 		if err != nil {
 			return nil, err
 		}
@@ -193,40 +594,83 @@ func (s *QuizService) ListQuizzes(ctx context.Context, courseID uint, user UserI
 	return result, nil
 }
 
-// CreateQuiz creates a new quiz.
+// CreateQuiz creates a new quiz. Window bounds without an offset are read in
+// the course's time zone.
 func (s *QuizService) CreateQuiz(ctx context.Context, req CreateQuizRequest) (*models.Quiz, error) {
+	loc, err := s.courseLocation(ctx, req.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	startTime := resolveSchedule(req.StartTime, loc)
+	endTime := resolveSchedule(req.EndTime, loc)
+	if err := validateQuizSchedule(startTime, endTime, req.TimeLimit); err != nil {
+		return nil, err
+	}
+	if req.ChapterID != nil {
+		if err := validateContentChapter(ctx, s.repo.FindChapter, req.CourseID, *req.ChapterID); err != nil {
+			return nil, err
+		}
+	}
 	maxAttempts := req.MaxAttempts
 	if maxAttempts < 1 || maxAttempts > 3 {
 		maxAttempts = 1
 	}
 	quiz := &models.Quiz{
 		CourseID:           req.CourseID,
+		ChapterID:          req.ChapterID,
 		CreatedByID:        req.CreatedByID,
 		Title:              req.Title,
 		Description:        req.Description,
 		TimeLimit:          req.TimeLimit,
-		StartTime:          req.StartTime,
-		EndTime:            req.EndTime,
+		StartTime:          startTime,
+		EndTime:            endTime,
 		MaxAttempts:        maxAttempts,
 		ShowAnswerAfterEnd: req.ShowAnswerAfterEnd,
+		LeaderboardEnabled: req.LeaderboardEnabled,
+		RequireAllAnswered: req.RequireAllAnswered,
 		IsPublished:        false,
 		TotalPoints:        0,
 	}
 	if err := s.repo.Create(ctx, quiz); err != nil {
 		return nil, err
 	}
+	localizeQuiz(quiz, loc)
 	return quiz, nil
 }
 
+// validateQuizSchedule checks that a quiz ends after it starts and that its
+// time limit (minutes, 0 for none) is not negative and fits in the window
+// when both bounds are set.
+func validateQuizSchedule(start, end *time.Time, timeLimit int) error {
+	if timeLimit < 0 {
+		return ErrInvalidTimeLimit
+	}
+	if start == nil || end == nil {
+		return nil
+	}
+	if !end.After(*start) {
+		return ErrInvalidQuizWindow
+	}
+	if time.Duration(timeLimit)*time.Minute > end.Sub(*start) {
+		return ErrInvalidTimeLimit
+	}
+	return nil
+}
+
 // GetQuiz returns quiz details and questions, with access rules applied.
 func (s *QuizService) GetQuiz(ctx context.Context, quizID uint, user UserInfo) (*QuizDetail, error) {
-	quiz, err := s.repo.FindByID(ctx, quizID)
+	quiz, err := s.findAccessibleQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsTeacher() && !quiz.IsPublished {
+		return nil, ErrQuizNotAvailable
+	}
+	loc, err := s.courseLocation(ctx, quiz.CourseID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrQuizNotFound
-		}
 		return nil, err
 	}
+	localizeQuiz(quiz, loc)
 	questions, err := s.repo.ListQuestions(ctx, quizID)
 	if err != nil {
 		return nil, err
@@ -236,12 +680,9 @@ func (s *QuizService) GetQuiz(ctx context.Context, quizID uint, user UserInfo) (
 		for i, q := range questions {
 			withAnswers[i] = QuestionWithAnswer{Question: q, Answer: q.Answer}
 		}
-		return &QuizDetail{Quiz: *quiz, Questions: withAnswers}, nil
+		return &QuizDetail{Quiz: *quiz, Questions: withAnswers, TimeZone: loc.String()}, nil
 	}
-	if !quiz.IsPublished {
-		return nil, ErrQuizNotAvailable
-	}
-	return &QuizDetail{Quiz: *quiz, Questions: questions}, nil
+	return &QuizDetail{Quiz: *quiz, Questions: questions, TimeZone: loc.String()}, nil
 }
 
 // UpdateQuiz updates editable quiz fields.
@@ -253,8 +694,38 @@ func (s *QuizService) UpdateQuiz(ctx context.Context, quizID uint, req UpdateQui
 		}
 		return nil, err
 	}
+	loc, err := s.courseLocation(ctx, quiz.CourseID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the window the quiz will have after the update, since a
+	// request may change only one bound.
+	startTime, endTime, timeLimit := quiz.StartTime, quiz.EndTime, quiz.TimeLimit
+	if req.StartTime != nil {
+		startTime = resolveSchedule(req.StartTime, loc)
+	}
+	if req.EndTime != nil {
+		endTime = resolveSchedule(req.EndTime, loc)
+	}
+	if req.TimeLimit != nil {
+		timeLimit = *req.TimeLimit
+	}
+	if err := validateQuizSchedule(startTime, endTime, timeLimit); err != nil {
+		return nil, err
+	}
 
 	updates := make(map[string]interface{})
+	if req.MoveChapter {
+		if req.ChapterID == nil {
+			updates["chapter_id"] = nil
+		} else {
+			if err := validateContentChapter(ctx, s.repo.FindChapter, quiz.CourseID, *req.ChapterID); err != nil {
+				return nil, err
+			}
+			updates["chapter_id"] = *req.ChapterID
+		}
+	}
 	if req.Title != nil {
 		updates["title"] = *req.Title
 	}
@@ -265,10 +736,10 @@ func (s *QuizService) UpdateQuiz(ctx context.Context, quizID uint, req UpdateQui
 		updates["time_limit"] = *req.TimeLimit
 	}
 	if req.StartTime != nil {
-		updates["start_time"] = *req.StartTime
+		updates["start_time"] = req.StartTime.In(loc)
 	}
 	if req.EndTime != nil {
-		updates["end_time"] = *req.EndTime
+		updates["end_time"] = req.EndTime.In(loc)
 	}
 	if req.MaxAttempts != nil && *req.MaxAttempts >= 1 && *req.MaxAttempts <= 3 {
 		updates["max_attempts"] = *req.MaxAttempts
@@ -276,6 +747,12 @@ func (s *QuizService) UpdateQuiz(ctx context.Context, quizID uint, req UpdateQui
 	if req.ShowAnswerAfterEnd != nil {
 		updates["show_answer_after_end"] = *req.ShowAnswerAfterEnd
 	}
+	if req.LeaderboardEnabled != nil {
+		updates["leaderboard_enabled"] = *req.LeaderboardEnabled
+	}
+	if req.RequireAllAnswered != nil {
+		updates["require_all_answered"] = *req.RequireAllAnswered
+	}
 
 	if len(updates) > 0 {
 		if err := s.repo.Update(ctx, quiz, updates); err != nil {
@@ -287,164 +764,479 @@ func (s *QuizService) UpdateQuiz(ctx context.Context, quizID uint, req UpdateQui
 	if err != nil {
 		return nil, err
 	}
+	localizeQuiz(updated, loc)
 	return updated, nil
 }
 
-// DeleteQuiz removes a quiz and its related records.
-func (s *QuizService) DeleteQuiz(ctx context.Context, quizID uint) error {
-	if _, err := s.repo.FindByID(ctx, quizID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrQuizNotFound
-		}
-		return err
-	}
-	if err := s.repo.DeleteQuestionsByQuiz(ctx, quizID); err != nil {
-		return err
-	}
-	if err := s.repo.DeleteAttemptsByQuiz(ctx, quizID); err != nil {
+// DeleteQuiz soft-deletes a quiz. Its questions, attempts and grants are kept
+// so RestoreQuiz can bring it back; a deleted quiz cannot be viewed, started or
+// submitted. Only the course teacher or an admin may delete it.
+func (s *QuizService) DeleteQuiz(ctx context.Context, quizID uint, user UserInfo) error {
+	if _, err := s.findManagedQuiz(ctx, quizID, user); err != nil {
 		return err
 	}
 	return s.repo.DeleteByID(ctx, quizID)
 }
 
-// PublishQuiz publishes a quiz and calculates total points.
-func (s *QuizService) PublishQuiz(ctx context.Context, quizID uint) (*models.Quiz, error) {
-	quiz, err := s.repo.FindByID(ctx, quizID)
+// RestoreQuiz undoes DeleteQuiz. It returns ErrQuizNotFound unless the quiz
+// exists and is deleted. Only the course teacher or an admin may restore it.
+func (s *QuizService) RestoreQuiz(ctx context.Context, quizID uint, user UserInfo) (*models.Quiz, error) {
+	deleted, err := s.repo.FindDeletedByID(ctx, quizID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrQuizNotFound
 		}
 		return nil, err
 	}
-	totalPoints, err := s.repo.SumQuestionPoints(ctx, quizID)
-	if err != nil {
+	if err := s.checkCourseManager(ctx, deleted.CourseID, user); err != nil {
 		return nil, err
 	}
-	quiz.IsPublished = true
-	quiz.TotalPoints = totalPoints
-	if err := s.repo.Save(ctx, quiz); err != nil {
+	if err := s.repo.Restore(ctx, quizID); err != nil {
 		return nil, err
 	}
-	return quiz, nil
+	return s.repo.FindByID(ctx, quizID)
 }
 
-// UnpublishQuiz unpublishes a quiz when no attempts exist.
-func (s *QuizService) UnpublishQuiz(ctx context.Context, quizID uint) (*models.Quiz, error) {
-	quiz, err := s.repo.FindByID(ctx, quizID)
+// PublishQuiz publishes a quiz and calculates total points. normalizeTo sets
+// the total scores are reported out of (0 for raw points); nil keeps the
+// quiz's current setting, which cannot change once students have attempted.
+// Only the course teacher or an admin may publish it.
+func (s *QuizService) PublishQuiz(ctx context.Context, quizID uint, user UserInfo, normalizeTo *int) (*models.Quiz, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrQuizNotFound
+		return nil, err
+	}
+	if normalizeTo != nil && *normalizeTo != quiz.NormalizeTo {
+		// Attempts keep the score and max score they were graded with.
+		count, err := s.repo.CountAttempts(ctx, quizID)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrNormalizeToLocked
 		}
+	}
+	if err := setNormalizeTo(quiz, normalizeTo); err != nil {
 		return nil, err
 	}
-	count, err := s.repo.CountAttempts(ctx, quizID)
+	limits, err := s.quizLimits(ctx, quiz.CourseID)
 	if err != nil {
 		return nil, err
 	}
-	if count > 0 {
-		return nil, ErrUnpublishNotAllowed
+	totalPoints, err := checkPublishSize(ctx, s.repo, quiz, limits)
+	if err != nil {
+		return nil, err
 	}
-	quiz.IsPublished = false
+	quiz.IsPublished = true
+	applyPublishedPoints(quiz, totalPoints)
 	if err := s.repo.Save(ctx, quiz); err != nil {
 		return nil, err
 	}
 	return quiz, nil
 }
 
-// AddQuestion adds a new question to a quiz.
-func (s *QuizService) AddQuestion(ctx context.Context, quizID uint, req AddQuestionRequest) (*QuestionResponse, error) {
-	quiz, err := s.repo.FindByID(ctx, quizID)
+// checkPublishSize returns the quiz's total points, failing with a LimitError
+// when its questions are over limits.
+func checkPublishSize(ctx context.Context, repo *repositories.QuizRepository, quiz *models.Quiz, limits QuizLimits) (int, error) {
+	count, err := repo.CountQuestions(ctx, quiz.ID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrQuizNotFound
-		}
-		return nil, err
+		return 0, err
 	}
-	if quiz.IsPublished {
-		return nil, ErrQuizPublished
+	totalPoints, err := repo.SumQuestionPoints(ctx, quiz.ID)
+	if err != nil {
+		return 0, err
 	}
-
-	validTypes := map[string]bool{"single_choice": true, "multiple_choice": true, "true_false": true, "fill_blank": true}
-	if !validTypes[req.Type] {
-		return nil, ErrInvalidQuestionType
+	if err := checkQuizSize(count, totalPoints, limits); err != nil {
+		return 0, err
 	}
+	return totalPoints, nil
+}
 
-	optionsJSON := ""
-	if len(req.Options) > 0 {
-		if len(req.Options) > 10 {
-			return nil, ErrTooManyOptions
-		}
-		b, _ := json.Marshal(req.Options)
-		if len(b) > 10*1024 {
-			return nil, ErrOptionsTooLarge
-		}
-		optionsJSON = string(b)
+// createQuestions adds questions to a quiz, failing with a LimitError and
+// adding none of them when they would take it over limits. Every way of
+// adding questions goes through here, inside a transaction so the size
+// check and the inserts see the same questions.
+func createQuestions(ctx context.Context, tx *repositories.QuizRepository, quizID uint, questions []*models.Question, limits QuizLimits) error {
+	count, err := tx.CountQuestions(ctx, quizID)
+	if err != nil {
+		return err
 	}
-
-	points := req.Points
-	if points < 1 {
-		points = 1
+	totalPoints, err := tx.SumQuestionPoints(ctx, quizID)
+	if err != nil {
+		return err
 	}
-	matchRule := req.MatchRule
-	if matchRule == "" {
-		matchRule = "exact_trim"
+	for _, q := range questions {
+		totalPoints += q.Points
+	}
+	if err := checkQuizSize(count+int64(len(questions)), totalPoints, limits); err != nil {
+		return err
 	}
+	for _, q := range questions {
+		if err := tx.CreateQuestion(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	question := &models.Question{
-		QuizID:    quizID,
-		Type:      req.Type,
-		Content:   req.Content,
-		Options:   optionsJSON,
-		Answer:    req.Answer,
-		MatchRule: matchRule,
-		Points:    points,
-		OrderNum:  req.OrderNum,
-	}
-	if err := s.repo.CreateQuestion(ctx, question); err != nil {
-		return nil, err
-	}
-	return &QuestionResponse{
-		ID:        question.ID,
-		QuizID:    question.QuizID,
-		Type:      question.Type,
-		Content:   question.Content,
-		Options:   req.Options,
-		Answer:    question.Answer,
-		MatchRule: question.MatchRule,
-		Points:    question.Points,
-		OrderNum:  question.OrderNum,
-	}, nil
+// MaxBulkQuizzes caps how many quizzes one bulk publish or unpublish may name.
+const MaxBulkQuizzes = 100
+
+// BulkQuizResult is the outcome for one quiz of a bulk publish or unpublish.
+type BulkQuizResult struct {
+	QuizID  uint         `json:"quiz_id"`
+	Success bool         `json:"success"`
+	Error   string       `json:"error,omitempty"` // "attempts_exist" when unpublish is not allowed
+	Quiz    *models.Quiz `json:"quiz,omitempty"`
 }
 
-// UpdateQuestion updates an existing quiz question.
-func (s *QuizService) UpdateQuestion(ctx context.Context, questionID uint, req UpdateQuestionRequest) (*QuestionResponse, error) {
-	question, err := s.repo.FindQuestionByID(ctx, questionID)
+// PublishQuizzes publishes several quizzes of a course at once, calculating
+// each one's total points and score scale as PublishQuiz does. Every quiz must belong to the
+// course. The quizzes are published in one transaction, so either all of them
+// succeed or none is published. Only the course teacher or an admin may do so.
+func (s *QuizService) PublishQuizzes(ctx context.Context, courseID uint, user UserInfo, quizIDs []uint) ([]BulkQuizResult, error) {
+	quizzes, err := s.findCourseQuizzes(ctx, courseID, user, quizIDs)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrQuestionNotFound
-		}
 		return nil, err
 	}
-	quiz, err := s.repo.FindByID(ctx, question.QuizID)
+	limits, err := s.quizLimits(ctx, courseID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrQuizNotFound
+		return nil, err
+	}
+	results := make([]BulkQuizResult, len(quizzes))
+	err = s.repo.Transaction(ctx, func(tx *repositories.QuizRepository) error {
+		for i := range quizzes {
+			quiz := &quizzes[i]
+			totalPoints, err := checkPublishSize(ctx, tx, quiz, limits)
+			if err != nil {
+				var limitErr *LimitError
+				if errors.As(err, &limitErr) {
+					limitErr.QuizID = quiz.ID
+				}
+				return err
+			}
+			quiz.IsPublished = true
+			applyPublishedPoints(quiz, totalPoints)
+			if err := tx.Save(ctx, quiz); err != nil {
+				return err
+			}
+			results[i] = BulkQuizResult{QuizID: quiz.ID, Success: true, Quiz: quiz}
 		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	if quiz.IsPublished {
-		return nil, ErrQuizPublished
+	return results, nil
+}
+
+// UnpublishQuizzes unpublishes several quizzes of a course at once. As with
+// UnpublishQuiz, a quiz with attempts stays published and is reported as
+// failed; the others are unpublished together in one transaction.
+func (s *QuizService) UnpublishQuizzes(ctx context.Context, courseID uint, user UserInfo, quizIDs []uint) ([]BulkQuizResult, error) {
+	quizzes, err := s.findCourseQuizzes(ctx, courseID, user, quizIDs)
+	if err != nil {
+		return nil, err
 	}
-	if req.Content != nil {
-		question.Content = *req.Content
+	results := make([]BulkQuizResult, len(quizzes))
+	err = s.repo.Transaction(ctx, func(tx *repositories.QuizRepository) error {
+		for i := range quizzes {
+			quiz := &quizzes[i]
+			count, err := tx.CountAttempts(ctx, quiz.ID)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				results[i] = BulkQuizResult{QuizID: quiz.ID, Error: "attempts_exist"}
+				continue
+			}
+			quiz.IsPublished = false
+			if err := tx.Save(ctx, quiz); err != nil {
+				return err
+			}
+			results[i] = BulkQuizResult{QuizID: quiz.ID, Success: true, Quiz: quiz}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// findCourseQuizzes checks the user manages the course and loads the named
+// quizzes in request order, without duplicates. It fails with a
+// QuizNotInCourseError when any of them is missing or in another course.
+func (s *QuizService) findCourseQuizzes(ctx context.Context, courseID uint, user UserInfo, quizIDs []uint) ([]models.Quiz, error) {
+	if err := s.checkCourseManager(ctx, courseID, user); err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(quizIDs))
+	seen := make(map[uint]bool, len(quizIDs))
+	for _, id := range quizIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	found, err := s.repo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Quiz, len(found))
+	for _, q := range found {
+		if q.CourseID == courseID {
+			byID[q.ID] = q
+		}
+	}
+
+	quizzes := make([]models.Quiz, 0, len(ids))
+	var missing []uint
+	for _, id := range ids {
+		quiz, ok := byID[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		quizzes = append(quizzes, quiz)
+	}
+	if len(missing) > 0 {
+		return nil, &QuizNotInCourseError{QuizIDs: missing}
+	}
+	return quizzes, nil
+}
+
+// checkCourseManager returns ErrAccessDenied unless the user is an admin or
+// the course teacher.
+func (s *QuizService) checkCourseManager(ctx context.Context, courseID uint, user UserInfo) error {
+	course, err := s.repo.FindCourse(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCourseNotFound
+		}
+		return err
+	}
+	if user.Role != "admin" && !(user.Role == "teacher" && course.TeacherID == user.ID) {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// CloseQuiz ends a published quiz now by setting EndTime, so no new attempts
+// can start. In-progress attempts keep their deadline but no later than
+// CloseGracePeriod from now, giving students a short window to submit.
+// A quiz that has not started yet cannot be closed. Only the course teacher
+// or an admin may close a quiz.
+func (s *QuizService) CloseQuiz(ctx context.Context, quizID uint, user UserInfo) (*models.Quiz, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	if !quiz.IsPublished {
+		return nil, ErrQuizNotAvailable
+	}
+	now := time.Now()
+	if quiz.StartTime != nil && quiz.StartTime.After(now) {
+		return nil, ErrQuizNotStarted
+	}
+	if quiz.EndTime != nil && !quiz.EndTime.After(now) {
+		return nil, ErrQuizEnded
+	}
+
+	if err := s.repo.Update(ctx, quiz, map[string]interface{}{"end_time": now}); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CapInProgressAttemptDeadlines(ctx, quizID, now.Add(CloseGracePeriod)); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, quizID)
+}
+
+// MoveQuizToChapter attaches the quiz to a chapter of its course, or detaches
+// it when chapterID is nil. Only the course teacher or an admin may move it.
+func (s *QuizService) MoveQuizToChapter(ctx context.Context, quizID uint, user UserInfo, chapterID *uint) (*models.Quiz, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	if chapterID != nil {
+		if err := validateContentChapter(ctx, s.repo.FindChapter, quiz.CourseID, *chapterID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.Update(ctx, quiz, map[string]interface{}{"chapter_id": chapterID}); err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.FindByID(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := s.courseLocation(ctx, updated.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	localizeQuiz(updated, loc)
+	return updated, nil
+}
+
+// UnpublishQuiz unpublishes a quiz when no attempts exist.
+func (s *QuizService) UnpublishQuiz(ctx context.Context, quizID uint) (*models.Quiz, error) {
+	quiz, err := s.repo.FindByID(ctx, quizID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuizNotFound
+		}
+		return nil, err
+	}
+	count, err := s.repo.CountAttempts(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUnpublishNotAllowed
+	}
+	quiz.IsPublished = false
+	if err := s.repo.Save(ctx, quiz); err != nil {
+		return nil, err
+	}
+	return quiz, nil
+}
+
+// AddQuestion adds a new question to a quiz.
+func (s *QuizService) AddQuestion(ctx context.Context, quizID uint, req AddQuestionRequest) (*QuestionResponse, error) {
+	quiz, err := s.repo.FindByID(ctx, quizID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuizNotFound
+		}
+		return nil, err
+	}
+	if quiz.IsPublished {
+		return nil, ErrQuizPublished
+	}
+	limits, err := s.quizLimits(ctx, quiz.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	question, err := buildQuestion(quizID, req, limits)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.Transaction(ctx, func(tx *repositories.QuizRepository) error {
+		return createQuestions(ctx, tx, quizID, []*models.Question{question}, limits)
+	})
+	if err != nil {
+		return nil, err
+	}
+	response := toQuestionResponse(*question)
+	return &response, nil
+}
+
+// buildQuestion validates a new question for quizID and fills in defaults,
+// without saving it.
+func buildQuestion(quizID uint, req AddQuestionRequest, limits QuizLimits) (*models.Question, error) {
+	validTypes := map[string]bool{"single_choice": true, "multiple_choice": true, "true_false": true, "fill_blank": true, "ordering": true, "matching": true}
+	if !validTypes[req.Type] {
+		return nil, ErrInvalidQuestionType
+	}
+	optionsJSON, err := encodeOptions(req.Type, req.Options, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateImageURL(req.ImageURL); err != nil {
+		return nil, err
+	}
+	contentFormat := req.ContentFormat
+	if contentFormat == "" {
+		contentFormat = "plain"
+	}
+	if err := validateQuestionContent(req.Content, contentFormat); err != nil {
+		return nil, err
+	}
+	if len(req.Answer) > maxQuestionAnswerBytes {
+		return nil, ErrQuestionAnswerTooLong
+	}
+	if err := validateStructuredAnswer(req.Type, req.Options, req.Answer); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	points := req.Points
+	if points < 1 {
+		points = 1
+	}
+	matchRule := req.MatchRule
+	if matchRule == "" {
+		matchRule = "exact_trim"
+	}
+
+	return &models.Question{
+		QuizID:        quizID,
+		Type:          req.Type,
+		Content:       req.Content,
+		Options:       optionsJSON,
+		Answer:        req.Answer,
+		MatchRule:     matchRule,
+		Points:        points,
+		OrderNum:      req.OrderNum,
+		ImageURL:      req.ImageURL,
+		ContentFormat: contentFormat,
+		Tags:          encodeTags(tags),
+	}, nil
+}
+
+// UpdateQuestion updates an existing quiz question.
+func (s *QuizService) UpdateQuestion(ctx context.Context, questionID uint, req UpdateQuestionRequest) (*QuestionResponse, error) {
+	question, err := s.repo.FindQuestionByID(ctx, questionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuestionNotFound
+		}
+		return nil, err
+	}
+	quiz, err := s.repo.FindByID(ctx, question.QuizID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuizNotFound
+		}
+		return nil, err
+	}
+	if quiz.IsPublished {
+		return nil, ErrQuizPublished
+	}
+	if req.Content != nil {
+		question.Content = *req.Content
 	}
 	if req.Options != nil {
-		b, _ := json.Marshal(req.Options)
-		question.Options = string(b)
+		limits, err := s.quizLimits(ctx, quiz.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		optionsJSON, err := encodeOptions(question.Type, req.Options, limits)
+		if err != nil {
+			return nil, err
+		}
+		question.Options = optionsJSON
 	}
 	if req.Answer != nil {
+		if len(*req.Answer) > maxQuestionAnswerBytes {
+			return nil, ErrQuestionAnswerTooLong
+		}
 		question.Answer = *req.Answer
 	}
+	if req.Options != nil || req.Answer != nil {
+		var options []string
+		if question.Options != "" {
+			_ = json.Unmarshal([]byte(question.Options), &options)
+		}
+		if err := validateStructuredAnswer(question.Type, options, question.Answer); err != nil {
+			return nil, err
+		}
+	}
 	if req.MatchRule != nil {
 		question.MatchRule = *req.MatchRule
 	}
@@ -454,22 +1246,34 @@ func (s *QuizService) UpdateQuestion(ctx context.Context, questionID uint, req U
 	if req.OrderNum != nil {
 		question.OrderNum = *req.OrderNum
 	}
+	if req.ImageURL != nil {
+		if err := validateImageURL(*req.ImageURL); err != nil {
+			return nil, err
+		}
+		question.ImageURL = *req.ImageURL
+	}
+	if req.ContentFormat != nil {
+		question.ContentFormat = *req.ContentFormat
+	}
+	if req.Content != nil || req.ContentFormat != nil {
+		if err := validateQuestionContent(question.Content, question.ContentFormat); err != nil {
+			return nil, err
+		}
+	}
+	if req.Tags != nil {
+		tags, err := normalizeTags(req.Tags)
+		if err != nil {
+			return nil, err
+		}
+		question.Tags = encodeTags(tags)
+	}
 
 	if err := s.repo.SaveQuestion(ctx, question); err != nil {
 		return nil, err
 	}
 
-	return &QuestionResponse{
-		ID:        question.ID,
-		QuizID:    question.QuizID,
-		Type:      question.Type,
-		Content:   question.Content,
-		Options:   question.Options,
-		Answer:    question.Answer,
-		MatchRule: question.MatchRule,
-		Points:    question.Points,
-		OrderNum:  question.OrderNum,
-	}, nil
+	response := toQuestionResponse(*question)
+	return &response, nil
 }
 
 // DeleteQuestion removes a question from an unpublished quiz.
@@ -496,11 +1300,8 @@ func (s *QuizService) DeleteQuestion(ctx context.Context, questionID uint) error
 
 // StartQuiz starts or resumes a quiz attempt for a student.
 func (s *QuizService) StartQuiz(ctx context.Context, quizID uint, user UserInfo) (*StartQuizResult, error) {
-	quiz, err := s.repo.FindByID(ctx, quizID)
+	quiz, err := s.findAccessibleQuiz(ctx, quizID, user)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrQuizNotFound
-		}
 		return nil, err
 	}
 	if !quiz.IsPublished {
@@ -515,14 +1316,8 @@ func (s *QuizService) StartQuiz(ctx context.Context, quizID uint, user UserInfo)
 		return nil, ErrQuizEnded
 	}
 
-	attemptCount, err := s.repo.CountAttemptsByQuizAndStudent(ctx, quizID, user.ID)
-	if err != nil {
-		return nil, err
-	}
-	if int(attemptCount) >= quiz.MaxAttempts {
-		return nil, ErrMaxAttemptsReached
-	}
-
+	// An in-progress attempt, including one a teacher reopened, is resumed
+	// even when it was the student's last.
 	if existingAttempt, err := s.repo.FindInProgressAttempt(ctx, quizID, user.ID); err == nil {
 		questions, err := s.repo.ListQuestions(ctx, quizID)
 		if err != nil {
@@ -537,12 +1332,16 @@ func (s *QuizService) StartQuiz(ctx context.Context, quizID uint, user UserInfo)
 		return nil, err
 	}
 
-	deadline := now.Add(24 * time.Hour)
-	if quiz.TimeLimit > 0 {
-		deadline = now.Add(time.Duration(quiz.TimeLimit) * time.Minute)
+	attemptCount, err := s.repo.CountAttemptsByQuizAndStudent(ctx, quizID, user.ID)
+	if err != nil {
+		return nil, err
 	}
-	if quiz.EndTime != nil && quiz.EndTime.Before(deadline) {
-		deadline = *quiz.EndTime
+	extraAttempts, err := s.repo.CountAttemptGrants(ctx, quizID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if attemptCount >= int64(quiz.MaxAttempts)+extraAttempts {
+		return nil, ErrMaxAttemptsReached
 	}
 
 	attempt := &models.QuizAttempt{
@@ -550,8 +1349,8 @@ func (s *QuizService) StartQuiz(ctx context.Context, quizID uint, user UserInfo)
 		StudentID:     user.ID,
 		AttemptNumber: int(attemptCount) + 1,
 		StartedAt:     now,
-		Deadline:      deadline,
-		MaxScore:      quiz.TotalPoints,
+		Deadline:      attemptDeadline(quiz, now),
+		MaxScore:      quizMaxScore(quiz),
 	}
 
 	if err := s.repo.CreateAttempt(ctx, attempt); err != nil {
@@ -570,8 +1369,352 @@ func (s *QuizService) StartQuiz(ctx context.Context, quizID uint, user UserInfo)
 	}, nil
 }
 
+// attemptDeadline is when an attempt started at now must be submitted: the
+// quiz time limit, or a day without one, and never after the quiz ends.
+func attemptDeadline(quiz *models.Quiz, now time.Time) time.Time {
+	deadline := now.Add(24 * time.Hour)
+	if quiz.TimeLimit > 0 {
+		deadline = now.Add(time.Duration(quiz.TimeLimit) * time.Minute)
+	}
+	if quiz.EndTime != nil && quiz.EndTime.Before(deadline) {
+		deadline = *quiz.EndTime
+	}
+	return deadline
+}
+
+// ReopenAttempt returns a submitted attempt to progress after a technical
+// failure, so the student can resume it with StartQuiz and submit again. The
+// score is cleared, the saved answers are kept, and the attempt gets a fresh
+// deadline as if started now, capped at the quiz end. Only the course teacher
+// or an admin may reopen; the reason is logged.
+func (s *QuizService) ReopenAttempt(ctx context.Context, attemptID uint, user UserInfo, reason string) (*models.QuizAttempt, error) {
+	attempt, err := s.repo.FindAttemptByID(ctx, attemptID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttemptNotFound
+		}
+		return nil, err
+	}
+	quiz, err := s.findManagedQuiz(ctx, attempt.QuizID, user)
+	if err != nil {
+		return nil, err
+	}
+	if attempt.SubmittedAt == nil {
+		return nil, ErrAttemptNotSubmitted
+	}
+	now := time.Now()
+	if quiz.EndTime != nil && !quiz.EndTime.After(now) {
+		return nil, ErrQuizEnded
+	}
+	if _, err := s.repo.FindInProgressAttempt(ctx, quiz.ID, attempt.StudentID); err == nil {
+		return nil, ErrAttemptInProgress
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	previousScore := 0
+	if attempt.Score != nil {
+		previousScore = *attempt.Score
+	}
+	err = s.repo.Transaction(ctx, func(tx *repositories.QuizRepository) error {
+		reopened, err := tx.ReopenAttempt(ctx, attempt.ID, attemptDeadline(quiz, now))
+		if err != nil {
+			return err
+		}
+		if !reopened {
+			return ErrAttemptNotSubmitted
+		}
+		return RecordAudit(ctx, tx.Audit(), AuditEntry{
+			Actor:      user,
+			Action:     AuditAttemptReopen,
+			TargetType: "quiz_attempt",
+			TargetID:   attempt.ID,
+			Details: map[string]interface{}{
+				"quiz_id":        quiz.ID,
+				"student_id":     attempt.StudentID,
+				"previous_score": previousScore,
+				"reason":         reason,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Info("quiz attempt reopened",
+		slog.Uint64("attempt_id", uint64(attempt.ID)),
+		slog.Uint64("quiz_id", uint64(quiz.ID)),
+		slog.Uint64("student_id", uint64(attempt.StudentID)),
+		slog.Uint64("reopened_by", uint64(user.ID)),
+		slog.Int("previous_score", previousScore),
+		slog.String("reason", reason),
+	)
+	return s.repo.FindAttemptByID(ctx, attempt.ID)
+}
+
+// AttemptGrantResult reports a student's attempt allowance after a grant.
+type AttemptGrantResult struct {
+	QuizID        uint  `json:"quiz_id"`
+	StudentID     uint  `json:"student_id"`
+	ExtraAttempts int64 `json:"extra_attempts"`
+	MaxAttempts   int64 `json:"max_attempts"` // quiz MaxAttempts plus extra attempts
+	AttemptsUsed  int64 `json:"attempts_used"`
+}
+
+// GrantExtraAttempt gives one student one more attempt on a quiz. Only the
+// course teacher or an admin may grant; the reason is stored with the grant.
+func (s *QuizService) GrantExtraAttempt(ctx context.Context, quizID, studentID uint, user UserInfo, reason string) (*AttemptGrantResult, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	enrolled, err := s.repo.HasEnrollment(ctx, quiz.CourseID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if !enrolled {
+		return nil, ErrStudentNotEnrolled
+	}
+
+	grant := &models.QuizAttemptGrant{
+		QuizID:      quizID,
+		StudentID:   studentID,
+		GrantedByID: user.ID,
+		Reason:      reason,
+	}
+	if err := s.repo.CreateAttemptGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("quiz attempt granted",
+		slog.Uint64("quiz_id", uint64(quizID)),
+		slog.Uint64("student_id", uint64(studentID)),
+		slog.Uint64("granted_by", uint64(user.ID)),
+		slog.String("reason", reason),
+	)
+
+	extra, err := s.repo.CountAttemptGrants(ctx, quizID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.repo.CountAttemptsByQuizAndStudent(ctx, quizID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return &AttemptGrantResult{
+		QuizID:        quizID,
+		StudentID:     studentID,
+		ExtraAttempts: extra,
+		MaxAttempts:   int64(quiz.MaxAttempts) + extra,
+		AttemptsUsed:  used,
+	}, nil
+}
+
+// PreviewGrade scores sample answers against the quiz's current questions so a
+// teacher can check match rules before publishing. Nothing is persisted, and
+// unpublished quizzes are allowed. Only the course teacher or an admin may preview.
+func (s *QuizService) PreviewGrade(ctx context.Context, quizID uint, user UserInfo, answers map[string]interface{}) (*GradePreview, error) {
+	if _, err := s.findManagedQuiz(ctx, quizID, user); err != nil {
+		return nil, err
+	}
+
+	questions, err := s.repo.ListQuestions(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &GradePreview{QuizID: quizID, Questions: make([]QuestionReview, len(questions))}
+	for i, q := range questions {
+		review := QuestionReview{QuestionWithAnswer: QuestionWithAnswer{Question: q, Answer: q.Answer}}
+		if ans, ok := answers[strconv.FormatUint(uint64(q.ID), 10)]; ok {
+			review.StudentAnswer = ans
+			review.EarnedPoints = grading.Score(q, ans)
+			review.Correct = review.EarnedPoints >= q.Points
+		}
+		preview.Score += review.EarnedPoints
+		preview.MaxScore += q.Points
+		preview.Questions[i] = review
+	}
+	return preview, nil
+}
+
+// RegradeQuiz re-scores every submitted attempt against the quiz's current
+// answers. Questions are locked once a quiz is published, so corrections are
+// the one controlled way to fix a wrong answer afterwards: only the answer of
+// the listed questions changes, and it is applied in the same transaction as
+// the new scores. Only the course teacher or an admin may regrade.
+func (s *QuizService) RegradeQuiz(ctx context.Context, quizID uint, user UserInfo, corrections []AnswerCorrection) (*RegradeResult, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RegradeResult{QuizID: quizID}
+	err = s.repo.Transaction(ctx, func(tx *repositories.QuizRepository) error {
+		questions, err := tx.ListQuestions(ctx, quizID)
+		if err != nil {
+			return err
+		}
+		byID := make(map[uint]int, len(questions))
+		for i, q := range questions {
+			byID[q.ID] = i
+		}
+
+		for _, c := range corrections {
+			i, ok := byID[c.QuestionID]
+			if !ok {
+				return ErrQuestionNotFound
+			}
+			q := &questions[i]
+			if len(c.Answer) > maxQuestionAnswerBytes {
+				return ErrQuestionAnswerTooLong
+			}
+			var options []string
+			if q.Options != "" {
+				_ = json.Unmarshal([]byte(q.Options), &options)
+			}
+			if err := validateStructuredAnswer(q.Type, options, c.Answer); err != nil {
+				return err
+			}
+			if c.Answer == q.Answer {
+				continue
+			}
+			if err := tx.UpdateQuestionAnswer(ctx, q.ID, c.Answer); err != nil {
+				return err
+			}
+			q.Answer = c.Answer
+			result.QuestionsCorrected++
+		}
+
+		attempts, err := tx.ListSubmittedAttemptsByQuiz(ctx, quizID)
+		if err != nil {
+			return err
+		}
+		for _, attempt := range attempts {
+			score := 0
+			for _, review := range reviewAttempt(attempt, questions) {
+				score += review.EarnedPoints
+			}
+			score = scaledScore(quiz, score)
+			result.AttemptsChecked++
+			if attempt.Score != nil && *attempt.Score == score {
+				continue
+			}
+			if err := tx.UpdateAttemptScore(ctx, attempt.ID, score); err != nil {
+				return err
+			}
+			result.AttemptsChanged++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetQuizResults summarizes each student's submitted attempts on a quiz for
+// export. Every enrolled student gets a row, and so does anyone who submitted
+// but has since left the course. Time spent is the sum of each submitted
+// attempt's start-to-submit time. Only the course teacher or an admin may export.
+func (s *QuizService) GetQuizResults(ctx context.Context, quizID uint, user UserInfo) (*QuizResults, error) {
+	quiz, err := s.findManagedQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	maxScore, err := s.repo.SumQuestionPoints(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+	studentIDs, err := s.repo.ListStudentIDsByCourse(ctx, quiz.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	attempts, err := s.repo.ListSubmittedAttemptsByQuiz(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[uint]*QuizResultRow, len(studentIDs))
+	order := make([]uint, 0, len(studentIDs))
+	rowFor := func(studentID uint) *QuizResultRow {
+		row, ok := rows[studentID]
+		if !ok {
+			row = &QuizResultRow{StudentID: studentID}
+			rows[studentID] = row
+			order = append(order, studentID)
+		}
+		return row
+	}
+	for _, id := range studentIDs {
+		rowFor(id)
+	}
+
+	latestNumber := make(map[uint]int)
+	for _, a := range attempts {
+		row := rowFor(a.StudentID)
+		row.Attempts++
+		if spent := a.SubmittedAt.Sub(a.StartedAt); spent > 0 {
+			row.TimeSpentSeconds += int(spent / time.Second)
+		}
+		if row.LastSubmittedAt == nil || a.SubmittedAt.After(*row.LastSubmittedAt) {
+			row.LastSubmittedAt = a.SubmittedAt
+		}
+		if a.Score == nil {
+			continue
+		}
+		if row.BestScore == nil || *a.Score > *row.BestScore {
+			row.BestScore = a.Score
+		}
+		if a.AttemptNumber >= latestNumber[a.StudentID] {
+			latestNumber[a.StudentID] = a.AttemptNumber
+			row.LatestScore = a.Score
+		}
+	}
+
+	users, err := s.repo.FindUsersByIDs(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	result := &QuizResults{Quiz: *quiz, MaxScore: scaledScore(quiz, maxScore), Students: make([]QuizResultRow, len(order))}
+	for i, id := range order {
+		row := rows[id]
+		row.Username = users[id].Username
+		row.Name = users[id].Name
+		result.Students[i] = *row
+	}
+	return result, nil
+}
+
+// GetAttemptRemaining returns the remaining time on the user's in-progress attempt.
+func (s *QuizService) GetAttemptRemaining(ctx context.Context, quizID uint, user UserInfo) (*AttemptRemaining, error) {
+	if _, err := s.findAccessibleQuiz(ctx, quizID, user); err != nil {
+		return nil, err
+	}
+	attempt, err := s.repo.FindInProgressAttempt(ctx, quizID, user.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoActiveAttempt
+		}
+		return nil, err
+	}
+	now := time.Now()
+	remaining := int(attempt.Deadline.Sub(now) / time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &AttemptRemaining{
+		AttemptID:        attempt.ID,
+		Deadline:         attempt.Deadline,
+		ServerTime:       now,
+		RemainingSeconds: remaining,
+	}, nil
+}
+
 // SubmitQuiz submits the current attempt answers for scoring.
 func (s *QuizService) SubmitQuiz(ctx context.Context, quizID uint, user UserInfo, req SubmitQuizRequest) (*SubmitQuizResult, error) {
+	quiz, err := s.findAccessibleQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
 	attempt, err := s.repo.FindInProgressAttempt(ctx, quizID, user.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -581,13 +1724,17 @@ func (s *QuizService) SubmitQuiz(ctx context.Context, quizID uint, user UserInfo
 	}
 
 	now := time.Now()
-	if now.After(attempt.Deadline) {
+	if now.After(attempt.Deadline.Add(SubmissionGracePeriod)) {
 		return nil, ErrSubmissionDeadline
 	}
 
+	limits, err := s.quizLimits(ctx, quiz.CourseID)
+	if err != nil {
+		return nil, err
+	}
 	answersJSON, _ := json.Marshal(req.Answers)
-	if len(answersJSON) > 100*1024 {
-		return nil, ErrAnswersTooLarge
+	if len(answersJSON) > limits.MaxAnswersBytes {
+		return nil, &LimitError{Err: ErrAnswersTooLarge, Limit: limits.MaxAnswersBytes}
 	}
 
 	questions, err := s.repo.ListQuestions(ctx, quizID)
@@ -595,6 +1742,14 @@ func (s *QuizService) SubmitQuiz(ctx context.Context, quizID uint, user UserInfo
 		return nil, err
 	}
 
+	// Past the deadline (within the grace period) partial answers are
+	// accepted so an auto-submit never loses work.
+	if quiz.RequireAllAnswered && !now.After(attempt.Deadline) {
+		if missing := unansweredQuestionNumbers(questions, req.Answers); len(missing) > 0 {
+			return nil, &UnansweredError{QuestionNumbers: missing}
+		}
+	}
+
 	snapshotJSON, _ := json.Marshal(questions)
 
 	score := 0
@@ -604,17 +1759,37 @@ func (s *QuizService) SubmitQuiz(ctx context.Context, quizID uint, user UserInfo
 		if !ok {
 			continue
 		}
-		score += gradeQuestion(q, studentAnswer)
+		score += grading.Score(q, studentAnswer)
 	}
+	score = scaledScore(quiz, score)
 
 	attempt.Answers = string(answersJSON)
 	attempt.AnswerSnapshot = string(snapshotJSON)
 	attempt.SubmittedAt = &now
 	attempt.Score = &score
+	attempt.SubmitIP = req.ClientIP
+	attempt.SubmitUserAgent = truncateRunes(req.UserAgent, maxUserAgentRunes)
 
-	if err := s.repo.SaveAttempt(ctx, attempt); err != nil {
+	// Only the first of two racing submits may write the attempt.
+	submitted, err := s.repo.SubmitAttempt(ctx, attempt)
+	if err != nil {
 		return nil, err
 	}
+	if !submitted {
+		first, err := s.repo.FindAttemptByID(ctx, attempt.ID)
+		if err != nil {
+			return nil, err
+		}
+		firstScore := 0
+		if first.Score != nil {
+			firstScore = *first.Score
+		}
+		return nil, &AlreadySubmittedError{Result: SubmitQuizResult{
+			Attempt:  *first,
+			Score:    firstScore,
+			MaxScore: first.MaxScore,
+		}}
+	}
 
 	return &SubmitQuizResult{
 		Attempt:  *attempt,
@@ -623,146 +1798,458 @@ func (s *QuizService) SubmitQuiz(ctx context.Context, quizID uint, user UserInfo
 	}, nil
 }
 
-// GetQuizResult returns attempts and optional answers based on role and timing.
-func (s *QuizService) GetQuizResult(ctx context.Context, quizID uint, user UserInfo) (*QuizResult, error) {
-	quiz, err := s.repo.FindByID(ctx, quizID)
-	if err != nil {
+// maxUserAgentRunes matches the size of models.QuizAttempt.SubmitUserAgent.
+const maxUserAgentRunes = 512
+
+// truncateRunes cuts s to at most max runes.
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+// unansweredQuestionNumbers returns the 1-based positions of questions whose
+// answer is missing, null, blank or an empty selection.
+func unansweredQuestionNumbers(questions []models.Question, answers map[string]interface{}) []int {
+	var missing []int
+	for i, q := range questions {
+		answer, ok := answers[strconv.FormatUint(uint64(q.ID), 10)]
+		blank := !ok || answer == nil
+		switch v := answer.(type) {
+		case string:
+			blank = strings.TrimSpace(v) == ""
+		case []interface{}:
+			blank = len(v) == 0
+		}
+		if blank {
+			missing = append(missing, i+1)
+		}
+	}
+	return missing
+}
+
+// ListStudentAttempts returns a student's submitted attempts across every quiz
+// in a course, newest first. Staff with course access may view any student;
+// students only themselves.
+func (s *QuizService) ListStudentAttempts(ctx context.Context, courseID, studentID uint, user UserInfo) ([]StudentQuizAttempt, error) {
+	if _, err := s.repo.FindCourse(ctx, courseID); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrQuizNotFound
+			return nil, ErrCourseNotFound
 		}
 		return nil, err
 	}
+	if user.ID != studentID && !user.IsTeacher() {
+		return nil, ErrAccessDenied
+	}
+	if err := s.checkCourseAccess(ctx, courseID, user); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.ListSubmittedAttemptsByCourseAndStudent(ctx, courseID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]StudentQuizAttempt, len(rows))
+	for i, row := range rows {
+		attempts[i] = StudentQuizAttempt{
+			AttemptID:     row.AttemptID,
+			QuizID:        row.QuizID,
+			QuizTitle:     row.QuizTitle,
+			AttemptNumber: row.AttemptNumber,
+			Score:         row.Score,
+			MaxScore:      row.MaxScore,
+			SubmittedAt:   row.SubmittedAt,
+		}
+	}
+	return attempts, nil
+}
 
+// GetQuizResult returns attempts and optional answers based on role and timing.
+// Staff see every attempt and where it was submitted from, and must manage the
+// quiz's course. Students must belong to the course.
+func (s *QuizService) GetQuizResult(ctx context.Context, quizID uint, user UserInfo) (*QuizResult, error) {
 	if user.IsTeacher() {
+		quiz, err := s.findManagedQuiz(ctx, quizID, user)
+		if err != nil {
+			return nil, err
+		}
 		attempts, err := s.repo.ListAttemptsByQuiz(ctx, quizID, "score DESC")
 		if err != nil {
 			return nil, err
 		}
+		origins := make([]AttemptOrigin, 0, len(attempts))
+		for _, a := range attempts {
+			if a.SubmittedAt != nil {
+				origins = append(origins, AttemptOrigin{AttemptID: a.ID, StudentID: a.StudentID, IP: a.SubmitIP, UserAgent: a.SubmitUserAgent})
+			}
+		}
 		return &QuizResult{
 			Quiz:     *quiz,
 			Attempts: attempts,
+			Origins:  origins,
 		}, nil
 	}
 
+	quiz, err := s.findAccessibleQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
 	attempts, err := s.repo.ListAttemptsByQuizAndStudentOrder(ctx, quizID, user.ID, "attempt_number DESC")
 	if err != nil {
 		return nil, err
 	}
+	questions, err := s.repo.ListQuestions(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+	result := &QuizResult{
+		Quiz:      *quiz,
+		Attempts:  attempts,
+		Snapshots: attemptSnapshots(attempts, questions),
+	}
 
+	// Answers stay hidden while anyone can still submit, such as attempts
+	// CloseQuiz left CloseGracePeriod to finish.
 	showAnswers := false
-	if quiz.ShowAnswerAfterEnd && quiz.EndTime != nil && time.Now().After(*quiz.EndTime) {
-		showAnswers = true
+	if now := time.Now(); quiz.ShowAnswerAfterEnd && quiz.EndTime != nil && now.After(*quiz.EndTime) {
+		open, err := s.repo.HasOpenAttempts(ctx, quizID, now.Add(-SubmissionGracePeriod))
+		if err != nil {
+			return nil, err
+		}
+		showAnswers = !open
 	}
 
 	if showAnswers {
-		questions, err := s.repo.ListQuestions(ctx, quizID)
-		if err != nil {
-			return nil, err
+		if review := pickReviewAttempt(attempts); review != nil {
+			reviewID := review.ID
+			result.Questions = reviewAttempt(*review, questions)
+			result.ReviewAttemptID = &reviewID
+			return result, nil
 		}
 		withAnswers := make([]QuestionWithAnswer, len(questions))
 		for i, q := range questions {
 			withAnswers[i] = QuestionWithAnswer{Question: q, Answer: q.Answer}
 		}
-		return &QuizResult{
-			Quiz:      *quiz,
-			Attempts:  attempts,
-			Questions: withAnswers,
-		}, nil
+		result.Questions = withAnswers
 	}
 
-	return &QuizResult{
-		Quiz:     *quiz,
-		Attempts: attempts,
-	}, nil
+	return result, nil
 }
 
-func gradeQuestion(q models.Question, studentAnswer interface{}) int {
-	switch q.Type {
-	case "single_choice", "true_false":
-		ans, ok := studentAnswer.(string)
-		if !ok {
-			return 0
+// attemptSnapshots pairs each submitted attempt's questions, taken from its
+// snapshot so later edits to the quiz do not change what the student sees,
+// with the student's answers. Attempts submitted before snapshots were stored
+// fall back to the current questions.
+func attemptSnapshots(attempts []models.QuizAttempt, current []models.Question) []AttemptSnapshot {
+	snapshots := make([]AttemptSnapshot, 0, len(attempts))
+	for _, attempt := range attempts {
+		if attempt.SubmittedAt == nil {
+			continue
 		}
-		if ans == q.Answer {
-			return q.Points
+		questions := current
+		if attempt.AnswerSnapshot != "" {
+			var snapshot []models.Question
+			if err := json.Unmarshal([]byte(attempt.AnswerSnapshot), &snapshot); err == nil && len(snapshot) > 0 {
+				questions = snapshot
+			}
+		}
+		var studentAnswers map[string]interface{}
+		if attempt.Answers != "" {
+			_ = json.Unmarshal([]byte(attempt.Answers), &studentAnswers)
 		}
 
-	case "multiple_choice":
-		var studentAns []string
-		switch v := studentAnswer.(type) {
-		case []interface{}:
-			for _, item := range v {
-				if s, ok := item.(string); ok {
-					studentAns = append(studentAns, s)
-				}
-			}
-		case string:
-			if err := json.Unmarshal([]byte(v), &studentAns); err != nil {
-				return 0
+		entry := AttemptSnapshot{
+			AttemptID:     attempt.ID,
+			AttemptNumber: attempt.AttemptNumber,
+			Questions:     make([]SnapshotQuestion, len(questions)),
+		}
+		for i, q := range questions {
+			q.Answer = ""
+			entry.Questions[i] = SnapshotQuestion{
+				Question:      q,
+				StudentAnswer: studentAnswers[strconv.FormatUint(uint64(q.ID), 10)],
 			}
 		}
+		snapshots = append(snapshots, entry)
+	}
+	return snapshots
+}
 
-		var correctAns []string
-		if err := json.Unmarshal([]byte(q.Answer), &correctAns); err != nil {
-			return 0
+// GetLeaderboard returns the top students by best score. Staff always see
+// names; students only see an anonymized board when the quiz enables it.
+func (s *QuizService) GetLeaderboard(ctx context.Context, quizID uint, user UserInfo, limit int) (*Leaderboard, error) {
+	quiz, err := s.findAccessibleQuiz(ctx, quizID, user)
+	if err != nil {
+		return nil, err
+	}
+	anonymize := !user.IsTeacher()
+	if anonymize {
+		if !quiz.IsPublished {
+			return nil, ErrQuizNotAvailable
+		}
+		if !quiz.LeaderboardEnabled {
+			return nil, ErrLeaderboardDisabled
 		}
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	rows, err := s.repo.TopScoresByQuiz(ctx, quizID, limit)
+	if err != nil {
+		return nil, err
+	}
 
-		sort.Strings(studentAns)
-		sort.Strings(correctAns)
-		if equalStringSlices(studentAns, correctAns) {
-			return q.Points
+	entries := make([]LeaderboardEntry, len(rows))
+	for i, row := range rows {
+		rank := i + 1
+		if i > 0 && row.BestScore == rows[i-1].BestScore {
+			rank = entries[i-1].Rank
+		}
+		name := row.Name
+		if name == "" {
+			name = row.Username
+		}
+		entry := LeaderboardEntry{
+			Rank:        rank,
+			DisplayName: name,
+			BestScore:   row.BestScore,
+			IsMe:        row.StudentID == user.ID,
+		}
+		if anonymize {
+			entry.DisplayName = maskName(name)
+		} else {
+			entry.StudentID = row.StudentID
 		}
+		entries[i] = entry
+	}
 
-	case "fill_blank":
-		ans, ok := studentAnswer.(string)
-		if !ok {
-			return 0
+	return &Leaderboard{
+		QuizID:     quiz.ID,
+		MaxScore:   quizMaxScore(quiz),
+		Anonymized: anonymize,
+		Entries:    entries,
+	}, nil
+}
+
+// GetCourseQuizSummary returns aggregate quiz numbers for a course.
+// Only the course teacher and admins may view it.
+func (s *QuizService) GetCourseQuizSummary(ctx context.Context, courseID uint, user UserInfo) (*CourseQuizSummary, error) {
+	if err := s.checkCourseManager(ctx, courseID, user); err != nil {
+		return nil, err
+	}
+
+	var err error
+	summary := &CourseQuizSummary{CourseID: courseID}
+	if summary.TotalQuizzes, err = s.repo.CountByCourse(ctx, courseID, false); err != nil {
+		return nil, err
+	}
+	if summary.PublishedQuizzes, err = s.repo.CountByCourse(ctx, courseID, true); err != nil {
+		return nil, err
+	}
+	if summary.SubmittedAttempts, summary.AvgScoreRate, err = s.repo.SubmittedAttemptStatsByCourse(ctx, courseID); err != nil {
+		return nil, err
+	}
+	if summary.EnrolledStudents, err = s.repo.CountStudentsByCourse(ctx, courseID); err != nil {
+		return nil, err
+	}
+	if summary.ParticipatingStudents, err = s.repo.CountParticipatingStudentsByCourse(ctx, courseID); err != nil {
+		return nil, err
+	}
+	if summary.EnrolledStudents > 0 {
+		summary.ParticipationRate = float64(summary.ParticipatingStudents) / float64(summary.EnrolledStudents)
+	}
+	return summary, nil
+}
+
+// maskName keeps the first character and masks the rest, e.g. "张三丰" -> "张**".
+func maskName(name string) string {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return "*"
+	}
+	if len(runes) == 1 {
+		return string(runes) + "*"
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-1)
+}
+
+// pickReviewAttempt returns the best graded attempt, preferring the latest on ties.
+func pickReviewAttempt(attempts []models.QuizAttempt) *models.QuizAttempt {
+	var best *models.QuizAttempt
+	for i := range attempts {
+		a := &attempts[i]
+		if a.SubmittedAt == nil || a.Score == nil {
+			continue
 		}
-		if matchFillBlank(q.Answer, ans, q.MatchRule) {
-			return q.Points
+		if best == nil || *a.Score > *best.Score ||
+			(*a.Score == *best.Score && a.AttemptNumber > best.AttemptNumber) {
+			best = a
+		}
+	}
+	return best
+}
+
+// reviewAttempt re-grades an attempt question by question. The questions come
+// from the attempt's snapshot when available; the snapshot does not carry
+// answers (they are hidden from JSON), so answers are taken from the current
+// questions, which are locked while the quiz is published.
+func reviewAttempt(attempt models.QuizAttempt, current []models.Question) []QuestionReview {
+	answersByID := make(map[uint]string, len(current))
+	for _, q := range current {
+		answersByID[q.ID] = q.Answer
+	}
+
+	questions := current
+	if attempt.AnswerSnapshot != "" {
+		var snapshot []models.Question
+		if err := json.Unmarshal([]byte(attempt.AnswerSnapshot), &snapshot); err == nil && len(snapshot) > 0 {
+			questions = snapshot
 		}
 	}
 
-	return 0
+	var studentAnswers map[string]interface{}
+	if attempt.Answers != "" {
+		_ = json.Unmarshal([]byte(attempt.Answers), &studentAnswers)
+	}
+
+	reviews := make([]QuestionReview, len(questions))
+	for i, q := range questions {
+		q.Answer = answersByID[q.ID]
+		review := QuestionReview{QuestionWithAnswer: QuestionWithAnswer{Question: q, Answer: q.Answer}}
+		if ans, ok := studentAnswers[strconv.FormatUint(uint64(q.ID), 10)]; ok {
+			review.StudentAnswer = ans
+			review.EarnedPoints = grading.Score(q, ans)
+			review.Correct = review.EarnedPoints >= q.Points
+		}
+		reviews[i] = review
+	}
+	return reviews
 }
 
-func equalStringSlices(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
+// validateImageURL accepts an empty value or an absolute http(s) URL, which
+// covers both external links and MinIO signed URLs. Images are not hosted by
+// the platform; diagrams should be kept small (under ~2MB) for mobile clients.
+func validateImageURL(raw string) error {
+	if raw == "" {
+		return nil
 	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
+	if !isHTTPURL(raw) {
+		return ErrInvalidImageURL
+	}
+	return nil
+}
+
+// quizLimits returns the effective quiz limits for a course.
+func (s *QuizService) quizLimits(ctx context.Context, courseID uint) (QuizLimits, error) {
+	course, err := s.repo.FindCourse(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultQuizLimits, nil
 		}
+		return QuizLimits{}, err
 	}
-	return true
+	return quizLimitsFor(course), nil
 }
 
-func matchFillBlank(answer, studentAns, rule string) bool {
-	var answers []string
-	if err := json.Unmarshal([]byte(answer), &answers); err != nil {
-		answers = []string{answer}
+// encodeOptions validates question options against limits and returns them as
+// JSON ("" when empty). Choice, ordering and matching options must be non-blank
+// and unique after trimming.
+func encodeOptions(questionType string, options []string, limits QuizLimits) (string, error) {
+	if len(options) == 0 {
+		return "", nil
+	}
+	if len(options) > limits.MaxOptions {
+		return "", &LimitError{Err: ErrTooManyOptions, Limit: limits.MaxOptions}
+	}
+	switch questionType {
+	case "single_choice", "multiple_choice", "ordering", "matching":
+		seen := make(map[string]bool, len(options))
+		for _, opt := range options {
+			trimmed := strings.TrimSpace(opt)
+			if trimmed == "" {
+				return "", ErrBlankOption
+			}
+			if seen[trimmed] {
+				return "", &DuplicateOptionError{Value: trimmed}
+			}
+			seen[trimmed] = true
+		}
 	}
+	b, _ := json.Marshal(options)
+	if len(b) > limits.MaxOptionsBytes {
+		return "", &LimitError{Err: ErrOptionsTooLarge, Limit: limits.MaxOptionsBytes}
+	}
+	return string(b), nil
+}
 
-	for _, ans := range answers {
-		switch rule {
-		case "exact":
-			if studentAns == ans {
-				return true
+// validateStructuredAnswer checks that an ordering answer is a permutation of
+// the options and that a matching answer pairs every prompt (the first half of
+// the options) with a distinct target (the second half). See package grading
+// for the JSON shapes. Other question types are not checked.
+func validateStructuredAnswer(questionType string, options []string, answer string) error {
+	switch questionType {
+	case "ordering":
+		items, ok := grading.DecodeOrdering(answer)
+		if !ok || len(options) < 2 || len(items) != len(options) {
+			return ErrInvalidQuestionAnswer
+		}
+		remaining := make(map[string]bool, len(options))
+		for _, opt := range options {
+			remaining[opt] = true
+		}
+		for _, item := range items {
+			if !remaining[item] {
+				return ErrInvalidQuestionAnswer
 			}
-		case "exact_trim":
-			if strings.TrimSpace(strings.ToLower(studentAns)) == strings.TrimSpace(strings.ToLower(ans)) {
-				return true
+			delete(remaining, item)
+		}
+	case "matching":
+		pairs, ok := grading.DecodeMatching(answer)
+		if !ok || len(options) < 4 || len(options)%2 != 0 || len(pairs) != len(options)/2 {
+			return ErrInvalidQuestionAnswer
+		}
+		half := len(options) / 2
+		targets := make(map[string]bool, half)
+		for _, target := range options[half:] {
+			targets[target] = true
+		}
+		for _, prompt := range options[:half] {
+			target, ok := pairs[prompt]
+			if !ok || !targets[target] {
+				return ErrInvalidQuestionAnswer
 			}
-		case "contains":
-			if strings.Contains(strings.ToLower(studentAns), strings.ToLower(ans)) {
-				return true
+			delete(targets, target)
+		}
+	}
+	return nil
+}
+
+// validateQuestionContent checks the format and size of question content.
+// Content is never modified; for latex, inline ($...$) and display ($$...$$)
+// delimiters must be balanced, ignoring escaped \$.
+func validateQuestionContent(content, format string) error {
+	if !validContentFormats[format] {
+		return ErrInvalidContentFormat
+	}
+	if len(content) > maxQuestionContentBytes {
+		return ErrContentTooLarge
+	}
+	if format == "latex" {
+		dollars := 0
+		for i := 0; i < len(content); i++ {
+			if content[i] == '\\' {
+				i++
+				continue
 			}
-		case "regex":
-			if matched, err := regexp.MatchString(ans, studentAns); err == nil && matched {
-				return true
+			if content[i] == '$' {
+				dollars++
 			}
 		}
+		if dollars%2 != 0 {
+			return ErrUnbalancedLatex
+		}
 	}
-	return false
+	return nil
 }
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/datatypes"
+)
+
+var (
+	// ErrTooManyTags indicates a question has more than maxQuestionTags tags.
+	ErrTooManyTags = errors.New("too many tags")
+	// ErrInvalidTag indicates a tag is blank or longer than maxQuestionTagLength.
+	ErrInvalidTag = errors.New("invalid tag")
+)
+
+const (
+	maxQuestionTags      = 10
+	maxQuestionTagLength = 32 // characters
+)
+
+// normalizeTags trims tags and drops repeats, compared case-insensitively,
+// keeping the first spelling. A nil or empty list yields nil.
+func normalizeTags(tags []string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || utf8.RuneCountInString(tag) > maxQuestionTagLength {
+			return nil, ErrInvalidTag
+		}
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxQuestionTags {
+		return nil, ErrTooManyTags
+	}
+	return out, nil
+}
+
+func encodeTags(tags []string) datatypes.JSON {
+	if len(tags) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(tags)
+	return data
+}
+
+func decodeTags(data datatypes.JSON) []string {
+	tags := []string{}
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, &tags)
+	}
+	return tags
+}
+
+func hasTag(question models.Question, tag string) bool {
+	for _, t := range decodeTags(question.Tags) {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListQuestions returns a quiz's questions with answers for its staff,
+// optionally only those tagged tag (case-insensitive).
+func (s *QuizService) ListQuestions(ctx context.Context, quizID uint, tag string, user UserInfo) ([]QuestionResponse, error) {
+	if _, err := s.findManagedQuiz(ctx, quizID, user); err != nil {
+		return nil, err
+	}
+	questions, err := s.repo.ListQuestions(ctx, quizID)
+	if err != nil {
+		return nil, err
+	}
+	tag = strings.TrimSpace(tag)
+	result := make([]QuestionResponse, 0, len(questions))
+	for _, q := range questions {
+		if tag != "" && !hasTag(q, tag) {
+			continue
+		}
+		result = append(result, toQuestionResponse(q))
+	}
+	return result, nil
+}
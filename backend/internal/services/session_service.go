@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// sessionCacheLimit bounds the revocation cache; it is simply reset when full.
+const sessionCacheLimit = 10000
+
+// SessionService records session revocations and checks tokens against them.
+// Lookups are cached per user for cacheTTL, so a revocation made on another
+// instance takes effect within that window; revocations made here apply at once.
+type SessionService struct {
+	repo     *repositories.SessionRepository
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[uint]cachedRevocation
+}
+
+type cachedRevocation struct {
+	revokedAt time.Time // zero when the user has no revocation
+	fetchedAt time.Time
+}
+
+// NewSessionService builds a SessionService. A cacheTTL of zero disables caching.
+func NewSessionService(db *gorm.DB, cacheTTL time.Duration) *SessionService {
+	return &SessionService{
+		repo:     repositories.NewSessionRepository(db),
+		cacheTTL: cacheTTL,
+		cache:    make(map[uint]cachedRevocation),
+	}
+}
+
+// Revoke invalidates every token issued to the user up to now. Because token
+// issue times have one-second precision, a token issued later in the same
+// second is rejected as well. The revocation time is stored at that same
+// precision, so a database that rounds fractional seconds cannot move it
+// into the next second and the cached and stored values always agree.
+func (s *SessionService) Revoke(ctx context.Context, userID uint, reason string) error {
+	now := time.Now().Truncate(time.Second)
+	if err := s.repo.UpsertRevocation(ctx, userID, now, reason); err != nil {
+		return err
+	}
+	s.remember(userID, now)
+	return nil
+}
+
+// IsRevoked reports whether a token issued to the user at issuedAt has been revoked.
+func (s *SessionService) IsRevoked(ctx context.Context, userID uint, issuedAt time.Time) (bool, error) {
+	revokedAt, err := s.revokedAt(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return !revokedAt.IsZero() && !issuedAt.After(revokedAt), nil
+}
+
+func (s *SessionService) revokedAt(ctx context.Context, userID uint) (time.Time, error) {
+	s.mu.Lock()
+	entry, ok := s.cache[userID]
+	s.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < s.cacheTTL {
+		return entry.revokedAt, nil
+	}
+
+	var revokedAt time.Time
+	revocation, err := s.repo.FindRevocation(ctx, userID)
+	switch {
+	case err == nil:
+		revokedAt = revocation.RevokedAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return time.Time{}, err
+	}
+	s.remember(userID, revokedAt)
+	return revokedAt, nil
+}
+
+func (s *SessionService) remember(userID uint, revokedAt time.Time) {
+	if s.cacheTTL <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cache) >= sessionCacheLimit {
+		s.cache = make(map[uint]cachedRevocation)
+	}
+	s.cache[userID] = cachedRevocation{revokedAt: revokedAt, fetchedAt: time.Now()}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Availability of a quiz to students, as filtered by ListStudentQuizzes.
+const (
+	QuizAvailable = "available" // open now
+	QuizUpcoming  = "upcoming"  // opens later
+	QuizEnded     = "ended"     // closed
+)
+
+// ErrInvalidQuizAvailability indicates a status filter other than the
+// Quiz* availability values.
+var ErrInvalidQuizAvailability = errors.New("invalid quiz availability")
+
+// StudentQuiz is a quiz from one of the student's courses with their
+// attempts on it. Its window is written in the course's time zone.
+type StudentQuiz struct {
+	QuizWithAttempt
+	CourseName string `json:"course_name"`
+	Status     string `json:"status"` // available, upcoming or ended
+}
+
+// StudentQuizPage is one page of a student's quizzes.
+type StudentQuizPage struct {
+	Items    []StudentQuiz `json:"items"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// quizAvailability reports whether a quiz with the window start to end is
+// available, upcoming or ended at now. Both bounds are inclusive, matching
+// when StartQuiz accepts an attempt.
+func quizAvailability(start, end *time.Time, now time.Time) string {
+	switch {
+	case start != nil && now.Before(*start):
+		return QuizUpcoming
+	case end != nil && now.After(*end):
+		return QuizEnded
+	}
+	return QuizAvailable
+}
+
+// quizAvailabilityRank orders available quizzes before upcoming ones and
+// ended ones last.
+var quizAvailabilityRank = map[string]int{QuizAvailable: 0, QuizUpcoming: 1, QuizEnded: 2}
+
+// ListStudentQuizzes pages through the published quizzes of every course the
+// student is enrolled in, optionally only those with the given availability.
+// Available quizzes come first, closing soonest first, then upcoming ones by
+// opening time, then ended ones, most recently closed first.
+func (s *QuizService) ListStudentQuizzes(ctx context.Context, studentID uint, status string, page, pageSize int) (*StudentQuizPage, error) {
+	if _, ok := quizAvailabilityRank[status]; status != "" && !ok {
+		return nil, ErrInvalidQuizAvailability
+	}
+	result := &StudentQuizPage{Items: []StudentQuiz{}, Page: page, PageSize: pageSize}
+
+	courses, err := s.courses.FindByStudentID(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(courses) == 0 {
+		return result, nil
+	}
+	courseIDs := make([]uint, len(courses))
+	courseNames := make(map[uint]string, len(courses))
+	courseLocs := make(map[uint]*time.Location, len(courses))
+	for i, c := range courses {
+		courseIDs[i] = c.ID
+		courseNames[c.ID] = c.Name
+		courseLocs[c.ID] = courseLocation(&courses[i])
+	}
+	quizzes, err := s.repo.ListPublishedByCourses(ctx, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	statuses := make(map[uint]string, len(quizzes))
+	matched := quizzes[:0]
+	for _, q := range quizzes {
+		st := quizAvailability(q.StartTime, q.EndTime, now)
+		if status != "" && st != status {
+			continue
+		}
+		statuses[q.ID] = st
+		matched = append(matched, q)
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		ra, rb := quizAvailabilityRank[statuses[a.ID]], quizAvailabilityRank[statuses[b.ID]]
+		if ra != rb {
+			return ra < rb
+		}
+		switch statuses[a.ID] {
+		case QuizAvailable:
+			// Quizzes without a deadline go after those closing at some point.
+			if (a.EndTime == nil) != (b.EndTime == nil) {
+				return b.EndTime == nil
+			}
+			if a.EndTime != nil && !a.EndTime.Equal(*b.EndTime) {
+				return a.EndTime.Before(*b.EndTime)
+			}
+		case QuizUpcoming:
+			if !a.StartTime.Equal(*b.StartTime) {
+				return a.StartTime.Before(*b.StartTime)
+			}
+		case QuizEnded:
+			if !a.EndTime.Equal(*b.EndTime) {
+				return a.EndTime.After(*b.EndTime)
+			}
+		}
+		return a.ID > b.ID
+	})
+
+	result.Total = len(matched)
+	start := min((page-1)*pageSize, len(matched))
+	end := min(start+pageSize, len(matched))
+	pageQuizzes := matched[start:end]
+	for i := range pageQuizzes {
+		localizeQuiz(&pageQuizzes[i], courseLocs[pageQuizzes[i].CourseID])
+	}
+	decorated, err := s.withAttempts(ctx, pageQuizzes, studentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range decorated {
+		result.Items = append(result.Items, StudentQuiz{
+			QuizWithAttempt: q,
+			CourseName:      courseNames[q.CourseID],
+			Status:          statuses[q.ID],
+		})
+	}
+	return result, nil
+}
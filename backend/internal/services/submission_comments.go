@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/jobs"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/logger"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidComment indicates a submission comment is blank or too long.
+var ErrInvalidComment = errors.New("invalid comment")
+
+// MaxCommentRunes caps the length of a submission comment.
+const MaxCommentRunes = 2000
+
+// CommentNotifier tells a user's WeChat Work account about a new comment on a
+// submission.
+type CommentNotifier interface {
+	NotifySubmissionComment(ctx context.Context, recipientWecomID, assignmentTitle, authorName, body string) error
+}
+
+// WithCommentNotifier enables comment notifications for courses that opt in
+// via ModuleWecomNotify. Notifications are delivered on queue.
+func (s *AssignmentService) WithCommentNotifier(n CommentNotifier, queue *jobs.Queue) *AssignmentService {
+	s.comments = n
+	s.queue = queue
+	return s
+}
+
+// SubmissionCommentView is a submission comment with its author's name.
+type SubmissionCommentView struct {
+	models.SubmissionComment
+	AuthorName string `json:"author_name"`
+}
+
+// commentThread is a submission whose discussion the user may join, with
+// the people on each side of it.
+type commentThread struct {
+	data     *AssignmentGradingContext
+	students []uint // the submitting student and their group
+	isStaff  bool   // the user takes part as course staff
+}
+
+// ListSubmissionComments returns a submission's discussion, oldest first.
+// Only the submitting student, their group and course staff may read it.
+func (s *AssignmentService) ListSubmissionComments(ctx context.Context, submissionID uint, user UserInfo) ([]SubmissionCommentView, error) {
+	thread, err := s.findCommentThread(ctx, submissionID, user)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.repo.ListSubmissionComments(ctx, thread.data.Submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.commentViews(ctx, comments)
+}
+
+// AddSubmissionComment posts a comment to a submission's discussion, under the
+// same access rules as ListSubmissionComments, and notifies the other side.
+func (s *AssignmentService) AddSubmissionComment(ctx context.Context, submissionID uint, user UserInfo, body string) (*SubmissionCommentView, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > MaxCommentRunes {
+		return nil, ErrInvalidComment
+	}
+	thread, err := s.findCommentThread(ctx, submissionID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &models.SubmissionComment{
+		SubmissionID: thread.data.Submission.ID,
+		AuthorID:     user.ID,
+		Body:         body,
+	}
+	if err := s.repo.CreateSubmissionComment(ctx, comment); err != nil {
+		return nil, err
+	}
+	views, err := s.commentViews(ctx, []models.SubmissionComment{*comment})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyComment(ctx, thread, views[0])
+	return &views[0], nil
+}
+
+// findCommentThread loads a submission and decides the user's side of its
+// discussion. Members of the submitting group count as the student side even
+// when they also hold a staff role.
+func (s *AssignmentService) findCommentThread(ctx context.Context, submissionID uint, user UserInfo) (*commentThread, error) {
+	submission, err := s.repo.FindSubmissionByID(ctx, submissionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubmissionNotFound
+		}
+		return nil, err
+	}
+	assignment, err := s.repo.FindAssignment(ctx, submission.AssignmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAssignmentNotFound
+		}
+		return nil, err
+	}
+	course, err := s.repo.FindCourse(ctx, assignment.CourseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	students, err := s.submissionStudentIDs(ctx, submission)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := &commentThread{
+		data: &AssignmentGradingContext{
+			Submission: *submission,
+			Assignment: *assignment,
+			Course:     *course,
+		},
+		students: students,
+	}
+	for _, id := range students {
+		if id == user.ID {
+			return thread, nil
+		}
+	}
+	if course.TeacherID == user.ID || user.Role == "admin" || user.Role == "assistant" {
+		thread.isStaff = true
+		return thread, nil
+	}
+	return nil, ErrAccessDenied
+}
+
+// commentViews attaches author names to comments.
+func (s *AssignmentService) commentViews(ctx context.Context, comments []models.SubmissionComment) ([]SubmissionCommentView, error) {
+	authorIDs := make([]uint, 0, len(comments))
+	seen := make(map[uint]bool, len(comments))
+	for _, c := range comments {
+		if !seen[c.AuthorID] {
+			seen[c.AuthorID] = true
+			authorIDs = append(authorIDs, c.AuthorID)
+		}
+	}
+	users, err := s.repo.ListUsers(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Name
+		if u.Name == "" {
+			names[u.ID] = u.Username
+		}
+	}
+
+	views := make([]SubmissionCommentView, len(comments))
+	for i, c := range comments {
+		views[i] = SubmissionCommentView{SubmissionComment: c, AuthorName: names[c.AuthorID]}
+	}
+	return views, nil
+}
+
+// notifyComment queues a push of the comment to the other side of the
+// discussion: the students when staff wrote it, otherwise the course teacher
+// and whoever graded the submission. Like grade notifications it is a no-op
+// unless a notifier is set and the course opted in, and failures never affect
+// the comment.
+func (s *AssignmentService) notifyComment(ctx context.Context, thread *commentThread, comment SubmissionCommentView) {
+	if s.comments == nil || s.queue == nil {
+		return
+	}
+	modules, err := parseEnabledModules(thread.data.Course.EnabledModules)
+	if err != nil || !containsString(modules, ModuleWecomNotify) {
+		return
+	}
+	recipients := thread.students
+	if !thread.isStaff {
+		recipients = []uint{thread.data.Course.TeacherID}
+		if grader := thread.data.Submission.GradedBy; grader != nil && *grader != thread.data.Course.TeacherID {
+			recipients = append(recipients, *grader)
+		}
+	}
+
+	notifier := s.comments
+	title := thread.data.Assignment.Title
+	for _, userID := range recipients {
+		if userID == comment.AuthorID {
+			continue
+		}
+		recipient, err := s.repo.FindUser(ctx, userID)
+		if err != nil || recipient.WecomUserID == "" {
+			continue
+		}
+		wecomID := recipient.WecomUserID
+		err = s.queue.Enqueue(jobs.Task{
+			Name:        fmt.Sprintf("comment_notification:comment_%d:user_%d", comment.ID, userID),
+			MaxAttempts: gradeNotifyAttempts,
+			Timeout:     gradeNotifyTimeout,
+			Run: func(ctx context.Context) error {
+				return notifier.NotifySubmissionComment(ctx, wecomID, title, comment.AuthorName, comment.Body)
+			},
+		})
+		if err != nil {
+			logger.Log.Warn("comment notification not queued", slog.Uint64("comment_id", uint64(comment.ID)), slog.Any("error", err))
+		}
+	}
+}
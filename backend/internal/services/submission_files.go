@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// SubmissionFile is one submission of an assignment, named for the student
+// who submitted it. FileURL is empty when the submission has no file.
+type SubmissionFile struct {
+	SubmissionID uint
+	StudentID    uint
+	Username     string
+	FileURL      string
+	SubmittedAt  time.Time
+}
+
+// ListSubmissionFiles returns the assignment and its submissions ordered by
+// username, for downloading every file at once. Only the course teacher and
+// admins may list them.
+func (s *AssignmentService) ListSubmissionFiles(ctx context.Context, assignmentID uint, user UserInfo) (*models.Assignment, []SubmissionFile, error) {
+	assignment, err := s.repo.FindAssignment(ctx, assignmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAssignmentNotFound
+		}
+		return nil, nil, err
+	}
+	if err := s.checkCourseTeacher(ctx, assignment.CourseID, user); err != nil {
+		return nil, nil, err
+	}
+
+	submissions, err := s.repo.ListSubmissionsByAssignment(ctx, assignment.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	studentIDs := make([]uint, len(submissions))
+	for i, sub := range submissions {
+		studentIDs[i] = sub.StudentID
+	}
+	users, err := s.repo.ListUsers(ctx, studentIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	files := make([]SubmissionFile, len(submissions))
+	for i, sub := range submissions {
+		files[i] = SubmissionFile{
+			SubmissionID: sub.ID,
+			StudentID:    sub.StudentID,
+			Username:     usernames[sub.StudentID],
+			FileURL:      sub.FileURL,
+			SubmittedAt:  sub.UpdatedAt,
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Username < files[j].Username
+	})
+	return assignment, files, nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+)
+
+// ErrReceiptsDisabled indicates no receipt signer is configured.
+var ErrReceiptsDisabled = errors.New("submission receipts are disabled")
+
+// SubmissionReceipt is a signed record, kept by the student, that the server
+// received a submission with ContentHash at SubmittedAt.
+type SubmissionReceipt struct {
+	SubmissionID uint      `json:"submission_id"`
+	AssignmentID uint      `json:"assignment_id"`
+	StudentID    uint      `json:"student_id"` // the student who submitted
+	SubmittedAt  time.Time `json:"submitted_at"`
+	ContentHash  string    `json:"content_hash"` // hex SHA-256, see SubmissionContentHash
+	Signature    string    `json:"signature"`    // hex HMAC-SHA256 over the fields above
+}
+
+// ReceiptVerification is the outcome of checking a presented receipt.
+type ReceiptVerification struct {
+	// Valid reports whether the server issued the receipt for this submission.
+	Valid bool `json:"valid"`
+	// ContentMatches reports whether the submission still has the receipt's
+	// content; it is false once the student resubmits.
+	ContentMatches bool `json:"content_matches"`
+	// Deadline is the student's effective deadline, if any; OnTime compares
+	// the receipt's time against it and is only set for valid receipts.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	OnTime   *bool      `json:"on_time,omitempty"`
+}
+
+// ReceiptSigner signs and checks submission receipts.
+type ReceiptSigner struct {
+	key []byte
+}
+
+// NewReceiptSigner derives the signing key from secret, so the JWT secret can
+// be reused without its HMACs being interchangeable with tokens.
+func NewReceiptSigner(secret string) *ReceiptSigner {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("submission-receipt-v1"))
+	return &ReceiptSigner{key: mac.Sum(nil)}
+}
+
+func (r *ReceiptSigner) sign(receipt SubmissionReceipt) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(strings.Join([]string{
+		strconv.FormatUint(uint64(receipt.SubmissionID), 10),
+		strconv.FormatUint(uint64(receipt.AssignmentID), 10),
+		strconv.FormatUint(uint64(receipt.StudentID), 10),
+		receipt.SubmittedAt.UTC().Format(time.RFC3339Nano),
+		receipt.ContentHash,
+	}, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether receipt carries a signature issued by this signer.
+func (r *ReceiptSigner) Verify(receipt SubmissionReceipt) bool {
+	got, err := hex.DecodeString(receipt.Signature)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(r.sign(receipt))
+	return hmac.Equal(got, want)
+}
+
+// SubmissionContentHash is the hex SHA-256 of a submission's text and file
+// URL, separated by a NUL byte.
+func SubmissionContentHash(submission *models.Submission) string {
+	sum := sha256.Sum256([]byte(submission.Content + "\x00" + submission.FileURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithReceiptSigner makes SubmitAssignment callers able to issue receipts.
+func (s *AssignmentService) WithReceiptSigner(signer *ReceiptSigner) *AssignmentService {
+	s.receipts = signer
+	return s
+}
+
+// IssueReceipt signs a receipt for a submission the student just saved, or
+// returns nil when no signer is configured.
+func (s *AssignmentService) IssueReceipt(submission *models.Submission, studentID uint) *SubmissionReceipt {
+	if s.receipts == nil {
+		return nil
+	}
+	receipt := SubmissionReceipt{
+		SubmissionID: submission.ID,
+		AssignmentID: submission.AssignmentID,
+		StudentID:    studentID,
+		SubmittedAt:  submission.UpdatedAt.UTC(),
+		ContentHash:  SubmissionContentHash(submission),
+	}
+	receipt.Signature = s.receipts.sign(receipt)
+	return &receipt
+}
+
+// VerifyReceipt checks a receipt presented for a submission. The students of
+// the submission and course staff may verify it, as for its comments.
+func (s *AssignmentService) VerifyReceipt(ctx context.Context, submissionID uint, user UserInfo, receipt SubmissionReceipt) (*ReceiptVerification, error) {
+	if s.receipts == nil {
+		return nil, ErrReceiptsDisabled
+	}
+	thread, err := s.findCommentThread(ctx, submissionID, user)
+	if err != nil {
+		return nil, err
+	}
+	submission := &thread.data.Submission
+	receipt.SubmissionID = submission.ID
+
+	result := &ReceiptVerification{
+		Valid:          receipt.AssignmentID == submission.AssignmentID && s.receipts.Verify(receipt),
+		ContentMatches: receipt.ContentHash == SubmissionContentHash(submission),
+	}
+	if !result.Valid {
+		result.ContentMatches = false
+		return result, nil
+	}
+	deadline, err := s.effectiveDeadline(ctx, &thread.data.Assignment, receipt.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	if deadline != nil {
+		onTime := !receipt.SubmittedAt.After(*deadline)
+		result.Deadline = deadline
+		result.OnTime = &onTime
+	}
+	return result, nil
+}
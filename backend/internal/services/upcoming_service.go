@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/models"
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// DefaultUpcomingHorizon is how far ahead ListUpcoming looks when no horizon is given.
+const DefaultUpcomingHorizon = 7 * 24 * time.Hour
+
+// MaxUpcomingHorizon caps the look-ahead window of ListUpcoming.
+const MaxUpcomingHorizon = 90 * 24 * time.Hour
+
+// UpcomingService aggregates a student's open quizzes and assignments across courses.
+type UpcomingService struct {
+	courses     *repositories.CourseRepository
+	quizzes     *repositories.QuizRepository
+	assignments *repositories.AssignmentRepository
+}
+
+// NewUpcomingService builds an UpcomingService with its repositories.
+func NewUpcomingService(db *gorm.DB) *UpcomingService {
+	return &UpcomingService{
+		courses:     repositories.NewCourseRepository(db),
+		quizzes:     repositories.NewQuizRepository(db),
+		assignments: repositories.NewAssignmentRepository(db),
+	}
+}
+
+// UpcomingItem is a quiz or assignment the student still has to complete.
+// DueAt is written in the course's time zone.
+type UpcomingItem struct {
+	Type         string    `json:"type"` // "quiz" or "assignment"
+	ID           uint      `json:"id"`
+	CourseID     uint      `json:"course_id"`
+	CourseName   string    `json:"course_name"`
+	Title        string    `json:"title"`
+	DueAt        time.Time `json:"due_at"`
+	AttemptsUsed int       `json:"attempts_used,omitempty"`
+	MaxAttempts  int       `json:"max_attempts,omitempty"`
+}
+
+// ListUpcoming returns work due within horizon in the user's enrolled courses,
+// sorted by due time: published quizzes with attempts left and published
+// assignments not yet submitted.
+func (s *UpcomingService) ListUpcoming(ctx context.Context, user UserInfo, horizon time.Duration) ([]UpcomingItem, error) {
+	if horizon <= 0 {
+		horizon = DefaultUpcomingHorizon
+	}
+	if horizon > MaxUpcomingHorizon {
+		horizon = MaxUpcomingHorizon
+	}
+	now := time.Now()
+	until := now.Add(horizon)
+
+	courses, err := s.courses.FindByStudentID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]UpcomingItem, 0)
+	if len(courses) == 0 {
+		return items, nil
+	}
+	courseIDs := make([]uint, len(courses))
+	courseNames := make(map[uint]string, len(courses))
+	courseLocs := make(map[uint]*time.Location, len(courses))
+	for i, c := range courses {
+		courseIDs[i] = c.ID
+		courseNames[c.ID] = c.Name
+		courseLocs[c.ID] = courseLocation(&courses[i])
+	}
+
+	quizzes, err := s.quizzes.ListDueByCourses(ctx, courseIDs, now, until)
+	if err != nil {
+		return nil, err
+	}
+	quizIDs := make([]uint, len(quizzes))
+	for i, q := range quizzes {
+		quizIDs[i] = q.ID
+	}
+	submitted, err := s.quizzes.CountSubmittedAttemptsByStudent(ctx, user.ID, quizIDs)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := s.quizzes.CountAttemptGrantsByStudent(ctx, user.ID, quizIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range quizzes {
+		maxAttempts := q.MaxAttempts + granted[q.ID]
+		if submitted[q.ID] >= maxAttempts {
+			continue
+		}
+		items = append(items, UpcomingItem{
+			Type:         "quiz",
+			ID:           q.ID,
+			CourseID:     q.CourseID,
+			CourseName:   courseNames[q.CourseID],
+			Title:        q.Title,
+			DueAt:        *q.EndTime,
+			AttemptsUsed: submitted[q.ID],
+			MaxAttempts:  maxAttempts,
+		})
+	}
+
+	assignments, err := s.assignments.ListDueByCourses(ctx, courseIDs, now, until)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.unsubmittedAssignments(ctx, user.ID, assignments, courseNames)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, pending...)
+
+	for i := range items {
+		items[i].DueAt = items[i].DueAt.In(courseLocs[items[i].CourseID])
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DueAt.Before(items[j].DueAt)
+	})
+	return items, nil
+}
+
+func (s *UpcomingService) unsubmittedAssignments(ctx context.Context, studentID uint, assignments []models.Assignment, courseNames map[uint]string) ([]UpcomingItem, error) {
+	ids := make([]uint, len(assignments))
+	for i, a := range assignments {
+		ids[i] = a.ID
+	}
+	submittedIDs, err := s.assignments.ListSubmittedAssignmentIDs(ctx, studentID, ids)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[uint]bool, len(submittedIDs))
+	for _, id := range submittedIDs {
+		done[id] = true
+	}
+	items := make([]UpcomingItem, 0, len(assignments))
+	for _, a := range assignments {
+		if done[a.ID] {
+			continue
+		}
+		items = append(items, UpcomingItem{
+			Type:       "assignment",
+			ID:         a.ID,
+			CourseID:   a.CourseID,
+			CourseName: courseNames[a.CourseID],
+			Title:      a.Title,
+			DueAt:      *a.Deadline,
+		})
+	}
+	return items, nil
+}
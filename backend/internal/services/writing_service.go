@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/huaodong/emfield-teaching-platform/backend/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCourseIDRequired indicates a course must be named to scope the request.
+	ErrCourseIDRequired = errors.New("course_id is required")
+)
+
+// WritingService provides read models over students' writing submissions.
+type WritingService struct {
+	repo *repositories.WritingRepository
+}
+
+// NewWritingService builds a WritingService with its repository.
+func NewWritingService(db *gorm.DB) *WritingService {
+	return &WritingService{repo: repositories.NewWritingRepository(db)}
+}
+
+// WritingTrendPoint is one analyzed submission in a writing trend.
+type WritingTrendPoint struct {
+	SubmissionID uint               `json:"submission_id"`
+	CourseID     uint               `json:"course_id"`
+	WritingType  string             `json:"writing_type"`
+	Title        string             `json:"title"`
+	SubmittedAt  time.Time          `json:"submitted_at"`
+	OverallScore float64            `json:"overall_score"`
+	Dimensions   map[string]float64 `json:"dimensions"`
+}
+
+// WritingTrend is a student's analyzed submissions in date order. Dimensions
+// lists every dimension name that appears in any point, sorted.
+type WritingTrend struct {
+	StudentID  uint                `json:"student_id"`
+	CourseID   uint                `json:"course_id,omitempty"`
+	Type       string              `json:"type,omitempty"`
+	Dimensions []string            `json:"dimensions"`
+	Points     []WritingTrendPoint `json:"points"`
+}
+
+// GetWritingTrend returns the overall and per-dimension scores of a student's
+// finalized, analyzed submissions, oldest first. Submissions still waiting on
+// analysis, or whose stored feedback cannot be parsed, are left out.
+//
+// Students may only read their own trend. Admins may read any. Teachers and
+// assistants must name a course they teach or are enrolled in, and only that
+// course's submissions are returned.
+func (s *WritingService) GetWritingTrend(ctx context.Context, studentID uint, user UserInfo, courseID uint, writingType string) (*WritingTrend, error) {
+	if err := s.checkTrendAccess(ctx, studentID, user, courseID); err != nil {
+		return nil, err
+	}
+
+	submissions, err := s.repo.ListFinalizedByStudent(ctx, studentID, courseID, writingType)
+	if err != nil {
+		return nil, err
+	}
+
+	trend := &WritingTrend{
+		StudentID:  studentID,
+		CourseID:   courseID,
+		Type:       writingType,
+		Dimensions: []string{},
+		Points:     []WritingTrendPoint{},
+	}
+	seen := make(map[string]bool)
+	for _, sub := range submissions {
+		var feedback struct {
+			OverallScore *float64 `json:"overall_score"`
+		}
+		if sub.FeedbackJSON == "" || json.Unmarshal([]byte(sub.FeedbackJSON), &feedback) != nil || feedback.OverallScore == nil {
+			continue
+		}
+		var dimensions []struct {
+			Name  string  `json:"name"`
+			Score float64 `json:"score"`
+		}
+		if sub.DimensionJSON != "" {
+			_ = json.Unmarshal([]byte(sub.DimensionJSON), &dimensions)
+		}
+
+		point := WritingTrendPoint{
+			SubmissionID: sub.ID,
+			CourseID:     sub.CourseID,
+			WritingType:  sub.WritingType,
+			Title:        sub.Title,
+			SubmittedAt:  sub.CreatedAt,
+			OverallScore: *feedback.OverallScore,
+			Dimensions:   make(map[string]float64, len(dimensions)),
+		}
+		for _, d := range dimensions {
+			if d.Name == "" {
+				continue
+			}
+			point.Dimensions[d.Name] = d.Score
+			if !seen[d.Name] {
+				seen[d.Name] = true
+				trend.Dimensions = append(trend.Dimensions, d.Name)
+			}
+		}
+		trend.Points = append(trend.Points, point)
+	}
+	sort.Strings(trend.Dimensions)
+	return trend, nil
+}
+
+// checkTrendAccess applies the access rules described on GetWritingTrend.
+func (s *WritingService) checkTrendAccess(ctx context.Context, studentID uint, user UserInfo, courseID uint) error {
+	switch {
+	case user.Role == "admin":
+		return nil
+	case user.Role == "student":
+		if user.ID != studentID {
+			return ErrAccessDenied
+		}
+		return nil
+	case courseID == 0:
+		return ErrCourseIDRequired
+	}
+
+	course, err := s.repo.FindCourse(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCourseNotFound
+		}
+		return err
+	}
+	if user.Role == "teacher" && course.TeacherID == user.ID {
+		return nil
+	}
+	ok, err := s.repo.HasEnrollment(ctx, courseID, user.ID)
+	if err != nil {
+		return err
+	}
+	if !ok || !user.IsTeacher() {
+		return ErrAccessDenied
+	}
+	return nil
+}
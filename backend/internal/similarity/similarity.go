@@ -0,0 +1,204 @@
+// Package similarity flags near-duplicate texts with token shingles. Each
+// document becomes the set of its k-token shingles; MinHash signatures give a
+// cheap estimate of the Jaccard similarity of two sets, and pairs whose
+// estimate comes near the threshold are confirmed with the exact Jaccard index.
+package similarity
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ShingleSize is the number of consecutive tokens in a shingle.
+	ShingleSize = 3
+	// signatureSize is the number of MinHash functions per signature; the
+	// estimate's standard error is about 1/sqrt(signatureSize).
+	signatureSize = 128
+	// candidateSlack widens the MinHash filter so estimation error does not
+	// drop pairs whose exact score reaches the threshold.
+	candidateSlack = 0.15
+)
+
+// Document is a text to compare, identified by the caller's ID.
+type Document struct {
+	ID   uint
+	Text string
+}
+
+// Pair is two documents whose Jaccard similarity reached the threshold.
+// A is always the smaller ID.
+type Pair struct {
+	A, B  uint
+	Score float64
+}
+
+// Tokenize lower-cases text and splits it into words. Runs of letters and
+// digits form one token, except Han characters, which are a token each since
+// Chinese is written without spaces. Everything else separates tokens.
+func Tokenize(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// Shingles returns the hashed k-token shingles of text. Texts shorter than k
+// tokens yield a single shingle of all their tokens; empty texts yield none.
+func Shingles(text string, k int) map[uint64]struct{} {
+	tokens := Tokenize(text)
+	set := make(map[uint64]struct{})
+	if len(tokens) == 0 {
+		return set
+	}
+	if len(tokens) < k {
+		k = len(tokens)
+	}
+	for i := 0; i+k <= len(tokens); i++ {
+		set[hashTokens(tokens[i:i+k])] = struct{}{}
+	}
+	return set
+}
+
+// Jaccard returns |a ∩ b| / |a ∪ b|, or 0 when both sets are empty.
+func Jaccard(a, b map[uint64]struct{}) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	inter := 0
+	for h := range a {
+		if _, ok := b[h]; ok {
+			inter++
+		}
+	}
+	union := len(a) + len(b) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// FindPairs compares every pair of documents and returns those whose
+// similarity is at least threshold, most similar first. Documents without
+// any tokens are skipped.
+func FindPairs(docs []Document, threshold float64) []Pair {
+	type entry struct {
+		id        uint
+		shingles  map[uint64]struct{}
+		signature []uint64
+	}
+	entries := make([]entry, 0, len(docs))
+	for _, d := range docs {
+		set := Shingles(d.Text, ShingleSize)
+		if len(set) == 0 {
+			continue
+		}
+		entries = append(entries, entry{id: d.ID, shingles: set, signature: signature(set)})
+	}
+
+	pairs := []Pair{}
+	for i := 0; i < len(entries); i++ {
+		for j := i + 1; j < len(entries); j++ {
+			if estimate(entries[i].signature, entries[j].signature) < threshold-candidateSlack {
+				continue
+			}
+			score := Jaccard(entries[i].shingles, entries[j].shingles)
+			if score < threshold {
+				continue
+			}
+			a, b := entries[i].id, entries[j].id
+			if a > b {
+				a, b = b, a
+			}
+			pairs = append(pairs, Pair{A: a, B: b, Score: score})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		if pairs[i].A != pairs[j].A {
+			return pairs[i].A < pairs[j].A
+		}
+		return pairs[i].B < pairs[j].B
+	})
+	return pairs
+}
+
+func hashTokens(tokens []string) uint64 {
+	h := fnv.New64a()
+	for _, t := range tokens {
+		h.Write([]byte(t))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// signature is the MinHash signature of set: for each seed, the minimum of
+// the shingle hashes re-mixed with that seed.
+func signature(set map[uint64]struct{}) []uint64 {
+	sig := make([]uint64, signatureSize)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for h := range set {
+		for i := range sig {
+			if v := mix(h ^ seeds[i]); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// estimate is the share of positions where two signatures agree, an unbiased
+// estimate of the Jaccard similarity of the underlying sets.
+func estimate(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// mix is the splitmix64 finalizer, a cheap bijective 64-bit hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// seeds are fixed so results are reproducible across runs.
+var seeds = func() [signatureSize]uint64 {
+	var s [signatureSize]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range s {
+		x += 0x9e3779b97f4a7c15
+		s[i] = mix(x)
+	}
+	return s
+}()
@@ -2,6 +2,8 @@
 # 本地开发启动脚本
 
 export DB_DSN='emfield:EmField2024Pass!@tcp(localhost:3306)/emfield?charset=utf8mb4&parseTime=True&loc=Local'
+export APP_ENV='development'
+export JWT_SECRET='local-dev-jwt-secret-not-for-prod'
 export MINIO_ENDPOINT='localhost:9000'
 export MINIO_ACCESS_KEY='minioadmin'
 export MINIO_SECRET_KEY='minioadmin123'
@@ -2,6 +2,8 @@
 # 本地开发启动脚本 (SQLite版)
 
 export DB_DSN='sqlite:emfield.db'
+export APP_ENV='development'
+export JWT_SECRET='local-dev-jwt-secret-not-for-prod'
 export MINIO_ENDPOINT='localhost:9000'
 export MINIO_ACCESS_KEY='minioadmin'
 export MINIO_SECRET_KEY='minioadmin123'
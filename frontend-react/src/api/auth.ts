@@ -49,9 +49,12 @@ export const authApi = {
     },
 
     /**
-     * Clear local authentication state.
+     * Revoke the session server-side (best effort) and clear local authentication state.
      */
     logout() {
+        if (authStore.getToken()) {
+            api.auth.logout().catch(() => undefined);
+        }
         authStore.clearToken();
     }
 };
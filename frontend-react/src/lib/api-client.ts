@@ -8,7 +8,7 @@ const apiConfig = {
     getAccessToken: () => authStore.getToken(),
     getTokenType: () => 'Bearer',
     onUnauthorized: ({ url }: { url: string }) => {
-        if (url.includes('/auth/login') || url.includes('/auth/wecom')) return;
+        if (url.includes('/auth/login') || url.includes('/auth/wecom') || url.includes('/auth/logout')) return;
         authStore.clearToken();
         window.location.href = '/login';
     },
@@ -101,11 +101,11 @@ export async function recordLearningEvent(event: {
 // ============ Writing Submission API ============
 
 /**
- * Submit a writing sample for analysis.
+ * Save a writing sample as a draft; call finalizeWriting to submit it for analysis.
  *
  * @param courseId Course identifier.
- * @param data Writing payload to submit.
- * @returns The created writing submission.
+ * @param data Writing payload to save.
+ * @returns The created draft.
  */
 export async function submitWriting(
     courseId: number,
@@ -119,6 +119,26 @@ export async function submitWriting(
     return api.student.submitWriting(courseId, data);
 }
 
+/**
+ * Finalize a writing draft so it is analyzed and visible to teachers.
+ *
+ * @param id Submission identifier.
+ * @returns The finalized submission.
+ */
+export async function finalizeWriting(id: number): Promise<WritingSubmission> {
+    return api.student.finalizeWriting(id);
+}
+
+/**
+ * Delete the current student's unfinished drafts in a course.
+ *
+ * @param courseId Course identifier.
+ * @returns How many drafts were removed.
+ */
+export async function deleteWritingDrafts(courseId: number): Promise<{ deleted: number }> {
+    return api.student.deleteWritingDrafts(courseId);
+}
+
 /**
  * Get writing submissions for a course.
  *
@@ -1,7 +1,7 @@
 import { useState, useEffect, useCallback } from 'react';
 import { useCourse } from '@/domains/course/useCourse';
 import { announcementApi, type Announcement } from '@/api/announcement';
-import { Plus, Trash2, Megaphone } from 'lucide-react';
+import { Plus, Trash2, Megaphone, Pin, PinOff } from 'lucide-react';
 import { useAuth } from '@/domains/auth/useAuth';
 import { logger } from '@/lib/logger';
 
@@ -67,6 +67,16 @@ export function AnnouncementsPage() {
         }
     };
 
+    const handleTogglePin = async (id: number, pinned: boolean) => {
+        try {
+            await (pinned ? announcementApi.unpin(id) : announcementApi.pin(id));
+            await loadAnnouncements();
+        } catch (error) {
+            logger.error('failed to toggle announcement pin', { error, id });
+            alert('Failed to update pin (the pinned limit may have been reached)');
+        }
+    };
+
     const handleMarkRead = async (id: number, isRead: boolean) => {
         if (isRead) return;
         try {
@@ -160,6 +170,9 @@ export function AnnouncementsPage() {
                                     {!announcement.is_read && (
                                         <div className="w-2 h-2 rounded-full bg-blue-500 shrink-0" />
                                     )}
+                                    {announcement.pinned && (
+                                        <Pin className="w-4 h-4 text-amber-400 shrink-0" />
+                                    )}
                                     <h3 className={`text-lg font-semibold ${announcement.is_read ? 'text-gray-300' : 'text-white'}`}>
                                         {announcement.title}
                                     </h3>
@@ -168,6 +181,18 @@ export function AnnouncementsPage() {
                                     <span className="text-sm text-gray-500">
                                         {new Date(announcement.created_at).toLocaleDateString()}
                                     </span>
+                                    {canManage && (
+                                        <button
+                                            onClick={(e) => {
+                                                e.stopPropagation();
+                                                handleTogglePin(announcement.id, announcement.pinned);
+                                            }}
+                                            className="text-gray-500 hover:text-amber-400 transition-colors"
+                                            title={announcement.pinned ? '取消置顶' : '置顶'}
+                                        >
+                                            {announcement.pinned ? <PinOff className="w-4 h-4" /> : <Pin className="w-4 h-4" />}
+                                        </button>
+                                    )}
                                     {canManage && (
                                         <button
                                             onClick={(e) => {
@@ -48,8 +48,8 @@ export function AttendancePage() {
 
     const loadRecords = useCallback(async (sessionId: number) => {
         try {
-            const data = await attendanceApi.getRecords(sessionId);
-            setRecords(data);
+            const data = await attendanceApi.getRecords(sessionId, { page_size: 200 });
+            setRecords(data.items);
         } catch (error) {
             logger.error('failed to load attendance records', { error, sessionId });
         }
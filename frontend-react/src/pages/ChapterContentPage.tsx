@@ -181,7 +181,9 @@ export function ChapterContentPage() {
                                 <div className="flex justify-between items-center pt-2 border-t border-gray-700">
                                     <span className="text-gray-400">平均分</span>
                                     <span className="text-green-400 font-bold text-lg">
-                                        {(stats?.assignment_stats?.avg_score ?? 0).toFixed(1)}
+                                        {stats?.assignment_stats?.avg_score != null
+                                            ? stats.assignment_stats.avg_score.toFixed(1)
+                                            : '—'}
                                     </span>
                                 </div>
                             </div>
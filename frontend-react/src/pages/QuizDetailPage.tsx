@@ -719,6 +719,8 @@ function getQuestionTypeLabel(type: string): string {
         case 'multiple_choice': return '多选';
         case 'true_false': return '判断';
         case 'fill_blank': return '填空';
+        case 'ordering': return '排序';
+        case 'matching': return '连线';
         default: return type;
     }
 }
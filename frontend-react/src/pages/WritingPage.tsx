@@ -9,6 +9,7 @@ import { useState } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
 import {
     submitWriting,
+    finalizeWriting,
     getWritingSubmissions,
     getWritingTypeName,
     parseFeedback,
@@ -82,11 +83,12 @@ export default function WritingPage() {
         setSuccess('');
 
         try {
-            await submitWriting(parseInt(courseId), {
+            const draft = await submitWriting(parseInt(courseId), {
                 title: title.trim(),
                 content: content.trim(),
                 writing_type: writingType,
             });
+            await finalizeWriting(draft.ID ?? draft.id);
             setSuccess('提交成功！AI正在分析您的写作...');
             setTitle('');
             setContent('');
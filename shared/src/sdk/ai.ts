@@ -1,9 +1,11 @@
 import type { ApiClient } from './http';
-import type { ChatRequest, ChatResponse } from '../types';
+import type { ChatRequest, ChatResponse, ChatTranscript } from '../types';
 
 export function createAiApi(client: ApiClient) {
   return {
     chat: (request: ChatRequest, signal?: AbortSignal) =>
       client.post<ChatResponse>('/ai/chat', request, signal ? { signal } : undefined),
+    getChatSession: (sessionId: string) =>
+      client.get<ChatTranscript>(`/chat-sessions/${encodeURIComponent(sessionId)}`),
   };
 }
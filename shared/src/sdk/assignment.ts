@@ -7,6 +7,12 @@ import type {
   CreateAssignmentRequest,
   SubmitAssignmentRequest,
   GradeSubmissionRequest,
+  SimilarityReport,
+  StudentGroup,
+  SubmissionComment,
+  SubmissionReceipt,
+  ReceiptVerification,
+  StudentGroupRequest,
 } from '../types';
 
 export function createAssignmentApi(client: ApiClient) {
@@ -15,17 +21,46 @@ export function createAssignmentApi(client: ApiClient) {
     get: (id: number) => client.get<Assignment>(`/assignments/${id}`),
     create: (data: CreateAssignmentRequest) =>
       client.post<Assignment>(`/courses/${data.course_id}/assignments`, data),
+    /** Teacher/admin only; the chapter must belong to the same course, null detaches. */
+    moveToChapter: (assignmentId: number, chapterId: number | null) =>
+      client.put<Assignment>(`/assignments/${assignmentId}/chapter`, { chapter_id: chapterId }),
     submit: (assignmentId: number, data: SubmitAssignmentRequest) =>
-      client.post<AssignmentSubmission>(`/assignments/${assignmentId}/submit`, data),
+      client.post<AssignmentSubmission & { receipt?: SubmissionReceipt }>(`/assignments/${assignmentId}/submit`, data),
     listSubmissions: (assignmentId: number) =>
       client.get<AssignmentSubmission[]>(`/assignments/${assignmentId}/submissions`),
     grade: (submissionId: number, data: GradeSubmissionRequest) =>
       client.post<AssignmentSubmission>(`/submissions/${submissionId}/grade`, data),
     aiGrade: (submissionId: number) =>
       client.post<{ suggestion: string; recommended_grade: number | null }>(`/submissions/${submissionId}/ai-grade`),
+    listComments: (submissionId: number) =>
+      client.get<SubmissionComment[]>(`/submissions/${submissionId}/comments`),
+    /** Body is 1-2000 characters; the other side is notified where the course enables it. */
+    addComment: (submissionId: number, body: string) =>
+      client.post<SubmissionComment>(`/submissions/${submissionId}/comments`, { body }),
+    verifyReceipt: (submissionId: number, receipt: SubmissionReceipt) =>
+      client.get<ReceiptVerification>(`/submissions/${submissionId}/verify-receipt`, {
+        query: {
+          assignment_id: receipt.assignment_id,
+          student_id: receipt.student_id,
+          submitted_at: receipt.submitted_at,
+          content_hash: receipt.content_hash,
+          signature: receipt.signature,
+        },
+      }),
     getAssignmentStats: (id: number) =>
       client.get<AssignmentDetailedStats>(`/assignments/${id}/stats`),
     getCourseAssignmentStats: (courseId: number) =>
       client.get<CourseAssignmentStats>(`/courses/${courseId}/assignments/stats`),
+    /** Large classes return a pending report; poll getSimilarityReport until done. */
+    checkSimilarity: (id: number, threshold?: number) =>
+      client.post<SimilarityReport>(`/assignments/${id}/similarity`, threshold === undefined ? {} : { threshold }),
+    getSimilarityReport: (id: number) =>
+      client.get<SimilarityReport>(`/assignments/${id}/similarity`),
+    listGroups: (courseId: number) => client.get<StudentGroup[]>(`/courses/${courseId}/groups`),
+    createGroup: (courseId: number, data: StudentGroupRequest) =>
+      client.post<StudentGroup>(`/courses/${courseId}/groups`, data),
+    updateGroup: (groupId: number, data: StudentGroupRequest) =>
+      client.put<StudentGroup>(`/groups/${groupId}`, data),
+    deleteGroup: (groupId: number) => client.delete<{ message: string }>(`/groups/${groupId}`),
   };
 }
@@ -1,17 +1,34 @@
 import type { ApiClient } from './http';
-import type { ActiveSession, AttendanceSummary, SessionListItem, AttendanceRecord, CheckinResponse } from '../types';
+import type {
+  ActiveSession,
+  AttendanceCodeOptions,
+  AttendanceSummary,
+  AttendanceTrend,
+  SessionListItem,
+  AttendanceRecordPage,
+  AttendanceRecordQuery,
+  CheckinResponse,
+} from '../types';
 
 export function createAttendanceApi(client: ApiClient) {
   return {
     getSummary: (courseId: number) =>
       client.get<AttendanceSummary>(`/courses/${courseId}/attendance/summary`),
+    /** Teacher/admin only; sessions are ordered oldest first. */
+    getTrend: (courseId: number) =>
+      client.get<AttendanceTrend>(`/courses/${courseId}/attendance/trend`),
     listSessions: (courseId: number) =>
       client.get<SessionListItem[]>(`/courses/${courseId}/attendance/sessions`),
-    startSession: (courseId: number, timeoutMinutes = 15) =>
-      client.post<ActiveSession>(`/courses/${courseId}/attendance/start`, { timeout_minutes: timeoutMinutes }),
+    startSession: (courseId: number, timeoutMinutes = 15, codeOptions: AttendanceCodeOptions = {}) =>
+      client.post<ActiveSession>(`/courses/${courseId}/attendance/start`, {
+        timeout_minutes: timeoutMinutes,
+        ...codeOptions,
+      }),
     endSession: (sessionId: number) => client.post<void>(`/attendance/${sessionId}/end`, {}),
     checkin: (sessionId: number, code: string) =>
       client.post<CheckinResponse>(`/attendance/${sessionId}/checkin`, { code }),
-    getRecords: (sessionId: number) => client.get<AttendanceRecord[]>(`/attendance/${sessionId}/records`),
+    /** Earliest check-in first. */
+    getRecords: (sessionId: number, params: AttendanceRecordQuery = {}) =>
+      client.get<AttendanceRecordPage>(`/attendance/${sessionId}/records`, { query: params }),
   };
 }
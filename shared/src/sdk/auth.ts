@@ -6,6 +6,9 @@ export function createAuthApi(client: ApiClient) {
     login: (username: string, password: string) =>
       client.post<LoginResponse>('/auth/login', { username, password } satisfies LoginRequest),
     me: () => client.get<MeResponse>('/auth/me'),
+    logout: () => client.post<{ message: string }>('/auth/logout', {}),
     wecomLogin: (code: string) => client.post<LoginResponse>('/auth/wecom', { code }),
+    /** Feature flags on for the current user's role, e.g. 'ai_grading'. */
+    featureFlags: () => client.get<{ flags: string[] }>('/feature-flags'),
   };
 }
@@ -1,10 +1,12 @@
 import type { ApiClient } from './http';
-import type { Chapter, ChapterStudentStats } from '../types';
+import type { Chapter, ChapterStudentStats, CourseStudyTime } from '../types';
 
 export function createChapterApi(client: ApiClient) {
   return {
     list: (courseId: number | string) => client.get<Chapter[]>(`/courses/${courseId}/chapters`),
     get: (id: number | string) => client.get<Chapter>(`/chapters/${id}`),
+    getCourseStudyTime: (courseId: number | string) =>
+      client.get<CourseStudyTime>(`/courses/${courseId}/study-time`),
     getMyStats: (id: number | string) => client.get<ChapterStudentStats>(`/chapters/${id}/my-stats`),
     heartbeat: (id: number | string) => client.post<{ message: string; duration: number }>(`/chapters/${id}/heartbeat`, {}),
     recordStudyTime: (id: number | string, durationSeconds?: number) =>
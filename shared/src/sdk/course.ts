@@ -1,16 +1,35 @@
 import type { ApiClient } from './http';
-import type { Course } from '../types';
+import type { AtRiskReport, Course, CourseEnrollment, CourseOverview, CoursePurgeResult, RiskPolicy } from '../types';
 
 export type CreateCourseRequest = {
   name: string;
   code?: string;
   semester?: string;
+  time_zone?: string;
 };
 
 export function createCourseApi(client: ApiClient) {
   return {
     list: () => client.get<Course[]>('/courses'),
     get: (id: number | string) => client.get<Course>(`/courses/${id}`),
+    /** limit caps each list (default 5, max 20); follow has_more to the section's own endpoint. */
+    getOverview: (id: number | string, limit?: number) =>
+      client.get<CourseOverview>(`/courses/${id}/overview`, { query: { limit } }),
+    /** Teacher/admin only; unset policy fields keep their defaults. */
+    listAtRisk: (id: number | string, policy?: Partial<RiskPolicy>) =>
+      client.get<AtRiskReport>(`/courses/${id}/at-risk`, { query: policy }),
     create: (data: CreateCourseRequest) => client.post<Course>('/courses', data),
+    /** Teacher/admin only; an empty string resets the course to UTC. */
+    updateTimeZone: (courseId: number, timeZone: string) =>
+      client.put<Course>(`/courses/${courseId}/time-zone`, { time_zone: timeZone }),
+    /** Teacher/admin only; the user must already be enrolled. */
+    updateEnrollmentRole: (courseId: number, userId: number, role: CourseEnrollment['role']) =>
+      client.put<CourseEnrollment>(`/courses/${courseId}/enrollments/${userId}/role`, { role }),
+    /** Teacher/admin only; permanently deletes the course and all its data. confirm must be the course name. */
+    purge: (courseId: number, confirm: string) =>
+      client.delete<CoursePurgeResult>(`/courses/${courseId}`, { body: { confirm } }),
+    /** Students only; fails with ENROLLMENT_HAS_WORK once they have submissions or quiz attempts. */
+    unenroll: (courseId: number) =>
+      client.delete<{ course_id: number; unenrolled: boolean }>(`/courses/${courseId}/enrollments/me`),
   };
 }
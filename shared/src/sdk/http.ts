@@ -25,6 +25,8 @@ export type ApiClientConfig = {
   baseUrl: string;
   getAccessToken?: () => string | null | undefined;
   getTokenType?: () => string | null | undefined;
+  /** Teacher/admin preview: a course id sends X-View-As: student for that course. Writes are rejected while previewing. */
+  getViewAsCourseId?: () => number | null | undefined;
   onUnauthorized?: (info: { url: string; status: number }) => void;
   timeoutMs?: number;
   fetchFn?: typeof fetch;
@@ -130,7 +132,13 @@ export function createApiClient(config: ApiClientConfig): ApiClient {
     const token = config.getAccessToken?.();
     if (!token) return {};
     const tokenType = config.getTokenType?.() ?? 'Bearer';
-    return { Authorization: `${tokenType} ${token}` };
+    const headers: Record<string, string> = { Authorization: `${tokenType} ${token}` };
+    const viewAsCourseId = config.getViewAsCourseId?.();
+    if (viewAsCourseId) {
+      headers['X-View-As'] = 'student';
+      headers['X-View-As-Course'] = String(viewAsCourseId);
+    }
+    return headers;
   };
 
   async function request<T>(path: string, options: RequestOptions = {}): Promise<T> {
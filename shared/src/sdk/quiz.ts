@@ -1,27 +1,74 @@
-import type { ApiClient } from './http';
+import type { ApiClient, UploadRequest } from './http';
 import type {
   Quiz,
   QuizWithAttempt,
+  QuizInfo,
+  StudentQuizPage,
+  StudentQuizQuery,
   Question,
   QuestionWithAnswer,
   QuizAttempt,
+  AttemptSnapshot,
+  AttemptOrigin,
   CreateQuizRequest,
   CreateQuestionRequest,
   SubmitQuizRequest,
+  RegradeQuizRequest,
+  RegradeQuizResult,
+  QuizResults,
+  BulkQuizResult,
+  QuizFeedback,
+  QuizFeedbackRequest,
+  QuizFeedbackSummary,
+  NormalizationPreview,
+  QuestionImportResult,
 } from '../types';
 
 export function createQuizApi(client: ApiClient) {
   return {
     listByCourse: (courseId: number) => client.get<Array<Quiz | QuizWithAttempt>>(`/courses/${courseId}/quizzes`),
+    /** Published quizzes across the caller's courses: available first, then upcoming, then ended. */
+    listMine: (params: StudentQuizQuery = {}) => client.get<StudentQuizPage>('/me/quizzes', { query: params }),
     create: (data: CreateQuizRequest) => client.post<Quiz>('/quizzes', data),
     get: (quizId: number) =>
-      client.get<{ quiz: Quiz; questions: Array<Question | QuestionWithAnswer> }>(`/quizzes/${quizId}`),
+      client.get<{ quiz: Quiz; questions: Array<Question | QuestionWithAnswer>; time_zone: string }>(`/quizzes/${quizId}`),
+    /** Uses no attempt and lists no questions; students see published quizzes only. */
+    info: (quizId: number) => client.get<QuizInfo>(`/quizzes/${quizId}/info`),
     update: (quizId: number, data: Partial<CreateQuizRequest>) => client.put<Quiz>(`/quizzes/${quizId}`, data),
     delete: (quizId: number) => client.delete<void>(`/quizzes/${quizId}`),
-    publish: (quizId: number) => client.post<Quiz>(`/quizzes/${quizId}/publish`, {}),
+    /** Teacher/admin only; the chapter must belong to the same course, null detaches. */
+    moveToChapter: (quizId: number, chapterId: number | null) =>
+      client.put<Quiz>(`/quizzes/${quizId}/chapter`, { chapter_id: chapterId }),
+    /** normalize_to reports scores out of that total; 0 keeps raw points. */
+    publish: (quizId: number, options: { normalize_to?: number } = {}) =>
+      client.post<Quiz>(`/quizzes/${quizId}/publish`, options),
+    previewPublish: (quizId: number, normalizeTo?: number) =>
+      client.post<NormalizationPreview>(`/quizzes/${quizId}/publish`, { normalize_to: normalizeTo, preview: true }),
     unpublish: (quizId: number) => client.post<Quiz>(`/quizzes/${quizId}/unpublish`, {}),
+    /** Publishes all or none; every quiz must belong to the course (at most 100). */
+    publishMany: (courseId: number, quizIds: number[]) =>
+      client.post<BulkQuizResult[]>(`/courses/${courseId}/quizzes/publish`, { quiz_ids: quizIds }),
+    /** Quizzes with attempts stay published and are reported as failed. */
+    unpublishMany: (courseId: number, quizIds: number[]) =>
+      client.post<BulkQuizResult[]>(`/courses/${courseId}/quizzes/unpublish`, { quiz_ids: quizIds }),
+    /** Staff only; tag filters case-insensitively. */
+    listQuestions: (quizId: number, tag?: string) =>
+      client.get<QuestionWithAnswer[]>(`/quizzes/${quizId}/questions`, { query: tag ? { tag } : undefined }),
     addQuestion: (quizId: number, data: CreateQuestionRequest) =>
       client.post<QuestionWithAnswer>(`/quizzes/${quizId}/questions`, data),
+    /**
+     * Imports questions from a CSV file with a header row: type, content, answer and optionally options,
+     * points, match_rule, order_num, content_format, tags (or their Chinese labels, e.g. 题型, 题目, 答案).
+     * Options and tags are separated by "|".
+     */
+    importQuestions: (quizId: number, file: UploadRequest['file']) =>
+      client.upload<QuestionImportResult>(`/quizzes/${quizId}/questions/import`, { file }),
+    /** Copies questions of another quiz into this unpublished one; the user must manage both courses (at most 100). */
+    copyQuestions: (quizId: number, sourceQuizId: number, questionIds: number[]) =>
+      client.post<QuestionWithAnswer[]>(`/quizzes/${quizId}/questions/copy-from`, {
+        source_quiz_id: sourceQuizId,
+        question_ids: questionIds,
+      }),
     updateQuestion: (questionId: number, data: Partial<CreateQuestionRequest>) =>
       client.put<QuestionWithAnswer>(`/questions/${questionId}`, data),
     deleteQuestion: (questionId: number) => client.delete<void>(`/questions/${questionId}`),
@@ -31,9 +78,38 @@ export function createQuizApi(client: ApiClient) {
       client.post<{ score: number; max_score: number; attempt: QuizAttempt }>(`/quizzes/${quizId}/submit`, {
         answers,
       }),
+    /** Teacher only: reopens a submitted attempt so the student can resume it via start. */
+    reopenAttempt: (attemptId: number, reason: string) =>
+      client.post<QuizAttempt>(`/quiz-attempts/${attemptId}/reopen`, { reason }),
+    /** Student only, on their own submitted attempt; sending again replaces it. */
+    submitFeedback: (attemptId: number, data: QuizFeedbackRequest) =>
+      client.post<QuizFeedback>(`/quiz-attempts/${attemptId}/feedback`, data),
+    getFeedbackSummary: (quizId: number) => client.get<QuizFeedbackSummary>(`/quizzes/${quizId}/feedback-summary`),
+    regrade: (quizId: number, data: RegradeQuizRequest = {}) =>
+      client.post<RegradeQuizResult>(`/quizzes/${quizId}/regrade`, data),
+    getResults: (quizId: number) => client.get<QuizResults>(`/quizzes/${quizId}/results`),
     getResult: (quizId: number) =>
-      client.get<{ quiz: Quiz; attempts: QuizAttempt[]; questions?: QuestionWithAnswer[] }>(
-        `/quizzes/${quizId}/result`
-      ),
+      client.get<{
+        quiz: Quiz;
+        attempts: QuizAttempt[];
+        questions?: QuestionWithAnswer[];
+        review_attempt_id?: number;
+        snapshots?: AttemptSnapshot[];
+        origins?: AttemptOrigin[];
+      }>(`/quizzes/${quizId}/result`),
+    listStudentAttempts: (courseId: number, studentId: number) =>
+      client.get<{
+        course_id: number;
+        student_id: number;
+        attempts: Array<{
+          attempt_id: number;
+          quiz_id: number;
+          quiz_title: string;
+          attempt_number: number;
+          score: number | null;
+          max_score: number;
+          submitted_at: string;
+        }>;
+      }>(`/courses/${courseId}/students/${studentId}/quiz-attempts`),
   };
 }
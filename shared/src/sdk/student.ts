@@ -4,12 +4,17 @@ import type {
   LearningEvent,
   WritingSubmission,
   WritingType,
+  WritingTrend,
 } from '../types';
 
 export type LearningTimelineParams = {
   page?: number;
   page_size?: number;
   course_id?: number;
+  /** Cursor paging (preferred for feeds): events older than this ID. */
+  before_id?: number;
+  /** Cursor paging: events newer than this ID. */
+  after_id?: number;
 };
 
 export type LearningTimelineResponse = {
@@ -17,6 +22,8 @@ export type LearningTimelineResponse = {
   total: number;
   page: number;
   page_size: number;
+  /** Set in cursor mode; pass back as before_id/after_id. */
+  next_cursor?: number | null;
 };
 
 export function createStudentApi(client: ApiClient) {
@@ -36,6 +43,7 @@ export function createStudentApi(client: ApiClient) {
         total: (payload as LearningTimelineResponse).total ?? items.length,
         page: (payload as LearningTimelineResponse).page ?? params?.page ?? 1,
         page_size: (payload as LearningTimelineResponse).page_size ?? params?.page_size ?? items.length,
+        next_cursor: (payload as LearningTimelineResponse).next_cursor ?? null,
       } satisfies LearningTimelineResponse;
     },
     recordLearningEvent: (event: {
@@ -51,6 +59,11 @@ export function createStudentApi(client: ApiClient) {
         query: writingType ? { writing_type: writingType } : undefined,
       }),
     getWritingSubmission: (submissionId: number) => client.get<WritingSubmission>(`/writing/${submissionId}`),
+    finalizeWriting: (submissionId: number) => client.post<WritingSubmission>(`/writing/${submissionId}/finalize`, {}),
+    deleteWritingDrafts: (courseId: number) =>
+      client.delete<{ deleted: number }>(`/courses/${courseId}/writing/drafts`),
+    getWritingTrend: (studentId: number, params: { course_id?: number; type?: WritingType } = {}) =>
+      client.get<WritingTrend>(`/students/${studentId}/writing-trend`, { query: params }),
     getWritingStats: (courseId: number) =>
       client.get<{ weakness_stats: Array<{ name: string; count: number }>; student_count: number }>(
         `/courses/${courseId}/writing/stats`
@@ -1,9 +1,11 @@
 import type { ApiClient } from './http';
-import type { UserStats, LearningStats } from '../types';
+import type { UserStats, LearningStats, UpcomingItem, Dashboard } from '../types';
 
 export function createUserApi(client: ApiClient) {
   return {
     getStats: () => client.get<UserStats>('/user/stats'),
     getMyLearningStats: () => client.get<LearningStats>('/users/me/stats'),
+    getUpcoming: () => client.get<UpcomingItem[]>('/me/upcoming'),
+    getDashboard: () => client.get<Dashboard>('/me/dashboard'),
   };
 }
@@ -9,6 +9,9 @@ export type ChatRequest = {
   mode?: string;
   messages: ChatMessage[];
   stream?: boolean;
+  /** Continues a stored transcript; the server returns the id in X-Chat-Session-ID. */
+  session_id?: string;
+  course_id?: number;
 };
 
 export type ChatResponse = {
@@ -30,3 +33,24 @@ export type ChatWithToolsResponse = {
   tool_calls?: Record<string, unknown>[];
   tool_results?: Record<string, unknown>[];
 };
+
+export type ChatTranscriptMessage = {
+  ID: number;
+  CreatedAt?: string;
+  transcript_id: number;
+  role: 'system' | 'user' | 'assistant';
+  content: string;
+};
+
+export type ChatTranscript = {
+  ID: number;
+  CreatedAt?: string;
+  UpdatedAt?: string;
+  session_id: string;
+  user_id: number;
+  course_id?: number;
+  mode: string;
+  message_count: number;
+  truncated: boolean;
+  messages: ChatTranscriptMessage[];
+};
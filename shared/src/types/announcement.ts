@@ -4,6 +4,8 @@ export type Announcement = {
   content: string;
   created_at: string;
   is_read: boolean;
+  /** Pinned announcements are listed first. */
+  pinned: boolean;
 };
 
 export type AnnouncementSummary = {
@@ -20,3 +22,18 @@ export type CreateAnnouncementRequest = {
   title: string;
   content: string;
 };
+
+export type AnnouncementSearchQuery = {
+  /** Matched against title and content; at most 100 characters. */
+  q: string;
+  page?: number;
+  /** 1-100, default 20. */
+  page_size?: number;
+};
+
+export type AnnouncementSearchPage = {
+  items: Announcement[];
+  total: number;
+  page: number;
+  page_size: number;
+};
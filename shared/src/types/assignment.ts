@@ -11,6 +11,12 @@ export type Assignment = {
   allow_file?: boolean;
   max_file_size?: number;
   max_score?: number;
+  /** One shared submission per student group; every member gets its grade. */
+  group_submission?: boolean;
+  /** Accept submissions after the deadline; each started day late costs late_penalty_per_day points, down to late_penalty_floor. */
+  allow_late?: boolean;
+  late_penalty_per_day?: number;
+  late_penalty_floor?: number;
   status?: 'pending' | 'submitted' | 'graded';
   submission?: AssignmentSubmission;
   CreatedAt?: string;
@@ -22,9 +28,16 @@ export type AssignmentSubmission = {
   id?: number;
   assignment_id: number;
   student_id: number;
+  /** Set on group submissions; student_id is then the member who first submitted. */
+  group_id?: number | null;
   content?: string;
   file_url?: string | null;
+  /** After any late penalty; raw_grade is the grade as entered. */
   grade?: number | null;
+  raw_grade?: number | null;
+  late_penalty?: number;
+  /** When the content last changed after the student's deadline; absent when on time. */
+  late_at?: string | null;
   score?: number;
   feedback?: string | null;
   graded_by?: number | null;
@@ -34,12 +47,48 @@ export type AssignmentSubmission = {
   UpdatedAt?: string;
 };
 
+/** A message in the discussion between a student and course staff on a submission. */
+export type SubmissionComment = {
+  id: number;
+  submission_id: number;
+  author_id: number;
+  author_name: string;
+  body: string;
+  created_at: string;
+};
+
+/** Signed proof, returned on submit, that the server received the content at submitted_at. */
+export type SubmissionReceipt = {
+  submission_id: number;
+  assignment_id: number;
+  student_id: number;
+  submitted_at: string;
+  content_hash: string;
+  signature: string;
+};
+
+export type ReceiptVerification = {
+  valid: boolean;
+  /** False once the student has resubmitted different content. */
+  content_matches: boolean;
+  deadline?: string;
+  on_time?: boolean;
+};
+
 export type CreateAssignmentRequest = {
   course_id: number;
+  /** A chapter of the same course. */
+  chapter_id?: number;
   title: string;
   description?: string;
+  /** RFC 3339, or "YYYY-MM-DDTHH:MM[:SS]" read in the course's time zone. */
   deadline?: string;
   allow_file?: boolean;
+  group_submission?: boolean;
+  /** Late penalties (0-100 points) require allow_late. */
+  allow_late?: boolean;
+  late_penalty_per_day?: number;
+  late_penalty_floor?: number;
 };
 
 export type SubmitAssignmentRequest = {
@@ -55,15 +104,68 @@ export type GradeSubmissionRequest = {
 export type AssignmentDetailedStats = {
   total_students: number;
   submitted_count: number;
+  not_submitted_count: number;
+  ungraded_count: number;
   graded_count: number;
-  average_grade: number;
-  highest_grade: number;
-  lowest_grade: number;
+  /** Grade aggregates are null until at least one submission is graded. */
+  average_grade: number | null;
+  highest_grade: number | null;
+  lowest_grade: number | null;
 };
 
 export type CourseAssignmentStats = {
   total_assignments: number;
   pending_count: number;
   submitted_count: number;
-  average_grade: number;
+  not_submitted_count: number;
+  ungraded_count: number;
+  graded_count: number;
+  /** Null until at least one submission is graded. */
+  average_grade: number | null;
+};
+
+export type SimilarityPair = {
+  submission_a_id: number;
+  student_a_id: number;
+  submission_b_id: number;
+  student_b_id: number;
+  /** Jaccard similarity of the two texts' 3-token shingles, 0–1. */
+  score: number;
+};
+
+export type SimilarityReport = {
+  ID: number;
+  assignment_id: number;
+  requested_by_id: number;
+  status: 'pending' | 'done' | 'failed';
+  threshold: number;
+  submission_count: number;
+  truncated: boolean;
+  pairs: SimilarityPair[] | null;
+  error?: string;
+  completed_at?: string;
+  CreatedAt?: string;
+};
+
+export type StudentGroupMember = {
+  id: number;
+  group_id: number;
+  course_id: number;
+  user_id: number;
+  created_at?: string;
+};
+
+export type StudentGroup = {
+  ID: number;
+  course_id: number;
+  name: string;
+  members?: StudentGroupMember[];
+  CreatedAt?: string;
+  UpdatedAt?: string;
+};
+
+/** A student may belong to at most one group per course. */
+export type StudentGroupRequest = {
+  name: string;
+  member_ids: number[];
 };
@@ -4,6 +4,14 @@ export type ActiveSession = {
   ends_at: string;
 };
 
+/** Overrides the course's check-in code settings (module_settings.attendance) for one session. */
+export type AttendanceCodeOptions = {
+  /** 4-16 characters; defaults to 6. */
+  code_length?: number;
+  /** Letters and digits instead of digits only. */
+  code_alphanumeric?: boolean;
+};
+
 export type AttendanceSummary = {
   attendance_rate: number;
   sessions_count: number;
@@ -26,8 +34,39 @@ export type AttendanceRecord = {
   ip_address: string;
 };
 
+export type AttendanceRecordQuery = {
+  page?: number;
+  /** 1-200, default 50. */
+  page_size?: number;
+  /** RFC 3339; only check-ins at or after this time. */
+  since?: string;
+};
+
+export type AttendanceRecordPage = {
+  items: AttendanceRecord[];
+  total: number;
+  page: number;
+  page_size: number;
+};
+
 export type CheckinResponse = {
   success: boolean;
   already_checked_in?: boolean;
   checked_in_at: string;
 };
+
+export type AttendanceTrendPoint = {
+  session_id: number;
+  start_at: string;
+  /** Session start date, YYYY-MM-DD. */
+  date: string;
+  attendee_count: number;
+  /** Students enrolled by the time the session ended. */
+  enrolled_count: number;
+  rate: number;
+};
+
+export type AttendanceTrend = {
+  course_id: number;
+  sessions: AttendanceTrendPoint[];
+};
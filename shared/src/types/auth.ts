@@ -3,6 +3,13 @@ export type LoginRequest = {
   password: string;
 };
 
+/** First-screen counts; absent when the server could not compute them. */
+export type SessionCounts = {
+  /** Courses enrolled in, taught or assisted; all courses for admins. */
+  courses: number;
+  unread_announcements: number;
+};
+
 export type LoginResponse = {
   access_token: string;
   token_type?: string;
@@ -10,6 +17,7 @@ export type LoginResponse = {
   user_id?: number | string;
   username?: string;
   role?: string;
+  counts?: SessionCounts;
 };
 
 export type MeResponse = {
@@ -18,6 +26,7 @@ export type MeResponse = {
   name?: string;
   role: string;
   permissions: string[];
+  counts?: SessionCounts;
 };
 
 export type User = {
@@ -7,6 +7,8 @@ export type Course = {
   description?: string;
   code?: string;
   semester?: string;
+  /** IANA zone quiz deadlines are entered and shown in; absent means UTC. */
+  time_zone?: string;
   teacher_id: number;
   teacher_name?: string;
   student_count?: number;
@@ -14,6 +16,14 @@ export type Course = {
   UpdatedAt?: string;
 };
 
+export type CourseEnrollment = {
+  ID: number;
+  course_id: number;
+  user_id: number;
+  role: 'student' | 'assistant';
+  enrolled_at: string;
+};
+
 export type Chapter = {
   ID: number;
   id?: number;
@@ -33,8 +43,11 @@ export type AssignmentStats = {
   total: number;
   submitted: number;
   graded: number;
-  avg_score: number;
-  accuracy_rate: number;
+  not_submitted: number;
+  ungraded: number;
+  /** Null until at least one submission is graded. */
+  avg_score: number | null;
+  accuracy_rate: number | null;
 };
 
 export type QuizStats = {
@@ -52,3 +65,130 @@ export type ChapterStudentStats = {
   resources?: Resource[];
   knowledge_points?: string[];
 };
+
+export type ChapterStudyTime = {
+  chapter_id: number;
+  title: string;
+  seconds: number;
+  formatted: string;
+  /** Teachers only. */
+  class_avg_seconds?: number;
+  class_avg_formatted?: string;
+};
+
+export type CourseStudyTime = {
+  course_id: number;
+  total_seconds: number;
+  total_formatted: string;
+  /** Teachers only; averages count students who have not studied as zero. */
+  total_students?: number;
+  class_avg_seconds?: number;
+  class_avg_formatted?: string;
+  chapters: ChapterStudyTime[];
+};
+
+export type OverviewSection<T> = {
+  items: T[];
+  total: number;
+  has_more: boolean;
+};
+
+/**
+ * A course page in one payload. Fields marked "student view" are only set
+ * when `view` is 'student'; times are in the course's time zone.
+ */
+export type CourseOverview = {
+  course: Course;
+  view: 'student' | 'staff';
+  chapters: OverviewSection<{
+    id: number;
+    title: string;
+    order_num: number;
+    /** Student view. */
+    completed?: boolean;
+  }>;
+  assignments: OverviewSection<{
+    id: number;
+    title: string;
+    deadline?: string;
+    is_published: boolean;
+    /** Student view. */
+    submitted?: boolean;
+  }>;
+  quizzes: OverviewSection<{
+    id: number;
+    title: string;
+    start_time?: string;
+    end_time?: string;
+    time_limit: number;
+    is_published: boolean;
+    /** Includes attempts granted to the student in the student view. */
+    max_attempts: number;
+    /** Student view. */
+    attempts_used?: number;
+  }>;
+  announcements: OverviewSection<{
+    id: number;
+    title: string;
+    pinned: boolean;
+    created_at: string;
+    read: boolean;
+  }>;
+  attendance: {
+    sessions: number;
+    /** Student view; rate is absent before the first session. */
+    attended?: number;
+    rate?: number;
+    /** Staff view. */
+    active_session?: { id: number; code: string; ends_at: string };
+  };
+  progress?: {
+    completed_chapters: number;
+    total_chapters: number;
+    pending_assignments: number;
+    pending_quizzes: number;
+    unread_announcements: number;
+  };
+  staff?: {
+    students: number;
+    pending_grading: number;
+    unpublished_quizzes: number;
+    unread_announcements: number;
+  };
+};
+
+/** Thresholds (rates 0–1, quiz average a percentage) and weights of the at-risk list. */
+export type RiskPolicy = {
+  min_attendance_rate: number;
+  min_submission_rate: number;
+  min_quiz_average: number;
+  attendance_weight: number;
+  submission_weight: number;
+  quiz_weight: number;
+};
+
+export type AtRiskReport = {
+  policy: RiskPolicy;
+  /** Students enrolled, flagged or not. */
+  students: number;
+  /** Riskiest first. */
+  items: {
+    student_id: number;
+    username: string;
+    name: string;
+    /** null when there is nothing to rate yet. */
+    attendance_rate: number | null;
+    submission_rate: number | null;
+    quiz_average: number | null;
+    /** 0 to 1. */
+    risk_score: number;
+    reasons: { signal: 'attendance' | 'submission' | 'quiz'; value: number; threshold: number }[];
+  }[];
+};
+
+export type CoursePurgeResult = {
+  course_id: number;
+  name: string;
+  /** Rows deleted per table, e.g. { quizzes: 3, quiz_attempts: 120 }. */
+  deleted: Record<string, number>;
+};
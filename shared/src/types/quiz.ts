@@ -12,8 +12,14 @@ export type Quiz = {
   end_time?: string | null;
   max_attempts?: number;
   show_answer_after_end?: boolean;
+  /** Reject submissions with unanswered questions before the deadline. */
+  require_all_answered?: boolean;
   is_published?: boolean;
   total_points?: number;
+  /** Scores are reported out of this total when set; 0 means raw points. */
+  normalize_to?: number;
+  /** normalize_to / total_points, fixed on publish; 0 means raw points. */
+  score_scale?: number;
   status?: 'not_started' | 'in_progress' | 'completed';
   score?: number;
   max_score?: number;
@@ -26,15 +32,59 @@ export type QuizWithAttempt = Quiz & {
   best_score: number | null;
 };
 
+export type QuizAvailability = 'available' | 'upcoming' | 'ended';
+
+/** A published quiz from one of the student's courses, window in the course's time zone. */
+export type StudentQuiz = QuizWithAttempt & {
+  course_name: string;
+  status: QuizAvailability;
+};
+
+export type StudentQuizPage = {
+  items: StudentQuiz[];
+  total: number;
+  page: number;
+  page_size: number;
+};
+
+export type StudentQuizQuery = {
+  status?: QuizAvailability;
+  page?: number;
+  page_size?: number;
+};
+
+/** Quiz rules and the caller's attempts, shown before starting; no questions. */
+export type QuizInfo = {
+  quiz_id: number;
+  title: string;
+  description: string;
+  /** Minutes, 0 = unlimited. */
+  time_limit: number;
+  start_time?: string;
+  end_time?: string;
+  time_zone: string;
+  status: 'not_started' | 'open' | 'ended';
+  question_count: number;
+  max_score: number;
+  require_all_answered: boolean;
+  /** Includes granted attempts; an in-progress attempt counts as used. */
+  max_attempts: number;
+  attempts_used: number;
+  attempts_remaining: number;
+  in_progress: boolean;
+  attempt_deadline?: string;
+};
+
 export type Question = {
   ID: number;
   quiz_id: number;
-  type: 'single_choice' | 'multiple_choice' | 'true_false' | 'fill_blank' | 'text';
+  type: 'single_choice' | 'multiple_choice' | 'true_false' | 'fill_blank' | 'ordering' | 'matching' | 'text';
   content: string;
   options?: string[] | string;
   match_rule?: string;
   points?: number;
   order_num?: number;
+  tags?: string[];
 };
 
 export type QuestionWithAnswer = Question & {
@@ -54,27 +104,130 @@ export type QuizAttempt = {
   max_score: number;
 };
 
+export type SnapshotQuestion = Question & {
+  student_answer?: unknown;
+};
+
+export type AttemptSnapshot = {
+  attempt_id: number;
+  attempt_number: number;
+  questions: SnapshotQuestion[];
+};
+
+// Where a submitted attempt came from. Only returned to course staff.
+export type AttemptOrigin = {
+  attempt_id: number;
+  student_id: number;
+  ip: string;
+  user_agent: string;
+};
+
 export type CreateQuizRequest = {
   course_id: number;
+  /** A chapter of the same course; on update, null detaches the quiz. */
+  chapter_id?: number | null;
   title: string;
   description?: string;
   time_limit?: number;
+  /** RFC 3339, or "YYYY-MM-DDTHH:MM[:SS]" read in the course's time zone. */
   start_time?: string;
   end_time?: string;
   max_attempts?: number;
   show_answer_after_end?: boolean;
+  require_all_answered?: boolean;
 };
 
 export type CreateQuestionRequest = {
-  type: 'single_choice' | 'multiple_choice' | 'true_false' | 'fill_blank';
+  type: 'single_choice' | 'multiple_choice' | 'true_false' | 'fill_blank' | 'ordering' | 'matching';
   content: string;
+  /** For matching: the prompts followed by the same number of targets. */
   options?: string[];
+  /** JSON for ordering (items in order) and matching ({prompt: target}). */
   answer: string;
   match_rule?: string;
   points?: number;
   order_num?: number;
+  /** Up to 10 labels of 1-32 characters; repeats are dropped case-insensitively. */
+  tags?: string[];
 };
 
 export type SubmitQuizRequest = {
-  answers: Record<string, string | string[]>;
+  answers: Record<string, string | string[] | Record<string, string>>;
+};
+
+export type RegradeQuizRequest = {
+  /** Corrected answers, applied before re-scoring; allowed on published quizzes. */
+  corrections?: Array<{ question_id: number; answer: string }>;
+};
+
+export type RegradeQuizResult = {
+  quiz_id: number;
+  questions_corrected: number;
+  attempts_checked: number;
+  attempts_changed: number;
+};
+
+export type QuizResultRow = {
+  student_id: number;
+  username: string;
+  name: string;
+  attempts: number;
+  best_score: number | null;
+  latest_score: number | null;
+  time_spent_seconds: number;
+  last_submitted_at: string | null;
+};
+
+export type BulkQuizResult = {
+  quiz_id: number;
+  success: boolean;
+  /** 'attempts_exist' when a quiz with attempts cannot be unpublished. */
+  error?: string;
+  quiz?: Quiz;
+};
+
+/** Result of a CSV question import; rows that failed are skipped and listed by line. */
+export type QuestionImportResult = {
+  imported: QuestionWithAnswer[];
+  errors: Array<{ line: number; column?: string; message: string; details?: Record<string, unknown> }>;
+};
+
+export type NormalizationPreview = {
+  quiz_id: number;
+  total_points: number;
+  normalize_to: number;
+  scale: number;
+  questions: Array<{ question_id: number; points: number; weighted_points: number }>;
+};
+
+export type QuizFeedbackRequest = {
+  /** 1 (easy) to 5 (hard). */
+  difficulty: number;
+  /** 1 (guessing) to 5 (sure). */
+  confidence?: number;
+  comment?: string;
+};
+
+export type QuizFeedback = QuizFeedbackRequest & {
+  ID: number;
+  attempt_id: number;
+  quiz_id: number;
+  student_id: number;
+};
+
+export type QuizFeedbackSummary = {
+  quiz_id: number;
+  responses: number;
+  average_difficulty: number | null;
+  /** Index 0 counts difficulty 1. */
+  difficulty_counts: [number, number, number, number, number];
+  average_confidence: number | null;
+  /** Newest first, without student identities. */
+  comments: Array<{ difficulty: number; comment: string; created_at: string }>;
+};
+
+export type QuizResults = {
+  quiz: Quiz;
+  max_score: number;
+  students: QuizResultRow[];
 };
@@ -30,6 +30,8 @@ export type WritingSubmission = {
   word_count: number;
   feedback_json?: string;
   dimension_json?: string;
+  /** Drafts are only visible to their author and are analyzed once finalized. */
+  draft?: boolean;
   created_at: string;
   updated_at?: string;
   CreatedAt?: string;
@@ -60,3 +62,23 @@ export type StudentGlobalProfile = {
   updated_at?: string;
   competencies?: Record<string, number>;
 };
+
+export type WritingTrendPoint = {
+  submission_id: number;
+  course_id: number;
+  writing_type: WritingType;
+  title: string;
+  submitted_at: string;
+  overall_score: number;
+  /** Dimension name to score. */
+  dimensions: Record<string, number>;
+};
+
+export type WritingTrend = {
+  student_id: number;
+  course_id?: number;
+  type?: WritingType;
+  /** Every dimension name present in any point, sorted. */
+  dimensions: string[];
+  points: WritingTrendPoint[];
+};
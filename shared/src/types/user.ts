@@ -46,3 +46,33 @@ export type LearningStats = {
   total_quizzes: number;
   average_quiz_score?: number;
 };
+
+export type UpcomingItem = {
+  type: 'assignment' | 'quiz';
+  id: number;
+  course_id: number;
+  course_name: string;
+  title: string;
+  due_at: string;
+  attempts_used?: number;
+  max_attempts?: number;
+};
+
+export type StudentCourseSummary = {
+  course_id: number;
+  course_name: string;
+  pending_assignments: number;
+  upcoming_quizzes: number;
+  attendance_rate: number | null;
+};
+
+export type TeacherCourseSummary = {
+  course_id: number;
+  course_name: string;
+  pending_grading: number;
+  active_session: { id: number; code: string; ends_at: string } | null;
+};
+
+export type Dashboard =
+  | { role: 'student'; courses: StudentCourseSummary[]; upcoming?: UpcomingItem[] }
+  | { role: 'teacher'; courses: TeacherCourseSummary[] };